@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	gozero "github.com/magic-lib/go-servicekit/go-zero"
+	"golang.org/x/mod/modfile"
+)
+
+const (
+	stepService = "service"
+	stepModel   = "model"
+	stepStatic  = "static"
+	stepWire    = "wire"
+)
+
+var allSteps = []string{stepService, stepModel, stepStatic, stepWire}
+
+// generator 按清单生成项目，所有命令都在项目目录中执行
+type generator struct {
+	m    *Manifest
+	dir  string
+	dsn  string //命令行传入的 DSN，优先于环境变量，不会写入清单
+	out  io.Writer
+	home string
+	// secret 输出命令和错误时隐藏的内容，即使用的 DSN
+	secret string
+}
+
+func newGenerator(m *Manifest, dir, dsn string, out io.Writer) (*generator, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &generator{m: m, dir: abs, dsn: dsn, out: out}, nil
+}
+
+// run 依次执行各个步骤，steps 为空表示全部执行
+func (g *generator) run(steps []string) error {
+	enabled := map[string]bool{}
+	if len(steps) == 0 {
+		steps = allSteps
+	}
+	for _, step := range steps {
+		found := false
+		for _, s := range allSteps {
+			found = found || s == step
+		}
+		if !found {
+			return fmt.Errorf("unknown step %q, must be one of %s", step, strings.Join(allSteps, ","))
+		}
+		enabled[step] = true
+	}
+
+	home, cleanup, err := g.templateHome()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	g.home = home
+
+	if enabled[stepService] {
+		if err = g.ensureGoMod(); err != nil {
+			return err
+		}
+		if err = g.genService(); err != nil {
+			return err
+		}
+	}
+	if enabled[stepModel] && g.m.Model != nil {
+		if err = g.genModel(); err != nil {
+			return err
+		}
+	}
+	if enabled[stepStatic] && g.m.Docker {
+		if err = g.genStatic(); err != nil {
+			return err
+		}
+	}
+	if enabled[stepWire] && g.m.Wire.any() {
+		if err = g.wire(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateHome 返回 goctl 的模版目录，未指定时把内置模版解压到临时目录
+func (g *generator) templateHome() (string, func(), error) {
+	if g.m.Home != "" {
+		return g.path(g.m.Home), func() {}, nil
+	}
+	tmp, err := os.MkdirTemp("", "servicekit-tmpl-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(tmp)
+	}
+	root, err := fs.Sub(gozero.Files, "goctl-tmpl")
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if err = copyFS(tmp, root); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp, cleanup, nil
+}
+
+func copyFS(dst string, src fs.FS) error {
+	return fs.WalkDir(src, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, filepath.FromSlash(path))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		data, err := fs.ReadFile(src, path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+}
+
+// path 清单中的相对路径都相对于项目目录
+func (g *generator) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(g.dir, p)
+}
+
+func (g *generator) ensureGoMod() error {
+	data, err := os.ReadFile(g.path("go.mod"))
+	if err == nil {
+		if module := modfile.ModulePath(data); module != g.m.Module {
+			return fmt.Errorf("go.mod declares module %q, but the manifest has %q", module, g.m.Module)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	return g.command("go", "mod", "init", g.m.Module)
+}
+
+func (g *generator) goctl(args ...string) error {
+	goctl := g.m.Goctl
+	if goctl == "" {
+		goctl = "goctl"
+	}
+	path, err := exec.LookPath(goctl)
+	if err != nil {
+		return fmt.Errorf("goctl not found, install it with `go install github.com/zeromicro/go-zero/tools/goctl@latest`: %w", err)
+	}
+	return g.command(path, args...)
+}
+
+func (g *generator) command(name string, args ...string) error {
+	line := filepath.Base(name) + " " + strings.Join(args, " ")
+	if g.secret != "" {
+		line = strings.ReplaceAll(line, g.secret, "***")
+	}
+	_, _ = fmt.Fprintf(g.out, "servicekit: %s\n", line)
+	cmd := exec.Command(name, args...)
+	cmd.Dir = g.dir
+	cmd.Stdout = g.out
+	cmd.Stderr = g.out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", line, err)
+	}
+	return nil
+}
+
+func (g *generator) genService() error {
+	switch g.m.Type {
+	case serviceAPI:
+		if err := g.writeIfMissing(g.m.API, "newapi/newtemplate.tpl", map[string]any{
+			"name":    g.m.Name,
+			"handler": camel(g.m.Name),
+		}); err != nil {
+			return err
+		}
+		return g.goctl("api", "go", "--api", g.m.API, "--dir", ".", "--home", g.home, "--style", g.m.Style)
+	case serviceRPC:
+		if g.m.Proto.FromModel {
+			if err := g.genProto(); err != nil {
+				return err
+			}
+		}
+		if err := g.writeIfMissing(g.m.Proto.File, "rpc/template.tpl", map[string]any{
+			"package":     strings.ReplaceAll(strings.ToLower(g.m.Name), "-", "_"),
+			"serviceName": camel(g.m.Name),
+		}); err != nil {
+			return err
+		}
+		args := []string{"rpc", "protoc", g.m.Proto.File,
+			"--go_out=.", "--go-grpc_out=.", "--zrpc_out=.",
+			"--proto_path=" + filepath.Dir(g.m.Proto.File)}
+		for _, p := range g.m.Proto.Paths {
+			args = append(args, "--proto_path="+p)
+		}
+		args = append(args, "--home", g.home, "--style", g.m.Style)
+		return g.goctl(args...)
+	}
+	return fmt.Errorf("unknown service type %q", g.m.Type)
+}
+
+// writeIfMissing 用模版生成 api 或 proto 的初始文件，已存在时不覆盖
+func (g *generator) writeIfMissing(file, tpl string, data map[string]any) error {
+	target := g.path(file)
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	text, err := os.ReadFile(filepath.Join(g.home, filepath.FromSlash(tpl)))
+	if err != nil {
+		return err
+	}
+	t, err := template.New(tpl).Parse(string(text))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, data); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(g.out, "servicekit: create %s\n", file)
+	return os.WriteFile(target, buf.Bytes(), 0o644)
+}
+
+// modelDSN 返回 model 使用的 DSN，命令行参数优先于环境变量
+func (g *generator) modelDSN() (string, error) {
+	dsn := g.dsn
+	if dsn == "" {
+		dsn = os.Getenv(g.m.Model.DSNEnv)
+	}
+	if dsn == "" {
+		return "", fmt.Errorf("no database dsn to generate from, set $%s or pass -dsn", g.m.Model.DSNEnv)
+	}
+	g.secret = dsn
+	return dsn, nil
+}
+
+// genProto 用 sql2pb 从 model 的表生成 proto，sql2pb 会合并已存在的 proto 文件
+func (g *generator) genProto() error {
+	model := g.m.Model
+	name := strings.ReplaceAll(strings.ToLower(g.m.Name), "-", "_")
+	args := []string{"-db", model.Dialect, "-table", model.Table,
+		"-service_name", camel(g.m.Name), "-package", name, "-go_package", "./" + name,
+		"-field_style", "sql_pb", "-out", g.m.Proto.File}
+	if len(model.DDL) > 0 {
+		args = append(args, "-ddl", strings.Join(model.DDL, ","))
+	} else {
+		dsn, err := g.modelDSN()
+		if err != nil {
+			return err
+		}
+		args = append(args, "-dsn", dsn, "-schema", model.Schema)
+	}
+	sql2pb := g.m.Proto.Sql2pb
+	if sql2pb == "" {
+		sql2pb = "sql2pb"
+	}
+	path, err := exec.LookPath(sql2pb)
+	if err != nil {
+		return fmt.Errorf("sql2pb not found, install it with `go install github.com/Mikaelemmmm/sql2pb@latest`: %w", err)
+	}
+	return g.command(path, args...)
+}
+
+// genModel 生成 goctl model，先删除上次生成的 _gen.go 文件，表删除后不会留下旧的 model
+func (g *generator) genModel() error {
+	model := g.m.Model
+	var commands [][]string
+	switch {
+	case len(model.DDL) > 0:
+		if model.Dialect != "mysql" {
+			return fmt.Errorf("goctl only generates models from mysql ddl, not %s", model.Dialect)
+		}
+		for _, pattern := range model.DDL {
+			files, err := filepath.Glob(g.path(pattern))
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no ddl file matches %s", pattern)
+			}
+			for _, file := range files {
+				commands = append(commands, []string{"model", "mysql", "ddl", "--src", file})
+			}
+		}
+	default:
+		dsn, err := g.modelDSN()
+		if err != nil {
+			return err
+		}
+		switch model.Dialect {
+		case "mysql":
+			commands = append(commands, []string{"model", "mysql", "datasource", "--url", dsn, "--table", model.Table})
+		case "postgres":
+			args := []string{"model", "pg", "datasource", "--url", dsn, "--table", model.Table}
+			if model.Schema != "" {
+				args = append(args, "--schema", model.Schema)
+			}
+			commands = append(commands, args)
+		default:
+			return fmt.Errorf("goctl doesn't generate models for %s", model.Dialect)
+		}
+	}
+
+	generated, err := filepath.Glob(filepath.Join(g.path(model.Dir), "*_gen.go"))
+	if err != nil {
+		return err
+	}
+	for _, file := range generated {
+		if err = os.Remove(file); err != nil {
+			return err
+		}
+	}
+	for _, args := range commands {
+		args = append(args, "--dir", model.Dir, "--home", g.home, "--style", g.m.Style)
+		if model.Cache {
+			args = append(args, "--cache")
+		}
+		if err = g.goctl(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// genStatic 生成 Dockerfile 和 .gitlab-ci.yml，已存在的文件由项目自行维护，不覆盖
+func (g *generator) genStatic() error {
+	files := map[string]string{
+		"Dockerfile":     "static-file/Dockerfile",
+		".gitlab-ci.yml": "static-file/.gitlab-ci.yml",
+	}
+	if g.m.Type == serviceRPC {
+		files[".gitlab-ci.yml"] = "static-file/grpc/.gitlab-ci.yml"
+	}
+	for _, target := range []string{"Dockerfile", ".gitlab-ci.yml"} {
+		path := g.path(target)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		data, err := fs.ReadFile(gozero.Files, files[target])
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(g.out, "servicekit: create %s\n", target)
+		if err = os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// camel 把 user-center、user_center 转换为 UserCenter
+func camel(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if r == '-' || r == '_' || r == '.' {
+			upper = true
+			continue
+		}
+		if upper && r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
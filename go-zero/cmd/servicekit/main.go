@@ -0,0 +1,189 @@
+// servicekit 按项目清单（servicekit.yaml）用 goctl 和 servicekit 的模版生成 go-zero 项目：
+// api 或 rpc 服务、goctl model、Dockerfile，以及 tracer、consul、oauth2 的接入代码。
+//
+//	servicekit init -type api -name account -module example.com/account -tracer -ddl "sql/*.sql" -model_dir internal/model
+//	servicekit gen
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintln(os.Stderr, "servicekit:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		usage(stderr)
+		return flag.ErrHelp
+	}
+	switch args[0] {
+	case "init":
+		return runInit(args[1:], stdout, stderr)
+	case "gen":
+		return runGen(args[1:], stdout, stderr)
+	case "-h", "--help", "help":
+		usage(stdout)
+		return nil
+	}
+	usage(stderr)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprint(w, `Usage: servicekit <command> [flags]
+
+Commands:
+  init   write servicekit.yaml for a new api or rpc service and generate it
+  gen    regenerate the project from servicekit.yaml
+
+Run 'servicekit <command> -h' for the flags of a command.
+`)
+}
+
+// listFlag 可以重复指定，或者用逗号分隔的参数
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+func runInit(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		typ        = fs.String("type", serviceAPI, "the service type, api | rpc")
+		name       = fs.String("name", "", "the service name")
+		dir        = fs.String("dir", "", "the project directory, defaults to ./<name>")
+		module     = fs.String("module", "", "the go module of the project, defaults to the name")
+		style      = fs.String("style", "go_zero", "the goctl file style")
+		home       = fs.String("home", "", "the goctl template home, defaults to the templates built into servicekit")
+		goctl      = fs.String("goctl", "", "the goctl binary, defaults to goctl in PATH")
+		apiFile    = fs.String("api", "", "the api file of an api service, created from the template if missing, defaults to <name>.api")
+		protoFile  = fs.String("proto", "", "the proto file of an rpc service, created from the template if missing, defaults to <name>.proto")
+		protoModel = fs.Bool("proto_from_model", false, "generate the proto of an rpc service from the model tables with sql2pb")
+		sql2pb     = fs.String("sql2pb", "", "the sql2pb binary, defaults to sql2pb in PATH")
+		withTracer = fs.Bool("tracer", false, "wire in the servicekit tracer")
+		withConsul = fs.Bool("consul", false, "register the service to consul")
+		withOAuth2 = fs.Bool("oauth2", false, "serve the oauth2 client_credentials token endpoint, api only")
+		docker     = fs.Bool("docker", true, "create the Dockerfile and .gitlab-ci.yml")
+		modelDir   = fs.String("model_dir", "", "also generate goctl models into the dir")
+		dialect    = fs.String("dialect", "mysql", "the database of the models, mysql | postgres")
+		dsnEnv     = fs.String("dsn_env", "", "the environment variable with the database dsn of the models, defaults to "+defaultDSNEnv)
+		dsn        = fs.String("dsn", "", "the database dsn for this run only, it is never written to the manifest")
+		table      = fs.String("table", "*", "the tables of the models")
+		schema     = fs.String("schema", "", "the postgres schema of the models")
+		cache      = fs.Bool("model_cache", false, "generate models with cache")
+		force      = fs.Bool("force", false, "overwrite an existing servicekit.yaml")
+	)
+	var protoPaths, ddl listFlag
+	fs.Var(&protoPaths, "proto_path", "extra proto import paths of an rpc service, repeated or ',' split")
+	fs.Var(&ddl, "ddl", "generate the models from ddl files instead of a database, patterns repeated or ',' split")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("init: -name is required")
+	}
+	if *dir == "" {
+		*dir = *name
+	}
+	if *module == "" {
+		*module = *name
+	}
+
+	m := &Manifest{
+		Version: manifestVersion,
+		Name:    *name,
+		Type:    *typ,
+		Module:  *module,
+		Style:   *style,
+		Home:    *home,
+		Goctl:   *goctl,
+		API:     *apiFile,
+		Wire:    Wire{Tracer: *withTracer, Consul: *withConsul, OAuth2: *withOAuth2},
+		Docker:  *docker,
+	}
+	if *typ == serviceRPC {
+		m.Proto = &Proto{File: *protoFile, Paths: protoPaths, FromModel: *protoModel, Sql2pb: *sql2pb}
+	} else if *protoFile != "" || len(protoPaths) > 0 || *protoModel || *sql2pb != "" {
+		return errors.New("init: -proto, -proto_path, -proto_from_model and -sql2pb are only used by rpc services")
+	}
+	if *modelDir != "" {
+		m.Model = &Model{
+			Dir:     *modelDir,
+			Dialect: *dialect,
+			DDL:     ddl,
+			DSNEnv:  *dsnEnv,
+			Table:   *table,
+			Schema:  *schema,
+			Cache:   *cache,
+		}
+	} else if len(ddl) > 0 || *dsnEnv != "" || *dsn != "" {
+		return errors.New("init: -ddl, -dsn and -dsn_env need -model_dir")
+	}
+	if err := m.validate(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(*dir, manifestFile)); err == nil && !*force {
+		return fmt.Errorf("init: %s already exists, run servicekit gen or use -force", filepath.Join(*dir, manifestFile))
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+	if err := m.save(*dir); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(stdout, "servicekit: create %s\n", filepath.Join(*dir, manifestFile))
+
+	g, err := newGenerator(m, *dir, *dsn, stdout)
+	if err != nil {
+		return err
+	}
+	return g.run(nil)
+}
+
+func runGen(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		file = fs.String("f", manifestFile, "the manifest, the project directory is the directory of the manifest")
+		dsn  = fs.String("dsn", "", "the database dsn of the models, overrides the dsn_env of the manifest, never written to the manifest")
+	)
+	var only listFlag
+	fs.Var(&only, "only", "only run the steps, "+strings.Join(allSteps, ",")+", repeated or ',' split")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	m, err := loadManifest(*file)
+	if err != nil {
+		return err
+	}
+	g, err := newGenerator(m, filepath.Dir(*file), *dsn, stdout)
+	if err != nil {
+		return err
+	}
+	return g.run(only)
+}
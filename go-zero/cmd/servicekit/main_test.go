@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeGoctl 记录调用参数，并像 goctl api go 一样生成配置、main 和 etc 文件
+const fakeGoctl = `#!/bin/sh
+echo "$@" >> "$GOCTL_LOG"
+if [ "$1 $2" = "api go" ]; then
+  mkdir -p internal/config etc
+  cat > internal/config/config.go <<'EOF'
+package config
+
+import "github.com/zeromicro/go-zero/rest"
+
+type Config struct {
+	RestConf rest.RestConf
+}
+EOF
+  cat > account.go <<'EOF'
+package main
+
+import (
+	"flag"
+
+	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/rest"
+)
+
+var configFile = flag.String("f", "etc/account.yaml", "the config file")
+
+func main() {
+	flag.Parse()
+
+	var c config.Config
+	conf.MustLoad(*configFile, &c)
+
+	server := rest.MustNewServer(c.RestConf)
+	defer server.Stop()
+
+	server.Start()
+}
+EOF
+  printf 'RestConf:\n  Name: account-http\n' > etc/account.yaml
+fi
+`
+
+func setupProject(t *testing.T) (dir, goctl, log string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake goctl is a shell script")
+	}
+	dir = t.TempDir()
+	goctl = filepath.Join(t.TempDir(), "goctl")
+	if err := os.WriteFile(goctl, []byte(fakeGoctl), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	log = filepath.Join(t.TempDir(), "goctl.log")
+	t.Setenv("GOCTL_LOG", log)
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/account\n\ngo 1.24\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, goctl, log
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestInitAndGen(t *testing.T) {
+	dir, goctl, log := setupProject(t)
+	const dsn = "root:secret@tcp(127.0.0.1:3306)/account"
+	t.Setenv("ACCOUNT_DSN", dsn)
+
+	var out bytes.Buffer
+	err := run([]string{"init", "-name", "account", "-dir", dir, "-module", "example.com/account",
+		"-goctl", goctl, "-tracer", "-consul", "-oauth2",
+		"-model_dir", "internal/model", "-dsn_env", "ACCOUNT_DSN"}, &out, &out)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	if strings.Contains(out.String(), dsn) {
+		t.Fatalf("dsn leaked into the output:\n%s", out.String())
+	}
+
+	manifest := readFile(t, filepath.Join(dir, manifestFile))
+	if strings.Contains(manifest, "secret") || !strings.Contains(manifest, "dsn_env: ACCOUNT_DSN") {
+		t.Fatalf("unexpected manifest:\n%s", manifest)
+	}
+	calls := readFile(t, log)
+	for _, want := range []string{
+		"api go --api account.api --dir . --home ",
+		"model mysql datasource --url " + dsn + " --table * --dir internal/model --home ",
+	} {
+		if !strings.Contains(calls, want) {
+			t.Fatalf("goctl not called with %q:\n%s", want, calls)
+		}
+	}
+	for _, file := range []string{"account.api", "Dockerfile", ".gitlab-ci.yml", "internal/servicekit/servicekit.go"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mainSrc := readFile(t, filepath.Join(dir, "account.go"))
+	for _, want := range []string{
+		`"example.com/account/internal/servicekit"`,
+		"kit := servicekit.MustNew(c.Servicekit, &c.RestConf.ServiceConf)",
+		"kit.MustRegisterRest(server, c.RestConf.Host, c.RestConf.Port)",
+	} {
+		if !strings.Contains(mainSrc, want) {
+			t.Fatalf("main not wired with %q:\n%s", want, mainSrc)
+		}
+	}
+	if !strings.Contains(readFile(t, filepath.Join(dir, configFile)), "Servicekit servicekit.Config") {
+		t.Fatal("config not wired")
+	}
+	etc := readFile(t, filepath.Join(dir, "etc/account.yaml"))
+	if !strings.Contains(etc, "Servicekit:") || !strings.Contains(etc, "  Consul:") {
+		t.Fatalf("etc not wired:\n%s", etc)
+	}
+
+	// 重新生成：goctl 不会覆盖已存在的 main 和配置，接入代码不重复
+	out.Reset()
+	if err = run([]string{"gen", "-f", filepath.Join(dir, manifestFile)}, &out, &out); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	if got := readFile(t, filepath.Join(dir, "account.go")); got != mainSrc {
+		t.Fatalf("main changed on regeneration:\n%s", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "etc/account.yaml")); got != etc {
+		t.Fatalf("etc changed on regeneration:\n%s", got)
+	}
+
+	t.Setenv("ACCOUNT_DSN", "")
+	err = run([]string{"gen", "-f", filepath.Join(dir, manifestFile), "-only", "model"}, &out, &out)
+	if err == nil || !strings.Contains(err.Error(), "$ACCOUNT_DSN") {
+		t.Fatalf("expected missing dsn error, got %v", err)
+	}
+}
+
+func TestInitValidation(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	cases := [][]string{
+		{"init", "-dir", dir},
+		{"init", "-name", "a", "-dir", dir, "-type", "grpc"},
+		{"init", "-name", "a", "-dir", dir, "-type", "rpc", "-oauth2"},
+		{"init", "-name", "a", "-dir", dir, "-proto", "a.proto"},
+		{"init", "-name", "a", "-dir", dir, "-ddl", "a.sql"},
+		{"init", "-name", "a", "-dir", dir, "-model_dir", "model", "-ddl", "a.sql", "-dsn_env", "DSN"},
+		{"init", "-name", "a", "-dir", dir, "-proto_from_model"},
+		{"init", "-name", "a", "-dir", dir, "-type", "rpc", "-proto_from_model"},
+		{"init", "-name", "a", "-dir", dir, "-type", "rpc", "-proto_from_model", "-model_dir", "model"},
+	}
+	for _, args := range cases {
+		if err := run(args, &out, &out); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, manifestFile)); !os.IsNotExist(err) {
+		t.Fatal("invalid init should not write the manifest")
+	}
+}
+
+func TestInitRpcFromModel(t *testing.T) {
+	dir, goctl, log := setupProject(t)
+	sql2pb := filepath.Join(t.TempDir(), "sql2pb")
+	script := "#!/bin/sh\necho sql2pb \"$@\" >> \"$GOCTL_LOG\"\necho 'syntax = \"proto3\";' > account.proto\n"
+	if err := os.WriteFile(sql2pb, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "account.sql"), []byte("CREATE TABLE user (id bigint PRIMARY KEY);"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err := run([]string{"init", "-type", "rpc", "-name", "account", "-dir", dir, "-module", "example.com/account",
+		"-goctl", goctl, "-sql2pb", sql2pb, "-proto_from_model", "-docker=false",
+		"-model_dir", "internal/model", "-ddl", "*.sql"}, &out, &out)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	calls := strings.Split(strings.TrimSpace(readFile(t, log)), "\n")
+	want := []string{
+		"sql2pb -db mysql -table * -service_name Account -package account -go_package ./account -field_style sql_pb -out account.proto -ddl *.sql",
+		"rpc protoc account.proto --go_out=. --go-grpc_out=. --zrpc_out=. --proto_path=. --home ",
+		"model mysql ddl --src " + filepath.Join(dir, "account.sql") + " --dir internal/model --home ",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("unexpected calls:\n%s", strings.Join(calls, "\n"))
+	}
+	for i, call := range calls {
+		if !strings.HasPrefix(call, want[i]) {
+			t.Fatalf("call %d is %q, want prefix %q", i, call, want[i])
+		}
+	}
+	if _, err = os.Stat(filepath.Join(dir, "Dockerfile")); !os.IsNotExist(err) {
+		t.Fatal("Dockerfile created with -docker=false")
+	}
+}
+
+func TestRenderWire(t *testing.T) {
+	for _, typ := range []string{serviceAPI, serviceRPC} {
+		for mask := 1; mask < 8; mask++ {
+			w := Wire{Tracer: mask&1 != 0, Consul: mask&2 != 0, OAuth2: mask&4 != 0}
+			if typ == serviceRPC && w.OAuth2 {
+				continue
+			}
+			if _, err := renderWire(wireData{Wire: w, Type: typ, Name: "account"}); err != nil {
+				t.Errorf("%s %+v: %v", typ, w, err)
+			}
+		}
+	}
+}
+
+func TestPatchMainRpc(t *testing.T) {
+	src := "package main\n\nfunc main() {\n\tconf.MustLoad(*configFile, &c)\n\ts := zrpc.MustNewServer(c.RpcServerConf, nil)\n\tdefer s.Stop()\n}\n"
+	got, err := patchMain(src, "example.com/greet/internal/servicekit", serviceRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\tkit := servicekit.MustNew(c.Servicekit, &c.RpcServerConf.ServiceConf)\n\tdefer kit.Stop()\n",
+		"\tdefer s.Stop()\n\tkit.MustRegisterRpc(c.RpcServerConf.ListenOn)\n",
+		"import \"example.com/greet/internal/servicekit\"",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in:\n%s", want, got)
+		}
+	}
+	if _, err = patchMain("package main\n", "x", serviceAPI); err == nil {
+		t.Fatal("expected error without conf.MustLoad")
+	}
+}
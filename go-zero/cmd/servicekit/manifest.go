@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	manifestFile    = "servicekit.yaml"
+	manifestVersion = 1
+
+	serviceAPI = "api"
+	serviceRPC = "rpc"
+
+	defaultDSNEnv = "SERVICEKIT_DSN"
+)
+
+// Manifest 项目清单，记录生成项目时的全部参数，保证重新生成的结果一致。
+// 清单中不保存数据库密码等敏感信息，DSN 在生成时从环境变量读取
+type Manifest struct {
+	Version int    `yaml:"version"`
+	Name    string `yaml:"name"`
+	Type    string `yaml:"type"` //api 或 rpc
+	Module  string `yaml:"module"`
+	Style   string `yaml:"style,omitempty"`
+	Home    string `yaml:"home,omitempty"`  //goctl 模版目录，为空使用内置模版
+	Goctl   string `yaml:"goctl,omitempty"` //goctl 命令，默认使用 PATH 中的 goctl
+	API     string `yaml:"api,omitempty"`   //api 描述文件
+	Proto   *Proto `yaml:"proto,omitempty"`
+	Model   *Model `yaml:"model,omitempty"`
+	Wire    Wire   `yaml:"wire"`
+	Docker  bool   `yaml:"docker"` //生成 Dockerfile 和 .gitlab-ci.yml，已存在的文件不会覆盖
+}
+
+// Proto rpc 服务的 proto 文件
+type Proto struct {
+	File      string   `yaml:"file"`
+	Paths     []string `yaml:"paths,omitempty"`      //额外的 import 目录
+	FromModel bool     `yaml:"from_model,omitempty"` //用 sql2pb 从 model 的数据库或 DDL 生成 proto，已有的 tag 和自定义内容会保留
+	Sql2pb    string   `yaml:"sql2pb,omitempty"`     //sql2pb 命令，默认使用 PATH 中的 sql2pb
+}
+
+// Model goctl model 的生成参数，DDL 和 DSNEnv 二选一
+type Model struct {
+	Dir     string   `yaml:"dir"`
+	Dialect string   `yaml:"dialect,omitempty"`
+	DDL     []string `yaml:"ddl,omitempty"`     //DDL 文件，支持通配符
+	DSNEnv  string   `yaml:"dsn_env,omitempty"` //保存 DSN 的环境变量名
+	Table   string   `yaml:"table,omitempty"`
+	Schema  string   `yaml:"schema,omitempty"` //postgres 的 schema，从 mysql 生成 proto 时为库名
+	Cache   bool     `yaml:"cache,omitempty"`
+}
+
+// Wire 接入的 servicekit 组件
+type Wire struct {
+	Tracer bool `yaml:"tracer"`
+	Consul bool `yaml:"consul"`
+	OAuth2 bool `yaml:"oauth2"`
+}
+
+func (w Wire) any() bool {
+	return w.Tracer || w.Consul || w.OAuth2
+}
+
+// validate 检查清单并补充默认值
+func (m *Manifest) validate() error {
+	if m.Version != manifestVersion {
+		return fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	if m.Name == "" {
+		return errors.New("manifest: name is empty")
+	}
+	if m.Module == "" {
+		return errors.New("manifest: module is empty")
+	}
+	switch m.Type {
+	case serviceAPI:
+		if m.API == "" {
+			m.API = m.Name + ".api"
+		}
+		if m.Proto != nil {
+			return errors.New("manifest: proto is only used by rpc services")
+		}
+	case serviceRPC:
+		if m.Proto == nil {
+			m.Proto = &Proto{}
+		}
+		if m.Proto.File == "" {
+			m.Proto.File = m.Name + ".proto"
+		}
+		if m.API != "" {
+			return errors.New("manifest: api is only used by api services")
+		}
+		if m.Wire.OAuth2 {
+			return errors.New("manifest: oauth2 token endpoint is only wired into api services")
+		}
+	default:
+		return fmt.Errorf("manifest: unknown service type %q, must be api or rpc", m.Type)
+	}
+	if m.Style == "" {
+		m.Style = "go_zero"
+	}
+	if m.Model != nil {
+		if m.Model.Dir == "" {
+			return errors.New("manifest: model dir is empty")
+		}
+		if m.Model.Dialect == "" {
+			m.Model.Dialect = "mysql"
+		}
+		if len(m.Model.DDL) > 0 && m.Model.DSNEnv != "" {
+			return errors.New("manifest: model ddl and dsn_env cannot be used together")
+		}
+		if len(m.Model.DDL) == 0 && m.Model.DSNEnv == "" {
+			m.Model.DSNEnv = defaultDSNEnv
+		}
+		if m.Model.Table == "" {
+			m.Model.Table = "*"
+		}
+	}
+	if m.Proto != nil && m.Proto.FromModel {
+		if m.Model == nil {
+			return errors.New("manifest: proto from_model needs a model")
+		}
+		if len(m.Model.DDL) == 0 && m.Model.Schema == "" {
+			return errors.New("manifest: proto from_model needs the model schema, the database of mysql")
+		}
+	}
+	return nil
+}
+
+func loadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Manifest)
+	if err = yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err = m.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m *Manifest) save(dir string) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	header := "# Generated by servicekit init, regenerate the project with `servicekit gen`.\n" +
+		"# Do not put credentials here: the database dsn is read from the dsn_env environment variable.\n"
+	return os.WriteFile(filepath.Join(dir, manifestFile), append([]byte(header), data...), 0o644)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+)
+
+const userDDL = "CREATE TABLE `user` (\n" +
+	"  `id` bigint unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `name` varchar(64) NOT NULL DEFAULT '',\n" +
+	"  `version` bigint NOT NULL DEFAULT 0,\n" +
+	"  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+	"  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+	"  `deleted_at` timestamp NULL DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `idx_name` (`name`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n"
+
+// modelGoMod 生成 model 所在项目的 go.mod，servicekit 和 tracer 使用本仓库的代码
+func modelGoMod(t *testing.T) []byte {
+	root, err := filepath.Abs("../../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err = range []error{
+		f.AddModuleStmt("example.com/account"),
+		f.AddRequire("github.com/magic-lib/go-servicekit", "v0.0.0"),
+		f.AddRequire("github.com/magic-lib/go-servicekit/tracer", "v0.0.0"),
+		f.AddReplace("github.com/magic-lib/go-servicekit", "", root, ""),
+		f.AddReplace("github.com/magic-lib/go-servicekit/tracer", "", filepath.Join(root, "tracer"), ""),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := f.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// TestGoctlModelTemplates 用 goctl 和内置模版从 DDL 生成带缓存和不带缓存的 model，并编译生成的代码。
+// 需要 PATH 中的 goctl（或 GOCTL 指定）和下载依赖的网络，-short 时跳过
+func TestGoctlModelTemplates(t *testing.T) {
+	if testing.Short() {
+		t.Skip("generating and building the models needs goctl and the network")
+	}
+	goctl := os.Getenv("GOCTL")
+	if goctl == "" {
+		goctl = "goctl"
+	}
+	goctl, err := exec.LookPath(goctl)
+	if err != nil {
+		t.Skip("goctl not found")
+	}
+	goMod := modelGoMod(t)
+
+	for _, cache := range []bool{false, true} {
+		name := "no_cache"
+		if cache {
+			name = "cache"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "go.mod"), goMod, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "user.sql"), []byte(userDDL), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			m := &Manifest{
+				Version: manifestVersion,
+				Name:    "account",
+				Type:    serviceAPI,
+				Module:  "example.com/account",
+				Style:   "go_zero",
+				Goctl:   goctl,
+				Model:   &Model{Dir: "internal/model", DDL: []string{"user.sql"}, Cache: cache},
+			}
+			if err := m.validate(); err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			g, err := newGenerator(m, dir, "", &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = g.run([]string{stepModel}); err != nil {
+				t.Fatalf("%v\n%s", err, out.String())
+			}
+
+			gen := readFile(t, filepath.Join(dir, "internal/model/usermodel_gen.go"))
+			for _, want := range []string{
+				`tracer.TraceModelConn(conn, "mysql", "` + "`user`" + `")`,
+				"m.tableInfo.WhereNotDeleted(whereCond)",
+				"m.tableInfo.MarkDeleted(ctx, oneSession, sql, list...)",
+				"m.tableInfo.AndNotDeleted()",
+			} {
+				if !strings.Contains(gen, want) {
+					t.Errorf("usermodel_gen.go doesn't contain %q", want)
+				}
+			}
+			if got := strings.Contains(gen, "sqlc.NewConn(tracedConn"); got != cache {
+				t.Errorf("cached conn in the model = %v, want %v", got, cache)
+			}
+
+			for _, args := range [][]string{{"mod", "tidy"}, {"build", "./..."}, {"vet", "./..."}} {
+				cmd := exec.Command("go", args...)
+				cmd.Dir = dir
+				if output, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed servicekit.go.tpl
+var wireTemplate string
+
+const (
+	wireDir      = "internal/servicekit"
+	wireFile     = "servicekit.go"
+	configFile   = "internal/config/config.go"
+	mustLoadLine = "conf.MustLoad(*configFile, &c)"
+)
+
+type wireData struct {
+	Wire
+	Type string
+	Name string
+}
+
+// wire 生成 internal/servicekit，并在 goctl 生成的配置、main 和 etc 配置文件中接入，
+// 已经接入的文件不会重复修改，重新生成是幂等的
+func (g *generator) wire() error {
+	code, err := renderWire(wireData{Wire: g.m.Wire, Type: g.m.Type, Name: g.m.Name})
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(g.path(wireDir), 0o755); err != nil {
+		return err
+	}
+	if err = os.WriteFile(g.path(filepath.Join(wireDir, wireFile)), code, 0o644); err != nil {
+		return err
+	}
+
+	importPath := g.m.Module + "/" + wireDir
+	if err = g.patchGo(configFile, func(src string) (string, error) {
+		return patchConfig(src, importPath)
+	}); err != nil {
+		return err
+	}
+	mainFile, err := g.findMain()
+	if err != nil {
+		return err
+	}
+	if err = g.patchGo(mainFile, func(src string) (string, error) {
+		return patchMain(src, importPath, g.m.Type)
+	}); err != nil {
+		return err
+	}
+
+	etcFiles, err := filepath.Glob(g.path("etc/*.yaml"))
+	if err != nil {
+		return err
+	}
+	for _, file := range etcFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		patched := patchEtc(string(data), g.m.Name, g.m.Wire)
+		if patched == string(data) {
+			continue
+		}
+		_, _ = fmt.Fprintf(g.out, "servicekit: wire %s\n", g.rel(file))
+		if err = os.WriteFile(file, []byte(patched), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderWire(data wireData) ([]byte, error) {
+	t, err := template.New(wireFile).Parse(wireTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func (g *generator) rel(path string) string {
+	if rel, err := filepath.Rel(g.dir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// findMain 查找 goctl 生成的 main 文件，文件名由服务名决定
+func (g *generator) findMain() (string, error) {
+	files, err := filepath.Glob(g.path("*.go"))
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(string(data), mustLoadLine) {
+			return g.rel(file), nil
+		}
+	}
+	return "", fmt.Errorf("no main file with %s found in %s", mustLoadLine, g.dir)
+}
+
+func (g *generator) patchGo(file string, patch func(src string) (string, error)) error {
+	path := g.path(file)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	patched, err := patch(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if patched == string(data) {
+		return nil
+	}
+	code, err := format.Source([]byte(patched))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	_, _ = fmt.Fprintf(g.out, "servicekit: wire %s\n", file)
+	return os.WriteFile(path, code, 0o644)
+}
+
+// addImport 在 package 语句后增加一个 import
+func addImport(src, importPath string) (string, error) {
+	quoted := fmt.Sprintf("%q", importPath)
+	if strings.Contains(src, quoted) {
+		return src, nil
+	}
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "package ") {
+			lines = append(lines[:i+1], append([]string{"", "import " + quoted}, lines[i+1:]...)...)
+			return strings.Join(lines, "\n"), nil
+		}
+	}
+	return "", fmt.Errorf("no package clause")
+}
+
+// insertAfter 在包含 anchor 的行后插入代码，保持该行的缩进
+func insertAfter(src, anchor string, code ...string) (string, error) {
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, anchor) {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		inserted := make([]string, 0, len(code))
+		for _, c := range code {
+			inserted = append(inserted, indent+c)
+		}
+		lines = append(lines[:i+1], append(inserted, lines[i+1:]...)...)
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", fmt.Errorf("%q not found, add the servicekit wiring by hand", anchor)
+}
+
+func patchConfig(src, importPath string) (string, error) {
+	if strings.Contains(src, "servicekit.Config") {
+		return src, nil
+	}
+	src, err := insertAfter(src, "type Config struct {", "\tServicekit servicekit.Config `json:\",optional\"`")
+	if err != nil {
+		return "", err
+	}
+	return addImport(src, importPath)
+}
+
+func patchMain(src, importPath, typ string) (string, error) {
+	if strings.Contains(src, "servicekit.MustNew(") {
+		return src, nil
+	}
+	srvConf, serverStop, register := "c.RestConf.ServiceConf", "defer server.Stop()", "kit.MustRegisterRest(server, c.RestConf.Host, c.RestConf.Port)"
+	if typ == serviceRPC {
+		srvConf, serverStop, register = "c.RpcServerConf.ServiceConf", "defer s.Stop()", "kit.MustRegisterRpc(c.RpcServerConf.ListenOn)"
+	}
+	src, err := insertAfter(src, mustLoadLine,
+		fmt.Sprintf("kit := servicekit.MustNew(c.Servicekit, &%s)", srvConf),
+		"defer kit.Stop()")
+	if err != nil {
+		return "", err
+	}
+	if src, err = insertAfter(src, serverStop, register); err != nil {
+		return "", err
+	}
+	return addImport(src, importPath)
+}
+
+// patchEtc 在配置文件末尾增加 Servicekit 配置，已存在时不修改
+func patchEtc(src, name string, w Wire) string {
+	if strings.Contains(src, "\nServicekit:") || strings.HasPrefix(src, "Servicekit:") {
+		return src
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(src, "\n"))
+	b.WriteString("\n\nServicekit: # servicekit 组件配置\n")
+	if w.Tracer {
+		b.WriteString("  Trace:\n")
+		b.WriteString("    Namespace: namespace\n")
+		fmt.Fprintf(&b, "    ServiceName: %s\n", name)
+		b.WriteString("    Endpoint: \"\" # 为空时不开启链路追踪\n")
+		b.WriteString("    SamplerPercent: 50\n")
+	}
+	if w.Consul {
+		b.WriteString("  Consul:\n")
+		b.WriteString("    Host: 127.0.0.1\n")
+		b.WriteString("    Port: \"8500\"\n")
+	}
+	if w.OAuth2 {
+		b.WriteString("  OAuth2:\n")
+		b.WriteString("    Clients: [] # - {Id: client, Secret: secret}\n")
+	}
+	return b.String()
+}
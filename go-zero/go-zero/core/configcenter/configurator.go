@@ -7,16 +7,23 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/zeromicro/go-zero/core/configcenter/subscriber"
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/core/mapping"
 	"github.com/zeromicro/go-zero/core/threading"
+	"github.com/zeromicro/go-zero/core/validation"
 )
 
+const defaultHistory = 10
+
 var (
 	errEmptyConfig            = errors.New("empty config value")
 	errMissingUnmarshalerType = errors.New("missing unmarshaler type")
+
+	// ErrVersionNotFound is returned if the version is not in the history.
+	ErrVersionNotFound = errors.New("config version not found")
 )
 
 // Configurator is the interface for configuration center.
@@ -25,6 +32,17 @@ type Configurator[T any] interface {
 	GetConfig() (T, error)
 	// AddListener adds a listener to the subscriber.
 	AddListener(listener func())
+	// AddChangeListener adds a listener that receives the previous and the current config.
+	AddChangeListener(listener func(old, new T))
+	// Version returns the version of the current config, 0 if there is no valid config yet.
+	Version() uint64
+	// History returns the kept versions, the oldest first.
+	History() []Version[T]
+	// Diff returns the line diff of the configs between two versions.
+	Diff(from, to uint64) (string, error)
+	// Rollback switches back to the config of the given version,
+	// it's applied as a new version, and the listeners are notified.
+	Rollback(version uint64) error
 }
 
 type (
@@ -34,18 +52,61 @@ type (
 		Type string `json:",default=yaml,options=[yaml,json,toml]"`
 		// Log is the flag to control logging.
 		Log bool `json:",default=true"`
+		// History is the number of versions kept for rollback.
+		History int `json:",default=10"`
+	}
+
+	// Version is a valid config that has been applied.
+	Version[T any] struct {
+		Version uint64
+		Data    string
+		Config  T
+		Time    time.Time
+		// Rollback is the version rolled back to, 0 if it's from the subscriber.
+		Rollback uint64
 	}
 
+	// Option customizes a Configurator.
+	Option[T any] func(c *configCenter[T])
+
 	configCenter[T any] struct {
-		conf        Config
-		unmarshaler LoaderFn
-		subscriber  subscriber.Subscriber
-		listeners   []func()
-		lock        sync.Mutex
-		snapshot    atomic.Value
+		conf            Config
+		unmarshaler     LoaderFn
+		validators      []func(T) error
+		subscriber      subscriber.Subscriber
+		listeners       []queuedListener
+		changeListeners []changeListener[T]
+		lock            sync.Mutex
+		// updateLock serializes the changes from the subscriber and rollbacks.
+		updateLock sync.Mutex
+		history    []*value[T]
+		version    uint64
+		snapshot   atomic.Value
+	}
+
+	// queuedListener runs on its own queue, so that it gets the changes in order.
+	queuedListener struct {
+		fn    func()
+		queue *listenerQueue
+	}
+
+	changeListener[T any] struct {
+		fn    func(old, new T)
+		queue *listenerQueue
+	}
+
+	// listenerQueue runs the pushed events one by one in a goroutine,
+	// which exits when the queue is drained.
+	listenerQueue struct {
+		lock    sync.Mutex
+		events  []func()
+		running bool
 	}
 
 	value[T any] struct {
+		version     uint64
+		rollback    uint64
+		time        time.Time
 		data        string
 		marshalData T
 		err         error
@@ -55,25 +116,40 @@ type (
 // Configurator is the interface for configuration center.
 var _ Configurator[any] = (*configCenter[any])(nil)
 
+// WithValidator returns an Option that rejects the configs that fn returns errors on,
+// the last valid config is kept in that case.
+// Configs that implement validation.Validator are always validated.
+func WithValidator[T any](fn func(T) error) Option[T] {
+	return func(c *configCenter[T]) {
+		c.validators = append(c.validators, fn)
+	}
+}
+
 // MustNewConfigCenter returns a Configurator, exits on errors.
-func MustNewConfigCenter[T any](c Config, subscriber subscriber.Subscriber) Configurator[T] {
-	cc, err := NewConfigCenter[T](c, subscriber)
+func MustNewConfigCenter[T any](c Config, subscriber subscriber.Subscriber, opts ...Option[T]) Configurator[T] {
+	cc, err := NewConfigCenter[T](c, subscriber, opts...)
 	logx.Must(err)
 	return cc
 }
 
 // NewConfigCenter returns a Configurator.
-func NewConfigCenter[T any](c Config, subscriber subscriber.Subscriber) (Configurator[T], error) {
+func NewConfigCenter[T any](c Config, subscriber subscriber.Subscriber, opts ...Option[T]) (Configurator[T], error) {
 	unmarshaler, ok := Unmarshaler(strings.ToLower(c.Type))
 	if !ok {
 		return nil, fmt.Errorf("unknown format: %s", c.Type)
 	}
+	if c.History <= 0 {
+		c.History = defaultHistory
+	}
 
 	cc := &configCenter[T]{
 		conf:        c,
 		unmarshaler: unmarshaler,
 		subscriber:  subscriber,
 	}
+	for _, opt := range opts {
+		opt(cc)
+	}
 
 	if err := cc.loadConfig(); err != nil {
 		return nil, err
@@ -94,7 +170,20 @@ func NewConfigCenter[T any](c Config, subscriber subscriber.Subscriber) (Configu
 func (c *configCenter[T]) AddListener(listener func()) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	c.listeners = append(c.listeners, listener)
+	c.listeners = append(c.listeners, queuedListener{
+		fn:    listener,
+		queue: new(listenerQueue),
+	})
+}
+
+// AddChangeListener adds a listener that receives the previous and the current config.
+func (c *configCenter[T]) AddChangeListener(listener func(old, new T)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.changeListeners = append(c.changeListeners, changeListener[T]{
+		fn:    listener,
+		queue: new(listenerQueue),
+	})
 }
 
 // GetConfig return structured config.
@@ -117,8 +206,115 @@ func (c *configCenter[T]) Value() string {
 	return v.data
 }
 
+// Version returns the version of the current config.
+func (c *configCenter[T]) Version() uint64 {
+	v := c.value()
+	if v == nil {
+		return 0
+	}
+	return v.version
+}
+
+// History returns the kept versions, the oldest first.
+func (c *configCenter[T]) History() []Version[T] {
+	c.updateLock.Lock()
+	defer c.updateLock.Unlock()
+
+	versions := make([]Version[T], 0, len(c.history))
+	for _, v := range c.history {
+		versions = append(versions, Version[T]{
+			Version:  v.version,
+			Data:     v.data,
+			Config:   v.marshalData,
+			Time:     v.time,
+			Rollback: v.rollback,
+		})
+	}
+	return versions
+}
+
+// Diff returns the line diff of the configs between two versions.
+func (c *configCenter[T]) Diff(from, to uint64) (string, error) {
+	c.updateLock.Lock()
+	defer c.updateLock.Unlock()
+
+	fv, err := c.find(from)
+	if err != nil {
+		return "", err
+	}
+	tv, err := c.find(to)
+	if err != nil {
+		return "", err
+	}
+
+	return diffLines(fv.data, tv.data), nil
+}
+
+// Rollback switches back to the config of the given version.
+func (c *configCenter[T]) Rollback(version uint64) error {
+	c.updateLock.Lock()
+	defer c.updateLock.Unlock()
+
+	target, err := c.find(version)
+	if err != nil {
+		return err
+	}
+
+	old := c.value()
+	if old != nil && old.err == nil && old.data == target.data {
+		return nil
+	}
+	if old != nil && old.err != nil {
+		// an invalid initial config was never applied, so there is no previous config.
+		old = nil
+	}
+
+	v := &value[T]{
+		rollback:    version,
+		data:        target.data,
+		marshalData: target.marshalData,
+	}
+	c.apply(v)
+
+	if c.conf.Log {
+		logx.Infof("ConfigCenter rolls back to version %d as version %d", version, v.version)
+	}
+	c.notify(old, v)
+	return nil
+}
+
+// apply makes v the current config, must be called with updateLock held.
+func (c *configCenter[T]) apply(v *value[T]) {
+	c.version++
+	v.version = c.version
+	v.time = time.Now()
+	c.history = append(c.history, v)
+	if len(c.history) > c.conf.History {
+		c.history = c.history[len(c.history)-c.conf.History:]
+	}
+	c.snapshot.Store(v)
+}
+
+// find returns the version in the history, must be called with updateLock held.
+func (c *configCenter[T]) find(version uint64) (*value[T], error) {
+	for _, v := range c.history {
+		if v.version == version {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %d", ErrVersionNotFound, version)
+}
+
 func (c *configCenter[T]) loadConfig() error {
-	v, err := c.subscriber.Value()
+	return c.load()
+}
+
+// load loads the config from the subscriber and notifies the listeners if it's applied.
+// An invalid config is rejected and the last valid one is kept, while an invalid initial
+// config is kept to report the error.
+func (c *configCenter[T]) load() error {
+	data, err := c.subscriber.Value()
 	if err != nil {
 		if c.conf.Log {
 			logx.Errorf("ConfigCenter loads changed configuration, error: %v", err)
@@ -126,26 +322,100 @@ func (c *configCenter[T]) loadConfig() error {
 		return err
 	}
 
+	c.updateLock.Lock()
+	defer c.updateLock.Unlock()
+
+	old := c.value()
+	if old != nil && old.err == nil && old.data == data {
+		return nil
+	}
+
 	if c.conf.Log {
-		logx.Infof("ConfigCenter loads changed configuration, content [%s]", v)
+		logx.Infof("ConfigCenter loads changed configuration, content [%s]", data)
 	}
 
-	c.snapshot.Store(c.genValue(v))
+	v := c.genValue(data)
+	if v.err == nil && len(data) == 0 {
+		v.err = errEmptyConfig
+	}
+	if v.err != nil {
+		if old == nil || old.err != nil {
+			// no valid config yet, keep the invalid one to report the error.
+			c.snapshot.Store(v)
+			return nil
+		}
+
+		logx.Errorf("ConfigCenter rejects configuration and keeps version %d, error: %v, content [%s]",
+			old.version, v.err, data)
+		return nil
+	}
+
+	c.apply(v)
+	if old != nil && old.err != nil {
+		old = nil
+	}
+	c.notify(old, v)
 	return nil
 }
 
 func (c *configCenter[T]) onChange() {
-	if err := c.loadConfig(); err != nil {
-		return
-	}
+	_ = c.load()
+}
 
+// notify queues the change to the listeners, must be called with updateLock held,
+// so that the changes are queued in the order they are applied.
+func (c *configCenter[T]) notify(old, cur *value[T]) {
 	c.lock.Lock()
-	listeners := make([]func(), len(c.listeners))
+	listeners := make([]queuedListener, len(c.listeners))
 	copy(listeners, c.listeners)
+	changeListeners := make([]changeListener[T], len(c.changeListeners))
+	copy(changeListeners, c.changeListeners)
 	c.lock.Unlock()
 
 	for _, l := range listeners {
-		threading.GoSafe(l)
+		l.queue.push(l.fn)
+	}
+
+	var oldConfig T
+	if old != nil {
+		oldConfig = old.marshalData
+	}
+	for _, l := range changeListeners {
+		l.queue.push(func() {
+			l.fn(oldConfig, cur.marshalData)
+		})
+	}
+}
+
+// push queues the event, and starts the goroutine to run the events if it's not running.
+func (q *listenerQueue) push(event func()) {
+	q.lock.Lock()
+	q.events = append(q.events, event)
+	if q.running {
+		q.lock.Unlock()
+		return
+	}
+	q.running = true
+	q.lock.Unlock()
+
+	threading.GoSafe(q.run)
+}
+
+func (q *listenerQueue) run() {
+	for {
+		q.lock.Lock()
+		if len(q.events) == 0 {
+			q.running = false
+			q.lock.Unlock()
+			return
+		}
+		event := q.events[0]
+		q.events[0] = nil
+		q.events = q.events[1:]
+		q.lock.Unlock()
+
+		// a panicking listener doesn't stop the events after it.
+		threading.RunSafe(event)
 	}
 }
 
@@ -196,5 +466,30 @@ func (c *configCenter[T]) genValue(data string) *value[T] {
 		v.err = errMissingUnmarshalerType
 	}
 
+	if v.err == nil {
+		v.err = c.validate(v.marshalData)
+	}
+
 	return v
 }
+
+// validate runs validation.Validator of the config and the validators from the options.
+func (c *configCenter[T]) validate(config T) error {
+	if val, ok := any(config).(validation.Validator); ok {
+		if err := val.Validate(); err != nil {
+			return err
+		}
+	} else if val, ok := any(&config).(validation.Validator); ok {
+		if err := val.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, fn := range c.validators {
+		if err := fn(config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
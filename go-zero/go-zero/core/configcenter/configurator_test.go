@@ -2,11 +2,16 @@ package configurator
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/configcenter/subscriber"
 )
 
 func TestNewConfigCenter(t *testing.T) {
@@ -137,6 +142,8 @@ func TestConfigCenter_AddListener(t *testing.T) {
 
 	assert.Equal(t, 2, len(cc.listeners))
 
+	// the listeners are notified only if the value changes.
+	mock.v = "5678"
 	mock.change()
 
 	time.Sleep(time.Millisecond * 100)
@@ -211,6 +218,210 @@ func TestConfigCenter_genValue(t *testing.T) {
 	})
 }
 
+func TestConfigCenter_FileSubscriber(t *testing.T) {
+	type Data struct {
+		Name string `json:"name"`
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("name: go-zero"), 0o644))
+	c, err := NewConfigCenter[Data](Config{Type: "yaml"}, subscriber.MustNewFileSubscriber(path))
+	assert.NoError(t, err)
+
+	data, err := c.GetConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "go-zero", data.Name)
+
+	changed := make(chan struct{}, 1)
+	c.AddListener(func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	assert.NoError(t, os.WriteFile(path, []byte("name: go-zero2"), 0o644))
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting for the change")
+	}
+	data, err = c.GetConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "go-zero2", data.Name)
+}
+
+type validatedData struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+func (d validatedData) Validate() error {
+	if len(d.Name) == 0 {
+		return errors.New("empty name")
+	}
+	return nil
+}
+
+func TestConfigCenter_Validate(t *testing.T) {
+	mock := &mockSubscriber{v: `{"name": "a", "port": 80}`}
+	c, err := NewConfigCenter[validatedData](Config{Type: "json"}, mock,
+		WithValidator(func(d validatedData) error {
+			if d.Port <= 0 {
+				return errors.New("invalid port")
+			}
+			return nil
+		}))
+	assert.NoError(t, err)
+
+	var calls int32
+	c.AddListener(func() {
+		atomic.AddInt32(&calls, 1)
+	})
+
+	for _, v := range []string{
+		`{"name": "", "port": 81}`,
+		`{"name": "b", "port": 0}`,
+		`{"name": "b", "port": `,
+		``,
+	} {
+		mock.v = v
+		mock.change()
+		data, err := c.GetConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, validatedData{Name: "a", Port: 80}, data)
+		assert.Equal(t, uint64(1), c.Version())
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+	mock.v = `{"name": "", "port": 80}`
+	_, err = NewConfigCenter[validatedData](Config{Type: "json"}, mock)
+	assert.EqualError(t, err, "empty name")
+}
+
+func TestConfigCenter_History(t *testing.T) {
+	mock := &mockSubscriber{v: "name: a\nport: 80"}
+	c, err := NewConfigCenter[validatedData](Config{Type: "yaml", History: 3}, mock)
+	assert.NoError(t, err)
+
+	changes := make(chan [2]validatedData, 10)
+	c.AddChangeListener(func(old, new validatedData) {
+		changes <- [2]validatedData{old, new}
+	})
+
+	for _, v := range []string{"name: b\nport: 80", "name: c\nport: 80", "name: d\nport: 81"} {
+		mock.v = v
+		mock.change()
+	}
+	assert.Equal(t, uint64(4), c.Version())
+
+	history := c.History()
+	assert.Len(t, history, 3)
+	assert.Equal(t, uint64(2), history[0].Version)
+	assert.Equal(t, "b", history[0].Config.Name)
+	assert.Equal(t, "d", history[2].Config.Name)
+
+	diff, err := c.Diff(3, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "-name: c\n-port: 80\n+name: d\n+port: 81\n", diff)
+
+	_, err = c.Diff(1, 4)
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+	assert.ErrorIs(t, c.Rollback(1), ErrVersionNotFound)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the change")
+		}
+	}
+
+	assert.NoError(t, c.Rollback(2))
+	assert.Equal(t, uint64(5), c.Version())
+	data, err := c.GetConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "b", data.Name)
+	history = c.History()
+	assert.Equal(t, uint64(2), history[2].Rollback)
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, "d", change[0].Name)
+		assert.Equal(t, "b", change[1].Name)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the rollback")
+	}
+
+	// rolling back to the current config is a no-op.
+	assert.NoError(t, c.Rollback(5))
+	assert.Equal(t, uint64(5), c.Version())
+}
+
+func TestConfigCenter_ListenerOrder(t *testing.T) {
+	mock := &mockSubscriber{v: "name: a\nport: 0"}
+	c, err := NewConfigCenter[validatedData](Config{Type: "yaml", History: 3}, mock)
+	assert.NoError(t, err)
+
+	const count = 100
+	var calls int32
+	c.AddListener(func() {
+		atomic.AddInt32(&calls, 1)
+	})
+	changes := make(chan [2]validatedData, count)
+	c.AddChangeListener(func(old, new validatedData) {
+		// a slow listener must not get the later changes first.
+		time.Sleep(time.Microsecond * time.Duration(count-new.Port))
+		changes <- [2]validatedData{old, new}
+	})
+
+	for i := 1; i <= count; i++ {
+		mock.v = fmt.Sprintf("name: a\nport: %d", i)
+		mock.change()
+	}
+
+	for i := 1; i <= count; i++ {
+		select {
+		case change := <-changes:
+			assert.Equal(t, i-1, change[0].Port)
+			assert.Equal(t, i, change[1].Port)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the change")
+		}
+	}
+	assert.Equal(t, int32(count), atomic.LoadInt32(&calls))
+}
+
+func TestConfigCenter_RollbackInvalid(t *testing.T) {
+	mock := &mockSubscriber{v: "name: a\nport: 80"}
+	c, err := NewConfigCenter[validatedData](Config{Type: "yaml"}, mock)
+	assert.NoError(t, err)
+
+	changes := make(chan [2]validatedData, 1)
+	c.AddChangeListener(func(old, new validatedData) {
+		changes <- [2]validatedData{old, new}
+	})
+
+	// the current snapshot is an invalid config that was never applied.
+	cc := c.(*configCenter[validatedData])
+	cc.snapshot.Store(&value[validatedData]{
+		data:        "name: \nport: 80",
+		marshalData: validatedData{Port: 80},
+		err:         errors.New("empty name"),
+	})
+	assert.NoError(t, c.Rollback(1))
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, validatedData{}, change[0])
+		assert.Equal(t, "a", change[1].Name)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the rollback")
+	}
+}
+
 type mockSubscriber struct {
 	v              string
 	lisErr, valErr error
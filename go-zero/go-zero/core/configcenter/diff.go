@@ -0,0 +1,58 @@
+package configurator
+
+import "strings"
+
+// diffLines returns the line diff from a to b, the removed lines are prefixed
+// with "-", the added lines with "+" and the unchanged lines with " ".
+func diffLines(a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var builder strings.Builder
+	write := func(prefix, line string) {
+		builder.WriteString(prefix)
+		builder.WriteString(line)
+		builder.WriteByte('\n')
+	}
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			write(" ", x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			write("-", x[i])
+			i++
+		default:
+			write("+", y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		write("-", x[i])
+	}
+	for ; j < len(y); j++ {
+		write("+", y[j])
+	}
+
+	return builder.String()
+}
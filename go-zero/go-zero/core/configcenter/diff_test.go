@@ -0,0 +1,45 @@
+package configurator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   string
+		expect string
+	}{
+		{
+			name: "same",
+			a:    "a\nb",
+			b:    "a\nb",
+		},
+		{
+			name:   "change",
+			a:      "a\nb\nc",
+			b:      "a\nx\nc",
+			expect: " a\n-b\n+x\n c\n",
+		},
+		{
+			name:   "add and remove",
+			a:      "a\nb",
+			b:      "b\nc",
+			expect: "-a\n b\n+c\n",
+		},
+		{
+			name:   "from empty",
+			a:      "",
+			b:      "a",
+			expect: "-\n+a\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expect, diffLines(test.a, test.b))
+		})
+	}
+}
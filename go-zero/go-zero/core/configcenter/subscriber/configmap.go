@@ -0,0 +1,156 @@
+//go:build !no_k8s
+
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/proc"
+	"github.com/zeromicro/go-zero/core/threading"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const configMapResyncInterval = 5 * time.Minute
+
+var errConfigMapEmptyName = errors.New("empty configmap name or key")
+
+type (
+	// ConfigMapConf is the configuration for Kubernetes ConfigMap.
+	ConfigMapConf struct {
+		Namespace string `json:",default=default"`
+		Name      string
+		// Key is the data key in the ConfigMap that holds the configuration.
+		Key string
+	}
+
+	// configMapSubscriber is a subscriber that watches a Kubernetes ConfigMap.
+	configMapSubscriber struct {
+		valueContainer
+		conf   ConfigMapConf
+		inf    informers.SharedInformerFactory
+		stopCh chan struct{}
+	}
+)
+
+// MustNewConfigMapSubscriber returns a ConfigMap Subscriber, exits on errors.
+func MustNewConfigMapSubscriber(conf ConfigMapConf) Subscriber {
+	s, err := NewConfigMapSubscriber(conf)
+	logx.Must(err)
+	return s
+}
+
+// NewConfigMapSubscriber returns a ConfigMap Subscriber with the in-cluster config.
+// The service account needs the get, list and watch permissions on configmaps.
+func NewConfigMapSubscriber(conf ConfigMapConf) (Subscriber, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConfigMapSubscriberWithClient(cs, conf)
+}
+
+// NewConfigMapSubscriberWithClient returns a ConfigMap Subscriber with the given client.
+func NewConfigMapSubscriberWithClient(cs kubernetes.Interface, conf ConfigMapConf) (Subscriber, error) {
+	s, err := newConfigMapSubscriber(cs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	proc.AddShutdownListener(s.stop)
+	return s, nil
+}
+
+func newConfigMapSubscriber(cs kubernetes.Interface, conf ConfigMapConf) (*configMapSubscriber, error) {
+	if len(conf.Name) == 0 || len(conf.Key) == 0 {
+		return nil, errConfigMapEmptyName
+	}
+	if len(conf.Namespace) == 0 {
+		conf.Namespace = metav1.NamespaceDefault
+	}
+
+	// get the initial value synchronously, a missing ConfigMap is an error,
+	// while a ConfigMap deleted later leaves an empty value.
+	cm, err := cs.CoreV1().ConfigMaps(conf.Namespace).Get(context.Background(),
+		conf.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &configMapSubscriber{
+		conf: conf,
+		inf: informers.NewSharedInformerFactoryWithOptions(cs, configMapResyncInterval,
+			informers.WithNamespace(conf.Namespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("metadata.name", conf.Name).String()
+			})),
+		stopCh: make(chan struct{}),
+	}
+	s.set(s.valueOf(cm))
+
+	_, err = s.inf.Core().V1().ConfigMaps().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			s.onUpdate(obj)
+		},
+		UpdateFunc: func(_, newObj any) {
+			s.onUpdate(newObj)
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if cm, ok := obj.(*corev1.ConfigMap); ok && cm.Name == s.conf.Name {
+				s.set("")
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	threading.GoSafe(func() {
+		s.inf.Start(s.stopCh)
+	})
+	return s, nil
+}
+
+func (s *configMapSubscriber) onUpdate(obj any) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	// the field selector may not be honored, like by the fake clientset.
+	if !ok || cm.Name != s.conf.Name {
+		return
+	}
+
+	s.set(s.valueOf(cm))
+}
+
+func (s *configMapSubscriber) valueOf(cm *corev1.ConfigMap) string {
+	if val, ok := cm.Data[s.conf.Key]; ok {
+		return val
+	}
+
+	return string(cm.BinaryData[s.conf.Key])
+}
+
+func (s *configMapSubscriber) stop() {
+	select {
+	case <-s.stopCh:
+	default:
+		close(s.stopCh)
+	}
+	s.inf.Shutdown()
+}
@@ -0,0 +1,78 @@
+//go:build !no_k8s
+
+package subscriber
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapSubscriber(t *testing.T) {
+	cs := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "account"},
+			Data:       map[string]string{"config.yaml": "v1"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "other"},
+			Data:       map[string]string{"config.yaml": "other"},
+		},
+	)
+
+	s, err := newConfigMapSubscriber(cs, ConfigMapConf{
+		Namespace: "apps",
+		Name:      "account",
+		Key:       "config.yaml",
+	})
+	assert.NoError(t, err)
+	defer s.stop()
+
+	val, err := s.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	changed := make(chan string, 10)
+	assert.NoError(t, s.AddListener(func() {
+		val, _ := s.Value()
+		changed <- val
+	}))
+
+	configMaps := cs.CoreV1().ConfigMaps("apps")
+	update := func(name string, data map[string]string, binary map[string][]byte) {
+		_, err := configMaps.Update(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: name},
+			Data:       data,
+			BinaryData: binary,
+		}, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+	}
+
+	// the changes of other ConfigMaps are ignored.
+	update("other", map[string]string{"config.yaml": "other2"}, nil)
+	update("account", map[string]string{"config.yaml": "v2"}, nil)
+	assert.Equal(t, "v2", waitChange(t, changed))
+
+	update("account", nil, map[string][]byte{"config.yaml": []byte("v3")})
+	assert.Equal(t, "v3", waitChange(t, changed))
+
+	assert.NoError(t, configMaps.Delete(context.Background(), "account", metav1.DeleteOptions{}))
+	assert.Equal(t, "", waitChange(t, changed))
+}
+
+func TestConfigMapSubscriberError(t *testing.T) {
+	cs := fake.NewSimpleClientset()
+
+	_, err := NewConfigMapSubscriberWithClient(cs, ConfigMapConf{Name: "account"})
+	assert.ErrorIs(t, err, errConfigMapEmptyName)
+
+	_, err = NewConfigMapSubscriberWithClient(cs, ConfigMapConf{Name: "account", Key: "config.yaml"})
+	assert.Error(t, err)
+
+	_, err = NewConfigMapSubscriber(ConfigMapConf{Name: "account", Key: "config.yaml"})
+	assert.Error(t, err)
+}
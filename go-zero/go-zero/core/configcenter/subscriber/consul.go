@@ -0,0 +1,205 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/proc"
+	"github.com/zeromicro/go-zero/core/threading"
+)
+
+const (
+	consulIndexHeader = "X-Consul-Index"
+	consulMinBackoff  = time.Second
+	consulMaxBackoff  = time.Minute
+)
+
+var (
+	errConsulEmptyKey    = errors.New("empty consul key")
+	errConsulKeyNotFound = errors.New("consul key not found")
+)
+
+type (
+	// ConsulConf is the configuration for consul.
+	ConsulConf struct {
+		// Host is the address of the consul agent, like 127.0.0.1:8500.
+		Host string
+		// Key is the KV key that holds the configuration.
+		Key        string
+		Scheme     string        `json:",default=http,options=[http,https]"`
+		Token      string        `json:",optional"`
+		Datacenter string        `json:",optional"`
+		WaitTime   time.Duration `json:",default=5m"`
+	}
+
+	// consulSubscriber is a subscriber that watches a consul KV key with blocking queries.
+	consulSubscriber struct {
+		valueContainer
+		conf   ConsulConf
+		client *http.Client
+		url    string
+		index  uint64
+		ctx    context.Context
+		cancel context.CancelFunc
+	}
+)
+
+// MustNewConsulSubscriber returns a consul Subscriber, exits on errors.
+func MustNewConsulSubscriber(conf ConsulConf) Subscriber {
+	s, err := NewConsulSubscriber(conf)
+	logx.Must(err)
+	return s
+}
+
+// NewConsulSubscriber returns a consul Subscriber.
+// The consul HTTP API is used directly, so no consul client library is required.
+func NewConsulSubscriber(conf ConsulConf) (Subscriber, error) {
+	s, err := newConsulSubscriber(conf, http.DefaultClient)
+	if err != nil {
+		return nil, err
+	}
+
+	proc.AddShutdownListener(s.stop)
+	return s, nil
+}
+
+func newConsulSubscriber(conf ConsulConf, client *http.Client) (*consulSubscriber, error) {
+	if len(conf.Key) == 0 {
+		return nil, errConsulEmptyKey
+	}
+	if len(conf.Scheme) == 0 {
+		conf.Scheme = "http"
+	}
+	if conf.WaitTime <= 0 {
+		conf.WaitTime = 5 * time.Minute
+	}
+
+	s := &consulSubscriber{
+		conf:   conf,
+		client: client,
+		url: fmt.Sprintf("%s://%s/v1/kv/%s", conf.Scheme, conf.Host,
+			strings.TrimPrefix(conf.Key, "/")),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	// load the initial value synchronously, so that the config center can be built with it.
+	// a missing key starts with an empty value, it's set when the key is created.
+	val, index, err := s.query(0)
+	if err != nil && !errors.Is(err, errConsulKeyNotFound) {
+		s.cancel()
+		return nil, err
+	}
+	s.index = index
+	s.set(val)
+
+	threading.GoSafe(s.watch)
+	return s, nil
+}
+
+// query runs a blocking query if index is not zero,
+// returns the value of the key and the index of the response.
+// errConsulKeyNotFound is returned with the index if the key doesn't exist.
+func (s *consulSubscriber) query(index uint64) (string, uint64, error) {
+	params := url.Values{}
+	params.Set("raw", "")
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", s.conf.WaitTime.String())
+	}
+	if len(s.conf.Datacenter) > 0 {
+		params.Set("dc", s.conf.Datacenter)
+	}
+
+	// consul adds a random jitter of up to wait/16 to the wait time.
+	timeout := s.conf.WaitTime + s.conf.WaitTime/16 + 10*time.Second
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(s.conf.Token) > 0 {
+		req.Header.Set("X-Consul-Token", s.conf.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get(consulIndexHeader), 10, 64)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return string(body), newIndex, nil
+	case http.StatusNotFound:
+		// the key doesn't exist or has been deleted.
+		return "", newIndex, errConsulKeyNotFound
+	default:
+		return "", 0, fmt.Errorf("consul kv %s: %s, %s", s.conf.Key, resp.Status,
+			strings.TrimSpace(string(body)))
+	}
+}
+
+func (s *consulSubscriber) watch() {
+	backoff := consulMinBackoff
+	for {
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		val, index, err := s.query(s.index)
+		notFound := errors.Is(err, errConsulKeyNotFound)
+		if err != nil && !notFound {
+			if s.ctx.Err() != nil {
+				return
+			}
+
+			logx.Errorf("watch consul key %s, error: %v", s.conf.Key, err)
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, consulMaxBackoff)
+			continue
+		}
+
+		backoff = consulMinBackoff
+		// the index must be reset if it goes backwards, see
+		// https://developer.hashicorp.com/consul/api-docs/features/blocking
+		switch {
+		case index == 0:
+			// never fall back to non-blocking queries in a loop.
+			index = 1
+		case index < s.index:
+			index = 0
+		}
+		s.index = index
+		if notFound {
+			// the key may be deleted by mistake or while being replaced,
+			// keep the last value until it comes back, like the file subscriber.
+			logx.Errorf("consul key %s not found, keep the last value", s.conf.Key)
+			continue
+		}
+		s.set(val)
+	}
+}
+
+func (s *consulSubscriber) stop() {
+	s.cancel()
+}
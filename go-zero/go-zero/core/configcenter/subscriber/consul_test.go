@@ -0,0 +1,190 @@
+package subscriber
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConsulKV is a consul KV endpoint that supports blocking queries on one key.
+type fakeConsulKV struct {
+	lock    sync.Mutex
+	index   uint64
+	value   *string
+	queries []string
+}
+
+func newFakeConsulKV(value string) *fakeConsulKV {
+	return &fakeConsulKV{index: 10, value: &value}
+}
+
+func (kv *fakeConsulKV) put(value *string) {
+	kv.lock.Lock()
+	kv.index++
+	kv.value = value
+	kv.lock.Unlock()
+}
+
+func (kv *fakeConsulKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	kv.queries = append(kv.queries, r.URL.RawQuery)
+	if r.URL.Path != "/v1/kv/app/config" || r.Header.Get("X-Consul-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if index, err := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); err == nil {
+		deadline := time.Now().Add(time.Second)
+		for kv.index <= index && time.Now().Before(deadline) {
+			kv.lock.Unlock()
+			time.Sleep(time.Millisecond * 5)
+			kv.lock.Lock()
+		}
+	}
+
+	w.Header().Set(consulIndexHeader, strconv.FormatUint(kv.index, 10))
+	if kv.value == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_, _ = w.Write([]byte(*kv.value))
+}
+
+func TestConsulSubscriber(t *testing.T) {
+	kv := newFakeConsulKV("v1")
+	svr := httptest.NewServer(kv)
+	defer svr.Close()
+
+	s, err := newConsulSubscriber(ConsulConf{
+		Host:       strings.TrimPrefix(svr.URL, "http://"),
+		Key:        "/app/config",
+		Token:      "token",
+		Datacenter: "dc1",
+		WaitTime:   time.Second,
+	}, svr.Client())
+	assert.NoError(t, err)
+	defer s.stop()
+
+	val, err := s.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	changed := make(chan string, 10)
+	assert.NoError(t, s.AddListener(func() {
+		val, _ := s.Value()
+		changed <- val
+	}))
+
+	v2 := "v2"
+	kv.put(&v2)
+	assert.Equal(t, "v2", waitChange(t, changed))
+
+	// the same value doesn't notify the listeners.
+	kv.put(&v2)
+	v3 := "v3"
+	kv.put(&v3)
+	assert.Equal(t, "v3", waitChange(t, changed))
+
+	// a deleted key keeps the last value until it comes back.
+	kv.put(nil)
+	kv.lock.Lock()
+	deletedIndex := kv.index
+	kv.lock.Unlock()
+	waitQuery(t, kv, "index="+strconv.FormatUint(deletedIndex, 10))
+	val, err = s.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "v3", val)
+	assert.Empty(t, changed)
+
+	v4 := "v4"
+	kv.put(&v4)
+	assert.Equal(t, "v4", waitChange(t, changed))
+
+	kv.lock.Lock()
+	queries := append([]string(nil), kv.queries...)
+	kv.lock.Unlock()
+	assert.Equal(t, "dc=dc1&raw=", queries[0])
+	assert.Contains(t, queries[1], "index=10")
+	assert.Contains(t, queries[1], "wait=1s")
+}
+
+func TestConsulSubscriberMissingKey(t *testing.T) {
+	kv := &fakeConsulKV{index: 10}
+	svr := httptest.NewServer(kv)
+	defer svr.Close()
+
+	s, err := newConsulSubscriber(ConsulConf{
+		Host:     strings.TrimPrefix(svr.URL, "http://"),
+		Key:      "app/config",
+		Token:    "token",
+		WaitTime: time.Second,
+	}, svr.Client())
+	assert.NoError(t, err)
+	defer s.stop()
+
+	val, err := s.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "", val)
+
+	changed := make(chan string, 10)
+	assert.NoError(t, s.AddListener(func() {
+		val, _ := s.Value()
+		changed <- val
+	}))
+	v1 := "v1"
+	kv.put(&v1)
+	assert.Equal(t, "v1", waitChange(t, changed))
+}
+
+func TestConsulSubscriberError(t *testing.T) {
+	_, err := NewConsulSubscriber(ConsulConf{Host: "127.0.0.1:8500"})
+	assert.ErrorIs(t, err, errConsulEmptyKey)
+
+	svr := httptest.NewServer(newFakeConsulKV("v1"))
+	defer svr.Close()
+
+	_, err = newConsulSubscriber(ConsulConf{
+		Host: strings.TrimPrefix(svr.URL, "http://"),
+		Key:  "app/config",
+	}, svr.Client())
+	assert.ErrorContains(t, err, "403")
+}
+
+// waitQuery waits until the subscriber sends a query that contains the given part.
+func waitQuery(t *testing.T, kv *fakeConsulKV, part string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		kv.lock.Lock()
+		queries := append([]string(nil), kv.queries...)
+		kv.lock.Unlock()
+		for _, query := range queries {
+			if strings.Contains(query, part) {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("timeout waiting for the query with %s", part)
+}
+
+func waitChange(t *testing.T, changed <-chan string) string {
+	t.Helper()
+
+	select {
+	case val := <-changed:
+		return val
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting for the change")
+		return ""
+	}
+}
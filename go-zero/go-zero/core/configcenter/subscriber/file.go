@@ -0,0 +1,116 @@
+package subscriber
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/proc"
+	"github.com/zeromicro/go-zero/core/threading"
+)
+
+// fileDebounce is the quiet time after the last change before reloading,
+// to skip the partial contents while a file is being written.
+const fileDebounce = 100 * time.Millisecond
+
+// fileSubscriber is a subscriber that watches a local file.
+type fileSubscriber struct {
+	valueContainer
+	path    string
+	watcher *fsnotify.Watcher
+}
+
+// MustNewFileSubscriber returns a file Subscriber, exits on errors.
+func MustNewFileSubscriber(path string) Subscriber {
+	s, err := NewFileSubscriber(path)
+	logx.Must(err)
+	return s
+}
+
+// NewFileSubscriber returns a file Subscriber.
+// The directory of the file is watched instead of the file itself, so that the
+// changes made by renaming, like editors and mounted Kubernetes ConfigMaps do, are
+// also picked up.
+func NewFileSubscriber(path string) (Subscriber, error) {
+	s, err := newFileSubscriber(path)
+	if err != nil {
+		return nil, err
+	}
+
+	proc.AddShutdownListener(s.stop)
+	return s, nil
+}
+
+func newFileSubscriber(path string) (*fileSubscriber, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	s := &fileSubscriber{
+		path:    path,
+		watcher: watcher,
+	}
+	s.set(string(content))
+	threading.GoSafe(s.watch)
+	return s, nil
+}
+
+func (s *fileSubscriber) watch() {
+	var reload <-chan time.Time
+	for {
+		select {
+		case _, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			// any change in the directory may replace the file through renames or
+			// symlinks, set only notifies the listeners if the content changes.
+			reload = time.After(fileDebounce)
+		case <-reload:
+			reload = nil
+			s.reload()
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			logx.Errorf("watch file %s, error: %v", s.path, err)
+		}
+	}
+}
+
+func (s *fileSubscriber) reload() {
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		// the file may be removed for a moment while being replaced,
+		// keep the last value until it comes back.
+		if !os.IsNotExist(err) {
+			logx.Errorf("read file %s, error: %v", s.path, err)
+		}
+		return
+	}
+
+	s.set(string(content))
+}
+
+func (s *fileSubscriber) stop() {
+	if err := s.watcher.Close(); err != nil {
+		logx.Error(err)
+	}
+}
@@ -0,0 +1,66 @@
+package subscriber
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSubscriber(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("v1"), 0o644))
+
+	s, err := newFileSubscriber(path)
+	assert.NoError(t, err)
+	defer s.stop()
+
+	val, err := s.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	changed := make(chan string, 10)
+	assert.NoError(t, s.AddListener(func() {
+		val, _ := s.Value()
+		changed <- val
+	}))
+
+	assert.NoError(t, os.WriteFile(path, []byte("v2"), 0o644))
+	waitValue(t, changed, "v2")
+
+	// replace the file by renaming, like editors do.
+	tmp := filepath.Join(dir, "config.yaml.tmp")
+	assert.NoError(t, os.WriteFile(tmp, []byte("v3"), 0o644))
+	assert.NoError(t, os.Rename(tmp, path))
+	waitValue(t, changed, "v3")
+
+	// removing the file keeps the last value.
+	assert.NoError(t, os.Remove(path))
+	assert.NoError(t, os.WriteFile(path, []byte("v4"), 0o644))
+	waitValue(t, changed, "v4")
+}
+
+func TestFileSubscriberError(t *testing.T) {
+	_, err := NewFileSubscriber(filepath.Join(t.TempDir(), "not-exist.yaml"))
+	assert.Error(t, err)
+}
+
+// waitValue waits until the subscriber changes to the expected value.
+func waitValue(t *testing.T, changed <-chan string, expect string) {
+	t.Helper()
+
+	timeout := time.After(time.Second * 5)
+	for {
+		select {
+		case val := <-changed:
+			if val == expect {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for %q", expect)
+		}
+	}
+}
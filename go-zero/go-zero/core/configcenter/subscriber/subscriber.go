@@ -1,5 +1,10 @@
 package subscriber
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 // Subscriber is the interface for configcenter subscribers.
 type Subscriber interface {
 	// AddListener adds a listener to the subscriber.
@@ -7,3 +12,46 @@ type Subscriber interface {
 	// Value returns the value of the subscriber.
 	Value() (string, error)
 }
+
+// valueContainer holds the latest value of a subscriber,
+// and notifies the listeners only when the value changes.
+type valueContainer struct {
+	value     atomic.Value
+	listeners []func()
+	lock      sync.Mutex
+}
+
+func (c *valueContainer) AddListener(listener func()) error {
+	c.lock.Lock()
+	c.listeners = append(c.listeners, listener)
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *valueContainer) Value() (string, error) {
+	return c.get(), nil
+}
+
+func (c *valueContainer) get() string {
+	if val, ok := c.value.Load().(string); ok {
+		return val
+	}
+
+	return ""
+}
+
+// set stores val and notifies the listeners if val differs from the current value.
+func (c *valueContainer) set(val string) {
+	c.lock.Lock()
+	if old, ok := c.value.Load().(string); ok && old == val {
+		c.lock.Unlock()
+		return
+	}
+	c.value.Store(val)
+	listeners := append(([]func())(nil), c.listeners...)
+	c.lock.Unlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
@@ -0,0 +1,191 @@
+package limit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+const gcraFormat = "{%s%s}.tat"
+
+var (
+	//go:embed gcrascript.lua
+	gcraLuaScript string
+	gcraScript    = redis.NewScript(gcraLuaScript)
+)
+
+type (
+	// A GcraLimit limits the requests with the generic cell rate algorithm with redis.
+	// It allows rate requests per period evenly, with bursts of at most burst requests,
+	// and only keeps one timestamp per key.
+	// The local time of the caller is passed to the script, like TokenLimiter does,
+	// so the clocks of the instances sharing the keys should be synchronized.
+	GcraLimit struct {
+		interval  time.Duration
+		tolerance time.Duration
+		store     *redis.Redis
+		keyPrefix string
+		rescue    *redisRescue
+		local     *LocalGcraLimit
+	}
+
+	// A LocalGcraLimit is the in-process equivalent of GcraLimit.
+	LocalGcraLimit struct {
+		interval  time.Duration
+		tolerance time.Duration
+		lock      sync.Mutex
+		// tats are the theoretical arrival times of the keys.
+		tats      map[string]time.Time
+		lastSweep time.Time
+	}
+)
+
+// NewGcraLimit returns a GcraLimit that allows rate requests per period with bursts of burst.
+func NewGcraLimit(period time.Duration, rate, burst int, store *redis.Redis, keyPrefix string) *GcraLimit {
+	interval, tolerance := gcraParams(period, rate, burst)
+	return &GcraLimit{
+		interval:  interval,
+		tolerance: tolerance,
+		store:     store,
+		keyPrefix: keyPrefix,
+		rescue:    newRedisRescue(store),
+		local:     NewLocalGcraLimit(period, rate, burst),
+	}
+}
+
+// Allow is shorthand for AllowCtx(context.Background(), key).
+func (l *GcraLimit) Allow(key string) (Result, error) {
+	return l.AllowCtx(context.Background(), key)
+}
+
+// AllowCtx takes a permit for key, and returns the decision.
+func (l *GcraLimit) AllowCtx(ctx context.Context, key string) (Result, error) {
+	now := time.Now()
+	if !l.rescue.alive() {
+		return l.local.allow(key, now), nil
+	}
+
+	// the times are in milliseconds with fractions, lua keeps 14 significant digits,
+	// which is about 0.1ms for the unix time in milliseconds.
+	resp, err := l.store.ScriptRunCtx(ctx, gcraScript, []string{
+		fmt.Sprintf(gcraFormat, l.keyPrefix, key),
+	}, []string{
+		formatMillis(l.interval),
+		formatMillis(l.tolerance),
+		strconv.FormatInt(now.UnixMilli(), 10),
+	})
+	if err != nil {
+		if l.rescue.shouldRescue(err) {
+			return l.local.allow(key, time.Now()), nil
+		}
+		return Result{}, err
+	}
+
+	vals, ok := resp.([]any)
+	if !ok || len(vals) != 2 {
+		return Result{}, ErrUnknownCode
+	}
+	allowed, ok1 := vals[0].(int64)
+	tatStr, ok2 := vals[1].(string)
+	if !ok1 || !ok2 {
+		return Result{}, ErrUnknownCode
+	}
+	tatMillis, err := strconv.ParseFloat(tatStr, 64)
+	if err != nil {
+		return Result{}, ErrUnknownCode
+	}
+
+	// compute with the same time in milliseconds that was passed to the script.
+	tat := time.UnixMicro(int64(tatMillis * 1000))
+	return gcraResult(allowed == 1, tat, time.UnixMilli(now.UnixMilli()), l.interval, l.tolerance), nil
+}
+
+// NewLocalGcraLimit returns a LocalGcraLimit that allows rate requests per period with bursts of burst.
+func NewLocalGcraLimit(period time.Duration, rate, burst int) *LocalGcraLimit {
+	interval, tolerance := gcraParams(period, rate, burst)
+	return &LocalGcraLimit{
+		interval:  interval,
+		tolerance: tolerance,
+		tats:      make(map[string]time.Time),
+	}
+}
+
+// Allow is shorthand for AllowCtx(context.Background(), key).
+func (l *LocalGcraLimit) Allow(key string) (Result, error) {
+	return l.allow(key, time.Now()), nil
+}
+
+// AllowCtx takes a permit for key, and returns the decision.
+func (l *LocalGcraLimit) AllowCtx(_ context.Context, key string) (Result, error) {
+	return l.allow(key, time.Now()), nil
+}
+
+func (l *LocalGcraLimit) allow(key string, now time.Time) Result {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.sweep(now)
+	tat, ok := l.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(l.interval)
+	if now.Before(newTat.Add(-l.tolerance)) {
+		return gcraResult(false, tat, now, l.interval, l.tolerance)
+	}
+
+	l.tats[key] = newTat
+	return gcraResult(true, newTat, now, l.interval, l.tolerance)
+}
+
+// sweep removes the keys that are back to full burst.
+func (l *LocalGcraLimit) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	l.lastSweep = now
+	for key, tat := range l.tats {
+		if tat.Before(now) {
+			delete(l.tats, key)
+		}
+	}
+}
+
+// gcraParams returns the emission interval and the burst tolerance.
+func gcraParams(period time.Duration, rate, burst int) (time.Duration, time.Duration) {
+	if rate <= 0 {
+		rate = 1
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+
+	interval := period / time.Duration(rate)
+	return interval, interval * time.Duration(burst)
+}
+
+// gcraResult computes the result, tat is the new theoretical arrival time if allowed,
+// otherwise the current one.
+func gcraResult(allowed bool, tat, now time.Time, interval, tolerance time.Duration) Result {
+	if allowed {
+		return Result{
+			Allowed:   true,
+			Remaining: max(0, int(now.Sub(tat.Add(-tolerance))/interval)),
+		}
+	}
+
+	return Result{
+		RetryAfter: ceilMillis(tat.Add(interval - tolerance).Sub(now)),
+	}
+}
+
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+}
@@ -0,0 +1,71 @@
+package limit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/stores/redis/redistest"
+)
+
+func TestGcraLimit_Allow(t *testing.T) {
+	store := redistest.CreateRedis(t)
+	testGcraLimit(t, NewGcraLimit(time.Minute, 60, 5, store, "gcralimit"))
+}
+
+func TestLocalGcraLimit_Allow(t *testing.T) {
+	testGcraLimit(t, NewLocalGcraLimit(time.Minute, 60, 5))
+}
+
+func TestGcraLimit_RedisUnavailable(t *testing.T) {
+	s, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	l := NewGcraLimit(time.Minute, 60, 5, redis.New(s.Addr()), "gcralimit")
+	s.Close()
+	testGcraLimit(t, l)
+	assert.False(t, l.rescue.alive())
+}
+
+func testGcraLimit(t *testing.T, l RateLimiter) {
+	for i := 0; i < 5; i++ {
+		res, err := l.AllowCtx(context.Background(), "first")
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 4-i, res.Remaining)
+	}
+
+	res, err := l.AllowCtx(context.Background(), "first")
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	// one request is emitted every second.
+	assert.True(t, res.RetryAfter > 900*time.Millisecond && res.RetryAfter <= time.Second, res.RetryAfter)
+
+	res, err = l.AllowCtx(context.Background(), "second")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestLocalGcraLimit_Emission(t *testing.T) {
+	l := NewLocalGcraLimit(time.Second, 10, 2)
+	now := time.Unix(1000, 0)
+
+	assert.True(t, l.allow("key", now).Allowed)
+	assert.True(t, l.allow("key", now).Allowed)
+	res := l.allow("key", now)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 100*time.Millisecond, res.RetryAfter)
+
+	now = now.Add(100 * time.Millisecond)
+	res = l.allow("key", now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.False(t, l.allow("key", now).Allowed)
+
+	// the keys back to full burst are removed.
+	l.allow("other", now.Add(sweepInterval*2))
+	assert.Len(t, l.tats, 1)
+}
@@ -0,0 +1,18 @@
+-- to be compatible with aliyun redis, we cannot use `local key = KEYS[1]` to reuse the key
+-- KEYS[1] as the theoretical arrival time in milliseconds
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tat = tonumber(redis.call("get", KEYS[1]) or "0")
+if tat < now then
+    tat = now
+end
+
+local new_tat = tat + interval
+if now < new_tat - tolerance then
+    -- return the values as strings, integer replies truncate the fractions
+    return {0, tostring(tat)}
+end
+
+redis.call("set", KEYS[1], new_tat, "PX", math.max(1, math.ceil(new_tat - now)))
+return {1, tostring(new_tat)}
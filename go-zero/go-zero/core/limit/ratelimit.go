@@ -0,0 +1,208 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/errorx"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+const (
+	// SlidingWindow is the algorithm of SlidingWindowLimit.
+	SlidingWindow = "sliding"
+	// Gcra is the algorithm of GcraLimit.
+	Gcra = "gcra"
+
+	// IpKey limits the requests by client ip.
+	IpKey = "ip"
+	// UserKey limits the requests by user, like the user id claim of jwt.
+	UserKey = "user"
+	// ClientKey limits the requests by client id, like the app of zrpc auth.
+	ClientKey = "client"
+	// RouteKey limits the requests by route, the path of rest or the method of zrpc.
+	RouteKey = "route"
+	// AnonymousKey is the key of the requests without an ip, user or client,
+	// they share one limit, so that the rules can't be bypassed by omitting the key.
+	AnonymousKey = "anonymous"
+
+	// sweepInterval is the interval to remove the idle keys of the in-process limiters.
+	sweepInterval = time.Minute
+)
+
+var errInvalidQuota = errors.New("rate limit quota and period must be positive")
+
+type (
+	// A Result is the decision of a RateLimiter.
+	Result struct {
+		Allowed bool
+		// Remaining is the number of requests still allowed right now.
+		Remaining int
+		// RetryAfter is the time to wait before the next request may be allowed,
+		// it's zero if the request is allowed.
+		RetryAfter time.Duration
+	}
+
+	// A RateLimiter limits the requests by key.
+	RateLimiter interface {
+		// AllowCtx takes a permit for key, and returns the decision.
+		AllowCtx(ctx context.Context, key string) (Result, error)
+	}
+
+	// RateLimitConf is the config of a rate limit rule.
+	RateLimitConf struct {
+		// Key is what to limit by, ip, user, client or route.
+		Key string `json:",default=ip,options=[ip,user,client,route]"`
+		// KeyName is the jwt claim or metadata key of the user, and the header or metadata key
+		// of the client, defaults to userId for users, X-Client-Id header and app metadata for clients.
+		// The zrpc metadata is set by the caller, so the user and client keys of zrpc
+		// only work for trusted callers.
+		KeyName   string `json:",optional"`
+		Algorithm string `json:",default=sliding,options=[sliding,gcra]"`
+		// Quota is the number of requests allowed in a Period.
+		Quota  int
+		Period time.Duration `json:",default=1s"`
+		// Burst is the max number of requests allowed at once with gcra, defaults to Quota.
+		Burst int `json:",optional"`
+		// TrustedProxies are the ips or CIDRs of the proxies trusted to set X-Forwarded-For
+		// for the ip rules of rest, the ip of the remote address is used if not set.
+		TrustedProxies []string `json:",optional"`
+		// Paths limits the rule to the rest route paths or the zrpc full methods, empty for all.
+		Paths []string `json:",optional"`
+		// Redis shares the limits across instances, the limits are in-process if not set.
+		Redis     redis.RedisConf `json:",optional"`
+		KeyPrefix string          `json:",optional"`
+	}
+)
+
+// NewRateLimiter returns the RateLimiter of c, keyPrefix is used if c.KeyPrefix is empty.
+func (c RateLimitConf) NewRateLimiter(keyPrefix string) (RateLimiter, error) {
+	if c.Quota <= 0 || c.Period <= 0 {
+		return nil, errInvalidQuota
+	}
+	if len(c.KeyPrefix) > 0 {
+		keyPrefix = c.KeyPrefix
+	}
+
+	var store *redis.Redis
+	if len(c.Redis.Host) > 0 {
+		rds, err := redis.NewRedis(c.Redis)
+		if err != nil {
+			return nil, err
+		}
+		store = rds
+	}
+
+	switch c.Algorithm {
+	case Gcra:
+		burst := c.Burst
+		if burst <= 0 {
+			burst = c.Quota
+		}
+		if store == nil {
+			return NewLocalGcraLimit(c.Period, c.Quota, burst), nil
+		}
+		return NewGcraLimit(c.Period, c.Quota, burst, store, keyPrefix), nil
+	case SlidingWindow, "":
+		if store == nil {
+			return NewLocalSlidingWindowLimit(c.Period, c.Quota), nil
+		}
+		return NewSlidingWindowLimit(c.Period, c.Quota, store, keyPrefix), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm: %s", c.Algorithm)
+	}
+}
+
+// Match checks if the rule applies to the rest route path or the zrpc full method.
+func (c RateLimitConf) Match(path string) bool {
+	if len(c.Paths) == 0 {
+		return true
+	}
+
+	for _, p := range c.Paths {
+		if p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ceilMillis rounds d up to milliseconds, to not retry too early.
+func ceilMillis(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(math.Ceil(float64(d)/float64(time.Millisecond))) * time.Millisecond
+}
+
+// redisRescue falls back to the in-process limiter while redis is down,
+// the same way as TokenLimiter does.
+type redisRescue struct {
+	store          *redis.Redis
+	lock           sync.Mutex
+	redisAlive     uint32
+	monitorStarted bool
+}
+
+func newRedisRescue(store *redis.Redis) *redisRescue {
+	return &redisRescue{
+		store:      store,
+		redisAlive: 1,
+	}
+}
+
+func (r *redisRescue) alive() bool {
+	return atomic.LoadUint32(&r.redisAlive) == 1
+}
+
+// shouldRescue checks if err should be handled by the in-process limiter,
+// context errors are returned to the caller instead.
+func (r *redisRescue) shouldRescue(err error) bool {
+	if errorx.In(err, context.DeadlineExceeded, context.Canceled) {
+		logx.Errorf("fail to use rate limiter: %s", err)
+		return false
+	}
+
+	logx.Errorf("fail to use rate limiter: %s, use in-process limiter for rescue", err)
+	r.startMonitor()
+	return true
+}
+
+func (r *redisRescue) startMonitor() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.monitorStarted {
+		return
+	}
+
+	r.monitorStarted = true
+	atomic.StoreUint32(&r.redisAlive, 0)
+
+	go r.waitForRedis()
+}
+
+func (r *redisRescue) waitForRedis() {
+	ticker := time.NewTicker(pingInterval)
+	defer func() {
+		ticker.Stop()
+		r.lock.Lock()
+		r.monitorStarted = false
+		r.lock.Unlock()
+	}()
+
+	for range ticker.C {
+		if r.store.Ping() {
+			atomic.StoreUint32(&r.redisAlive, 1)
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestRateLimitConf_NewRateLimiter(t *testing.T) {
+	_, err := RateLimitConf{Period: time.Second}.NewRateLimiter("")
+	assert.ErrorIs(t, err, errInvalidQuota)
+
+	_, err = RateLimitConf{Quota: 1, Period: time.Second, Algorithm: "leaky"}.NewRateLimiter("")
+	assert.Error(t, err)
+
+	l, err := RateLimitConf{Quota: 1, Period: time.Second}.NewRateLimiter("")
+	assert.NoError(t, err)
+	assert.IsType(t, &LocalSlidingWindowLimit{}, l)
+
+	l, err = RateLimitConf{Quota: 10, Period: time.Second, Algorithm: Gcra}.NewRateLimiter("")
+	assert.NoError(t, err)
+	assert.Equal(t, 100*time.Millisecond, l.(*LocalGcraLimit).interval)
+	assert.Equal(t, time.Second, l.(*LocalGcraLimit).tolerance)
+
+	s := miniredis.RunT(t)
+	conf := RateLimitConf{
+		Quota:     10,
+		Period:    time.Second,
+		Algorithm: SlidingWindow,
+		Redis:     redis.RedisConf{Host: s.Addr(), Type: redis.NodeType},
+		KeyPrefix: "custom:",
+	}
+	l, err = conf.NewRateLimiter("default:")
+	assert.NoError(t, err)
+	assert.Equal(t, "custom:", l.(*SlidingWindowLimit).keyPrefix)
+
+	conf.Algorithm = Gcra
+	conf.Burst = 3
+	l, err = conf.NewRateLimiter("default:")
+	assert.NoError(t, err)
+	assert.Equal(t, 300*time.Millisecond, l.(*GcraLimit).tolerance)
+}
+
+func TestRateLimitConf_Match(t *testing.T) {
+	assert.True(t, RateLimitConf{}.Match("/a"))
+	assert.True(t, RateLimitConf{Paths: []string{"/a", "/b"}}.Match("/b"))
+	assert.False(t, RateLimitConf{Paths: []string{"/a"}}.Match("/c"))
+}
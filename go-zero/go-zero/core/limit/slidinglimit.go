@@ -0,0 +1,204 @@
+package limit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+const slidingWindowFormat = "{%s%s}.%d"
+
+var (
+	//go:embed slidingwindowscript.lua
+	slidingWindowLuaScript string
+	slidingWindowScript    = redis.NewScript(slidingWindowLuaScript)
+)
+
+type (
+	// A SlidingWindowLimit limits the requests in a sliding window with redis.
+	// It weights the count of the previous fixed window by its overlap with the sliding window,
+	// so it doesn't let through the 2x bursts at the window edges like PeriodLimit does.
+	SlidingWindowLimit struct {
+		window    time.Duration
+		quota     int
+		store     *redis.Redis
+		keyPrefix string
+		rescue    *redisRescue
+		local     *LocalSlidingWindowLimit
+	}
+
+	// A LocalSlidingWindowLimit is the in-process equivalent of SlidingWindowLimit.
+	LocalSlidingWindowLimit struct {
+		window    time.Duration
+		quota     int
+		lock      sync.Mutex
+		counters  map[string]*slidingCounter
+		lastSweep time.Time
+	}
+
+	slidingCounter struct {
+		// index is the index of the current fixed window.
+		index   int64
+		prev    int
+		current int
+	}
+)
+
+// NewSlidingWindowLimit returns a SlidingWindowLimit that allows quota requests in window.
+func NewSlidingWindowLimit(window time.Duration, quota int, store *redis.Redis,
+	keyPrefix string) *SlidingWindowLimit {
+	return &SlidingWindowLimit{
+		window:    window,
+		quota:     quota,
+		store:     store,
+		keyPrefix: keyPrefix,
+		rescue:    newRedisRescue(store),
+		local:     NewLocalSlidingWindowLimit(window, quota),
+	}
+}
+
+// Allow is shorthand for AllowCtx(context.Background(), key).
+func (l *SlidingWindowLimit) Allow(key string) (Result, error) {
+	return l.AllowCtx(context.Background(), key)
+}
+
+// AllowCtx takes a permit for key, and returns the decision.
+func (l *SlidingWindowLimit) AllowCtx(ctx context.Context, key string) (Result, error) {
+	now := time.Now()
+	if !l.rescue.alive() {
+		return l.local.allow(key, now), nil
+	}
+
+	index, elapsed := windowIndex(now, l.window)
+	resp, err := l.store.ScriptRunCtx(ctx, slidingWindowScript, []string{
+		fmt.Sprintf(slidingWindowFormat, l.keyPrefix, key, index-1),
+		fmt.Sprintf(slidingWindowFormat, l.keyPrefix, key, index),
+	}, []string{
+		strconv.Itoa(l.quota),
+		strconv.FormatInt(l.window.Milliseconds(), 10),
+		strconv.FormatInt(elapsed.Milliseconds(), 10),
+	})
+	if err != nil {
+		if l.rescue.shouldRescue(err) {
+			return l.local.allow(key, time.Now()), nil
+		}
+		return Result{}, err
+	}
+
+	vals, ok := resp.([]any)
+	if !ok || len(vals) != 3 {
+		return Result{}, ErrUnknownCode
+	}
+	allowed, ok1 := vals[0].(int64)
+	prev, ok2 := vals[1].(int64)
+	current, ok3 := vals[2].(int64)
+	if !ok1 || !ok2 || !ok3 {
+		return Result{}, ErrUnknownCode
+	}
+
+	return slidingResult(allowed == 1, int(prev), int(current), elapsed, l.window, l.quota), nil
+}
+
+// NewLocalSlidingWindowLimit returns a LocalSlidingWindowLimit that allows quota requests in window.
+func NewLocalSlidingWindowLimit(window time.Duration, quota int) *LocalSlidingWindowLimit {
+	return &LocalSlidingWindowLimit{
+		window:   window,
+		quota:    quota,
+		counters: make(map[string]*slidingCounter),
+	}
+}
+
+// Allow is shorthand for AllowCtx(context.Background(), key).
+func (l *LocalSlidingWindowLimit) Allow(key string) (Result, error) {
+	return l.allow(key, time.Now()), nil
+}
+
+// AllowCtx takes a permit for key, and returns the decision.
+func (l *LocalSlidingWindowLimit) AllowCtx(_ context.Context, key string) (Result, error) {
+	return l.allow(key, time.Now()), nil
+}
+
+func (l *LocalSlidingWindowLimit) allow(key string, now time.Time) Result {
+	index, elapsed := windowIndex(now, l.window)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.sweep(now, index)
+	counter, ok := l.counters[key]
+	if !ok {
+		counter = &slidingCounter{index: index}
+		l.counters[key] = counter
+	}
+	switch {
+	case counter.index == index-1:
+		counter.prev, counter.current = counter.current, 0
+	case counter.index < index-1:
+		counter.prev, counter.current = 0, 0
+	}
+	counter.index = index
+
+	estimated := float64(counter.prev)*float64(l.window-elapsed)/float64(l.window) + float64(counter.current)
+	allowed := estimated+1 <= float64(l.quota)
+	if allowed {
+		counter.current++
+	}
+
+	return slidingResult(allowed, counter.prev, counter.current, elapsed, l.window, l.quota)
+}
+
+// sweep removes the counters that have no requests in the sliding window.
+func (l *LocalSlidingWindowLimit) sweep(now time.Time, index int64) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	l.lastSweep = now
+	for key, counter := range l.counters {
+		if counter.index < index-1 {
+			delete(l.counters, key)
+		}
+	}
+}
+
+// windowIndex returns the index of the fixed window that now is in, and the elapsed time in it.
+func windowIndex(now time.Time, window time.Duration) (int64, time.Duration) {
+	nanos := now.UnixNano()
+	return nanos / int64(window), time.Duration(nanos % int64(window))
+}
+
+// slidingResult computes the result from the counts of the previous and the current windows.
+// If allowed, current already includes the request.
+func slidingResult(allowed bool, prev, current int, elapsed, window time.Duration, quota int) Result {
+	weight := float64(window-elapsed) / float64(window)
+	estimated := float64(prev)*weight + float64(current)
+	if allowed {
+		return Result{
+			Allowed:   true,
+			Remaining: max(0, int(math.Floor(float64(quota)-estimated))),
+		}
+	}
+
+	// the estimated count decreases as the previous window slides out, find the time it
+	// leaves room for one more request, in the current window or the next one.
+	progress := float64(elapsed) / float64(window)
+	var wait float64
+	if room := float64(quota - current - 1); room >= 0 && prev > 0 {
+		wait = 1 - room/float64(prev) - progress
+	} else {
+		wait = 1 - progress
+		if current > 0 {
+			wait += max(0, 1-float64(quota-1)/float64(current))
+		}
+	}
+
+	return Result{
+		RetryAfter: ceilMillis(time.Duration(wait * float64(window))),
+	}
+}
@@ -0,0 +1,91 @@
+package limit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/stores/redis/redistest"
+)
+
+func TestSlidingWindowLimit_Allow(t *testing.T) {
+	store := redistest.CreateRedis(t)
+	testSlidingWindowLimit(t, NewSlidingWindowLimit(time.Minute, 5, store, "slidinglimit"))
+}
+
+func TestLocalSlidingWindowLimit_Allow(t *testing.T) {
+	testSlidingWindowLimit(t, NewLocalSlidingWindowLimit(time.Minute, 5))
+}
+
+func TestSlidingWindowLimit_RedisUnavailable(t *testing.T) {
+	s, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	l := NewSlidingWindowLimit(time.Minute, 5, redis.New(s.Addr()), "slidinglimit")
+	s.Close()
+	testSlidingWindowLimit(t, l)
+	assert.False(t, l.rescue.alive())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = NewSlidingWindowLimit(time.Minute, 5, redistest.CreateRedis(t), "slidinglimit")
+	_, err = l.AllowCtx(ctx, "first")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func testSlidingWindowLimit(t *testing.T, l RateLimiter) {
+	var allowed int
+	for i := 0; i < 10; i++ {
+		res, err := l.AllowCtx(context.Background(), "first")
+		assert.NoError(t, err)
+		if res.Allowed {
+			allowed++
+			assert.Zero(t, res.RetryAfter)
+		} else {
+			assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= 2*time.Minute, res.RetryAfter)
+		}
+	}
+	// the window may slide to the next one during the test.
+	assert.True(t, allowed >= 5 && allowed <= 10)
+
+	res, err := l.AllowCtx(context.Background(), "second")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestLocalSlidingWindowLimit_NoEdgeBurst(t *testing.T) {
+	l := NewLocalSlidingWindowLimit(time.Second, 10)
+	start := time.Unix(1000, 0)
+
+	// use up the quota at the end of a window.
+	for i := 0; i < 10; i++ {
+		assert.True(t, l.allow("key", start.Add(900*time.Millisecond)).Allowed)
+	}
+	res := l.allow("key", start.Add(900*time.Millisecond))
+	assert.False(t, res.Allowed)
+	// 10*(1-p)+0+1 <= 10 when p >= 0.1 in the next window
+	assert.Equal(t, 200*time.Millisecond, res.RetryAfter)
+
+	// a fixed window would allow 10 more right after the edge,
+	// 90% of the previous window is still in the sliding window.
+	var allowed int
+	for i := 0; i < 10; i++ {
+		if l.allow("key", start.Add(1100*time.Millisecond)).Allowed {
+			allowed++
+		}
+	}
+	assert.Equal(t, 1, allowed)
+
+	res = l.allow("key", start.Add(1100*time.Millisecond))
+	assert.False(t, res.Allowed)
+	// 10*(1-p)+1+1 <= 10 when p >= 0.2 in the current window
+	assert.Equal(t, 100*time.Millisecond, res.RetryAfter)
+	assert.True(t, l.allow("key", start.Add(1200*time.Millisecond)).Allowed)
+
+	// the counters without requests in the sliding window are removed.
+	l.allow("other", start.Add(sweepInterval*2))
+	assert.Len(t, l.counters, 1)
+}
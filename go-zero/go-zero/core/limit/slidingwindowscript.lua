@@ -0,0 +1,17 @@
+-- to be compatible with aliyun redis, we cannot use `local key = KEYS[1]` to reuse the key
+-- KEYS[1] as the counter of the previous window
+-- KEYS[2] as the counter of the current window
+local quota = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+local prev = tonumber(redis.call("get", KEYS[1]) or "0")
+local current = tonumber(redis.call("get", KEYS[2]) or "0")
+if prev * (window - elapsed) / window + current + 1 > quota then
+    return {0, prev, current}
+end
+
+current = redis.call("INCRBY", KEYS[2], 1)
+if current == 1 then
+    redis.call("pexpire", KEYS[2], window * 2)
+end
+return {1, prev, current}
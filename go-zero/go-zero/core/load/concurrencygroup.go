@@ -0,0 +1,95 @@
+package load
+
+import "sync"
+
+// A ConcurrencyLimiterGroup limits the concurrency of each key, like the routes or the methods,
+// and the concurrency of all the keys together. The adaptive limit of each key protects the others
+// from a slow key, and the fixed shared limit sheds the lower priorities first when overloaded.
+type ConcurrencyLimiterGroup struct {
+	conf     ConcurrencyConf
+	shared   *adaptiveConcurrencyLimiter
+	lock     sync.RWMutex
+	limiters map[string]*adaptiveConcurrencyLimiter
+}
+
+// NewConcurrencyLimiterGroup returns a ConcurrencyLimiterGroup.
+func NewConcurrencyLimiterGroup(c ConcurrencyConf) *ConcurrencyLimiterGroup {
+	sharedLimit := c.SharedLimit
+	if sharedLimit <= 0 {
+		sharedLimit = c.withDefaults().MaxLimit
+	}
+
+	return &ConcurrencyLimiterGroup{
+		conf: c,
+		// the min and max limits are the same, so the shared limit is fixed.
+		shared: newAdaptiveConcurrencyLimiter(ConcurrencyConf{
+			InitialLimit: sharedLimit,
+			MinLimit:     sharedLimit,
+			MaxLimit:     sharedLimit,
+		}),
+		limiters: make(map[string]*adaptiveConcurrencyLimiter),
+	}
+}
+
+// Allow returns the Promise if the request of key with priority is allowed,
+// otherwise ErrConcurrencyLimited.
+func (g *ConcurrencyLimiterGroup) Allow(key string, priority Priority) (Promise, error) {
+	// the limit of the key is not shared, so it's not by priority.
+	p, err := g.getLimiter(key).allow(PriorityCritical)
+	if err != nil {
+		return nil, err
+	}
+
+	sp, err := g.shared.allow(priority)
+	if err != nil {
+		// the request isn't processed, it doesn't tell anything about the latency.
+		p.limiter.release()
+		return nil, err
+	}
+
+	return groupPromise{p, sp}, nil
+}
+
+// GetLimiter returns the ConcurrencyLimiter of key.
+func (g *ConcurrencyLimiterGroup) GetLimiter(key string) ConcurrencyLimiter {
+	return g.getLimiter(key)
+}
+
+// SharedLimit returns the current limit of all the keys together.
+func (g *ConcurrencyLimiterGroup) SharedLimit() int {
+	return g.shared.Limit()
+}
+
+func (g *ConcurrencyLimiterGroup) getLimiter(key string) *adaptiveConcurrencyLimiter {
+	g.lock.RLock()
+	limiter, ok := g.limiters[key]
+	g.lock.RUnlock()
+	if ok {
+		return limiter
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if limiter, ok = g.limiters[key]; ok {
+		return limiter
+	}
+
+	limiter = newAdaptiveConcurrencyLimiter(g.conf)
+	g.limiters[key] = limiter
+	return limiter
+}
+
+type groupPromise []Promise
+
+func (p groupPromise) Fail() {
+	for _, each := range p {
+		each.Fail()
+	}
+}
+
+func (p groupPromise) Pass() {
+	for _, each := range p {
+		each.Pass()
+	}
+}
@@ -0,0 +1,81 @@
+package load
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimiterGroup(t *testing.T) {
+	conf := ConcurrencyConf{
+		InitialLimit: 4,
+		MinLimit:     1,
+		MaxLimit:     10,
+		SharedLimit:  4,
+	}
+
+	t.Run("limit of key", func(t *testing.T) {
+		group := NewConcurrencyLimiterGroup(conf)
+		assert.Equal(t, 4, group.SharedLimit())
+		assert.Equal(t, group.GetLimiter("a"), group.GetLimiter("a"))
+
+		for i := 0; i < 4; i++ {
+			_, err := group.Allow("a", PriorityCritical)
+			assert.NoError(t, err)
+		}
+		_, err := group.Allow("a", PriorityCritical)
+		assert.ErrorIs(t, err, ErrConcurrencyLimited)
+		// the shared limit is used up by a.
+		_, err = group.Allow("b", PriorityCritical)
+		assert.ErrorIs(t, err, ErrConcurrencyLimited)
+	})
+
+	t.Run("shared limit by priority", func(t *testing.T) {
+		group := NewConcurrencyLimiterGroup(conf)
+		for i := 0; i < 2; i++ {
+			_, err := group.Allow("b", PriorityLow)
+			assert.NoError(t, err)
+		}
+		_, err := group.Allow("b", PriorityLow)
+		assert.ErrorIs(t, err, ErrConcurrencyLimited)
+		// rejected by the shared limit, the permit of the key is released without backing off.
+		assert.Equal(t, 4, group.GetLimiter("b").Limit())
+		assert.Equal(t, 2, group.getLimiter("b").inflight)
+
+		_, err = group.Allow("c", PriorityCritical)
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		group := NewConcurrencyLimiterGroup(conf)
+		p, err := group.Allow("c", PriorityCritical)
+		assert.NoError(t, err)
+		p.Fail()
+		assert.Equal(t, 3, group.GetLimiter("c").Limit())
+		// the shared limit is fixed.
+		assert.Equal(t, 4, group.SharedLimit())
+	})
+
+	t.Run("default shared limit", func(t *testing.T) {
+		group := NewConcurrencyLimiterGroup(ConcurrencyConf{InitialLimit: 20})
+		assert.Equal(t, defaultMaxLimit, group.SharedLimit())
+		// the normal priority isn't capped by the initial limit of the keys.
+		for i := 0; i < 40; i++ {
+			_, err := group.Allow(fmt.Sprintf("key%d", i%2), PriorityNormal)
+			assert.NoError(t, err)
+		}
+
+		group = NewConcurrencyLimiterGroup(ConcurrencyConf{MaxLimit: 10})
+		assert.Equal(t, 10, group.SharedLimit())
+	})
+
+	t.Run("pass", func(t *testing.T) {
+		group := NewConcurrencyLimiterGroup(conf)
+		p, err := group.Allow("d", PriorityNormal)
+		assert.NoError(t, err)
+		p.Pass()
+		assert.Equal(t, 0, group.getLimiter("d").inflight)
+		assert.Equal(t, 0, group.shared.inflight)
+	})
+}
@@ -0,0 +1,235 @@
+package load
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/mathx"
+	"github.com/zeromicro/go-zero/core/timex"
+)
+
+const (
+	// PriorityLow is the priority of the requests that are shed first.
+	PriorityLow Priority = "low"
+	// PriorityNormal is the default priority.
+	PriorityNormal Priority = "normal"
+	// PriorityCritical is the priority of the requests that are shed last.
+	PriorityCritical Priority = "critical"
+
+	defaultInitialLimit = 20
+	defaultMinLimit     = 1
+	defaultMaxLimit     = 1000
+	// the shares of the limit that the priorities can use, so the lower priorities
+	// are rejected first when the limit is reached.
+	lowShare    = 0.5
+	normalShare = 0.8
+	// the requests are allowed to be queued up to sqrt(limit) with the gradient.
+	minGradient = 0.5
+	// rttTolerance tolerates the short rtt to be 1.5x of the long rtt without decreasing the limit.
+	rttTolerance = 1.5
+	// smoothing is the weight of the new limit to be applied.
+	smoothing = 0.2
+	// shortRttBeta is the moving average hyperparameter for the recent rtt.
+	shortRttBeta = 0.9
+	// longRttWindow is the number of samples of the long-term moving average of the rtt.
+	longRttWindow = 600
+	// longRttWarmup is the number of samples to average before using the moving average.
+	longRttWarmup = 10
+	// longRttDecay pulls the long rtt down if it drifts far above the recent rtt.
+	longRttDecay = 0.95
+	// backoffRatio decreases the limit on failures, like timeouts.
+	backoffRatio = 0.9
+)
+
+// ErrConcurrencyLimited is returned by ConcurrencyLimiter.Allow when the limit is reached.
+var ErrConcurrencyLimited = errors.New("concurrency limit reached")
+
+type (
+	// Priority is the priority class of the requests.
+	Priority string
+
+	// ConcurrencyConf is the config of the adaptive concurrency limiters.
+	ConcurrencyConf struct {
+		// Enabled enables the limiters on all the routes or methods.
+		Enabled      bool `json:",optional"`
+		InitialLimit int  `json:",default=20"`
+		MinLimit     int  `json:",default=1"`
+		MaxLimit     int  `json:",default=1000"`
+		// SharedLimit is the max concurrent requests of all the routes or methods together,
+		// the lower priorities are rejected first when it's reached. It's not adjusted by
+		// the latency, which differs by route. Defaults to MaxConns of rest, or MaxLimit.
+		SharedLimit int `json:",optional"`
+	}
+
+	// A ConcurrencyLimiter limits the concurrent requests with the limit adjusted by the latency.
+	ConcurrencyLimiter interface {
+		// Allow returns the Promise if allowed, otherwise ErrConcurrencyLimited.
+		Allow(priority Priority) (Promise, error)
+		// Limit returns the current limit.
+		Limit() int
+	}
+
+	// adaptiveConcurrencyLimiter adjusts the limit by the gradient of the long-term rtt
+	// against the recent rtt, like the gradient2 algorithm of Netflix concurrency-limits.
+	// The limit goes down when the requests queue up and the rtt increases,
+	// and goes up by sqrt(limit) when the rtt is stable.
+	adaptiveConcurrencyLimiter struct {
+		lock     sync.Mutex
+		limit    float64
+		minLimit float64
+		maxLimit float64
+		inflight int
+		shortRtt float64
+		longRtt  float64
+		samples  int
+	}
+)
+
+// NewAdaptiveConcurrencyLimiter returns a ConcurrencyLimiter that adjusts the limit by the latency.
+func NewAdaptiveConcurrencyLimiter(c ConcurrencyConf) ConcurrencyLimiter {
+	return newAdaptiveConcurrencyLimiter(c)
+}
+
+func newAdaptiveConcurrencyLimiter(c ConcurrencyConf) *adaptiveConcurrencyLimiter {
+	c = c.withDefaults()
+	return &adaptiveConcurrencyLimiter{
+		limit:    float64(c.InitialLimit),
+		minLimit: float64(c.MinLimit),
+		maxLimit: float64(c.MaxLimit),
+	}
+}
+
+// Allow implements ConcurrencyLimiter.Allow.
+func (l *adaptiveConcurrencyLimiter) Allow(priority Priority) (Promise, error) {
+	p, err := l.allow(priority)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Limit implements ConcurrencyLimiter.Limit.
+func (l *adaptiveConcurrencyLimiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return int(l.limit)
+}
+
+func (l *adaptiveConcurrencyLimiter) allow(priority Priority) (*concurrencyPromise, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if float64(l.inflight) >= l.limit*priority.share() {
+		return nil, ErrConcurrencyLimited
+	}
+
+	l.inflight++
+	return &concurrencyPromise{
+		start:   timex.Now(),
+		limiter: l,
+	}, nil
+}
+
+func (l *adaptiveConcurrencyLimiter) onDrop() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.inflight--
+	l.limit = mathx.Between(l.limit*backoffRatio, l.minLimit, l.maxLimit)
+}
+
+// release releases the permit without adjusting the limit.
+func (l *adaptiveConcurrencyLimiter) release() {
+	l.lock.Lock()
+	l.inflight--
+	l.lock.Unlock()
+}
+
+func (l *adaptiveConcurrencyLimiter) onSample(rtt time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// the inflight requests before this one finishes.
+	inflight := l.inflight
+	l.inflight--
+
+	sample := float64(rtt)
+	if sample <= 0 {
+		return
+	}
+
+	l.samples++
+	if l.samples == 1 {
+		l.shortRtt = sample
+	} else {
+		l.shortRtt = l.shortRtt*shortRttBeta + sample*(1-shortRttBeta)
+	}
+	if l.samples <= longRttWarmup {
+		l.longRtt += (sample - l.longRtt) / float64(l.samples)
+	} else {
+		l.longRtt += (sample - l.longRtt) / longRttWindow
+	}
+	// the long rtt can't catch up quickly after a long period of high rtt,
+	// pull it down to recover the limit faster.
+	if l.longRtt/l.shortRtt > 2 {
+		l.longRtt *= longRttDecay
+	}
+
+	// don't increase the limit if it's not used, the rtt doesn't reflect the load then.
+	if float64(inflight) < l.limit/2 {
+		return
+	}
+
+	gradient := mathx.Between(rttTolerance*l.longRtt/l.shortRtt, minGradient, 1)
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	newLimit = l.limit*(1-smoothing) + newLimit*smoothing
+	l.limit = mathx.Between(newLimit, l.minLimit, l.maxLimit)
+}
+
+func (c ConcurrencyConf) withDefaults() ConcurrencyConf {
+	if c.MinLimit <= 0 {
+		c.MinLimit = defaultMinLimit
+	}
+	if c.MaxLimit <= 0 {
+		c.MaxLimit = defaultMaxLimit
+	}
+	if c.MaxLimit < c.MinLimit {
+		c.MaxLimit = c.MinLimit
+	}
+	if c.InitialLimit <= 0 {
+		c.InitialLimit = defaultInitialLimit
+	}
+	c.InitialLimit = mathx.Between(c.InitialLimit, c.MinLimit, c.MaxLimit)
+
+	return c
+}
+
+// share returns the share of the limit that p can use.
+func (p Priority) share() float64 {
+	switch p {
+	case PriorityCritical:
+		return 1
+	case PriorityLow:
+		return lowShare
+	default:
+		return normalShare
+	}
+}
+
+type concurrencyPromise struct {
+	start   time.Duration
+	limiter *adaptiveConcurrencyLimiter
+}
+
+// Fail lets the limiter back off, like on timeouts.
+func (p *concurrencyPromise) Fail() {
+	p.limiter.onDrop()
+}
+
+// Pass lets the limiter adjust the limit by the latency.
+func (p *concurrencyPromise) Pass() {
+	p.limiter.onSample(timex.Since(p.start))
+}
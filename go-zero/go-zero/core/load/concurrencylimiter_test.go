@@ -0,0 +1,127 @@
+package load
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveConcurrencyLimiter_Allow(t *testing.T) {
+	limiter := NewAdaptiveConcurrencyLimiter(ConcurrencyConf{
+		InitialLimit: 10,
+		MinLimit:     1,
+		MaxLimit:     100,
+	})
+	assert.Equal(t, 10, limiter.Limit())
+
+	var promises []Promise
+	for i := 0; i < 5; i++ {
+		p, err := limiter.Allow(PriorityLow)
+		assert.NoError(t, err)
+		promises = append(promises, p)
+	}
+	// low priority can use half of the limit.
+	_, err := limiter.Allow(PriorityLow)
+	assert.ErrorIs(t, err, ErrConcurrencyLimited)
+
+	for i := 0; i < 3; i++ {
+		p, err := limiter.Allow(PriorityNormal)
+		assert.NoError(t, err)
+		promises = append(promises, p)
+	}
+	_, err = limiter.Allow(PriorityNormal)
+	assert.ErrorIs(t, err, ErrConcurrencyLimited)
+
+	for i := 0; i < 2; i++ {
+		p, err := limiter.Allow(PriorityCritical)
+		assert.NoError(t, err)
+		promises = append(promises, p)
+	}
+	_, err = limiter.Allow(PriorityCritical)
+	assert.ErrorIs(t, err, ErrConcurrencyLimited)
+
+	promises[0].Pass()
+	p, err := limiter.Allow(PriorityCritical)
+	assert.NoError(t, err)
+	p.Pass()
+}
+
+func TestAdaptiveConcurrencyLimiter_Fail(t *testing.T) {
+	limiter := NewAdaptiveConcurrencyLimiter(ConcurrencyConf{
+		InitialLimit: 10,
+		MinLimit:     8,
+		MaxLimit:     100,
+	})
+
+	p, err := limiter.Allow(PriorityNormal)
+	assert.NoError(t, err)
+	p.Fail()
+	assert.Equal(t, 9, limiter.Limit())
+
+	for i := 0; i < 10; i++ {
+		p, err := limiter.Allow(PriorityNormal)
+		assert.NoError(t, err)
+		p.Fail()
+	}
+	assert.Equal(t, 8, limiter.Limit())
+}
+
+func TestAdaptiveConcurrencyLimiter_Gradient(t *testing.T) {
+	limiter := newAdaptiveConcurrencyLimiter(ConcurrencyConf{
+		InitialLimit: 20,
+		MinLimit:     1,
+		MaxLimit:     40,
+	})
+
+	sample := func(rtt time.Duration, inflight int) {
+		limiter.inflight = inflight
+		limiter.onSample(rtt)
+	}
+
+	// stable rtt with the limit used, the limit goes up.
+	for i := 0; i < 20; i++ {
+		sample(10*time.Millisecond, limiter.Limit())
+	}
+	stable := limiter.Limit()
+	assert.Greater(t, stable, 20)
+	assert.LessOrEqual(t, stable, 40)
+
+	// the limit isn't used, it doesn't change.
+	sample(10*time.Millisecond, 1)
+	assert.Equal(t, stable, limiter.Limit())
+
+	// the requests queue up and the rtt increases, the limit goes down.
+	for i := 0; i < 50; i++ {
+		sample(100*time.Millisecond, limiter.Limit())
+	}
+	assert.Less(t, limiter.Limit(), stable)
+
+	// the long rtt catches up with the new rtt, the limit recovers.
+	low := limiter.Limit()
+	for i := 0; i < 1000; i++ {
+		sample(100*time.Millisecond, limiter.Limit())
+	}
+	assert.Greater(t, limiter.Limit(), low)
+
+	// ignore the invalid samples.
+	limit := limiter.Limit()
+	sample(0, limit)
+	assert.Equal(t, limit, limiter.Limit())
+}
+
+func TestConcurrencyConf_withDefaults(t *testing.T) {
+	c := ConcurrencyConf{}.withDefaults()
+	assert.Equal(t, defaultInitialLimit, c.InitialLimit)
+	assert.Equal(t, defaultMinLimit, c.MinLimit)
+	assert.Equal(t, defaultMaxLimit, c.MaxLimit)
+
+	c = ConcurrencyConf{
+		InitialLimit: 100,
+		MinLimit:     10,
+		MaxLimit:     5,
+	}.withDefaults()
+	assert.Equal(t, 10, c.InitialLimit)
+	assert.Equal(t, 10, c.MinLimit)
+	assert.Equal(t, 10, c.MaxLimit)
+}
@@ -3,6 +3,8 @@ package rest
 import (
 	"time"
 
+	"github.com/zeromicro/go-zero/core/limit"
+	"github.com/zeromicro/go-zero/core/load"
 	"github.com/zeromicro/go-zero/core/service"
 )
 
@@ -59,5 +61,10 @@ type (
 		Middlewares MiddlewaresConf
 		// TraceIgnorePaths is paths blacklist for trace middleware.
 		TraceIgnorePaths []string `json:",optional"`
+		// RateLimits are the rate limit rules, applied after the jwt authorization.
+		RateLimits []limit.RateLimitConf `json:",optional"`
+		// AdaptiveConcurrency limits the concurrency of each route by the latency,
+		// enabled on all routes if AdaptiveConcurrency.Enabled, or by WithAdaptiveConcurrency.
+		AdaptiveConcurrency load.ConcurrencyConf `json:",optional"`
 	}
 )
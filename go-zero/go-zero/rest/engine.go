@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/zeromicro/go-zero/core/codec"
+	"github.com/zeromicro/go-zero/core/limit"
 	"github.com/zeromicro/go-zero/core/load"
 	"github.com/zeromicro/go-zero/core/logc"
 	"github.com/zeromicro/go-zero/core/stat"
@@ -40,6 +41,8 @@ type engine struct {
 	middlewares          []Middleware
 	shedder              load.Shedder
 	priorityShedder      load.Shedder
+	rateLimiters         []limit.RateLimiter
+	concurrencyLimiters  *load.ConcurrencyLimiterGroup
 	tlsConfig            *tls.Config
 }
 
@@ -54,6 +57,11 @@ func newEngine(c RestConf) *engine {
 		svr.priorityShedder = load.NewAdaptiveShedder(load.WithCpuThreshold(
 			(c.CpuThreshold + topCpuUsage) >> 1))
 	}
+	concurrency := c.AdaptiveConcurrency
+	if concurrency.SharedLimit <= 0 && c.Middlewares.MaxConns && c.MaxConns > 0 {
+		concurrency.SharedLimit = c.MaxConns
+	}
+	svr.concurrencyLimiters = load.NewConcurrencyLimiterGroup(concurrency)
 
 	return svr
 }
@@ -83,6 +91,38 @@ func (ng *engine) appendAuthHandler(fr featuredRoutes, chn chain.Chain,
 	return verifier(chn)
 }
 
+// appendConcurrencyHandler appends the adaptive concurrency handler if enabled on the routes or all routes.
+func (ng *engine) appendConcurrencyHandler(fr featuredRoutes, chn chain.Chain, route Route) chain.Chain {
+	setting := fr.concurrency
+	if !setting.enabled {
+		if !ng.conf.AdaptiveConcurrency.Enabled {
+			return chn
+		}
+		setting.priority = load.PriorityNormal
+	}
+
+	return chn.Append(handler.ConcurrencyHandler(ng.concurrencyLimiters, route.Path, route.Method,
+		setting.priority))
+}
+
+// appendRateLimitHandlers appends the handlers of the rate limit rules that match the route.
+func (ng *engine) appendRateLimitHandlers(chn chain.Chain, route Route) (chain.Chain, error) {
+	for i, rule := range ng.conf.RateLimits {
+		if !rule.Match(route.Path) {
+			continue
+		}
+
+		keyFunc, err := handler.RateLimitKey(rule, route.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		chn = chn.Append(handler.RateLimitHandler(ng.rateLimiters[i], keyFunc))
+	}
+
+	return chn, nil
+}
+
 func (ng *engine) bindFeaturedRoutes(router httpx.Router, fr featuredRoutes, metrics *stat.Metrics) error {
 	verifier, err := ng.signatureVerifier(fr.signature)
 	if err != nil {
@@ -105,7 +145,12 @@ func (ng *engine) bindRoute(fr featuredRoutes, router httpx.Router, metrics *sta
 		chn = ng.buildChainWithNativeMiddlewares(fr, route, metrics)
 	}
 
+	chn = ng.appendConcurrencyHandler(fr, chn, route)
 	chn = ng.appendAuthHandler(fr, chn, verifier)
+	chn, err := ng.appendRateLimitHandlers(chn, route)
+	if err != nil {
+		return err
+	}
 
 	for _, middleware := range ng.middlewares {
 		chn = chn.Append(convertMiddleware(middleware))
@@ -117,6 +162,9 @@ func (ng *engine) bindRoute(fr featuredRoutes, router httpx.Router, metrics *sta
 
 func (ng *engine) bindRoutes(router httpx.Router) error {
 	metrics := ng.createMetrics()
+	if err := ng.buildRateLimiters(); err != nil {
+		return err
+	}
 
 	for _, fr := range ng.routes {
 		if err := ng.bindFeaturedRoutes(router, fr, metrics); err != nil {
@@ -170,6 +218,21 @@ func (ng *engine) buildChainWithNativeMiddlewares(fr featuredRoutes, route Route
 	return chn
 }
 
+// buildRateLimiters builds the limiters of the rate limit rules, shared by the routes.
+func (ng *engine) buildRateLimiters() error {
+	ng.rateLimiters = make([]limit.RateLimiter, 0, len(ng.conf.RateLimits))
+	for i, rule := range ng.conf.RateLimits {
+		limiter, err := rule.NewRateLimiter(fmt.Sprintf("ratelimit:%s:%d:", ng.conf.Name, i))
+		if err != nil {
+			return err
+		}
+
+		ng.rateLimiters = append(ng.rateLimiters, limiter)
+	}
+
+	return nil
+}
+
 func (ng *engine) checkedMaxBytes(bytes int64) int64 {
 	if bytes > 0 {
 		return bytes
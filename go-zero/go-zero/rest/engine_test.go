@@ -15,6 +15,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/zeromicro/go-zero/core/conf"
 	"github.com/zeromicro/go-zero/core/fs"
+	"github.com/zeromicro/go-zero/core/limit"
+	"github.com/zeromicro/go-zero/core/load"
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/rest/router"
 )
@@ -540,6 +542,164 @@ func TestEngine_ReadWriteTimeout(t *testing.T) {
 	}
 }
 
+func TestEngine_rateLimits(t *testing.T) {
+	logx.Disable()
+
+	ng := newEngine(RestConf{
+		RateLimits: []limit.RateLimitConf{
+			{
+				Key:    limit.IpKey,
+				Quota:  1,
+				Period: time.Minute,
+				Paths:  []string{"/limited"},
+			},
+		},
+	})
+	ng.addRoutes(featuredRoutes{
+		routes: []Route{
+			{
+				Method:  http.MethodGet,
+				Path:    "/limited",
+				Handler: func(w http.ResponseWriter, r *http.Request) {},
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/free",
+				Handler: func(w http.ResponseWriter, r *http.Request) {},
+			},
+		},
+	})
+	rt := router.NewRouter()
+	assert.NoError(t, ng.bindRoutes(rt))
+
+	serve := func(path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		req.RemoteAddr = "1.2.3.4:1000"
+		resp := httptest.NewRecorder()
+		rt.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/limited"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("/limited"))
+	assert.Equal(t, http.StatusOK, serve("/free"))
+	assert.Equal(t, http.StatusOK, serve("/free"))
+}
+
+func TestEngine_rateLimitsError(t *testing.T) {
+	logx.Disable()
+
+	ng := newEngine(RestConf{
+		RateLimits: []limit.RateLimitConf{
+			{
+				Key:    limit.IpKey,
+				Period: time.Minute,
+			},
+		},
+	})
+	assert.Error(t, ng.bindRoutes(router.NewRouter()))
+
+	ng = newEngine(RestConf{
+		RateLimits: []limit.RateLimitConf{
+			{
+				Key:    "bad",
+				Quota:  1,
+				Period: time.Minute,
+			},
+		},
+	})
+	ng.addRoutes(featuredRoutes{
+		routes: []Route{
+			{
+				Method:  http.MethodGet,
+				Path:    "/",
+				Handler: func(w http.ResponseWriter, r *http.Request) {},
+			},
+		},
+	})
+	assert.Error(t, ng.bindRoutes(router.NewRouter()))
+}
+
+func TestEngine_adaptiveConcurrency(t *testing.T) {
+	logx.Disable()
+
+	handle := func(w http.ResponseWriter, r *http.Request) {}
+	ng := newEngine(RestConf{
+		AdaptiveConcurrency: load.ConcurrencyConf{
+			InitialLimit: 5,
+		},
+	})
+	ng.addRoutes(featuredRoutes{
+		routes: []Route{{Method: http.MethodGet, Path: "/critical", Handler: handle}},
+		concurrency: concurrencySetting{
+			enabled:  true,
+			priority: load.PriorityCritical,
+		},
+	})
+	ng.addRoutes(featuredRoutes{
+		routes: []Route{{Method: http.MethodGet, Path: "/free", Handler: handle}},
+	})
+	rt := router.NewRouter()
+	assert.NoError(t, ng.bindRoutes(rt))
+
+	serve := func(path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		resp := httptest.NewRecorder()
+		rt.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/critical"))
+	assert.Equal(t, http.StatusOK, serve("/free"))
+	assert.Equal(t, 5, ng.concurrencyLimiters.GetLimiter("GET /critical").Limit())
+
+	// take all the permits of the route, the limited route is rejected, the others are not.
+	limiter := ng.concurrencyLimiters.GetLimiter("GET /critical")
+	for i := 0; i < 5; i++ {
+		_, err := limiter.Allow(load.PriorityCritical)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/critical"))
+	assert.Equal(t, http.StatusOK, serve("/free"))
+
+	// enabled on all routes.
+	ng = newEngine(RestConf{
+		AdaptiveConcurrency: load.ConcurrencyConf{
+			Enabled:      true,
+			InitialLimit: 5,
+		},
+	})
+	ng.addRoutes(featuredRoutes{
+		routes: []Route{{Method: http.MethodGet, Path: "/free", Handler: handle}},
+	})
+	rt = router.NewRouter()
+	assert.NoError(t, ng.bindRoutes(rt))
+	limiter = ng.concurrencyLimiters.GetLimiter("GET /free")
+	for i := 0; i < 5; i++ {
+		_, err := limiter.Allow(load.PriorityCritical)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/free"))
+
+	// the shared limit defaults to MaxConns, not the initial limit of the routes.
+	ng = newEngine(RestConf{
+		MaxConns:    100,
+		Middlewares: MiddlewaresConf{MaxConns: true},
+		AdaptiveConcurrency: load.ConcurrencyConf{
+			InitialLimit: 5,
+		},
+	})
+	assert.Equal(t, 100, ng.concurrencyLimiters.SharedLimit())
+	ng = newEngine(RestConf{
+		MaxConns:    100,
+		Middlewares: MiddlewaresConf{MaxConns: true},
+		AdaptiveConcurrency: load.ConcurrencyConf{
+			SharedLimit: 50,
+		},
+	})
+	assert.Equal(t, 50, ng.concurrencyLimiters.SharedLimit())
+}
+
 func TestEngine_start(t *testing.T) {
 	logx.Disable()
 
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/zeromicro/go-zero/core/load"
+	"github.com/zeromicro/go-zero/core/logc"
+	"github.com/zeromicro/go-zero/core/metric"
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"github.com/zeromicro/go-zero/rest/internal/response"
+)
+
+var (
+	metricConcurrencyLimit = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "concurrency",
+		Name:      "limit",
+		Help:      "http server adaptive concurrency limit of routes.",
+		Labels:    []string{"path", "method"},
+	})
+
+	metricConcurrencySharedLimit = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "concurrency",
+		Name:      "shared_limit",
+		Help:      "http server concurrency limit of all routes.",
+	})
+
+	metricConcurrencyRejected = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "concurrency",
+		Name:      "rejected_total",
+		Help:      "http server requests rejected by the adaptive concurrency limit.",
+		Labels:    []string{"path", "method", "priority"},
+	})
+)
+
+// ConcurrencyHandler returns a middleware that limits the concurrent requests of the route
+// adaptively, the requests with lower priority are rejected first when overloaded.
+func ConcurrencyHandler(group *load.ConcurrencyLimiterGroup, path, method string,
+	priority load.Priority) func(http.Handler) http.Handler {
+	key := method + " " + path
+	limiter := group.GetLimiter(key)
+	report := func() {
+		metricConcurrencyLimit.Set(float64(limiter.Limit()), path, method)
+		metricConcurrencySharedLimit.Set(float64(group.SharedLimit()))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			promise, err := group.Allow(key, priority)
+			if err != nil {
+				metricConcurrencyRejected.Inc(path, method, string(priority))
+				report()
+				logc.Errorf(r.Context(), "[http] concurrency limited, %s - %s - %s",
+					r.RequestURI, httpx.GetRemoteAddr(r), r.UserAgent())
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			cw := response.NewWithCodeResponseWriter(w)
+			defer func() {
+				if cw.Code == http.StatusServiceUnavailable {
+					promise.Fail()
+				} else {
+					promise.Pass()
+				}
+				report()
+			}()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/load"
+)
+
+func TestConcurrencyHandler(t *testing.T) {
+	group := load.NewConcurrencyLimiterGroup(load.ConcurrencyConf{
+		InitialLimit: 2,
+		MinLimit:     1,
+		MaxLimit:     10,
+	})
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := ConcurrencyHandler(group, "/slow", http.MethodGet, load.PriorityCritical)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entered <- struct{}{}
+			<-release
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/slow", http.NoBody)
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			done <- resp.Code
+		}()
+		<-entered
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/slow", http.NoBody)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, <-done)
+}
+
+func TestConcurrencyHandlerFail(t *testing.T) {
+	group := load.NewConcurrencyLimiterGroup(load.ConcurrencyConf{
+		InitialLimit: 10,
+		MinLimit:     1,
+		MaxLimit:     10,
+	})
+	handler := ConcurrencyHandler(group, "/", http.MethodGet, load.PriorityNormal)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	// the failed requests back off the limit.
+	assert.Equal(t, 9, group.GetLimiter("GET /").Limit())
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/zeromicro/go-zero/core/limit"
+	"github.com/zeromicro/go-zero/core/logc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+const (
+	defaultUserClaim    = "userId"
+	defaultClientHeader = "X-Client-Id"
+	retryAfterHeader    = "Retry-After"
+	xForwardedFor       = "X-Forwarded-For"
+)
+
+// RateLimitKeyFunc returns the key to limit r by, the request is not limited if it's empty.
+// The key funcs of RateLimitKey return limit.AnonymousKey if r has no ip, user or client.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitHandler returns a middleware that limits the requests by the keys from keyFunc.
+// The requests are rejected with 429 and the Retry-After header if limited,
+// and served if the limiter fails.
+func RateLimitHandler(limiter limit.RateLimiter, keyFunc RateLimitKeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if len(key) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := limiter.AllowCtx(r.Context(), key)
+			if err != nil {
+				logc.Errorf(r.Context(), "[http] rate limiter error: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !result.Allowed {
+				logc.Errorf(r.Context(), "[http] rate limited, key: %s, %s - %s - %s",
+					key, r.RequestURI, httpx.GetRemoteAddr(r), r.UserAgent())
+				w.Header().Set(retryAfterHeader, strconv.Itoa(retryAfterSeconds(result)))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimitKey returns the RateLimitKeyFunc of the rule c on the route path.
+func RateLimitKey(c limit.RateLimitConf, path string) (RateLimitKeyFunc, error) {
+	switch c.Key {
+	case limit.IpKey, "":
+		proxies, err := parseTrustedProxies(c.TrustedProxies)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *http.Request) string {
+			return orAnonymous(clientIp(r, proxies))
+		}, nil
+	case limit.UserKey:
+		claim := c.KeyName
+		if len(claim) == 0 {
+			claim = defaultUserClaim
+		}
+		return func(r *http.Request) string {
+			val := r.Context().Value(claim)
+			if val == nil {
+				return limit.AnonymousKey
+			}
+			return orAnonymous(fmt.Sprint(val))
+		}, nil
+	case limit.ClientKey:
+		name := c.KeyName
+		if len(name) == 0 {
+			name = defaultClientHeader
+		}
+		return func(r *http.Request) string {
+			return orAnonymous(r.Header.Get(name))
+		}, nil
+	case limit.RouteKey:
+		return func(r *http.Request) string {
+			return path
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit key: %s", c.Key)
+	}
+}
+
+// clientIp returns the ip of the remote address. If the remote address is a trusted proxy,
+// it returns the last ip of X-Forwarded-For that is not a trusted proxy, because the ips
+// before it may be set by the client.
+func clientIp(r *http.Request, proxies []netip.Prefix) string {
+	ip := remoteIp(r)
+	if !isTrustedProxy(ip, proxies) {
+		return ip
+	}
+
+	hops := strings.Split(r.Header.Get(xForwardedFor), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if len(hop) == 0 {
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(ip, proxies) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func isTrustedProxy(ip string, proxies []netip.Prefix) bool {
+	if len(proxies) == 0 {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, proxy := range proxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func orAnonymous(key string) string {
+	if len(key) == 0 {
+		return limit.AnonymousKey
+	}
+
+	return key
+}
+
+// parseTrustedProxies parses the ips or CIDRs of the trusted proxies.
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy: %s", proxy)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %s", proxy)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+func remoteIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// retryAfterSeconds rounds the retry after up to seconds, at least 1.
+func retryAfterSeconds(result limit.Result) int {
+	return max(1, int(math.Ceil(result.RetryAfter.Seconds())))
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/limit"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	limiter := limit.NewLocalSlidingWindowLimit(time.Minute, 2)
+	keyFunc, err := RateLimitKey(limit.RateLimitConf{Key: limit.IpKey}, "/")
+	assert.NoError(t, err)
+	handler := RateLimitHandler(limiter, keyFunc)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+		req.RemoteAddr = remoteAddr
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	assert.Equal(t, http.StatusOK, serve("1.2.3.4:1000").Code)
+	assert.Equal(t, http.StatusOK, serve("1.2.3.4:1001").Code)
+	resp := serve("1.2.3.4:1002")
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.NotEmpty(t, resp.Header().Get(retryAfterHeader))
+	assert.Equal(t, http.StatusOK, serve("5.6.7.8:1000").Code)
+}
+
+func TestRateLimitHandlerAnonymous(t *testing.T) {
+	limiter := limit.NewLocalSlidingWindowLimit(time.Minute, 1)
+	keyFunc, err := RateLimitKey(limit.RateLimitConf{Key: limit.UserKey}, "/")
+	assert.NoError(t, err)
+	handler := RateLimitHandler(limiter, keyFunc)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	// the requests without a user share one limit.
+	for _, code := range []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, code, resp.Code)
+	}
+}
+
+func TestRateLimitHandlerSkipEmptyKey(t *testing.T) {
+	limiter := limit.NewLocalSlidingWindowLimit(time.Minute, 1)
+	handler := RateLimitHandler(limiter, func(r *http.Request) string {
+		return ""
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	}
+}
+
+func TestRateLimitHandlerError(t *testing.T) {
+	handler := RateLimitHandler(mockRateLimiter{err: errors.New("any")}, remoteIp)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+func TestRateLimitHandlerRetryAfter(t *testing.T) {
+	handler := RateLimitHandler(mockRateLimiter{
+		result: limit.Result{RetryAfter: 1500 * time.Millisecond},
+	}, remoteIp)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Equal(t, "2", resp.Header().Get(retryAfterHeader))
+}
+
+func TestRateLimitKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/users", http.NoBody)
+	req.RemoteAddr = "1.2.3.4:1000"
+	req.Header.Set(defaultClientHeader, "app1")
+	req.Header.Set("X-App", "app2")
+	req = req.WithContext(context.WithValue(req.Context(), "uid", "u2"))
+	req = req.WithContext(context.WithValue(req.Context(), defaultUserClaim, 1))
+
+	tests := []struct {
+		name string
+		conf limit.RateLimitConf
+		want string
+	}{
+		{name: "ip", conf: limit.RateLimitConf{Key: limit.IpKey}, want: "1.2.3.4"},
+		{name: "user", conf: limit.RateLimitConf{Key: limit.UserKey}, want: "1"},
+		{name: "user claim", conf: limit.RateLimitConf{Key: limit.UserKey, KeyName: "uid"}, want: "u2"},
+		{name: "client", conf: limit.RateLimitConf{Key: limit.ClientKey}, want: "app1"},
+		{name: "client header", conf: limit.RateLimitConf{Key: limit.ClientKey, KeyName: "X-App"}, want: "app2"},
+		{name: "route", conf: limit.RateLimitConf{Key: limit.RouteKey}, want: "/users/:id"},
+		{name: "no user", conf: limit.RateLimitConf{Key: limit.UserKey, KeyName: "none"}, want: limit.AnonymousKey},
+		{name: "no client", conf: limit.RateLimitConf{Key: limit.ClientKey, KeyName: "X-None"}, want: limit.AnonymousKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyFunc, err := RateLimitKey(test.conf, "/users/:id")
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, keyFunc(req))
+		})
+	}
+
+	_, err := RateLimitKey(limit.RateLimitConf{Key: "bad"}, "/")
+	assert.Error(t, err)
+	_, err = RateLimitKey(limit.RateLimitConf{Key: limit.IpKey, TrustedProxies: []string{"10.0.0.0/33"}}, "/")
+	assert.EqualError(t, err, "invalid trusted proxy: 10.0.0.0/33")
+	_, err = RateLimitKey(limit.RateLimitConf{Key: limit.IpKey, TrustedProxies: []string{"bad"}}, "/")
+	assert.EqualError(t, err, "invalid trusted proxy: bad")
+}
+
+func TestClientIp(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "::ffff:192.168.0.1"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		proxies    []netip.Prefix
+		want       string
+	}{
+		{name: "remote", remoteAddr: "1.2.3.4:1000", want: "1.2.3.4"},
+		{name: "bad remote", remoteAddr: "bad", want: "bad"},
+		{name: "no trusted proxies", remoteAddr: "10.0.0.1:1000", forwarded: "5.6.7.8", want: "10.0.0.1"},
+		{
+			name:       "untrusted remote",
+			remoteAddr: "1.2.3.4:1000",
+			forwarded:  "5.6.7.8",
+			proxies:    proxies,
+			want:       "1.2.3.4",
+		},
+		{
+			name:       "trusted remote",
+			remoteAddr: "10.0.0.1:1000",
+			forwarded:  "5.6.7.8",
+			proxies:    proxies,
+			want:       "5.6.7.8",
+		},
+		{
+			// the hops before the first untrusted one from the right may be forged.
+			name:       "forged hops",
+			remoteAddr: "10.0.0.1:1000",
+			forwarded:  "9.9.9.9, 5.6.7.8, 192.168.0.1 ,10.0.0.2",
+			proxies:    proxies,
+			want:       "5.6.7.8",
+		},
+		{
+			name:       "all trusted",
+			remoteAddr: "192.168.0.1:1000",
+			forwarded:  "10.0.0.3, 10.0.0.2",
+			proxies:    proxies,
+			want:       "10.0.0.3",
+		},
+		{name: "trusted remote without hops", remoteAddr: "10.0.0.1:1000", proxies: proxies, want: "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+			req.RemoteAddr = test.remoteAddr
+			if len(test.forwarded) > 0 {
+				req.Header.Set(xForwardedFor, test.forwarded)
+			}
+			assert.Equal(t, test.want, clientIp(req, test.proxies))
+		})
+	}
+}
+
+type mockRateLimiter struct {
+	result limit.Result
+	err    error
+}
+
+func (m mockRateLimiter) AllowCtx(_ context.Context, _ string) (limit.Result, error) {
+	return m.result, m.err
+}
@@ -7,6 +7,7 @@ import (
 	"path"
 	"time"
 
+	"github.com/zeromicro/go-zero/core/load"
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/rest/chain"
 	"github.com/zeromicro/go-zero/rest/handler"
@@ -136,6 +137,15 @@ func ToMiddleware(handler func(next http.Handler) http.Handler) Middleware {
 	}
 }
 
+// WithAdaptiveConcurrency returns a RouteOption that limits the concurrency of each route adaptively
+// by the latency, the routes with lower priority are shed first when the service is overloaded.
+func WithAdaptiveConcurrency(priority load.Priority) RouteOption {
+	return func(r *featuredRoutes) {
+		r.concurrency.enabled = true
+		r.concurrency.priority = priority
+	}
+}
+
 // WithChain returns a RunOption that uses the given chain to replace the default chain.
 // JWT auth middleware and the middlewares that added by svr.Use() will be appended.
 func WithChain(chn chain.Chain) RunOption {
@@ -16,6 +16,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/core/load"
 	"github.com/zeromicro/go-zero/core/logx/logtest"
 	"github.com/zeromicro/go-zero/rest/chain"
 	"github.com/zeromicro/go-zero/rest/httpx"
@@ -336,6 +337,13 @@ func TestWithPrefix(t *testing.T) {
 	assert.EqualValues(t, []string{"/api/hello", "/api/world"}, vals)
 }
 
+func TestWithAdaptiveConcurrency(t *testing.T) {
+	var fr featuredRoutes
+	WithAdaptiveConcurrency(load.PriorityCritical)(&fr)
+	assert.True(t, fr.concurrency.enabled)
+	assert.Equal(t, load.PriorityCritical, fr.concurrency.priority)
+}
+
 func TestWithPriority(t *testing.T) {
 	var fr featuredRoutes
 	WithPriority()(&fr)
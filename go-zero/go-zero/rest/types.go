@@ -3,6 +3,8 @@ package rest
 import (
 	"net/http"
 	"time"
+
+	"github.com/zeromicro/go-zero/core/load"
 )
 
 type (
@@ -30,13 +32,19 @@ type (
 		enabled bool
 	}
 
+	concurrencySetting struct {
+		enabled  bool
+		priority load.Priority
+	}
+
 	featuredRoutes struct {
-		timeout   *time.Duration
-		priority  bool
-		jwt       jwtSetting
-		signature signatureSetting
-		sse       bool
-		routes    []Route
-		maxBytes  int64
+		timeout     *time.Duration
+		priority    bool
+		jwt         jwtSetting
+		signature   signatureSetting
+		concurrency concurrencySetting
+		sse         bool
+		routes      []Route
+		maxBytes    int64
 	}
 )
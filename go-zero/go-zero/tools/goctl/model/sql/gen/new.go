@@ -26,6 +26,7 @@ func genNew(table Table, withCache, postgreSql bool) (string, error) {
 		Execute(map[string]any{
 			"table":                 t,
 			"withCache":             withCache,
+			"postgreSql":            postgreSql,
 			"lowerStartCamelObject": stringx.From(camel).Untitle(),
 			"upperStartCamelObject": camel,
 			"data":                  table,
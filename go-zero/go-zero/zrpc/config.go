@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/zeromicro/go-zero/core/discov"
+	"github.com/zeromicro/go-zero/core/limit"
+	"github.com/zeromicro/go-zero/core/load"
 	"github.com/zeromicro/go-zero/core/service"
 	"github.com/zeromicro/go-zero/core/stores/redis"
 	"github.com/zeromicro/go-zero/zrpc/internal"
@@ -19,6 +21,8 @@ type (
 	StatConf = internal.StatConf
 	// MethodTimeoutConf defines specified timeout for gRPC method.
 	MethodTimeoutConf = internal.MethodTimeoutConf
+	// MethodPriorityConf defines the priority of gRPC method for the adaptive concurrency limits.
+	MethodPriorityConf = internal.MethodPriorityConf
 
 	// A RpcClientConf is a rpc client config.
 	RpcClientConf struct {
@@ -50,6 +54,13 @@ type (
 		Middlewares ServerMiddlewaresConf
 		// setting specified timeout for gRPC method
 		MethodTimeouts []MethodTimeoutConf `json:",optional"`
+		// RateLimits are the rate limit rules, applied after the auth.
+		RateLimits []limit.RateLimitConf `json:",optional"`
+		// AdaptiveConcurrency limits the concurrency of each unary method by the latency if enabled.
+		// The streams are not limited, their durations don't tell the load like the latencies.
+		AdaptiveConcurrency load.ConcurrencyConf `json:",optional"`
+		// MethodPriorities are the priorities of the methods for AdaptiveConcurrency, normal by default.
+		MethodPriorities []MethodPriorityConf `json:",optional"`
 	}
 )
 
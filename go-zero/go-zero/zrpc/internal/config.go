@@ -27,4 +27,7 @@ type (
 
 	// MethodTimeoutConf defines specified timeout for gRPC methods.
 	MethodTimeoutConf = serverinterceptors.MethodTimeoutConf
+
+	// MethodPriorityConf defines the priority of gRPC methods for the adaptive concurrency limits.
+	MethodPriorityConf = serverinterceptors.MethodPriorityConf
 )
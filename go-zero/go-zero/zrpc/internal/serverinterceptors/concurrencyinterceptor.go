@@ -0,0 +1,86 @@
+package serverinterceptors
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zeromicro/go-zero/core/load"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	metricServerConcurrencyLimit = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "concurrency",
+		Name:      "limit",
+		Help:      "rpc server adaptive concurrency limit of methods.",
+		Labels:    []string{"method"},
+	})
+
+	metricServerConcurrencySharedLimit = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "concurrency",
+		Name:      "shared_limit",
+		Help:      "rpc server concurrency limit of all methods.",
+	})
+
+	metricServerConcurrencyRejected = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "concurrency",
+		Name:      "rejected_total",
+		Help:      "rpc server requests rejected by the adaptive concurrency limit.",
+		Labels:    []string{"method", "priority"},
+	})
+)
+
+// MethodPriorityConf defines the priority of gRPC method for the adaptive concurrency limits.
+type MethodPriorityConf struct {
+	FullMethod string
+	Priority   load.Priority `json:",default=normal,options=[low,normal,critical]"`
+}
+
+// UnaryConcurrencyInterceptor returns a func that limits the concurrent unary requests of each method
+// adaptively, the methods with lower priority are rejected first when overloaded.
+func UnaryConcurrencyInterceptor(group *load.ConcurrencyLimiterGroup,
+	methodPriorities ...MethodPriorityConf) grpc.UnaryServerInterceptor {
+	priorities := make(map[string]load.Priority, len(methodPriorities))
+	for _, mp := range methodPriorities {
+		priorities[mp.FullMethod] = mp.Priority
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (val any, err error) {
+		priority, ok := priorities[info.FullMethod]
+		if !ok {
+			priority = load.PriorityNormal
+		}
+
+		promise, err := group.Allow(info.FullMethod, priority)
+		if err != nil {
+			metricServerConcurrencyRejected.Inc(info.FullMethod, string(priority))
+			reportConcurrency(group, info.FullMethod)
+			logx.WithContext(ctx).Errorf("concurrency limited, method: %s", info.FullMethod)
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		defer func() {
+			if errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded {
+				promise.Fail()
+			} else {
+				promise.Pass()
+			}
+			reportConcurrency(group, info.FullMethod)
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+func reportConcurrency(group *load.ConcurrencyLimiterGroup, method string) {
+	metricServerConcurrencyLimit.Set(float64(group.GetLimiter(method).Limit()), method)
+	metricServerConcurrencySharedLimit.Set(float64(group.SharedLimit()))
+}
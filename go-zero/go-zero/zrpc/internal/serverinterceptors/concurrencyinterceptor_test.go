@@ -0,0 +1,81 @@
+package serverinterceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/load"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryConcurrencyInterceptor(t *testing.T) {
+	group := load.NewConcurrencyLimiterGroup(load.ConcurrencyConf{
+		InitialLimit: 10,
+		MinLimit:     1,
+		MaxLimit:     10,
+	})
+	interceptor := UnaryConcurrencyInterceptor(group, MethodPriorityConf{
+		FullMethod: "/critical",
+		Priority:   load.PriorityCritical,
+	})
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		go func() {
+			_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{
+				FullMethod: "/normal",
+			}, func(ctx context.Context, req any) (any, error) {
+				entered <- struct{}{}
+				<-release
+				return nil, nil
+			})
+			done <- err
+		}()
+		<-entered
+	}
+
+	// normal priority can use 80% of the shared limit.
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{
+		FullMethod: "/other",
+	}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// critical methods are shed last.
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{
+		FullMethod: "/critical",
+	}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	close(release)
+	for i := 0; i < 8; i++ {
+		assert.NoError(t, <-done)
+	}
+}
+
+func TestUnaryConcurrencyInterceptor_DeadlineExceeded(t *testing.T) {
+	group := load.NewConcurrencyLimiterGroup(load.ConcurrencyConf{
+		InitialLimit: 10,
+		MinLimit:     1,
+		MaxLimit:     10,
+	})
+	interceptor := UnaryConcurrencyInterceptor(group)
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{
+		FullMethod: "/foo",
+	}, func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.DeadlineExceeded, "timeout")
+	})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	// the timeouts back off the limit.
+	assert.Equal(t, 9, group.GetLimiter("/foo").Limit())
+	assert.Equal(t, 10, group.SharedLimit())
+}
@@ -0,0 +1,162 @@
+package serverinterceptors
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+
+	"github.com/zeromicro/go-zero/core/limit"
+	"github.com/zeromicro/go-zero/core/logx"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	defaultUserKey   = "userid"
+	defaultClientKey = "app"
+	retryAfterKey    = "retry-after"
+)
+
+// RateLimitKeyFunc returns the key to limit the call of fullMethod by,
+// the call is not limited if it's empty.
+// The key funcs of RateLimitKey return limit.AnonymousKey if the call has no ip, user or client.
+type RateLimitKeyFunc func(ctx context.Context, fullMethod string) string
+
+// UnaryRateLimitInterceptor returns a func that limits the unary requests by the keys from keyFunc.
+func UnaryRateLimitInterceptor(limiter limit.RateLimiter, keyFunc RateLimitKeyFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		if err := allowRateLimit(ctx, limiter, keyFunc(ctx, info.FullMethod), func(md metadata.MD) {
+			_ = grpc.SetHeader(ctx, md)
+		}); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamRateLimitInterceptor returns a func that limits the stream requests by the keys from keyFunc.
+func StreamRateLimitInterceptor(limiter limit.RateLimiter, keyFunc RateLimitKeyFunc) grpc.StreamServerInterceptor {
+	return func(svr any, stream grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx := stream.Context()
+		if err := allowRateLimit(ctx, limiter, keyFunc(ctx, info.FullMethod), func(md metadata.MD) {
+			_ = stream.SetHeader(md)
+		}); err != nil {
+			return err
+		}
+
+		return handler(svr, stream)
+	}
+}
+
+// RateLimitKey returns the RateLimitKeyFunc of the rule c, the methods not matched are not limited.
+func RateLimitKey(c limit.RateLimitConf) (RateLimitKeyFunc, error) {
+	var keyFunc RateLimitKeyFunc
+	switch c.Key {
+	case limit.IpKey, "":
+		keyFunc = func(ctx context.Context, _ string) string {
+			return orAnonymous(peerIp(ctx))
+		}
+	case limit.UserKey:
+		keyFunc = metadataKey(c.KeyName, defaultUserKey)
+	case limit.ClientKey:
+		keyFunc = metadataKey(c.KeyName, defaultClientKey)
+	case limit.RouteKey:
+		keyFunc = func(_ context.Context, fullMethod string) string {
+			return fullMethod
+		}
+	default:
+		return nil, fmt.Errorf("unknown rate limit key: %s", c.Key)
+	}
+
+	return func(ctx context.Context, fullMethod string) string {
+		if !c.Match(fullMethod) {
+			return ""
+		}
+
+		return keyFunc(ctx, fullMethod)
+	}, nil
+}
+
+func allowRateLimit(ctx context.Context, limiter limit.RateLimiter, key string,
+	setHeader func(md metadata.MD)) error {
+	if len(key) == 0 {
+		return nil
+	}
+
+	result, err := limiter.AllowCtx(ctx, key)
+	if err != nil {
+		logx.WithContext(ctx).Errorf("rate limiter error: %v", err)
+		return nil
+	}
+	if result.Allowed {
+		return nil
+	}
+
+	seconds := max(1, int(math.Ceil(result.RetryAfter.Seconds())))
+	setHeader(metadata.Pairs(retryAfterKey, strconv.Itoa(seconds)))
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(result.RetryAfter),
+	})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	return st.Err()
+}
+
+// metadataKey returns the value of the incoming metadata name as the key.
+// The metadata is set by the caller and not verified here, a caller can send
+// a different value on each call to get a new quota, so only use the user or client keys
+// for trusted callers, like the ones behind a gateway, or with zrpc auth enabled for the app key.
+func metadataKey(name, defaultName string) RateLimitKeyFunc {
+	if len(name) == 0 {
+		name = defaultName
+	}
+
+	return func(ctx context.Context, _ string) string {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return limit.AnonymousKey
+		}
+
+		vals := md.Get(name)
+		if len(vals) == 0 {
+			return limit.AnonymousKey
+		}
+
+		return orAnonymous(vals[0])
+	}
+}
+
+func orAnonymous(key string) string {
+	if len(key) == 0 {
+		return limit.AnonymousKey
+	}
+
+	return key
+}
+
+func peerIp(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	addr := p.Addr.String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
@@ -0,0 +1,135 @@
+package serverinterceptors
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeromicro/go-zero/core/limit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryRateLimitInterceptor(t *testing.T) {
+	limiter := limit.NewLocalSlidingWindowLimit(time.Minute, 1)
+	keyFunc, err := RateLimitKey(limit.RateLimitConf{Key: limit.ClientKey})
+	assert.NoError(t, err)
+	interceptor := UnaryRateLimitInterceptor(limiter, keyFunc)
+
+	call := func(app string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("app", app))
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{
+			FullMethod: "/foo",
+		}, func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+		return err
+	}
+
+	assert.NoError(t, call("foo"))
+	err = call("foo")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	details := status.Convert(err).Details()
+	if assert.Len(t, details, 1) {
+		info, ok := details[0].(*errdetails.RetryInfo)
+		assert.True(t, ok)
+		assert.True(t, info.RetryDelay.AsDuration() > 0)
+	}
+	assert.NoError(t, call("bar"))
+}
+
+func TestUnaryRateLimitInterceptorError(t *testing.T) {
+	interceptor := UnaryRateLimitInterceptor(mockedRateLimiter{err: errors.New("any")},
+		func(_ context.Context, _ string) string {
+			return "foo"
+		})
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{
+		FullMethod: "/foo",
+	}, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestStreamRateLimitInterceptor(t *testing.T) {
+	limiter := limit.NewLocalGcraLimit(time.Minute, 1, 1)
+	keyFunc, err := RateLimitKey(limit.RateLimitConf{
+		Key:   limit.RouteKey,
+		Paths: []string{"/foo"},
+	})
+	assert.NoError(t, err)
+	interceptor := StreamRateLimitInterceptor(limiter, keyFunc)
+
+	call := func(method string) error {
+		return interceptor(nil, mockedStream{ctx: context.Background()}, &grpc.StreamServerInfo{
+			FullMethod: method,
+		}, func(_ any, _ grpc.ServerStream) error {
+			return nil
+		})
+	}
+
+	assert.NoError(t, call("/foo"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("/foo")))
+	// the methods not matched are not limited.
+	assert.NoError(t, call("/bar"))
+	assert.NoError(t, call("/bar"))
+}
+
+func TestRateLimitKey(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"app", "foo", "userid", "1", "uid", "2", "x-app", "bar"))
+	ctx = peer.NewContext(ctx, &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1000},
+	})
+
+	tests := []struct {
+		name string
+		conf limit.RateLimitConf
+		want string
+	}{
+		{name: "ip", conf: limit.RateLimitConf{Key: limit.IpKey}, want: "1.2.3.4"},
+		{name: "user", conf: limit.RateLimitConf{Key: limit.UserKey}, want: "1"},
+		{name: "user key", conf: limit.RateLimitConf{Key: limit.UserKey, KeyName: "uid"}, want: "2"},
+		{name: "client", conf: limit.RateLimitConf{Key: limit.ClientKey}, want: "foo"},
+		{name: "client key", conf: limit.RateLimitConf{Key: limit.ClientKey, KeyName: "x-app"}, want: "bar"},
+		{name: "route", conf: limit.RateLimitConf{Key: limit.RouteKey}, want: "/foo"},
+		{name: "not matched", conf: limit.RateLimitConf{Key: limit.IpKey, Paths: []string{"/bar"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyFunc, err := RateLimitKey(test.conf)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, keyFunc(ctx, "/foo"))
+		})
+	}
+
+	// the calls without an ip, user or client share one limit.
+	for _, key := range []string{limit.IpKey, limit.UserKey, limit.ClientKey} {
+		keyFunc, err := RateLimitKey(limit.RateLimitConf{Key: key})
+		assert.NoError(t, err)
+		assert.Equal(t, limit.AnonymousKey, keyFunc(context.Background(), "/foo"))
+	}
+	keyFunc, err := RateLimitKey(limit.RateLimitConf{Key: limit.ClientKey, KeyName: "none"})
+	assert.NoError(t, err)
+	assert.Equal(t, limit.AnonymousKey, keyFunc(ctx, "/foo"))
+
+	_, err = RateLimitKey(limit.RateLimitConf{Key: "bad"})
+	assert.Error(t, err)
+}
+
+type mockedRateLimiter struct {
+	result limit.Result
+	err    error
+}
+
+func (m mockedRateLimiter) AllowCtx(_ context.Context, _ string) (limit.Result, error) {
+	return m.result, m.err
+}
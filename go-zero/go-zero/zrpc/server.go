@@ -1,6 +1,7 @@
 package zrpc
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/zeromicro/go-zero/core/load"
@@ -55,6 +56,9 @@ func NewServer(c RpcServerConf, register internal.RegisterFn) (*RpcServer, error
 	if err = setupAuthInterceptors(server, c); err != nil {
 		return nil, err
 	}
+	if err = setupRateLimitInterceptors(server, c); err != nil {
+		return nil, err
+	}
 
 	rpcServer := &RpcServer{
 		server:   server,
@@ -129,6 +133,25 @@ func setupAuthInterceptors(svr internal.Server, c RpcServerConf) error {
 	return nil
 }
 
+func setupRateLimitInterceptors(svr internal.Server, c RpcServerConf) error {
+	for i, rule := range c.RateLimits {
+		limiter, err := rule.NewRateLimiter(fmt.Sprintf("ratelimit:%s:%d:", c.Name, i))
+		if err != nil {
+			return err
+		}
+
+		keyFunc, err := serverinterceptors.RateLimitKey(rule)
+		if err != nil {
+			return err
+		}
+
+		svr.AddStreamInterceptors(serverinterceptors.StreamRateLimitInterceptor(limiter, keyFunc))
+		svr.AddUnaryInterceptors(serverinterceptors.UnaryRateLimitInterceptor(limiter, keyFunc))
+	}
+
+	return nil
+}
+
 func setupStreamInterceptors(svr internal.Server, c RpcServerConf) {
 	if c.Middlewares.Trace {
 		svr.AddStreamInterceptors(serverinterceptors.StreamTracingInterceptor)
@@ -161,6 +184,11 @@ func setupUnaryInterceptors(svr internal.Server, c RpcServerConf, metrics *stat.
 		shedder := load.NewAdaptiveShedder(load.WithCpuThreshold(c.CpuThreshold))
 		svr.AddUnaryInterceptors(serverinterceptors.UnarySheddingInterceptor(shedder, metrics))
 	}
+	// the streams are not limited by the adaptive concurrency, see RpcServerConf.AdaptiveConcurrency.
+	if c.AdaptiveConcurrency.Enabled {
+		svr.AddUnaryInterceptors(serverinterceptors.UnaryConcurrencyInterceptor(
+			load.NewConcurrencyLimiterGroup(c.AdaptiveConcurrency), c.MethodPriorities...))
+	}
 	if c.Timeout > 0 {
 		svr.AddUnaryInterceptors(serverinterceptors.UnaryTimeoutInterceptor(
 			time.Duration(c.Timeout)*time.Millisecond, c.MethodTimeouts...))
@@ -8,6 +8,8 @@ import (
 	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/zeromicro/go-zero/core/discov"
+	"github.com/zeromicro/go-zero/core/limit"
+	"github.com/zeromicro/go-zero/core/load"
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/core/service"
 	"github.com/zeromicro/go-zero/core/stat"
@@ -213,6 +215,22 @@ func Test_setupUnaryInterceptors(t *testing.T) {
 			},
 			len: 7,
 		},
+		{
+			name: "adaptive concurrency",
+			r:    &mockedServer{},
+			conf: RpcServerConf{
+				AdaptiveConcurrency: load.ConcurrencyConf{
+					Enabled: true,
+				},
+				MethodPriorities: []MethodPriorityConf{
+					{
+						FullMethod: "/foo",
+						Priority:   load.PriorityCritical,
+					},
+				},
+			},
+			len: 1,
+		},
 	}
 
 	for _, test := range tests {
@@ -308,3 +326,45 @@ func Test_setupAuthInterceptors(t *testing.T) {
 		assert.Equal(t, 1, len(s.streamInterceptors))
 	})
 }
+
+func Test_setupRateLimitInterceptors(t *testing.T) {
+	t.Run("no rate limits", func(t *testing.T) {
+		s := &mockedServer{}
+		assert.NoError(t, setupRateLimitInterceptors(s, RpcServerConf{}))
+		assert.Equal(t, 0, len(s.unaryInterceptors))
+		assert.Equal(t, 0, len(s.streamInterceptors))
+	})
+
+	t.Run("bad quota", func(t *testing.T) {
+		s := &mockedServer{}
+		err := setupRateLimitInterceptors(s, RpcServerConf{
+			RateLimits: []limit.RateLimitConf{
+				{Key: limit.IpKey, Period: time.Second},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("bad key", func(t *testing.T) {
+		s := &mockedServer{}
+		err := setupRateLimitInterceptors(s, RpcServerConf{
+			RateLimits: []limit.RateLimitConf{
+				{Key: "bad", Quota: 1, Period: time.Second},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("works", func(t *testing.T) {
+		s := &mockedServer{}
+		err := setupRateLimitInterceptors(s, RpcServerConf{
+			RateLimits: []limit.RateLimitConf{
+				{Key: limit.IpKey, Quota: 10, Period: time.Second},
+				{Key: limit.ClientKey, Algorithm: limit.Gcra, Quota: 10, Period: time.Second},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(s.unaryInterceptors))
+		assert.Equal(t, 2, len(s.streamInterceptors))
+	})
+}
@@ -5,14 +5,10 @@ func (m *default{{.upperStartCamelObject}}Model) delete(ctx context.Context, {{.
 	}
 
 {{end}}	{{.keys}}
-    _, err {{if .containsIndexCache}}={{else}}:={{end}} m.ExecCtx(ctx, func(ctx context.Context, conn sqlx.SqlConn) (result sql.Result, err error) {
+    _, err {{if .containsIndexCache}}={{else}}:={{end}} m.CachedConn.ExecCtx(ctx, func(ctx context.Context, conn sqlx.SqlConn) (result sql.Result, err error) {
 		query := fmt.Sprintf("delete from %s where {{.originalPrimaryKey}} = {{if .postgreSql}}$1{{else}}?{{end}}", m.table)
-		//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-        //defer span.End()
 		return conn.ExecCtx(ctx, query, {{.lowerStartCamelPrimaryKey}})
 	}, {{.keyValues}}){{else}}query := fmt.Sprintf("delete from %s where {{.originalPrimaryKey}} = {{if .postgreSql}}$1{{else}}?{{end}}", m.table)
-		//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-        //defer span.End()
 		_,err:=m.conn.ExecCtx(ctx, query, {{.lowerStartCamelPrimaryKey}}){{end}}
 	return err
 }
@@ -52,17 +48,23 @@ func (m *default{{.upperStartCamelObject}}Model) DeleteByWhere(ctx context.Conte
         // 删除的条件不能为空，避免全表误删除了
         return fmt.Errorf("param delete where empty")
     }
-	query, deleteData, err := m.sqlBuilder.DeleteSql(whereCondition)
-	if err != nil {
-		return err
-	}
-	//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-	//defer span.End()
 
-	if len(session) > 0 && session[0] != nil {
-        _, err = session[0].ExecCtx(ctx, query, deleteData...)
-        return err
+    var oneSession sqlx.Session
+    if len(session) > 0 && session[0] != nil {
+        oneSession = session[0]
+    } else {
+        oneSession = m.conn
     }
-    _, err = m.conn.ExecCtx(ctx, query, deleteData...)
-    return err
+    return m.execWithCache(ctx, oneSession, whereCondition, func() error {
+        // 有 deleted_at 字段时只标记删除
+        if m.tableInfo.SoftDelete() {
+            return m.tableInfo.MarkDeleted(ctx, oneSession, sql, list...)
+        }
+        query, deleteData, err := m.sqlBuilder.DeleteSql(whereCondition)
+        if err != nil {
+            return err
+        }
+        _, err = oneSession.ExecCtx(ctx, query, deleteData...)
+        return err
+    })
 }
\ No newline at end of file
@@ -3,8 +3,6 @@ func (m *default{{.upperStartCamelObject}}Model) formatPrimary(primary any) stri
 }
 
 func (m *default{{.upperStartCamelObject}}Model) queryPrimary(ctx context.Context, conn sqlx.SqlConn, v, primary any) error {
-	query := fmt.Sprintf("select %s from %s where {{.originalPrimaryField}} = {{if .postgreSql}}$1{{else}}?{{end}} limit 1", {{.lowerStartCamelObject}}Rows, m.table )
-	//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-    //defer span.End()
+	query := fmt.Sprintf("select %s from %s where {{.originalPrimaryField}} = {{if .postgreSql}}$1{{else}}?{{end}}%s limit 1", {{.lowerStartCamelObject}}Rows, m.table, m.tableInfo.AndNotDeleted())
 	return conn.QueryRowCtx(ctx, v, query, primary)
 }
@@ -2,9 +2,7 @@ func (m *default{{.upperStartCamelObject}}Model) Find{{.upperStartCamelObject}}B
 	{{if .withCache}}{{.cacheKey}}
 	var resp {{.upperStartCamelObject}}
 	err := m.QueryRowIndexCtx(ctx, &resp, {{.cacheKeyVariable}}, m.formatPrimary, func(ctx context.Context, conn sqlx.SqlConn, v any) (i any, e error) {
-		query := fmt.Sprintf("select %s from %s where {{.originalField}} limit 1", {{.lowerStartCamelObject}}Rows, m.table)
-		//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-        //defer span.End()
+		query := fmt.Sprintf("select %s from %s where {{.originalField}}%s limit 1", {{.lowerStartCamelObject}}Rows, m.table, m.tableInfo.AndNotDeleted())
 		if err := conn.QueryRowCtx(ctx, &resp, query, {{.lowerStartCamelField}}); err != nil {
 			return nil, err
 		}
@@ -19,9 +17,7 @@ func (m *default{{.upperStartCamelObject}}Model) Find{{.upperStartCamelObject}}B
 		return nil, err
 	}
 }{{else}}var resp {{.upperStartCamelObject}}
-	query := fmt.Sprintf("select %s from %s where {{.originalField}} limit 1", {{.lowerStartCamelObject}}Rows, m.table )
-	//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-    //defer span.End()
+	query := fmt.Sprintf("select %s from %s where {{.originalField}}%s limit 1", {{.lowerStartCamelObject}}Rows, m.table, m.tableInfo.AndNotDeleted() )
     var oneSession sqlx.Session
     if len(session) > 0 && session[0] != nil {
         oneSession = session[0]
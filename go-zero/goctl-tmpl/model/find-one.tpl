@@ -1,10 +1,8 @@
 func (m *default{{.upperStartCamelObject}}Model) FindOne(ctx context.Context, {{.lowerStartCamelPrimaryKey}} {{.dataType}}, session ...sqlx.Session) (*{{.upperStartCamelObject}}, error) {
 	{{if .withCache}}{{.cacheKey}}
 	var resp {{.upperStartCamelObject}}
-	err := m.QueryRowCtx(ctx, &resp, {{.cacheKeyVariable}}, func(ctx context.Context, conn sqlx.SqlConn, v any) error {
-		query :=  fmt.Sprintf("select %s from %s where {{.originalPrimaryKey}} = {{if .postgreSql}}$1{{else}}?{{end}} limit 1", {{.lowerStartCamelObject}}Rows, m.table)
-		//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-        //defer span.End()
+	err := m.CachedConn.QueryRowCtx(ctx, &resp, {{.cacheKeyVariable}}, func(ctx context.Context, conn sqlx.SqlConn, v any) error {
+		query :=  fmt.Sprintf("select %s from %s where {{.originalPrimaryKey}} = {{if .postgreSql}}$1{{else}}?{{end}}%s limit 1", {{.lowerStartCamelObject}}Rows, m.table, m.tableInfo.AndNotDeleted())
 		return conn.QueryRowCtx(ctx, v, query, {{.lowerStartCamelPrimaryKey}})
 	})
 	switch err {
@@ -14,10 +12,8 @@ func (m *default{{.upperStartCamelObject}}Model) FindOne(ctx context.Context, {{
     	return nil, nil
 	default:
 		return nil, err
-	}{{else}}query := fmt.Sprintf("select %s from %s where {{.originalPrimaryKey}} = {{if .postgreSql}}$1{{else}}?{{end}} limit 1", {{.lowerStartCamelObject}}Rows, m.table)
+	}{{else}}query := fmt.Sprintf("select %s from %s where {{.originalPrimaryKey}} = {{if .postgreSql}}$1{{else}}?{{end}}%s limit 1", {{.lowerStartCamelObject}}Rows, m.table, m.tableInfo.AndNotDeleted())
 	var resp {{.upperStartCamelObject}}
-	//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-    //defer span.End()
     var oneSession sqlx.Session
     if len(session) > 0 && session[0] != nil {
         oneSession = session[0]
@@ -36,13 +32,11 @@ func (m *default{{.upperStartCamelObject}}Model) FindOne(ctx context.Context, {{
 }
 
 func (m *default{{.upperStartCamelObject}}Model) Find{{.upperStartCamelObject}}(ctx context.Context, whereCond sqlstatement.LogicCondition, session ...sqlx.Session) (*{{.upperStartCamelObject}}, error) {
-	query, data, err := m.sqlBuilder.SelectSql("*", whereCond, 0, 1)
+	query, data, err := m.sqlBuilder.SelectSql("*", m.tableInfo.WhereNotDeleted(whereCond), 0, 1)
 	if err != nil {
         return nil, err
     }
 	resp := new({{.upperStartCamelObject}})
-	//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-    //defer span.End()
     var oneSession sqlx.Session
     if len(session) > 0 && session[0] != nil {
         oneSession = session[0]
@@ -86,14 +80,16 @@ func (m *default{{.upperStartCamelObject}}Model) List{{.upperStartCamelObject}}B
     var data []any
     var err error
     var useCount bool
+    // 有 deleted_at 字段时排除已软删除的记录
+    selectCond := m.tableInfo.WhereNotDeleted(whereCond)
 
     if orderBy == "" {
-        query, data, err = m.sqlBuilder.SelectSql("*", whereCond, pageModel.PageOffset, pageModel.PageSize)
+        query, data, err = m.sqlBuilder.SelectSql("*", selectCond, pageModel.PageOffset, pageModel.PageSize)
         if err != nil {
             return nil, 0, err
         }
     }else{
-        query, data, err = m.sqlBuilder.SelectSql("*", whereCond, 0, 0)
+        query, data, err = m.sqlBuilder.SelectSql("*", selectCond, 0, 0)
         if err != nil {
             return nil, 0, err
         }
@@ -107,8 +103,6 @@ func (m *default{{.upperStartCamelObject}}Model) List{{.upperStartCamelObject}}B
     }
 
 	list := make([]*{{.upperStartCamelObject}}, 0)
-	//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-    //defer span.End()
 
     var oneSession sqlx.Session
     if len(session) > 0 && session[0] != nil {
@@ -135,14 +129,12 @@ func (m *default{{.upperStartCamelObject}}Model) List{{.upperStartCamelObject}}B
 
 
 func (m *default{{.upperStartCamelObject}}Model) Count{{.upperStartCamelObject}}(ctx context.Context, whereCond sqlstatement.LogicCondition, session ...sqlx.Session) (int64, error) {
-	countSql, countData, err := m.sqlBuilder.SelectSql("COUNT(*)", whereCond, 0, 0)
+	countSql, countData, err := m.sqlBuilder.SelectSql("COUNT(*)", m.tableInfo.WhereNotDeleted(whereCond), 0, 0)
     if err != nil {
         return 0, err
     }
     var total int64
 
-    //ctx, span := tracer.StartSpan(ctx, "SQL", countSql)
-    //defer span.End()
     var oneSession sqlx.Session
     if len(session) > 0 && session[0] != nil {
         oneSession = session[0]
@@ -154,4 +146,15 @@ func (m *default{{.upperStartCamelObject}}Model) Count{{.upperStartCamelObject}}
         return 0, err
     }
     return total, nil
-}
\ No newline at end of file
+}
+
+func (m *default{{.upperStartCamelObject}}Model) FindPage(ctx context.Context, filter *modelx.Filter, page modelx.PageRequest, session ...sqlx.Session) (*modelx.Page[{{.upperStartCamelObject}}], error) {
+    var oneSession sqlx.Session
+    if len(session) > 0 && session[0] != nil {
+        oneSession = session[0]
+    }else{
+        oneSession = m.conn
+    }
+    // page.Cursor 不为空时按 keyset 分页，否则按 offset 分页
+    return modelx.FindPage[{{.upperStartCamelObject}}](ctx, oneSession, m.tableInfo, filter, page)
+}
@@ -6,7 +6,8 @@ import (
 	"github.com/magic-lib/go-plat-utils/utils/httputil"
 	"sync"
 
-	//"github.com/magic-lib/go-plat-trace/tracer"
+	"github.com/magic-lib/go-servicekit/go-zero/zutils/modelx"
+	"github.com/magic-lib/go-servicekit/tracer"
 	"strings"
 	{{if .time}}"time"{{end}}
 
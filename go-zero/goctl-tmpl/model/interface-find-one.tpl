@@ -2,4 +2,5 @@ FindOne(ctx context.Context, {{.lowerStartCamelPrimaryKey}} {{.dataType}}, sessi
 Find{{.upperStartCamelObject}}(ctx context.Context, whereCond sqlstatement.LogicCondition, session ...sqlx.Session) (*{{.upperStartCamelObject}}, error)
 List{{.upperStartCamelObject}}(ctx context.Context, whereCond sqlstatement.LogicCondition, session ...sqlx.Session) ([]*{{.upperStartCamelObject}}, error)
 List{{.upperStartCamelObject}}ByPage(ctx context.Context, whereCond sqlstatement.LogicCondition, pageModel *httputil.PageModel, maxLimit int, orderBy string, session ...sqlx.Session) ([]*{{.upperStartCamelObject}}, int64, error)
-Count{{.upperStartCamelObject}}(ctx context.Context, whereCond sqlstatement.LogicCondition, session ...sqlx.Session) (int64, error)
\ No newline at end of file
+Count{{.upperStartCamelObject}}(ctx context.Context, whereCond sqlstatement.LogicCondition, session ...sqlx.Session) (int64, error)
+FindPage(ctx context.Context, filter *modelx.Filter, page modelx.PageRequest, session ...sqlx.Session) (*modelx.Page[{{.upperStartCamelObject}}], error)
\ No newline at end of file
@@ -1,3 +1,4 @@
 Update(ctx context.Context, data *{{.upperStartCamelObject}}, session ...sqlx.Session) error
 UpdatePartial(ctx context.Context, data *{{.upperStartCamelObject}}, columns []string, whereCondition sqlstatement.LogicCondition, session ...sqlx.Session) error
-UpdatePartialByFunc(ctx context.Context, {{.lowerStartCamelPrimaryKey}} {{.dataType}}, updateFunc func(data *{{.upperStartCamelObject}}) error, session ...sqlx.Session) error
\ No newline at end of file
+UpdatePartialByFunc(ctx context.Context, {{.lowerStartCamelPrimaryKey}} {{.dataType}}, updateFunc func(data *{{.upperStartCamelObject}}) error, session ...sqlx.Session) error
+UpdateWithVersion(ctx context.Context, data *{{.upperStartCamelObject}}, session ...sqlx.Session) error
\ No newline at end of file
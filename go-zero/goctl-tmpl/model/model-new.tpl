@@ -5,6 +5,8 @@ func new{{.upperStartCamelObject}}Model(conn sqlx.SqlConn{{if .withCache}}, c ca
             return dataModel
         }
     }
+	// 每条语句的span由运行时的 tracer 配置控制，耗时指标和慢查询日志由 sqlx 记录
+	tracedConn := tracer.TraceModelConn(conn, {{if .postgreSql}}"postgresql"{{else}}"mysql"{{end}}, {{.table}})
 	sqlBuilder := sqlstatement.NewSqlStruct(
         sqlstatement.SetColumnTagName("db"),
         sqlstatement.SetStructData({{.upperStartCamelObject}}{}),
@@ -12,9 +14,12 @@ func new{{.upperStartCamelObject}}Model(conn sqlx.SqlConn{{if .withCache}}, c ca
         sqlstatement.SetTableName({{.table}}),
     )
     default{{.upperStartCamelObject}} := &default{{.upperStartCamelObject}}Model{
-        {{if .withCache}}CachedConn: sqlc.NewConn(conn, c, opts...){{else}}conn:conn{{end}},
+        {{if .withCache}}CachedConn: sqlc.NewConn(tracedConn, c, opts...),
+        {{end}}conn:       tracedConn,
         sqlBuilder: sqlBuilder,
         table:      {{.table}},
+        // 有 deleted_at 字段时使用软删除，有 version 字段时可以使用乐观锁
+        tableInfo:  modelx.NewTable({{.table}}, {{.lowerStartCamelObject}}FieldNames, {{.lowerStartCamelObject}}PrimaryKey, modelx.WithIgnoreColumns({{.lowerStartCamelObject}}IgnoreColumns...)),
     }
     {{.lowerStartCamelObject}}ModelCache.Store(conn, default{{.upperStartCamelObject}})
 	return default{{.upperStartCamelObject}}
@@ -4,11 +4,14 @@ type (
 	}
 
 	default{{.upperStartCamelObject}}Model struct {
-		{{if .withCache}}sqlc.CachedConn{{else}}conn sqlx.SqlConn{{end}}
+		{{if .withCache}}sqlc.CachedConn
+		{{end}}conn sqlx.SqlConn
 		gormDB *gorm.DB
+		gormLocker sync.Mutex
 		sqlBuilder *sqlstatement.SqlStruct
 		insertLocker sync.Mutex
 		table string
+		tableInfo *modelx.Table
 	}
 
 	{{.upperStartCamelObject}} struct {
@@ -5,14 +5,10 @@ func (m *default{{.upperStartCamelObject}}Model) update(ctx context.Context, {{i
 	}
 
 {{end}}	{{.keys}}
-    _, {{if .containsIndexCache}}err{{else}}err:{{end}}= m.ExecCtx(ctx, func(ctx context.Context, conn sqlx.SqlConn) (result sql.Result, err error) {
+    _, {{if .containsIndexCache}}err{{else}}err:{{end}}= m.CachedConn.ExecCtx(ctx, func(ctx context.Context, conn sqlx.SqlConn) (result sql.Result, err error) {
 		query := fmt.Sprintf("update %s set %s where {{.originalPrimaryKey}} = {{if .postgreSql}}$1{{else}}?{{end}}", m.table, {{.lowerStartCamelObject}}RowsWithPlaceHolder)
-		//ctx, span := tracer.StartSpan(ctx, "SQL", query)
-        //defer span.End()
 		return conn.ExecCtx(ctx, query, {{.expressionValues}})
 	}, {{.keyValues}}){{else}}query := fmt.Sprintf("update %s set %s where {{.originalPrimaryKey}} = {{if .postgreSql}}$1{{else}}?{{end}}", m.table, {{.lowerStartCamelObject}}RowsWithPlaceHolder)
-    //ctx, span := tracer.StartSpan(ctx, "SQL", query)
-    //defer span.End()
     _,err:=m.conn.ExecCtx(ctx, query, {{.expressionValues}}){{end}}
 	return err
 }
@@ -74,12 +70,64 @@ func (m *default{{.upperStartCamelObject}}Model) updatePartialBySession(ctx cont
     if err != nil {
         return err
     }
-    //ctx, span := tracer.StartSpan(ctx, "SQL", query)
-    //defer span.End()
 
     if session == nil {
         session = m.conn
     }
-    _, err = session.ExecCtx(ctx, query, updateData...)
-    return err
+    return m.execWithCache(ctx, session, whereCondition, func() error {
+        _, err := session.ExecCtx(ctx, query, updateData...)
+        return err
+    })
+}
+
+// UpdateWithVersion 按主键和 version 字段乐观锁更新，版本不一致时返回 modelx.ErrVersionConflict
+func (m *default{{.upperStartCamelObject}}Model) UpdateWithVersion(ctx context.Context, data *{{.upperStartCamelObject}}, session ...sqlx.Session) error {
+    var oneSession sqlx.Session
+    if len(session) > 0 && session[0] != nil {
+        oneSession = session[0]
+    } else {
+        oneSession = m.conn
+    }
+    whereCondition := sqlstatement.LogicCondition{
+        Conditions: []sqlstatement.ICondition{
+            sqlstatement.Condition{
+                Field:    "{{.originalPrimaryKey}}",
+                Operator: sqlstatement.OperatorEqual,
+                Value:    data.{{.upperStartCamelPrimaryKey}},
+            },
+        },
+    }
+    return m.execWithCache(ctx, oneSession, whereCondition, func() error {
+        return m.tableInfo.UpdateWithVersion(ctx, oneSession, data)
+    })
+}
+
+// execWithCache 执行按条件的修改或删除，{{if .withCache}}执行前查出受影响的记录，执行后删除它们的缓存{{else}}未使用缓存时直接执行{{end}}
+func (m *default{{.upperStartCamelObject}}Model) execWithCache(ctx context.Context, session sqlx.Session, whereCondition sqlstatement.LogicCondition, exec func() error) error {
+    {{if .withCache}}query, data, err := m.sqlBuilder.SelectSql("*", whereCondition, 0, 0)
+    if err != nil {
+        return err
+    }
+    list := make([]*{{.upperStartCamelObject}}, 0)
+    if err = session.QueryRowsCtx(ctx, &list, query, data...); err != nil {
+        return err
+    }
+    if err = exec(); err != nil {
+        return err
+    }
+    keys := make([]string, 0, len(list))
+    for _, one := range list {
+        keys = append(keys, m.cacheKeys(one)...)
+    }
+    if len(keys) == 0 {
+        return nil
+    }
+    return m.DelCacheCtx(ctx, keys...){{else}}return exec(){{end}}
+}
+{{if .withCache}}
+// cacheKeys 一条记录对应的主键和唯一索引的缓存key
+func (m *default{{.upperStartCamelObject}}Model) cacheKeys(data *{{.upperStartCamelObject}}) []string {
+	{{.keys}}
+	return []string{ {{.keyValues}} }
 }
+{{end}}
\ No newline at end of file
@@ -0,0 +1,336 @@
+package goprotoc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// doBreakingCheck compares the given files with the previous version of the same files and reports
+// the changes that are not wire compatible. The previous version is either a descriptor set or a
+// directory of sources, for example a checkout of a git ref.
+func doBreakingCheck(against string, includePaths []string, fds []*desc.FileDescriptor) (issues, error) {
+	names := make([]string, len(fds))
+	for i, fd := range fds {
+		names[i] = fd.GetName()
+	}
+	previous, err := loadAgainst(against, includePaths, names)
+	if err != nil {
+		return nil, err
+	}
+
+	var is issues
+	for _, fd := range fds {
+		old, ok := previous[fd.GetName()]
+		if !ok {
+			// new file, nothing can break
+			continue
+		}
+		compareFiles(&is, old, fd)
+	}
+	return is, nil
+}
+
+// loadAgainst loads the previous version of the named files; files that don't exist in it are omitted.
+func loadAgainst(against string, includePaths []string, names []string) (map[string]*desc.FileDescriptor, error) {
+	info, err := os.Stat(against)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]*desc.FileDescriptor{}
+
+	if info.IsDir() {
+		var existing []string
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(against, name)); err == nil {
+				existing = append(existing, name)
+			}
+		}
+		if len(existing) == 0 {
+			return result, nil
+		}
+		// the previous sources come first so that their imports also resolve to the previous version
+		p := protoparse.Parser{ImportPaths: append([]string{against}, includePaths...)}
+		fds, err := p.ParseFiles(existing...)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", against, err)
+		}
+		for _, fd := range fds {
+			result[fd.GetName()] = fd
+		}
+		return result, nil
+	}
+
+	d, err := os.ReadFile(against)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(d, &set); err != nil {
+		return nil, fmt.Errorf("file %q is not a valid file descriptor set: %v", against, err)
+	}
+	allFiles := map[string]*descriptorpb.FileDescriptorProto{}
+	for _, fd := range set.File {
+		if _, ok := allFiles[fd.GetName()]; !ok {
+			allFiles[fd.GetName()] = fd
+		}
+	}
+	linked := map[string]*desc.FileDescriptor{}
+	for _, name := range names {
+		if _, ok := allFiles[name]; !ok {
+			continue
+		}
+		fd, err := linkFile(name, allFiles, linked, nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not load %q from %s: %v", name, against, err)
+		}
+		result[name] = fd
+	}
+	return result, nil
+}
+
+func compareFiles(is *issues, old, cur *desc.FileDescriptor) {
+	file := cur.GetName()
+	if old.GetPackage() != cur.GetPackage() {
+		is.add(file, nil, severityError, "PACKAGE_CHANGED", "package changed from %q to %q", old.GetPackage(), cur.GetPackage())
+	}
+
+	oldMsgs, curMsgs := map[string]*desc.MessageDescriptor{}, map[string]*desc.MessageDescriptor{}
+	oldEnums, curEnums := map[string]*desc.EnumDescriptor{}, map[string]*desc.EnumDescriptor{}
+	var oldMsgOrder []*desc.MessageDescriptor
+	var oldEnumOrder []*desc.EnumDescriptor
+	collectTypes(old.GetMessageTypes(), old.GetEnumTypes(), oldMsgs, oldEnums, &oldMsgOrder, &oldEnumOrder)
+	collectTypes(cur.GetMessageTypes(), cur.GetEnumTypes(), curMsgs, curEnums, nil, nil)
+
+	for _, om := range oldMsgOrder {
+		cm, ok := curMsgs[om.GetFullyQualifiedName()]
+		if !ok {
+			is.add(file, parentOf(cur, curMsgs, om), severityError, "MESSAGE_REMOVED", "message %q was removed", om.GetFullyQualifiedName())
+			continue
+		}
+		compareMessages(is, file, om, cm)
+	}
+	for _, oe := range oldEnumOrder {
+		ce, ok := curEnums[oe.GetFullyQualifiedName()]
+		if !ok {
+			is.add(file, parentOf(cur, curMsgs, oe), severityError, "ENUM_REMOVED", "enum %q was removed", oe.GetFullyQualifiedName())
+			continue
+		}
+		compareEnums(is, file, oe, ce)
+	}
+
+	for _, osd := range old.GetServices() {
+		csd := cur.FindService(osd.GetFullyQualifiedName())
+		if csd == nil {
+			is.add(file, nil, severityError, "SERVICE_REMOVED", "service %q was removed", osd.GetFullyQualifiedName())
+			continue
+		}
+		compareServices(is, file, osd, csd)
+	}
+}
+
+// collectTypes indexes the messages and enums by fully-qualified name, including nested ones.
+// Map entries are part of their field and are not compared on their own.
+func collectTypes(msgs []*desc.MessageDescriptor, enums []*desc.EnumDescriptor, msgIndex map[string]*desc.MessageDescriptor,
+	enumIndex map[string]*desc.EnumDescriptor, msgOrder *[]*desc.MessageDescriptor, enumOrder *[]*desc.EnumDescriptor) {
+	for _, ed := range enums {
+		enumIndex[ed.GetFullyQualifiedName()] = ed
+		if enumOrder != nil {
+			*enumOrder = append(*enumOrder, ed)
+		}
+	}
+	for _, md := range msgs {
+		if md.IsMapEntry() {
+			continue
+		}
+		msgIndex[md.GetFullyQualifiedName()] = md
+		if msgOrder != nil {
+			*msgOrder = append(*msgOrder, md)
+		}
+		collectTypes(md.GetNestedMessageTypes(), md.GetNestedEnumTypes(), msgIndex, enumIndex, msgOrder, enumOrder)
+	}
+}
+
+// parentOf returns the element of the current file that contained a removed element, if it still exists.
+func parentOf(cur *desc.FileDescriptor, curMsgs map[string]*desc.MessageDescriptor, removed desc.Descriptor) desc.Descriptor {
+	if parent, ok := removed.GetParent().(*desc.MessageDescriptor); ok {
+		if md, ok := curMsgs[parent.GetFullyQualifiedName()]; ok {
+			return md
+		}
+	}
+	return nil
+}
+
+func compareMessages(is *issues, file string, old, cur *desc.MessageDescriptor) {
+	for _, of := range old.GetFields() {
+		cf := cur.FindFieldByNumber(of.GetNumber())
+		if cf == nil {
+			if renamed := cur.FindFieldByName(of.GetName()); renamed != nil {
+				is.add(file, renamed, severityError, "FIELD_RENUMBERED", "field %q changed number from %d to %d",
+					of.GetFullyQualifiedName(), of.GetNumber(), renamed.GetNumber())
+			} else if !isReservedNumber(cur.AsDescriptorProto().GetReservedRange(), of.GetNumber()) {
+				is.add(file, cur, severityError, "FIELD_REMOVED", "field %q with number %d was removed without reserving its number",
+					of.GetFullyQualifiedName(), of.GetNumber())
+			}
+			continue
+		}
+		if of.GetName() != cf.GetName() {
+			is.add(file, cf, severityWarning, "FIELD_NAME_CHANGED", "field %d of %q changed name from %q to %q, which breaks the JSON encoding",
+				of.GetNumber(), cur.GetFullyQualifiedName(), of.GetName(), cf.GetName())
+		}
+		if oldType, curType := fieldTypeName(of), fieldTypeName(cf); !wireCompatible(of, cf) {
+			is.add(file, cf, severityError, "FIELD_TYPE_CHANGED", "field %q changed type from %s to %s",
+				cf.GetFullyQualifiedName(), oldType, curType)
+		}
+		if of.IsRepeated() != cf.IsRepeated() || of.IsMap() != cf.IsMap() {
+			is.add(file, cf, severityError, "FIELD_CARDINALITY_CHANGED", "field %q changed from %s to %s",
+				cf.GetFullyQualifiedName(), fieldCardinality(of), fieldCardinality(cf))
+		}
+	}
+}
+
+func compareEnums(is *issues, file string, old, cur *desc.EnumDescriptor) {
+	for _, ov := range old.GetValues() {
+		cv := cur.FindValueByNumber(ov.GetNumber())
+		if cv == nil {
+			if !isReservedEnumNumber(cur.AsEnumDescriptorProto().GetReservedRange(), ov.GetNumber()) {
+				is.add(file, cur, severityError, "ENUM_VALUE_REMOVED", "enum value %q with number %d was removed without reserving its number",
+					ov.GetFullyQualifiedName(), ov.GetNumber())
+			}
+			continue
+		}
+		if ov.GetName() != cv.GetName() {
+			is.add(file, cv, severityWarning, "ENUM_VALUE_NAME_CHANGED", "enum value %d of %q changed name from %q to %q, which breaks the JSON encoding",
+				ov.GetNumber(), cur.GetFullyQualifiedName(), ov.GetName(), cv.GetName())
+		}
+	}
+}
+
+func compareServices(is *issues, file string, old, cur *desc.ServiceDescriptor) {
+	for _, om := range old.GetMethods() {
+		cm := cur.FindMethodByName(om.GetName())
+		if cm == nil {
+			is.add(file, cur, severityError, "RPC_REMOVED", "rpc %q was removed", om.GetFullyQualifiedName())
+			continue
+		}
+		if om.IsClientStreaming() != cm.IsClientStreaming() || om.IsServerStreaming() != cm.IsServerStreaming() {
+			is.add(file, cm, severityError, "RPC_STREAMING_CHANGED", "rpc %q changed from %s to %s",
+				cm.GetFullyQualifiedName(), streamingMode(om), streamingMode(cm))
+		}
+		if om.GetInputType().GetFullyQualifiedName() != cm.GetInputType().GetFullyQualifiedName() {
+			is.add(file, cm, severityError, "RPC_REQUEST_TYPE_CHANGED", "rpc %q changed request type from %q to %q",
+				cm.GetFullyQualifiedName(), om.GetInputType().GetFullyQualifiedName(), cm.GetInputType().GetFullyQualifiedName())
+		}
+		if om.GetOutputType().GetFullyQualifiedName() != cm.GetOutputType().GetFullyQualifiedName() {
+			is.add(file, cm, severityError, "RPC_RESPONSE_TYPE_CHANGED", "rpc %q changed response type from %q to %q",
+				cm.GetFullyQualifiedName(), om.GetOutputType().GetFullyQualifiedName(), cm.GetOutputType().GetFullyQualifiedName())
+		}
+	}
+}
+
+// isReservedNumber reports whether n is in one of the message reserved ranges, whose end is exclusive.
+func isReservedNumber(ranges []*descriptorpb.DescriptorProto_ReservedRange, n int32) bool {
+	for _, r := range ranges {
+		if n >= r.GetStart() && n < r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+// isReservedEnumNumber reports whether n is in one of the enum reserved ranges, whose end is inclusive.
+func isReservedEnumNumber(ranges []*descriptorpb.EnumDescriptorProto_EnumReservedRange, n int32) bool {
+	for _, r := range ranges {
+		if n >= r.GetStart() && n <= r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+// wireTypeGroups the scalar types that can be changed into each other without breaking the binary encoding.
+var wireTypeGroups = map[descriptorpb.FieldDescriptorProto_Type]string{
+	descriptorpb.FieldDescriptorProto_TYPE_INT32:    "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_INT64:    "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_UINT32:   "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_UINT64:   "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_BOOL:     "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_ENUM:     "varint",
+	descriptorpb.FieldDescriptorProto_TYPE_SINT32:   "zigzag",
+	descriptorpb.FieldDescriptorProto_TYPE_SINT64:   "zigzag",
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED32:  "fixed32",
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED32: "fixed32",
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED64:  "fixed64",
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED64: "fixed64",
+	descriptorpb.FieldDescriptorProto_TYPE_FLOAT:    "float",
+	descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:   "double",
+	descriptorpb.FieldDescriptorProto_TYPE_STRING:   "bytes",
+	descriptorpb.FieldDescriptorProto_TYPE_BYTES:    "bytes",
+}
+
+func wireCompatible(old, cur *desc.FieldDescriptor) bool {
+	ot, ct := old.GetType(), cur.GetType()
+	switch {
+	case ot == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE || ot == descriptorpb.FieldDescriptorProto_TYPE_GROUP ||
+		ct == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE || ct == descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return ot == ct && old.GetMessageType().GetFullyQualifiedName() == cur.GetMessageType().GetFullyQualifiedName()
+	case ot == descriptorpb.FieldDescriptorProto_TYPE_ENUM && ct == descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		return old.GetEnumType().GetFullyQualifiedName() == cur.GetEnumType().GetFullyQualifiedName()
+	}
+	return wireTypeGroups[ot] == wireTypeGroups[ct]
+}
+
+func fieldTypeName(fd *desc.FieldDescriptor) string {
+	switch {
+	case fd.GetMessageType() != nil:
+		return fd.GetMessageType().GetFullyQualifiedName()
+	case fd.GetEnumType() != nil:
+		return fd.GetEnumType().GetFullyQualifiedName()
+	}
+	return descriptorTypeName(fd.GetType())
+}
+
+func descriptorTypeName(t descriptorpb.FieldDescriptorProto_Type) string {
+	name := t.String()
+	if len(name) > len("TYPE_") {
+		return lowerASCII(name[len("TYPE_"):])
+	}
+	return name
+}
+
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func fieldCardinality(fd *desc.FieldDescriptor) string {
+	switch {
+	case fd.IsMap():
+		return "map"
+	case fd.IsRepeated():
+		return "repeated"
+	}
+	return "singular"
+}
+
+func streamingMode(md *desc.MethodDescriptor) string {
+	switch {
+	case md.IsClientStreaming() && md.IsServerStreaming():
+		return "bidirectional streaming"
+	case md.IsClientStreaming():
+		return "client streaming"
+	case md.IsServerStreaming():
+		return "server streaming"
+	}
+	return "unary"
+}
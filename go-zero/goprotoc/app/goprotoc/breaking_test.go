@@ -0,0 +1,298 @@
+package goprotoc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// mustParse parses the given sources, keyed by file name, and returns the descriptors of all of them
+// in the order of the names.
+func mustParse(t *testing.T, sources map[string]string) []*desc.FileDescriptor {
+	t.Helper()
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	p := protoparse.Parser{
+		Accessor:              protoparse.FileContentsFromMap(sources),
+		IncludeSourceCodeInfo: true,
+	}
+	fds, err := p.ParseFiles(names...)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	return fds
+}
+
+func writeSources(t *testing.T, dir string, sources map[string]string) {
+	t.Helper()
+	for name, src := range sources {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func rules(is issues) []string {
+	result := []string{}
+	for _, i := range is {
+		result = append(result, i.Rule)
+	}
+	sort.Strings(result)
+	return result
+}
+
+const breakingHeader = "syntax = \"proto3\";\npackage test;\n"
+
+func breakingSource(pkg, body string) string {
+	return "syntax = \"proto3\";\npackage " + pkg + ";\n" + body
+}
+
+func TestCompareFiles(t *testing.T) {
+	testCases := []struct {
+		name   string
+		before string
+		after  string
+		// package of the after file, defaults to test
+		pkg   string
+		rules []string
+	}{
+		{
+			name:   "unchanged",
+			before: "message M { int32 a = 1; }",
+			after:  "message M { int32 a = 1; }",
+			rules:  []string{},
+		},
+		{
+			name:   "field removed unreserved",
+			before: "message M { int32 a = 1; string b = 2; }",
+			after:  "message M { int32 a = 1; }",
+			rules:  []string{"FIELD_REMOVED"},
+		},
+		{
+			name:   "field removed reserved",
+			before: "message M { int32 a = 1; string b = 2; }",
+			after:  "message M { int32 a = 1; reserved 2; }",
+			rules:  []string{},
+		},
+		{
+			name:   "field removed in reserved range",
+			before: "message M { int32 a = 1; string b = 5; }",
+			after:  "message M { int32 a = 1; reserved 3 to 6; }",
+			rules:  []string{},
+		},
+		{
+			name:   "field renumbered",
+			before: "message M { int32 a = 1; string b = 2; }",
+			after:  "message M { int32 a = 1; string b = 3; }",
+			rules:  []string{"FIELD_RENUMBERED"},
+		},
+		{
+			name:   "field renamed",
+			before: "message M { int32 a = 1; }",
+			after:  "message M { int32 c = 1; }",
+			rules:  []string{"FIELD_NAME_CHANGED"},
+		},
+		{
+			name:   "int32 to sint32",
+			before: "message M { int32 a = 1; }",
+			after:  "message M { sint32 a = 1; }",
+			rules:  []string{"FIELD_TYPE_CHANGED"},
+		},
+		{
+			name:   "sint32 to int32",
+			before: "message M { sint32 a = 1; }",
+			after:  "message M { int32 a = 1; }",
+			rules:  []string{"FIELD_TYPE_CHANGED"},
+		},
+		{
+			name:   "int32 to int64",
+			before: "message M { int32 a = 1; }",
+			after:  "message M { int64 a = 1; }",
+			rules:  []string{},
+		},
+		{
+			name:   "string to bytes",
+			before: "message M { string a = 1; }",
+			after:  "message M { bytes a = 1; }",
+			rules:  []string{},
+		},
+		{
+			name:   "message type changed",
+			before: "message A {} message B {} message M { A a = 1; }",
+			after:  "message A {} message B {} message M { B a = 1; }",
+			rules:  []string{"FIELD_TYPE_CHANGED"},
+		},
+		{
+			name:   "cardinality changed",
+			before: "message M { int32 a = 1; }",
+			after:  "message M { repeated int32 a = 1; }",
+			rules:  []string{"FIELD_CARDINALITY_CHANGED"},
+		},
+		{
+			name:   "nested message removed",
+			before: "message M { message N {} }",
+			after:  "message M {}",
+			rules:  []string{"MESSAGE_REMOVED"},
+		},
+		{
+			name:   "rpc removed",
+			before: "message R {} service S { rpc A(R) returns (R); rpc B(R) returns (R); }",
+			after:  "message R {} service S { rpc A(R) returns (R); }",
+			rules:  []string{"RPC_REMOVED"},
+		},
+		{
+			name:   "rpc streaming changed",
+			before: "message R {} service S { rpc A(R) returns (R); rpc B(stream R) returns (R); }",
+			after:  "message R {} service S { rpc A(R) returns (stream R); rpc B(R) returns (R); }",
+			rules:  []string{"RPC_STREAMING_CHANGED", "RPC_STREAMING_CHANGED"},
+		},
+		{
+			name:   "rpc types changed",
+			before: "message R {} message Q {} service S { rpc A(R) returns (R); }",
+			after:  "message R {} message Q {} service S { rpc A(Q) returns (Q); }",
+			rules:  []string{"RPC_REQUEST_TYPE_CHANGED", "RPC_RESPONSE_TYPE_CHANGED"},
+		},
+		{
+			name:   "service removed",
+			before: "message R {} service S { rpc A(R) returns (R); }",
+			after:  "message R {}",
+			rules:  []string{"SERVICE_REMOVED"},
+		},
+		{
+			name:   "enum value removed",
+			before: "enum E { E_UNSPECIFIED = 0; E_A = 1; E_B = 2; }",
+			after:  "enum E { E_UNSPECIFIED = 0; E_A = 1; }",
+			rules:  []string{"ENUM_VALUE_REMOVED"},
+		},
+		{
+			name:   "enum value removed reserved",
+			before: "enum E { E_UNSPECIFIED = 0; E_A = 1; E_B = 2; }",
+			after:  "enum E { E_UNSPECIFIED = 0; E_A = 1; reserved 2; }",
+			rules:  []string{},
+		},
+		{
+			name:   "enum value renamed",
+			before: "enum E { E_UNSPECIFIED = 0; E_A = 1; }",
+			after:  "enum E { E_UNSPECIFIED = 0; E_C = 1; }",
+			rules:  []string{"ENUM_VALUE_NAME_CHANGED"},
+		},
+		{
+			name:   "enum removed",
+			before: "message M { enum E { E_UNSPECIFIED = 0; } }",
+			after:  "message M {}",
+			rules:  []string{"ENUM_REMOVED"},
+		},
+		{
+			name:   "package changed",
+			before: "message M {}",
+			after:  "message M {}",
+			pkg:    "other",
+			rules:  []string{"MESSAGE_REMOVED", "PACKAGE_CHANGED"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pkg := tc.pkg
+			if pkg == "" {
+				pkg = "test"
+			}
+			old := mustParse(t, map[string]string{"test.proto": breakingHeader + tc.before})[0]
+			cur := mustParse(t, map[string]string{"test.proto": breakingSource(pkg, tc.after)})[0]
+			var is issues
+			compareFiles(&is, old, cur)
+			if got := rules(is); !reflect.DeepEqual(got, tc.rules) {
+				t.Errorf("wrong rules: expected %v, got %v: %v", tc.rules, got, is)
+			}
+		})
+	}
+}
+
+func TestCompareFilesSeverity(t *testing.T) {
+	old := mustParse(t, map[string]string{"test.proto": breakingHeader + "message M { int32 a = 1; int32 b = 2; }"})[0]
+	cur := mustParse(t, map[string]string{"test.proto": breakingHeader + "message M {\n  int32 c = 1;\n}"})[0]
+	var is issues
+	compareFiles(&is, old, cur)
+	expected := issues{
+		{Path: "test.proto", Line: 3, Column: 1, Severity: severityError, Rule: "FIELD_REMOVED",
+			Message: `field "test.M.b" with number 2 was removed without reserving its number`},
+		{Path: "test.proto", Line: 4, Column: 3, Severity: severityWarning, Rule: "FIELD_NAME_CHANGED",
+			Message: `field 1 of "test.M" changed name from "a" to "c", which breaks the JSON encoding`},
+	}
+	sort.Slice(is, func(a, b int) bool { return is[a].Line < is[b].Line })
+	if !reflect.DeepEqual(is, expected) {
+		t.Errorf("wrong issues:\nexpected %+v\ngot      %+v", expected, is)
+	}
+	if is.count(severityError) != 1 || is.count(severityWarning) != 2 {
+		t.Errorf("wrong counts: %d errors, %d warnings and errors", is.count(severityError), is.count(severityWarning))
+	}
+}
+
+func TestDoBreakingCheck(t *testing.T) {
+	before := map[string]string{
+		"a/common.proto": breakingHeader + "message Common { int32 id = 1; }",
+		"a/svc.proto":    breakingHeader + "import \"a/common.proto\";\nmessage Req { Common c = 1; int32 n = 2; }",
+	}
+	after := map[string]string{
+		"a/common.proto": breakingHeader + "message Common { int32 id = 1; }",
+		"a/svc.proto":    breakingHeader + "import \"a/common.proto\";\nmessage Req { Common c = 1; }",
+		"a/new.proto":    breakingHeader + "message New {}",
+	}
+	fds := mustParse(t, after)
+
+	// against a directory of the previous sources, the new file is skipped
+	dir := t.TempDir()
+	writeSources(t, dir, before)
+	is, err := doBreakingCheck(dir, nil, fds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules(is); !reflect.DeepEqual(got, []string{"FIELD_REMOVED"}) || is[0].Path != "a/svc.proto" {
+		t.Errorf("wrong issues against directory: %v", is)
+	}
+
+	// against a descriptor set
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range mustParse(t, before) {
+		set.File = append(set.File, fd.AsFileDescriptorProto())
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setFile := filepath.Join(t.TempDir(), "before.pb")
+	if err := os.WriteFile(setFile, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	is, err = doBreakingCheck(setFile, nil, fds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules(is); !reflect.DeepEqual(got, []string{"FIELD_REMOVED"}) {
+		t.Errorf("wrong issues against descriptor set: %v", is)
+	}
+
+	if _, err := doBreakingCheck(filepath.Join(dir, "missing"), nil, fds); err == nil {
+		t.Error("expected error for missing against path")
+	}
+	invalid := filepath.Join(t.TempDir(), "invalid.pb")
+	if err := os.WriteFile(invalid, []byte("not a descriptor set"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doBreakingCheck(invalid, nil, fds); err == nil {
+		t.Error("expected error for invalid descriptor set")
+	}
+}
@@ -0,0 +1,226 @@
+package goprotoc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	cacheFileName = "goprotoc-cache.json"
+	cacheVersion  = 1
+)
+
+// genCache records the inputs of the last code generation of each proto file,
+// so that a file whose sources, plugins and plugin parameters are unchanged is
+// not parsed nor passed to the plugins again.
+//
+// The plugins are invoked once with all the files that changed, so the outputs
+// cannot be attributed to the file that generated them. They are recorded with
+// their content hash instead: if any of them was removed or edited, every file
+// is generated again.
+//
+// Only the files that changed are passed to the plugins, so a plugin that
+// aggregates all of the files into one output must be named with
+// --cache_aggregate to be passed all of them whenever any changed.
+type genCache struct {
+	path string
+	// key of the generation inputs, hashed with the sources of the file
+	key string
+
+	Version int               `json:"version"`
+	Files   map[string]string `json:"files"`
+	Outputs map[string]string `json:"outputs"`
+}
+
+// loadCache loads the cache in the given directory. The key identifies the
+// plugins, their parameters and output locations, and the import paths.
+func loadCache(dir, key string) (*genCache, error) {
+	c := &genCache{
+		path:    filepath.Join(dir, cacheFileName),
+		key:     key,
+		Version: cacheVersion,
+		Files:   map[string]string{},
+		Outputs: map[string]string{},
+	}
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var loaded genCache
+	if err := json.Unmarshal(data, &loaded); err != nil || loaded.Version != cacheVersion {
+		// a corrupt or outdated cache is just discarded
+		return c, nil
+	}
+	for name, hash := range loaded.Outputs {
+		if h, err := hashFile(name); err != nil || h != hash {
+			// a generated file changed, so the records of the files are no longer trustworthy
+			return c, nil
+		}
+	}
+	if loaded.Files != nil {
+		c.Files = loaded.Files
+	}
+	if loaded.Outputs != nil {
+		c.Outputs = loaded.Outputs
+	}
+	return c, nil
+}
+
+// loadStaleFiles loads the cache of the code generation and returns the input
+// files that must be generated again, with the keys to record once they are.
+func loadStaleFiles(opts *protocOptions, sources *sourceSet) (*genCache, map[string]string, error) {
+	for lang, loc := range opts.output {
+		dest := loc[strings.Index(loc, ":")+1:]
+		if ext := strings.ToLower(filepath.Ext(dest)); ext == ".zip" || ext == ".jar" {
+			return nil, nil, fmt.Errorf("cannot use --cache_dir with the %s output to an archive", lang)
+		}
+	}
+	key, err := cacheKey(opts.output, opts.pluginDefs, opts.includePaths)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := loadCache(opts.cacheDir, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	stale := map[string]string{}
+	for _, name := range opts.protoFiles {
+		deps, err := sources.closure(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key := c.fileKey(deps); !c.fresh(name, key) {
+			stale[name] = key
+		}
+	}
+	return c, stale, nil
+}
+
+// fileKey computes the key of a proto file from the cache key and the contents
+// of the file and its transitive imports.
+func (c *genCache) fileKey(sources []*sourceFile) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n", c.key)
+	for _, f := range sources {
+		_, _ = fmt.Fprintf(h, "%s %s %s\n", f.name, f.path, f.hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fresh reports whether the file was generated with the given key.
+func (c *genCache) fresh(name, key string) bool {
+	return c.Files[name] == key
+}
+
+// update records the key of a generated file.
+func (c *genCache) update(name, key string) {
+	c.Files[name] = key
+}
+
+// addOutput records the content hash of a generated file.
+func (c *genCache) addOutput(path, hash string) {
+	c.Outputs[path] = hash
+}
+
+func (c *genCache) outputs() []string {
+	names := make([]string, 0, len(c.Outputs))
+	for name := range c.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *genCache) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// cacheKey computes the part of the cache key that does not depend on the
+// proto file: the identity of each plugin, its parameters and its output
+// location, and the import paths.
+func cacheKey(outputs map[string]string, pluginDefs map[string]string, includePaths []string) (string, error) {
+	langs := make([]string, 0, len(outputs))
+	for lang := range outputs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	// the import paths and output locations may be relative
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "goprotoc %s\n%s\n", version, wd)
+	for _, dir := range includePaths {
+		_, _ = fmt.Fprintf(h, "-I%s\n", dir)
+	}
+	for _, lang := range langs {
+		plugin, err := pluginIdentity(lang, pluginDefs[lang])
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "%s %s %q\n", lang, plugin, outputs[lang])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// pluginIdentity returns the content hash of the program that generates the
+// given output, following the same lookup as executePlugin.
+func pluginIdentity(lang, pluginName string) (string, error) {
+	if pluginName == "" {
+		if _, ok := inprocessPlugins[lang]; ok {
+			self, err := os.Executable()
+			if err != nil {
+				return "", err
+			}
+			return hashFile(self)
+		}
+		if _, ok := protocOutputs[lang]; ok {
+			pluginName = "protoc"
+		} else {
+			pluginName = "protoc-gen-" + lang
+		}
+	}
+	path, err := exec.LookPath(pluginName)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", lang, err)
+	}
+	return hashFile(path)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,287 @@
+package goprotoc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jhump/goprotoc/plugins"
+)
+
+// the files passed to the test plugins by the last run
+var cacheTestGenerated = map[string][]string{}
+
+func init() {
+	// cachetest generates a file for each proto file
+	RegisterPlugin("cachetest", func(req *plugins.CodeGenRequest, resp *plugins.CodeGenResponse) error {
+		for _, fd := range req.Files {
+			cacheTestGenerated["cachetest"] = append(cacheTestGenerated["cachetest"], fd.GetName())
+			var names []string
+			for _, md := range fd.GetMessageTypes() {
+				names = append(names, md.GetName())
+			}
+			_, err := fmt.Fprintf(resp.OutputFile(fd.GetName()+".txt"), "%s %s\n", strings.Join(req.Args, ","), strings.Join(names, ","))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	// cacheindex generates a single file from all of the proto files
+	RegisterPlugin("cacheindex", func(req *plugins.CodeGenRequest, resp *plugins.CodeGenResponse) error {
+		var names []string
+		for _, fd := range req.Files {
+			names = append(names, fd.GetName())
+		}
+		cacheTestGenerated["cacheindex"] = names
+		_, err := io.WriteString(resp.OutputFile("index.txt"), strings.Join(names, "\n")+"\n")
+		return err
+	})
+}
+
+func runCacheTest(t *testing.T, args ...string) map[string][]string {
+	t.Helper()
+	cacheTestGenerated = map[string][]string{}
+	args = append([]string{"goprotoc", "--proto_path=.", "--cache_dir=cache", "--cache_aggregate=cacheindex",
+		"--cacheindex_out=out", "a.proto", "b.proto", "c.proto"}, args...)
+	var stderr strings.Builder
+	if err := run(args, nil, io.Discard, &stderr); err != nil {
+		t.Fatalf("run failed: %v %s", err, stderr.String())
+	}
+	for _, names := range cacheTestGenerated {
+		sort.Strings(names)
+	}
+	return cacheTestGenerated
+}
+
+func readTestFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCodeGenCache(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeSources(t, dir, map[string]string{
+		"a.proto": breakingHeader + "import \"b.proto\";\nmessage A { B b = 1; }\n",
+		"b.proto": breakingHeader + "message B {}\n",
+		"c.proto": breakingHeader + "message C {}\n",
+	})
+	if err := os.Mkdir(filepath.Join(dir, "out"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	all := []string{"a.proto", "b.proto", "c.proto"}
+
+	steps := []struct {
+		name string
+		// change is applied before the run
+		change func()
+		args   []string
+		// files passed to the plugins, nil for none
+		perFile, aggregate []string
+	}{
+		{
+			name:      "first run",
+			args:      []string{"--cachetest_out=out"},
+			perFile:   all,
+			aggregate: all,
+		},
+		{
+			name: "unchanged",
+			args: []string{"--cachetest_out=out"},
+		},
+		{
+			name: "import changed",
+			change: func() {
+				writeSources(t, dir, map[string]string{"b.proto": breakingHeader + "message B {}\nmessage B2 {}\n"})
+			},
+			args:      []string{"--cachetest_out=out"},
+			perFile:   []string{"a.proto", "b.proto"},
+			aggregate: all,
+		},
+		{
+			name: "generated file edited",
+			change: func() {
+				writeSources(t, dir, map[string]string{"out/c.proto.txt": "edited\n"})
+			},
+			args:      []string{"--cachetest_out=out"},
+			perFile:   all,
+			aggregate: all,
+		},
+		{
+			name: "generated file removed",
+			change: func() {
+				if err := os.Remove(filepath.Join(dir, "out", "index.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			args:      []string{"--cachetest_out=out"},
+			perFile:   all,
+			aggregate: all,
+		},
+		{
+			name:      "parameter changed",
+			args:      []string{"--cachetest_out=v2:out"},
+			perFile:   all,
+			aggregate: all,
+		},
+		{
+			name: "unchanged again",
+			args: []string{"--cachetest_out=v2:out"},
+		},
+	}
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+		got := runCacheTest(t, step.args...)
+		if !reflect.DeepEqual(got["cachetest"], step.perFile) || !reflect.DeepEqual(got["cacheindex"], step.aggregate) {
+			t.Fatalf("%s: wrong files passed to the plugins: expected %v and %v, got %v", step.name, step.perFile, step.aggregate, got)
+		}
+	}
+
+	// the outputs are complete and up to date
+	if s := readTestFile(t, "out/c.proto.txt"); s != "v2 C\n" {
+		t.Errorf("wrong c.proto.txt: %q", s)
+	}
+	if s := readTestFile(t, "out/b.proto.txt"); s != "v2 B,B2\n" {
+		t.Errorf("wrong b.proto.txt: %q", s)
+	}
+	if s := readTestFile(t, "out/index.txt"); s != "a.proto\nb.proto\nc.proto\n" {
+		t.Errorf("wrong index.txt: %q", s)
+	}
+}
+
+func TestCodeGenCacheOptions(t *testing.T) {
+	testCases := []struct {
+		args []string
+		err  string
+	}{
+		{
+			args: []string{"--cache_aggregate=cachetest", "--cachetest_out=out", "a.proto"},
+			err:  "Can only use --cache_aggregate with --cache_dir.",
+		},
+		{
+			args: []string{"--cache_dir=cache", "--cache_aggregate=cacheindex", "--cachetest_out=out", "a.proto"},
+			err:  "--cache_aggregate=cacheindex does not name an output",
+		},
+		{
+			args: []string{"--cache_dir=cache", "--cachetest_out=out.zip", "a.proto"},
+			err:  "cannot use --cache_dir with the cachetest output to an archive",
+		},
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeSources(t, dir, map[string]string{"a.proto": breakingHeader})
+	for _, tc := range testCases {
+		err := run(append([]string{"goprotoc"}, tc.args...), nil, io.Discard, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: expected error containing %q, got %v", tc.args, tc.err, err)
+		}
+	}
+}
+
+func TestLoadStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeSources(t, dir, map[string]string{
+		"a.proto": breakingHeader + "import \"b.proto\";\n",
+		"b.proto": breakingHeader,
+		"c.proto": breakingHeader,
+	})
+	plugin := filepath.Join(dir, "protoc-gen-test")
+	if err := os.WriteFile(plugin, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out", "a.txt")
+	writeSources(t, dir, map[string]string{"out/a.txt": "generated"})
+
+	opts := protocOptions{
+		includePaths: []string{dir},
+		cacheDir:     filepath.Join(dir, "cache"),
+		pluginDefs:   map[string]string{"test": plugin},
+		output:       map[string]string{"test": filepath.Join(dir, "out")},
+		protoFiles:   []string{"a.proto", "b.proto", "c.proto"},
+	}
+	// stale returns the stale files, and records them as generated
+	stale := func() []string {
+		c, keys, err := loadStaleFiles(&opts, newSourceSet(opts.includePaths))
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for name, key := range keys {
+			names = append(names, name)
+			c.update(name, key)
+		}
+		sort.Strings(names)
+		hash, err := hashFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.addOutput(output, hash)
+		if err := c.save(); err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+	all := []string{"a.proto", "b.proto", "c.proto"}
+
+	steps := []struct {
+		name   string
+		change func()
+		stale  []string
+	}{
+		{name: "empty cache", stale: all},
+		{name: "unchanged", stale: []string{}},
+		{
+			name:   "import changed",
+			change: func() { writeSources(t, dir, map[string]string{"b.proto": breakingHeader + "message B {}\n"}) },
+			stale:  []string{"a.proto", "b.proto"},
+		},
+		{
+			name:   "plugin binary changed",
+			change: func() { writeSources(t, dir, map[string]string{"protoc-gen-test": "#!/bin/sh\nexit 0\n"}) },
+			stale:  all,
+		},
+		{
+			name:   "parameter changed",
+			change: func() { opts.output["test"] = "opt:" + filepath.Join(dir, "out") },
+			stale:  all,
+		},
+		{
+			name:   "import path added",
+			change: func() { opts.includePaths = append(opts.includePaths, filepath.Join(dir, "out")) },
+			stale:  all,
+		},
+		{
+			name:   "output edited",
+			change: func() { writeSources(t, dir, map[string]string{"out/a.txt": "edited"}) },
+			stale:  all,
+		},
+		{
+			name: "corrupt cache",
+			change: func() {
+				writeSources(t, dir, map[string]string{"cache/" + cacheFileName: "{"})
+			},
+			stale: all,
+		},
+		{name: "unchanged again", stale: []string{}},
+	}
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+		if got := stale(); !reflect.DeepEqual(got, step.stale) {
+			t.Errorf("%s: wrong stale files: expected %v, got %v", step.name, step.stale, got)
+		}
+	}
+}
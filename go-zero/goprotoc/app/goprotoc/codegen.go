@@ -4,6 +4,8 @@ import (
 	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -70,31 +72,40 @@ func (f outputFile) String() string {
 	return fmt.Sprintf("%s:%s", f.loc.path, f.fileName)
 }
 
-func doCodeGen(outputs map[string]string, fds []*desc.FileDescriptor, pluginDefs map[string]string) error {
+// doCodeGen runs the plugins and writes their outputs. It returns the names of
+// the files and archives that were generated. If a cache is given, the outputs
+// are recorded in it and a file is not written when its contents are unchanged.
+func doCodeGen(outputs map[string]string, fds []*desc.FileDescriptor, pluginDefs map[string]string, cache *genCache) ([]string, error) {
 	locations, args, err := computeOutputLocations(outputs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	resps, err := runPlugins(args, fds, pluginDefs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	results, err := assembleFileOutputs(resps, locations)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// now we can accumulate outputs by archive and emit the
 	// normal files
+	var written []string
 	archiveResults := map[outputLocation]map[string]io.Reader{}
 	for file, data := range results {
 		if file.loc.locationType == outputTypeDir {
 			fileName := filepath.Join(file.loc.path, file.fileName)
-			if err := writeFileResult(fileName, data); err != nil {
-				return err
+			if cache != nil {
+				if err := writeCachedFileResult(cache, fileName, data); err != nil {
+					return nil, err
+				}
+			} else if err := writeFileResult(fileName, data); err != nil {
+				return nil, err
 			}
+			written = append(written, fileName)
 		} else {
 			archiveFiles := archiveResults[file.loc]
 			if archiveFiles == nil {
@@ -108,11 +119,27 @@ func doCodeGen(outputs map[string]string, fds []*desc.FileDescriptor, pluginDefs
 	// finally: emit any archives
 	for location, files := range archiveResults {
 		if err := writeArchiveResult(location.path, location.locationType == outputTypeJar, files); err != nil {
-			return err
+			return nil, err
 		}
+		written = append(written, location.path)
 	}
 
-	return nil
+	return written, nil
+}
+
+// splitOutputs splits the outputs into those whose plugin generates each file
+// separately and those whose plugin aggregates all of the files.
+func splitOutputs(outputs map[string]string, aggregateLangs map[string]struct{}) (perFile, aggregate map[string]string) {
+	perFile = map[string]string{}
+	aggregate = map[string]string{}
+	for lang, loc := range outputs {
+		if _, ok := aggregateLangs[lang]; ok {
+			aggregate[lang] = loc
+		} else {
+			perFile[lang] = loc
+		}
+	}
+	return perFile, aggregate
 }
 
 func computeOutputLocations(outputs map[string]string) (map[string]outputLocation, map[string]string, error) {
@@ -261,6 +288,25 @@ func writeFileResult(fileName string, data io.Reader) (e error) {
 	return err
 }
 
+// writeCachedFileResult writes a generated file unless it already has the same
+// contents, so that its modification time only changes with its contents, and
+// records its hash in the cache.
+func writeCachedFileResult(cache *genCache, fileName string, data io.Reader) error {
+	contents, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(contents)
+	hash := hex.EncodeToString(sum[:])
+	if existing, err := hashFile(fileName); err != nil || existing != hash {
+		if err := writeFileResult(fileName, bytes.NewReader(contents)); err != nil {
+			return err
+		}
+	}
+	cache.addOutput(fileName, hash)
+	return nil
+}
+
 // same manifest that protoc produces, except "goprotoc" instead of "protoc"
 var manifestContents = []byte(
 	`Manifest-Version: 1.0
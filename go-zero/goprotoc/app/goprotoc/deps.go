@@ -0,0 +1,137 @@
+package goprotoc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// importPattern matches an import statement. The sources are scanned instead of
+// parsed, so that the imports of a file are known without compiling it; a match
+// inside a block comment only adds a spurious dependency.
+var importPattern = regexp.MustCompile(`^\s*import\s+(?:(?:public|weak)\s+)?["']([^"']+)["']\s*;`)
+
+// sourceFile is a proto source file found in the import paths.
+type sourceFile struct {
+	// name is the import path of the file, like foo/bar.proto
+	name string
+	// path is the location of the file on disk, or empty for a file that is
+	// not found, like the well-known imports that are built into the parser
+	path    string
+	hash    string
+	imports []string
+}
+
+// sourceSet scans source files and their imports. Each file is read at most once.
+type sourceSet struct {
+	includePaths []string
+	files        map[string]*sourceFile
+}
+
+func newSourceSet(includePaths []string) *sourceSet {
+	if len(includePaths) == 0 {
+		includePaths = []string{"."}
+	}
+	return &sourceSet{includePaths: includePaths, files: map[string]*sourceFile{}}
+}
+
+func (s *sourceSet) get(name string) (*sourceFile, error) {
+	if f, ok := s.files[name]; ok {
+		return f, nil
+	}
+	f := &sourceFile{name: name}
+	for _, dir := range s.includePaths {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			f.path = path
+			break
+		}
+	}
+	if f.path != "" {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		f.hash = hex.EncodeToString(sum[:])
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, len(data)+1)
+		for scanner.Scan() {
+			if m := importPattern.FindSubmatch(scanner.Bytes()); m != nil {
+				f.imports = append(f.imports, string(m[1]))
+			}
+		}
+	}
+	s.files[name] = f
+	return f, nil
+}
+
+// closure returns the given files and all of their transitive imports, sorted by name.
+func (s *sourceSet) closure(names ...string) ([]*sourceFile, error) {
+	seen := map[string]*sourceFile{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		if _, ok := seen[name]; ok {
+			return nil
+		}
+		f, err := s.get(name)
+		if err != nil {
+			return err
+		}
+		seen[name] = f
+		for _, imp := range f.imports {
+			if err := visit(imp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	files := make([]*sourceFile, 0, len(seen))
+	for _, f := range seen {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+	return files, nil
+}
+
+// writeDepsFile writes a dependency file in the format expected by make, the
+// same as protoc's --dependency_out: a single rule whose targets are the outputs
+// and whose prerequisites are the sources they were generated from.
+func writeDepsFile(fileName string, outputs []string, sources []*sourceFile) error {
+	var buf bytes.Buffer
+	targets := append([]string(nil), outputs...)
+	sort.Strings(targets)
+	for i, t := range targets {
+		if i > 0 {
+			buf.WriteString(" \\\n ")
+		}
+		buf.WriteString(escapeMakePath(t))
+	}
+	buf.WriteByte(':')
+	for _, f := range sources {
+		if f.path == "" {
+			continue
+		}
+		buf.WriteString(" \\\n ")
+		buf.WriteString(escapeMakePath(f.path))
+	}
+	buf.WriteByte('\n')
+	return os.WriteFile(fileName, buf.Bytes(), 0666)
+}
+
+func escapeMakePath(path string) string {
+	return strings.NewReplacer(" ", `\ `, "#", `\#`, "$", "$$").Replace(path)
+}
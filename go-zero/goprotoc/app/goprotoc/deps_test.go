@@ -0,0 +1,121 @@
+package goprotoc
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSourceSetClosure(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	writeSources(t, dir, map[string]string{
+		"a.proto": breakingHeader + "import \"b.proto\";\n  import public 'c/c.proto' ;\nimport \"google/protobuf/empty.proto\";\n",
+		"b.proto": breakingHeader + "import weak \"c/c.proto\";\n// import \"x.proto\"; is not an import\n",
+		// a cycle does not loop forever
+		"c/c.proto": breakingHeader + "import \"a.proto\";\n",
+		"d.proto":   breakingHeader,
+	})
+	// the first import path that has the file wins
+	writeSources(t, other, map[string]string{"b.proto": breakingHeader, "e.proto": breakingHeader})
+
+	s := newSourceSet([]string{dir, other})
+	files, err := s.closure("a.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, paths []string
+	for _, f := range files {
+		names = append(names, f.name)
+		paths = append(paths, f.path)
+	}
+	if expected := []string{"a.proto", "b.proto", "c/c.proto", "google/protobuf/empty.proto"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("wrong closure: expected %v, got %v", expected, names)
+	}
+	expected := []string{filepath.Join(dir, "a.proto"), filepath.Join(dir, "b.proto"), filepath.Join(dir, "c", "c.proto"), ""}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("wrong paths: expected %v, got %v", expected, paths)
+	}
+	if files[0].hash == "" || files[0].hash == files[1].hash || files[3].hash != "" {
+		t.Errorf("wrong hashes: %q %q %q", files[0].hash, files[1].hash, files[3].hash)
+	}
+
+	files, err = s.closure("d.proto", "e.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[1].path != filepath.Join(other, "e.proto") {
+		t.Errorf("wrong closure of d and e: %+v", files)
+	}
+}
+
+func TestWriteDepsFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "out.d")
+	sources := []*sourceFile{
+		{name: "a.proto", path: "protos/a b.proto"},
+		{name: "google/protobuf/empty.proto"},
+		{name: "c.proto", path: "protos/c#$.proto"},
+	}
+	if err := writeDepsFile(fileName, []string{"out/b.pb.go", "out/a.pb.go"}, sources); err != nil {
+		t.Fatal(err)
+	}
+	expected := "out/a.pb.go \\\n out/b.pb.go: \\\n protos/a\\ b.proto \\\n protos/c\\#$$.proto\n"
+	if s := readTestFile(t, fileName); s != expected {
+		t.Errorf("wrong deps file:\nexpected %q\ngot      %q", expected, s)
+	}
+}
+
+func TestDepsOutput(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeSources(t, dir, map[string]string{
+		"a.proto": breakingHeader + "import \"b.proto\";\nmessage A { B b = 1; }\n",
+		"b.proto": breakingHeader + "message B {}\n",
+	})
+	if err := os.Mkdir("out", 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the outputs are absolute, the sources are relative to the import path
+	a, b := filepath.Join(dir, "out", "a.proto.txt"), filepath.Join(dir, "out", "b.proto.txt")
+	runs := []struct {
+		name string
+		args []string
+		deps string
+	}{
+		{
+			name: "no cache",
+			args: []string{"a.proto"},
+			deps: a + ": \\\n a.proto \\\n b.proto\n",
+		},
+		{
+			name: "cache",
+			args: []string{"--cache_dir=cache", "a.proto", "b.proto"},
+			deps: a + " \\\n " + b + ": \\\n a.proto \\\n b.proto\n",
+		},
+		{
+			// the outputs recorded in the cache are listed although none was generated again
+			name: "cache unchanged",
+			args: []string{"--cache_dir=cache", "a.proto", "b.proto"},
+			deps: a + " \\\n " + b + ": \\\n a.proto \\\n b.proto\n",
+		},
+	}
+	for _, r := range runs {
+		if err := os.Remove("out.d"); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		args := append([]string{"goprotoc", "--proto_path=.", "--cachetest_out=out", "--deps=out.d"}, r.args...)
+		if err := run(args, nil, io.Discard, io.Discard); err != nil {
+			t.Fatalf("%s: %v", r.name, err)
+		}
+		if s := readTestFile(t, "out.d"); s != r.deps {
+			t.Errorf("%s: wrong deps file:\nexpected %q\ngot      %q", r.name, r.deps, s)
+		}
+	}
+	if err := run([]string{"goprotoc", "--proto_path=.", "--deps=out.d", "a.proto"}, nil, io.Discard, io.Discard); err == nil ||
+		!strings.Contains(err.Error(), "Can only use --deps when generating code or descriptors.") {
+		t.Errorf("expected error for --deps without outputs, got %v", err)
+	}
+}
@@ -64,13 +64,39 @@ func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) err
 		return errors.New("When using --decode_raw, no input files should be given.")
 	}
 
+	doingCodeGen := len(opts.output) > 0 || opts.outputDescriptor != ""
+	if opts.depsFile != "" && !doingCodeGen {
+		return errors.New("Can only use --deps when generating code or descriptors.")
+	}
+	if opts.cacheDir != "" && len(opts.output) == 0 {
+		return errors.New("Can only use --cache_dir when generating code.")
+	}
+	for lang := range opts.cacheAggregate {
+		if opts.cacheDir == "" {
+			return errors.New("Can only use --cache_aggregate with --cache_dir.")
+		}
+		if _, ok := opts.output[lang]; !ok {
+			return fmt.Errorf("--cache_aggregate=%s does not name an output: missing --%s_out.", lang, lang)
+		}
+	}
+	if len(opts.inputDescriptors) > 0 && (opts.depsFile != "" || opts.cacheDir != "") {
+		return errors.New("Cannot use --deps or --cache_dir with --descriptor_set_in.")
+	}
+
 	var fds []*desc.FileDescriptor
+	// genFds are the files passed to the plugins, the files that changed when a
+	// cache is used
+	var genFds []*desc.FileDescriptor
+	var sources *sourceSet
+	var cache *genCache
+	var cacheKeys map[string]string
 	if len(opts.protoFiles) > 0 {
 		if len(opts.inputDescriptors) > 0 {
 			var err error
 			if fds, err = loadDescriptors(opts.inputDescriptors, opts.protoFiles); err != nil {
 				return err
 			}
+			genFds = fds
 		} else {
 			includeSourceInfo := opts.includeSourceInfo
 			// We have to pass SourceCodeInfo to plugins as they expect this information to generate comments.
@@ -78,36 +104,45 @@ func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) err
 			// We could instead do a separate Parse if we wanted but the logic gets very complicated
 			// As we would want to make sure we are ONLY outputting to plugins and nothing else
 			// So that we don't have to parse twice in the general case.
-			if len(opts.output) > 0 {
+			// The breaking change and lint reports use the source info for the location of the issues.
+			if len(opts.output) > 0 || opts.breakingAgainst != "" || opts.lint {
 				includeSourceInfo = true
 			}
 			var err error
 			if opts.protoFiles, err = protoparse.ResolveFilenames(opts.includePaths, opts.protoFiles...); err != nil {
 				return err
 			}
-			var errs []error
-			p := protoparse.Parser{
-				ImportPaths:           opts.includePaths,
-				IncludeSourceCodeInfo: includeSourceInfo,
-				ErrorReporter: func(err protoparse.ErrorWithPos) error {
-					if len(errs) >= 20 {
-						return errors.New("Too many errors... aborting.")
+			sources = newSourceSet(opts.includePaths)
+			toParse := opts.protoFiles
+			if opts.cacheDir != "" {
+				if cache, cacheKeys, err = loadStaleFiles(&opts, sources); err != nil {
+					return err
+				}
+				// the other outputs, and the aggregate plugins once any file changed, need all of the files
+				onlyCodeGen := opts.outputDescriptor == "" && opts.breakingAgainst == "" && !opts.lint &&
+					!opts.printFreeFieldNumbers && opts.encodeType == "" && opts.decodeType == "" && !opts.decodeRaw
+				if onlyCodeGen && (len(cacheKeys) == 0 || len(opts.cacheAggregate) == 0) {
+					toParse = nil
+					for _, name := range opts.protoFiles {
+						if _, ok := cacheKeys[name]; ok {
+							toParse = append(toParse, name)
+						}
 					}
-					errs = append(errs, err)
-					return nil
-				},
+				}
 			}
-			if fds, err = p.ParseFiles(opts.protoFiles...); err != nil && err != protoparse.ErrInvalidSource {
-				errs = append(errs, err)
+			if len(toParse) > 0 {
+				if fds, err = parseFiles(opts.includePaths, includeSourceInfo, sources, toParse); err != nil {
+					return err
+				}
 			}
-			err = toError(errs)
-			if err != nil {
-				return err
+			for _, fd := range fds {
+				if _, ok := cacheKeys[fd.GetName()]; cache == nil || ok {
+					genFds = append(genFds, fd)
+				}
 			}
 		}
 	}
 
-	doingCodeGen := len(opts.output) > 0 || opts.outputDescriptor != ""
 	if doingCodeGen && opts.encodeType != "" {
 		return errors.New("Cannot use --encode and generate code or descriptors at the same time.")
 	}
@@ -118,6 +153,18 @@ func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) err
 		return errors.New("Only one of --encode and --decode can be specified.")
 	}
 
+	if opts.breakingAgainst != "" || opts.lint {
+		if opts.encodeType != "" || opts.decodeType != "" || opts.decodeRaw {
+			return errors.New("Cannot use --breaking_against or --lint with --encode or --decode.")
+		}
+		if err := doReport(&opts, fds, stdout); err != nil {
+			return err
+		}
+		if !doingCodeGen && !opts.printFreeFieldNumbers {
+			return nil
+		}
+	}
+
 	var err error
 	switch {
 	case opts.encodeType != "":
@@ -132,11 +179,35 @@ func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) err
 		if !doingCodeGen {
 			return errors.New("Missing output directives.")
 		}
-		if len(opts.output) > 0 {
-			err = doCodeGen(opts.output, fds, opts.pluginDefs)
+		var outputs []string
+		if len(opts.output) > 0 && (cache == nil || len(genFds) > 0) {
+			perFile, aggregate := splitOutputs(opts.output, opts.cacheAggregate)
+			if len(perFile) > 0 {
+				outputs, err = doCodeGen(perFile, genFds, opts.pluginDefs, cache)
+			}
+			if err == nil && len(aggregate) > 0 {
+				// the aggregate plugins generate their outputs from all of the files
+				var more []string
+				more, err = doCodeGen(aggregate, fds, opts.pluginDefs, cache)
+				outputs = append(outputs, more...)
+			}
+		}
+		if err == nil && cache != nil {
+			for name, key := range cacheKeys {
+				cache.update(name, key)
+			}
+			err = cache.save()
+			outputs = cache.outputs()
 		}
 		if err == nil && opts.outputDescriptor != "" {
 			err = saveDescriptor(opts.outputDescriptor, fds, opts.includeImports, opts.includeSourceInfo)
+			outputs = append(outputs, opts.outputDescriptor)
+		}
+		if err == nil && opts.depsFile != "" {
+			var deps []*sourceFile
+			if deps, err = sources.closure(opts.protoFiles...); err == nil {
+				err = writeDepsFile(opts.depsFile, outputs, deps)
+			}
 		}
 	}
 	return err
@@ -191,6 +262,45 @@ Parse PROTO_FILES and generate output based on the options given:
                               the same field number space with the parent
                               message. Extension ranges are counted as
                               occupied fields numbers.
+  --breaking_against=PATH     Report the changes of PROTO_FILES that are not
+                              wire compatible with their previous version:
+                              removed or renumbered fields, type changes,
+                              removed RPCs and changed streaming modes.
+                              PATH is either a FileDescriptorSet or a
+                              directory with the previous sources, such as
+                              a checkout of a git ref.  Exits with an error
+                              if any breaking change is found.
+  --lint                      Check the naming and package rules of
+                              PROTO_FILES.  Exits with an error if any
+                              issue of severity error is found.
+  --lint_config=FILE          A yaml file with the severity of the lint
+                              rules ('rules: {RULE: off|info|warning|error}')
+                              and the file path prefixes to skip
+                              ('ignore: [PREFIX]').
+  --lint_rule=RULE=SEVERITY   Override the severity of a lint rule.  May be
+                              specified multiple times.
+  --report_format=FORMAT      The format of the breaking change and lint
+                              reports, 'text' (the default) or 'json' (one
+                              object per line).
+  --cache_dir=DIR             Cache the inputs of the code generation in DIR.
+                              A file whose sources, transitive imports,
+                              plugins and plugin parameters are unchanged
+                              since the last run is neither parsed nor
+                              passed to the plugins again, and a generated
+                              file is not written if its contents are
+                              unchanged.  The plugins are only passed the
+                              files that changed, see --cache_aggregate.
+                              Cannot be used with zip or jar outputs.
+  --cache_aggregate=LANG      With --cache_dir, the plugin of --LANG_out
+                              generates outputs from all of PROTO_FILES
+                              together, like an index or a merged OpenAPI
+                              document, so it is passed all of the files
+                              whenever any of them changed instead of only
+                              the changed ones.  May be specified multiple
+                              times.
+  --deps=FILE,                Write a dependency file in the format expected
+    --dependency_out=FILE     by make: the generated files depend on the
+                              transitive set of their source files.
   --plugin=EXECUTABLE         Specifies a plugin executable to use.
                               Normally, protoc searches the PATH for
                               plugins, but you may specify additional
@@ -0,0 +1,211 @@
+package goprotoc
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"gopkg.in/yaml.v2"
+)
+
+// lintRules are the lint rules and their default severity.
+var lintRules = map[string]severity{
+	"PACKAGE_DEFINED":             severityError,
+	"PACKAGE_LOWER_SNAKE_CASE":    severityError,
+	"PACKAGE_DIRECTORY_MATCH":     severityOff,
+	"GO_PACKAGE_DEFINED":          severityError,
+	"MESSAGE_PASCAL_CASE":         severityError,
+	"FIELD_LOWER_SNAKE_CASE":      severityWarning,
+	"ENUM_PASCAL_CASE":            severityError,
+	"ENUM_VALUE_UPPER_SNAKE_CASE": severityError,
+	"ENUM_ZERO_VALUE_SUFFIX":      severityOff,
+	"SERVICE_PASCAL_CASE":         severityError,
+	"RPC_PASCAL_CASE":             severityError,
+	"RPC_REQUEST_STANDARD_NAME":   severityWarning,
+	"RPC_RESPONSE_STANDARD_NAME":  severityWarning,
+}
+
+var (
+	lowerSnakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	upperSnakeCase = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+	pascalCase     = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+	packageName    = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)*$`)
+)
+
+// lintConfig is the content of the --lint_config file:
+//
+//	rules:
+//	  FIELD_LOWER_SNAKE_CASE: off
+//	  PACKAGE_DIRECTORY_MATCH: error
+//	ignore:
+//	  - third_party/
+type lintConfig struct {
+	Rules  map[string]string `yaml:"rules,omitempty"`
+	Ignore []string          `yaml:"ignore,omitempty"`
+}
+
+type linter struct {
+	severities map[string]severity
+	ignore     []string
+}
+
+// newLinter creates a linter from the default severities, overridden by the config file and then
+// by the --lint_rule flags.
+func newLinter(configFile string, rules map[string]string) (*linter, error) {
+	l := &linter{severities: map[string]severity{}}
+	for rule, sev := range lintRules {
+		l.severities[rule] = sev
+	}
+	if configFile != "" {
+		b, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load lint config %s: %v", configFile, err)
+		}
+		var conf lintConfig
+		if err := yaml.UnmarshalStrict(b, &conf); err != nil {
+			return nil, fmt.Errorf("could not parse lint config %s: %v", configFile, err)
+		}
+		if err := l.setSeverities(conf.Rules); err != nil {
+			return nil, fmt.Errorf("%s: %v", configFile, err)
+		}
+		l.ignore = conf.Ignore
+	}
+	if err := l.setSeverities(rules); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *linter) setSeverities(rules map[string]string) error {
+	names := make([]string, 0, len(rules))
+	for rule := range rules {
+		names = append(names, rule)
+	}
+	sort.Strings(names)
+	for _, rule := range names {
+		if _, ok := lintRules[rule]; !ok {
+			return fmt.Errorf("unknown lint rule %q", rule)
+		}
+		sev, err := parseSeverity(strings.ToLower(rules[rule]))
+		if err != nil {
+			return fmt.Errorf("lint rule %s: %v", rule, err)
+		}
+		l.severities[rule] = sev
+	}
+	return nil
+}
+
+func (l *linter) lint(fds []*desc.FileDescriptor) issues {
+	var is issues
+	for _, fd := range fds {
+		if l.ignored(fd.GetName()) {
+			continue
+		}
+		l.lintFile(&is, fd)
+	}
+	return is
+}
+
+func (l *linter) ignored(file string) bool {
+	for _, prefix := range l.ignore {
+		if strings.HasPrefix(file, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// report adds an issue unless the rule is turned off.
+func (l *linter) report(is *issues, file string, d desc.Descriptor, rule, format string, args ...interface{}) {
+	if sev := l.severities[rule]; sev != severityOff {
+		is.add(file, d, sev, rule, format, args...)
+	}
+}
+
+func (l *linter) lintFile(is *issues, fd *desc.FileDescriptor) {
+	file := fd.GetName()
+	pkg := fd.GetPackage()
+	if pkg == "" {
+		l.report(is, file, nil, "PACKAGE_DEFINED", "file has no package")
+	} else {
+		if !packageName.MatchString(pkg) {
+			l.report(is, file, nil, "PACKAGE_LOWER_SNAKE_CASE", "package %q should be lower_snake_case, separated by dots", pkg)
+		}
+		if dir := path.Dir(file); dir != strings.ReplaceAll(pkg, ".", "/") {
+			l.report(is, file, nil, "PACKAGE_DIRECTORY_MATCH", "package %q should be in directory %q, not %q",
+				pkg, strings.ReplaceAll(pkg, ".", "/"), dir)
+		}
+	}
+	if fd.GetFileOptions().GetGoPackage() == "" {
+		l.report(is, file, nil, "GO_PACKAGE_DEFINED", "file has no go_package option")
+	}
+
+	for _, md := range fd.GetMessageTypes() {
+		l.lintMessage(is, file, md)
+	}
+	for _, ed := range fd.GetEnumTypes() {
+		l.lintEnum(is, file, ed)
+	}
+	for _, sd := range fd.GetServices() {
+		if !pascalCase.MatchString(sd.GetName()) {
+			l.report(is, file, sd, "SERVICE_PASCAL_CASE", "service %q should be PascalCase", sd.GetName())
+		}
+		for _, mtd := range sd.GetMethods() {
+			l.lintMethod(is, file, mtd)
+		}
+	}
+}
+
+func (l *linter) lintMessage(is *issues, file string, md *desc.MessageDescriptor) {
+	if md.IsMapEntry() {
+		return
+	}
+	if !pascalCase.MatchString(md.GetName()) {
+		l.report(is, file, md, "MESSAGE_PASCAL_CASE", "message %q should be PascalCase", md.GetName())
+	}
+	for _, fld := range md.GetFields() {
+		if !lowerSnakeCase.MatchString(fld.GetName()) {
+			l.report(is, file, fld, "FIELD_LOWER_SNAKE_CASE", "field %q of %q should be lower_snake_case", fld.GetName(), md.GetName())
+		}
+	}
+	for _, nested := range md.GetNestedMessageTypes() {
+		l.lintMessage(is, file, nested)
+	}
+	for _, ed := range md.GetNestedEnumTypes() {
+		l.lintEnum(is, file, ed)
+	}
+}
+
+func (l *linter) lintEnum(is *issues, file string, ed *desc.EnumDescriptor) {
+	if !pascalCase.MatchString(ed.GetName()) {
+		l.report(is, file, ed, "ENUM_PASCAL_CASE", "enum %q should be PascalCase", ed.GetName())
+	}
+	for _, vd := range ed.GetValues() {
+		if !upperSnakeCase.MatchString(vd.GetName()) {
+			l.report(is, file, vd, "ENUM_VALUE_UPPER_SNAKE_CASE", "enum value %q should be UPPER_SNAKE_CASE", vd.GetName())
+		}
+		if vd.GetNumber() == 0 && !strings.HasSuffix(vd.GetName(), "_UNSPECIFIED") {
+			l.report(is, file, vd, "ENUM_ZERO_VALUE_SUFFIX", "zero value %q of enum %q should have the suffix _UNSPECIFIED", vd.GetName(), ed.GetName())
+		}
+	}
+}
+
+func (l *linter) lintMethod(is *issues, file string, mtd *desc.MethodDescriptor) {
+	name := mtd.GetName()
+	if !pascalCase.MatchString(name) {
+		l.report(is, file, mtd, "RPC_PASCAL_CASE", "rpc %q should be PascalCase", name)
+	}
+	// the go-zero convention is <Rpc>Req and <Rpc>Resp, the google one <Rpc>Request and <Rpc>Response
+	if in := mtd.GetInputType().GetName(); in != name+"Req" && in != name+"Request" {
+		l.report(is, file, mtd, "RPC_REQUEST_STANDARD_NAME", "request type of rpc %q should be named %sReq or %sRequest, not %q",
+			name, name, name, in)
+	}
+	if out := mtd.GetOutputType().GetName(); out != name+"Resp" && out != name+"Response" {
+		l.report(is, file, mtd, "RPC_RESPONSE_STANDARD_NAME", "response type of rpc %q should be named %sResp or %sResponse, not %q",
+			name, name, name, out)
+	}
+}
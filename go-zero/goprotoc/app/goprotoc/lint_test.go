@@ -0,0 +1,150 @@
+package goprotoc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const lintSource = `syntax = "proto3";
+package Bad.pkg;
+
+message bad_message {
+  int32 FieldOne = 1;
+  map<string, int32> counts = 2;
+  message inner { int32 x = 1; }
+}
+
+enum status {
+  OK = 0;
+  not_found = 1;
+}
+
+service user_service {
+  rpc get_user(bad_message) returns (bad_message);
+  rpc Ping(PingReq) returns (PingResponse);
+}
+
+message PingReq {}
+message PingResponse {}
+`
+
+func TestLintDefaultSeverities(t *testing.T) {
+	fds := mustParse(t, map[string]string{"api/bad.proto": lintSource})
+	l, err := newLinter("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]severity{}
+	counts := map[string]int{}
+	for _, i := range l.lint(fds) {
+		got[i.Rule] = i.Severity
+		counts[i.Rule]++
+		if i.Path != "api/bad.proto" {
+			t.Errorf("wrong path for %s: %s", i.Rule, i.Path)
+		}
+	}
+	expected := map[string]severity{
+		"PACKAGE_LOWER_SNAKE_CASE":    severityError,
+		"GO_PACKAGE_DEFINED":          severityError,
+		"MESSAGE_PASCAL_CASE":         severityError,
+		"FIELD_LOWER_SNAKE_CASE":      severityWarning,
+		"ENUM_PASCAL_CASE":            severityError,
+		"ENUM_VALUE_UPPER_SNAKE_CASE": severityError,
+		"SERVICE_PASCAL_CASE":         severityError,
+		"RPC_PASCAL_CASE":             severityError,
+		"RPC_REQUEST_STANDARD_NAME":   severityWarning,
+		"RPC_RESPONSE_STANDARD_NAME":  severityWarning,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong rules:\nexpected %v\ngot      %v", expected, got)
+	}
+	// message and nested message, but not the map entry
+	if counts["MESSAGE_PASCAL_CASE"] != 2 {
+		t.Errorf("expected 2 MESSAGE_PASCAL_CASE issues, got %d", counts["MESSAGE_PASCAL_CASE"])
+	}
+	// get_user request and response, Ping has a standard request and response
+	if counts["RPC_REQUEST_STANDARD_NAME"] != 1 || counts["RPC_RESPONSE_STANDARD_NAME"] != 1 {
+		t.Errorf("wrong standard name issues: %v", counts)
+	}
+}
+
+func TestLintSeverityOverrides(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "lint.yaml")
+	err := os.WriteFile(config, []byte(`rules:
+  FIELD_LOWER_SNAKE_CASE: error
+  ENUM_ZERO_VALUE_SUFFIX: warning
+  PACKAGE_DIRECTORY_MATCH: info
+  RPC_PASCAL_CASE: error
+ignore:
+  - third_party/
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the flags override the config file
+	l, err := newLinter(config, map[string]string{"RPC_PASCAL_CASE": "OFF", "GO_PACKAGE_DEFINED": "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fds := mustParse(t, map[string]string{
+		"api/bad.proto":         lintSource,
+		"third_party/bad.proto": strings.Replace(lintSource, "Bad.pkg", "third", 1),
+	})
+	got := map[string]severity{}
+	for _, i := range l.lint(fds) {
+		if strings.HasPrefix(i.Path, "third_party/") {
+			t.Errorf("ignored file reported: %v", i)
+		}
+		got[i.Rule] = i.Severity
+	}
+	for rule, sev := range map[string]severity{
+		"FIELD_LOWER_SNAKE_CASE":  severityError,
+		"ENUM_ZERO_VALUE_SUFFIX":  severityWarning,
+		"PACKAGE_DIRECTORY_MATCH": severityInfo,
+		"GO_PACKAGE_DEFINED":      severityInfo,
+		"RPC_PASCAL_CASE":         severityOff,
+	} {
+		if got[rule] != sev {
+			t.Errorf("wrong severity of %s: expected %v, got %v", rule, sev, got[rule])
+		}
+	}
+}
+
+func TestLintConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name   string
+		config string
+		rules  map[string]string
+		err    string
+	}{
+		{name: "unknown rule flag", rules: map[string]string{"NO_SUCH_RULE": "error"}, err: `unknown lint rule "NO_SUCH_RULE"`},
+		{name: "invalid severity flag", rules: map[string]string{"RPC_PASCAL_CASE": "fatal"}, err: `invalid severity "fatal"`},
+		{name: "unknown rule in config", config: "rules:\n  NO_SUCH_RULE: off\n", err: `unknown lint rule "NO_SUCH_RULE"`},
+		{name: "unknown key in config", config: "rulez:\n  RPC_PASCAL_CASE: off\n", err: "could not parse lint config"},
+		{name: "missing config", config: "-", err: "could not load lint config"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var config string
+			switch tc.config {
+			case "":
+			case "-":
+				config = filepath.Join(dir, "missing.yaml")
+			default:
+				config = filepath.Join(dir, strings.ReplaceAll(tc.name, " ", "_")+".yaml")
+				if err := os.WriteFile(config, []byte(tc.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := newLinter(config, tc.rules)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
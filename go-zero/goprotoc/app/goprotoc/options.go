@@ -18,6 +18,14 @@ type protocOptions struct {
 	includeImports        bool
 	includeSourceInfo     bool
 	printFreeFieldNumbers bool
+	breakingAgainst       string
+	lint                  bool
+	lintConfig            string
+	lintRules             map[string]string
+	reportFormat          string
+	cacheDir              string
+	cacheAggregate        map[string]struct{}
+	depsFile              string
 	pluginDefs            map[string]string
 	output                map[string]string
 	protoFiles            []string
@@ -150,6 +158,67 @@ func parseFlags(source string, programName string, args []string, stdout io.Writ
 				return err
 			}
 			opts.printFreeFieldNumbers = value
+		case "--breaking_against":
+			value, err := getOptionArg()
+			if err != nil {
+				return err
+			}
+			opts.breakingAgainst = value
+		case "--lint":
+			value, err := getBoolArg()
+			if err != nil {
+				return err
+			}
+			opts.lint = value
+		case "--lint_config":
+			value, err := getOptionArg()
+			if err != nil {
+				return err
+			}
+			opts.lintConfig = value
+		case "--lint_rule":
+			value, err := getOptionArg()
+			if err != nil {
+				return err
+			}
+			rule := strings.SplitN(value, "=", 2)
+			if len(rule) != 2 || rule[0] == "" {
+				return fmt.Errorf("%s--lint_rule argument must be of the form RULE=SEVERITY", loc())
+			}
+			if opts.lintRules == nil {
+				opts.lintRules = make(map[string]string, 1)
+			}
+			opts.lintRules[rule[0]] = rule[1]
+		case "--report_format":
+			value, err := getOptionArg()
+			if err != nil {
+				return err
+			}
+			if value != reportFormatText && value != reportFormatJSON {
+				return fmt.Errorf("%s--report_format must be 'text' or 'json'", loc())
+			}
+			opts.reportFormat = value
+		case "--cache_dir":
+			value, err := getOptionArg()
+			if err != nil {
+				return err
+			}
+			opts.cacheDir = value
+		case "--cache_aggregate":
+			value, err := getOptionArg()
+			if err != nil {
+				return err
+			}
+			if opts.cacheAggregate == nil {
+				opts.cacheAggregate = make(map[string]struct{}, 1)
+			}
+			opts.cacheAggregate[value] = struct{}{}
+		case "--deps", "--dependency_out":
+			value, err := getOptionArg()
+			if err != nil {
+				return err
+			}
+			opts.depsFile = value
 		case "--plugin":
 			value, err := getOptionArg()
 			if err != nil {
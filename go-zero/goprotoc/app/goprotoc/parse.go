@@ -0,0 +1,139 @@
+package goprotoc
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"golang.org/x/sync/errgroup"
+)
+
+const maxParseErrors = 20
+
+// parseFiles parses the given proto files. Files that do not import one another
+// are independent, so they are split into groups that are parsed in parallel.
+// The descriptors are returned in the order of the given files.
+func parseFiles(includePaths []string, includeSourceInfo bool, sources *sourceSet, files []string) ([]*desc.FileDescriptor, error) {
+	groups, err := parseGroups(sources, files, runtime.GOMAXPROCS(0))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]*desc.FileDescriptor, len(groups))
+	errs := make([][]error, len(groups))
+	var grp errgroup.Group
+	for i, group := range groups {
+		grp.Go(func() error {
+			p := protoparse.Parser{
+				ImportPaths:           includePaths,
+				IncludeSourceCodeInfo: includeSourceInfo,
+				ErrorReporter: func(err protoparse.ErrorWithPos) error {
+					if len(errs[i]) >= maxParseErrors {
+						return errors.New("Too many errors... aborting.")
+					}
+					errs[i] = append(errs[i], err)
+					return nil
+				},
+			}
+			fds, err := p.ParseFiles(group...)
+			if err != nil && err != protoparse.ErrInvalidSource {
+				errs[i] = append(errs[i], err)
+			}
+			results[i] = fds
+			return nil
+		})
+	}
+	_ = grp.Wait()
+
+	var allErrs []error
+	for _, e := range errs {
+		allErrs = append(allErrs, e...)
+	}
+	if len(allErrs) > maxParseErrors {
+		allErrs = append(allErrs[:maxParseErrors], errors.New("Too many errors... aborting."))
+	}
+	if err := toError(allErrs); err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*desc.FileDescriptor{}
+	for _, fds := range results {
+		for _, fd := range fds {
+			byName[fd.GetName()] = fd
+		}
+	}
+	fds := make([]*desc.FileDescriptor, len(files))
+	for i, name := range files {
+		fds[i] = byName[name]
+	}
+	return fds, nil
+}
+
+// parseGroups splits the files into at most n groups. A file and the files that
+// it transitively imports are always in the same group, so that they are parsed
+// once and share their descriptors.
+func parseGroups(sources *sourceSet, files []string, n int) ([][]string, error) {
+	if n <= 1 || len(files) <= 1 {
+		return [][]string{files}, nil
+	}
+
+	index := make(map[string]int, len(files))
+	for i, name := range files {
+		index[name] = i
+	}
+	parent := make([]int, len(files))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, name := range files {
+		deps, err := sources.closure(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deps {
+			if j, ok := index[dep.name]; ok {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	components := map[int][]string{}
+	for i, name := range files {
+		root := find(i)
+		components[root] = append(components[root], name)
+	}
+	sorted := make([][]string, 0, len(components))
+	for _, c := range components {
+		sorted = append(sorted, c)
+	}
+	// largest first, so that the groups are balanced
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i][0] < sorted[j][0]
+	})
+	if len(sorted) < n {
+		n = len(sorted)
+	}
+	groups := make([][]string, n)
+	for _, c := range sorted {
+		smallest := 0
+		for i := range groups {
+			if len(groups[i]) < len(groups[smallest]) {
+				smallest = i
+			}
+		}
+		groups[smallest] = append(groups[smallest], c...)
+	}
+	return groups, nil
+}
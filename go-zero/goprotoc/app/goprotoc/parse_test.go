@@ -0,0 +1,107 @@
+package goprotoc
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseGroups(t *testing.T) {
+	dir := t.TempDir()
+	writeSources(t, dir, map[string]string{
+		// a -> b -> c, d -> c, e and f are alone
+		"a.proto": breakingHeader + "import \"b.proto\";\n",
+		"b.proto": breakingHeader + "import \"c.proto\";\n",
+		"c.proto": breakingHeader,
+		"d.proto": breakingHeader + "import \"c.proto\";\n",
+		"e.proto": breakingHeader,
+		"f.proto": breakingHeader,
+	})
+	files := []string{"f.proto", "a.proto", "e.proto", "d.proto", "c.proto"}
+
+	testCases := []struct {
+		n      int
+		groups [][]string
+	}{
+		{n: 1, groups: [][]string{files}},
+		{n: 2, groups: [][]string{{"a.proto", "d.proto", "c.proto"}, {"f.proto", "e.proto"}}},
+		{n: 3, groups: [][]string{{"a.proto", "d.proto", "c.proto"}, {"e.proto"}, {"f.proto"}}},
+		{n: 8, groups: [][]string{{"a.proto", "d.proto", "c.proto"}, {"e.proto"}, {"f.proto"}}},
+	}
+	for _, tc := range testCases {
+		groups, err := parseGroups(newSourceSet([]string{dir}), files, tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the files that import one another are grouped, b is not given so it is in no group
+		for _, g := range groups {
+			sort.Strings(g)
+		}
+		for _, g := range tc.groups {
+			sort.Strings(g)
+		}
+		sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+		sort.Slice(tc.groups, func(i, j int) bool { return tc.groups[i][0] < tc.groups[j][0] })
+		if !reflect.DeepEqual(groups, tc.groups) {
+			t.Errorf("%d groups: expected %v, got %v", tc.n, tc.groups, groups)
+		}
+	}
+}
+
+func TestParseFilesOrder(t *testing.T) {
+	dir := t.TempDir()
+	sources := map[string]string{}
+	var files []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("f%02d.proto", i)
+		src := breakingHeader + fmt.Sprintf("message M%02d {}\n", i)
+		// every other file imports the next one
+		if i%2 == 0 {
+			src = breakingHeader + fmt.Sprintf("import \"f%02d.proto\";\nmessage M%02d { M%02d m = 1; }\n", i+1, i, i+1)
+		}
+		sources[name] = src
+		files = append(files, name)
+	}
+	writeSources(t, dir, sources)
+	// reversed, so that the order differs from the groups
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+
+	for i := 0; i < 5; i++ {
+		fds, err := parseFiles([]string{dir}, false, newSourceSet([]string{dir}), files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, fd := range fds {
+			if fd.GetName() != files[j] {
+				t.Fatalf("file %d: expected %s, got %s", j, files[j], fd.GetName())
+			}
+			// an imported file is parsed once, in the group of its importer
+			for _, dep := range fd.GetDependencies() {
+				if k := sort.Search(len(files), func(k int) bool { return files[k] <= dep.GetName() }); fds[k] != dep {
+					t.Errorf("%s imports another descriptor of %s", fd.GetName(), dep.GetName())
+				}
+			}
+		}
+	}
+}
+
+func TestParseFilesErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeSources(t, dir, map[string]string{
+		"a.proto": breakingHeader + "message A { Missing m = 1; }\n",
+		"b.proto": breakingHeader + "message B { Other o = 1; }\n",
+		"c.proto": breakingHeader + "message C {}\n",
+	})
+	_, err := parseFiles([]string{dir}, false, newSourceSet([]string{dir}), []string{"a.proto", "b.proto", "c.proto"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	// the errors of all of the groups are reported
+	for _, s := range []string{"a.proto", "Missing", "b.proto", "Other"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error does not contain %q: %v", s, err)
+		}
+	}
+}
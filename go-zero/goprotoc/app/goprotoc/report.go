@@ -0,0 +1,155 @@
+package goprotoc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/jhump/protoreflect/desc"
+)
+
+const (
+	reportFormatText = "text"
+	reportFormatJSON = "json"
+)
+
+// severity of a reported issue, in increasing order.
+type severity int
+
+const (
+	severityOff severity = iota
+	severityInfo
+	severityWarning
+	severityError
+)
+
+var severityNames = map[severity]string{
+	severityOff:     "off",
+	severityInfo:    "info",
+	severityWarning: "warning",
+	severityError:   "error",
+}
+
+func parseSeverity(s string) (severity, error) {
+	for sev, name := range severityNames {
+		if name == s {
+			return sev, nil
+		}
+	}
+	return severityOff, fmt.Errorf("invalid severity %q: must be one of off, info, warning, error", s)
+}
+
+func (s severity) String() string {
+	return severityNames[s]
+}
+
+func (s severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// issue is a breaking change or a lint violation.
+type issue struct {
+	Path     string   `json:"path"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity severity `json:"severity"`
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
+}
+
+type issues []issue
+
+// add records an issue located at the given descriptor; d may be nil, in which case the
+// issue is reported against the file.
+func (is *issues) add(file string, d desc.Descriptor, sev severity, rule, format string, args ...interface{}) {
+	i := issue{
+		Path:     file,
+		Severity: sev,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if d != nil {
+		if loc := d.GetSourceInfo(); loc != nil && len(loc.Span) >= 2 {
+			i.Line = int(loc.Span[0]) + 1
+			i.Column = int(loc.Span[1]) + 1
+		}
+	}
+	*is = append(*is, i)
+}
+
+func (is issues) count(min severity) int {
+	n := 0
+	for _, i := range is {
+		if i.Severity >= min {
+			n++
+		}
+	}
+	return n
+}
+
+// print writes the issues sorted by location, one per line in text format or as JSON lines.
+func (is issues) print(w io.Writer, format string) error {
+	sort.SliceStable(is, func(a, b int) bool {
+		if is[a].Path != is[b].Path {
+			return is[a].Path < is[b].Path
+		}
+		if is[a].Line != is[b].Line {
+			return is[a].Line < is[b].Line
+		}
+		return is[a].Column < is[b].Column
+	})
+	for _, i := range is {
+		var err error
+		switch format {
+		case reportFormatJSON:
+			var b []byte
+			if b, err = json.Marshal(i); err == nil {
+				_, err = fmt.Fprintf(w, "%s\n", b)
+			}
+		default:
+			pos := i.Path
+			if i.Line > 0 {
+				pos = fmt.Sprintf("%s:%d:%d", i.Path, i.Line, i.Column)
+			}
+			_, err = fmt.Fprintf(w, "%s: %s: %s (%s)\n", pos, i.Severity, i.Message, i.Rule)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doReport runs the breaking change check and the linter requested by the options, prints the issues
+// and returns an error if any of them must fail the build.
+func doReport(opts *protocOptions, fds []*desc.FileDescriptor, w io.Writer) error {
+	var breaking, lint issues
+	if opts.breakingAgainst != "" {
+		var err error
+		if breaking, err = doBreakingCheck(opts.breakingAgainst, opts.includePaths, fds); err != nil {
+			return err
+		}
+	}
+	if opts.lint {
+		l, err := newLinter(opts.lintConfig, opts.lintRules)
+		if err != nil {
+			return err
+		}
+		lint = l.lint(fds)
+	}
+
+	all := make(issues, 0, len(breaking)+len(lint))
+	all = append(append(all, breaking...), lint...)
+	if err := all.print(w, opts.reportFormat); err != nil {
+		return err
+	}
+	var errs []error
+	if n := breaking.count(severityError); n > 0 {
+		errs = append(errs, fmt.Errorf("%d breaking changes found", n))
+	}
+	if n := lint.count(severityError); n > 0 {
+		errs = append(errs, fmt.Errorf("%d lint errors found", n))
+	}
+	return toError(errs)
+}
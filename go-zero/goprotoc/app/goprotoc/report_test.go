@@ -0,0 +1,141 @@
+package goprotoc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestIssuesPrint(t *testing.T) {
+	is := issues{
+		{Path: "b.proto", Line: 2, Column: 1, Severity: severityError, Rule: "FIELD_REMOVED", Message: "field removed"},
+		{Path: "a.proto", Line: 10, Column: 3, Severity: severityWarning, Rule: "FIELD_LOWER_SNAKE_CASE", Message: "bad name"},
+		{Path: "a.proto", Severity: severityError, Rule: "GO_PACKAGE_DEFINED", Message: "no go_package"},
+		{Path: "a.proto", Line: 10, Column: 1, Severity: severityInfo, Rule: "RPC_PASCAL_CASE", Message: `rpc "x"`},
+	}
+
+	var buf bytes.Buffer
+	if err := is.print(&buf, reportFormatText); err != nil {
+		t.Fatal(err)
+	}
+	expected := `a.proto: error: no go_package (GO_PACKAGE_DEFINED)
+a.proto:10:1: info: rpc "x" (RPC_PASCAL_CASE)
+a.proto:10:3: warning: bad name (FIELD_LOWER_SNAKE_CASE)
+b.proto:2:1: error: field removed (FIELD_REMOVED)
+`
+	if buf.String() != expected {
+		t.Errorf("wrong text output:\nexpected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := is.print(&buf, reportFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"path":"a.proto","severity":"error","rule":"GO_PACKAGE_DEFINED","message":"no go_package"}
+{"path":"a.proto","line":10,"column":1,"severity":"info","rule":"RPC_PASCAL_CASE","message":"rpc \"x\""}
+{"path":"a.proto","line":10,"column":3,"severity":"warning","rule":"FIELD_LOWER_SNAKE_CASE","message":"bad name"}
+{"path":"b.proto","line":2,"column":1,"severity":"error","rule":"FIELD_REMOVED","message":"field removed"}
+`
+	if buf.String() != expected {
+		t.Errorf("wrong json output:\nexpected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, sev := range []severity{severityOff, severityInfo, severityWarning, severityError} {
+		got, err := parseSeverity(sev.String())
+		if err != nil || got != sev {
+			t.Errorf("parseSeverity(%q) = %v, %v", sev.String(), got, err)
+		}
+	}
+	if _, err := parseSeverity("fatal"); err == nil {
+		t.Error("expected error for invalid severity")
+	}
+}
+
+func TestDoReport(t *testing.T) {
+	before := map[string]string{"api/svc.proto": breakingHeader + `option go_package = "api";
+message GetReq { int32 id = 1; int32 old = 2; }
+message GetResp {}
+service Svc { rpc Get(GetReq) returns (GetResp); }
+`}
+	after := map[string]string{"api/svc.proto": breakingHeader + `option go_package = "api";
+message GetReq { int32 id = 1; int32 userName = 3; }
+message GetResp {}
+service Svc { rpc Get(GetReq) returns (GetResp); }
+`}
+	dir := t.TempDir()
+	writeSources(t, dir, before)
+	fds := mustParse(t, after)
+
+	testCases := []struct {
+		name   string
+		opts   protocOptions
+		rules  []string
+		errors []string
+	}{
+		{
+			name:   "breaking",
+			opts:   protocOptions{breakingAgainst: dir},
+			rules:  []string{"FIELD_REMOVED"},
+			errors: []string{"1 breaking changes found"},
+		},
+		{
+			name:  "lint warnings pass",
+			opts:  protocOptions{lint: true},
+			rules: []string{"FIELD_LOWER_SNAKE_CASE"},
+		},
+		{
+			name:   "lint errors fail",
+			opts:   protocOptions{lint: true, lintRules: map[string]string{"FIELD_LOWER_SNAKE_CASE": "error"}},
+			rules:  []string{"FIELD_LOWER_SNAKE_CASE"},
+			errors: []string{"1 lint errors found"},
+		},
+		{
+			name:   "both",
+			opts:   protocOptions{breakingAgainst: dir, lint: true},
+			rules:  []string{"FIELD_REMOVED", "FIELD_LOWER_SNAKE_CASE"},
+			errors: []string{"1 breaking changes found"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.reportFormat = reportFormatJSON
+			var buf bytes.Buffer
+			err := doReport(&tc.opts, fds, &buf)
+
+			var got []string
+			scanner := bufio.NewScanner(&buf)
+			for scanner.Scan() {
+				var i struct {
+					Path string
+					Line int
+					Rule string
+				}
+				if err := json.Unmarshal(scanner.Bytes(), &i); err != nil {
+					t.Fatalf("invalid json line %q: %v", scanner.Text(), err)
+				}
+				if i.Path != "api/svc.proto" || i.Line == 0 {
+					t.Errorf("wrong location: %s", scanner.Text())
+				}
+				got = append(got, i.Rule)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.rules, ",") {
+				t.Errorf("wrong rules: expected %v, got %v", tc.rules, got)
+			}
+			if len(tc.errors) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			for _, e := range tc.errors {
+				if err == nil || !strings.Contains(err.Error(), e) {
+					t.Errorf("expected error containing %q, got %v", e, err)
+				}
+			}
+		})
+	}
+}
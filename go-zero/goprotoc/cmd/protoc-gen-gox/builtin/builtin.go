@@ -0,0 +1,125 @@
+// Package builtin contains the code generators that are compiled into the
+// protoc-gen-gox program, so that generating the code of a go-zero RPC service
+// does not need any other executable:
+//
+//	go            the messages, like protoc-gen-go
+//	grpc          the gRPC client and server stubs, like protoc-gen-go-grpc
+//	zrpc-server   the zrpc servers (internal/server), like goctl
+//	zrpc-logic    the logic stubs (internal/logic), only for new RPCs
+//	zrpc-svc      the service context, config, etc yaml and main, only if missing
+//	zrpc-call     the zrpc clients
+//	zrpc-gateway  REST routes mapped from google.api.http annotations
+//
+// The zrpc generators produce the same layout as `goctl rpc protoc`, from the
+// same templates. The plugins are registered with goxplugin.Register when the
+// package is linked in, so the zrpc ones are run in-process when enabled,
+// unless a location is configured for them. The go and grpc ones are only run
+// in-process with the "builtin" location, otherwise protoc-gen-go and
+// protoc-gen-go-grpc are run as usual.
+package builtin
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/jhump/goprotoc/cmd/protoc-gen-gox/goxplugin"
+	"github.com/jhump/goprotoc/plugins"
+)
+
+func init() {
+	goxplugin.Register("go", doGo)
+	goxplugin.Register("grpc", doGrpc)
+	goxplugin.Register("zrpc-server", zrpcPlugin(genServer))
+	goxplugin.Register("zrpc-logic", zrpcPlugin(genLogic))
+	goxplugin.Register("zrpc-svc", zrpcPlugin(genSvc))
+	goxplugin.Register("zrpc-call", zrpcPlugin(genCall))
+	goxplugin.Register("zrpc-gateway", zrpcPlugin(genGateway))
+}
+
+// newPlugin converts the request into a protogen plugin, so that the generators
+// compute the same Go names and import paths as protoc-gen-go. The args are the
+// protoc-gen-go parameters, such as "paths=source_relative" or "M<file>=<pkg>".
+func newPlugin(req *plugins.CodeGenRequest, args []string) (*protogen.Plugin, error) {
+	cgr := &pluginpb.CodeGeneratorRequest{
+		Parameter: proto.String(strings.Join(args, ",")),
+		CompilerVersion: &pluginpb.Version{
+			Major:  proto.Int32(int32(req.ProtocVersion.Major)),
+			Minor:  proto.Int32(int32(req.ProtocVersion.Minor)),
+			Patch:  proto.Int32(int32(req.ProtocVersion.Patch)),
+			Suffix: proto.String(req.ProtocVersion.Suffix),
+		},
+	}
+	added := map[string]bool{}
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		if added[fd.GetName()] {
+			return
+		}
+		added[fd.GetName()] = true
+		// dependencies must come before the files that import them
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+		cgr.ProtoFile = append(cgr.ProtoFile, fd.AsFileDescriptorProto())
+	}
+	for _, fd := range req.Files {
+		add(fd)
+		cgr.FileToGenerate = append(cgr.FileToGenerate, fd.GetName())
+	}
+	return protogen.Options{}.New(cgr)
+}
+
+// writeResponse copies the files generated by the protogen plugin to the response.
+func writeResponse(gen *protogen.Plugin, resp *plugins.CodeGenResponse) error {
+	out := gen.Response()
+	if out.Error != nil {
+		return errors.New(out.GetError())
+	}
+	for _, f := range out.File {
+		w := resp.OutputSnippet(f.GetName(), f.GetInsertionPoint())
+		if _, err := io.WriteString(w, f.GetContent()); err != nil {
+			return err
+		}
+	}
+	for _, feature := range []pluginpb.CodeGeneratorResponse_Feature{
+		pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL,
+		pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS,
+	} {
+		if out.GetSupportedFeatures()&uint64(feature) != 0 {
+			resp.SupportsFeatures(feature)
+		}
+	}
+	return nil
+}
+
+// splitArgs returns the value of the named args, and the other args.
+func splitArgs(args []string, names ...string) (map[string]string, []string) {
+	own := map[string]string{}
+	var others []string
+	for _, a := range args {
+		kv := strings.SplitN(a, "=", 2)
+		found := false
+		for _, name := range names {
+			if kv[0] == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			others = append(others, a)
+			continue
+		}
+		if len(kv) == 1 {
+			own[kv[0]] = ""
+		} else {
+			own[kv[0]] = kv[1]
+		}
+	}
+	return own, others
+}
@@ -0,0 +1,157 @@
+package builtin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gozero "github.com/magic-lib/go-servicekit/go-zero"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestFormatName(t *testing.T) {
+	testCases := []struct {
+		style, name, expectedResult string
+	}{
+		{style: "go_zero", name: "UserServiceServer", expectedResult: "user_service_server"},
+		{style: "gozero", name: "UserServiceServer", expectedResult: "userserviceserver"},
+		{style: "goZero", name: "GetUser_logic", expectedResult: "getUserLogic"},
+		{style: "GoZero", name: "HTTPServer", expectedResult: "HttpServer"},
+		{style: "go-zero", name: "user_v2Api", expectedResult: "user-v2-api"},
+	}
+	for _, tc := range testCases {
+		result, err := formatName(tc.style, tc.name)
+		if err != nil {
+			t.Errorf("formatName(%q, %q): %v", tc.style, tc.name, err)
+		} else if result != tc.expectedResult {
+			t.Errorf("formatName(%q, %q): expected %q, got %q", tc.style, tc.name, tc.expectedResult, result)
+		}
+	}
+
+	if _, err := formatName("snake", "x"); err == nil {
+		t.Error("expected an error for an invalid style")
+	}
+}
+
+func TestRoutePath(t *testing.T) {
+	testCases := []struct {
+		template     string
+		expectedPath string
+		expectedVars []string
+		expectedErr  bool
+	}{
+		{template: "/v1/users", expectedPath: "/v1/users"},
+		{template: "/v1/users/{id}", expectedPath: "/v1/users/:id", expectedVars: []string{"id"}},
+		{template: "/v1/{user.id=*}/books/{book}", expectedPath: "/v1/:user.id/books/:book", expectedVars: []string{"user.id", "book"}},
+		{template: "/v1/{name=shelves/*}", expectedErr: true},
+		{template: "/v1/users/{id}:get", expectedErr: true},
+		{template: "/v1/**", expectedErr: true},
+		{template: "v1/users", expectedErr: true},
+	}
+	for _, tc := range testCases {
+		path, vars, err := routePath(tc.template)
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("routePath(%q): expected an error", tc.template)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("routePath(%q): %v", tc.template, err)
+			continue
+		}
+		if path != tc.expectedPath || !reflect.DeepEqual(vars, tc.expectedVars) {
+			t.Errorf("routePath(%q): expected %q %v, got %q %v", tc.template, tc.expectedPath, tc.expectedVars, path, vars)
+		}
+	}
+}
+
+func TestGetHTTPRule(t *testing.T) {
+	var custom []byte
+	custom = protowire.AppendTag(custom, 1, protowire.BytesType)
+	custom = protowire.AppendString(custom, "head")
+	custom = protowire.AppendTag(custom, 2, protowire.BytesType)
+	custom = protowire.AppendString(custom, "/v1/users/{id}")
+
+	var additional []byte
+	additional = protowire.AppendTag(additional, 8, protowire.BytesType)
+	additional = protowire.AppendBytes(additional, custom)
+
+	var rule []byte
+	rule = protowire.AppendTag(rule, 4, protowire.BytesType)
+	rule = protowire.AppendString(rule, "/v1/users")
+	rule = protowire.AppendTag(rule, 7, protowire.BytesType)
+	rule = protowire.AppendString(rule, "*")
+	rule = protowire.AppendTag(rule, 11, protowire.BytesType)
+	rule = protowire.AppendBytes(rule, additional)
+
+	var unknown []byte
+	unknown = protowire.AppendTag(unknown, httpRuleField, protowire.BytesType)
+	unknown = protowire.AppendBytes(unknown, rule)
+	opts := &descriptorpb.MethodOptions{}
+	opts.ProtoReflect().SetUnknown(unknown)
+
+	r, err := getHTTPRule(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &httpRule{
+		method: "POST",
+		path:   "/v1/users",
+		body:   "*",
+		additional: []*httpRule{
+			{method: "HEAD", path: "/v1/users/{id}"},
+		},
+	}
+	if !reflect.DeepEqual(r, expected) {
+		t.Errorf("expected %+v, got %+v", expected, r)
+	}
+	if len(r.bindings()) != 2 {
+		t.Errorf("expected 2 bindings, got %d", len(r.bindings()))
+	}
+
+	if r, err := getHTTPRule(&descriptorpb.MethodOptions{}); err != nil || r != nil {
+		t.Errorf("expected no rule, got %+v, %v", r, err)
+	}
+}
+
+func TestReadTemplate(t *testing.T) {
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, "rpc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, "rpc", "main.tpl"), []byte("custom"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	shared, err := gozero.Files.ReadFile("goctl-tmpl/rpc/server.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := &zrpcGenerator{home: home}
+	testCases := []struct {
+		name, expectedResult string
+	}{
+		{name: "main.tpl", expectedResult: "custom"},
+		{name: "server.tpl", expectedResult: string(shared)},
+	}
+	for _, tc := range testCases {
+		text, err := z.readTemplate(tc.name)
+		if err != nil {
+			t.Errorf("readTemplate(%q): %v", tc.name, err)
+		} else if string(text) != tc.expectedResult {
+			t.Errorf("readTemplate(%q): expected %q, got %q", tc.name, tc.expectedResult, text)
+		}
+	}
+
+	// the client funcs are only in the builtin templates
+	if _, err := z.readTemplate("call-func.tpl"); err != nil {
+		t.Errorf("readTemplate(%q): %v", "call-func.tpl", err)
+	}
+	if _, err := z.readTemplate("missing.tpl"); err == nil {
+		t.Errorf("readTemplate(%q): expected an error", "missing.tpl")
+	}
+}
@@ -0,0 +1,489 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	gatewayDir = "internal/gateway"
+
+	httpPackage    = protogen.GoImportPath("net/http")
+	restPackage    = protogen.GoImportPath("github.com/zeromicro/go-zero/rest")
+	pathvarPackage = protogen.GoImportPath("github.com/zeromicro/go-zero/rest/pathvar")
+)
+
+// genGateway generates the REST routes of the RPCs with a google.api.http
+// annotation, in the style of grpc-gateway: the path variables, the query
+// parameters and the JSON body are bound to the request message, and the
+// response message is written as JSON. Only the variables that match a whole
+// path segment are supported, as they are mapped to go-zero path parameters,
+// and only the scalar fields of the request message are bound to the query
+// parameters.
+func genGateway(zf *zrpcFile) error {
+	importPath := protogen.GoImportPath(zf.module + "/" + gatewayDir)
+	var g *protogen.GeneratedFile
+	for _, svc := range zf.file.Services {
+		var routes []gatewayRoute
+		for _, m := range svc.Methods {
+			opts, _ := m.Desc.Options().(*descriptorpb.MethodOptions)
+			rule, err := getHTTPRule(opts)
+			if err != nil {
+				return fmt.Errorf("%s: invalid google.api.http option: %v", m.Desc.FullName(), err)
+			}
+			if rule == nil {
+				continue
+			}
+			if isStreaming(m) {
+				return fmt.Errorf("%s: streaming rpcs can't be mapped to REST routes", m.Desc.FullName())
+			}
+			for i, binding := range rule.bindings() {
+				name := unexport(svc.GoName) + m.GoName + "Handler"
+				if i > 0 {
+					name += fmt.Sprint(i)
+				}
+				routes = append(routes, gatewayRoute{method: m, rule: binding, handler: name})
+			}
+		}
+		if len(routes) == 0 {
+			continue
+		}
+		if g == nil {
+			g = zf.gen.NewGeneratedFile(gatewayDir+"/"+zf.fileName(zf.name+"Gateway")+".go", importPath)
+			g.P(zf.head(zf.file))
+			g.P()
+			g.P("package gateway")
+			g.P()
+		}
+		if err := zf.genGatewayService(g, svc, routes); err != nil {
+			return err
+		}
+	}
+	if g != nil && !zf.generated[gatewayDir+"/gateway.go"] {
+		zf.write(gatewayDir+"/gateway.go", string(importPath), gatewayHelpers)
+	}
+	return nil
+}
+
+type gatewayRoute struct {
+	method  *protogen.Method
+	rule    *httpRule
+	handler string
+}
+
+// ident resolves the relative import path of the identifier.
+func (zf *zrpcFile) ident(id protogen.GoIdent) protogen.GoIdent {
+	id.GoImportPath = protogen.GoImportPath(zf.importPath(id.GoImportPath))
+	return id
+}
+
+func (zf *zrpcFile) genGatewayService(g *protogen.GeneratedFile, svc *protogen.Service, routes []gatewayRoute) error {
+	client := zf.ident(protogen.GoIdent{GoName: svc.GoName + "Client", GoImportPath: zf.file.GoImportPath})
+
+	g.P("// Register", svc.GoName, "Routes adds the REST routes of the ", svc.GoName, " service, which are")
+	g.P("// mapped from the google.api.http annotations, to the server. The client is")
+	g.P("// either the zrpc client or the gRPC client of the service.")
+	g.P("func Register", svc.GoName, "Routes(server *", restPackage.Ident("Server"), ", cli ", client, ", opts ...", restPackage.Ident("RouteOption"), ") {")
+	g.P("server.AddRoutes([]", restPackage.Ident("Route"), "{")
+	for _, route := range routes {
+		path, _, err := routePath(route.rule.path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", route.method.Desc.FullName(), err)
+		}
+		g.P("{")
+		g.P("Method: ", fmt.Sprintf("%q", route.rule.method), ",")
+		g.P("Path: ", fmt.Sprintf("%q", path), ",")
+		g.P("Handler: ", route.handler, "(cli),")
+		g.P("},")
+	}
+	g.P("}, opts...)")
+	g.P("}")
+	g.P()
+
+	for _, route := range routes {
+		if err := zf.genGatewayHandler(g, client, route); err != nil {
+			return fmt.Errorf("%s: %v", route.method.Desc.FullName(), err)
+		}
+	}
+	return nil
+}
+
+func (zf *zrpcFile) genGatewayHandler(g *protogen.GeneratedFile, client protogen.GoIdent, route gatewayRoute) error {
+	m, rule := route.method, route.rule
+	_, vars, err := routePath(rule.path)
+	if err != nil {
+		return err
+	}
+
+	g.P("func ", route.handler, "(cli ", client, ") ", httpPackage.Ident("HandlerFunc"), " {")
+	g.P("return func(w ", httpPackage.Ident("ResponseWriter"), ", r *", httpPackage.Ident("Request"), ") {")
+	g.P("var in ", zf.ident(m.Input.GoIdent))
+
+	// the fields bound to the path or to the body are not bound to the query
+	bound := map[string]bool{}
+	switch rule.body {
+	case "":
+	case "*":
+		g.P("if err := decodeBody(r, &in); err != nil {")
+		g.P("writeError(w, r, err)")
+		g.P("return")
+		g.P("}")
+	default:
+		field := findField(m.Input, rule.body)
+		if field == nil || field.Message == nil || field.Desc.IsList() || field.Desc.IsMap() || isOneofField(field) {
+			return fmt.Errorf("body %q must be a singular message field of %s", rule.body, m.Input.Desc.FullName())
+		}
+		g.P("in.", field.GoName, " = new(", zf.ident(field.Message.GoIdent), ")")
+		g.P("if err := decodeBody(r, in.", field.GoName, "); err != nil {")
+		g.P("writeError(w, r, err)")
+		g.P("return")
+		g.P("}")
+		bound[rule.body] = true
+	}
+
+	if len(vars) > 0 {
+		g.P("vars := ", pathvarPackage.Ident("Vars"), "(r)")
+	}
+	for _, v := range vars {
+		target, field, err := zf.fieldPath(g, m.Input, v)
+		if err != nil {
+			return err
+		}
+		g.P("if v, ok := vars[", fmt.Sprintf("%q", v), "]; ok {")
+		zf.genBindValue(g, target, field, v, "v")
+		g.P("}")
+		bound[v] = true
+	}
+
+	if rule.body != "*" {
+		var query []*protogen.Field
+		for _, field := range m.Input.Fields {
+			if !bound[string(field.Desc.Name())] && field.Message == nil && !field.Desc.IsMap() {
+				query = append(query, field)
+			}
+		}
+		if len(query) > 0 {
+			g.P("q := r.URL.Query()")
+		}
+		for _, field := range query {
+			name := string(field.Desc.Name())
+			names := fmt.Sprintf("%q", name)
+			if jsonName := field.Desc.JSONName(); jsonName != name {
+				names += fmt.Sprintf(", %q", jsonName)
+			}
+			g.P("if vs := queryValues(q, ", names, "); len(vs) > 0 {")
+			if field.Desc.IsList() {
+				g.P("for _, v := range vs {")
+				zf.genBindValue(g, "in", field, name, "v")
+				g.P("}")
+			} else {
+				zf.genBindValue(g, "in", field, name, "vs[0]")
+			}
+			g.P("}")
+		}
+	}
+
+	g.P("out, err := cli.", m.GoName, "(r.Context(), &in)")
+	g.P("if err != nil {")
+	g.P("writeError(w, r, err)")
+	g.P("return")
+	g.P("}")
+	if rule.responseBody == "" {
+		g.P("writeMessage(w, r, out)")
+	} else {
+		field := findField(m.Output, rule.responseBody)
+		if field == nil || field.Message == nil || field.Desc.IsList() || field.Desc.IsMap() {
+			return fmt.Errorf("response_body %q must be a singular message field of %s", rule.responseBody, m.Output.Desc.FullName())
+		}
+		g.P("writeMessage(w, r, out.Get", field.GoName, "())")
+	}
+	g.P("}")
+	g.P("}")
+	g.P()
+	return nil
+}
+
+func findField(msg *protogen.Message, name string) *protogen.Field {
+	for _, field := range msg.Fields {
+		if string(field.Desc.Name()) == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func isOneofField(field *protogen.Field) bool {
+	return field.Oneof != nil && !field.Oneof.Desc.IsSynthetic()
+}
+
+// fieldPath generates the allocation of the messages of a nested path
+// variable, like book.id, and returns the expression of the message that has
+// the last field.
+func (zf *zrpcFile) fieldPath(g *protogen.GeneratedFile, msg *protogen.Message, path string) (string, *protogen.Field, error) {
+	target := "in"
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		field := findField(msg, name)
+		if field == nil {
+			return "", nil, fmt.Errorf("no field %q in %s for the path variable %q", name, msg.Desc.FullName(), path)
+		}
+		if i == len(names)-1 {
+			if field.Message != nil || field.Desc.IsList() || field.Desc.IsMap() {
+				return "", nil, fmt.Errorf("path variable %q must be a singular scalar field", path)
+			}
+			return target, field, nil
+		}
+		if field.Message == nil || field.Desc.IsList() || field.Desc.IsMap() || isOneofField(field) {
+			return "", nil, fmt.Errorf("field %q of the path variable %q must be a singular message field", name, path)
+		}
+		target += "." + field.GoName
+		g.P("if ", target, " == nil {")
+		g.P(target, " = new(", zf.ident(field.Message.GoIdent), ")")
+		g.P("}")
+		msg = field.Message
+	}
+	return "", nil, fmt.Errorf("empty path variable")
+}
+
+// scalarParsers are the helpers that parse the values of the scalar kinds.
+var scalarParsers = map[protoreflect.Kind]string{
+	protoreflect.BoolKind:     "parseBool",
+	protoreflect.Int32Kind:    "parseInt32",
+	protoreflect.Sint32Kind:   "parseInt32",
+	protoreflect.Sfixed32Kind: "parseInt32",
+	protoreflect.Int64Kind:    "parseInt64",
+	protoreflect.Sint64Kind:   "parseInt64",
+	protoreflect.Sfixed64Kind: "parseInt64",
+	protoreflect.Uint32Kind:   "parseUint32",
+	protoreflect.Fixed32Kind:  "parseUint32",
+	protoreflect.Uint64Kind:   "parseUint64",
+	protoreflect.Fixed64Kind:  "parseUint64",
+	protoreflect.FloatKind:    "parseFloat32",
+	protoreflect.DoubleKind:   "parseFloat64",
+	protoreflect.BytesKind:    "parseBytes",
+}
+
+// genBindValue generates the parsing of the value and its assignment to the field of the target message.
+func (zf *zrpcFile) genBindValue(g *protogen.GeneratedFile, target string, field *protogen.Field, name, value string) {
+	switch kind := field.Desc.Kind(); kind {
+	case protoreflect.StringKind:
+		g.P("x := ", value)
+	case protoreflect.EnumKind:
+		enum := zf.ident(field.Enum.GoIdent)
+		g.P("n, err := parseEnum(", fmt.Sprintf("%q", name), ", ", value, ", ", g.QualifiedGoIdent(enum), "_value)")
+		g.P("if err != nil {")
+		g.P("writeError(w, r, err)")
+		g.P("return")
+		g.P("}")
+		g.P("x := ", enum, "(n)")
+	default:
+		g.P("x, err := ", scalarParsers[kind], "(", fmt.Sprintf("%q", name), ", ", value, ")")
+		g.P("if err != nil {")
+		g.P("writeError(w, r, err)")
+		g.P("return")
+		g.P("}")
+	}
+
+	switch {
+	case field.Desc.IsList():
+		g.P(target, ".", field.GoName, " = append(", target, ".", field.GoName, ", x)")
+	case isOneofField(field):
+		g.P(target, ".", field.Oneof.GoName, " = &", zf.ident(field.GoIdent), "{", field.GoName, ": x}")
+	case field.Desc.HasPresence() && field.Desc.Kind() != protoreflect.BytesKind:
+		g.P(target, ".", field.GoName, " = &x")
+	default:
+		g.P(target, ".", field.GoName, " = x")
+	}
+}
+
+// gatewayHelpers is the file of the helpers of the generated handlers.
+const gatewayHelpers = `// Code generated by protoc-gen-gox. DO NOT EDIT.
+
+package gateway
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	marshaler   = protojson.MarshalOptions{EmitUnpopulated: true}
+	unmarshaler = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+type errorBody struct {
+	Code    int32  ` + "`json:\"code\"`" + `
+	Message string ` + "`json:\"message\"`" + `
+}
+
+// decodeBody decodes the JSON body of the request into the message, an empty body is allowed.
+func decodeBody(r *http.Request, m proto.Message) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if err := unmarshaler.Unmarshal(body, m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid body: %v", err)
+	}
+	return nil
+}
+
+// writeMessage writes the message as JSON.
+func writeMessage(w http.ResponseWriter, r *http.Request, m proto.Message) {
+	body, err := marshaler.Marshal(m)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set(httpx.ContentType, httpx.JsonContentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// writeError writes the error as JSON, the code of a gRPC status is mapped to the HTTP status.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	httpx.WriteJsonCtx(r.Context(), w, httpStatus(st.Code()), errorBody{
+		Code:    int32(st.Code()),
+		Message: st.Message(),
+	})
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// queryValues returns the values of the first of the names, the proto name and the JSON name of a field.
+func queryValues(q url.Values, names ...string) []string {
+	for _, name := range names {
+		if vs, ok := q[name]; ok {
+			return vs
+		}
+	}
+	return nil
+}
+
+func invalidParam(name string, err error) error {
+	return status.Errorf(codes.InvalidArgument, "invalid %s: %v", name, err)
+}
+
+func parseBool(name, v string) (bool, error) {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, invalidParam(name, err)
+	}
+	return b, nil
+}
+
+func parseInt32(name, v string) (int32, error) {
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return 0, invalidParam(name, err)
+	}
+	return int32(n), nil
+}
+
+func parseInt64(name, v string) (int64, error) {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, invalidParam(name, err)
+	}
+	return n, nil
+}
+
+func parseUint32(name, v string) (uint32, error) {
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, invalidParam(name, err)
+	}
+	return uint32(n), nil
+}
+
+func parseUint64(name, v string) (uint64, error) {
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, invalidParam(name, err)
+	}
+	return n, nil
+}
+
+func parseFloat32(name, v string) (float32, error) {
+	f, err := strconv.ParseFloat(v, 32)
+	if err != nil {
+		return 0, invalidParam(name, err)
+	}
+	return float32(f), nil
+}
+
+func parseFloat64(name, v string) (float64, error) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, invalidParam(name, err)
+	}
+	return f, nil
+}
+
+// parseBytes parses base64, in the standard or the URL encoding.
+func parseBytes(name, v string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		if b, err = base64.URLEncoding.DecodeString(v); err != nil {
+			return nil, invalidParam(name, err)
+		}
+	}
+	return b, nil
+}
+
+// parseEnum parses the name or the number of an enum value.
+func parseEnum(name, v string, values map[string]int32) (int32, error) {
+	if n, ok := values[v]; ok {
+		return n, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return 0, invalidParam(name, err)
+	}
+	return int32(n), nil
+}
+`
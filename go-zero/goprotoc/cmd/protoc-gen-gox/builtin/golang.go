@@ -0,0 +1,358 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/jhump/goprotoc/plugins"
+)
+
+// doGo generates the messages, the same as protoc-gen-go. The legacy
+// "plugins=grpc" parameter, which is added by the go-grpc pseudo-plugin, also
+// generates the gRPC stubs.
+func doGo(req *plugins.CodeGenRequest, resp *plugins.CodeGenResponse) error {
+	own, args := splitArgs(req.Args, "plugins")
+	withGrpc := false
+	for _, pl := range strings.Split(own["plugins"], "+") {
+		switch pl {
+		case "":
+		case "grpc":
+			withGrpc = true
+		default:
+			return fmt.Errorf("go: unsupported plugin %q, only grpc is supported", pl)
+		}
+	}
+
+	gen, err := newPlugin(req, args)
+	if err != nil {
+		return err
+	}
+	gen.SupportedFeatures = gengo.SupportedFeatures
+	gen.SupportedEditionsMinimum = gengo.SupportedEditionsMinimum
+	gen.SupportedEditionsMaximum = gengo.SupportedEditionsMaximum
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		gengo.GenerateFile(gen, f)
+		if withGrpc {
+			generateGrpcFile(gen, f)
+		}
+	}
+	return writeResponse(gen, resp)
+}
+
+// doGrpc generates the gRPC stubs, the same as protoc-gen-go-grpc.
+func doGrpc(req *plugins.CodeGenRequest, resp *plugins.CodeGenResponse) error {
+	gen, err := newPlugin(req, req.Args)
+	if err != nil {
+		return err
+	}
+	gen.SupportedFeatures = gengo.SupportedFeatures
+	gen.SupportedEditionsMinimum = gengo.SupportedEditionsMinimum
+	gen.SupportedEditionsMaximum = gengo.SupportedEditionsMaximum
+	for _, f := range gen.Files {
+		if f.Generate {
+			generateGrpcFile(gen, f)
+		}
+	}
+	return writeResponse(gen, resp)
+}
+
+const (
+	contextPackage = protogen.GoImportPath("context")
+	grpcPackage    = protogen.GoImportPath("google.golang.org/grpc")
+	codesPackage   = protogen.GoImportPath("google.golang.org/grpc/codes")
+	statusPackage  = protogen.GoImportPath("google.golang.org/grpc/status")
+)
+
+// generateGrpcFile generates the _grpc.pb.go file of the services of the file,
+// using the generic stream types of gRPC-Go v1.64.0 and later.
+func generateGrpcFile(gen *protogen.Plugin, file *protogen.File) {
+	if len(file.Services) == 0 {
+		return
+	}
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_grpc.pb.go", file.GoImportPath)
+	g.P("// Code generated by protoc-gen-gox. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+	g.P("// This is a compile-time assertion to ensure that this generated file")
+	g.P("// is compatible with the grpc package it is being compiled against.")
+	g.P("// Requires gRPC-Go v1.64.0 or later.")
+	g.P("const _ = ", grpcPackage.Ident("SupportPackageIsVersion9"))
+	g.P()
+	for _, service := range file.Services {
+		genGrpcService(g, file, service)
+	}
+}
+
+func genGrpcService(g *protogen.GeneratedFile, file *protogen.File, service *protogen.Service) {
+	clientName := service.GoName + "Client"
+	serverName := service.GoName + "Server"
+	descName := service.GoName + "_ServiceDesc"
+
+	g.P("const (")
+	for _, method := range service.Methods {
+		g.P(fullMethodName(service, method), ` = "/`, service.Desc.FullName(), "/", method.Desc.Name(), `"`)
+	}
+	g.P(")")
+	g.P()
+
+	// client
+	g.P("// ", clientName, " is the client API for ", service.GoName, " service.")
+	g.P("//")
+	g.P("// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.")
+	g.AnnotateSymbol(clientName, protogen.Annotation{Location: service.Location})
+	g.P("type ", clientName, " interface {")
+	for _, method := range service.Methods {
+		g.P(method.Comments.Leading, clientSignature(g, method))
+	}
+	g.P("}")
+	g.P()
+	unexportedClient := unexport(clientName)
+	g.P("type ", unexportedClient, " struct {")
+	g.P("cc ", grpcPackage.Ident("ClientConnInterface"))
+	g.P("}")
+	g.P()
+	g.P("func New", clientName, "(cc ", grpcPackage.Ident("ClientConnInterface"), ") ", clientName, " {")
+	g.P("return &", unexportedClient, "{cc}")
+	g.P("}")
+	g.P()
+	streamIndex := 0
+	for _, method := range service.Methods {
+		genGrpcClientMethod(g, service, method, descName, streamIndex)
+		if isStreaming(method) {
+			streamIndex++
+		}
+	}
+
+	// server
+	g.P("// ", serverName, " is the server API for ", service.GoName, " service.")
+	g.P("// All implementations must embed Unimplemented", serverName)
+	g.P("// for forward compatibility.")
+	g.AnnotateSymbol(serverName, protogen.Annotation{Location: service.Location})
+	g.P("type ", serverName, " interface {")
+	for _, method := range service.Methods {
+		g.P(method.Comments.Leading, serverSignature(g, method))
+	}
+	g.P("mustEmbedUnimplemented", serverName, "()")
+	g.P("}")
+	g.P()
+	g.P("// Unimplemented", serverName, " must be embedded to have")
+	g.P("// forward compatible implementations.")
+	g.P("//")
+	g.P("// NOTE: this should be embedded by value instead of pointer to avoid a nil")
+	g.P("// pointer dereference when methods are called.")
+	g.P("type Unimplemented", serverName, " struct{}")
+	g.P()
+	for _, method := range service.Methods {
+		nilArg := ""
+		if !isStreaming(method) {
+			nilArg = "nil, "
+		}
+		g.P("func (Unimplemented", serverName, ") ", serverSignature(g, method), " {")
+		g.P("return ", nilArg, statusPackage.Ident("Errorf"), "(", codesPackage.Ident("Unimplemented"), `, "method `, method.GoName, ` not implemented")`)
+		g.P("}")
+	}
+	g.P("func (Unimplemented", serverName, ") mustEmbedUnimplemented", serverName, "() {}")
+	g.P("func (Unimplemented", serverName, ") testEmbeddedByValue() {}")
+	g.P()
+	g.P("// Unsafe", serverName, " may be embedded to opt out of forward compatibility for this service.")
+	g.P("// Use of this interface is not recommended, as added methods to ", serverName, " will")
+	g.P("// result in compilation errors.")
+	g.P("type Unsafe", serverName, " interface {")
+	g.P("mustEmbedUnimplemented", serverName, "()")
+	g.P("}")
+	g.P()
+	g.P("func Register", serverName, "(s ", grpcPackage.Ident("ServiceRegistrar"), ", srv ", serverName, ") {")
+	g.P("// If the following call panics, it indicates Unimplemented", serverName, " was")
+	g.P("// embedded by pointer and is nil. This will cause panics if an")
+	g.P("// unimplemented method is ever invoked, so we test this at initialization")
+	g.P("// time to prevent it from happening at runtime later due to I/O.")
+	g.P("if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {")
+	g.P("t.testEmbeddedByValue()")
+	g.P("}")
+	g.P("s.RegisterService(&", descName, ", srv)")
+	g.P("}")
+	g.P()
+
+	var handlerNames []string
+	for _, method := range service.Methods {
+		handlerNames = append(handlerNames, genGrpcServerMethod(g, service, method))
+	}
+
+	g.P("// ", descName, " is the ", grpcPackage.Ident("ServiceDesc"), " for ", service.GoName, " service.")
+	g.P("// It's only intended for direct use with ", grpcPackage.Ident("RegisterService"), ",")
+	g.P("// and not to be introspected or modified (even as a copy)")
+	g.P("var ", descName, " = ", grpcPackage.Ident("ServiceDesc"), " {")
+	g.P("ServiceName: ", fmt.Sprintf("%q", service.Desc.FullName()), ",")
+	g.P("HandlerType: (*", serverName, ")(nil),")
+	g.P("Methods: []", grpcPackage.Ident("MethodDesc"), "{")
+	for i, method := range service.Methods {
+		if isStreaming(method) {
+			continue
+		}
+		g.P("{")
+		g.P("MethodName: ", fmt.Sprintf("%q", method.Desc.Name()), ",")
+		g.P("Handler: ", handlerNames[i], ",")
+		g.P("},")
+	}
+	g.P("},")
+	g.P("Streams: []", grpcPackage.Ident("StreamDesc"), "{")
+	for i, method := range service.Methods {
+		if !isStreaming(method) {
+			continue
+		}
+		g.P("{")
+		g.P("StreamName: ", fmt.Sprintf("%q", method.Desc.Name()), ",")
+		g.P("Handler: ", handlerNames[i], ",")
+		if method.Desc.IsStreamingServer() {
+			g.P("ServerStreams: true,")
+		}
+		if method.Desc.IsStreamingClient() {
+			g.P("ClientStreams: true,")
+		}
+		g.P("},")
+	}
+	g.P("},")
+	g.P("Metadata: ", fmt.Sprintf("%q", file.Desc.Path()), ",")
+	g.P("}")
+	g.P()
+}
+
+func genGrpcClientMethod(g *protogen.GeneratedFile, service *protogen.Service, method *protogen.Method, descName string, streamIndex int) {
+	g.P("func (c *", unexport(service.GoName), "Client) ", clientSignature(g, method), "{")
+	g.P("cOpts := append([]", grpcPackage.Ident("CallOption"), "{", grpcPackage.Ident("StaticMethod"), "()}, opts...)")
+	if !isStreaming(method) {
+		g.P("out := new(", method.Output.GoIdent, ")")
+		g.P("err := c.cc.Invoke(ctx, ", fullMethodName(service, method), ", in, out, cOpts...)")
+		g.P("if err != nil { return nil, err }")
+		g.P("return out, nil")
+		g.P("}")
+		g.P()
+		return
+	}
+
+	g.P("stream, err := c.cc.NewStream(ctx, &", descName, ".Streams[", streamIndex, "], ", fullMethodName(service, method), ", cOpts...)")
+	g.P("if err != nil { return nil, err }")
+	g.P("x := &", grpcPackage.Ident("GenericClientStream"), "[", method.Input.GoIdent, ", ", method.Output.GoIdent, "]{ClientStream: stream}")
+	if !method.Desc.IsStreamingClient() {
+		g.P("if err := x.ClientStream.SendMsg(in); err != nil { return nil, err }")
+		g.P("if err := x.ClientStream.CloseSend(); err != nil { return nil, err }")
+	}
+	g.P("return x, nil")
+	g.P("}")
+	g.P()
+	g.P("// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.")
+	g.P("type ", service.GoName, "_", method.GoName, "Client = ", clientStreamType(g, method))
+	g.P()
+}
+
+func genGrpcServerMethod(g *protogen.GeneratedFile, service *protogen.Service, method *protogen.Method) string {
+	serverName := service.GoName + "Server"
+	handlerName := fmt.Sprintf("_%s_%s_Handler", service.GoName, method.GoName)
+
+	if !isStreaming(method) {
+		g.P("func ", handlerName, "(srv any, ctx ", contextPackage.Ident("Context"), ", dec func(any) error, interceptor ", grpcPackage.Ident("UnaryServerInterceptor"), ") (any, error) {")
+		g.P("in := new(", method.Input.GoIdent, ")")
+		g.P("if err := dec(in); err != nil { return nil, err }")
+		g.P("if interceptor == nil { return srv.(", serverName, ").", method.GoName, "(ctx, in) }")
+		g.P("info := &", grpcPackage.Ident("UnaryServerInfo"), "{")
+		g.P("Server: srv,")
+		g.P("FullMethod: ", fullMethodName(service, method), ",")
+		g.P("}")
+		g.P("handler := func(ctx ", contextPackage.Ident("Context"), ", req any) (any, error) {")
+		g.P("return srv.(", serverName, ").", method.GoName, "(ctx, req.(*", method.Input.GoIdent, "))")
+		g.P("}")
+		g.P("return interceptor(ctx, in, info, handler)")
+		g.P("}")
+		g.P()
+		return handlerName
+	}
+
+	g.P("func ", handlerName, "(srv any, stream ", grpcPackage.Ident("ServerStream"), ") error {")
+	stream := fmt.Sprintf("&%s[%s, %s]{ServerStream: stream}", g.QualifiedGoIdent(grpcPackage.Ident("GenericServerStream")),
+		g.QualifiedGoIdent(method.Input.GoIdent), g.QualifiedGoIdent(method.Output.GoIdent))
+	if !method.Desc.IsStreamingClient() {
+		g.P("m := new(", method.Input.GoIdent, ")")
+		g.P("if err := stream.RecvMsg(m); err != nil { return err }")
+		g.P("return srv.(", serverName, ").", method.GoName, "(m, ", stream, ")")
+	} else {
+		g.P("return srv.(", serverName, ").", method.GoName, "(", stream, ")")
+	}
+	g.P("}")
+	g.P()
+	g.P("// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.")
+	g.P("type ", service.GoName, "_", method.GoName, "Server = ", serverStreamType(g, method))
+	g.P()
+	return handlerName
+}
+
+func clientSignature(g *protogen.GeneratedFile, method *protogen.Method) string {
+	s := method.GoName + "(ctx " + g.QualifiedGoIdent(contextPackage.Ident("Context"))
+	if !method.Desc.IsStreamingClient() {
+		s += ", in *" + g.QualifiedGoIdent(method.Input.GoIdent)
+	}
+	s += ", opts ..." + g.QualifiedGoIdent(grpcPackage.Ident("CallOption")) + ") ("
+	if isStreaming(method) {
+		s += clientStreamType(g, method)
+	} else {
+		s += "*" + g.QualifiedGoIdent(method.Output.GoIdent)
+	}
+	return s + ", error)"
+}
+
+func serverSignature(g *protogen.GeneratedFile, method *protogen.Method) string {
+	var params []string
+	ret := "error"
+	if !isStreaming(method) {
+		params = append(params, g.QualifiedGoIdent(contextPackage.Ident("Context")))
+		ret = "(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
+	}
+	if !method.Desc.IsStreamingClient() {
+		params = append(params, "*"+g.QualifiedGoIdent(method.Input.GoIdent))
+	}
+	if isStreaming(method) {
+		params = append(params, serverStreamType(g, method))
+	}
+	return method.GoName + "(" + strings.Join(params, ", ") + ") " + ret
+}
+
+func clientStreamType(g *protogen.GeneratedFile, method *protogen.Method) string {
+	in, out := g.QualifiedGoIdent(method.Input.GoIdent), g.QualifiedGoIdent(method.Output.GoIdent)
+	switch {
+	case method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer():
+		return g.QualifiedGoIdent(grpcPackage.Ident("BidiStreamingClient")) + "[" + in + ", " + out + "]"
+	case method.Desc.IsStreamingClient():
+		return g.QualifiedGoIdent(grpcPackage.Ident("ClientStreamingClient")) + "[" + in + ", " + out + "]"
+	}
+	return g.QualifiedGoIdent(grpcPackage.Ident("ServerStreamingClient")) + "[" + out + "]"
+}
+
+func serverStreamType(g *protogen.GeneratedFile, method *protogen.Method) string {
+	in, out := g.QualifiedGoIdent(method.Input.GoIdent), g.QualifiedGoIdent(method.Output.GoIdent)
+	switch {
+	case method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer():
+		return g.QualifiedGoIdent(grpcPackage.Ident("BidiStreamingServer")) + "[" + in + ", " + out + "]"
+	case method.Desc.IsStreamingClient():
+		return g.QualifiedGoIdent(grpcPackage.Ident("ClientStreamingServer")) + "[" + in + ", " + out + "]"
+	}
+	return g.QualifiedGoIdent(grpcPackage.Ident("ServerStreamingServer")) + "[" + out + "]"
+}
+
+func fullMethodName(service *protogen.Service, method *protogen.Method) string {
+	return service.GoName + "_" + method.GoName + "_FullMethodName"
+}
+
+func isStreaming(method *protogen.Method) bool {
+	return method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer()
+}
+
+func unexport(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,192 @@
+package builtin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// httpRuleField is the field number of the google.api.http extension of the
+// method options. The rule is decoded from the wire format, so that neither the
+// googleapis Go package nor a registered extension is needed.
+const httpRuleField = 72295728
+
+// httpRule is a google.api.HttpRule.
+type httpRule struct {
+	method       string
+	path         string
+	body         string
+	responseBody string
+	additional   []*httpRule
+}
+
+// getHTTPRule returns the google.api.http rule of the method, or nil if it has none.
+func getHTTPRule(opts *descriptorpb.MethodOptions) (*httpRule, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	// the extension is unknown unless its Go package is linked in, marshaling
+	// covers both cases
+	b, err := proto.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	var rule []byte
+	found := false
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if num == httpRuleField && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			// repeated occurrences of a message field are merged
+			rule = append(rule, v...)
+			found = true
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	if !found {
+		return nil, nil
+	}
+	return parseHTTPRule(rule)
+}
+
+func parseHTTPRule(b []byte) (*httpRule, error) {
+	r := &httpRule{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case 2:
+			r.method, r.path = "GET", string(v)
+		case 3:
+			r.method, r.path = "PUT", string(v)
+		case 4:
+			r.method, r.path = "POST", string(v)
+		case 5:
+			r.method, r.path = "DELETE", string(v)
+		case 6:
+			r.method, r.path = "PATCH", string(v)
+		case 7:
+			r.body = string(v)
+		case 8:
+			kind, pattern, err := parseCustomPattern(v)
+			if err != nil {
+				return nil, err
+			}
+			r.method, r.path = strings.ToUpper(kind), pattern
+		case 11:
+			additional, err := parseHTTPRule(v)
+			if err != nil {
+				return nil, err
+			}
+			r.additional = append(r.additional, additional)
+		case 12:
+			r.responseBody = string(v)
+		}
+	}
+	return r, nil
+}
+
+func parseCustomPattern(b []byte) (kind, pattern string, err error) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return "", "", protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return "", "", protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return "", "", protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case 1:
+			kind = string(v)
+		case 2:
+			pattern = string(v)
+		}
+	}
+	return kind, pattern, nil
+}
+
+// bindings returns the rule and its additional bindings.
+func (r *httpRule) bindings() []*httpRule {
+	all := []*httpRule{r}
+	for _, a := range r.additional {
+		all = append(all, a.bindings()...)
+	}
+	return all
+}
+
+// routePath converts a path template, like /v1/{name}/books/{book.id=*}, to a
+// go-zero route path, /v1/:name/books/:book.id, and returns the field paths of
+// the variables. The variables must be whole segments that match one segment.
+func routePath(template string) (string, []string, error) {
+	if !strings.HasPrefix(template, "/") {
+		return "", nil, fmt.Errorf("path %q must start with /", template)
+	}
+	segments := strings.Split(template[1:], "/")
+	var vars []string
+	for i, seg := range segments {
+		if !strings.ContainsAny(seg, "{}") {
+			if strings.Contains(seg, "*") || strings.HasPrefix(seg, ":") {
+				return "", nil, fmt.Errorf("path %q: wildcard segments are not supported", template)
+			}
+			continue
+		}
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			return "", nil, fmt.Errorf("path %q: variable must be a whole segment", template)
+		}
+		field := seg[1 : len(seg)-1]
+		if eq := strings.IndexByte(field, '='); eq >= 0 {
+			if field[eq+1:] != "*" {
+				return "", nil, fmt.Errorf("path %q: only single segment variables are supported", template)
+			}
+			field = field[:eq]
+		}
+		if field == "" {
+			return "", nil, errors.New("empty variable in path " + template)
+		}
+		vars = append(vars, field)
+		segments[i] = ":" + field
+	}
+	return "/" + strings.Join(segments, "/"), vars, nil
+}
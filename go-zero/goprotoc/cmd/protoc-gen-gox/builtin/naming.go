@@ -0,0 +1,68 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const defaultStyle = "go_zero"
+
+// formatName formats a file or directory name in the goctl style, which is an
+// example of how "go zero" is written, such as gozero, go_zero or goZero.
+func formatName(style, name string) (string, error) {
+	lower := strings.ToLower(style)
+	end := strings.LastIndex(lower, "zero")
+	if !strings.HasPrefix(lower, "go") || end < 2 || end+len("zero") != len(lower) {
+		return "", fmt.Errorf("invalid style %q, it must be like gozero, go_zero or goZero", style)
+	}
+	sep := style[2:end]
+	firstUpper, restUpper := style[0] == 'G', style[end] == 'Z'
+
+	words := splitWords(name)
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if (i == 0 && firstUpper) || (i > 0 && restUpper) {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		words[i] = w
+	}
+	return strings.Join(words, sep), nil
+}
+
+// splitWords splits a camel case or snake case name into words; an acronym,
+// like the HTTP of HTTPServer, is one word.
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+			}
+			start = -1
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := runes[i-1]
+		boundary := unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+			(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])))
+		if boundary {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// packageName returns the Go package name of a directory named after name.
+func packageName(name string) string {
+	return strings.ToLower(strings.Join(splitWords(name), ""))
+}
@@ -0,0 +1,546 @@
+package builtin
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"google.golang.org/protobuf/compiler/protogen"
+
+	"github.com/jhump/goprotoc/plugins"
+	gozero "github.com/magic-lib/go-servicekit/go-zero"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// zrpcGenerator generates the files of the go-zero RPC services. It accepts
+// these parameters, the other ones are protoc-gen-go parameters such as
+// "M<file>=<pkg>":
+//
+//	module=<path>  the import path of the output directory, defaults to the one
+//	               computed from the go.mod of the output directory
+//	dir=<path>     the output directory, defaults to the current directory; the
+//	               scaffolding files that already exist in it are kept
+//	style=<style>  the goctl file name style, defaults to go_zero
+//	home=<path>    the goctl template home, its rpc templates override the
+//	               builtin ones
+type zrpcGenerator struct {
+	gen    *protogen.Plugin
+	module string
+	dir    string
+	style  string
+	home   string
+	// files that were already generated, the service context and config are
+	// shared by all the files of the request
+	generated map[string]bool
+}
+
+// zrpcFile is a proto file with services.
+type zrpcFile struct {
+	*zrpcGenerator
+	file *protogen.File
+	// name is the base name of the proto file, used for main and the etc yaml
+	name     string
+	pbImport string
+	multiple bool
+}
+
+func zrpcPlugin(gen func(*zrpcFile) error) plugins.Plugin {
+	return func(req *plugins.CodeGenRequest, resp *plugins.CodeGenResponse) error {
+		own, args := splitArgs(req.Args, "module", "dir", "style", "home")
+		z := &zrpcGenerator{
+			module:    own["module"],
+			dir:       own["dir"],
+			style:     own["style"],
+			home:      own["home"],
+			generated: map[string]bool{},
+		}
+		if z.dir == "" {
+			z.dir = "."
+		}
+		if z.style == "" {
+			z.style = defaultStyle
+		}
+		if _, err := formatName(z.style, "x"); err != nil {
+			return err
+		}
+		if z.module == "" {
+			var err error
+			if z.module, err = moduleOf(z.dir); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if z.gen, err = newPlugin(req, args); err != nil {
+			return err
+		}
+		for _, f := range z.gen.Files {
+			if !f.Generate || len(f.Services) == 0 {
+				continue
+			}
+			zf := &zrpcFile{
+				zrpcGenerator: z,
+				file:          f,
+				name:          strings.TrimSuffix(path.Base(f.Desc.Path()), ".proto"),
+				pbImport:      z.importPath(f.GoImportPath),
+				multiple:      len(f.Services) > 1,
+			}
+			if err := gen(zf); err != nil {
+				return fmt.Errorf("%s: %v", f.Desc.Path(), err)
+			}
+		}
+		return writeResponse(z.gen, resp)
+	}
+}
+
+// moduleOf computes the import path of the directory from the go.mod of the
+// directory or of its parents.
+func moduleOf(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for root := abs; ; root = filepath.Dir(root) {
+		if mod, err := os.ReadFile(filepath.Join(root, "go.mod")); err == nil {
+			module := modulePath(mod)
+			if module == "" {
+				return "", fmt.Errorf("no module path in %s", filepath.Join(root, "go.mod"))
+			}
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(module, filepath.ToSlash(rel)), nil
+		}
+		if filepath.Dir(root) == root {
+			return "", fmt.Errorf("no go.mod found for %s, use the module parameter", abs)
+		}
+	}
+}
+
+func modulePath(mod []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(mod))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// importPath resolves the relative go_package paths, like "./user", which
+// goctl supports, against the module.
+func (z *zrpcGenerator) importPath(p protogen.GoImportPath) string {
+	if strings.HasPrefix(string(p), ".") {
+		return path.Join(z.module, string(p))
+	}
+	return string(p)
+}
+
+func (z *zrpcGenerator) fileName(name string) string {
+	s, _ := formatName(z.style, name)
+	return s
+}
+
+// exists returns true if the file was already generated by this request or
+// exists in the output directory.
+func (z *zrpcGenerator) exists(name string) bool {
+	if z.generated[name] {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(z.dir, filepath.FromSlash(name)))
+	return err == nil
+}
+
+// readTemplate returns the rpc template, the one of the template home if it
+// exists, otherwise the goctl template shared with the servicekit command.
+func (z *zrpcGenerator) readTemplate(name string) ([]byte, error) {
+	var text []byte
+	var err error
+	if z.home != "" {
+		text, err = os.ReadFile(filepath.Join(z.home, "rpc", name))
+	}
+	if z.home == "" || os.IsNotExist(err) {
+		text, err = gozero.Files.ReadFile("goctl-tmpl/rpc/" + name)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		// the templates of the client funcs are not in the goctl templates
+		text, err = templates.ReadFile("templates/rpc/" + name)
+	}
+	return text, err
+}
+
+// render executes the rpc template.
+func (z *zrpcGenerator) render(name string, data map[string]interface{}) (string, error) {
+	text, err := z.readTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	t, err := template.New(name).Parse(string(text))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// write adds a file to the response, the Go files are formatted.
+func (z *zrpcGenerator) write(name, importPath, content string) {
+	z.generated[name] = true
+	g := z.gen.NewGeneratedFile(name, protogen.GoImportPath(importPath))
+	g.P(content)
+}
+
+func (z *zrpcGenerator) head(f *protogen.File) string {
+	return "// Code generated by protoc-gen-gox. DO NOT EDIT.\n// Source: " + f.Desc.Path()
+}
+
+// goImports are the imports of a generated file.
+type goImports struct {
+	aliases map[string]string
+	names   map[string]bool
+}
+
+func newGoImports() *goImports {
+	return &goImports{aliases: map[string]string{}, names: map[string]bool{}}
+}
+
+// add imports the package, and returns its name in the file.
+func (im *goImports) add(importPath, name string) string {
+	if alias, ok := im.aliases[importPath]; ok {
+		return alias
+	}
+	alias := name
+	for i := 1; im.names[alias]; i++ {
+		alias = fmt.Sprintf("%s%d", name, i)
+	}
+	im.aliases[importPath] = alias
+	im.names[alias] = true
+	return alias
+}
+
+// String returns the import specs, one per line.
+func (im *goImports) String() string {
+	return im.specs("")
+}
+
+// specs returns the import specs, one per line, except the one of the excluded package.
+func (im *goImports) specs(exclude string) string {
+	var specs []string
+	for importPath, alias := range im.aliases {
+		if importPath == exclude {
+			continue
+		}
+		if alias == path.Base(importPath) {
+			specs = append(specs, fmt.Sprintf("%q", importPath))
+		} else {
+			specs = append(specs, fmt.Sprintf("%s %q", alias, importPath))
+		}
+	}
+	sort.Strings(specs)
+	return strings.Join(specs, "\n\t")
+}
+
+// pkg imports the pb package of the proto file.
+func (zf *zrpcFile) pkg(im *goImports) string {
+	return im.add(zf.pbImport, string(zf.file.GoPackageName))
+}
+
+// typeName returns the qualified name of the message type.
+func (zf *zrpcFile) typeName(im *goImports, msg *protogen.Message) string {
+	f := zf.gen.FilesByPath[msg.Desc.ParentFile().Path()]
+	return im.add(zf.importPath(msg.GoIdent.GoImportPath), string(f.GoPackageName)) + "." + msg.GoIdent.GoName
+}
+
+func (zf *zrpcFile) serverPkg(svc *protogen.Service) string {
+	if zf.multiple {
+		return packageName(svc.GoName)
+	}
+	return "server"
+}
+
+func (zf *zrpcFile) serverDir(svc *protogen.Service) string {
+	if zf.multiple {
+		return "internal/server/" + zf.fileName(svc.GoName)
+	}
+	return "internal/server"
+}
+
+func (zf *zrpcFile) logicPkg(svc *protogen.Service) string {
+	if zf.multiple {
+		return packageName(svc.GoName)
+	}
+	return "logic"
+}
+
+func (zf *zrpcFile) logicDir(svc *protogen.Service) string {
+	if zf.multiple {
+		return "internal/logic/" + zf.fileName(svc.GoName)
+	}
+	return "internal/logic"
+}
+
+func (zf *zrpcFile) callDir(svc *protogen.Service) string {
+	if zf.multiple {
+		return "client/" + zf.fileName(svc.GoName)
+	}
+	return zf.fileName(svc.GoName)
+}
+
+func methodComment(m *protogen.Method) string {
+	return strings.TrimSuffix(m.Comments.Leading.String(), "\n")
+}
+
+// genServer generates the servers, which delegate the RPCs to the logic.
+func genServer(zf *zrpcFile) error {
+	for _, svc := range zf.file.Services {
+		im := newGoImports()
+		im.add(zf.module+"/internal/svc", "svc")
+		logicAlias := "logic"
+		if zf.multiple {
+			logicAlias = zf.logicPkg(svc) + "logic"
+		}
+		logicPkg := im.add(zf.module+"/"+zf.logicDir(svc), logicAlias)
+		pb := zf.pkg(im)
+
+		var funcs []string
+		notStream := false
+		for _, m := range svc.Methods {
+			notStream = notStream || !isStreaming(m)
+			fn, err := zf.render("server-func.tpl", map[string]interface{}{
+				"server":     svc.GoName,
+				"logicName":  m.GoName + "Logic",
+				"method":     m.GoName,
+				"request":    "*" + zf.typeName(im, m.Input),
+				"response":   "*" + zf.typeName(im, m.Output),
+				"hasComment": len(m.Comments.Leading) > 0,
+				"comment":    methodComment(m),
+				"hasReq":     !m.Desc.IsStreamingClient(),
+				"stream":     isStreaming(m),
+				"notStream":  !isStreaming(m),
+				"streamBody": fmt.Sprintf("%s.%s_%sServer", pb, svc.GoName, m.GoName),
+				"logicPkg":   logicPkg,
+			})
+			if err != nil {
+				return err
+			}
+			funcs = append(funcs, fn)
+		}
+
+		content, err := zf.render("server.tpl", map[string]interface{}{
+			"head":                zf.head(zf.file),
+			"unimplementedServer": fmt.Sprintf("%s.Unimplemented%sServer", pb, svc.GoName),
+			"server":              svc.GoName,
+			"imports":             im.String(),
+			"funcs":               strings.Join(funcs, "\n"),
+			"notStream":           notStream,
+		})
+		if err != nil {
+			return err
+		}
+		dir := zf.serverDir(svc)
+		zf.write(dir+"/"+zf.fileName(svc.GoName+"Server")+".go", zf.module+"/"+dir, content)
+	}
+	return nil
+}
+
+// genLogic generates the logic stubs of the RPCs that don't have one yet.
+func genLogic(zf *zrpcFile) error {
+	for _, svc := range zf.file.Services {
+		dir := zf.logicDir(svc)
+		for _, m := range svc.Methods {
+			name := dir + "/" + zf.fileName(m.GoName+"Logic") + ".go"
+			if zf.exists(name) {
+				continue
+			}
+			im := newGoImports()
+			im.add(zf.module+"/internal/svc", "svc")
+			streamBody := ""
+			if isStreaming(m) {
+				streamBody = fmt.Sprintf("%s.%s_%sServer", zf.pkg(im), svc.GoName, m.GoName)
+			}
+			logicName := m.GoName + "Logic"
+			fn, err := zf.render("logic-func.tpl", map[string]interface{}{
+				"logicName":    logicName,
+				"method":       m.GoName,
+				"hasReq":       !m.Desc.IsStreamingClient(),
+				"request":      "*" + zf.typeName(im, m.Input),
+				"hasReply":     !isStreaming(m),
+				"response":     "*" + zf.typeName(im, m.Output),
+				"responseType": zf.typeName(im, m.Output),
+				"stream":       isStreaming(m),
+				"streamBody":   streamBody,
+				"hasComment":   len(m.Comments.Leading) > 0,
+				"comment":      methodComment(m),
+			})
+			if err != nil {
+				return err
+			}
+			content, err := zf.render("logic.tpl", map[string]interface{}{
+				"packageName": zf.logicPkg(svc),
+				"logicName":   logicName,
+				"imports":     im.String(),
+				"functions":   fn,
+			})
+			if err != nil {
+				return err
+			}
+			zf.write(name, zf.module+"/"+dir, content)
+		}
+	}
+	return nil
+}
+
+// genSvc generates the service context, the config, the etc yaml and main,
+// unless they exist.
+func genSvc(zf *zrpcFile) error {
+	configImport := zf.module + "/internal/config"
+	svcImport := zf.module + "/internal/svc"
+
+	if name := "internal/config/config.go"; !zf.exists(name) {
+		content, err := zf.render("config.tpl", map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+		zf.write(name, configImport, content)
+	}
+	if name := "internal/svc/" + zf.fileName("ServiceContext") + ".go"; !zf.exists(name) {
+		content, err := zf.render("svc.tpl", map[string]interface{}{
+			"imports": fmt.Sprintf("%q", configImport),
+		})
+		if err != nil {
+			return err
+		}
+		zf.write(name, svcImport, content)
+	}
+	if name := "etc/" + zf.name + ".yaml"; !zf.exists(name) {
+		content, err := zf.render("etc.tpl", map[string]interface{}{
+			"serviceName": zf.name,
+		})
+		if err != nil {
+			return err
+		}
+		zf.write(name, "", content)
+	}
+	if name := zf.fileName(zf.name) + ".go"; !zf.exists(name) {
+		im := newGoImports()
+		im.add(configImport, "config")
+		im.add(svcImport, "svc")
+		pb := zf.pkg(im)
+		type serviceName struct {
+			Pkg, GRPCService, ServerPkg, Service string
+		}
+		var services []serviceName
+		for _, svc := range zf.file.Services {
+			alias := "server"
+			if zf.multiple {
+				alias = zf.serverPkg(svc) + "server"
+			}
+			services = append(services, serviceName{
+				Pkg:         pb,
+				GRPCService: svc.GoName,
+				ServerPkg:   im.add(zf.module+"/"+zf.serverDir(svc), alias),
+				Service:     svc.GoName,
+			})
+		}
+		content, err := zf.render("main.tpl", map[string]interface{}{
+			"serviceName":  zf.name,
+			"imports":      im.String(),
+			"serviceNames": services,
+		})
+		if err != nil {
+			return err
+		}
+		zf.write(name, zf.module, content)
+	}
+	return nil
+}
+
+// genCall generates the zrpc clients, with aliases of the message types.
+func genCall(zf *zrpcFile) error {
+	for _, svc := range zf.file.Services {
+		im := newGoImports()
+		pb := zf.pkg(im)
+
+		var aliases []string
+		var walk func(msgs []*protogen.Message)
+		walk = func(msgs []*protogen.Message) {
+			for _, msg := range msgs {
+				if msg.Desc.IsMapEntry() {
+					continue
+				}
+				aliases = append(aliases, fmt.Sprintf("%s = %s.%s", msg.GoIdent.GoName, pb, msg.GoIdent.GoName))
+				walk(msg.Messages)
+			}
+		}
+		walk(zf.file.Messages)
+
+		var interfaces, funcs []string
+		for _, m := range svc.Methods {
+			data := map[string]interface{}{
+				"serviceName":            svc.GoName,
+				"rpcServiceName":         svc.GoName,
+				"method":                 m.GoName,
+				"package":                pb,
+				"isCallPkgSameToGrpcPkg": false,
+				"pbRequest":              zf.typeName(im, m.Input),
+				"pbResponse":             zf.typeName(im, m.Output),
+				"hasReq":                 !m.Desc.IsStreamingClient(),
+				"notStream":              !isStreaming(m),
+				"streamBody":             fmt.Sprintf("%s.%s_%sClient", pb, svc.GoName, m.GoName),
+				"hasComment":             len(m.Comments.Leading) > 0,
+				"comment":                methodComment(m),
+			}
+			fn, err := zf.render("call-interface-func.tpl", data)
+			if err != nil {
+				return err
+			}
+			interfaces = append(interfaces, strings.TrimSpace(fn))
+			if fn, err = zf.render("call-func.tpl", data); err != nil {
+				return err
+			}
+			funcs = append(funcs, fn)
+		}
+
+		// the types of other packages, such as emptypb.Empty, are imported after the pb package
+		pbImport := fmt.Sprintf("%q", zf.pbImport)
+		if pb != path.Base(zf.pbImport) {
+			pbImport = pb + " " + pbImport
+		}
+		protoGoPackage := pbImport
+		if others := im.specs(zf.pbImport); others != "" {
+			protoGoPackage = others
+		}
+		dir := zf.callDir(svc)
+		content, err := zf.render("call.tpl", map[string]interface{}{
+			"head":           zf.head(zf.file),
+			"filePackage":    packageName(svc.GoName),
+			"pbPackage":      pbImport,
+			"protoGoPackage": protoGoPackage,
+			"alias":          strings.Join(aliases, "\n\t"),
+			"serviceName":    svc.GoName,
+			"interface":      strings.Join(interfaces, "\n\t\t"),
+			"functions":      strings.Join(funcs, "\n"),
+		})
+		if err != nil {
+			return err
+		}
+		zf.write(dir+"/"+zf.fileName(svc.GoName)+".go", zf.module+"/"+dir, content)
+	}
+	return nil
+}
@@ -50,7 +50,8 @@
 //	# other keys indicate plugin names and their config
 //	plugin_name:
 //	  # optional path to where plugin file resides - can be path to
-//	  # plugin itself or directory that contains plugin
+//	  # plugin itself or directory that contains plugin, or "builtin"
+//	  # to run the builtin plugin of that name
 //	  location: "/foo/bar/plugin_name"
 //	  # optional arguments to supply to this plugin
 //	  params: ["frobnitz=off"]
@@ -69,6 +70,30 @@
 // registered when the plugin binary was initialized. If a given protoc plugin
 // is *not* a Go plugin or fails to register any plugins, it will then be
 // invoked as a standard protoc plugin executable.
+//
+// # Builtin Plugins
+//
+// The plugins of the builtin package are compiled into protoc-gen-gox: "go"
+// and "grpc", which generate the same code as protoc-gen-go and
+// protoc-gen-go-grpc, and "zrpc-server", "zrpc-logic", "zrpc-svc", "zrpc-call"
+// and "zrpc-gateway", which generate a go-zero RPC service like goctl does,
+// with REST routes mapped from the google.api.http annotations.
+//
+// The zrpc plugins are run in-process, unless a location is configured for
+// them. The "go" and "grpc" ones are opt-in, so that protoc-gen-go and
+// protoc-gen-go-grpc are still run by default; they are run in-process only
+// with the "builtin" location. With this config file, a single protoc
+// invocation generates a whole service without any other executable:
+//
+//	go:
+//	  location: builtin
+//	grpc:
+//	  location: builtin
+//
+//	protoc --gox_out=config=gox.yaml,+grpc,+zrpc-server,+zrpc-logic,+zrpc-svc,+zrpc-call:. user.proto
+//
+// The zrpc plugins accept "module", "dir", "style" and "home" parameters,
+// given in the config file, see the builtin package for their meaning.
 package main
 
 import (
@@ -82,6 +107,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v2"
 
+	_ "github.com/jhump/goprotoc/cmd/protoc-gen-gox/builtin"
 	"github.com/jhump/goprotoc/cmd/protoc-gen-gox/goxplugin"
 	"github.com/jhump/goprotoc/plugins"
 )
@@ -95,15 +121,20 @@ func doCodeGen(req *plugins.CodeGenRequest, resp *plugins.CodeGenResponse) error
 	if err != nil {
 		return err
 	}
-	if err := resolveLocations(conf); err != nil {
+	// the plugins registered before loading any Go plugin are the builtin ones
+	reg := goxplugin.GetAll()
+	if err := resolveLocations(conf, reg); err != nil {
 		return err
 	}
 
 	asGoPlugin := map[string]*pluginConfig{}
 	asExecutable := map[string]*pluginConfig{}
 
-	reg := goxplugin.GetAll()
 	for plName, plConf := range conf.plugins {
+		if inProcess(plName, plConf, reg) {
+			asGoPlugin[plName] = plConf
+			continue
+		}
 		// try to load them as Go plugins first
 		if _, err := plugin.Open(plConf.Location); err != nil {
 			asExecutable[plName] = plConf
@@ -337,8 +368,40 @@ type pluginConfig struct {
 	Params   []string `yaml:"params,omitempty"`
 }
 
-func resolveLocations(conf *effectiveConfig) error {
+// builtinLocation is the location of the builtin plugins.
+const builtinLocation = "builtin"
+
+// optInBuiltins are the builtin plugins that replace a standard plugin, they
+// are only run in-process with the builtin location.
+var optInBuiltins = map[string]bool{
+	"go":   true,
+	"grpc": true,
+}
+
+// inProcess returns true if plName is a builtin plugin that should be run
+// in-process.
+func inProcess(plName string, plConf *pluginConfig, builtins map[string]plugins.Plugin) bool {
+	if _, ok := builtins[plName]; !ok {
+		return false
+	}
+	switch plConf.Location {
+	case builtinLocation:
+		return true
+	case "":
+		return !optInBuiltins[plName]
+	default:
+		return false
+	}
+}
+
+func resolveLocations(conf *effectiveConfig, builtins map[string]plugins.Plugin) error {
 	for plName, plConf := range conf.plugins {
+		if inProcess(plName, plConf, builtins) {
+			continue
+		}
+		if plConf.Location == builtinLocation {
+			return fmt.Errorf("%s: there is no builtin plugin with this name", plName)
+		}
 		paths := conf.pluginPath
 		if plConf.Location != "" {
 			// validate configuration if it's present
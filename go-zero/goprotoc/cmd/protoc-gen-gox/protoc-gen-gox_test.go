@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jhump/goprotoc/plugins"
+)
+
+func TestInProcess(t *testing.T) {
+	builtins := map[string]plugins.Plugin{
+		"go":          nil,
+		"grpc":        nil,
+		"zrpc-server": nil,
+	}
+	testCases := []struct {
+		name, location string
+		expectedResult bool
+	}{
+		{name: "go", location: "", expectedResult: false},
+		{name: "go", location: builtinLocation, expectedResult: true},
+		{name: "grpc", location: "", expectedResult: false},
+		{name: "grpc", location: builtinLocation, expectedResult: true},
+		{name: "zrpc-server", location: "", expectedResult: true},
+		{name: "zrpc-server", location: "/usr/bin/protoc-gen-zrpc-server", expectedResult: false},
+		{name: "foo", location: builtinLocation, expectedResult: false},
+	}
+	for _, tc := range testCases {
+		result := inProcess(tc.name, &pluginConfig{Location: tc.location}, builtins)
+		if result != tc.expectedResult {
+			t.Errorf("inProcess(%q, %q): expected %v, got %v", tc.name, tc.location, tc.expectedResult, result)
+		}
+	}
+
+	conf := &effectiveConfig{plugins: map[string]*pluginConfig{
+		"foo": {Location: builtinLocation},
+	}}
+	if err := resolveLocations(conf, builtins); err == nil {
+		t.Errorf("resolveLocations: expected an error for an unknown builtin plugin")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"github.com/Mikaelemmmm/sql2pb/tools/stringx"
 	"github.com/samber/lo"
-	"log"
 	"regexp"
 	"sort"
 	"strings"
@@ -36,30 +35,74 @@ const (
 // the protobuf types. The schema reflects the layout of a protobuf file and should be used
 // to pipe the output of the `Schema.String()` to a file.
 func GenerateSchema(db *sql.DB, table string, ignoreTables, ignoreColumns []string, serviceName, goPkg, pkg, fieldStyle string) (*Schema, error) {
-	s := &Schema{}
+	return GenerateSchemaWithOptions(db, Options{
+		Dialect:       DialectMysql,
+		Table:         table,
+		IgnoreTables:  ignoreTables,
+		IgnoreColumns: ignoreColumns,
+		ServiceName:   serviceName,
+		GoPackage:     goPkg,
+		Package:       pkg,
+		FieldStyle:    fieldStyle,
+	})
+}
 
-	dbs, err := dbSchema(db)
+// Options configures GenerateSchemaWithOptions.
+type Options struct {
+	// Dialect is one of mysql | postgres | sqlite, defaults to mysql
+	Dialect string
+	// Table is the table filter, multiple tables ',' split, `*` or empty means all tables
+	Table         string
+	IgnoreTables  []string
+	IgnoreColumns []string
+	ServiceName   string
+	GoPackage     string
+	Package       string
+	FieldStyle    string
+	TypeMapping   TypeMapping
+}
+
+// GenerateSchemaWithOptions generates a protobuf schema like GenerateSchema, for any supported
+// database dialect and with a configurable type mapping.
+func GenerateSchemaWithOptions(db *sql.DB, opts Options) (*Schema, error) {
+	dialect, err := NormalizeDialect(opts.Dialect)
 	if nil != err {
 		return nil, err
 	}
 
-	s.Syntax = proto3
-	s.ServiceName = serviceName
-	if "" != pkg {
-		s.Package = pkg
-	}
-	if "" != goPkg {
-		s.GoPackage = goPkg
-	} else {
-		s.GoPackage = "./" + s.Package
+	dbs, err := dbSchema(db, dialect)
+	if nil != err {
+		return nil, err
 	}
 
-	cols, err := dbColumns(db, dbs, table)
+	cols, err := dbColumns(db, dialect, dbs, opts.Table)
 	if nil != err {
 		return nil, err
 	}
 
-	err = typesFromColumns(s, cols, ignoreTables, ignoreColumns, fieldStyle)
+	return generateSchema(cols, opts)
+}
+
+// generateSchema generates the schema of the columns read from a database or parsed from DDL.
+func generateSchema(cols []Column, opts Options) (*Schema, error) {
+	if err := opts.TypeMapping.Validate(); nil != err {
+		return nil, err
+	}
+
+	s := &Schema{Mapping: opts.TypeMapping}
+
+	s.Syntax = proto3
+	s.ServiceName = opts.ServiceName
+	if "" != opts.Package {
+		s.Package = opts.Package
+	}
+	if "" != opts.GoPackage {
+		s.GoPackage = opts.GoPackage
+	} else {
+		s.GoPackage = "./" + s.Package
+	}
+
+	err := typesFromColumns(s, cols, opts.IgnoreTables, opts.IgnoreColumns, opts.FieldStyle)
 	if nil != err {
 		return nil, err
 	}
@@ -113,58 +156,6 @@ func typesFromColumns(s *Schema, cols []Column, ignoreTables, ignoreColumns []st
 	return nil
 }
 
-func dbSchema(db *sql.DB) (string, error) {
-	var schema string
-
-	err := db.QueryRow("SELECT SCHEMA()").Scan(&schema)
-
-	return schema, err
-}
-
-func dbColumns(db *sql.DB, schema, table string) ([]Column, error) {
-
-	tableArr := strings.Split(table, ",")
-
-	q := "SELECT c.TABLE_NAME, c.COLUMN_NAME, c.IS_NULLABLE, c.DATA_TYPE, " +
-		"c.CHARACTER_MAXIMUM_LENGTH, c.NUMERIC_PRECISION, c.NUMERIC_SCALE, c.COLUMN_TYPE ,c.COLUMN_COMMENT,t.TABLE_COMMENT " +
-		"FROM INFORMATION_SCHEMA.COLUMNS as c  LEFT JOIN  INFORMATION_SCHEMA.TABLES as t  on c.TABLE_NAME = t.TABLE_NAME and  c.TABLE_SCHEMA = t.TABLE_SCHEMA" +
-		" WHERE c.TABLE_SCHEMA = ?"
-
-	if table != "" && table != "*" {
-		q += " AND c.TABLE_NAME IN('" + strings.TrimRight(strings.Join(tableArr, "' ,'"), ",") + "')"
-	}
-
-	q += " ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION"
-
-	rows, err := db.Query(q, schema)
-	defer rows.Close()
-	if nil != err {
-		return nil, err
-	}
-
-	cols := []Column{}
-
-	for rows.Next() {
-		cs := Column{}
-		err := rows.Scan(&cs.TableName, &cs.ColumnName, &cs.IsNullable, &cs.DataType,
-			&cs.CharacterMaximumLength, &cs.NumericPrecision, &cs.NumericScale, &cs.ColumnType, &cs.ColumnComment, &cs.TableComment)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if cs.TableComment == "" {
-			cs.TableComment = stringx.From(cs.TableName).ToCamelWithStartLower()
-		}
-
-		cols = append(cols, cs)
-	}
-	if err := rows.Err(); nil != err {
-		return nil, err
-	}
-
-	return cols, nil
-}
-
 // Schema is a representation of a protobuf schema.
 type Schema struct {
 	Syntax      string
@@ -174,6 +165,9 @@ type Schema struct {
 	Imports     sort.StringSlice
 	Messages    MessageCollection
 	Enums       EnumCollection
+	Mapping     TypeMapping
+
+	previous *ProtoFile
 }
 
 // MessageCollection represents a sortable collection of messages.
@@ -222,6 +216,36 @@ func (s *Schema) AppendImport(imports string) {
 
 }
 
+// MergeFrom makes String merge the schema into a previously generated .proto file: fields keep their tags,
+// dropped fields become reserved, and the messages, enums, rpcs, imports and options which are not
+// generated are kept as they are.
+func (s *Schema) MergeFrom(previous *ProtoFile) {
+	s.previous = previous
+}
+
+// generatedNames returns the names of the messages and rpcs generated for the schema.
+func (s *Schema) generatedNames() (map[string]bool, map[string]bool) {
+	messages, rpcs := map[string]bool{}, map[string]bool{}
+	for _, m := range s.Messages {
+		messages[m.Name] = true
+		for _, action := range []string{"Insert", "Update", "Del"} {
+			messages[action+m.Name+"Req"] = true
+			messages[action+m.Name+"Resp"] = true
+			rpcs[action+m.Name] = true
+		}
+		messages["Find"+m.Name+"ByIdReq"] = true
+		messages["Find"+m.Name+"ByIdResp"] = true
+		messages["List"+m.Name+"Req"] = true
+		messages["List"+m.Name+"Resp"] = true
+		rpcs["Find"+m.Name+"ById"] = true
+		rpcs["List"+m.Name] = true
+	}
+	for _, e := range s.Enums {
+		messages[e.Name] = true
+	}
+	return messages, rpcs
+}
+
 // String returns a string representation of a Schema.
 func (s *Schema) String() string {
 	buf := new(bytes.Buffer)
@@ -229,17 +253,28 @@ func (s *Schema) String() string {
 	buf.WriteString("\n")
 	buf.WriteString(fmt.Sprintf("package %s;\n", s.Package))
 	buf.WriteString("\n")
-	lo.ForEach(s.Imports, func(item string, index int) {
+	imports := s.Imports
+	if s.previous != nil {
+		imports = lo.Uniq(append(append(sort.StringSlice{}, s.Imports...), s.previous.Imports...))
+		sort.Sort(imports)
+	}
+	lo.ForEach(imports, func(item string, index int) {
 		buf.WriteString(fmt.Sprintf("import \"%s\";\n", item))
 	})
 	buf.WriteString("\n")
 	buf.WriteString(fmt.Sprintf("option go_package =\"%s\";\n", s.GoPackage))
+	if s.previous != nil {
+		for _, option := range s.previous.Options {
+			buf.WriteString(option + "\n")
+		}
+	}
 	buf.WriteString("\n")
 	buf.WriteString("// ------------------------------------ \n")
 	buf.WriteString("// Messages\n")
 	buf.WriteString("// ------------------------------------ \n\n")
 
 	for _, m := range s.Messages {
+		m.previous = s.previous
 		buf.WriteString("//--------------------------------" + m.Comment + "--------------------------------")
 		buf.WriteString("\n")
 		m.GenDefaultMessage(buf)
@@ -262,6 +297,24 @@ func (s *Schema) String() string {
 		}
 	}
 
+	generatedMessages, generatedRpcs := s.generatedNames()
+	if s.previous != nil {
+		var custom []string
+		for _, d := range s.previous.Decls {
+			if (d.Kind == "message" || d.Kind == "enum") && !generatedMessages[d.Name] || d.Kind == "extend" {
+				custom = append(custom, d.Raw)
+			}
+		}
+		if len(custom) > 0 {
+			buf.WriteString("// ------------------------------------ \n")
+			buf.WriteString("// Custom Messages\n")
+			buf.WriteString("// ------------------------------------ \n\n")
+			for _, raw := range custom {
+				buf.WriteString(raw + "\n\n")
+			}
+		}
+	}
+
 	buf.WriteString("\n")
 	buf.WriteString("// ------------------------------------ \n")
 	buf.WriteString("// Rpc Func\n")
@@ -276,9 +329,31 @@ func (s *Schema) String() string {
 		funcTpl += "\t rpc Find" + m.Name + "ById(Find" + m.Name + "ByIdReq) returns (Find" + m.Name + "ByIdResp); \n"
 		funcTpl += "\t rpc List" + m.Name + "(List" + m.Name + "Req) returns (List" + m.Name + "Resp); \n"
 	}
+	if s.previous != nil {
+		if svc, ok := s.previous.Services[s.ServiceName]; ok {
+			var custom []string
+			for _, rpc := range svc.Rpcs {
+				if !generatedRpcs[rpc.Name] {
+					custom = append(custom, "\t "+rpc.Raw+"\n")
+				}
+			}
+			if len(custom) > 0 {
+				funcTpl += "\n\t //----------------------- custom ----------------------- \n\n"
+				funcTpl += strings.Join(custom, "")
+			}
+		}
+	}
 	funcTpl = funcTpl + "\n}"
 	buf.WriteString(funcTpl)
 
+	if s.previous != nil {
+		for _, d := range s.previous.Decls {
+			if d.Kind == "service" && d.Name != s.ServiceName {
+				buf.WriteString("\n\n" + d.Raw)
+			}
+		}
+	}
+
 	return buf.String()
 }
 
@@ -375,6 +450,8 @@ type Message struct {
 	Comment string
 	Fields  []MessageField
 	Style   string
+
+	previous *ProtoFile
 }
 
 // GenDefaultMessage gen default message
@@ -627,7 +704,18 @@ func (m Message) String() string {
 	var buf bytes.Buffer
 
 	buf.WriteString(fmt.Sprintf("message %s {\n", m.Name))
-	for _, f := range m.Fields {
+	fields := m.Fields
+	if m.previous != nil {
+		if prev, ok := m.previous.Messages[m.Name]; ok {
+			var (
+				reserved      [][2]int
+				reservedNames []string
+			)
+			fields, reserved, reservedNames = prev.assignTags(fields)
+			buf.WriteString(formatReserved(reserved, reservedNames))
+		}
+	}
+	for _, f := range fields {
 		comment := strings.ReplaceAll(f.Comment, "\n", "")
 		buf.WriteString(fmt.Sprintf("%s%s; //%s\n", indent, f, comment))
 	}
@@ -700,15 +788,16 @@ func parseColumn(s *Schema, msg *Message, col Column) error {
 	var fieldType string
 
 	switch typ {
-	case "char", "varchar", "text", "longtext", "mediumtext", "tinytext":
+	case "char", "varchar", "text", "longtext", "mediumtext", "tinytext",
+		"character", "character varying", "uuid", "citext", "inet", "cidr", "macaddr", "interval", "xml":
 		fieldType = "string"
 	case "enum", "set":
 		// Parse c.ColumnType to get the enum list
-		enumList := regexp.MustCompile(`[enum|set]\((.+?)\)`).FindStringSubmatch(col.ColumnType)
-		enums := strings.FieldsFunc(enumList[1], func(c rune) bool {
-			cs := string(c)
-			return "," == cs || "'" == cs
-		})
+		enumList := regexp.MustCompile(`[enum|set]\((.+)\)`).FindStringSubmatch(col.ColumnType)
+		if len(enumList) < 2 {
+			return fmt.Errorf("no enum values found in `%s`. column: `%s`.`%s`", col.ColumnType, col.TableName, col.ColumnName)
+		}
+		enums := enumLabels(enumList[1])
 
 		enumName := inflect.Singularize(snaker.SnakeToCamel(col.TableName)) + snaker.SnakeToCamel(col.ColumnName)
 		enum, err := newEnumFromStrings(enumName, col.ColumnComment, enums)
@@ -719,30 +808,50 @@ func parseColumn(s *Schema, msg *Message, col Column) error {
 		s.Enums = append(s.Enums, enum)
 
 		fieldType = enumName
-	case "blob", "mediumblob", "longblob", "varbinary", "binary":
+	case "blob", "mediumblob", "longblob", "tinyblob", "varbinary", "binary", "bytea":
 		fieldType = "bytes"
-	case "date", "time", "datetime", "timestamp":
+	case "date", "time", "datetime", "timestamp",
+		"timestamp without time zone", "timestamp with time zone", "time without time zone", "time with time zone":
 		s.AppendImport("google/protobuf/timestamp.proto")
 		fieldType = "google.protobuf.Timestamp"
-	case "bool", "bit":
+	case "bool", "boolean", "bit":
 		fieldType = "bool"
 	case "bigint":
-		fieldType = "int64"
-	case "tinyint", "smallint", "int", "mediumint":
+		fieldType = s.Mapping.integerType(col, 64)
+	case "int", "mediumint", "integer":
+		// unsigned int doesn't fit in int32
+		fieldType = s.Mapping.integerType(col, 32)
+	case "tinyint", "smallint", "year":
 		if col.ColumnType == "tinyint(1)" {
 			fieldType = "bool"
 		} else {
-			fieldType = "int32"
+			fieldType = s.Mapping.integerType(col, 32)
 		}
-	case "float", "decimal", "double":
+	case "float", "double", "real", "double precision":
 		fieldType = "double"
-	case "json":
-		fieldType = "string"
+	case "decimal", "numeric", "money":
+		fieldType = s.Mapping.decimalType(s)
+	case "json", "jsonb":
+		fieldType = s.Mapping.jsonType(s)
+	case "array":
+		// postgres array, the udt name of the element type is prefixed with `_`
+		elem := col
+		elem.DataType = postgresArrayElemType(col.ColumnType)
+		elem.ColumnType = elem.DataType
+		elem.IsNullable = "NO"
+		tmp := &Message{}
+		if err := parseColumn(s, tmp, elem); nil != err {
+			return err
+		}
+		fieldType = "repeated " + tmp.Fields[0].Typ
 	}
 
 	if "" == fieldType {
 		return fmt.Errorf("no compatible protobuf type found for `%s`. column: `%s`.`%s`", col.DataType, col.TableName, col.ColumnName)
 	}
+	if !strings.HasPrefix(fieldType, "repeated ") {
+		fieldType = s.Mapping.nullableType(s, col, fieldType)
+	}
 
 	field := NewMessageField(fieldType, col.ColumnName, len(msg.Fields)+1, col.ColumnComment)
 
@@ -754,6 +863,55 @@ func parseColumn(s *Schema, msg *Message, col Column) error {
 	return nil
 }
 
+// enumLabels splits the quoted labels of an enum or set type, a quote inside a label is doubled.
+func enumLabels(list string) []string {
+	var (
+		labels []string
+		label  strings.Builder
+		quoted bool
+	)
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+		switch {
+		case c == '\'' && quoted && i+1 < len(list) && list[i+1] == '\'':
+			label.WriteByte(c)
+			i++
+		case c == '\'':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			labels = append(labels, label.String())
+			label.Reset()
+		default:
+			label.WriteByte(c)
+		}
+	}
+	return append(labels, label.String())
+}
+
+// postgresArrayElemType maps the udt name of a postgres array element to its data type.
+func postgresArrayElemType(udtName string) string {
+	switch elem := strings.TrimPrefix(udtName, "_"); elem {
+	case "int2":
+		return "smallint"
+	case "int4":
+		return "integer"
+	case "int8":
+		return "bigint"
+	case "float4", "float8":
+		return "double"
+	case "bool":
+		return "boolean"
+	case "bpchar":
+		return "character"
+	case "timestamptz":
+		return "timestamp with time zone"
+	case "timetz":
+		return "time with time zone"
+	default:
+		return elem
+	}
+}
+
 func isInSlice(slice []string, s string) bool {
 	for i, _ := range slice {
 		if slice[i] == s {
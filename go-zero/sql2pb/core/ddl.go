@@ -0,0 +1,607 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Mikaelemmmm/sql2pb/tools/stringx"
+)
+
+// GenerateSchemaFromDDL generates a protobuf schema like GenerateSchemaWithOptions, from `CREATE TABLE`
+// statements instead of a live database. opts.Dialect selects the DDL flavour.
+func GenerateSchemaFromDDL(ddl string, opts Options) (*Schema, error) {
+	dialect, err := NormalizeDialect(opts.Dialect)
+	if nil != err {
+		return nil, err
+	}
+	cols, err := ParseDDL(ddl, dialect)
+	if nil != err {
+		return nil, err
+	}
+
+	if tables := splitTables(opts.Table); len(tables) > 0 {
+		filtered := cols[:0]
+		for _, c := range cols {
+			if isInSlice(tables, c.TableName) {
+				filtered = append(filtered, c)
+			}
+		}
+		cols = filtered
+	}
+	for i := range cols {
+		if cols[i].TableComment == "" {
+			cols[i].TableComment = stringx.From(cols[i].TableName).ToCamelWithStartLower()
+		}
+	}
+
+	return generateSchema(cols, opts)
+}
+
+// ReadDDLFiles reads and joins the DDL files, patterns like `./sql/*.sql` are expanded.
+func ReadDDLFiles(patterns []string) (string, []string, error) {
+	var (
+		buf   strings.Builder
+		files []string
+	)
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(matches) == 0 {
+			return "", nil, fmt.Errorf("no ddl file matches `%s`", pattern)
+		}
+		for _, file := range matches {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return "", nil, err
+			}
+			buf.Write(data)
+			buf.WriteString(";\n")
+			files = append(files, file)
+		}
+	}
+	return buf.String(), files, nil
+}
+
+// ParseDDL parses the `CREATE TABLE` statements of a mysql, postgres or sqlite DDL script into columns.
+// Postgres `CREATE TYPE ... AS ENUM` and `COMMENT ON` statements are supported as well,
+// other statements are ignored.
+func ParseDDL(ddl, dialect string) ([]Column, error) {
+	tokens, err := sqlTokenize(ddl)
+	if nil != err {
+		return nil, err
+	}
+
+	p := &ddlParser{
+		dialect: dialect,
+		enums:   map[string][]string{},
+		tables:  map[string]*ddlTable{},
+	}
+	for _, stmt := range splitStatements(tokens) {
+		if err := p.statement(stmt); nil != err {
+			return nil, err
+		}
+	}
+
+	cols := []Column{}
+	for _, name := range p.order {
+		t := p.tables[name]
+		for _, c := range t.columns {
+			c.TableComment = t.comment
+			if comment, ok := t.columnComments[c.ColumnName]; ok {
+				c.ColumnComment = comment
+			}
+			if isInSlice(t.primaryKey, c.ColumnName) {
+				c.IsNullable = "NO"
+			}
+			if labels, ok := p.enums[strings.ToLower(c.DataType)]; ok {
+				c.DataType = "enum"
+				c.ColumnType = enumColumnType(labels)
+			}
+			cols = append(cols, c)
+		}
+	}
+
+	return cols, nil
+}
+
+type sqlTokenKind int
+
+const (
+	sqlWord sqlTokenKind = iota
+	sqlQuotedIdent
+	sqlString
+	sqlNumber
+	sqlSymbol
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+func (t sqlToken) is(words ...string) bool {
+	if t.kind != sqlWord && t.kind != sqlSymbol {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlTokenize splits a DDL script into tokens, comments are dropped.
+func sqlTokenize(src string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-', r == '#':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			j := i + 2
+			for j+1 < len(rs) && !(rs[j] == '*' && rs[j+1] == '/') {
+				j++
+			}
+			if j+1 >= len(rs) {
+				return nil, fmt.Errorf("unterminated comment in ddl")
+			}
+			i = j + 2
+		case r == '\'' || r == '"' || r == '`' || r == '[':
+			closing := r
+			if r == '[' {
+				// `[]` is a postgres array type, `[name]` a quoted identifier
+				if i+1 < len(rs) && rs[i+1] == ']' {
+					tokens = append(tokens, sqlToken{sqlSymbol, "[]"})
+					i += 2
+					continue
+				}
+				closing = ']'
+			}
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(rs); j++ {
+				if rs[j] == '\\' && r == '\'' && j+1 < len(rs) {
+					j++
+					sb.WriteRune(rs[j])
+					continue
+				}
+				if rs[j] == closing {
+					if j+1 < len(rs) && rs[j+1] == closing && closing != ']' {
+						sb.WriteRune(closing)
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated quote %c in ddl", r)
+			}
+			kind := sqlQuotedIdent
+			if r == '\'' {
+				kind = sqlString
+			}
+			tokens = append(tokens, sqlToken{kind, sb.String()})
+			i = j + 1
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '$') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{sqlWord, string(rs[i:j])})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{sqlNumber, string(rs[i:j])})
+			i = j
+		default:
+			tokens = append(tokens, sqlToken{sqlSymbol, string(r)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func splitStatements(tokens []sqlToken) [][]sqlToken {
+	var (
+		stmts [][]sqlToken
+		start int
+	)
+	for i, t := range tokens {
+		if t.kind == sqlSymbol && t.text == ";" {
+			if i > start {
+				stmts = append(stmts, tokens[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		stmts = append(stmts, tokens[start:])
+	}
+	return stmts
+}
+
+// splitTopLevel splits tokens by commas which are not inside parentheses.
+func splitTopLevel(tokens []sqlToken) [][]sqlToken {
+	var (
+		parts [][]sqlToken
+		depth int
+		start int
+	)
+	for i, t := range tokens {
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case t.is(",") && depth == 0:
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		parts = append(parts, tokens[start:])
+	}
+	return parts
+}
+
+// matchParen returns the index of the parenthesis closing tokens[open].
+func matchParen(tokens []sqlToken, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is("("):
+			depth++
+		case tokens[i].is(")"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+type ddlTable struct {
+	columns        []Column
+	comment        string
+	columnComments map[string]string
+	primaryKey     []string
+}
+
+type ddlParser struct {
+	dialect string
+	enums   map[string][]string
+	tables  map[string]*ddlTable
+	order   []string
+}
+
+func (p *ddlParser) table(name string) *ddlTable {
+	t, ok := p.tables[name]
+	if !ok {
+		t = &ddlTable{columnComments: map[string]string{}}
+		p.tables[name] = t
+		p.order = append(p.order, name)
+	}
+	return t
+}
+
+// qualifiedName reads `schema.name` at tokens[i] and returns the last part and the next index.
+func qualifiedName(tokens []sqlToken, i int) (string, int) {
+	var name string
+	for i < len(tokens) {
+		name = tokens[i].text
+		i++
+		if i < len(tokens) && tokens[i].is(".") {
+			i++
+			continue
+		}
+		break
+	}
+	return name, i
+}
+
+func (p *ddlParser) statement(stmt []sqlToken) error {
+	switch {
+	case len(stmt) > 2 && stmt[0].is("create"):
+		i := 1
+		for i < len(stmt) && stmt[i].is("temporary", "temp", "unlogged", "or", "replace") {
+			i++
+		}
+		if i < len(stmt) && stmt[i].is("table") {
+			return p.createTable(stmt, i+1)
+		}
+		if i < len(stmt) && stmt[i].is("type") {
+			p.createType(stmt, i+1)
+		}
+	case len(stmt) > 4 && stmt[0].is("comment") && stmt[1].is("on"):
+		p.commentOn(stmt)
+	}
+	return nil
+}
+
+func (p *ddlParser) createTable(stmt []sqlToken, i int) error {
+	if i+2 < len(stmt) && stmt[i].is("if") && stmt[i+1].is("not") && stmt[i+2].is("exists") {
+		i += 3
+	}
+	name, i := qualifiedName(stmt, i)
+	if i >= len(stmt) || !stmt[i].is("(") {
+		// CREATE TABLE ... LIKE / AS SELECT
+		return nil
+	}
+	end := matchParen(stmt, i)
+	if end < 0 {
+		return fmt.Errorf("unbalanced parentheses in create table `%s`", name)
+	}
+
+	t := p.table(name)
+	for _, def := range splitTopLevel(stmt[i+1 : end]) {
+		if len(def) == 0 {
+			continue
+		}
+		if def[0].kind == sqlWord && def[0].is("primary", "key", "index", "unique", "constraint", "foreign",
+			"check", "fulltext", "spatial", "exclude", "like") {
+			if pk := primaryKeyColumns(def); pk != nil {
+				t.primaryKey = append(t.primaryKey, pk...)
+			}
+			continue
+		}
+		col, err := p.column(name, def)
+		if nil != err {
+			return err
+		}
+		t.columns = append(t.columns, col)
+	}
+
+	// table options: COMMENT='...'
+	for j := end + 1; j < len(stmt); j++ {
+		if stmt[j].is("comment") {
+			k := j + 1
+			if k < len(stmt) && stmt[k].is("=") {
+				k++
+			}
+			if k < len(stmt) && isDDLString(stmt[k]) {
+				t.comment = stmt[k].text
+			}
+		}
+	}
+	return nil
+}
+
+// primaryKeyColumns returns the columns of a `[CONSTRAINT x] PRIMARY KEY (a, b)` definition.
+func primaryKeyColumns(def []sqlToken) []string {
+	for i := 0; i+1 < len(def); i++ {
+		if !def[i].is("primary") || !def[i+1].is("key") {
+			continue
+		}
+		for j := i + 2; j < len(def); j++ {
+			if !def[j].is("(") {
+				continue
+			}
+			end := matchParen(def, j)
+			if end < 0 {
+				return nil
+			}
+			var cols []string
+			for _, part := range splitTopLevel(def[j+1 : end]) {
+				if len(part) > 0 {
+					cols = append(cols, part[0].text)
+				}
+			}
+			return cols
+		}
+	}
+	return nil
+}
+
+// columnAttributes the words that end the type of a column definition.
+var columnAttributes = []string{"not", "null", "default", "primary", "unique", "comment", "auto_increment",
+	"autoincrement", "references", "check", "constraint", "collate", "generated", "on", "key", "as",
+	"identity", "visible", "invisible", "storage", "column_format", "srid"}
+
+func (p *ddlParser) column(table string, def []sqlToken) (Column, error) {
+	col := Column{TableName: table, ColumnName: def[0].text, IsNullable: "YES"}
+
+	var (
+		words []string
+		args  string
+		array bool
+		i     = 1
+	)
+	for ; i < len(def); i++ {
+		t := def[i]
+		if t.kind == sqlWord && t.is(columnAttributes...) {
+			break
+		}
+		// CHARACTER SET / CHARSET after the type
+		if len(words) > 0 && (t.is("charset") || (t.is("character", "char") && i+1 < len(def) && def[i+1].is("set"))) {
+			break
+		}
+		switch {
+		case t.is("("):
+			end := matchParen(def, i)
+			if end < 0 {
+				return col, fmt.Errorf("unbalanced parentheses in column `%s`.`%s`", table, col.ColumnName)
+			}
+			var parts []string
+			for _, tk := range def[i+1 : end] {
+				if tk.kind == sqlString {
+					parts = append(parts, "'"+strings.ReplaceAll(tk.text, "'", "''")+"'")
+				} else {
+					parts = append(parts, tk.text)
+				}
+			}
+			args = "(" + strings.Join(parts, "") + ")"
+			i = end
+		case t.is("[]"), t.is("array"):
+			array = true
+		case t.kind == sqlWord:
+			words = append(words, strings.ToLower(t.text))
+		}
+	}
+	if len(words) == 0 {
+		return col, fmt.Errorf("no type found for column `%s`.`%s`", table, col.ColumnName)
+	}
+
+	// the modifiers follow the arguments, `int(10) unsigned`
+	var base, modifiers []string
+	for _, w := range words {
+		if w == "unsigned" || w == "signed" || w == "zerofill" {
+			modifiers = append(modifiers, w)
+		} else {
+			base = append(base, w)
+		}
+	}
+	baseType := strings.Join(base, " ")
+	col.ColumnType = strings.TrimSpace(baseType + args + " " + strings.Join(modifiers, " "))
+	col.DataType = ddlDataType(baseType)
+	setColumnSize(&col, args)
+
+	for ; i < len(def); i++ {
+		t := def[i]
+		switch {
+		case t.is("not") && i+1 < len(def) && def[i+1].is("null"):
+			col.IsNullable = "NO"
+			i++
+		case t.is("primary") && i+1 < len(def) && def[i+1].is("key"):
+			col.IsNullable = "NO"
+			i++
+		case t.is("comment") && i+1 < len(def) && isDDLString(def[i+1]):
+			col.ColumnComment = def[i+1].text
+			i++
+		}
+	}
+
+	if p.dialect == DialectSqlite {
+		col.DataType = sqliteDataType(col.ColumnType)
+	}
+	if array {
+		col.ColumnType = "_" + strings.SplitN(baseType, " ", 2)[0]
+		col.DataType = "array"
+	}
+	if col.DataType == "serial" || col.DataType == "bigserial" || col.DataType == "smallserial" {
+		col.IsNullable = "NO"
+	}
+	return col, nil
+}
+
+// isDDLString reports whether the token is a string literal, mysql also quotes strings with `"`,
+// which can't be an identifier after COMMENT.
+func isDDLString(t sqlToken) bool {
+	return t.kind == sqlString || t.kind == sqlQuotedIdent
+}
+
+// enumColumnType returns the labels as the mysql column type `enum('a','b')`.
+func enumColumnType(labels []string) string {
+	quoted := make([]string, len(labels))
+	for i, label := range labels {
+		quoted[i] = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	return "enum(" + strings.Join(quoted, ",") + ")"
+}
+
+// ddlDataType maps the type aliases to the data types reported by information_schema.
+func ddlDataType(baseType string) string {
+	switch baseType {
+	case "int4", "serial", "serial4":
+		return "integer"
+	case "int8", "bigserial", "serial8":
+		return "bigint"
+	case "int2", "smallserial", "serial2":
+		return "smallint"
+	case "float4":
+		return "real"
+	case "float8":
+		return "double precision"
+	case "bool":
+		return "boolean"
+	case "bpchar":
+		return "character"
+	case "varchar", "character varying", "nvarchar", "varying character":
+		return "varchar"
+	case "char", "nchar":
+		return "char"
+	case "timestamptz":
+		return "timestamp with time zone"
+	case "timetz":
+		return "time with time zone"
+	}
+	return baseType
+}
+
+// setColumnSize fills the length or precision of `varchar(64)` / `decimal(10,2)`.
+func setColumnSize(col *Column, args string) {
+	parts := strings.Split(strings.Trim(args, "()"), ",")
+	size, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil {
+		return
+	}
+	switch col.DataType {
+	case "varchar", "char", "character", "binary", "varbinary":
+		col.CharacterMaximumLength = sql.NullInt64{Int64: size, Valid: true}
+	default:
+		col.NumericPrecision = sql.NullInt64{Int64: size, Valid: true}
+		if len(parts) > 1 {
+			if scale, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64); err == nil {
+				col.NumericScale = sql.NullInt64{Int64: scale, Valid: true}
+			}
+		}
+	}
+}
+
+// createType records `CREATE TYPE name AS ENUM ('a', 'b')`.
+func (p *ddlParser) createType(stmt []sqlToken, i int) {
+	name, i := qualifiedName(stmt, i)
+	if i+2 >= len(stmt) || !stmt[i].is("as") || !stmt[i+1].is("enum") || !stmt[i+2].is("(") {
+		return
+	}
+	var labels []string
+	for _, t := range stmt[i+3:] {
+		if t.kind == sqlString {
+			labels = append(labels, t.text)
+		}
+	}
+	p.enums[strings.ToLower(name)] = labels
+}
+
+// commentOn records `COMMENT ON TABLE t IS '...'` and `COMMENT ON COLUMN t.c IS '...'`.
+func (p *ddlParser) commentOn(stmt []sqlToken) {
+	last := stmt[len(stmt)-1]
+	if last.kind != sqlString || !stmt[len(stmt)-2].is("is") {
+		return
+	}
+	var parts []string
+	for _, t := range stmt[3 : len(stmt)-2] {
+		if !t.is(".") {
+			parts = append(parts, t.text)
+		}
+	}
+	switch {
+	case stmt[2].is("table") && len(parts) > 0:
+		p.table(parts[len(parts)-1]).comment = last.text
+	case stmt[2].is("column") && len(parts) > 1:
+		t := p.table(parts[len(parts)-2])
+		t.columnComments[parts[len(parts)-1]] = last.text
+	}
+}
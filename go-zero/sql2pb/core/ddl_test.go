@@ -0,0 +1,203 @@
+package core
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDDLMysql(t *testing.T) {
+	ddl := "-- users of the shop\n" +
+		"CREATE TABLE IF NOT EXISTS `shop`.`user` (\n" +
+		"  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT 'user''s id',\n" +
+		"  `name` varchar(64) CHARACTER SET utf8mb4 NOT NULL DEFAULT '' COMMENT \"it's the name; not a statement\",\n" +
+		"  `remark` text COMMENT 'line\\'s end -- not a comment',\n" +
+		"  `price` decimal(10,2) DEFAULT NULL,\n" +
+		"  `state` enum('on','off') NOT NULL DEFAULT 'on' COMMENT 'state, (on/off)',\n" +
+		"  /* block comment, with a comma */\n" +
+		"  `deleted` tinyint(1) NOT NULL DEFAULT 0,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `idx_name` (`name`, `state`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='user table';\n" +
+		"INSERT INTO `user` VALUES (1, 'a');\n" +
+		"CREATE TABLE `copy` LIKE `user`;\n"
+
+	cols, err := ParseDDL(ddl, DialectMysql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Column{
+		{ColumnName: "id", IsNullable: "NO", DataType: "bigint", ColumnType: "bigint(20) unsigned",
+			NumericPrecision: sql.NullInt64{Int64: 20, Valid: true}, ColumnComment: "user's id"},
+		{ColumnName: "name", IsNullable: "NO", DataType: "varchar", ColumnType: "varchar(64)",
+			CharacterMaximumLength: sql.NullInt64{Int64: 64, Valid: true}, ColumnComment: "it's the name; not a statement"},
+		{ColumnName: "remark", IsNullable: "YES", DataType: "text", ColumnType: "text", ColumnComment: "line's end -- not a comment"},
+		{ColumnName: "price", IsNullable: "YES", DataType: "decimal", ColumnType: "decimal(10,2)",
+			NumericPrecision: sql.NullInt64{Int64: 10, Valid: true}, NumericScale: sql.NullInt64{Int64: 2, Valid: true}},
+		{ColumnName: "state", IsNullable: "NO", DataType: "enum", ColumnType: "enum('on','off')", ColumnComment: "state, (on/off)"},
+		{ColumnName: "deleted", IsNullable: "NO", DataType: "tinyint", ColumnType: "tinyint(1)",
+			NumericPrecision: sql.NullInt64{Int64: 1, Valid: true}},
+	}
+	if len(cols) != len(want) {
+		t.Fatalf("got %d columns, want %d: %+v", len(cols), len(want), cols)
+	}
+	for i := range want {
+		want[i].TableName = "user"
+		want[i].TableComment = "user table"
+		if !reflect.DeepEqual(cols[i], want[i]) {
+			t.Errorf("column %d = %+v, want %+v", i, cols[i], want[i])
+		}
+	}
+}
+
+func TestParseDDLPostgres(t *testing.T) {
+	ddl := `
+CREATE TYPE public.order_status AS ENUM ('new', 'it''s paid');
+CREATE TABLE "order" (
+	id bigserial,
+	status order_status NOT NULL,
+	tags text[],
+	scores INTEGER ARRAY,
+	amount numeric(12, 2),
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT order_pk PRIMARY KEY (id, status)
+);
+COMMENT ON TABLE public."order" IS 'orders; of users';
+COMMENT ON COLUMN "order".amount IS 'the amount';
+`
+	cols, err := ParseDDL(ddl, DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]Column{}
+	for _, c := range cols {
+		if c.TableName != "order" || c.TableComment != "orders; of users" {
+			t.Errorf("table of %s = %s %q", c.ColumnName, c.TableName, c.TableComment)
+		}
+		got[c.ColumnName] = c
+	}
+	cases := []struct {
+		name, dataType, columnType, nullable string
+	}{
+		{"id", "bigint", "bigserial", "NO"},
+		{"status", "enum", "enum('new','it''s paid')", "NO"},
+		{"tags", "array", "_text", "YES"},
+		{"scores", "array", "_integer", "YES"},
+		{"amount", "numeric", "numeric(12,2)", "YES"},
+		{"created_at", "timestamp with time zone", "timestamptz", "NO"},
+	}
+	for _, c := range cases {
+		col := got[c.name]
+		if col.DataType != c.dataType || col.ColumnType != c.columnType || col.IsNullable != c.nullable {
+			t.Errorf("%s = %s %s %s, want %s %s %s", c.name, col.DataType, col.ColumnType, col.IsNullable,
+				c.dataType, c.columnType, c.nullable)
+		}
+	}
+	if got["amount"].ColumnComment != "the amount" {
+		t.Errorf("amount comment = %q", got["amount"].ColumnComment)
+	}
+}
+
+func TestParseDDLSqlite(t *testing.T) {
+	cols, err := ParseDDL(`CREATE TABLE [note] ("id" INTEGER PRIMARY KEY AUTOINCREMENT, body CLOB, data)`, DialectSqlite)
+	if err == nil {
+		t.Fatalf("column without type should fail: %+v", cols)
+	}
+	cols, err = ParseDDL(`CREATE TABLE [note] ("id" INTEGER PRIMARY KEY AUTOINCREMENT, body CLOB, size UNSIGNED BIG INT)`, DialectSqlite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range cols {
+		got = append(got, c.TableName+"."+c.ColumnName+":"+c.DataType+":"+c.IsNullable)
+	}
+	want := "note.id:bigint:NO note.body:text:YES note.size:bigint:YES"
+	if strings.Join(got, " ") != want {
+		t.Errorf("columns = %v, want %s", got, want)
+	}
+}
+
+func TestParseDDLErrors(t *testing.T) {
+	cases := map[string]string{
+		"unterminated quote":   "CREATE TABLE t (name varchar(10) COMMENT 'oops)",
+		"unterminated comment": "CREATE TABLE t (id int) /* oops",
+		"unbalanced":           "CREATE TABLE t (id int",
+	}
+	for name, ddl := range cases {
+		if _, err := ParseDDL(ddl, DialectMysql); err == nil {
+			t.Errorf("%s: ParseDDL should fail", name)
+		}
+	}
+}
+
+func TestGenerateSchemaFromDDL(t *testing.T) {
+	ddl := "CREATE TABLE user (id bigint NOT NULL, nickname varchar(32) COMMENT 'nick', PRIMARY KEY (id));" +
+		"CREATE TABLE user_log (id bigint NOT NULL)"
+	s, err := GenerateSchemaFromDDL(ddl, Options{
+		Dialect:     "mysql",
+		Table:       "user",
+		Package:     "user",
+		TypeMapping: TypeMapping{Nullable: NullableOptional},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Messages) != 1 || s.Messages[0].Comment != "user" {
+		t.Fatalf("messages = %+v", s.Messages)
+	}
+	want := map[string]string{"id": "int64", "nickname": "optional string"}
+	if got := fieldTypes(t, s, "User"); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
+func TestReadDDLFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"1_user.sql":  "CREATE TABLE user (id bigint)",
+		"2_order.sql": "CREATE TABLE orders (id bigint)",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ddl, files, err := ReadDDLFiles([]string{filepath.Join(dir, "*.sql"), " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || ddl != "CREATE TABLE user (id bigint);\nCREATE TABLE orders (id bigint);\n" {
+		t.Errorf("ReadDDLFiles() = %q, %v", ddl, files)
+	}
+	if _, _, err = ReadDDLFiles([]string{filepath.Join(dir, "*.ddl")}); err == nil {
+		t.Error("missing file should fail")
+	}
+}
+
+func TestEnumLabels(t *testing.T) {
+	cases := map[string][]string{
+		"'on','off'":         {"on", "off"},
+		"'it''s paid','a,b'": {"it's paid", "a,b"},
+		"'(x)'":              {"(x)"},
+		"small,large":        {"small", "large"},
+	}
+	for in, want := range cases {
+		if got := enumLabels(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("enumLabels(%s) = %q, want %q", in, got, want)
+		}
+	}
+
+	if got := enumColumnType([]string{"it's paid", "a,b"}); got != "enum('it''s paid','a,b')" {
+		t.Errorf("enumColumnType() = %s", got)
+	}
+
+	s, err := GenerateSchemaFromDDL("CREATE TABLE pay (state enum('it''s paid','(refund)') NOT NULL)", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Enums) != 1 || len(s.Enums[0].Fields) != 2 || s.Enums[0].Fields[0].Name() != "IT_S_PAID" {
+		t.Errorf("enums = %+v", s.Enums)
+	}
+}
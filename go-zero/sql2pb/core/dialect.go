@@ -0,0 +1,360 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Mikaelemmmm/sql2pb/tools/stringx"
+)
+
+const (
+	// supported database dialects
+	DialectMysql    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSqlite   = "sqlite"
+)
+
+// NormalizeDialect returns the dialect for a database type or driver name, such as `pgx` or `sqlite3`.
+func NormalizeDialect(dbType string) (string, error) {
+	switch strings.ToLower(dbType) {
+	case "", "mysql":
+		return DialectMysql, nil
+	case "postgres", "postgresql", "pgsql", "pgx":
+		return DialectPostgres, nil
+	case "sqlite", "sqlite3":
+		return DialectSqlite, nil
+	}
+	return "", fmt.Errorf("unsupported database type `%s`, supported: mysql | postgres | sqlite", dbType)
+}
+
+// splitTables returns the table filter, nil means all tables.
+func splitTables(table string) []string {
+	if table == "" || table == "*" {
+		return nil
+	}
+	var tables []string
+	for _, t := range strings.Split(table, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// placeholders returns the bind parameters of the dialect, starting at position start.
+func placeholders(dialect string, start, n int) string {
+	marks := make([]string, n)
+	for i := range marks {
+		if dialect == DialectPostgres {
+			marks[i] = fmt.Sprintf("$%d", start+i)
+		} else {
+			marks[i] = "?"
+		}
+	}
+	return strings.Join(marks, ",")
+}
+
+func dbSchema(db *sql.DB, dialect string) (string, error) {
+	var schema string
+
+	switch dialect {
+	case DialectPostgres:
+		err := db.QueryRow("SELECT current_schema()").Scan(&schema)
+		return schema, err
+	case DialectSqlite:
+		return "main", nil
+	}
+
+	err := db.QueryRow("SELECT SCHEMA()").Scan(&schema)
+
+	return schema, err
+}
+
+func dbColumns(db *sql.DB, dialect, schema, table string) ([]Column, error) {
+	var (
+		cols []Column
+		err  error
+	)
+	switch dialect {
+	case DialectPostgres:
+		cols, err = postgresColumns(db, schema, splitTables(table))
+	case DialectSqlite:
+		cols, err = sqliteColumns(db, splitTables(table))
+	default:
+		cols, err = mysqlColumns(db, schema, splitTables(table))
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	for i := range cols {
+		if cols[i].TableComment == "" {
+			cols[i].TableComment = stringx.From(cols[i].TableName).ToCamelWithStartLower()
+		}
+	}
+
+	return cols, nil
+}
+
+func mysqlColumns(db *sql.DB, schema string, tables []string) ([]Column, error) {
+	q := "SELECT c.TABLE_NAME, c.COLUMN_NAME, c.IS_NULLABLE, c.DATA_TYPE, " +
+		"c.CHARACTER_MAXIMUM_LENGTH, c.NUMERIC_PRECISION, c.NUMERIC_SCALE, c.COLUMN_TYPE ,c.COLUMN_COMMENT,t.TABLE_COMMENT " +
+		"FROM INFORMATION_SCHEMA.COLUMNS as c  LEFT JOIN  INFORMATION_SCHEMA.TABLES as t  on c.TABLE_NAME = t.TABLE_NAME and  c.TABLE_SCHEMA = t.TABLE_SCHEMA" +
+		" WHERE c.TABLE_SCHEMA = ?"
+
+	args := []interface{}{schema}
+	if len(tables) > 0 {
+		q += " AND c.TABLE_NAME IN(" + placeholders(DialectMysql, 2, len(tables)) + ")"
+		for _, t := range tables {
+			args = append(args, t)
+		}
+	}
+
+	q += " ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION"
+
+	rows, err := db.Query(q, args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := []Column{}
+
+	for rows.Next() {
+		cs := Column{}
+		err := rows.Scan(&cs.TableName, &cs.ColumnName, &cs.IsNullable, &cs.DataType,
+			&cs.CharacterMaximumLength, &cs.NumericPrecision, &cs.NumericScale, &cs.ColumnType, &cs.ColumnComment, &cs.TableComment)
+		if err != nil {
+			return nil, err
+		}
+
+		cols = append(cols, cs)
+	}
+	if err := rows.Err(); nil != err {
+		return nil, err
+	}
+
+	return cols, nil
+}
+
+// postgresColumns reads columns from information_schema. Comments come from pg_description,
+// user defined enum types are rewritten to `enum('a','b')` so they are parsed like mysql enums.
+func postgresColumns(db *sql.DB, schema string, tables []string) ([]Column, error) {
+	q := "SELECT c.table_name, c.column_name, c.is_nullable, c.data_type, " +
+		"c.character_maximum_length, c.numeric_precision, c.numeric_scale, c.udt_name, " +
+		"COALESCE(col_description(pc.oid, c.ordinal_position::int), ''), COALESCE(obj_description(pc.oid, 'pg_class'), '') " +
+		"FROM information_schema.columns AS c " +
+		"JOIN information_schema.tables AS t ON c.table_name = t.table_name AND c.table_schema = t.table_schema AND t.table_type = 'BASE TABLE' " +
+		"JOIN pg_catalog.pg_namespace AS pn ON pn.nspname = c.table_schema " +
+		"JOIN pg_catalog.pg_class AS pc ON pc.relname = c.table_name AND pc.relnamespace = pn.oid " +
+		"WHERE c.table_schema = $1"
+
+	args := []interface{}{schema}
+	if len(tables) > 0 {
+		q += " AND c.table_name IN(" + placeholders(DialectPostgres, 2, len(tables)) + ")"
+		for _, t := range tables {
+			args = append(args, t)
+		}
+	}
+
+	q += " ORDER BY c.table_name, c.ordinal_position"
+
+	rows, err := db.Query(q, args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := []Column{}
+
+	for rows.Next() {
+		cs := Column{}
+		err := rows.Scan(&cs.TableName, &cs.ColumnName, &cs.IsNullable, &cs.DataType,
+			&cs.CharacterMaximumLength, &cs.NumericPrecision, &cs.NumericScale, &cs.ColumnType, &cs.ColumnComment, &cs.TableComment)
+		if err != nil {
+			return nil, err
+		}
+
+		cols = append(cols, cs)
+	}
+	if err := rows.Err(); nil != err {
+		return nil, err
+	}
+
+	for i, cs := range cols {
+		if cs.DataType != "USER-DEFINED" {
+			continue
+		}
+		labels, err := postgresEnumLabels(db, cs.ColumnType)
+		if nil != err {
+			return nil, err
+		}
+		if len(labels) == 0 {
+			continue
+		}
+		cols[i].DataType = "enum"
+		cols[i].ColumnType = enumColumnType(labels)
+	}
+
+	return cols, nil
+}
+
+func postgresEnumLabels(db *sql.DB, typeName string) ([]string, error) {
+	rows, err := db.Query("SELECT e.enumlabel FROM pg_catalog.pg_type AS t "+
+		"JOIN pg_catalog.pg_enum AS e ON t.oid = e.enumtypid WHERE t.typname = $1 ORDER BY e.enumsortorder", typeName)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []string
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+
+	return labels, rows.Err()
+}
+
+var sqliteTypeRe = regexp.MustCompile(`^\s*([a-zA-Z ]*[a-zA-Z])\s*(?:\((\d+)(?:\s*,\s*(\d+))?\))?`)
+
+// sqliteColumns reads the tables from sqlite_master and the columns with the table_info pragma.
+// sqlite has no column comments, the declared type is kept in ColumnType.
+func sqliteColumns(db *sql.DB, tables []string) ([]Column, error) {
+	q := "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"
+
+	var args []interface{}
+	if len(tables) > 0 {
+		q += " AND name IN(" + placeholders(DialectSqlite, 1, len(tables)) + ")"
+		for _, t := range tables {
+			args = append(args, t)
+		}
+	}
+
+	q += " ORDER BY name"
+
+	rows, err := db.Query(q, args...)
+	if nil != err {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); nil != err {
+		return nil, err
+	}
+
+	cols := []Column{}
+	for _, name := range names {
+		tableCols, err := sqliteTableColumns(db, name)
+		if nil != err {
+			return nil, err
+		}
+		cols = append(cols, tableCols...)
+	}
+
+	return cols, nil
+}
+
+func sqliteTableColumns(db *sql.DB, table string) ([]Column, error) {
+	rows, err := db.Query("SELECT name, type, \"notnull\", pk FROM pragma_table_info(?) ORDER BY cid", table)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []Column
+	for rows.Next() {
+		var (
+			cs      = Column{TableName: table}
+			notNull int
+			pk      int
+		)
+		if err := rows.Scan(&cs.ColumnName, &cs.ColumnType, &notNull, &pk); err != nil {
+			return nil, err
+		}
+		cs.IsNullable = "YES"
+		if notNull == 1 || pk > 0 {
+			cs.IsNullable = "NO"
+		}
+		cs.ColumnType = strings.ToLower(strings.TrimSpace(cs.ColumnType))
+		cs.DataType = sqliteDataType(cs.ColumnType)
+		if m := sqliteTypeRe.FindStringSubmatch(cs.ColumnType); m != nil && m[2] != "" {
+			var size int64
+			if _, err := fmt.Sscan(m[2], &size); err != nil {
+				return nil, fmt.Errorf("invalid size in type `%s`. column: `%s`.`%s`: %w", cs.ColumnType, table, cs.ColumnName, err)
+			}
+			if cs.DataType == "varchar" || cs.DataType == "char" {
+				cs.CharacterMaximumLength = sql.NullInt64{Int64: size, Valid: true}
+			} else {
+				cs.NumericPrecision = sql.NullInt64{Int64: size, Valid: true}
+			}
+			if m[3] != "" {
+				var scale int64
+				if _, err := fmt.Sscan(m[3], &scale); err != nil {
+					return nil, fmt.Errorf("invalid scale in type `%s`. column: `%s`.`%s`: %w", cs.ColumnType, table, cs.ColumnName, err)
+				}
+				cs.NumericScale = sql.NullInt64{Int64: scale, Valid: true}
+			}
+		}
+
+		cols = append(cols, cs)
+	}
+
+	return cols, rows.Err()
+}
+
+// sqliteDataType maps a declared sqlite type to a data type known by parseColumn,
+// unknown types fall back to the sqlite type affinity rules.
+func sqliteDataType(columnType string) string {
+	base := columnType
+	if m := sqliteTypeRe.FindStringSubmatch(columnType); m != nil {
+		base = m[1]
+	}
+	base = strings.Join(strings.Fields(strings.ReplaceAll(base, "unsigned", "")), " ")
+	switch base {
+	case "integer":
+		// sqlite integers are 64-bit, the same as the rowid
+		return "bigint"
+	case "int", "tinyint", "smallint", "mediumint", "bigint", "boolean", "bool",
+		"varchar", "char", "text", "blob", "real", "double", "float", "decimal", "numeric",
+		"date", "datetime", "timestamp", "time", "json":
+		return base
+	case "big int", "int8":
+		return "bigint"
+	case "int2":
+		return "smallint"
+	case "double precision":
+		return "double"
+	case "character", "nchar", "native character":
+		return "char"
+	case "varying character", "nvarchar":
+		return "varchar"
+	case "clob":
+		return "text"
+	}
+
+	switch {
+	case strings.Contains(base, "int"):
+		return "bigint"
+	case strings.Contains(base, "char"), strings.Contains(base, "clob"), strings.Contains(base, "text"):
+		return "text"
+	case base == "", strings.Contains(base, "blob"):
+		return "blob"
+	case strings.Contains(base, "real"), strings.Contains(base, "floa"), strings.Contains(base, "doub"):
+		return "double"
+	}
+	return "numeric"
+}
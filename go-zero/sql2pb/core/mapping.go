@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// decimal column mapping
+	DecimalDouble = "double"
+	DecimalString = "string"
+	DecimalMoney  = "money"
+
+	// json column mapping
+	JsonString = "string"
+	JsonStruct = "struct"
+
+	// nullable column mapping
+	NullableNone     = "none"
+	NullableWrapper  = "wrapper"
+	NullableOptional = "optional"
+)
+
+// TypeMapping configures how database types without an exact protobuf equivalent are mapped.
+// The zero value keeps the historical behaviour: decimal to double, json to string,
+// unsigned integers to signed ones and nullable columns to plain scalars.
+type TypeMapping struct {
+	// Decimal maps decimal/numeric columns to `double`, `string` or `google.type.Money`
+	Decimal string
+	// Json maps json/jsonb columns to `string` or `google.protobuf.Struct`
+	Json string
+	// Unsigned maps unsigned integer columns to uint32/uint64
+	Unsigned bool
+	// Nullable maps nullable columns to wrapper types (`google.protobuf.Int64Value`) or proto3 `optional` fields
+	Nullable string
+}
+
+// Validate checks the mapping options.
+func (tm TypeMapping) Validate() error {
+	switch tm.Decimal {
+	case "", DecimalDouble, DecimalString, DecimalMoney:
+	default:
+		return fmt.Errorf("unsupported decimal mapping `%s`, supported: double | string | money", tm.Decimal)
+	}
+	switch tm.Json {
+	case "", JsonString, JsonStruct:
+	default:
+		return fmt.Errorf("unsupported json mapping `%s`, supported: string | struct", tm.Json)
+	}
+	switch tm.Nullable {
+	case "", NullableNone, NullableWrapper, NullableOptional:
+	default:
+		return fmt.Errorf("unsupported nullable mapping `%s`, supported: none | wrapper | optional", tm.Nullable)
+	}
+	return nil
+}
+
+// wrapperTypes the google/protobuf/wrappers.proto type of each scalar type.
+var wrapperTypes = map[string]string{
+	"double": "google.protobuf.DoubleValue",
+	"float":  "google.protobuf.FloatValue",
+	"int64":  "google.protobuf.Int64Value",
+	"uint64": "google.protobuf.UInt64Value",
+	"int32":  "google.protobuf.Int32Value",
+	"uint32": "google.protobuf.UInt32Value",
+	"bool":   "google.protobuf.BoolValue",
+	"string": "google.protobuf.StringValue",
+	"bytes":  "google.protobuf.BytesValue",
+}
+
+func (tm TypeMapping) decimalType(s *Schema) string {
+	switch tm.Decimal {
+	case DecimalString:
+		return "string"
+	case DecimalMoney:
+		s.AppendImport("google/type/money.proto")
+		return "google.type.Money"
+	}
+	return "double"
+}
+
+func (tm TypeMapping) jsonType(s *Schema) string {
+	if tm.Json == JsonStruct {
+		s.AppendImport("google/protobuf/struct.proto")
+		return "google.protobuf.Struct"
+	}
+	return "string"
+}
+
+// integerType returns the signed type, or the unsigned one when the column is unsigned and Unsigned is enabled.
+func (tm TypeMapping) integerType(col Column, bits int) string {
+	unsigned := tm.Unsigned && strings.Contains(strings.ToLower(col.ColumnType), "unsigned")
+	switch {
+	case bits == 64 && unsigned:
+		return "uint64"
+	case bits == 64:
+		return "int64"
+	case unsigned:
+		return "uint32"
+	}
+	return "int32"
+}
+
+// nullableType applies the nullable mapping to a field type. Message types already have presence and
+// are returned as is, enums can't be wrapped and use `optional` instead.
+func (tm TypeMapping) nullableType(s *Schema, col Column, fieldType string) string {
+	if !strings.EqualFold(col.IsNullable, "YES") {
+		return fieldType
+	}
+	switch tm.Nullable {
+	case NullableWrapper:
+		if wrapper, ok := wrapperTypes[fieldType]; ok {
+			s.AppendImport("google/protobuf/wrappers.proto")
+			return wrapper
+		}
+		if !strings.Contains(fieldType, ".") {
+			return "optional " + fieldType
+		}
+	case NullableOptional:
+		if !strings.Contains(fieldType, ".") {
+			return "optional " + fieldType
+		}
+	}
+	return fieldType
+}
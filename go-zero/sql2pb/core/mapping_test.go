@@ -0,0 +1,130 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypeMappingValidate(t *testing.T) {
+	valid := []TypeMapping{
+		{},
+		{Decimal: DecimalMoney, Json: JsonStruct, Unsigned: true, Nullable: NullableWrapper},
+		{Decimal: DecimalString, Json: JsonString, Nullable: NullableOptional},
+	}
+	for _, tm := range valid {
+		if err := tm.Validate(); err != nil {
+			t.Errorf("%+v: %v", tm, err)
+		}
+	}
+	invalid := []TypeMapping{
+		{Decimal: "float"},
+		{Json: "bytes"},
+		{Nullable: "pointer"},
+	}
+	for _, tm := range invalid {
+		if err := tm.Validate(); err == nil {
+			t.Errorf("%+v should be invalid", tm)
+		}
+	}
+}
+
+func TestTypeMapping(t *testing.T) {
+	cols := []Column{
+		{ColumnName: "id", DataType: "bigint", ColumnType: "bigint unsigned", IsNullable: "NO"},
+		{ColumnName: "age", DataType: "int", ColumnType: "int(10) unsigned", IsNullable: "NO"},
+		{ColumnName: "level", DataType: "tinyint", ColumnType: "tinyint(3) unsigned", IsNullable: "YES"},
+		{ColumnName: "deleted", DataType: "tinyint", ColumnType: "tinyint(1)", IsNullable: "YES"},
+		{ColumnName: "price", DataType: "decimal", ColumnType: "decimal(10,2)", IsNullable: "YES"},
+		{ColumnName: "extra", DataType: "json", ColumnType: "json", IsNullable: "YES"},
+		{ColumnName: "status", DataType: "enum", ColumnType: "enum('on','off')", IsNullable: "YES"},
+		{ColumnName: "created_at", DataType: "datetime", ColumnType: "datetime", IsNullable: "YES"},
+		{ColumnName: "tags", DataType: "ARRAY", ColumnType: "_text", IsNullable: "YES"},
+	}
+	for i := range cols {
+		cols[i].TableName = "goods"
+	}
+
+	cases := []struct {
+		name    string
+		mapping TypeMapping
+		want    map[string]string
+		imports []string
+	}{
+		{
+			name: "default",
+			want: map[string]string{
+				"id":         "int64",
+				"age":        "int32",
+				"level":      "int32",
+				"deleted":    "bool",
+				"price":      "double",
+				"extra":      "string",
+				"status":     "GoodStatus",
+				"created_at": "google.protobuf.Timestamp",
+				"tags":       "repeated string",
+			},
+			imports: []string{"google/protobuf/timestamp.proto"},
+		},
+		{
+			name:    "wrapper",
+			mapping: TypeMapping{Decimal: DecimalString, Json: JsonStruct, Unsigned: true, Nullable: NullableWrapper},
+			want: map[string]string{
+				"id":         "uint64",
+				"age":        "uint32",
+				"level":      "google.protobuf.UInt32Value",
+				"deleted":    "google.protobuf.BoolValue",
+				"price":      "google.protobuf.StringValue",
+				"extra":      "google.protobuf.Struct",
+				"status":     "optional GoodStatus",
+				"created_at": "google.protobuf.Timestamp",
+				"tags":       "repeated string",
+			},
+			imports: []string{
+				"google/protobuf/struct.proto",
+				"google/protobuf/timestamp.proto",
+				"google/protobuf/wrappers.proto",
+			},
+		},
+		{
+			name:    "optional",
+			mapping: TypeMapping{Decimal: DecimalMoney, Nullable: NullableOptional},
+			want: map[string]string{
+				"id":         "int64",
+				"age":        "int32",
+				"level":      "optional int32",
+				"deleted":    "optional bool",
+				"price":      "google.type.Money",
+				"extra":      "optional string",
+				"status":     "optional GoodStatus",
+				"created_at": "google.protobuf.Timestamp",
+				"tags":       "repeated string",
+			},
+			imports: []string{"google/protobuf/timestamp.proto", "google/type/money.proto"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := generateSchema(cols, Options{Package: "goods", TypeMapping: c.mapping})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fieldTypes(t, s, "Goods"); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("fields = %v, want %v", got, c.want)
+			}
+			if !reflect.DeepEqual([]string(s.Imports), c.imports) {
+				t.Errorf("imports = %v, want %v", s.Imports, c.imports)
+			}
+		})
+	}
+}
+
+func TestTypeMappingUnsupported(t *testing.T) {
+	_, err := generateSchema([]Column{{TableName: "t", ColumnName: "geo", DataType: "geometry"}}, Options{})
+	if err == nil {
+		t.Error("geometry column should fail")
+	}
+	_, err = generateSchema(nil, Options{TypeMapping: TypeMapping{Decimal: "float"}})
+	if err == nil {
+		t.Error("invalid mapping should fail")
+	}
+}
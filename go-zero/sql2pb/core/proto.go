@@ -0,0 +1,434 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/samber/lo"
+)
+
+// maxFieldNumber is the `max` of a reserved range.
+const maxFieldNumber = 536870911
+
+// ProtoFile is what is kept of an existing .proto file when it is generated again: the field tags
+// and reserved numbers of the generated messages, and everything that was written by hand.
+type ProtoFile struct {
+	Imports  []string
+	Options  []string
+	Messages map[string]*ProtoMessage
+	Services map[string]*ProtoService
+	// Decls the top level messages, enums, services and extends in the order of the file
+	Decls []ProtoDecl
+}
+
+// ProtoDecl is a top level declaration with its leading comments.
+type ProtoDecl struct {
+	Kind string
+	Name string
+	Raw  string
+}
+
+// ProtoMessage is the field numbering of a message.
+type ProtoMessage struct {
+	Name          string
+	Fields        map[string]int
+	Reserved      [][2]int
+	ReservedNames []string
+}
+
+// ProtoService is a service with the rpcs in the order of the file.
+type ProtoService struct {
+	Name string
+	Rpcs []ProtoDecl
+}
+
+// ParseProto parses the parts of a .proto file needed to merge a generated schema into it.
+func ParseProto(src string) (*ProtoFile, error) {
+	tokens, err := protoTokenize(src)
+	if nil != err {
+		return nil, err
+	}
+	p := &protoParser{src: src, tokens: tokens}
+	f := &ProtoFile{
+		Messages: map[string]*ProtoMessage{},
+		Services: map[string]*ProtoService{},
+	}
+	for p.pos < len(p.tokens) {
+		start := p.pos
+		t := p.next()
+		switch t.text {
+		case "syntax", "package", ";":
+			p.skipStatement()
+		case "import":
+			for p.pos < len(p.tokens) && !p.peek().is(";") {
+				if tk := p.next(); tk.kind == protoString {
+					f.Imports = append(f.Imports, tk.text)
+				}
+			}
+			p.skipStatement()
+		case "option":
+			p.skipStatement()
+			raw := p.raw(start, p.pos, false)
+			if !strings.Contains(raw, "go_package") {
+				f.Options = append(f.Options, raw)
+			}
+		case "message", "enum", "extend":
+			name := p.next().text
+			open := p.find("{")
+			if open < 0 {
+				return nil, fmt.Errorf("missing `{` of %s %s", t.text, name)
+			}
+			end := p.matchBrace(open)
+			if end < 0 {
+				return nil, fmt.Errorf("unbalanced braces in %s %s", t.text, name)
+			}
+			if t.text == "message" {
+				f.Messages[name] = parseProtoMessage(name, p.tokens[open+1:end])
+			}
+			p.pos = end + 1
+			f.Decls = append(f.Decls, ProtoDecl{Kind: t.text, Name: name, Raw: p.raw(start, p.pos, true)})
+		case "service":
+			name := p.next().text
+			open := p.find("{")
+			if open < 0 {
+				return nil, fmt.Errorf("missing `{` of service %s", name)
+			}
+			end := p.matchBrace(open)
+			if end < 0 {
+				return nil, fmt.Errorf("unbalanced braces in service %s", name)
+			}
+			svc := &ProtoService{Name: name}
+			p.pos = open + 1
+			for p.pos < end {
+				rpcStart := p.pos
+				if tk := p.next(); !tk.is("rpc") {
+					if tk.is("option") {
+						p.skipStatement()
+					}
+					continue
+				}
+				rpcName := p.next().text
+				// rpc X(A) returns (B); or rpc X(A) returns (B) { option ...; }
+				for p.pos < end && !p.peek().is(";") && !p.peek().is("{") {
+					p.pos++
+				}
+				if p.pos < end && p.peek().is("{") {
+					p.pos = p.matchBrace(p.pos) + 1
+					if p.pos < end && p.peek().is(";") {
+						p.pos++
+					}
+				} else {
+					p.pos++
+				}
+				svc.Rpcs = append(svc.Rpcs, ProtoDecl{Kind: "rpc", Name: rpcName, Raw: p.raw(rpcStart, p.pos, true)})
+			}
+			p.pos = end + 1
+			f.Services[name] = svc
+			f.Decls = append(f.Decls, ProtoDecl{Kind: "service", Name: name, Raw: p.raw(start, p.pos, true)})
+		default:
+			return nil, fmt.Errorf("unexpected `%s` in proto file", t.text)
+		}
+	}
+	return f, nil
+}
+
+// parseProtoMessage reads the field numbers and reserved ranges of a message body,
+// the fields of a oneof belong to the message, nested messages and enums are skipped.
+func parseProtoMessage(name string, body []protoToken) *ProtoMessage {
+	m := &ProtoMessage{Name: name, Fields: map[string]int{}}
+	for i := 0; i < len(body); i++ {
+		t := body[i]
+		switch {
+		case t.is("message"), t.is("enum"), t.is("extend"):
+			for depth := 0; i < len(body); i++ {
+				if body[i].is("{") {
+					depth++
+				} else if body[i].is("}") {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+		case t.is("oneof"):
+			// the fields inside the braces are read by the next iterations
+			for i < len(body) && !body[i].is("{") {
+				i++
+			}
+		case t.is("option"):
+			for i < len(body) && !body[i].is(";") {
+				i++
+			}
+		case t.is("reserved"):
+			for i++; i < len(body) && !body[i].is(";"); i++ {
+				switch {
+				case body[i].kind == protoString:
+					m.ReservedNames = append(m.ReservedNames, body[i].text)
+				case body[i].kind == protoNumber:
+					from, _ := strconv.Atoi(body[i].text)
+					to := from
+					if i+2 < len(body) && body[i+1].is("to") {
+						if body[i+2].is("max") {
+							to = maxFieldNumber
+						} else {
+							to, _ = strconv.Atoi(body[i+2].text)
+						}
+						i += 2
+					}
+					m.Reserved = append(m.Reserved, [2]int{from, to})
+				}
+			}
+		case t.is("=") && i > 0 && i+1 < len(body) && body[i+1].kind == protoNumber:
+			tag, _ := strconv.Atoi(body[i+1].text)
+			m.Fields[body[i-1].text] = tag
+		case t.is("["):
+			// field options, `[default = 1]`
+			for i < len(body) && !body[i].is("]") {
+				i++
+			}
+		}
+	}
+	return m
+}
+
+// isReserved reports whether the tag is in a reserved range.
+func (m *ProtoMessage) isReserved(tag int) bool {
+	for _, r := range m.Reserved {
+		if tag >= r[0] && tag <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// assignTags numbers the generated fields: the fields of the previous version keep their tags, new fields
+// get unused tags and the tags and names of dropped fields become reserved.
+func (m *ProtoMessage) assignTags(fields []MessageField) ([]MessageField, [][2]int, []string) {
+	var (
+		result   = make([]MessageField, len(fields))
+		used     = map[int]bool{}
+		names    = map[string]bool{}
+		reserved = append([][2]int(nil), m.Reserved...)
+		resNames = append([]string(nil), m.ReservedNames...)
+		next     = 1
+	)
+	for _, f := range fields {
+		names[f.Name] = true
+	}
+	for name, tag := range m.Fields {
+		used[tag] = true
+		if !names[name] {
+			reserved = append(reserved, [2]int{tag, tag})
+			if !isInSlice(resNames, name) {
+				resNames = append(resNames, name)
+			}
+		}
+	}
+	for i, f := range fields {
+		// a column added again with the name of a dropped one gets a new tag
+		resNames = lo.Without(resNames, f.Name)
+		if tag, ok := m.Fields[f.Name]; ok {
+			f.tag = tag
+		} else {
+			for used[next] || m.isReserved(next) || (next >= 19000 && next <= 19999) {
+				next++
+			}
+			f.tag = next
+			used[next] = true
+		}
+		result[i] = f
+	}
+
+	sort.Slice(reserved, func(i, j int) bool { return reserved[i][0] < reserved[j][0] })
+	sort.Strings(resNames)
+	return result, reserved, resNames
+}
+
+// formatReserved renders the reserved statements of a message.
+func formatReserved(reserved [][2]int, names []string) string {
+	var buf strings.Builder
+	if len(reserved) > 0 {
+		items := make([]string, len(reserved))
+		for i, r := range reserved {
+			switch {
+			case r[1] == maxFieldNumber:
+				items[i] = fmt.Sprintf("%d to max", r[0])
+			case r[0] == r[1]:
+				items[i] = strconv.Itoa(r[0])
+			default:
+				items[i] = fmt.Sprintf("%d to %d", r[0], r[1])
+			}
+		}
+		buf.WriteString(fmt.Sprintf("%sreserved %s;\n", indent, strings.Join(items, ", ")))
+	}
+	if len(names) > 0 {
+		buf.WriteString(fmt.Sprintf("%sreserved \"%s\";\n", indent, strings.Join(names, "\", \"")))
+	}
+	return buf.String()
+}
+
+type protoTokenKind int
+
+const (
+	protoIdent protoTokenKind = iota
+	protoString
+	protoNumber
+	protoSymbol
+	protoComment
+)
+
+type protoToken struct {
+	kind  protoTokenKind
+	text  string
+	start int
+	end   int
+}
+
+func (t protoToken) is(text string) bool {
+	return t.kind != protoString && t.kind != protoComment && t.text == text
+}
+
+// protoTokenize splits a .proto file into tokens, the comments are kept to attach them to declarations.
+func protoTokenize(src string) ([]protoToken, error) {
+	var tokens []protoToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			tokens = append(tokens, protoToken{protoComment, src[i : i+end], i, i + end})
+			i += end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment in proto file")
+			}
+			tokens = append(tokens, protoToken{protoComment, src[i : i+end+4], i, i + end + 4})
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string in proto file")
+			}
+			tokens = append(tokens, protoToken{protoString, src[i+1 : j], i, j + 1})
+			i = j + 1
+		case c == '_' || c == '.' && i+1 < len(src) && isIdentByte(src[i+1]) || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (isIdentByte(src[j]) || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, protoToken{protoIdent, src[i:j], i, j})
+			i = j
+		case c >= '0' && c <= '9' || c == '-':
+			j := i + 1
+			for j < len(src) && (isIdentByte(src[j]) || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, protoToken{protoNumber, src[i:j], i, j})
+			i = j
+		default:
+			tokens = append(tokens, protoToken{protoSymbol, string(c), i, i + 1})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+type protoParser struct {
+	src    string
+	tokens []protoToken
+	pos    int
+}
+
+// next returns the next token which isn't a comment.
+func (p *protoParser) next() protoToken {
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		p.pos++
+		if t.kind != protoComment {
+			return t
+		}
+	}
+	return protoToken{}
+}
+
+func (p *protoParser) peek() protoToken {
+	for i := p.pos; i < len(p.tokens); i++ {
+		if p.tokens[i].kind != protoComment {
+			return p.tokens[i]
+		}
+		p.pos = i + 1
+	}
+	return protoToken{}
+}
+
+// skipStatement moves past the next `;`.
+func (p *protoParser) skipStatement() {
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		p.pos++
+		if t.is(";") {
+			return
+		}
+	}
+}
+
+// find returns the index of the next token with the text.
+func (p *protoParser) find(text string) int {
+	for i := p.pos; i < len(p.tokens); i++ {
+		if p.tokens[i].is(text) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *protoParser) matchBrace(open int) int {
+	depth := 0
+	for i := open; i < len(p.tokens); i++ {
+		switch {
+		case p.tokens[i].is("{"):
+			depth++
+		case p.tokens[i].is("}"):
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// raw returns the source of the tokens [start, end), with the comments directly above when withComments is set.
+// A blank line ends the comments, so section banners are not attached to the next declaration.
+func (p *protoParser) raw(start, end int, withComments bool) string {
+	for start < end && p.tokens[start].kind == protoComment {
+		start++
+	}
+	from := p.tokens[start].start
+	if withComments {
+		for i := start - 1; i >= 0 && p.tokens[i].kind == protoComment; i-- {
+			lineStart := strings.LastIndexByte(p.src[:p.tokens[i].start], '\n') + 1
+			if strings.Count(p.src[p.tokens[i].end:from], "\n") > 1 || strings.TrimSpace(p.src[lineStart:p.tokens[i].start]) != "" {
+				break
+			}
+			from = p.tokens[i].start
+		}
+	}
+	return p.src[from:p.tokens[end-1].end]
+}
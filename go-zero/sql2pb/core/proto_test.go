@@ -0,0 +1,221 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// generateProto generates the proto of the DDL, merged into previous when it isn't empty.
+func generateProto(t *testing.T, ddl, previous string) string {
+	t.Helper()
+	s, err := GenerateSchemaFromDDL(ddl, Options{ServiceName: "usercenter", Package: "pb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "" {
+		prev, err := ParseProto(previous)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.MergeFrom(prev)
+	}
+	return s.String()
+}
+
+// protoMessage returns the source of a top level message.
+func protoMessage(t *testing.T, src, name string) string {
+	t.Helper()
+	start := strings.Index(src, "message "+name+" {")
+	if start < 0 {
+		t.Fatalf("message %s not found in:\n%s", name, src)
+	}
+	end := strings.Index(src[start:], "}\n")
+	return src[start : start+end+1]
+}
+
+func assertContains(t *testing.T, src string, parts ...string) {
+	t.Helper()
+	for _, part := range parts {
+		if !strings.Contains(src, part) {
+			t.Errorf("missing %q in:\n%s", part, src)
+		}
+	}
+}
+
+const (
+	userV1 = "CREATE TABLE user (id bigint NOT NULL, name varchar(32) COMMENT 'the name', " +
+		"nickname varchar(32), age int, PRIMARY KEY (id))"
+	// nickname is dropped and email added
+	userV2 = "CREATE TABLE user (id bigint NOT NULL, name varchar(32) COMMENT 'the name', " +
+		"age int, email varchar(64), PRIMARY KEY (id))"
+	// nickname is added again
+	userV3 = "CREATE TABLE user (id bigint NOT NULL, name varchar(32) COMMENT 'the name', " +
+		"age int, email varchar(64), nickname varchar(32), PRIMARY KEY (id))"
+)
+
+// customize adds handwritten declarations to a generated proto.
+func customize(src string) string {
+	src = strings.Replace(src, "option go_package", "option java_package = \"com.example.pb\";\noption go_package", 1)
+	end := strings.LastIndex(src, "\n}")
+	src = src[:end] + "\n\t// Ping checks the service\n\trpc Ping(PingReq) returns (PingResp);\n" + src[end:]
+	return src + `
+
+// PingReq is written by hand
+message PingReq {
+  string msg = 1; // "quoted } brace"
+  reserved 2;
+}
+
+message PingResp {
+  string msg = 1;
+}
+
+service admin {
+  rpc Ping(PingReq) returns (PingResp) {
+    option deprecated = true;
+  };
+}
+`
+}
+
+func TestParseProto(t *testing.T) {
+	f, err := ParseProto(customize(generateProto(t, userV1, "")) + `
+message Order {
+  reserved 3, 5 to 7, 100 to max;
+  reserved "old", "older";
+  oneof pay {
+    string card = 1;
+    string cash = 2;
+  }
+  message Item {
+    int64 id = 9;
+  }
+  int64 price = 4 [json_name = "p"];
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.Imports, []string(nil)) || !reflect.DeepEqual(f.Options, []string{`option java_package = "com.example.pb";`}) {
+		t.Errorf("imports = %v, options = %v", f.Imports, f.Options)
+	}
+	want := &ProtoMessage{
+		Name:          "Order",
+		Fields:        map[string]int{"card": 1, "cash": 2, "price": 4},
+		Reserved:      [][2]int{{3, 3}, {5, 7}, {100, maxFieldNumber}},
+		ReservedNames: []string{"old", "older"},
+	}
+	if got := f.Messages["Order"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Order = %+v, want %+v", got, want)
+	}
+	if got := f.Messages["User"].Fields; !reflect.DeepEqual(got, map[string]int{"id": 1, "name": 2, "nickname": 3, "age": 4}) {
+		t.Errorf("User fields = %v", got)
+	}
+
+	var rpcs []string
+	for _, rpc := range f.Services["usercenter"].Rpcs {
+		rpcs = append(rpcs, rpc.Name)
+	}
+	if strings.Join(rpcs, ",") != "InsertUser,UpdateUser,DelUser,FindUserById,ListUser,Ping" {
+		t.Errorf("rpcs = %v", rpcs)
+	}
+	if ping := f.Services["usercenter"].Rpcs[5].Raw; ping != "// Ping checks the service\n\trpc Ping(PingReq) returns (PingResp);" {
+		t.Errorf("Ping rpc = %q", ping)
+	}
+	if admin := f.Services["admin"].Rpcs[0].Raw; !strings.HasSuffix(admin, "option deprecated = true;\n  };") {
+		t.Errorf("admin Ping = %q", admin)
+	}
+
+	if _, err = ParseProto("message A {"); err == nil {
+		t.Error("unbalanced braces should fail")
+	}
+	if _, err = ParseProto("foo bar;"); err == nil {
+		t.Error("unexpected statement should fail")
+	}
+}
+
+func TestAssignTags(t *testing.T) {
+	prev := &ProtoMessage{
+		Fields:        map[string]int{"id": 1, "name": 3, "dropped": 2},
+		Reserved:      [][2]int{{4, 5}},
+		ReservedNames: []string{"older", "email"},
+	}
+	fields := []MessageField{
+		NewMessageField("int64", "id", 1, ""),
+		NewMessageField("string", "email", 2, ""),
+		NewMessageField("string", "name", 3, ""),
+		NewMessageField("string", "phone", 4, ""),
+	}
+	got, reserved, names := prev.assignTags(fields)
+	var tags []int
+	for _, f := range got {
+		tags = append(tags, f.Tag())
+	}
+	// new fields skip the used and reserved tags, a re-added name is no longer reserved
+	if !reflect.DeepEqual(tags, []int{1, 6, 3, 7}) {
+		t.Errorf("tags = %v", tags)
+	}
+	if !reflect.DeepEqual(reserved, [][2]int{{2, 2}, {4, 5}}) || !reflect.DeepEqual(names, []string{"dropped", "older"}) {
+		t.Errorf("reserved = %v %v", reserved, names)
+	}
+	if r := formatReserved([][2]int{{2, 2}, {4, 5}, {100, maxFieldNumber}}, names); r != "  reserved 2, 4 to 5, 100 to max;\n  reserved \"dropped\", \"older\";\n" {
+		t.Errorf("formatReserved() = %q", r)
+	}
+
+	// the tags reserved for extensions by protobuf are skipped
+	prev = &ProtoMessage{Fields: map[string]int{}, Reserved: [][2]int{{1, 18999}}}
+	got, _, _ = prev.assignTags([]MessageField{NewMessageField("int64", "id", 1, "")})
+	if got[0].Tag() != 20000 {
+		t.Errorf("tag = %d, want 20000", got[0].Tag())
+	}
+}
+
+func TestMergeProto(t *testing.T) {
+	v1 := customize(generateProto(t, userV1, ""))
+
+	// dropped columns keep their tags reserved, new columns get unused tags
+	v2 := generateProto(t, userV2, v1)
+	assertContains(t, protoMessage(t, v2, "User"),
+		"  reserved 3;\n  reserved \"nickname\";\n",
+		"int64 id = 1;",
+		"string name = 2; //the name",
+		"int32 age = 4;",
+		"string email = 5;")
+	assertContains(t, protoMessage(t, v2, "ListUserReq"),
+		"int64 page = 1;", "reserved 5;", "int32 age = 6;", "string email = 7;")
+
+	// the handwritten declarations are kept
+	assertContains(t, v2,
+		"option java_package = \"com.example.pb\";\n",
+		"// PingReq is written by hand\nmessage PingReq {\n  string msg = 1; // \"quoted } brace\"\n  reserved 2;\n}",
+		"message PingResp {",
+		"\t // Ping checks the service\n\trpc Ping(PingReq) returns (PingResp);\n",
+		"service admin {\n  rpc Ping(PingReq) returns (PingResp) {\n    option deprecated = true;\n  };\n}")
+	if n := strings.Count(v2, "message PingReq {"); n != 1 {
+		t.Errorf("PingReq written %d times", n)
+	}
+	if n := strings.Count(v2, "rpc Ping("); n != 2 {
+		t.Errorf("rpc Ping written %d times", n)
+	}
+
+	// merging the same schema again doesn't change the file
+	if again := generateProto(t, userV2, v2); again != v2 {
+		t.Errorf("merge is not idempotent:\n%s\n---\n%s", v2, again)
+	}
+
+	// a column added with the name of a dropped one gets a new tag
+	v3 := generateProto(t, userV3, v2)
+	user := protoMessage(t, v3, "User")
+	assertContains(t, user, "  reserved 3;\n", "string nickname = 6;", "string email = 5;")
+	if strings.Contains(user, "reserved \"nickname\"") {
+		t.Errorf("nickname is still reserved:\n%s", user)
+	}
+	if again := generateProto(t, userV3, v3); again != v3 {
+		t.Errorf("merge is not idempotent:\n%s\n---\n%s", v3, again)
+	}
+
+	// a dropped table keeps the generated messages as custom ones until they are removed by hand
+	v4 := generateProto(t, "CREATE TABLE orders (id bigint NOT NULL)", v3)
+	assertContains(t, v4, "message Orders {", "message User {", "message PingReq {")
+}
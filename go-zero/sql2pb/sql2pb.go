@@ -6,19 +6,23 @@ import (
 	"fmt"
 	"github.com/Mikaelemmmm/sql2pb/core"
 	"log"
+	"net/url"
+	"os"
 	"strings"
 
+	_ "github.com/glebarez/go-sqlite"
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 )
 
 func main() {
-	dbType := flag.String("db", "mysql", "the database type")
+	dbType := flag.String("db", "mysql", "the database type, mysql | postgres | sqlite")
 	host := flag.String("host", "localhost", "the database host")
-	port := flag.Int("port", 3306, "the database port")
+	port := flag.Int("port", 3306, "the database port, defaults to 5432 for postgres")
 	user := flag.String("user", "root", "the database user")
 	password := flag.String("password", "", "the database password")
 	schema := flag.String("schema", "", "the database schema")
-	dsn := flag.String("dsn", "", "the database dsn")
+	dsn := flag.String("dsn", "", "the database dsn, the database file for sqlite")
 	table := flag.String("table", "*", "the table schema，multiple tables ',' split. ")
 	serviceName := flag.String("service_name", *schema, "the protobuf service name , defaults to the database schema.")
 	packageName := flag.String("package", *schema, "the protocol buffer package. defaults to the database schema.")
@@ -26,35 +30,131 @@ func main() {
 	ignoreTableStr := flag.String("ignore_tables", "", "a comma spaced list of tables to ignore")
 	ignoreColumnStr := flag.String("ignore_columns", "", "a comma spaced list of mysql columns to ignore")
 	fieldStyle := flag.String("field_style", "sqlPb", "gen protobuf field style, sql_pb | sqlPb")
+	decimal := flag.String("decimal", core.DecimalDouble, "gen protobuf type of decimal columns, double | string | money")
+	jsonType := flag.String("json", core.JsonString, "gen protobuf type of json columns, string | struct")
+	unsigned := flag.Bool("unsigned", false, "gen uint32/uint64 for unsigned integer columns")
+	nullable := flag.String("nullable", core.NullableNone, "gen protobuf type of nullable columns, none | wrapper | optional")
+	ddl := flag.String("ddl", "", "gen from CREATE TABLE ddl files instead of a database, multiple files or patterns ',' split")
+	out := flag.String("out", "", "write the proto to the file instead of stdout, an existing file is merged: field tags, reserved numbers, custom messages and rpcs are kept")
+	modelDir := flag.String("model_dir", "", "also gen go-zero models into the dir with goctl")
+	modelHome := flag.String("model_home", "", "the goctl template home of the models")
+	modelStyle := flag.String("model_style", "go_zero", "the goctl file style of the models")
+	modelCache := flag.Bool("model_cache", false, "gen models with cache")
+	goctl := flag.String("goctl", "goctl", "the goctl binary")
 
 	flag.Parse()
 
-	if *schema == "" {
-		fmt.Println(" - please input the database schema ")
-		return
-	}
-
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", *user, *password, *host, *port, *schema)
-	if *dsn != "" {
-		connStr = *dsn
-	}
-	db, err := sql.Open(*dbType, connStr)
+	dialect, err := core.NormalizeDialect(*dbType)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer db.Close()
+	opts := core.Options{
+		Dialect:       dialect,
+		Table:         *table,
+		IgnoreTables:  strings.Split(*ignoreTableStr, ","),
+		IgnoreColumns: strings.Split(*ignoreColumnStr, ","),
+		ServiceName:   *serviceName,
+		GoPackage:     *goPackageName,
+		Package:       *packageName,
+		FieldStyle:    *fieldStyle,
+		TypeMapping: core.TypeMapping{
+			Decimal:  *decimal,
+			Json:     *jsonType,
+			Unsigned: *unsigned,
+			Nullable: *nullable,
+		},
+	}
+	modelOpts := core.ModelOptions{
+		Goctl:   *goctl,
+		Dir:     *modelDir,
+		Home:    *modelHome,
+		Style:   *modelStyle,
+		Cache:   *modelCache,
+		Dialect: dialect,
+		Table:   *table,
+	}
 
-	ignoreTables := strings.Split(*ignoreTableStr, ",")
-	ignoreColumns := strings.Split(*ignoreColumnStr, ",")
+	var s *core.Schema
+	if *ddl != "" {
+		script, files, err := core.ReadDDLFiles(strings.Split(*ddl, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+		s, err = core.GenerateSchemaFromDDL(script, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		modelOpts.DDLFiles = files
+	} else {
+		if *schema == "" && !(dialect == core.DialectSqlite && *dsn != "") {
+			fmt.Println(" - please input the database schema ")
+			return
+		}
 
-	s, err := core.GenerateSchema(db, *table, ignoreTables, ignoreColumns, *serviceName, *goPackageName, *packageName, *fieldStyle)
+		portSet := false
+		flag.Visit(func(f *flag.Flag) {
+			portSet = portSet || f.Name == "port"
+		})
+		connStr, modelUrl := connString(dialect, *user, *password, *host, *port, portSet, *schema)
+		if *dsn != "" {
+			connStr, modelUrl = *dsn, *dsn
+		}
+		db, err := sql.Open(dialect, connStr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
 
-	if nil != err {
-		log.Fatal(err)
+		s, err = core.GenerateSchemaWithOptions(db, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		modelOpts.DataSource = modelUrl
 	}
 
-	if nil != s {
+	if *out == "" {
 		fmt.Println(s)
+	} else {
+		if data, err := os.ReadFile(*out); err == nil {
+			previous, err := core.ParseProto(string(data))
+			if err != nil {
+				log.Fatalf("parse %s: %v", *out, err)
+			}
+			s.MergeFrom(previous)
+		} else if !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*out, []byte(s.String()+"\n"), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *modelDir != "" {
+		if err := core.GenerateModel(modelOpts); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// connString returns the connection string of the database driver, and the data source url of goctl.
+func connString(dialect, user, password, host string, port int, portSet bool, schema string) (string, string) {
+	switch dialect {
+	case core.DialectPostgres:
+		if !portSet {
+			port = 5432
+		}
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(user, password),
+			Host:     fmt.Sprintf("%s:%d", host, port),
+			Path:     "/" + schema,
+			RawQuery: "sslmode=disable",
+		}
+		return u.String(), u.String()
+	case core.DialectSqlite:
+		return schema, schema
 	}
+	connStr := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", user, password, host, port, schema)
+	return connStr, connStr
 }
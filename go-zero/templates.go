@@ -0,0 +1,9 @@
+// Package gozero 提供 go-zero 项目使用的 goctl 模版和静态文件
+package gozero
+
+import "embed"
+
+// Files goctl 模版（goctl-tmpl）和项目静态文件（static-file），servicekit 命令未指定模版目录时使用
+//
+//go:embed all:goctl-tmpl all:static-file
+var Files embed.FS
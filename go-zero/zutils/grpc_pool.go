@@ -0,0 +1,522 @@
+package zutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/magic-lib/go-plat-utils/conv"
+	"github.com/zeromicro/go-zero/core/metric"
+	"github.com/zeromicro/go-zero/zrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPoolSize           = 4
+	defaultHealthInterval     = 10 * time.Second
+	defaultHealthTimeout      = time.Second
+	defaultUnhealthyThreshold = 3
+	defaultWarmUpTimeout      = 5 * time.Second
+	poolMetricsNamespace      = "zrpc_client"
+	poolMetricsSubsystem      = "pool"
+	poolStateHealthy          = "healthy"
+	poolStateUnhealthy        = "unhealthy"
+	poolResultOk              = "ok"
+	poolResultNoHealthyConn   = "no_healthy_conn"
+	poolResultClosed          = "closed"
+)
+
+var (
+	// ErrPoolClosed 连接池已经关闭
+	ErrPoolClosed = errors.New("grpc pool is closed")
+	// ErrNoHealthyConn 连接池中没有健康的连接
+	ErrNoHealthyConn = errors.New("grpc pool has no healthy connection")
+
+	metricPoolConns = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: poolMetricsNamespace,
+		Subsystem: poolMetricsSubsystem,
+		Name:      "conns",
+		Help:      "grpc pool connections by health state.",
+		Labels:    []string{"pool", "state"},
+	})
+	metricPoolInFlight = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: poolMetricsNamespace,
+		Subsystem: poolMetricsSubsystem,
+		Name:      "in_flight",
+		Help:      "grpc pool in-flight calls.",
+		Labels:    []string{"pool"},
+	})
+	metricPoolGets = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: poolMetricsNamespace,
+		Subsystem: poolMetricsSubsystem,
+		Name:      "gets_total",
+		Help:      "grpc pool get results.",
+		Labels:    []string{"pool", "result"},
+	})
+	metricPoolReconnects = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: poolMetricsNamespace,
+		Subsystem: poolMetricsSubsystem,
+		Name:      "reconnects_total",
+		Help:      "grpc pool connections recreated after failing health checks.",
+		Labels:    []string{"pool"},
+	})
+)
+
+// HealthCheckConfig 通过 gRPC 健康检查协议探测连接
+type HealthCheckConfig struct {
+	Disable            bool          `json:",optional"`
+	Service            string        `json:",optional"`    //检查的服务名，为空表示整个服务端
+	Interval           time.Duration `json:",default=10s"` //检查间隔
+	Timeout            time.Duration `json:",default=1s"`  //单次检查超时时间
+	UnhealthyThreshold int           `json:",default=3"`   //连续失败多少次后重建连接
+}
+
+// PoolStats 连接池的使用情况
+type PoolStats struct {
+	Size      int   // 连接数
+	Healthy   int   // 健康的连接数
+	InFlight  int64 // 正在进行的调用数
+	Gets      int64 // 获取连接的次数
+	Failures  int64 // 获取连接失败的次数
+	Reconnect int64 // 重建连接的次数
+}
+
+// pooledConn 连接池中的一个连接
+type pooledConn struct {
+	conn     *grpc.ClientConn
+	inFlight atomic.Int64
+	healthy  atomic.Bool
+	failures int // 连续健康检查失败次数，只在检查协程中使用
+
+	retired   atomic.Bool // 已经被替换，正在进行的调用结束后关闭
+	closeOnce sync.Once
+}
+
+func (pc *pooledConn) begin() {
+	pc.inFlight.Add(1)
+}
+
+// end 调用结束，被替换的连接在最后一个调用结束时关闭
+func (pc *pooledConn) end() {
+	if pc.inFlight.Add(-1) == 0 && pc.retired.Load() {
+		pc.close()
+	}
+}
+
+func (pc *pooledConn) close() {
+	pc.closeOnce.Do(func() {
+		_ = pc.conn.Close()
+	})
+}
+
+// GrpcConnPool gRPC 连接池，每次从健康的连接中选择正在进行调用最少的一个
+// gRPC 连接本身支持多路复用，连接池用于分散单个连接的流量，连接不会按使用时间回收，只在健康检查连续失败后重建
+type GrpcConnPool struct {
+	name    string
+	cfg     zrpc.RpcClientConf
+	options []zrpc.ClientOption
+	health  HealthCheckConfig
+
+	mu     sync.RWMutex
+	conns  []*pooledConn
+	next   atomic.Uint64
+	closed atomic.Bool
+
+	gets       atomic.Int64
+	failures   atomic.Int64
+	reconnects atomic.Int64
+
+	redialMu   sync.Mutex
+	nextRedial atomic.Int64 // 下次允许在 Get 中重建连接的时间
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewGrpcConnPool 创建连接池，WarmUp 为 true 时等待连接就绪并完成第一次健康检查
+func NewGrpcConnPool(cfg *ZRpcClientConfig) (*GrpcConnPool, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("cfg is nil")
+	}
+	size := cfg.MaxSize
+	if size <= 0 {
+		size = defaultPoolSize
+	}
+	health := cfg.HealthCheck
+	if health.Interval <= 0 {
+		health.Interval = defaultHealthInterval
+	}
+	if health.Timeout <= 0 {
+		health.Timeout = defaultHealthTimeout
+	}
+	if health.UnhealthyThreshold <= 0 {
+		health.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+	name := cfg.Name
+	if name == "" {
+		name = cfg.ClientCfg.Target
+		if name == "" {
+			name = conv.String(cfg.ClientCfg.Endpoints)
+		}
+		if name == "" || name == "null" {
+			name = cfg.ClientCfg.Etcd.Key
+		}
+	}
+
+	p := &GrpcConnPool{
+		name:    name,
+		cfg:     cfg.ClientCfg,
+		health:  health,
+		conns:   make([]*pooledConn, size),
+		done:    make(chan struct{}),
+		options: cfg.ClientOptions,
+	}
+	var errs []error
+	for i := range p.conns {
+		pc, err := p.dial()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.conns[i] = pc
+	}
+	if len(errs) == size {
+		return nil, errors.Join(errs...)
+	}
+
+	if cfg.WarmUp {
+		timeout := cfg.WarmUpTimeout
+		if timeout <= 0 {
+			timeout = defaultWarmUpTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := p.warmUp(ctx)
+		cancel()
+		if err != nil {
+			p.closeConns()
+			return nil, err
+		}
+	}
+	p.updateMetrics()
+
+	if !health.Disable {
+		p.wg.Add(1)
+		go p.healthLoop()
+	}
+	return p, nil
+}
+
+func (p *GrpcConnPool) dial() (*pooledConn, error) {
+	pc := new(pooledConn)
+	pc.healthy.Store(true)
+	counter := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		pc.begin()
+		metricPoolInFlight.Inc(p.name)
+		defer func() {
+			pc.end()
+			metricPoolInFlight.Dec(p.name)
+		}()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	// 只统计一元调用，流式调用的生命周期由调用方控制
+	options := append([]zrpc.ClientOption{
+		zrpc.WithUnaryClientInterceptor(counter),
+	}, p.options...)
+
+	// 使用go-zero创建gRPC客户端
+	client, err := zrpc.NewClient(p.cfg, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zrpc client: %w (pool: %s)", err, p.name)
+	}
+	if client == nil || client.Conn() == nil {
+		return nil, fmt.Errorf("zrpc client or its connection is nil (pool: %s)", p.name)
+	}
+	pc.conn = client.Conn()
+	return pc, nil
+}
+
+// warmUp 建立全部连接并做一次健康检查，至少有一个健康的连接才算成功
+func (p *GrpcConnPool) warmUp(ctx context.Context) error {
+	p.mu.RLock()
+	conns := append([]*pooledConn(nil), p.conns...)
+	p.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, pc := range conns {
+		if pc == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pc.conn.Connect()
+			for state := pc.conn.GetState(); state != connectivity.Ready; state = pc.conn.GetState() {
+				if !pc.conn.WaitForStateChange(ctx, state) {
+					break
+				}
+			}
+			if p.health.Disable {
+				pc.healthy.Store(pc.conn.GetState() == connectivity.Ready)
+				return
+			}
+			pc.healthy.Store(p.check(ctx, pc) == nil)
+		}()
+	}
+	wg.Wait()
+	if p.Stats().Healthy == 0 {
+		return fmt.Errorf("warm up grpc pool %s: %w", p.name, ErrNoHealthyConn)
+	}
+	return nil
+}
+
+// check 调用 grpc.health.v1.Health/Check，服务端没有注册健康检查服务时按连接状态判断
+func (p *GrpcConnPool) check(ctx context.Context, pc *pooledConn) error {
+	ctx, cancel := context.WithTimeout(ctx, p.health.Timeout)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(pc.conn).Check(ctx, &healthpb.HealthCheckRequest{Service: p.health.Service})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented && pc.conn.GetState() == connectivity.Ready {
+			return nil
+		}
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("health status of %q is %s", p.health.Service, resp.GetStatus())
+	}
+	return nil
+}
+
+func (p *GrpcConnPool) healthLoop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.health.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.checkAll()
+		}
+	}
+}
+
+// checkAll 检查全部连接，连续失败达到阈值或者连接创建失败的位置重新建立连接
+func (p *GrpcConnPool) checkAll() {
+	p.mu.RLock()
+	conns := append([]*pooledConn(nil), p.conns...)
+	p.mu.RUnlock()
+
+	for i, pc := range conns {
+		if p.closed.Load() {
+			return
+		}
+		if pc != nil {
+			err := p.check(context.Background(), pc)
+			if err == nil {
+				pc.failures = 0
+				pc.healthy.Store(true)
+				continue
+			}
+			pc.failures++
+			pc.healthy.Store(false)
+			if pc.failures < p.health.UnhealthyThreshold {
+				continue
+			}
+			log.Printf("grpc pool %s conn %d unhealthy after %d checks, reconnecting: %v", p.name, i, pc.failures, err)
+		}
+		newConn, err := p.dial()
+		if err != nil {
+			log.Printf("grpc pool %s reconnect error: %v", p.name, err)
+			continue
+		}
+		p.mu.Lock()
+		if p.closed.Load() {
+			p.mu.Unlock()
+			newConn.close()
+			return
+		}
+		p.conns[i] = newConn
+		p.mu.Unlock()
+		p.reconnects.Add(1)
+		metricPoolReconnects.Inc(p.name)
+		if pc != nil {
+			p.closeWhenIdle(pc)
+		}
+	}
+	p.updateMetrics()
+}
+
+// closeWhenIdle 被替换的连接等正在进行的调用结束后再关闭，没有调用时立即关闭
+func (p *GrpcConnPool) closeWhenIdle(pc *pooledConn) {
+	pc.retired.Store(true)
+	if pc.inFlight.Load() == 0 {
+		pc.close()
+	}
+}
+
+// redialMissing 重新建立创建失败的连接，关闭健康检查时只能在 Get 中重建，
+// 每个检查间隔最多尝试一次，在后台进行不阻塞 Get
+func (p *GrpcConnPool) redialMissing() {
+	now := time.Now().UnixNano()
+	if now < p.nextRedial.Load() || !p.redialMu.TryLock() {
+		return
+	}
+	p.mu.RLock()
+	var missing []int
+	for i, pc := range p.conns {
+		if pc == nil {
+			missing = append(missing, i)
+		}
+	}
+	p.mu.RUnlock()
+	if len(missing) == 0 {
+		p.redialMu.Unlock()
+		return
+	}
+	p.nextRedial.Store(now + int64(p.health.Interval))
+
+	go func() {
+		defer p.redialMu.Unlock()
+		for _, i := range missing {
+			pc, err := p.dial()
+			if err != nil {
+				log.Printf("grpc pool %s redial error: %v", p.name, err)
+				continue
+			}
+			p.mu.Lock()
+			if p.closed.Load() || p.conns[i] != nil {
+				p.mu.Unlock()
+				pc.close()
+				continue
+			}
+			p.conns[i] = pc
+			p.mu.Unlock()
+			p.reconnects.Add(1)
+			metricPoolReconnects.Inc(p.name)
+		}
+		p.updateMetrics()
+	}()
+}
+
+// fallback 没有健康连接时返回任意一个连接，调用会返回 gRPC 错误
+func (p *GrpcConnPool) fallback() *grpc.ClientConn {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, pc := range p.conns {
+		if pc != nil {
+			return pc.conn
+		}
+	}
+	return nil
+}
+
+// Get 返回健康且正在进行调用最少的连接，没有健康连接时返回 ErrNoHealthyConn
+func (p *GrpcConnPool) Get() (*grpc.ClientConn, error) {
+	p.gets.Add(1)
+	if p.closed.Load() {
+		p.failures.Add(1)
+		metricPoolGets.Inc(p.name, poolResultClosed)
+		return nil, ErrPoolClosed
+	}
+	p.redialMissing()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	size := len(p.conns)
+	start := int(p.next.Add(1) % uint64(size))
+	var best *pooledConn
+	for i := 0; i < size; i++ {
+		pc := p.conns[(start+i)%size]
+		if pc == nil || !pc.healthy.Load() {
+			continue
+		}
+		if best == nil || pc.inFlight.Load() < best.inFlight.Load() {
+			best = pc
+		}
+	}
+	if best == nil {
+		p.failures.Add(1)
+		metricPoolGets.Inc(p.name, poolResultNoHealthyConn)
+		return nil, fmt.Errorf("%w: %s", ErrNoHealthyConn, p.name)
+	}
+	metricPoolGets.Inc(p.name, poolResultOk)
+	return best.conn, nil
+}
+
+// Stats 连接池的使用情况
+func (p *GrpcConnPool) Stats() PoolStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	stats := PoolStats{
+		Gets:      p.gets.Load(),
+		Failures:  p.failures.Load(),
+		Reconnect: p.reconnects.Load(),
+	}
+	for _, pc := range p.conns {
+		if pc == nil {
+			continue
+		}
+		stats.Size++
+		if pc.healthy.Load() {
+			stats.Healthy++
+		}
+		stats.InFlight += pc.inFlight.Load()
+	}
+	return stats
+}
+
+func (p *GrpcConnPool) updateMetrics() {
+	stats := p.Stats()
+	metricPoolConns.Set(float64(stats.Healthy), p.name, poolStateHealthy)
+	metricPoolConns.Set(float64(stats.Size-stats.Healthy), p.name, poolStateUnhealthy)
+}
+
+// Close 不再分配连接，等待正在进行的调用结束或者 ctx 结束后关闭全部连接
+func (p *GrpcConnPool) Close(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var err error
+	p.closeOnce.Do(func() {
+		p.closed.Store(true)
+		close(p.done)
+		p.wg.Wait()
+
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+	drain:
+		for p.Stats().InFlight > 0 {
+			select {
+			case <-ctx.Done():
+				err = fmt.Errorf("drain grpc pool %s: %w", p.name, ctx.Err())
+				break drain
+			case <-ticker.C:
+			}
+		}
+		p.closeConns()
+		metricPoolConns.Set(0, p.name, poolStateHealthy)
+		metricPoolConns.Set(0, p.name, poolStateUnhealthy)
+	})
+	return err
+}
+
+func (p *GrpcConnPool) closeConns() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, pc := range p.conns {
+		if pc != nil {
+			pc.close()
+			p.conns[i] = nil
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package zutils
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zeromicro/go-zero/zrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestRedialMissing(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+
+	pool, err := NewGrpcConnPool(&ZRpcClientConfig{
+		ClientCfg: zrpc.RpcClientConf{
+			Endpoints: []string{lis.Addr().String()},
+			NonBlock:  true,
+		},
+		MaxSize: 2,
+		HealthCheck: HealthCheckConfig{
+			Disable:  true,
+			Interval: time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close(nil)
+
+	// 模拟第一次创建失败的连接
+	pool.mu.Lock()
+	pool.conns[1].close()
+	pool.conns[1] = nil
+	pool.mu.Unlock()
+
+	if _, err = pool.Get(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for pool.Stats().Size != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("missing conn not redialed, stats = %+v", pool.Stats())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if stats := pool.Stats(); stats.Reconnect != 1 {
+		t.Fatalf("stats after redial = %+v", stats)
+	}
+}
+
+func TestCloseWhenIdle(t *testing.T) {
+	conn, err := grpc.NewClient("passthrough:///idle", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := &GrpcConnPool{health: HealthCheckConfig{Interval: time.Millisecond}}
+	pc := &pooledConn{conn: conn}
+
+	pc.begin()
+	pool.closeWhenIdle(pc)
+	time.Sleep(20 * time.Millisecond)
+	if state := conn.GetState(); state == connectivity.Shutdown {
+		t.Fatal("conn closed with a call in flight")
+	}
+	pc.end()
+	if state := conn.GetState(); state != connectivity.Shutdown {
+		t.Fatalf("conn state after last call = %s", state)
+	}
+
+	idle, err := grpc.NewClient("passthrough:///idle", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.closeWhenIdle(&pooledConn{conn: idle})
+	if state := idle.GetState(); state != connectivity.Shutdown {
+		t.Fatalf("idle conn state = %s", state)
+	}
+}
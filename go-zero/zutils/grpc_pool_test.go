@@ -0,0 +1,135 @@
+package zutils_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/magic-lib/go-servicekit/go-zero/zutils"
+	"github.com/zeromicro/go-zero/zrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func startHealthServer(t *testing.T) (string, *health.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String(), healthServer
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestGrpcConnPool(t *testing.T) {
+	addr, healthServer := startHealthServer(t)
+	cfg := &zutils.ZRpcClientConfig{
+		ClientCfg: zrpc.RpcClientConf{
+			Endpoints: []string{addr},
+			NonBlock:  true,
+			Timeout:   1000,
+		},
+		MaxSize: 2,
+		WarmUp:  true,
+		HealthCheck: zutils.HealthCheckConfig{
+			Interval:           50 * time.Millisecond,
+			Timeout:            200 * time.Millisecond,
+			UnhealthyThreshold: 2,
+		},
+	}
+	newClient, err := zutils.NewPoolGrpcClientWithErr(cfg, func(conn *grpc.ClientConn) healthpb.HealthClient {
+		return healthpb.NewHealthClient(conn)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := zutils.GetGrpcConnPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := pool.Stats(); stats.Size != 2 || stats.Healthy != 2 {
+		t.Fatalf("stats after warm up = %+v", stats)
+	}
+
+	client, err := newClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	waitFor(t, func() bool {
+		_, err := newClient()
+		return errors.Is(err, zutils.ErrNoHealthyConn)
+	})
+	// 连续失败达到阈值后重建连接
+	waitFor(t, func() bool {
+		return pool.Stats().Reconnect > 0
+	})
+
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	waitFor(t, func() bool {
+		_, err := newClient()
+		return err == nil
+	})
+	if stats := pool.Stats(); stats.Reconnect == 0 || stats.Failures == 0 {
+		t.Fatalf("stats after recovery = %+v", stats)
+	}
+
+	if err = zutils.CloseGrpcConnPools(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pool.Get(); !errors.Is(err, zutils.ErrPoolClosed) {
+		t.Fatalf("get after close error = %v", err)
+	}
+}
+
+func TestPoolGrpcClientAfterClose(t *testing.T) {
+	addr, _ := startHealthServer(t)
+	cfg := &zutils.ZRpcClientConfig{
+		ClientCfg: zrpc.RpcClientConf{
+			Endpoints: []string{addr},
+			NonBlock:  true,
+		},
+		Name:        "after-close",
+		MaxSize:     1,
+		HealthCheck: zutils.HealthCheckConfig{Disable: true},
+	}
+	newClient, err := zutils.NewPoolGrpcClient(cfg, func(conn *grpc.ClientConn) healthpb.HealthClient {
+		return healthpb.NewHealthClient(conn)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = newClient().Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = zutils.CloseGrpcConnPools(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 连接池关闭后调用返回错误，不会 panic
+	if _, err = newClient().Check(context.Background(), &healthpb.HealthCheckRequest{}); err == nil {
+		t.Fatal("expected error after pool closed")
+	}
+}
@@ -0,0 +1,155 @@
+package modelx
+
+import (
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Op 过滤条件的操作符
+type Op string
+
+const (
+	OpEq      Op = "="
+	OpNe      Op = "<>"
+	OpGt      Op = ">"
+	OpGte     Op = ">="
+	OpLt      Op = "<"
+	OpLte     Op = "<="
+	OpIn      Op = "IN"
+	OpNotIn   Op = "NOT IN"
+	OpLike    Op = "LIKE"
+	OpIsNull  Op = "IS NULL"
+	OpNotNull Op = "IS NOT NULL"
+)
+
+// Cond 单个过滤条件，多个条件之间是 AND 关系
+type Cond struct {
+	Column string
+	Op     Op
+	Value  any
+}
+
+// Order 排序字段
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+// Filter FindPage 的过滤和排序条件，字段名必须是表中的字段
+type Filter struct {
+	Conds       []Cond
+	Orders      []Order
+	WithDeleted bool //包含已软删除的记录
+}
+
+// NewFilter 创建过滤条件
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+// Where 追加一个过滤条件
+func (f *Filter) Where(column string, op Op, value any) *Filter {
+	f.Conds = append(f.Conds, Cond{Column: column, Op: op, Value: value})
+	return f
+}
+
+// Eq 等于
+func (f *Filter) Eq(column string, value any) *Filter {
+	return f.Where(column, OpEq, value)
+}
+
+// In 包含在列表中
+func (f *Filter) In(column string, values any) *Filter {
+	return f.Where(column, OpIn, values)
+}
+
+// Like 模糊匹配，value 需要自行带上 %
+func (f *Filter) Like(column string, value string) *Filter {
+	return f.Where(column, OpLike, value)
+}
+
+// Asc 升序
+func (f *Filter) Asc(column string) *Filter {
+	f.Orders = append(f.Orders, Order{Column: column})
+	return f
+}
+
+// Desc 降序
+func (f *Filter) Desc(column string) *Filter {
+	f.Orders = append(f.Orders, Order{Column: column, Desc: true})
+	return f
+}
+
+// Unscoped 包含已软删除的记录
+func (f *Filter) Unscoped() *Filter {
+	f.WithDeleted = true
+	return f
+}
+
+// toSql 生成 where 条件，不含软删除的条件
+func (f *Filter) toSql(t *Table) (sq.And, error) {
+	var where sq.And
+	if f == nil {
+		return where, nil
+	}
+	for _, c := range f.Conds {
+		if !t.HasColumn(c.Column) {
+			return nil, fmt.Errorf("modelx: unknown column %q in filter of %s", c.Column, t.name)
+		}
+		col := t.Quote(c.Column)
+		switch c.Op {
+		case OpEq:
+			where = append(where, sq.Eq{col: c.Value})
+		case OpNe:
+			where = append(where, sq.NotEq{col: c.Value})
+		case OpGt:
+			where = append(where, sq.Gt{col: c.Value})
+		case OpGte:
+			where = append(where, sq.GtOrEq{col: c.Value})
+		case OpLt:
+			where = append(where, sq.Lt{col: c.Value})
+		case OpLte:
+			where = append(where, sq.LtOrEq{col: c.Value})
+		case OpIn:
+			// squirrel 对切片生成 IN，空切片生成恒假的条件
+			where = append(where, sq.Eq{col: c.Value})
+		case OpNotIn:
+			where = append(where, sq.NotEq{col: c.Value})
+		case OpLike:
+			where = append(where, sq.Like{col: c.Value})
+		case OpIsNull:
+			where = append(where, sq.Eq{col: nil})
+		case OpNotNull:
+			where = append(where, sq.NotEq{col: nil})
+		default:
+			return nil, fmt.Errorf("modelx: unsupported operator %q", c.Op)
+		}
+	}
+	return where, nil
+}
+
+// orders 排序字段，最后补上主键保证顺序唯一，keyset 分页依赖这一点
+func (f *Filter) orders(t *Table) ([]Order, error) {
+	var orders []Order
+	if f != nil {
+		orders = append(orders, f.Orders...)
+	}
+	hasPrimary := false
+	for _, o := range orders {
+		if !t.HasColumn(o.Column) {
+			return nil, fmt.Errorf("modelx: unknown column %q in order of %s", o.Column, t.name)
+		}
+		if o.Column == t.primaryKey {
+			hasPrimary = true
+		}
+	}
+	if !hasPrimary {
+		desc := false
+		if len(orders) > 0 {
+			desc = orders[len(orders)-1].Desc
+		}
+		orders = append(orders, Order{Column: t.primaryKey, Desc: desc})
+	}
+	return orders, nil
+}
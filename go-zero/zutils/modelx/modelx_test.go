@@ -0,0 +1,267 @@
+package modelx_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magic-lib/go-plat-mysql/sqlstatement"
+	"github.com/magic-lib/go-servicekit/go-zero/zutils/modelx"
+	"github.com/zeromicro/go-zero/core/stores/sqlx"
+)
+
+type user struct {
+	Id        int64        `db:"id"`
+	Name      string       `db:"name"`
+	CreatedAt time.Time    `db:"created_at"`
+	DeletedAt sql.NullTime `db:"deleted_at"`
+	Version   int64        `db:"version"`
+}
+
+var userFieldNames = []string{"`id`", "`name`", "`created_at`", "`deleted_at`", "`version`"}
+
+type call struct {
+	query string
+	args  []any
+}
+
+// fakeSession 记录执行的sql，查询时返回预设的数据
+type fakeSession struct {
+	sqlx.Session
+	calls    []call
+	rows     []*user
+	total    int64
+	affected int64
+}
+
+func (s *fakeSession) ExecCtx(_ context.Context, query string, args ...any) (sql.Result, error) {
+	s.calls = append(s.calls, call{query, args})
+	return fakeResult(s.affected), nil
+}
+
+func (s *fakeSession) QueryRowCtx(_ context.Context, v any, query string, args ...any) error {
+	s.calls = append(s.calls, call{query, args})
+	*(v.(*int64)) = s.total
+	return nil
+}
+
+func (s *fakeSession) QueryRowsCtx(_ context.Context, v any, query string, args ...any) error {
+	s.calls = append(s.calls, call{query, args})
+	*(v.(*[]*user)) = s.rows
+	return nil
+}
+
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return 0, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func newUserTable() *modelx.Table {
+	return modelx.NewTable("`user`", userFieldNames, "`id`", modelx.WithIgnoreColumns("`created_at`"))
+}
+
+func TestNewTable(t *testing.T) {
+	table := newUserTable()
+	if !table.SoftDelete() || !table.Versioned() {
+		t.Fatal("deleted_at and version columns not detected")
+	}
+	if table.PrimaryKey() != "id" {
+		t.Fatalf("unexpected primary key %q", table.PrimaryKey())
+	}
+	if got := table.AndNotDeleted(); got != " and `deleted_at` is null" {
+		t.Fatalf("unexpected AndNotDeleted %q", got)
+	}
+
+	plain := modelx.NewTable(`"user"`, []string{`"id"`, `"name"`}, "id")
+	if plain.SoftDelete() || plain.Versioned() || plain.NotDeleted() != nil || plain.AndNotDeleted() != "" {
+		t.Fatal("table without deleted_at and version should not use them")
+	}
+	if plain.Quote("name") != `"name"` {
+		t.Fatal("postgresql field names should be quoted with double quotes")
+	}
+}
+
+func TestFindPageKeyset(t *testing.T) {
+	table := newUserTable()
+	base := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	session := &fakeSession{
+		total: 10,
+		rows: []*user{
+			{Id: 3, Name: "a", CreatedAt: base},
+			{Id: 2, Name: "a", CreatedAt: base.Add(-time.Second)},
+			{Id: 1, Name: "a", CreatedAt: base.Add(-2 * time.Second)},
+		},
+	}
+	filter := modelx.NewFilter().Eq("name", "a").Desc("created_at")
+	page, err := modelx.FindPage[user](context.Background(), session, table, filter, modelx.PageRequest{Limit: 2, Count: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.List) != 2 || !page.HasMore || page.NextCursor == "" || page.Total != 10 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if len(session.calls) != 2 {
+		t.Fatalf("expected count and select, got %d queries", len(session.calls))
+	}
+	count := session.calls[0]
+	if count.query != "SELECT COUNT(*) FROM `user` WHERE (`name` = ? AND `deleted_at` IS NULL)" {
+		t.Fatalf("unexpected count query: %s", count.query)
+	}
+	first := session.calls[1]
+	want := "SELECT `id`,`name`,`created_at`,`deleted_at`,`version` FROM `user` WHERE (`name` = ? AND `deleted_at` IS NULL) " +
+		"ORDER BY `created_at` DESC, `id` DESC LIMIT 3"
+	if first.query != want {
+		t.Fatalf("unexpected first page query:\n%s\nwant:\n%s", first.query, want)
+	}
+
+	session.calls, session.rows = nil, session.rows[2:]
+	next, err := modelx.FindPage[user](context.Background(), session, table, filter, modelx.PageRequest{Cursor: page.NextCursor, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.List) != 1 || next.HasMore || next.NextCursor != "" {
+		t.Fatalf("unexpected last page: %+v", next)
+	}
+	second := session.calls[0]
+	if !strings.Contains(second.query, "((`created_at` < ?) OR (`created_at` = ? AND `id` < ?))") {
+		t.Fatalf("unexpected keyset query: %s", second.query)
+	}
+	cursorAt := base.Add(-time.Second)
+	wantArgs := []any{"a", cursorAt, cursorAt, int64(2)}
+	if len(second.args) != len(wantArgs) {
+		t.Fatalf("unexpected keyset args: %v", second.args)
+	}
+	for i := range wantArgs {
+		if tm, ok := second.args[i].(time.Time); ok {
+			if !tm.Equal(wantArgs[i].(time.Time)) {
+				t.Fatalf("arg %d: got %v, want %v", i, tm, wantArgs[i])
+			}
+		} else if !reflect.DeepEqual(second.args[i], wantArgs[i]) {
+			t.Fatalf("arg %d: got %#v, want %#v", i, second.args[i], wantArgs[i])
+		}
+	}
+
+	// 排序变了，旧的游标不能再用
+	_, err = modelx.FindPage[user](context.Background(), session, table, modelx.NewFilter().Asc("name"), modelx.PageRequest{Cursor: page.NextCursor})
+	if !errors.Is(err, modelx.ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	_, err = modelx.FindPage[user](context.Background(), session, table, filter, modelx.PageRequest{Cursor: "not-a-cursor"})
+	if !errors.Is(err, modelx.ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestFindPageOffset(t *testing.T) {
+	table := newUserTable()
+	session := &fakeSession{}
+	filter := modelx.NewFilter().In("id", []int64{1, 2}).Where("name", modelx.OpLike, "a%").Unscoped()
+	_, err := modelx.FindPage[user](context.Background(), session, table, filter, modelx.PageRequest{Offset: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT `id`,`name`,`created_at`,`deleted_at`,`version` FROM `user` WHERE (`id` IN (?,?) AND `name` LIKE ?) " +
+		"ORDER BY `id` ASC LIMIT 21 OFFSET 40"
+	if session.calls[0].query != want {
+		t.Fatalf("unexpected query:\n%s\nwant:\n%s", session.calls[0].query, want)
+	}
+
+	_, err = modelx.FindPage[user](context.Background(), session, table, modelx.NewFilter().Eq("password", "x"), modelx.PageRequest{})
+	if err == nil {
+		t.Fatal("expected error for unknown column")
+	}
+}
+
+func TestUpdateWithVersion(t *testing.T) {
+	table := newUserTable()
+	session := &fakeSession{affected: 1}
+	data := &user{Id: 7, Name: "b", Version: 3}
+	if err := table.UpdateWithVersion(context.Background(), session, data); err != nil {
+		t.Fatal(err)
+	}
+	want := "UPDATE `user` SET `name` = ?, `version` = `version` + 1 WHERE `id` = ? AND `version` = ? AND `deleted_at` IS NULL"
+	if session.calls[0].query != want {
+		t.Fatalf("unexpected query:\n%s\nwant:\n%s", session.calls[0].query, want)
+	}
+	if !reflect.DeepEqual(session.calls[0].args, []any{"b", int64(7), int64(3)}) {
+		t.Fatalf("unexpected args: %v", session.calls[0].args)
+	}
+	if data.Version != 4 {
+		t.Fatalf("version should be bumped, got %d", data.Version)
+	}
+
+	session.affected = 0
+	if err := table.UpdateWithVersion(context.Background(), session, data); !errors.Is(err, modelx.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if data.Version != 4 {
+		t.Fatalf("version should not change on conflict, got %d", data.Version)
+	}
+
+	plain := modelx.NewTable("`user`", []string{"`id`", "`name`"}, "`id`")
+	if err := plain.UpdateWithVersion(context.Background(), session, data); !errors.Is(err, modelx.ErrNoVersionColumn) {
+		t.Fatalf("expected ErrNoVersionColumn, got %v", err)
+	}
+}
+
+func TestMarkDeleted(t *testing.T) {
+	table := newUserTable()
+	session := &fakeSession{}
+	if err := table.MarkDeleted(context.Background(), session, "`id` IN (?,?)", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	want := "UPDATE `user` SET `deleted_at` = ? WHERE (`id` IN (?,?)) AND `deleted_at` IS NULL"
+	if session.calls[0].query != want {
+		t.Fatalf("unexpected query:\n%s\nwant:\n%s", session.calls[0].query, want)
+	}
+	if err := table.MarkDeleted(context.Background(), session, " "); !errors.Is(err, modelx.ErrEmptyWhere) {
+		t.Fatalf("expected ErrEmptyWhere, got %v", err)
+	}
+}
+
+func TestWhereNotDeleted(t *testing.T) {
+	table := newUserTable()
+	if table.SoftDeleteColumn() != "deleted_at" {
+		t.Fatalf("unexpected soft delete column %q", table.SoftDeleteColumn())
+	}
+	st := new(sqlstatement.Statement)
+	where := sqlstatement.LogicCondition{
+		Conditions: []sqlstatement.ICondition{
+			sqlstatement.Condition{Field: "name", Operator: sqlstatement.OperatorEqual, Value: "tom"},
+			sqlstatement.Condition{Field: "id", Value: []int64{1, 2}},
+		},
+		Operator: sqlstatement.OperatorOr,
+	}
+	cases := []struct {
+		name  string
+		table *modelx.Table
+		where sqlstatement.LogicCondition
+		want  string
+		args  []any
+	}{
+		{"soft delete", table, where, "((`name` = ?) OR (`id` IN (?,?))) AND (`deleted_at` IS NULL)", []any{"tom", int64(1), int64(2)}},
+		{"empty where", table, sqlstatement.LogicCondition{}, "(`deleted_at` IS NULL)", []any{}},
+		{"no soft delete", modelx.NewTable("`user`", []string{"`id`", "`name`"}, "`id`"), where, "(`name` = ?) OR (`id` IN (?,?))", []any{"tom", int64(1), int64(2)}},
+	}
+	for _, c := range cases {
+		query, args := st.GenerateWhereClause(c.table.WhereNotDeleted(c.where))
+		if query != c.want || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: got %s %v, want %s %v", c.name, query, args, c.want, c.args)
+		}
+	}
+
+	// DeleteByWhere 生成的条件交给 MarkDeleted 软删除
+	query, args := st.GenerateWhereClause(where)
+	session := &fakeSession{}
+	if err := table.MarkDeleted(context.Background(), session, query, args...); err != nil {
+		t.Fatal(err)
+	}
+	want := "UPDATE `user` SET `deleted_at` = ? WHERE ((`name` = ?) OR (`id` IN (?,?))) AND `deleted_at` IS NULL"
+	if session.calls[0].query != want {
+		t.Fatalf("unexpected query:\n%s\nwant:\n%s", session.calls[0].query, want)
+	}
+}
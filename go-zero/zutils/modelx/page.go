@@ -0,0 +1,251 @@
+package modelx
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/zeromicro/go-zero/core/stores/sqlx"
+)
+
+const (
+	// DefaultPageLimit 未指定每页数量时的默认值
+	DefaultPageLimit = 20
+	// MaxPageLimit 每页数量的上限
+	MaxPageLimit = 1000
+)
+
+// ErrInvalidCursor 游标格式错误，或与当前的排序字段不一致
+var ErrInvalidCursor = errors.New("modelx: invalid page cursor")
+
+// PageRequest 分页参数，Cursor 不为空时使用 keyset 分页并忽略 Offset
+type PageRequest struct {
+	Cursor string //上一页返回的 NextCursor
+	Offset int
+	Limit  int
+	Count  bool //是否统计满足条件的总数
+}
+
+// Page 分页结果
+type Page[T any] struct {
+	List       []*T
+	NextCursor string //还有下一页时不为空，offset 分页也会返回，可以切换为 keyset 分页
+	HasMore    bool
+	Total      int64 //只有 PageRequest.Count 为 true 时才统计
+}
+
+// FindPage 按过滤条件分页查询，排序字段最后总是补上主键，保证 keyset 分页不重复不遗漏。
+// 排序字段应为 NOT NULL 的字段，游标中不能保存 NULL 值
+func FindPage[T any](ctx context.Context, session sqlx.Session, t *Table, filter *Filter, req PageRequest) (*Page[T], error) {
+	where, err := filter.toSql(t)
+	if err != nil {
+		return nil, err
+	}
+	if (filter == nil || !filter.WithDeleted) && t.SoftDelete() {
+		where = append(where, t.NotDeleted())
+	}
+	orders, err := filter.orders(t)
+	if err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	} else if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	page := &Page[T]{List: make([]*T, 0)}
+	if req.Count {
+		builder := sq.Select("COUNT(*)").From(t.name).PlaceholderFormat(t.placeholder())
+		if len(where) > 0 {
+			builder = builder.Where(where)
+		}
+		query, args, err := builder.ToSql()
+		if err != nil {
+			return nil, err
+		}
+		if err = session.QueryRowCtx(ctx, &page.Total, query, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	query, args, err := t.pageSql(where, orders, req.Cursor, req.Offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err = session.QueryRowsCtx(ctx, &page.List, query, args...); err != nil {
+		return nil, err
+	}
+	if len(page.List) > limit {
+		page.List = page.List[:limit]
+		page.HasMore = true
+		page.NextCursor, err = encodeCursor(orders, page.List[limit-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// pageSql 多查一条用来判断是否还有下一页
+func (t *Table) pageSql(where sq.And, orders []Order, cursor string, offset, limit int) (string, []any, error) {
+	if cursor != "" {
+		values, err := decodeCursor(orders, cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		where = append(where, t.keyset(orders, values))
+	}
+	columns := make([]string, 0, len(t.columns))
+	for _, col := range t.columns {
+		columns = append(columns, t.Quote(col))
+	}
+	builder := sq.Select(strings.Join(columns, ",")).From(t.name).PlaceholderFormat(t.placeholder())
+	if len(where) > 0 {
+		builder = builder.Where(where)
+	}
+	for _, o := range orders {
+		if o.Desc {
+			builder = builder.OrderBy(t.Quote(o.Column) + " DESC")
+		} else {
+			builder = builder.OrderBy(t.Quote(o.Column) + " ASC")
+		}
+	}
+	if cursor == "" && offset > 0 {
+		builder = builder.Offset(uint64(offset))
+	}
+	return builder.Limit(uint64(limit + 1)).ToSql()
+}
+
+// keyset 排在游标之后的条件：(a > ?) OR (a = ? AND b > ?) ...，支持升降序混合
+func (t *Table) keyset(orders []Order, values []any) sq.Sqlizer {
+	var or sq.Or
+	for i, o := range orders {
+		var and sq.And
+		for j := 0; j < i; j++ {
+			and = append(and, sq.Eq{t.Quote(orders[j].Column): values[j]})
+		}
+		if o.Desc {
+			and = append(and, sq.Lt{t.Quote(o.Column): values[i]})
+		} else {
+			and = append(and, sq.Gt{t.Quote(o.Column): values[i]})
+		}
+		or = append(or, and)
+	}
+	return or
+}
+
+// pageCursor 游标内容：排序字段（降序带 - 前缀）和最后一条记录的排序字段值
+type pageCursor struct {
+	Keys   []string `json:"k"`
+	Values []any    `json:"v"`
+}
+
+// cursorTime json 中的时间值，与字符串区分开
+type cursorTime struct {
+	Time string `json:"t"`
+}
+
+func orderKeys(orders []Order) []string {
+	keys := make([]string, 0, len(orders))
+	for _, o := range orders {
+		if o.Desc {
+			keys = append(keys, "-"+o.Column)
+		} else {
+			keys = append(keys, o.Column)
+		}
+	}
+	return keys
+}
+
+func encodeCursor(orders []Order, row any) (string, error) {
+	fields, err := columnFields(row)
+	if err != nil {
+		return "", err
+	}
+	c := pageCursor{Keys: orderKeys(orders)}
+	for _, o := range orders {
+		field, ok := fields[o.Column]
+		if !ok {
+			return "", fmt.Errorf("modelx: %T has no field for column %s", row, o.Column)
+		}
+		v := field.Interface()
+		if valuer, ok := v.(driver.Valuer); ok {
+			if v, err = valuer.Value(); err != nil {
+				return "", err
+			}
+		}
+		switch val := v.(type) {
+		case nil:
+			return "", fmt.Errorf("modelx: order column %s is NULL, cannot build page cursor", o.Column)
+		case time.Time:
+			v = cursorTime{Time: val.Format(time.RFC3339Nano)}
+		case []byte:
+			v = string(val)
+		}
+		c.Values = append(c.Values, v)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(orders []Order, cursor string) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var c pageCursor
+	if err = decoder.Decode(&c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	keys := orderKeys(orders)
+	if len(c.Keys) != len(keys) || len(c.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+	for i := range keys {
+		if c.Keys[i] != keys[i] {
+			return nil, ErrInvalidCursor
+		}
+	}
+	values := make([]any, len(c.Values))
+	for i, v := range c.Values {
+		switch val := v.(type) {
+		case json.Number:
+			if n, err := val.Int64(); err == nil {
+				values[i] = n
+			} else if f, err := val.Float64(); err == nil {
+				values[i] = f
+			} else {
+				return nil, ErrInvalidCursor
+			}
+		case map[string]any:
+			s, ok := val["t"].(string)
+			if !ok {
+				return nil, ErrInvalidCursor
+			}
+			tm, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			values[i] = tm
+		case nil, []any:
+			return nil, ErrInvalidCursor
+		default:
+			values[i] = val
+		}
+	}
+	return values, nil
+}
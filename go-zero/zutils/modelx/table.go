@@ -0,0 +1,295 @@
+package modelx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/magic-lib/go-plat-mysql/sqlstatement"
+	"github.com/zeromicro/go-zero/core/stores/sqlx"
+)
+
+const (
+	// DefaultSoftDeleteColumn 软删除字段，为 NULL 表示未删除
+	DefaultSoftDeleteColumn = "deleted_at"
+	// DefaultVersionColumn 乐观锁的版本号字段
+	DefaultVersionColumn = "version"
+)
+
+var (
+	// ErrVersionConflict 乐观锁冲突：记录不存在、已删除或已被其它请求修改
+	ErrVersionConflict = errors.New("modelx: version conflict")
+	// ErrNoVersionColumn 表没有版本号字段，不能使用乐观锁
+	ErrNoVersionColumn = errors.New("modelx: table has no version column")
+	// ErrEmptyWhere 写操作的条件不能为空，避免全表误操作
+	ErrEmptyWhere = errors.New("modelx: where condition is empty")
+)
+
+// Table goctl 生成的 model 对应的表信息，软删除和乐观锁字段按表结构自动识别
+type Table struct {
+	name          string
+	columns       []string
+	columnSet     map[string]struct{}
+	primaryKey    string
+	softDelete    string
+	version       string
+	ignoreColumns map[string]struct{}
+	postgres      bool
+}
+
+// TableOption 表信息的可选项
+type TableOption func(t *Table)
+
+// WithIgnoreColumns 更新时忽略的字段，由数据库维护，如 create_time、update_time
+func WithIgnoreColumns(columns ...string) TableOption {
+	return func(t *Table) {
+		for _, col := range columns {
+			t.ignoreColumns[unquote(col)] = struct{}{}
+		}
+	}
+}
+
+// WithSoftDeleteColumn 指定软删除字段，为空表示不使用软删除
+func WithSoftDeleteColumn(column string) TableOption {
+	return func(t *Table) {
+		t.softDelete = unquote(column)
+	}
+}
+
+// WithVersionColumn 指定乐观锁的版本号字段，为空表示不使用乐观锁
+func WithVersionColumn(column string) TableOption {
+	return func(t *Table) {
+		t.version = unquote(column)
+	}
+}
+
+// NewTable 根据 goctl 生成的 fieldNames（builder.RawFieldNames 的结果）创建表信息，
+// 字段名带双引号时按 postgresql 处理
+func NewTable(table string, fieldNames []string, primaryKey string, opts ...TableOption) *Table {
+	t := &Table{
+		name:          table,
+		columnSet:     make(map[string]struct{}, len(fieldNames)),
+		primaryKey:    unquote(primaryKey),
+		softDelete:    DefaultSoftDeleteColumn,
+		version:       DefaultVersionColumn,
+		ignoreColumns: map[string]struct{}{},
+	}
+	for _, name := range fieldNames {
+		if strings.HasPrefix(name, `"`) {
+			t.postgres = true
+		}
+		col := unquote(name)
+		t.columns = append(t.columns, col)
+		t.columnSet[col] = struct{}{}
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if !t.HasColumn(t.softDelete) {
+		t.softDelete = ""
+	}
+	if !t.HasColumn(t.version) {
+		t.version = ""
+	}
+	return t
+}
+
+// Name 表名
+func (t *Table) Name() string {
+	return t.name
+}
+
+// PrimaryKey 主键字段名
+func (t *Table) PrimaryKey() string {
+	return t.primaryKey
+}
+
+// HasColumn 是否包含该字段
+func (t *Table) HasColumn(column string) bool {
+	_, ok := t.columnSet[column]
+	return ok
+}
+
+// SoftDelete 是否使用软删除
+func (t *Table) SoftDelete() bool {
+	return t.softDelete != ""
+}
+
+// Versioned 是否使用乐观锁
+func (t *Table) Versioned() bool {
+	return t.version != ""
+}
+
+// Quote 按数据库类型给字段名加引号
+func (t *Table) Quote(column string) string {
+	if t.postgres {
+		return `"` + column + `"`
+	}
+	return "`" + column + "`"
+}
+
+// SoftDeleteColumn 软删除字段名，未使用软删除时为空
+func (t *Table) SoftDeleteColumn() string {
+	return t.softDelete
+}
+
+// NotDeleted 未删除的条件，未使用软删除时返回 nil
+func (t *Table) NotDeleted() sq.Sqlizer {
+	if !t.SoftDelete() {
+		return nil
+	}
+	return sq.Expr(t.Quote(t.softDelete) + " IS NULL")
+}
+
+// WhereNotDeleted 给 sqlstatement 的查询条件追加未删除条件，未使用软删除时原样返回
+func (t *Table) WhereNotDeleted(where sqlstatement.LogicCondition) sqlstatement.LogicCondition {
+	if !t.SoftDelete() {
+		return where
+	}
+	return sqlstatement.LogicCondition{
+		Conditions: []sqlstatement.ICondition{
+			where,
+			// IS 不使用 Value，但 Value 为 nil 的条件会被忽略
+			sqlstatement.Condition{Field: t.softDelete, Operator: sqlstatement.OperatorIs, Value: "NULL"},
+		},
+		Operator: sqlstatement.OperatorAnd,
+	}
+}
+
+// AndNotDeleted 追加在原生sql的 where 后面的未删除条件
+func (t *Table) AndNotDeleted() string {
+	if !t.SoftDelete() {
+		return ""
+	}
+	return " and " + t.Quote(t.softDelete) + " is null"
+}
+
+func (t *Table) placeholder() sq.PlaceholderFormat {
+	if t.postgres {
+		return sq.Dollar
+	}
+	return sq.Question
+}
+
+// MarkDeleted 软删除满足条件的记录，where 使用 ? 占位符
+func (t *Table) MarkDeleted(ctx context.Context, session sqlx.Session, where string, args ...any) error {
+	if !t.SoftDelete() {
+		return fmt.Errorf("modelx: table %s has no soft delete column", t.name)
+	}
+	if strings.TrimSpace(where) == "" {
+		return ErrEmptyWhere
+	}
+	query, data, err := sq.Update(t.name).
+		Set(t.Quote(t.softDelete), time.Now()).
+		// 加括号，避免条件中的 OR 和追加的未删除条件混在一起
+		Where("("+where+")", args...).
+		Where(t.NotDeleted()).
+		PlaceholderFormat(t.placeholder()).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = session.ExecCtx(ctx, query, data...)
+	return err
+}
+
+// UpdateWithVersion 按主键和版本号更新记录的全部字段，版本号加1，
+// 没有更新到记录时返回 ErrVersionConflict，成功后 data 中的版本号同步加1
+func (t *Table) UpdateWithVersion(ctx context.Context, session sqlx.Session, data any) error {
+	if !t.Versioned() {
+		return ErrNoVersionColumn
+	}
+	fields, err := columnFields(data)
+	if err != nil {
+		return err
+	}
+	pk, ok := fields[t.primaryKey]
+	if !ok {
+		return fmt.Errorf("modelx: %T has no field for primary key %s", data, t.primaryKey)
+	}
+	ver, ok := fields[t.version]
+	if !ok {
+		return fmt.Errorf("modelx: %T has no field for version column %s", data, t.version)
+	}
+
+	builder := sq.Update(t.name)
+	for _, col := range t.columns {
+		if _, ok := t.ignoreColumns[col]; ok {
+			continue
+		}
+		if col == t.primaryKey || col == t.version || col == t.softDelete {
+			continue
+		}
+		field, ok := fields[col]
+		if !ok {
+			continue
+		}
+		builder = builder.Set(t.Quote(col), field.Interface())
+	}
+	query, args, err := builder.
+		Set(t.Quote(t.version), sq.Expr(t.Quote(t.version)+" + 1")).
+		Where(sq.Eq{t.Quote(t.primaryKey): pk.Interface(), t.Quote(t.version): ver.Interface()}).
+		Where(t.NotDeleted()).
+		PlaceholderFormat(t.placeholder()).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	result, err := session.ExecCtx(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrVersionConflict
+	}
+	switch ver.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		ver.SetInt(ver.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		ver.SetUint(ver.Uint() + 1)
+	}
+	return nil
+}
+
+// columnFields 按 db tag 取结构体的字段，与 builder.RawFieldNames 的规则一致
+func columnFields(data any) (map[string]reflect.Value, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, errors.New("modelx: data is nil")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("modelx: %T is not a struct", data)
+	}
+	typ := v.Type()
+	fields := make(map[string]reflect.Value, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := strings.Split(sf.Tag.Get("db"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields[name] = v.Field(i)
+	}
+	return fields, nil
+}
+
+func unquote(name string) string {
+	return strings.Trim(strings.TrimSpace(name), "`\"")
+}
@@ -1,28 +1,83 @@
 package zutils
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/magic-lib/go-plat-cache/cache"
-	"github.com/magic-lib/go-plat-utils/conv"
-	"github.com/magic-lib/go-plat-utils/utils"
-	"github.com/orcaman/concurrent-map/v2"
 	"github.com/zeromicro/go-zero/zrpc"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"sync"
 	"time"
 )
 
 type ZRpcClientConfig struct {
 	ClientCfg     zrpc.RpcClientConf
-	ClientOptions []zrpc.ClientOption
-	MaxSize       int
-	MaxUsage      time.Duration
+	Name          string              `json:",optional"`  //连接池名称，用于监控指标，默认使用 Target 或者 Endpoints
+	ClientOptions []zrpc.ClientOption `json:"-"`          //相同配置的连接池只在第一次创建时使用
+	MaxSize       int                 `json:",default=4"` //连接数
+	WarmUp        bool                `json:",optional"`  //创建时等待连接就绪并完成健康检查
+	WarmUpTimeout time.Duration       `json:",optional"`  //预热的超时时间，默认5秒
+	HealthCheck   HealthCheckConfig   `json:",optional"`
+
+	// Deprecated: 健康的连接不再按使用时间回收，由 HealthCheck 判断是否需要重建
+	MaxUsage time.Duration `json:",optional"`
 }
 
 var (
-	singletonGrpcClient = cmap.New[any]()
+	grpcPoolsMu sync.Mutex
+	grpcPools   = make(map[string]*GrpcConnPool)
 )
 
+// closedConn 连接池关闭后使用的连接，调用直接返回错误，不会像零值连接一样 panic
+var closedConn = sync.OnceValue(func() *grpc.ClientConn {
+	conn, err := grpc.NewClient("passthrough:///closed", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		panic(err)
+	}
+	_ = conn.Close()
+	return conn
+})
+
+// poolKey 相同配置共用一个连接池，ClientOptions 是函数无法比较，不参与计算
+func poolKey(cfg *ZRpcClientConfig) (string, error) {
+	key, err := json.Marshal(struct {
+		Name        string
+		ClientCfg   zrpc.RpcClientConf
+		MaxSize     int
+		HealthCheck HealthCheckConfig
+	}{cfg.Name, cfg.ClientCfg, cfg.MaxSize, cfg.HealthCheck})
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
+
+// GetGrpcConnPool 按配置返回连接池，相同配置只创建一次
+func GetGrpcConnPool(cfg *ZRpcClientConfig) (*GrpcConnPool, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("cfg is nil")
+	}
+	key, err := poolKey(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool key: %w", err)
+	}
+	grpcPoolsMu.Lock()
+	defer grpcPoolsMu.Unlock()
+	if pool, ok := grpcPools[key]; ok && !pool.closed.Load() {
+		return pool, nil
+	}
+	pool, err := NewGrpcConnPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+	grpcPools[key] = pool
+	return pool, nil
+}
+
+// NewPoolGrpcClient 返回创建 gRPC 客户端的方法，每次调用从连接池中取一个健康的连接，
+// 没有健康连接时使用任意一个连接，由调用返回错误，需要提前判断的使用 NewPoolGrpcClientWithErr
 func NewPoolGrpcClient[T any](cfg *ZRpcClientConfig, fun func(clientConn *grpc.ClientConn) T) (func() T, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("cfg is nil")
@@ -30,94 +85,59 @@ func NewPoolGrpcClient[T any](cfg *ZRpcClientConfig, fun func(clientConn *grpc.C
 	if fun == nil {
 		return nil, fmt.Errorf("client constructor function 'fun' is nil")
 	}
-	typeKey, err := utils.GetGenericTypeId[T]()
+	pool, err := GetGrpcConnPool(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get type unique id: %v", err)
-	}
-	if singletonGrpcClient.Has(typeKey) {
-		if existingVal, ok := singletonGrpcClient.Get(typeKey); ok {
-			if oneSingleton, ok := existingVal.(func() T); ok {
-				return oneSingleton, nil
-			}
-		}
-		return nil, fmt.Errorf("singleton cache already exists for type %s", typeKey)
+		return nil, err
 	}
 
-	connPool := newZRPCConnPool(cfg.ClientCfg, cfg.MaxSize, cfg.MaxUsage, cfg.ClientOptions...)
-
-	oneSingleton := func() T {
-		var retConn *grpc.ClientConn
-		connWrapper, err := connPool.Get()
-		if err == nil && connWrapper != nil {
-			retConn = connWrapper.Get()
-		} else {
-			retConn = new(grpc.ClientConn)
+	return func() T {
+		conn, err := pool.Get()
+		if err != nil {
+			if conn = pool.fallback(); conn == nil {
+				conn = closedConn()
+			}
 		}
-
 		// 通过连接构造具体的gRPC客户端
-		client := fun(retConn)
-		return client
-	}
-	singletonGrpcClient.Set(typeKey, oneSingleton)
-	return oneSingleton, nil
+		return fun(conn)
+	}, nil
 }
 
-// newZRPCConnPool 创建go-zero gRPC连接池
-// 参数：
-//
-//	cfg - RPC客户端配置
-//	maxSize - 连接池最大连接数（<=0时使用默认值10）
-//	maxUsage - 连接最长使用时间（0时使用默认值30秒）
-//	options - 额外的gRPC客户端选项
-//
-// 返回：gRPC连接池实例
-func newZRPCConnPool(cfg zrpc.RpcClientConf, maxSize int, maxUsage time.Duration, options ...zrpc.ClientOption) *cache.CommPool[*grpc.ClientConn] {
-	// 设置默认值
-	if maxSize <= 0 {
-		maxSize = 10
+// NewPoolGrpcClientWithErr 与 NewPoolGrpcClient 相同，没有健康连接时返回错误
+func NewPoolGrpcClientWithErr[T any](cfg *ZRpcClientConfig, fun func(clientConn *grpc.ClientConn) T) (func() (T, error), error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("cfg is nil")
 	}
-	if maxUsage == 0 {
-		maxUsage = 30 * time.Second
+	if fun == nil {
+		return nil, fmt.Errorf("client constructor function 'fun' is nil")
+	}
+	pool, err := GetGrpcConnPool(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	// 连接健康检查函数
-	checkConnHealth := func(conn *grpc.ClientConn) error {
-		state := conn.GetState()
-		// 仅当连接状态为非就绪/非连接中/非空闲时判定为异常
-		if state != connectivity.Idle && state != connectivity.Connecting && state != connectivity.Ready {
-			return fmt.Errorf("invalid connection state: %s (etcd: %s, endpoints: %s)",
-				state.String(), conv.String(cfg.Etcd), conv.String(cfg.Endpoints))
+	return func() (T, error) {
+		conn, err := pool.Get()
+		if err != nil {
+			var zero T
+			return zero, err
 		}
-		return nil
-	}
+		// 通过连接构造具体的gRPC客户端
+		return fun(conn), nil
+	}, nil
+}
 
-	// 连接关闭函数
-	closeConn := func(conn *grpc.ClientConn) error {
-		if conn == nil {
-			return nil
+// CloseGrpcConnPools 关闭全部连接池，等待正在进行的调用结束或者 ctx 结束
+func CloseGrpcConnPools(ctx context.Context) error {
+	grpcPoolsMu.Lock()
+	pools := grpcPools
+	grpcPools = make(map[string]*GrpcConnPool)
+	grpcPoolsMu.Unlock()
+
+	var errs []error
+	for _, pool := range pools {
+		if err := pool.Close(ctx); err != nil {
+			errs = append(errs, err)
 		}
-		return conn.Close()
-	}
-	// 创建连接池
-	poolConfig := &cache.ResPoolConfig[*grpc.ClientConn]{
-		MaxSize:  maxSize,
-		MaxUsage: maxUsage,
-		// 新建连接的函数
-		New: func() (*grpc.ClientConn, error) {
-			// 使用go-zero创建gRPC客户端
-			client, err := zrpc.NewClient(cfg, options...)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create zrpc client: %w (etcd: %s, endpoints: %s)",
-					err, conv.String(cfg.Etcd), conv.String(cfg.Endpoints))
-			}
-			if client == nil || client.Conn() == nil {
-				return nil, fmt.Errorf("zrpc client or its connection is nil (etcd: %s, endpoints: %s)",
-					conv.String(cfg.Etcd), conv.String(cfg.Endpoints))
-			}
-			return client.Conn(), nil
-		},
-		CheckFunc: checkConnHealth,
-		CloseFunc: closeConn,
 	}
-	return cache.NewResPool(poolConfig)
+	return errors.Join(errs...)
 }
@@ -0,0 +1,82 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AuditEventType token相关审计事件类型
+type AuditEventType string
+
+const (
+	AuditTokenIssued    AuditEventType = "issued"      // 颁发token
+	AuditTokenRefreshed AuditEventType = "refreshed"   // 刷新token
+	AuditTokenRevoked   AuditEventType = "revoked"     // 吊销token
+	AuditAuthFailed     AuditEventType = "failed_auth" // 认证失败或被限流
+)
+
+// AuditEvent 一条结构化的审计记录
+type AuditEvent struct {
+	Type     AuditEventType `json:"type"`
+	ClientID string         `json:"client_id"`
+	Scope    string         `json:"scope,omitempty"`
+	IP       string         `json:"ip,omitempty"`
+	TraceID  string         `json:"trace_id,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Time     time.Time      `json:"time"`
+}
+
+// AuditSink 审计事件的输出位置，可以写日志、消息队列或数据库
+type AuditSink interface {
+	Write(ctx context.Context, event *AuditEvent)
+}
+
+// AuditSinkFunc 函数形式的 AuditSink
+type AuditSinkFunc func(ctx context.Context, event *AuditEvent)
+
+// Write 实现 AuditSink
+func (f AuditSinkFunc) Write(ctx context.Context, event *AuditEvent) {
+	f(ctx, event)
+}
+
+// LogAuditSink 以JSON格式写入标准日志，未配置 AuditSink 时默认使用
+var LogAuditSink AuditSink = AuditSinkFunc(func(_ context.Context, event *AuditEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Print("oauth2 audit marshal error:", err.Error())
+		return
+	}
+	log.Print("oauth2 audit:", string(data))
+})
+
+type clientIPKey struct{}
+
+// ContextWithClientIP 在ctx中带上请求方IP，RevokeToken 等不经过token接口的审计事件会从中读取
+func ContextWithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func (c *ClientCredentials) audit(ctx context.Context, event *AuditEvent) {
+	sink := c.AuditSink
+	if sink == nil {
+		sink = LogAuditSink
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.IP == "" {
+		if ip, ok := ctx.Value(clientIPKey{}).(string); ok {
+			event.IP = ip
+		}
+	}
+	if event.TraceID == "" {
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+			event.TraceID = spanCtx.TraceID().String()
+		}
+	}
+	sink.Write(ctx, event)
+}
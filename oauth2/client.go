@@ -15,8 +15,10 @@ import (
 	"github.com/magic-lib/go-plat-utils/conv"
 	"github.com/magic-lib/go-plat-utils/utils/httputil/param"
 	"github.com/magic-lib/go-servicekit/oauth2/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -82,9 +84,12 @@ type ClientCredentials struct {
 	ClientStorage      oauth2.ClientStore           //client存储方式
 	JWTAccessGenerate  *generates.JWTAccessGenerate //jwt的配置，如果配置了，则会使用jwt生成方式
 	ClientScopeHandler server.ClientScopeHandler    //判断权限scope的范围是否合法
+	RateLimit          *RateLimitConfig             //按clientId和IP限流，以及失败锁定，为空则不限制
+	AuditSink          AuditSink                    //审计事件输出，为空则写入标准日志
 
 	getAccessToken server.AuthorizeScopeHandler
 	server         *server.Server
+	limiter        *tokenLimiter
 
 	serverName           string
 	jwtSecret            string
@@ -112,6 +117,11 @@ func NewClientCredentials(cfg *ClientCredentials) (*ClientCredentials, error) {
 			cfg.JWTAccessGenerate.SignedMethod = jwt.SigningMethodHS512
 		}
 	}
+	limiter, err := newTokenLimiter(cfg.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	cfg.limiter = limiter
 	_ = cfg.GetServer()
 	return cfg, nil
 }
@@ -132,11 +142,9 @@ func (c *ClientCredentials) GetServer() *server.Server {
 	srv.SetClientInfoHandler(server.ClientFormHandler)
 	srv.SetAllowGetAccessRequest(true)
 	clientInfoHandler := func(r *http.Request) (clientID, clientSecret string, err error) {
-		req := new(types.ClientTokenRequest)
-		query := param.NewParam().GetAllString(r)
-		err = conv.Unmarshal(query, req)
+		req, err := parseClientTokenRequest(r)
 		if err != nil {
-			return "", "", errors.ErrInvalidRequest
+			return "", "", err
 		}
 		if req.ClientID == "" || req.ClientSecret == "" {
 			return "", "", errors.ErrInvalidClient
@@ -166,14 +174,123 @@ func (c *ClientCredentials) getTokenPath() string {
 	return path
 }
 
+func parseClientTokenRequest(r *http.Request) (*types.ClientTokenRequest, error) {
+	req := new(types.ClientTokenRequest)
+	query := param.NewParam().GetAllString(r)
+	if err := conv.Unmarshal(query, req); err != nil {
+		return nil, errors.ErrInvalidRequest
+	}
+	return req, nil
+}
+
 func (c *ClientCredentials) GetHttpServerHandler() (http.HandlerFunc, string) {
-	srv := c.GetServer()
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := srv.HandleTokenRequest(w, r); err != nil {
+		if err := c.handleTokenRequest(w, r); err != nil {
 			log.Print("Internal Error:", err.Error())
 		}
 	}, c.getTokenPath()
 }
+
+// handleTokenRequest 与 server.HandleTokenRequest 流程一致，增加了限流、失败锁定和审计
+func (c *ClientCredentials) handleTokenRequest(w http.ResponseWriter, r *http.Request) error {
+	srv := c.GetServer()
+	ctx := r.Context()
+
+	event := &AuditEvent{Type: AuditAuthFailed, IP: c.clientIP(r)}
+	if req, err := parseClientTokenRequest(r); err == nil {
+		event.ClientID = req.ClientID
+		event.Scope = req.Scope
+	}
+	if c.limiter != nil {
+		if err := c.limiter.allow(ctx, event.ClientID, event.IP); err != nil {
+			event.Error = err.Error()
+			c.audit(ctx, event)
+			if err != errTooManyRequests && err != errClientLocked && err != errIPLocked {
+				return c.tokenError(w, err)
+			}
+			header := http.Header{}
+			header.Set("Retry-After", strconv.Itoa(c.limiter.retryAfter(err)))
+			return writeToResponse(w, map[string]interface{}{
+				"error":             err.Error(),
+				"error_description": http.StatusText(http.StatusTooManyRequests),
+			}, header, http.StatusTooManyRequests)
+		}
+	}
+
+	gt, tgr, err := srv.ValidationTokenRequest(r)
+	if err == nil {
+		event.Scope = tgr.Scope
+		var ti oauth2.TokenInfo
+		if ti, err = srv.GetAccessToken(ctx, gt, tgr); err == nil {
+			event.Type = AuditTokenIssued
+			if gt == oauth2.Refreshing {
+				event.Type = AuditTokenRefreshed
+			}
+			event.Scope = ti.GetScope()
+			c.audit(ctx, event)
+			if c.limiter != nil {
+				if err = c.limiter.resetFailures(ctx, event.ClientID); err != nil {
+					log.Print("oauth2 reset failures error:", err.Error())
+				}
+			}
+			return writeToResponse(w, srv.GetTokenData(ti), nil)
+		}
+	}
+
+	event.Error = err.Error()
+	c.audit(ctx, event)
+	if c.limiter != nil && isAuthFailure(err) {
+		if lErr := c.limiter.recordFailure(ctx, event.ClientID, event.IP); lErr != nil {
+			log.Print("oauth2 record failure error:", lErr.Error())
+		}
+	}
+	return c.tokenError(w, err)
+}
+
+// clientIP 获取请求方IP，未配置限流时也会写入审计事件
+func (c *ClientCredentials) clientIP(r *http.Request) string {
+	if c.RateLimit != nil && c.RateLimit.IPExtractor != nil {
+		return c.RateLimit.IPExtractor(r)
+	}
+	return httpx.GetRemoteAddr(r)
+}
+
+func (c *ClientCredentials) tokenError(w http.ResponseWriter, err error) error {
+	data, statusCode, header := c.GetServer().GetErrorData(err)
+	return writeToResponse(w, data, header, statusCode)
+}
+
+// isAuthFailure 是否为凭证类错误，只有这类错误计入失败锁定
+func isAuthFailure(err error) bool {
+	return err == errors.ErrInvalidClient || err == errors.ErrUnauthorizedClient ||
+		err == errors.ErrInvalidGrant || err == errors.ErrAccessDenied
+}
+
+// RevokeToken 吊销access token，并记录审计事件
+// 审计事件的IP从ctx中读取，需要调用方通过 ContextWithClientIP 设置
+// 注意：JWT方式生成的token为无状态校验，吊销只会从存储中删除
+func (c *ClientCredentials) RevokeToken(ctx context.Context, token string) error {
+	tokenInfo, err := c.getTokenInfo(ctx, token)
+	if err != nil {
+		return err
+	}
+	token, _ = jwtRequest.AuthorizationHeaderExtractor.Filter(token)
+	if err = c.GetServer().Manager.RemoveAccessToken(ctx, token); err != nil {
+		return err
+	}
+	c.audit(ctx, &AuditEvent{
+		Type:     AuditTokenRevoked,
+		ClientID: tokenInfo.GetClientID(),
+		Scope:    tokenInfo.GetScope(),
+	})
+	return nil
+}
+
+// RevokeTokenRequest 吊销http请求中的access token，审计事件带上请求方IP
+func (c *ClientCredentials) RevokeTokenRequest(r *http.Request, token string) error {
+	return c.RevokeToken(ContextWithClientIP(r.Context(), c.clientIP(r)), token)
+}
+
 func (c *ClientCredentials) getTokenInfo(ctx context.Context, token string) (oauth2.TokenInfo, error) {
 	srv := c.GetServer()
 
@@ -1,8 +1,10 @@
 package oauth2_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/alicebob/miniredis/v2"
 	goOauth2 "github.com/go-oauth2/oauth2/v4"
 	"github.com/go-oauth2/oauth2/v4/generates"
 	"github.com/go-oauth2/oauth2/v4/models"
@@ -10,9 +12,13 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/magic-lib/go-plat-utils/utils/httputil/param"
 	"github.com/magic-lib/go-servicekit/oauth2"
+	"github.com/zeromicro/go-zero/core/stores/redis"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 )
 
 func TestClientCredentials(t *testing.T) {
@@ -66,3 +72,160 @@ func TestClientCredentials(t *testing.T) {
 	}()
 	select {}
 }
+
+func newTestClientCredentials(t *testing.T, rateLimit *oauth2.RateLimitConfig,
+	events *[]*oauth2.AuditEvent) *oauth2.ClientCredentials {
+	clientStore := store.NewClientStore()
+	_ = clientStore.Set("client1", &models.Client{ID: "client1", Secret: "secret1"})
+	_ = clientStore.Set("client2", &models.Client{ID: "client2", Secret: "secret2"})
+
+	cTemp, err := oauth2.NewClientCredentials(&oauth2.ClientCredentials{
+		ClientStorage: clientStore,
+		RateLimit:     rateLimit,
+		AuditSink: oauth2.AuditSinkFunc(func(_ context.Context, event *oauth2.AuditEvent) {
+			*events = append(*events, event)
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cTemp
+}
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Redis) {
+	mr := miniredis.RunT(t)
+	return mr, redis.MustNewRedis(redis.RedisConf{Host: mr.Addr(), Type: redis.NodeType})
+}
+
+// checkToken 请求token接口，校验返回的状态码和Retry-After
+func checkToken(t *testing.T, cTemp *oauth2.ClientCredentials, clientID, secret, ip string,
+	wantCode int, wantRetryAfter string) *httptest.ResponseRecorder {
+	t.Helper()
+	handler, path := cTemp.GetHttpServerHandler()
+	query := url.Values{}
+	query.Set("grant_type", "client_credentials")
+	query.Set("client_id", clientID)
+	query.Set("client_secret", secret)
+	req := httptest.NewRequest(http.MethodGet, path+"?"+query.Encode(), http.NoBody)
+	req.RemoteAddr = ip
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+
+	if wantCode > 0 && resp.Code != wantCode {
+		t.Errorf("%s from %s: code = %d, want %d, body: %s", clientID, ip, resp.Code, wantCode, resp.Body.String())
+	}
+	if got := resp.Header().Get("Retry-After"); got != wantRetryAfter {
+		t.Errorf("%s from %s: Retry-After = %q, want %q", clientID, ip, got, wantRetryAfter)
+	}
+	return resp
+}
+
+func TestClientCredentials_RateLimit(t *testing.T) {
+	_, rds := newTestRedis(t)
+	var events []*oauth2.AuditEvent
+	cTemp := newTestClientCredentials(t, &oauth2.RateLimitConfig{
+		Store:       rds,
+		Period:      60,
+		ClientQuota: 2,
+	}, &events)
+
+	checkToken(t, cTemp, "client1", "secret1", "1.2.3.4", http.StatusOK, "")
+	checkToken(t, cTemp, "client1", "secret1", "1.2.3.4", http.StatusOK, "")
+	checkToken(t, cTemp, "client1", "secret1", "1.2.3.4", http.StatusTooManyRequests, "60")
+	// 其它clientId不受影响
+	checkToken(t, cTemp, "client2", "secret2", "1.2.3.4", http.StatusOK, "")
+}
+
+func TestClientCredentials_IPRateLimit(t *testing.T) {
+	_, rds := newTestRedis(t)
+	var events []*oauth2.AuditEvent
+	cTemp := newTestClientCredentials(t, &oauth2.RateLimitConfig{
+		Store:   rds,
+		Period:  30,
+		IPQuota: 1,
+	}, &events)
+
+	checkToken(t, cTemp, "client1", "secret1", "1.2.3.4", http.StatusOK, "")
+	checkToken(t, cTemp, "client2", "secret2", "1.2.3.4", http.StatusTooManyRequests, "30")
+	checkToken(t, cTemp, "client2", "secret2", "5.6.7.8", http.StatusOK, "")
+}
+
+func TestClientCredentials_ClientLockout(t *testing.T) {
+	mr, rds := newTestRedis(t)
+	var events []*oauth2.AuditEvent
+	cTemp := newTestClientCredentials(t, &oauth2.RateLimitConfig{
+		Store:          rds,
+		MaxFailures:    2,
+		LockoutSeconds: 120,
+	}, &events)
+
+	checkToken(t, cTemp, "client1", "bad", "1.2.3.4", http.StatusUnauthorized, "")
+	checkToken(t, cTemp, "client1", "bad", "1.2.3.4", http.StatusUnauthorized, "")
+	// 锁定后正确的密码也被拒绝，其它clientId不受影响
+	checkToken(t, cTemp, "client1", "secret1", "1.2.3.4", http.StatusTooManyRequests, "120")
+	checkToken(t, cTemp, "client2", "secret2", "1.2.3.4", http.StatusOK, "")
+
+	// 锁定到期后解锁
+	mr.FastForward(121 * time.Second)
+	checkToken(t, cTemp, "client1", "secret1", "1.2.3.4", http.StatusOK, "")
+}
+
+func TestClientCredentials_IPLockout(t *testing.T) {
+	mr, rds := newTestRedis(t)
+	var events []*oauth2.AuditEvent
+	cTemp := newTestClientCredentials(t, &oauth2.RateLimitConfig{
+		Store:          rds,
+		IPMaxFailures:  2,
+		LockoutSeconds: 60,
+	}, &events)
+
+	// 同一IP轮换clientId尝试
+	checkToken(t, cTemp, "client1", "bad", "1.2.3.4", http.StatusUnauthorized, "")
+	checkToken(t, cTemp, "client2", "bad", "1.2.3.4", http.StatusUnauthorized, "")
+	checkToken(t, cTemp, "client1", "secret1", "1.2.3.4", http.StatusTooManyRequests, "60")
+	checkToken(t, cTemp, "client1", "secret1", "5.6.7.8", http.StatusOK, "")
+
+	mr.FastForward(61 * time.Second)
+	checkToken(t, cTemp, "client1", "secret1", "1.2.3.4", http.StatusOK, "")
+}
+
+func TestClientCredentials_Audit(t *testing.T) {
+	var events []*oauth2.AuditEvent
+	// 未配置限流时也需要记录IP
+	cTemp := newTestClientCredentials(t, nil, &events)
+
+	resp := checkToken(t, cTemp, "client1", "secret1", "1.2.3.4", http.StatusOK, "")
+	checkToken(t, cTemp, "client1", "bad", "5.6.7.8", http.StatusUnauthorized, "")
+
+	var data map[string]any
+	if err := json.Unmarshal(resp.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	token, _ := data["access_token"].(string)
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/revoke", http.NoBody)
+	req.RemoteAddr = "9.9.9.9"
+	if err := cTemp.RevokeTokenRequest(req, token); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []oauth2.AuditEvent{
+		{Type: oauth2.AuditTokenIssued, ClientID: "client1", IP: "1.2.3.4"},
+		{Type: oauth2.AuditAuthFailed, ClientID: "client1", IP: "5.6.7.8"},
+		{Type: oauth2.AuditTokenRevoked, ClientID: "client1", IP: "9.9.9.9"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d audit events, want %d", len(events), len(want))
+	}
+	for i, w := range want {
+		got := events[i]
+		if got.Type != w.Type || got.ClientID != w.ClientID || got.IP != w.IP {
+			t.Errorf("event %d = %+v, want type %s, client %s, ip %s", i, got, w.Type, w.ClientID, w.IP)
+		}
+		if got.Time.IsZero() {
+			t.Errorf("event %d has no time", i)
+		}
+	}
+	if events[1].Error == "" {
+		t.Errorf("failed event has no error")
+	}
+}
@@ -0,0 +1,191 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/zeromicro/go-zero/core/limit"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+const (
+	defaultLimitKeyPrefix = "oauth2:limit"
+	defaultLimitPeriod    = 60
+	defaultLockoutSeconds = 300
+)
+
+var (
+	errTooManyRequests = fmt.Errorf("too_many_requests")
+	errClientLocked    = fmt.Errorf("client_locked")
+	errIPLocked        = fmt.Errorf("ip_locked")
+)
+
+const (
+	lockKindClient = "client"
+	lockKindIP     = "ip"
+)
+
+// RateLimitConfig token接口的限流与失败锁定配置，计数基于 go-zero core/limit 存储在redis中
+type RateLimitConfig struct {
+	Store          *redis.Redis                 // 计数存储，必传
+	KeyPrefix      string                       // redis key 前缀，默认 oauth2:limit
+	Period         int                          // 限流窗口（秒），默认60
+	ClientQuota    int                          // 每个clientId在窗口内允许的请求数，0表示不限制
+	IPQuota        int                          // 每个IP在窗口内允许的请求数，0表示不限制
+	MaxFailures    int                          // 同一clientId窗口内认证失败多少次后锁定，0表示不锁定
+	IPMaxFailures  int                          // 同一IP窗口内认证失败多少次后锁定该IP，0表示不锁定
+	LockoutSeconds int                          // 锁定时长（秒），默认300
+	IPExtractor    func(r *http.Request) string // 获取客户端IP，默认使用 httpx.GetRemoteAddr
+}
+
+type tokenLimiter struct {
+	cfg         *RateLimitConfig
+	clientLimit *limit.PeriodLimit
+	ipLimit     *limit.PeriodLimit
+}
+
+func newTokenLimiter(cfg *RateLimitConfig) (*tokenLimiter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.Store == nil {
+		return nil, fmt.Errorf("RateLimit.Store nil: redis store is required")
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaultLimitKeyPrefix
+	}
+	if cfg.Period <= 0 {
+		cfg.Period = defaultLimitPeriod
+	}
+	if cfg.LockoutSeconds <= 0 {
+		cfg.LockoutSeconds = defaultLockoutSeconds
+	}
+	if cfg.IPExtractor == nil {
+		cfg.IPExtractor = httpx.GetRemoteAddr
+	}
+
+	l := &tokenLimiter{cfg: cfg}
+	if cfg.ClientQuota > 0 {
+		l.clientLimit = limit.NewPeriodLimit(cfg.Period, cfg.ClientQuota, cfg.Store, cfg.KeyPrefix+":client:")
+	}
+	if cfg.IPQuota > 0 {
+		l.ipLimit = limit.NewPeriodLimit(cfg.Period, cfg.IPQuota, cfg.Store, cfg.KeyPrefix+":ip:")
+	}
+	return l, nil
+}
+
+func (l *tokenLimiter) lockKey(kind, id string) string {
+	return l.cfg.KeyPrefix + ":lock:" + kind + ":" + id
+}
+
+func (l *tokenLimiter) failKey(kind, id string) string {
+	return l.cfg.KeyPrefix + ":fail:" + kind + ":" + id
+}
+
+// maxFailures 不同维度的失败锁定阈值
+func (l *tokenLimiter) maxFailures(kind string) int {
+	if kind == lockKindIP {
+		return l.cfg.IPMaxFailures
+	}
+	return l.cfg.MaxFailures
+}
+
+// allow 判断本次请求是否允许进入token颁发流程
+func (l *tokenLimiter) allow(ctx context.Context, clientID, ip string) error {
+	if err := l.checkLocked(ctx, lockKindClient, clientID, errClientLocked); err != nil {
+		return err
+	}
+	if err := l.checkLocked(ctx, lockKindIP, ip, errIPLocked); err != nil {
+		return err
+	}
+	if l.ipLimit != nil && ip != "" {
+		if err := takeLimit(ctx, l.ipLimit, ip); err != nil {
+			return err
+		}
+	}
+	if l.clientLimit != nil && clientID != "" {
+		if err := takeLimit(ctx, l.clientLimit, clientID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *tokenLimiter) checkLocked(ctx context.Context, kind, id string, lockedErr error) error {
+	if id == "" || l.maxFailures(kind) <= 0 {
+		return nil
+	}
+	locked, err := l.cfg.Store.ExistsCtx(ctx, l.lockKey(kind, id))
+	if err != nil {
+		return err
+	}
+	if locked {
+		return lockedErr
+	}
+	return nil
+}
+
+func takeLimit(ctx context.Context, pl *limit.PeriodLimit, key string) error {
+	code, err := pl.TakeCtx(ctx, key)
+	if err != nil {
+		return err
+	}
+	if code == limit.OverQuota {
+		return errTooManyRequests
+	}
+	return nil
+}
+
+// recordFailure 记录一次认证失败，clientId或IP达到各自阈值后被锁定
+func (l *tokenLimiter) recordFailure(ctx context.Context, clientID, ip string) error {
+	if err := l.recordKindFailure(ctx, lockKindClient, clientID); err != nil {
+		return err
+	}
+	return l.recordKindFailure(ctx, lockKindIP, ip)
+}
+
+func (l *tokenLimiter) recordKindFailure(ctx context.Context, kind, id string) error {
+	maxFailures := l.maxFailures(kind)
+	if id == "" || maxFailures <= 0 {
+		return nil
+	}
+	key := l.failKey(kind, id)
+	count, err := l.cfg.Store.IncrCtx(ctx, key)
+	if err != nil {
+		return err
+	}
+	if count == 1 {
+		if err = l.cfg.Store.ExpireCtx(ctx, key, l.cfg.Period); err != nil {
+			return err
+		}
+	}
+	if count < int64(maxFailures) {
+		return nil
+	}
+	if err = l.cfg.Store.SetexCtx(ctx, l.lockKey(kind, id), strconv.FormatInt(count, 10), l.cfg.LockoutSeconds); err != nil {
+		return err
+	}
+	_, err = l.cfg.Store.DelCtx(ctx, key)
+	return err
+}
+
+// resetFailures 认证成功后清空该clientId的失败计数
+// IP的失败计数不清空，避免持有一个有效凭证的请求方借此继续尝试其它clientId
+func (l *tokenLimiter) resetFailures(ctx context.Context, clientID string) error {
+	if clientID == "" || l.cfg.MaxFailures <= 0 {
+		return nil
+	}
+	_, err := l.cfg.Store.DelCtx(ctx, l.failKey(lockKindClient, clientID))
+	return err
+}
+
+// retryAfter 被拒绝时建议客户端等待的秒数
+func (l *tokenLimiter) retryAfter(err error) int {
+	if err == errClientLocked || err == errIPLocked {
+		return l.cfg.LockoutSeconds
+	}
+	return l.cfg.Period
+}
@@ -5,22 +5,40 @@ import (
     "embed"
     "fmt"
     "io/fs"
+{{- if .Templates}}
+    htmltemplate "html/template"
+    "io"
+    "sync"
+    "text/template"
+{{- end}}
+{{- if .Http}}
+    "net/http"
+    "strconv"
+    "strings"
+{{- end}}
     "github.com/samber/lo"
     "github.com/xuri/excelize/v2"
+{{- if .Rest}}
+    "github.com/zeromicro/go-zero/rest"
+{{- end}}
+{{- range .Imports}}
+    {{.Alias}} "{{.Path}}"
+{{- end}}
 )
 
-{{range .Files}}//go:embed {{.EmbedPath}}
+{{range .Files}}//go:embed {{.EmbedPath}}{{if .GzipPath}} {{.GzipPath}}{{end}}{{if .BrotliPath}} {{.BrotliPath}}{{end}}
 var {{.EmbedFS}} embed.FS
 {{end}}
 
 const (
-{{range .Files}}	{{.ConstName}} = "{{.EmbedPath}}"
+{{range .Files}}	{{.ConstName}} = "{{.AssetPath}}"
 {{end}})
 
 type AssetFile struct {
     Key      string // 文件唯一标识
 	FileName string // 文件名（含后缀）
-	FullName string // 文件全路径
+	FullName string // 文件全路径，相对于资源目录
+	EmbedName string // 文件在 EmbedFS 中的路径
 	BaseName string // 不含后缀的文件名
 	Ext      string // 文件后缀
 	Dir      string // 目录路径
@@ -28,12 +46,20 @@ type AssetFile struct {
 	Content  []byte // 文件内容
 	EmbedFS  embed.FS
 	ModTime  string // 修改时间
+	Hash       string // 内容的 sha256
+	ETag       string // 用于 If-None-Match
+	HashedName string // 带内容摘要的文件路径，内容变化后路径也会变化，可以长期缓存
+	MimeType   string // Content-Type
+	GzipName   string // 预压缩的 gzip 文件，为空表示没有
+	BrotliName string // 预压缩的 brotli 文件，为空表示没有
+	Kind       string // 文件类型：html-template、text-template、excel 或者 MIME 的主类型，比如 text、image
 }
 
 // AssetDir represents an embedded directory, allowing fs.Sub to read files within it.
 type AssetDir struct {
 	Key     string   // 目录唯一标识
-	DirName string   // 目录名
+	DirName string   // 目录路径，相对于资源目录
+	EmbedName string // 目录在 EmbedFS 中的路径
 	EmbedFS embed.FS // 整个目录的 embed.FS
 }
 
@@ -48,6 +74,7 @@ var allFiles = map[string]*AssetFile{
         Key:      "{{.ConstName}}",
         FileName: "{{.Name}}",
         FullName: {{.ConstName}},
+        EmbedName: "{{.EmbedPath}}",
         BaseName: "{{.BaseName}}",
         Ext:      "{{.Ext}}",
         Dir:      "{{.Dir}}",
@@ -55,13 +82,26 @@ var allFiles = map[string]*AssetFile{
         Content:  nil,
         EmbedFS:  {{.EmbedFS}},
         ModTime:  "{{.ModTime}}",
+        Hash:       "{{.Hash}}",
+        ETag:       `"{{slice .Hash 0 16}}"`,
+        HashedName: "{{.HashedName}}",
+        MimeType:   "{{.MimeType}}",
+        GzipName:   "{{.GzipPath}}",
+        BrotliName: "{{.BrotliPath}}",
+        Kind:       "{{.Kind}}",
     },
 {{end}}{{end}}}
 
+// hashedFiles 带内容摘要的文件路径到文件的映射
+var hashedFiles = map[string]string{
+{{range .Files}}{{if not .IsDirWild}}	"{{.HashedName}}": {{.ConstName}},
+{{end}}{{end}}}
+
 var allDirs = map[string]*AssetDir{
 {{range .Files}}{{if .IsDirWild}}	{{.ConstName}}: {
 		Key:     "{{.ConstName}}",
 		DirName: "{{.Dir}}",
+		EmbedName: "{{.EmbedDir}}",
 		EmbedFS: {{.EmbedFS}},
 	},
 {{end}}{{end}}}
@@ -71,7 +111,7 @@ func LoadAssetFile(fileName string) (*AssetFile, error) {
 		return data, nil
 	}
 	if f, ok := allFiles[fileName]; ok {
-		data, err := f.EmbedFS.ReadFile(fileName)
+		data, err := f.EmbedFS.ReadFile(f.EmbedName)
 		if err != nil {
 			return nil, err
 		}
@@ -82,6 +122,14 @@ func LoadAssetFile(fileName string) (*AssetFile, error) {
 	return nil, fmt.Errorf("file %s not found", fileName)
 }
 
+// AssetHashedName 返回带内容摘要的文件路径，用于页面中引用静态文件，文件不存在时原样返回
+func AssetHashedName(fileName string) string {
+	if f, ok := allFiles[fileName]; ok {
+		return f.HashedName
+	}
+	return fileName
+}
+
 func LoadAllAssetFiles() []*AssetFile {
 	return lo.MapToSlice(allFiles, func(fileName string, file *AssetFile) *AssetFile {
 		return file
@@ -95,7 +143,7 @@ func LoadAssetDir(dirName string) (fs.FS, error) {
 		return dfs, nil
 	}
 	if d, ok := allDirs[dirName]; ok {
-		subFS, err := fs.Sub(d.EmbedFS, d.DirName)
+		subFS, err := fs.Sub(d.EmbedFS, d.EmbedName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create sub filesystem for dir %s: %w", dirName, err)
 		}
@@ -104,7 +152,197 @@ func LoadAssetDir(dirName string) (fs.FS, error) {
 	}
 	return nil, fmt.Errorf("dir %s not found", dirName)
 }
+{{if .Templates}}
+// TemplateFuncs 模板中使用的自定义函数，需要在 ParseTemplates 或者第一次渲染之前设置
+var TemplateFuncs = map[string]any{}
+
+// executor text/template 和 html/template 共同的渲染方法
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+var (
+	templatesOnce   sync.Once
+	templatesErr    error
+	parsedTemplates = map[string]executor{}
+)
+
+// ParseTemplates 解析全部模板，建议在启动时调用，模板有误或者缺少自定义函数时返回错误
+func ParseTemplates() error {
+	templatesOnce.Do(func() {
+		for _, one := range []struct {
+			name string
+			html bool
+		}{
+{{range .Templates}}			{ {{- .ConstName}}, {{eq .Kind "html-template"}}},
+{{end}}		} {
+			if templatesErr = parseTemplate(one.name, one.html); templatesErr != nil {
+				return
+			}
+		}
+	})
+	return templatesErr
+}
+
+func parseTemplate(fileName string, html bool) error {
+	f, err := LoadAssetFile(fileName)
+	if err != nil {
+		return err
+	}
+	if html {
+		t, err := htmltemplate.New(fileName).Funcs(TemplateFuncs).Parse(string(f.Content))
+		if err != nil {
+			return err
+		}
+		parsedTemplates[fileName] = t
+		return nil
+	}
+	t, err := template.New(fileName).Funcs(TemplateFuncs).Parse(string(f.Content))
+	if err != nil {
+		return err
+	}
+	parsedTemplates[fileName] = t
+	return nil
+}
+
+func renderTemplate(w io.Writer, fileName string, data any) error {
+	if err := ParseTemplates(); err != nil {
+		return err
+	}
+	return parsedTemplates[fileName].Execute(w, data)
+}
+{{range .Templates}}
+// {{.RenderFunc}} 渲染 {{.AssetPath}}
+func {{.RenderFunc}}(w io.Writer, data {{.DataType}}) error {
+	return renderTemplate(w, {{.ConstName}}, data)
+}
+{{end}}{{end}}{{if .Http}}
+// lookupAsset 根据请求路径查找文件，支持原始路径和带内容摘要的路径
+func lookupAsset(name string) (*AssetFile, bool) {
+	if f, ok := allFiles[name]; ok {
+		return f, false
+	}
+	if key, ok := hashedFiles[name]; ok {
+		return allFiles[key], true
+	}
+	return nil, false
+}
 
+// etagMatch 判断 If-None-Match 是否包含 etag，支持 * 和弱校验
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, one := range strings.Split(ifNoneMatch, ",") {
+		one = strings.TrimSpace(one)
+		if one == "*" || strings.TrimPrefix(one, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptEncoding 判断客户端是否接受该压缩格式
+func acceptEncoding(r *http.Request, encoding string) bool {
+	for _, one := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, q, _ := strings.Cut(strings.TrimSpace(one), ";")
+		if strings.TrimSpace(name) == encoding {
+			return strings.TrimSpace(strings.ReplaceAll(q, " ", "")) != "q=0"
+		}
+	}
+	return false
+}
+
+// serveAsset 输出文件内容，hashed 为 true 时可以长期缓存
+func serveAsset(w http.ResponseWriter, r *http.Request, f *AssetFile, hashed bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	header := w.Header()
+	header.Set("ETag", f.ETag)
+	if hashed {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		header.Set("Cache-Control", "no-cache")
+	}
+	if f.GzipName != "" || f.BrotliName != "" {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatch(inm, f.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	name := f.EmbedName
+	switch {
+	case f.BrotliName != "" && acceptEncoding(r, "br"):
+		name = f.BrotliName
+		header.Set("Content-Encoding", "br")
+	case f.GzipName != "" && acceptEncoding(r, "gzip"):
+		name = f.GzipName
+		header.Set("Content-Encoding", "gzip")
+	}
+	data, err := f.EmbedFS.ReadFile(name)
+	if err != nil {
+		header.Del("Content-Encoding")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	header.Set("Content-Type", f.MimeType)
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+// ServeHTTP 输出当前文件，支持 If-None-Match 和预压缩文件
+func (file *AssetFile) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveAsset(w, r, file, false)
+}
+
+// AssetHandler 静态文件服务，请求路径去掉 prefix 后为文件路径或者带内容摘要的文件路径
+func AssetHandler(prefix string) http.Handler {
+	prefix = "/" + strings.Trim(prefix, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		f, hashed := lookupAsset(name)
+		if f == nil {
+			http.NotFound(w, r)
+			return
+		}
+		serveAsset(w, r, f, hashed)
+	})
+}
+{{end}}{{if .Rest}}
+// AssetRoutes 生成 go-zero rest 路由，每个文件注册原始路径和带内容摘要的路径，比如: server.AddRoutes(AssetRoutes("/static"))
+func AssetRoutes(prefix string) []rest.Route {
+	prefix = strings.TrimRight("/"+strings.Trim(prefix, "/"), "/")
+	var routes []rest.Route
+	for _, f := range allFiles {
+		file := f
+		for _, one := range []struct {
+			name   string
+			hashed bool
+		}{
+			{file.FullName, false},
+			{file.HashedName, true},
+		} {
+			hashed := one.hashed
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				serveAsset(w, r, file, hashed)
+			}
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				routes = append(routes, rest.Route{
+					Method:  method,
+					Path:    prefix + "/" + one.name,
+					Handler: handler,
+				})
+			}
+		}
+	}
+	return routes
+}
+{{end}}
 type ExcelTmplData struct {
 	NewSheetName  string // 新建文件sheet名称
 	TmplSheetName string // 模版sheet名称
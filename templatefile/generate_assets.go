@@ -2,33 +2,86 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"go/format"
+	htmltemplate "html/template"
+	"io"
 	"io/fs"
+	"mime"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 	"text/template"
 	"time"
 	"unicode"
+
+	"github.com/andybalholm/brotli"
 )
 
 //go:embed assets.tpl
 var templateFS embed.FS
 
+// options 命令行参数
+type options struct {
+	dirs        []string
+	goOut       string
+	goFile      string
+	pkg         string
+	includes    []string
+	excludes    []string
+	gzip        bool
+	brotli      bool
+	compressDir string
+	http        bool
+	rest        bool
+	templates   []string          // 需要预解析的模板文件
+	tmplFuncs   []string          // 模板中使用的自定义函数名
+	tmplData    map[string]string // 模板文件 -> 渲染数据的类型
+	manifest    string
+}
+
 func main() {
 	var (
-		dir    string
-		goOut  string
-		goFile string
-		pkg    string
+		dir      string
+		goOut    string
+		goFile   string
+		pkg      string
+		include  string
+		exclude  string
+		watch    bool
+		interval time.Duration
+		tmpl     string
+		funcs    string
+		tmplData string
+		opts     options
 	)
 	flag.StringVar(&dir, "dir", "", "Input directory(s) to scan for files, comma-separated (required)")
 	flag.StringVar(&goOut, "go_out", "", "Output directory for generated Go file (default: first input directory)")
 	flag.StringVar(&goFile, "go_file", "", "Output Go file name (default: assets.go)")
 	flag.StringVar(&pkg, "pkg", "", "Package name for generated Go file (default: basename of go_out or first input directory)")
+	flag.StringVar(&include, "include", "", "Glob patterns of files to embed, comma-separated, e.g. \"*.html,js/*\" (default: all files)")
+	flag.StringVar(&exclude, "exclude", "~*", "Glob patterns of files or directories to skip, comma-separated")
+	flag.BoolVar(&opts.gzip, "gzip", false, "Generate precompressed gzip variants")
+	flag.BoolVar(&opts.brotli, "brotli", false, "Generate precompressed brotli variants")
+	flag.StringVar(&opts.compressDir, "compress_dir", "assets_compressed", "Directory under go_out for precompressed variants, recreated on every run with -gzip or -brotli")
+	flag.BoolVar(&opts.http, "http", false, "Generate an http.Handler serving the embedded files")
+	flag.BoolVar(&opts.rest, "rest", false, "Generate go-zero rest routes for the embedded files (implies -http)")
+	flag.StringVar(&tmpl, "tmpl", "", "Glob patterns of text/html templates to pre-parse and generate Render functions for, comma-separated, e.g. \"*.tmpl,*.gohtml\"")
+	flag.StringVar(&funcs, "tmpl_funcs", "", "Names of custom template functions, comma-separated, used when checking template syntax")
+	flag.StringVar(&tmplData, "tmpl_data", "", "Data types of Render functions, comma-separated, e.g. \"mail/invoice.html.tmpl=*github.com/foo/billing.Invoice\" (default: any)")
+	flag.StringVar(&opts.manifest, "manifest", "assets.manifest.json", "Manifest file under go_out listing size, hash and type of each asset, \"-\" to disable")
+	flag.BoolVar(&watch, "watch", false, "Watch input directories and regenerate on changes")
+	flag.DurationVar(&interval, "interval", time.Second, "Polling interval of -watch")
 	flag.Parse()
 
 	// Backward compatibility: if first non-flag argument is provided, treat as dir
@@ -48,61 +101,213 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Split comma-separated directories and tpl_paths
-	dirs := splitCommaSeparated(dir)
+	// Split comma-separated directories and glob patterns
+	opts.dirs = splitCommaSeparated(dir)
+	opts.goOut = goOut
+	opts.goFile = goFile
+	opts.pkg = pkg
+	opts.includes = splitCommaSeparated(include)
+	opts.excludes = splitCommaSeparated(exclude)
+	if opts.rest {
+		opts.http = true
+	}
+	opts.templates = splitCommaSeparated(tmpl)
+	opts.tmplFuncs = splitCommaSeparated(funcs)
+	opts.tmplData = make(map[string]string)
+	for _, one := range splitCommaSeparated(tmplData) {
+		name, typ, ok := strings.Cut(one, "=")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: invalid -tmpl_data %q, want file=type\n", one)
+			os.Exit(1)
+		}
+		opts.tmplData[strings.TrimSpace(name)] = strings.TrimSpace(typ)
+	}
+	if err := opts.normalize(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
+	if err := generate(&opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if !watch {
+			os.Exit(1)
+		}
+	}
+	if watch {
+		watchAndGenerate(&opts, interval)
+	}
+}
+
+// normalize 检查输入目录，确定输出目录、文件名和包名
+func (opts *options) normalize() error {
 	// Ensure all input directories exist
-	for i, d := range dirs {
+	for i, d := range opts.dirs {
 		info, err := os.Stat(d)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading directory %s: %v\n", d, err)
-			os.Exit(1)
+			return fmt.Errorf("reading directory %s: %w", d, err)
 		}
 		if !info.IsDir() {
-			fmt.Fprintf(os.Stderr, "%s is not a directory\n", d)
-			os.Exit(1)
+			return fmt.Errorf("%s is not a directory", d)
 		}
 		// Normalize path
 		absPath, err := filepath.Abs(d)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting absolute path for %s: %v\n", d, err)
-			os.Exit(1)
+			return fmt.Errorf("getting absolute path for %s: %w", d, err)
 		}
-		dirs[i] = absPath
+		opts.dirs[i] = absPath
 	}
 
 	// Determine output file name early (used to skip the generated file itself during walk)
-	outputFileName := goFile
-	if outputFileName == "" {
-		outputFileName = "assets.go"
+	if opts.goFile == "" {
+		opts.goFile = "assets.go"
+	}
+
+	// Determine package name
+	if opts.pkg == "" {
+		// Use basename of go_out if provided, otherwise basename of first input directory
+		if opts.goOut != "" {
+			absOut, err := filepath.Abs(opts.goOut)
+			if err != nil {
+				return err
+			}
+			opts.pkg = filepath.Base(absOut)
+		} else {
+			opts.pkg = filepath.Base(opts.dirs[0])
+		}
+	}
+	if !isValidIdentifier(opts.pkg) {
+		return fmt.Errorf("invalid package name: %s", opts.pkg)
 	}
 
-	// Collect files from all directories
-	type collectedFile struct {
-		RelPath   string // Relative path within its source directory
-		SourceDir string // Absolute path of source directory
-		IsDirWild bool   // True if this entry represents a directory wildcard (e.g. "js/*")
+	// Determine output directory, default to first input directory
+	outputDir := opts.dirs[0]
+	if opts.goOut != "" {
+		outputDir = opts.goOut
+	}
+	// Normalize outputDir to absolute path for Rel() computation
+	if absOutputDir, err := filepath.Abs(outputDir); err == nil {
+		outputDir = absOutputDir
+	}
+	opts.goOut = outputDir
+	// 预压缩目录每次生成时会被删除重建，只能是 go_out 下的子目录
+	if filepath.IsAbs(opts.compressDir) {
+		return fmt.Errorf("compress_dir %s must be relative to go_out", opts.compressDir)
+	}
+	if rel, err := filepath.Rel(opts.goOut, opts.compressPath()); err != nil || rel == "." ||
+		rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("compress_dir %s must be a directory under go_out", opts.compressDir)
+	}
+	for _, pattern := range append(append(append([]string{}, opts.includes...), opts.excludes...), opts.templates...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// compressPath 预压缩文件所在目录的绝对路径
+func (opts *options) compressPath() string {
+	return filepath.Join(opts.goOut, filepath.FromSlash(opts.compressDir))
+}
+
+// matchGlob 不含 / 的模式匹配文件名，否则匹配相对路径
+func matchGlob(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		target := rel
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(rel)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// skipPath 判断扫描时是否跳过该路径，生成的 Go 文件和预压缩目录总是跳过
+func (opts *options) skipPath(sourceDir, fullPath string, isDir bool) (bool, error) {
+	if isDir {
+		if fullPath == opts.compressPath() {
+			return true, nil
+		}
+		if fullPath == sourceDir {
+			return false, nil
+		}
+	} else if filepath.Dir(fullPath) == opts.goOut &&
+		(filepath.Base(fullPath) == opts.goFile || filepath.Base(fullPath) == opts.manifest) {
+		// Skip the generated Go file and manifest itself
+		return true, nil
+	}
+	rel, err := filepath.Rel(sourceDir, fullPath)
+	if err != nil {
+		return false, err
 	}
+	if matchGlob(opts.excludes, rel) {
+		return true, nil
+	}
+	if !isDir && len(opts.includes) > 0 && !matchGlob(opts.includes, rel) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// Collect files from all directories
+type collectedFile struct {
+	RelPath   string // Relative path within its source directory
+	SourceDir string // Absolute path of source directory
+	IsDirWild bool   // True if this entry represents a directory wildcard (e.g. "js/*")
+}
+
+// Prepare file infos
+type fileInfo struct {
+	EmbedFS    string
+	EmbedPath  string // 相对于生成文件所在目录的路径，用于 go:embed 和读取 embed.FS
+	AssetPath  string // 相对于输入目录的路径，用于常量、请求路径和清单
+	EmbedDir   string // 目录通配项在 embed.FS 中的目录
+	ConstName  string
+	Name       string    // 文件名（含后缀）
+	BaseName   string    // 不含后缀的文件名
+	Ext        string    // 文件后缀（如 .xlsx）
+	Dir        string    // AssetPath 的目录路径
+	Size       int64     // 文件大小
+	Content    []byte    // 文件内容
+	ModTime    time.Time // 修改时间
+	IsDirWild  bool      // True if this entry represents a directory wildcard (e.g. "js/*")
+	Hash       string    // 内容的 sha256
+	HashedName string    // 带内容摘要的文件路径，用于缓存
+	MimeType   string    // Content-Type
+	GzipPath   string    // 预压缩的 gzip 文件
+	BrotliPath string    // 预压缩的 brotli 文件
+	GzipSize   int       // 预压缩的 gzip 文件大小
+	BrotliSize int       // 预压缩的 brotli 文件大小
+	Kind       string    // 检测到的文件类型：html-template、text-template、excel 或者 MIME 的主类型
+	RenderFunc string    // 模板的渲染方法名
+	DataType   string    // 模板渲染数据的类型
+}
+
+func collectFiles(opts *options) ([]collectedFile, error) {
 	var allFiles []collectedFile
 
 	// Track directories that contain files, to add wildcard entries
 	dirsWithFiles := make(map[string]string) // key: relative dir path, value: sourceDir
 
-	for _, sourceDir := range dirs {
+	for _, sourceDir := range opts.dirs {
 		err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
-			if d.IsDir() {
-				return nil
+			skip, err := opts.skipPath(sourceDir, path, d.IsDir())
+			if err != nil {
+				return err
 			}
-			// Skip the generated Go file itself
-			if filepath.Base(path) == outputFileName {
+			if d.IsDir() {
+				if skip {
+					return filepath.SkipDir
+				}
 				return nil
 			}
-			// Skip temporary files starting with ~
-			baseName := filepath.Base(path)
-			if strings.HasPrefix(baseName, "~") {
+			if skip {
 				return nil
 			}
 
@@ -125,15 +330,14 @@ func main() {
 			return nil
 		})
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error walking directory %s: %v\n", sourceDir, err)
-			os.Exit(1)
+			return nil, fmt.Errorf("walking directory %s: %w", sourceDir, err)
 		}
 	}
 
 	// Prepend directory wildcard entries (e.g. "js/*") to allFiles,
 	// so they appear first in the generated Go code.
 	// This allows using fs.Sub(embedFS, "js") to get the entire subdirectory.
-	for _, sourceDir := range dirs {
+	for _, sourceDir := range opts.dirs {
 		// Collect dirs that belong to this sourceDir, sorted for stable output
 		var dirsForSource []string
 		for relDir, sd := range dirsWithFiles {
@@ -154,53 +358,33 @@ func main() {
 	}
 
 	if len(allFiles) == 0 {
-		fmt.Fprintf(os.Stderr, "No files found in any input directory\n")
-		os.Exit(1)
+		return nil, fmt.Errorf("no files found in any input directory")
 	}
+	return allFiles, nil
+}
 
-	// Determine output directory
-	outputDir := dirs[0] // Default to first input directory
-	if goOut != "" {
-		outputDir = goOut
-		// Create output directory if it doesn't exist
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output directory %s: %v\n", outputDir, err)
-			os.Exit(1)
-		}
-	}
-	// Normalize outputDir to absolute path for Rel() computation
-	if absOutputDir, err := filepath.Abs(outputDir); err == nil {
-		outputDir = absOutputDir
+// generate 扫描输入目录并生成 Go 文件
+func generate(opts *options) error {
+	allFiles, err := collectFiles(opts)
+	if err != nil {
+		return err
 	}
 
-	// Determine package name
-	if pkg == "" {
-		// Use basename of go_out if provided, otherwise basename of first input directory
-		if goOut != "" {
-			pkg = filepath.Base(goOut)
-		} else {
-			pkg = filepath.Base(dirs[0])
-		}
+	outputDir := opts.goOut
+	// Create output directory if it doesn't exist
+	if err = os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("creating output directory %s: %w", outputDir, err)
 	}
-	if !isValidIdentifier(pkg) {
-		panic(fmt.Sprintf("Invalid package name: %s", pkg))
+	// 预压缩文件每次重新生成，避免残留已删除文件的压缩文件，未开启压缩时不动该目录
+	if opts.gzip || opts.brotli {
+		if err = os.RemoveAll(opts.compressPath()); err != nil {
+			return fmt.Errorf("cleaning compress directory %s: %w", opts.compressPath(), err)
+		}
 	}
 
-	// Prepare file infos
-	type fileInfo struct {
-		EmbedFS   string
-		EmbedPath string
-		ConstName string
-		Name      string    // 文件名（含后缀）
-		BaseName  string    // 不含后缀的文件名
-		Ext       string    // 文件后缀（如 .xlsx）
-		Dir       string    // 目录路径
-		Size      int64     // 文件大小
-		Content   []byte    // 文件内容
-		ModTime   time.Time // 修改时间
-		IsDirWild bool      // True if this entry represents a directory wildcard (e.g. "js/*")
-	}
 	var infos []fileInfo
+	var tmplErrors []string
+	assetPaths := make(map[string]string) // AssetPath -> 输入目录
 	for i, cf := range allFiles {
 		// Get file full path (strip wildcard for directory wildcard entries)
 		relForPath := cf.RelPath
@@ -212,111 +396,406 @@ func main() {
 		// EmbedPath is the relative path from outputDir (where generated .go lives) to the source
 		embedPath, err := filepath.Rel(outputDir, fullPath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error computing relative path from %s to %s: %v\n", outputDir, fullPath, err)
-			os.Exit(1)
+			return fmt.Errorf("computing relative path from %s to %s: %w", outputDir, fullPath, err)
+		}
+		embedPath = filepath.ToSlash(embedPath)
+		// go:embed 不能引用上级目录
+		if embedPath == ".." || strings.HasPrefix(embedPath, "../") {
+			return fmt.Errorf("input directory %s must be inside go_out %s", cf.SourceDir, outputDir)
 		}
+		// AssetPath 相对于输入目录，-go_out 和输入目录不同时请求路径也不变
+		assetPath := filepath.ToSlash(relForPath)
 		// For directory wildcard entries, append "/*" to the embed path
 		if cf.IsDirWild {
 			embedPath = embedPath + "/*"
+			assetPath = assetPath + "/*"
+		}
+		if other, ok := assetPaths[assetPath]; ok {
+			return fmt.Errorf("%s exists in both %s and %s", assetPath, other, cf.SourceDir)
 		}
+		assetPaths[assetPath] = cf.SourceDir
 
 		// Generate constant name from EmbedPath to ensure uniqueness across directories
 		constName := embedPathToConst(embedPath)
 
-		// For wildcard dir entries, we don't stat an actual file
-		var (
-			baseName      string
-			ext2          string
-			nameWithoutExt string
-			dirTemp       string
-			fileSize      int64
-			modTime       time.Time
-		)
+		info := fileInfo{
+			EmbedPath: embedPath,
+			AssetPath: assetPath,
+			ConstName: constName,
+			EmbedFS:   fmt.Sprintf("file%03d", i+1),
+			IsDirWild: cf.IsDirWild,
+		}
 
 		if cf.IsDirWild {
-			// Wildcard entry: use the dir name as the "Name"
-			dirTemp = filepath.Base(filepath.Dir(embedPath))
-			if dirTemp == "." {
-				dirTemp = ""
-			}
-			baseName = dirTemp
-			nameWithoutExt = dirTemp
-			ext2 = ""
-			fileSize = 0
-			modTime = time.Time{}
+			// Wildcard entry: Dir is the directory path within the input directory, Name is its base name
+			info.Dir = path.Dir(assetPath)
+			info.Name = path.Base(info.Dir)
+			info.BaseName = info.Name
+			info.EmbedDir = strings.TrimSuffix(embedPath, "/*")
 		} else {
 			// Read file info and content
 			fileInfoStat, err := os.Stat(fullPath)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", fullPath, err)
-				os.Exit(1)
+				return fmt.Errorf("reading file %s: %w", fullPath, err)
 			}
-
-			baseName = filepath.Base(fullPath)
-			ext := filepath.Ext(baseName)
-			ext2 = ext
-			if len(ext) > 0 {
-				ext2 = ext[1:]
+			content, err := os.ReadFile(fullPath)
+			if err != nil {
+				return fmt.Errorf("reading file %s: %w", fullPath, err)
 			}
-			nameWithoutExt = baseName[:len(baseName)-len(ext)]
 
-			// Dir is the directory portion of EmbedPath (e.g. "js" for "js/aaa.js", "" for root-level files)
-			dirTemp = filepath.Dir(embedPath)
-			if dirTemp == "." {
-				dirTemp = ""
+			info.Name = filepath.Base(fullPath)
+			ext := filepath.Ext(info.Name)
+			info.Ext = strings.TrimPrefix(ext, ".")
+			info.BaseName = info.Name[:len(info.Name)-len(ext)]
+
+			// Dir is the directory portion of AssetPath (e.g. "js" for "js/aaa.js", "" for root-level files)
+			info.Dir = path.Dir(assetPath)
+			if info.Dir == "." {
+				info.Dir = ""
+			}
+			info.Size = fileInfoStat.Size()
+			info.ModTime = fileInfoStat.ModTime()
+
+			sum := sha256.Sum256(content)
+			info.Hash = hex.EncodeToString(sum[:])
+			info.HashedName = path.Join(info.Dir, info.BaseName+"."+info.Hash[:8]+ext)
+			info.MimeType = mime.TypeByExtension(ext)
+			if info.MimeType == "" {
+				info.MimeType = http.DetectContentType(content)
+			}
+			if err = writeCompressed(opts, &info, content); err != nil {
+				return err
+			}
+			info.Kind = detectKind(&info)
+			if isTemplate(opts, cf.RelPath) {
+				if err = checkTemplate(opts, &info, content); err != nil {
+					tmplErrors = append(tmplErrors, err.Error())
+				}
 			}
-			fileSize = fileInfoStat.Size()
-			modTime = fileInfoStat.ModTime()
 		}
-
-		infos = append(infos, fileInfo{
-			EmbedPath: embedPath,
-			ConstName: constName,
-			Name:      baseName,
-			BaseName:  nameWithoutExt,
-			Ext:       ext2,
-			Dir:       dirTemp,
-			Size:      fileSize,
-			Content:   nil,
-			EmbedFS:   fmt.Sprintf("file%03d", i+1),
-			ModTime:   modTime,
-			IsDirWild: cf.IsDirWild,
-		})
+		infos = append(infos, info)
+	}
+	// 模板语法错误在生成时报告，全部检查完后一起输出
+	if len(tmplErrors) > 0 {
+		return fmt.Errorf("template errors:\n  %s", strings.Join(tmplErrors, "\n  "))
+	}
+	templates, imports, err := renderFuncs(opts, infos)
+	if err != nil {
+		return err
 	}
 
 	// Load embedded template
 	tmplContent, err := templateFS.ReadFile("assets.tpl")
 	if err != nil {
-		panic(err)
+		return err
 	}
 	tmpl, err := template.New("assets").Parse(string(tmplContent))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing template: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("parsing template: %w", err)
 	}
 
 	// Execute template
 	data := struct {
-		Package string
-		Files   []fileInfo
+		Package   string
+		Files     []fileInfo
+		Http      bool
+		Rest      bool
+		Templates []fileInfo
+		Imports   []importSpec
 	}{
-		Package: pkg,
-		Files:   infos,
+		Package:   opts.pkg,
+		Files:     infos,
+		Http:      opts.http,
+		Rest:      opts.rest,
+		Templates: templates,
+		Imports:   imports,
 	}
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		fmt.Fprintf(os.Stderr, "Error executing template: %v\n", err)
-		os.Exit(1)
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting generated code: %w", err)
 	}
 
 	// Write generated Go file to output directory
-	outputPath := filepath.Join(outputDir, outputFileName)
-	err = os.WriteFile(outputPath, buf.Bytes(), 0644)
+	outputPath := filepath.Join(outputDir, opts.goFile)
+	if err = os.WriteFile(outputPath, code, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", opts.goFile, err)
+	}
+	if err = writeManifest(opts, infos); err != nil {
+		return err
+	}
+	fmt.Printf("Generated %s with %d embedded files (package: %s)\n", outputPath, len(allFiles), opts.pkg)
+	return nil
+}
+
+// writeCompressed 生成预压缩文件，压缩后没有变小的文件（比如图片）不生成
+func writeCompressed(opts *options, info *fileInfo, content []byte) error {
+	variants := []struct {
+		enabled bool
+		ext     string
+		target  *string
+		size    *int
+		newFn   func(w io.Writer) io.WriteCloser
+	}{
+		{opts.gzip, ".gz", &info.GzipPath, &info.GzipSize, func(w io.Writer) io.WriteCloser {
+			gw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+			return gw
+		}},
+		{opts.brotli, ".br", &info.BrotliPath, &info.BrotliSize, func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriterLevel(w, brotli.BestCompression)
+		}},
+	}
+	for _, v := range variants {
+		if !v.enabled {
+			continue
+		}
+		var buf bytes.Buffer
+		cw := v.newFn(&buf)
+		if _, err := cw.Write(content); err != nil {
+			return err
+		}
+		if err := cw.Close(); err != nil {
+			return err
+		}
+		if buf.Len() >= len(content) {
+			continue
+		}
+		if err := os.MkdirAll(opts.compressPath(), 0755); err != nil {
+			return fmt.Errorf("creating compress directory %s: %w", opts.compressPath(), err)
+		}
+		name := info.EmbedFS + v.ext
+		if err := os.WriteFile(filepath.Join(opts.compressPath(), name), buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("writing compressed file %s: %w", name, err)
+		}
+		*v.target = path.Join(filepath.ToSlash(opts.compressDir), name)
+		*v.size = buf.Len()
+	}
+	return nil
+}
+
+// templateExts 模板文件的后缀，判断 html 模板时先去掉
+var templateExts = []string{".tmpl", ".tpl", ".gotmpl"}
+
+func isTemplate(opts *options, rel string) bool {
+	return len(opts.templates) > 0 && matchGlob(opts.templates, rel)
+}
+
+// detectKind 根据后缀和 MIME 判断文件类型，模板类型在 checkTemplate 中设置
+func detectKind(info *fileInfo) string {
+	switch strings.ToLower(info.Ext) {
+	case "xlsx", "xls", "xlsm":
+		return "excel"
+	}
+	kind, _, _ := strings.Cut(info.MimeType, "/")
+	return kind
+}
+
+// isHtmlTemplate .gohtml 以及 .html.tmpl 等使用 html/template，其他使用 text/template
+func isHtmlTemplate(name string) bool {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".gohtml") {
+		return true
+	}
+	for _, ext := range templateExts {
+		lower = strings.TrimSuffix(lower, ext)
+	}
+	ext := path.Ext(lower)
+	return ext == ".html" || ext == ".htm"
+}
+
+// checkTemplate 生成时解析模板，自定义函数使用空实现，只检查语法
+func checkTemplate(opts *options, info *fileInfo, content []byte) error {
+	funcs := make(map[string]any, len(opts.tmplFuncs))
+	for _, name := range opts.tmplFuncs {
+		funcs[name] = func(...any) any { return nil }
+	}
+	var err error
+	if isHtmlTemplate(info.Name) {
+		info.Kind = "html-template"
+		_, err = htmltemplate.New(info.AssetPath).Funcs(funcs).Parse(string(content))
+	} else {
+		info.Kind = "text-template"
+		_, err = template.New(info.AssetPath).Funcs(funcs).Parse(string(content))
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", outputFileName, err)
-		os.Exit(1)
+		return err
+	}
+
+	// invoice_email.html.tmpl -> RenderInvoiceEmail
+	name := info.AssetPath
+	for _, ext := range append(templateExts, ".gohtml") {
+		name = strings.TrimSuffix(name, ext)
+	}
+	name = strings.TrimSuffix(name, path.Ext(name))
+	info.RenderFunc = "Render" + embedPathToConst(name)
+	info.DataType = "any"
+	return nil
+}
+
+// importSpec 生成代码需要导入的包
+type importSpec struct {
+	Alias string
+	Path  string
+}
+
+// renderFuncs 返回需要生成渲染方法的模板，以及渲染数据类型需要导入的包
+func renderFuncs(opts *options, infos []fileInfo) ([]fileInfo, []importSpec, error) {
+	var templates []fileInfo
+	var imports []importSpec
+	aliases := make(map[string]string) // 包路径 -> 别名
+	used := make(map[string]bool)
+	funcNames := make(map[string]string)
+	for _, info := range infos {
+		if info.RenderFunc == "" {
+			continue
+		}
+		if other, ok := funcNames[info.RenderFunc]; ok {
+			return nil, nil, fmt.Errorf("templates %s and %s both generate %s", other, info.AssetPath, info.RenderFunc)
+		}
+		funcNames[info.RenderFunc] = info.AssetPath
+
+		typ, ok := opts.tmplData[info.AssetPath]
+		if !ok {
+			typ, ok = opts.tmplData[info.Name]
+		}
+		if ok && typ != "" {
+			// *github.com/foo/billing.Invoice -> *billing.Invoice
+			pointer := strings.HasPrefix(typ, "*")
+			typ = strings.TrimPrefix(typ, "*")
+			if dot := strings.LastIndex(typ, "."); dot > 0 && dot > strings.LastIndex(typ, "/") {
+				pkgPath, typeName := typ[:dot], typ[dot+1:]
+				alias, ok := aliases[pkgPath]
+				if !ok {
+					base := strings.NewReplacer("-", "_", ".", "_").Replace(path.Base(pkgPath))
+					alias = base
+					for i := 2; used[alias] || alias == opts.pkg; i++ {
+						alias = fmt.Sprintf("%s%d", base, i)
+					}
+					aliases[pkgPath] = alias
+					used[alias] = true
+					imports = append(imports, importSpec{Alias: alias, Path: pkgPath})
+				}
+				typ = alias + "." + typeName
+			}
+			if pointer {
+				typ = "*" + typ
+			}
+			info.DataType = typ
+		}
+		templates = append(templates, info)
+	}
+	return templates, imports, nil
+}
+
+// manifestFile 清单中的一个文件
+type manifestFile struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Sha256     string `json:"sha256"`
+	HashedName string `json:"hashed_name"`
+	Kind       string `json:"kind"`
+	MimeType   string `json:"mime_type"`
+	GzipSize   int    `json:"gzip_size,omitempty"`
+	BrotliSize int    `json:"brotli_size,omitempty"`
+	RenderFunc string `json:"render_func,omitempty"`
+}
+
+// writeManifest 输出每个文件的大小、摘要和类型，内容不变时文件也不变，方便提交到代码库比对
+func writeManifest(opts *options, infos []fileInfo) error {
+	if opts.manifest == "" || opts.manifest == "-" {
+		return nil
+	}
+	files := make([]manifestFile, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDirWild {
+			continue
+		}
+		files = append(files, manifestFile{
+			Path:       info.AssetPath,
+			Size:       info.Size,
+			Sha256:     info.Hash,
+			HashedName: info.HashedName,
+			Kind:       info.Kind,
+			MimeType:   info.MimeType,
+			GzipSize:   info.GzipSize,
+			BrotliSize: info.BrotliSize,
+			RenderFunc: info.RenderFunc,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	data, err := json.MarshalIndent(map[string]any{"files": files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(opts.goOut, opts.manifest)
+	if err = os.WriteFile(manifestPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing manifest %s: %w", manifestPath, err)
+	}
+	return nil
+}
+
+// snapshot 输入目录中参与生成的文件及其修改时间、大小
+func snapshot(opts *options) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, sourceDir := range opts.dirs {
+		err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			skip, err := opts.skipPath(sourceDir, path, d.IsDir())
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if skip {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if skip {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			files[path] = fmt.Sprintf("%d/%d", info.ModTime().UnixNano(), info.Size())
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// watchAndGenerate 定时扫描输入目录，文件新增、删除或修改后重新生成
+func watchAndGenerate(opts *options, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	last, err := snapshot(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error scanning: %v\n", err)
+	}
+	fmt.Printf("Watching %s every %s\n", strings.Join(opts.dirs, ","), interval)
+	for range time.Tick(interval) {
+		current, err := snapshot(opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error scanning: %v\n", err)
+			continue
+		}
+		if reflect.DeepEqual(current, last) {
+			continue
+		}
+		last = current
+		if err = generate(opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
 	}
-	fmt.Printf("Generated %s with %d embedded files (package: %s)\n", outputPath, len(allFiles), pkg)
 }
 
 func filenameToConst(filename string) string {
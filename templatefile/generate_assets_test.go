@@ -0,0 +1,643 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// writeFiles 在 dir 下创建文件，name 使用 / 分隔
+func writeFiles(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for name, content := range files {
+		fullPath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestOptions 与命令行默认值一致的参数
+func newTestOptions(t *testing.T, goOut string, dirs ...string) *options {
+	t.Helper()
+	opts := &options{
+		dirs:        dirs,
+		goOut:       goOut,
+		pkg:         "assets",
+		excludes:    []string{"~*"},
+		compressDir: "assets_compressed",
+		manifest:    "assets.manifest.json",
+		tmplData:    map[string]string{},
+	}
+	if err := opts.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func readManifest(t *testing.T, opts *options) map[string]manifestFile {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(opts.goOut, opts.manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Files []manifestFile `json:"files"`
+	}
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]manifestFile)
+	for _, f := range manifest.Files {
+		files[f.Path] = f
+	}
+	return files
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+var testIndexHtml = []byte("<html><body>" + strings.Repeat("<p>hello</p>", 200) + "</body></html>")
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		rel      string
+		want     bool
+	}{
+		{[]string{"*.html"}, "index.html", true},
+		{[]string{"*.html"}, "pages/about.html", true},
+		{[]string{"*.html"}, "index.htm", false},
+		{[]string{"css/*"}, "css/site.css", true},
+		{[]string{"css/*"}, "css/sub/site.css", false},
+		{[]string{"css/*"}, "site.css", false},
+		{[]string{"~*"}, "pages/~draft.html", true},
+		{[]string{"*.js", "*.css"}, "css/site.css", true},
+		{nil, "index.html", false},
+	}
+	for _, c := range cases {
+		if got := matchGlob(c.patterns, c.rel); got != c.want {
+			t.Errorf("matchGlob(%v, %q) = %v, want %v", c.patterns, c.rel, got, c.want)
+		}
+	}
+}
+
+func TestCollectFilesGlobs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{
+		"index.html":       testIndexHtml,
+		"~draft.html":      []byte("draft"),
+		"css/site.css":     []byte("body{}"),
+		"css/sub/deep.css": []byte("p{}"),
+		"js/app.js":        []byte("1"),
+		"README.md":        []byte("readme"),
+	})
+	opts := newTestOptions(t, "", dir)
+	opts.includes = []string{"*.html", "css/*"}
+	files, err := collectFiles(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, filepath.ToSlash(f.RelPath))
+	}
+	want := "[css/* css/site.css index.html]"
+	if strings.Join(got, " ") != strings.Trim(want, "[]") {
+		t.Errorf("collected %v, want %s", got, want)
+	}
+}
+
+func TestGenerateAssetRoot(t *testing.T) {
+	goOut := t.TempDir()
+	assetDir := filepath.Join(goOut, "public")
+	writeFiles(t, assetDir, map[string][]byte{
+		"index.html":   testIndexHtml,
+		"css/site.css": []byte("body{}"),
+	})
+	opts := newTestOptions(t, goOut, assetDir)
+	if err := generate(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// 请求路径相对于输入目录，go:embed 路径相对于生成文件所在目录
+	manifest := readManifest(t, opts)
+	for _, name := range []string{"index.html", "css/site.css"} {
+		if _, ok := manifest[name]; !ok {
+			t.Errorf("manifest missing %s: %v", name, manifest)
+		}
+	}
+	code, err := os.ReadFile(filepath.Join(goOut, "assets.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"//go:embed public/index.html\n",
+		`PublicIndexHtml  = "index.html"`,
+		`EmbedName:  "public/index.html"`,
+		`EmbedName: "public/css"`,
+	} {
+		if !bytes.Contains(code, []byte(want)) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	if bytes.Contains(code, []byte("../")) {
+		t.Error("generated code references a parent directory")
+	}
+
+	// 输入目录不在 go_out 下时 go:embed 无法引用
+	opts = newTestOptions(t, t.TempDir(), assetDir)
+	if err = generate(opts); err == nil || !strings.Contains(err.Error(), "must be inside go_out") {
+		t.Errorf("generate() error = %v, want input directory outside go_out", err)
+	}
+
+	// 多个输入目录中的同名文件请求路径冲突
+	other := filepath.Join(goOut, "other")
+	writeFiles(t, other, map[string][]byte{"index.html": []byte("other")})
+	opts = newTestOptions(t, goOut, assetDir, other)
+	if err = generate(opts); err == nil || !strings.Contains(err.Error(), "index.html exists in both") {
+		t.Errorf("generate() error = %v, want duplicated index.html", err)
+	}
+}
+
+func TestGenerateHashAndCompress(t *testing.T) {
+	dir := t.TempDir()
+	logo := randomBytes(t, 1024)
+	writeFiles(t, dir, map[string][]byte{
+		"index.html": testIndexHtml,
+		"logo.png":   logo,
+	})
+	opts := newTestOptions(t, "", dir)
+	opts.gzip = true
+	opts.brotli = true
+	// 上次生成的压缩文件需要删除
+	writeFiles(t, opts.compressPath(), map[string][]byte{"file999.gz": []byte("stale")})
+	if err := generate(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.compressPath(), "file999.gz")); !os.IsNotExist(err) {
+		t.Errorf("stale compressed file not removed: %v", err)
+	}
+
+	manifest := readManifest(t, opts)
+	for name, content := range map[string][]byte{"index.html": testIndexHtml, "logo.png": logo} {
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		f := manifest[name]
+		if f.Sha256 != hash {
+			t.Errorf("%s sha256 = %s, want %s", name, f.Sha256, hash)
+		}
+		ext := filepath.Ext(name)
+		if want := strings.TrimSuffix(name, ext) + "." + hash[:8] + ext; f.HashedName != want {
+			t.Errorf("%s hashed name = %s, want %s", name, f.HashedName, want)
+		}
+		if f.Size != int64(len(content)) {
+			t.Errorf("%s size = %d, want %d", name, f.Size, len(content))
+		}
+	}
+
+	// 文本文件生成 gzip 和 brotli，内容解压后不变
+	html := manifest["index.html"]
+	if html.Kind != "text" || html.GzipSize == 0 || html.BrotliSize == 0 {
+		t.Fatalf("index.html manifest = %+v", html)
+	}
+	compressed, err := filepath.Glob(filepath.Join(opts.compressPath(), "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) != 2 {
+		t.Fatalf("compressed files = %v, want gzip and brotli of index.html", compressed)
+	}
+	for _, name := range compressed {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader
+		switch filepath.Ext(name) {
+		case ".gz":
+			if len(data) != html.GzipSize {
+				t.Errorf("gzip size = %d, want %d", len(data), html.GzipSize)
+			}
+			if r, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+		case ".br":
+			if len(data) != html.BrotliSize {
+				t.Errorf("brotli size = %d, want %d", len(data), html.BrotliSize)
+			}
+			r = brotli.NewReader(bytes.NewReader(data))
+		default:
+			t.Fatalf("unexpected compressed file %s", name)
+		}
+		plain, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plain, testIndexHtml) {
+			t.Errorf("%s does not decompress to index.html", name)
+		}
+	}
+
+	// 压缩后没有变小的文件不生成压缩文件
+	if png := manifest["logo.png"]; png.Kind != "image" || png.GzipSize != 0 || png.BrotliSize != 0 {
+		t.Errorf("logo.png manifest = %+v", png)
+	}
+}
+
+// generatedHandlerTest 编译生成的代码并验证静态文件服务
+const generatedHandlerTest = `package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func serve(method, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	AssetHandler("/static").ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAssetHandler(t *testing.T) {
+	index, err := LoadAssetFile(PublicIndexHtml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(http.MethodGet, "/static/index.html", nil)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), index.Content) {
+		t.Fatalf("GET index.html = %d", rec.Code)
+	}
+	if rec.Header().Get("Cache-Control") != "no-cache" || rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("GET index.html header = %v", rec.Header())
+	}
+	etag := rec.Header().Get("ETag")
+	if etag != index.ETag {
+		t.Errorf("ETag = %s, want %s", etag, index.ETag)
+	}
+
+	for _, inm := range []string{etag, "W/" + etag, "\"other\", " + etag, "*"} {
+		rec = serve(http.MethodGet, "/static/index.html", map[string]string{"If-None-Match": inm})
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s = %d", inm, rec.Code)
+		}
+	}
+	rec = serve(http.MethodGet, "/static/index.html", map[string]string{"If-None-Match": "\"other\""})
+	if rec.Code != http.StatusOK {
+		t.Errorf("If-None-Match other = %d", rec.Code)
+	}
+
+	for _, c := range []struct {
+		accept   string
+		encoding string
+	}{
+		{"gzip", "gzip"},
+		{"gzip, br", "br"},
+		{"br;q=0, gzip", "gzip"},
+		{"identity", ""},
+	} {
+		rec = serve(http.MethodGet, "/static/index.html", map[string]string{"Accept-Encoding": c.accept})
+		if got := rec.Header().Get("Content-Encoding"); got != c.encoding {
+			t.Errorf("Accept-Encoding %s: Content-Encoding = %q, want %q", c.accept, got, c.encoding)
+			continue
+		}
+		var r io.Reader = rec.Body
+		switch c.encoding {
+		case "gzip":
+			if r, err = gzip.NewReader(rec.Body); err != nil {
+				t.Fatal(err)
+			}
+		case "br":
+			r = brotli.NewReader(rec.Body)
+		}
+		if body, err := io.ReadAll(r); err != nil || !bytes.Equal(body, index.Content) {
+			t.Errorf("Accept-Encoding %s: body mismatch, err %v", c.accept, err)
+		}
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %s: Vary = %q", c.accept, rec.Header().Get("Vary"))
+		}
+	}
+
+	rec = serve(http.MethodGet, "/static/"+AssetHashedName(PublicIndexHtml), nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("GET hashed index.html = %d %v", rec.Code, rec.Header())
+	}
+	rec = serve(http.MethodGet, "/static/logo.png", map[string]string{"Accept-Encoding": "gzip, br"})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("GET logo.png = %d %v", rec.Code, rec.Header())
+	}
+	rec = serve(http.MethodHead, "/static/css/site.css", nil)
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("HEAD site.css = %d, body %d bytes", rec.Code, rec.Body.Len())
+	}
+	if rec = serve(http.MethodPost, "/static/css/site.css", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST site.css = %d", rec.Code)
+	}
+	if rec = serve(http.MethodGet, "/static/public/index.html", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET public/index.html = %d", rec.Code)
+	}
+
+	css, err := LoadAssetDir(PublicCss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = css.Open("site.css"); err != nil {
+		t.Errorf("open site.css in css dir: %v", err)
+	}
+}
+
+func TestAssetRoutes(t *testing.T) {
+	paths := map[string]bool{}
+	for _, route := range AssetRoutes("static") {
+		paths[route.Method+" "+route.Path] = true
+	}
+	for _, want := range []string{
+		"GET /static/index.html",
+		"HEAD /static/index.html",
+		"GET /static/" + AssetHashedName(PublicCssSiteCss),
+		"GET /static/logo.png",
+	} {
+		if !paths[want] {
+			t.Errorf("AssetRoutes missing %s: %v", want, paths)
+		}
+	}
+}
+`
+
+// newGeneratedModule 创建独立的模块用于编译生成的代码，依赖版本与本仓库一致
+func newGeneratedModule(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("compiling generated code is slow")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	goMod, err := os.ReadFile("../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goSum, err := os.ReadFile("../go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rest, _ := strings.Cut(string(goMod), "\n")
+	goMod = []byte("module assetstest\n" + rest + "\nrequire github.com/xuri/excelize/v2 v2.10.0\n")
+	writeFiles(t, dir, map[string][]byte{"go.mod": goMod, "go.sum": goSum})
+	return dir
+}
+
+// goTest 在生成的模块中执行 go test
+func goTest(t *testing.T, dir string) {
+	t.Helper()
+	cmd := exec.Command("go", "test", "-count=1", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test generated code: %v\n%s", err, out)
+	}
+}
+
+func TestGeneratedHandler(t *testing.T) {
+	goOut := newGeneratedModule(t)
+	assetDir := filepath.Join(goOut, "public")
+	writeFiles(t, assetDir, map[string][]byte{
+		"index.html":   testIndexHtml,
+		"css/site.css": []byte("body{}"),
+		"logo.png":     append([]byte("\x89PNG\r\n\x1a\n"), randomBytes(t, 1024)...),
+	})
+	opts := newTestOptions(t, goOut, assetDir)
+	opts.gzip = true
+	opts.brotli = true
+	opts.http = true
+	opts.rest = true
+	if err := generate(opts); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, goOut, map[string][]byte{"assets_test.go": []byte(generatedHandlerTest)})
+	goTest(t, goOut)
+}
+
+// copyDir 复制 testdata 中的目录
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		writeFiles(t, dst, map[string][]byte{filepath.ToSlash(rel): content})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// modTimeRe 修改时间随检出时间变化，比对前去掉
+var modTimeRe = regexp.MustCompile(`(ModTime:\s+)"[^"]*"`)
+
+// generatedTemplateTest 编译生成的代码并渲染模板
+const generatedTemplateTest = `package assets
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"assetstest/billing"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	if err := ParseTemplates(); err == nil || !strings.Contains(err.Error(), "money") {
+		t.Fatalf("ParseTemplates() without funcs error = %v", err)
+	}
+	templatesOnce = sync.Once{}
+	TemplateFuncs["money"] = func(cents int) string {
+		return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+	}
+	if err := ParseTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderMailInvoice(&buf, &billing.Invoice{Title: "<Tom & Jerry>", Amount: 1234}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<h1>&lt;Tom &amp; Jerry&gt;</h1><p>12.34</p>\n"; buf.String() != want {
+		t.Errorf("RenderMailInvoice() = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := RenderNotice(&buf, "<world>"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hello <world>!\n"; buf.String() != want {
+		t.Errorf("RenderNotice() = %q, want %q", buf.String(), want)
+	}
+}
+`
+
+func newTemplateOptions(t *testing.T, goOut string) *options {
+	t.Helper()
+	copyDir(t, "testdata/templates", filepath.Join(goOut, "templates"))
+	opts := newTestOptions(t, goOut, filepath.Join(goOut, "templates"))
+	opts.templates = []string{"*.tmpl"}
+	opts.tmplFuncs = []string{"money"}
+	opts.tmplData = map[string]string{"mail/invoice.html.tmpl": "*assetstest/billing.Invoice"}
+	return opts
+}
+
+func TestGenerateTemplatesGolden(t *testing.T) {
+	goOut := newGeneratedModule(t)
+	opts := newTemplateOptions(t, goOut)
+	if err := generate(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := os.ReadFile(filepath.Join(goOut, "assets.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code = modTimeRe.ReplaceAll(code, []byte(`${1}"-"`))
+	golden := filepath.Join("testdata", "templates.go.golden")
+	if *update {
+		if err = os.WriteFile(golden, code, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, want) {
+		t.Errorf("generated code differs from %s, run go test -update to update it:\n%s", golden, code)
+	}
+
+	manifest := readManifest(t, opts)
+	for name, want := range map[string]manifestFile{
+		"mail/invoice.html.tmpl": {Kind: "html-template", RenderFunc: "RenderMailInvoice"},
+		"notice.tmpl":            {Kind: "text-template", RenderFunc: "RenderNotice"},
+		"style.css":              {Kind: "text"},
+	} {
+		if got := manifest[name]; got.Kind != want.Kind || got.RenderFunc != want.RenderFunc {
+			t.Errorf("manifest %s = %s/%s, want %s/%s", name, got.Kind, got.RenderFunc, want.Kind, want.RenderFunc)
+		}
+	}
+
+	writeFiles(t, goOut, map[string][]byte{
+		"billing/billing.go": []byte("package billing\n\ntype Invoice struct {\n\tTitle  string\n\tAmount int\n}\n"),
+		"assets_test.go":     []byte(generatedTemplateTest),
+	})
+	goTest(t, goOut)
+}
+
+func TestCompressDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{"index.html": testIndexHtml})
+	for _, compressDir := range []string{"", ".", "..", "../other", "a/../..", filepath.Join(dir, "abs")} {
+		opts := &options{dirs: []string{dir}, pkg: "assets", compressDir: compressDir}
+		if err := opts.normalize(); err == nil || !strings.Contains(err.Error(), "compress_dir") {
+			t.Errorf("compress_dir %q: normalize() error = %v", compressDir, err)
+		}
+	}
+
+	// 未开启压缩时不删除预压缩目录
+	opts := newTestOptions(t, "", dir)
+	opts.compressDir = "static/gz"
+	writeFiles(t, opts.compressPath(), map[string][]byte{"keep.txt": []byte("keep")})
+	if err := generate(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.compressPath(), "keep.txt")); err != nil {
+		t.Errorf("compress directory cleaned without compression: %v", err)
+	}
+}
+
+func TestGenerateTemplateErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string][]byte
+		funcs []string
+		want  string
+	}{
+		{
+			name:  "syntax",
+			files: map[string][]byte{"broken.tmpl": []byte("{{if .}}")},
+			want:  "broken.tmpl",
+		},
+		{
+			name:  "unknown func",
+			files: map[string][]byte{"mail/invoice.html.tmpl": []byte("{{money .}}")},
+			want:  `function "money" not defined`,
+		},
+		{
+			name: "duplicated render func",
+			files: map[string][]byte{
+				"mail_invoice.tmpl":      []byte("a"),
+				"mail/invoice.html.tmpl": []byte("b"),
+			},
+			want: "both generate RenderMailInvoice",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, c.files)
+			opts := newTestOptions(t, "", dir)
+			opts.templates = []string{"*.tmpl"}
+			opts.tmplFuncs = c.funcs
+			err := generate(opts)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("generate() error = %v, want %q", err, c.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package tracer
 
 import (
 	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
 	"gorm.io/plugin/opentelemetry/tracing"
 )
@@ -11,10 +14,23 @@ func (hc *TraceConfig) GormMiddleware(ctx context.Context, db *gorm.DB) *gorm.DB
 	if err != nil {
 		return db
 	}
-	err = db.Use(tracing.NewPlugin())
-	if err != nil {
+	err = db.Use(tracing.NewPlugin(tracing.WithTracerProvider(hc.tracerProvider())))
+	if err != nil && !errors.Is(err, gorm.ErrRegistered) {
 		return db
 	}
 	db = db.WithContext(ctx)
 	return db
 }
+
+// TraceGormDB 为 gorm 连接注册链路插件，sql中的参数以占位符记录。
+// 使用 otel 全局的 TracerProvider，默认 Tracer 在注册之后才初始化也能生效，重复注册不会报错
+func TraceGormDB(db *gorm.DB) error {
+	err := db.Use(tracing.NewPlugin(
+		tracing.WithTracerProvider(otel.GetTracerProvider()),
+		tracing.WithoutQueryVariables(),
+	))
+	if err != nil && !errors.Is(err, gorm.ErrRegistered) {
+		return err
+	}
+	return nil
+}
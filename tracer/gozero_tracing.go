@@ -6,41 +6,40 @@ import (
 	"github.com/zeromicro/go-zero/core/service"
 	ztrace "github.com/zeromicro/go-zero/core/trace"
 	"github.com/zeromicro/go-zero/rest"
+	"go.opentelemetry.io/otel"
 	"net/http"
 )
 
 // InitGoZeroTracing 初始化gozeroTracing,避免后续更新会被 rest.MustNewServer 抢占的问题
+// go-zero 的 rest/zrpc 埋点使用该配置实例的 TracerProvider，采样规则、限速采样和span过滤器同样生效，
+// 需要在 rest.MustNewServer、zrpc.MustNewServer 之前调用
 func (hc *TraceConfig) InitGoZeroTracing(srvConfig *service.ServiceConf) error {
-	_, err := hc.InitTrace()
+	t, err := hc.Tracer()
 	if err != nil {
 		return err
 	}
-	tc := GetTraceConfig()
-	if tc.Endpoint == "" {
-		return nil
-	}
-	srvConfig.Telemetry.Name = tc.getTracerName()
-	srvConfig.Telemetry.Endpoint = tc.Endpoint
-	srvConfig.Telemetry.Sampler = tc.getSampler()
-	srvConfig.Telemetry.Batcher = tc.Batcher
-	srvConfig.Telemetry.OtlpHeaders = tc.OtlpHeaders
-	srvConfig.Telemetry.OtlpHttpPath = tc.OtlpHttpPath
-	srvConfig.Telemetry.OtlpHttpSecure = tc.OtlpHttpSecure
+	srvConfig.Telemetry.Name = hc.getTracerName()
+	// 上报由该实例的 provider 负责，go-zero 自己的 provider 不创建 exporter，避免重复上报
+	srvConfig.Telemetry.Endpoint = ""
+	srvConfig.Telemetry.Sampler = hc.samplerRatio()
+	srvConfig.Telemetry.Batcher = hc.getBatcher()
+	srvConfig.Telemetry.OtlpHeaders = hc.OtlpHeaders
+	srvConfig.Telemetry.OtlpHttpPath = hc.OtlpHttpPath
+	srvConfig.Telemetry.OtlpHttpSecure = hc.OtlpHttpSecure
 	srvConfig.Telemetry.Disabled = false
-	ztrace.StartAgent(srvConfig.Telemetry)
+	// rest/zrpc 用服务配置初始化 logx，在这里改名才能生效
+	setLogxFieldKeys(&srvConfig.Log)
+	startGoZeroAgent(t, srvConfig.Telemetry)
 	return nil
 }
 
 func (hc *TraceConfig) GoZeroMiddleware(serv *rest.Server, pc ...*param.PathConfig) func(next http.HandlerFunc) http.HandlerFunc {
-	err := hc.checkConfig()
-	if err == nil {
-		ztrace.StartAgent(ztrace.Config{
-			Name:     hc.getTracerName(),
-			Endpoint: hc.Endpoint,
-			Batcher:  hc.Batcher,
-			Sampler:  hc.getSampler(),
+	if t, err := hc.Tracer(); err == nil {
+		startGoZeroAgent(t, ztrace.Config{
+			Name:    hc.getTracerName(),
+			Batcher: hc.getBatcher(),
+			Sampler: hc.samplerRatio(),
 		})
-		hc.useGoZero = true
 	}
 	return hc.commMiddleware(func(r *http.Request) (string, string) {
 		routesList := serv.Routes()
@@ -58,3 +57,13 @@ func (hc *TraceConfig) GoZeroMiddleware(serv *rest.Server, pc ...*param.PathConf
 		return "", ""
 	})
 }
+
+// startGoZeroAgent go-zero 的 agent 只会初始化一次，先占用这次初始化，再把 otel 全局设置指向 t，
+// go-zero 埋点通过 otel.Tracer 获取的 tracer 就会使用 t 的采样器和处理器
+func startGoZeroAgent(t *Tracer, c ztrace.Config) {
+	c.Endpoint = ""
+	ztrace.StartAgent(c)
+	goZeroAgentRun.Store(true)
+	otel.SetTracerProvider(t.Provider())
+	otel.SetTextMapPropagator(t.Propagator())
+}
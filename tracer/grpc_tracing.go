@@ -6,5 +6,9 @@ import (
 )
 
 func (hc *TraceConfig) GrpcMiddleware() (grpc.ServerOption, grpc.DialOption) {
-	return grpc.StatsHandler(otelgrpc.NewServerHandler()), grpc.WithStatsHandler(otelgrpc.NewClientHandler())
+	opts := []otelgrpc.Option{
+		otelgrpc.WithTracerProvider(hc.tracerProvider()),
+		otelgrpc.WithPropagators(hc.propagator()),
+	}
+	return grpc.StatsHandler(otelgrpc.NewServerHandler(opts...)), grpc.WithStatsHandler(otelgrpc.NewClientHandler(opts...))
 }
@@ -4,10 +4,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/magic-lib/go-plat-utils/utils/httputil/param"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
-	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"net/http"
 	"slices"
 	"strings"
+	"time"
 )
 
 var defaultSpanNameFormatter = func(method string, path string) string {
@@ -43,15 +44,18 @@ func (hc *TraceConfig) commMiddleware(fun func(r *http.Request) (tracName string
 
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			ctx := SpanFromRequest(r.Context(), r)
+			ctx := hc.propagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
 
 			traceName, spanName := fun(r)
 
 			if traceName == "" {
 				traceName = hc.ServiceName
 			}
+			// 指标只使用匹配到的路由，避免原始路径导致维度过多
+			metricRoute := spanName
 			if spanName == "" {
 				spanName = defaultSpanNameFormatter(r.Method, r.URL.Path)
+				metricRoute = "unmatched"
 			}
 
 			ctx, span := hc.StartSpan(ctx, spanName, traceName)
@@ -68,7 +72,15 @@ func (hc *TraceConfig) commMiddleware(fun func(r *http.Request) (tracName string
 				"url.host":                r.URL.Host,
 			})
 			r = r.WithContext(ctx)
-			next(w, r)
+			t := hc.current()
+			if t == nil || t.red == nil {
+				next(w, r)
+				return
+			}
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next(recorder, r)
+			t.red.record(ctx, r.Method, metricRoute, recorder.status, time.Since(start))
 		}
 	}
 }
@@ -89,8 +101,8 @@ func (hc *TraceConfig) GinMiddleware(opts ...otelgin.Option) gin.HandlerFunc {
 		optList = append(optList, opts...)
 	}
 
-	optList = append(optList, otelgin.WithTracerProvider(otel.GetTracerProvider()))
-	optList = append(optList, otelgin.WithPropagators(otel.GetTextMapPropagator()))
+	optList = append(optList, otelgin.WithTracerProvider(hc.tracerProvider()))
+	optList = append(optList, otelgin.WithPropagators(hc.propagator()))
 	optList = append(optList, otelgin.WithSpanNameFormatter(func(c *gin.Context) string {
 		return defaultSpanNameFormatter(c.Request.Method, c.FullPath())
 	}))
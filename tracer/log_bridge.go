@@ -0,0 +1,66 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	logTraceIdKey  = "trace_id"
+	logSpanIdKey   = "span_id"
+	logTraceURLKey = "trace_url"
+	logEventName   = "log"
+	traceIdHolder  = "{traceId}"
+	levelError     = "error"
+)
+
+// LogConfig 日志与链路关联的配置
+type LogConfig struct {
+	MirrorErrors bool   `json:",optional"` // error级别的日志同时写入当前span的事件
+	TraceURL     string `json:",optional"` // 链路查询地址模板，{traceId} 会被替换，比如 http://jaeger:16686/trace/{traceId}
+}
+
+// traceURL 根据 traceId 生成可以跳转到链路系统的地址，未配置模板时返回空
+func (hc *TraceConfig) traceURL(traceId string) string {
+	if hc.Log == nil || hc.Log.TraceURL == "" || traceId == "" {
+		return ""
+	}
+	return strings.ReplaceAll(hc.Log.TraceURL, traceIdHolder, traceId)
+}
+
+func (hc *TraceConfig) mirrorErrors() bool {
+	return hc.Log != nil && hc.Log.MirrorErrors
+}
+
+// logTraceFields 从ctx中取出 trace_id/span_id，以及可选的 trace_url
+func (hc *TraceConfig) logTraceFields(ctx context.Context) map[string]string {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return nil
+	}
+	fields := map[string]string{
+		logTraceIdKey: spanCtx.TraceID().String(),
+		logSpanIdKey:  spanCtx.SpanID().String(),
+	}
+	if url := hc.traceURL(spanCtx.TraceID().String()); url != "" {
+		fields[logTraceURLKey] = url
+	}
+	return fields
+}
+
+// mirrorLog 把error日志作为事件写入ctx中的span
+func (hc *TraceConfig) mirrorLog(ctx context.Context, level string, msg any, attrs ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	attrs = append([]attribute.KeyValue{
+		attribute.String("log.severity", level),
+		attribute.String("log.message", fmt.Sprint(msg)),
+	}, attrs...)
+	span.AddEvent(logEventName, trace.WithAttributes(attrs...))
+}
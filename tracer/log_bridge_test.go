@@ -0,0 +1,125 @@
+package tracer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSlogHandler(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := newFileConfig(t, "svc-log")
+	cfg.SpanProcessors = []sdktrace.SpanProcessor{sdktrace.NewSimpleSpanProcessor(exporter)}
+	cfg.Log = &LogConfig{
+		MirrorErrors: true,
+		TraceURL:     "http://jaeger:16686/trace/{traceId}",
+	}
+	defer cfg.Stop()
+	if _, err := cfg.Tracer(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(cfg.SlogHandler(slog.NewJSONHandler(&buf, nil)))
+	ctx, span := cfg.StartSpan(context.Background(), "GET /users")
+	logger.InfoContext(ctx, "list users")
+	logger.ErrorContext(ctx, "query failed", "table", "user")
+	span.End()
+
+	traceId := span.SpanContext().TraceID().String()
+	out := buf.String()
+	for _, want := range []string{
+		`"trace_id":"` + traceId + `"`,
+		`"span_id":"` + span.SpanContext().SpanID().String() + `"`,
+		`"trace_url":"http://jaeger:16686/trace/` + traceId + `"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output missing %s: %s", want, out)
+		}
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	events := spans[0].Events
+	if len(events) != 1 || events[0].Name != logEventName {
+		t.Fatalf("events = %v, want a single log event", events)
+	}
+	attrs := map[string]string{}
+	for _, kv := range events[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["log.message"] != "query failed" || attrs["log.table"] != "user" {
+		t.Errorf("unexpected event attributes: %v", attrs)
+	}
+}
+
+type logxCollector struct {
+	fields [][]logx.LogField
+}
+
+func (c *logxCollector) Alert(any)                   {}
+func (c *logxCollector) Close() error                { return nil }
+func (c *logxCollector) Debug(any, ...logx.LogField) {}
+func (c *logxCollector) Error(any, ...logx.LogField) {}
+func (c *logxCollector) Severe(any)                  {}
+func (c *logxCollector) Slow(any, ...logx.LogField)  {}
+func (c *logxCollector) Stack(any)                   {}
+func (c *logxCollector) Stat(any, ...logx.LogField)  {}
+func (c *logxCollector) Info(_ any, fields ...logx.LogField) {
+	c.fields = append(c.fields, fields)
+}
+
+func TestLogxTraceURL(t *testing.T) {
+	cfg := newFileConfig(t, "svc-logx")
+	cfg.Log = &LogConfig{TraceURL: "http://jaeger:16686/trace/{traceId}"}
+	defer cfg.Stop()
+	if _, err := cfg.Tracer(); err != nil {
+		t.Fatal(err)
+	}
+	collector := &logxCollector{}
+	logx.SetWriter(collector)
+	defer logx.Reset()
+
+	ctx, span := cfg.StartSpan(context.Background(), "GET /users")
+	defer span.End()
+	ctx, child := cfg.StartSpan(ctx, "query users")
+	defer child.End()
+	logx.WithContext(ctx).Info("list users")
+
+	if len(collector.fields) != 1 {
+		t.Fatalf("got %d logs, want 1", len(collector.fields))
+	}
+	want := "http://jaeger:16686/trace/" + span.SpanContext().TraceID().String()
+	var urls []string
+	for _, field := range collector.fields[0] {
+		if field.Key == logTraceURLKey {
+			urls = append(urls, fmt.Sprint(field.Value))
+		}
+	}
+	// 子span不会重复写入 trace_url
+	if len(urls) != 1 || urls[0] != want {
+		t.Errorf("trace_url = %v, want [%s]", urls, want)
+	}
+}
+
+func TestSetLogxFieldKeys(t *testing.T) {
+	var c logx.LogConf
+	setLogxFieldKeys(&c)
+	if c.FieldKeys.TraceKey != logTraceIdKey || c.FieldKeys.SpanKey != logSpanIdKey {
+		t.Errorf("field keys = %s/%s, want %s/%s", c.FieldKeys.TraceKey, c.FieldKeys.SpanKey, logTraceIdKey, logSpanIdKey)
+	}
+	c.FieldKeys.TraceKey = "tid"
+	setLogxFieldKeys(&c)
+	if c.FieldKeys.TraceKey != "tid" {
+		t.Errorf("custom trace key = %s, want tid", c.FieldKeys.TraceKey)
+	}
+}
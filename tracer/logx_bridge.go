@@ -0,0 +1,172 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetupLogx 初始化 go-zero logx，把 trace/span 字段改名为 trace_id/span_id。
+// logx.SetUp 每个进程只生效一次，rest/zrpc 会用服务配置中的 Log 先初始化，
+// 所以使用 go-zero 服务时由 InitGoZeroTracing 修改服务配置中的字段名，这里的改名不再生效。
+// trace_url 在创建新链路时写入日志上下文，logc 和 logx.WithContext 输出的日志会自动带上，不依赖字段名
+func (hc *TraceConfig) SetupLogx(c logx.LogConf) error {
+	setLogxFieldKeys(&c)
+	return logx.SetUp(c)
+}
+
+// setLogxFieldKeys 未自定义字段名时把 trace/span 改名为 trace_id/span_id
+func setLogxFieldKeys(c *logx.LogConf) {
+	if c.FieldKeys.TraceKey == "" || c.FieldKeys.TraceKey == "trace" {
+		c.FieldKeys.TraceKey = logTraceIdKey
+	}
+	if c.FieldKeys.SpanKey == "" || c.FieldKeys.SpanKey == "span" {
+		c.FieldKeys.SpanKey = logSpanIdKey
+	}
+}
+
+// withLogTraceURL 新链路的根span（没有父span或父span来自上游）把 trace_url 写入日志上下文，
+// 子span沿用父级上下文中的字段，避免重复
+func (hc *TraceConfig) withLogTraceURL(ctx context.Context, parent, span trace.SpanContext) context.Context {
+	if parent.IsValid() && !parent.IsRemote() {
+		return ctx
+	}
+	if url := hc.traceURL(span.TraceID().String()); url != "" && span.IsValid() {
+		return logx.ContextWithFields(ctx, logx.Field(logTraceURLKey, url))
+	}
+	return ctx
+}
+
+// LogxWriter 包装 logx.Writer，日志字段中存在 traceKey 时追加 trace_url，traceKey 需要与 logx 实际使用的字段名一致
+func (hc *TraceConfig) LogxWriter(w logx.Writer, traceKey string) logx.Writer {
+	if hc.Log == nil || hc.Log.TraceURL == "" {
+		return w
+	}
+	return &logxTraceWriter{
+		Writer:   w,
+		hc:       hc,
+		traceKey: traceKey,
+	}
+}
+
+type logxTraceWriter struct {
+	logx.Writer
+	hc       *TraceConfig
+	traceKey string
+}
+
+func (w *logxTraceWriter) withURL(fields []logx.LogField) []logx.LogField {
+	for _, field := range fields {
+		if field.Key != w.traceKey {
+			continue
+		}
+		if url := w.hc.traceURL(fmt.Sprint(field.Value)); url != "" {
+			return append(fields, logx.Field(logTraceURLKey, url))
+		}
+	}
+	return fields
+}
+
+func (w *logxTraceWriter) Debug(v any, fields ...logx.LogField) {
+	w.Writer.Debug(v, w.withURL(fields)...)
+}
+
+func (w *logxTraceWriter) Error(v any, fields ...logx.LogField) {
+	w.Writer.Error(v, w.withURL(fields)...)
+}
+
+func (w *logxTraceWriter) Info(v any, fields ...logx.LogField) {
+	w.Writer.Info(v, w.withURL(fields)...)
+}
+
+func (w *logxTraceWriter) Slow(v any, fields ...logx.LogField) {
+	w.Writer.Slow(v, w.withURL(fields)...)
+}
+
+func (w *logxTraceWriter) Stat(v any, fields ...logx.LogField) {
+	w.Writer.Stat(v, w.withURL(fields)...)
+}
+
+// Logger 返回带链路信息的 logx.Logger，开启 MirrorErrors 时error日志会同时写入当前span的事件
+func (hc *TraceConfig) Logger(ctx context.Context) logx.Logger {
+	return &traceLogger{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		hc:     hc,
+	}
+}
+
+type traceLogger struct {
+	logx.Logger
+	ctx context.Context
+	hc  *TraceConfig
+}
+
+func (l *traceLogger) mirror(msg any, fields ...logx.LogField) {
+	if !l.hc.mirrorErrors() {
+		return
+	}
+	attrs := make([]attribute.KeyValue, 0, len(fields))
+	for _, field := range fields {
+		attrs = append(attrs, attribute.String("log."+field.Key, fmt.Sprint(field.Value)))
+	}
+	l.hc.mirrorLog(l.ctx, levelError, msg, attrs...)
+}
+
+func (l *traceLogger) Error(v ...any) {
+	l.mirror(fmt.Sprint(v...))
+	l.Logger.WithCallerSkip(1).Error(v...)
+}
+
+func (l *traceLogger) Errorf(format string, v ...any) {
+	l.mirror(fmt.Sprintf(format, v...))
+	l.Logger.WithCallerSkip(1).Errorf(format, v...)
+}
+
+func (l *traceLogger) Errorfn(fn func() any) {
+	msg := fn()
+	l.mirror(msg)
+	l.Logger.WithCallerSkip(1).Errorv(msg)
+}
+
+func (l *traceLogger) Errorv(v any) {
+	l.mirror(v)
+	l.Logger.WithCallerSkip(1).Errorv(v)
+}
+
+func (l *traceLogger) Errorw(msg string, fields ...logx.LogField) {
+	l.mirror(msg, fields...)
+	l.Logger.WithCallerSkip(1).Errorw(msg, fields...)
+}
+
+func (l *traceLogger) wrap(logger logx.Logger) logx.Logger {
+	return &traceLogger{
+		Logger: logger,
+		ctx:    l.ctx,
+		hc:     l.hc,
+	}
+}
+
+func (l *traceLogger) WithCallerSkip(skip int) logx.Logger {
+	return l.wrap(l.Logger.WithCallerSkip(skip))
+}
+
+func (l *traceLogger) WithContext(ctx context.Context) logx.Logger {
+	return &traceLogger{
+		Logger: l.Logger.WithContext(ctx),
+		ctx:    ctx,
+		hc:     l.hc,
+	}
+}
+
+func (l *traceLogger) WithDuration(d time.Duration) logx.Logger {
+	return l.wrap(l.Logger.WithDuration(d))
+}
+
+func (l *traceLogger) WithFields(fields ...logx.LogField) logx.Logger {
+	return l.wrap(l.Logger.WithFields(fields...))
+}
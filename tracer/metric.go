@@ -0,0 +1,145 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const defaultMetricInterval = 15 * time.Second
+
+// MetricConfig 指标上报配置，与 TraceConfig 共用服务名和资源属性
+type MetricConfig struct {
+	Exporter string        `json:",default=otlpgrpc,options=otlpgrpc|otlphttp|file"`
+	Endpoint string        `json:",optional"` // 为空时使用 TraceConfig.Endpoint
+	Interval time.Duration `json:",optional"` // 上报间隔，默认15s
+}
+
+// redMetrics 请求的 RED (Rate/Errors/Duration) 指标
+type redMetrics struct {
+	requests metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newRedMetrics(meter metric.Meter) (*redMetrics, error) {
+	requests, err := meter.Int64Counter("http.server.request.count",
+		metric.WithDescription("number of http requests"))
+	if err != nil {
+		return nil, err
+	}
+	errCounter, err := meter.Int64Counter("http.server.error.count",
+		metric.WithDescription("number of http requests with 5xx status"))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("duration of http requests"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	return &redMetrics{
+		requests: requests,
+		errors:   errCounter,
+		duration: duration,
+	}, nil
+}
+
+func (m *redMetrics) record(ctx context.Context, method, route string, status int, cost time.Duration) {
+	if m == nil {
+		return
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("http.route", route),
+		attribute.Int("http.response.status_code", status),
+	)
+	m.requests.Add(ctx, 1, attrs)
+	if status >= http.StatusInternalServerError {
+		m.errors.Add(ctx, 1, attrs)
+	}
+	m.duration.Record(ctx, cost.Seconds(), attrs)
+}
+
+// statusRecorder 记录响应状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (hc *TraceConfig) createMetricExporter() (sdkmetric.Exporter, error) {
+	mc := hc.Metric
+	endpoint := mc.Endpoint
+	if endpoint == "" {
+		endpoint = hc.Endpoint
+	}
+	switch mc.Exporter {
+	case "", kindOtlpGrpc:
+		opts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithInsecure(),
+			otlpmetricgrpc.WithEndpoint(endpoint),
+		}
+		if len(hc.OtlpHeaders) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(hc.OtlpHeaders))
+		}
+		return otlpmetricgrpc.New(context.Background(), opts...)
+	case kindOtlpHttp:
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(endpoint),
+		}
+		if !hc.OtlpHttpSecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		if len(hc.OtlpHeaders) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(hc.OtlpHeaders))
+		}
+		return otlpmetrichttp.New(context.Background(), opts...)
+	case kindFile:
+		f, err := os.OpenFile(endpoint, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return nil, fmt.Errorf("file metric exporter endpoint error: %s", err.Error())
+		}
+		return stdoutmetric.New(stdoutmetric.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown metric exporter: %s", mc.Exporter)
+	}
+}
+
+// newMeterProvider 按 Metric 配置创建 MeterProvider，未配置时返回nil
+func (hc *TraceConfig) newMeterProvider(res *resource.Resource) (*sdkmetric.MeterProvider, error) {
+	if hc.Metric == nil {
+		return nil, nil
+	}
+	exporter, err := hc.createMetricExporter()
+	if err != nil {
+		return nil, err
+	}
+	interval := hc.Metric.Interval
+	if interval <= 0 {
+		interval = defaultMetricInterval
+	}
+	return sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))),
+		sdkmetric.WithResource(res),
+	), nil
+}
@@ -0,0 +1,64 @@
+package tracer
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/stores/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const dbTableAttributeKey = attribute.Key("db.sql.table")
+
+// ModelConfig goctl 生成的 model 的观测配置，零值表示全部开启
+// 耗时、错误、慢查询指标和慢查询日志由 go-zero sqlx 统一上报，这里不再重复统计
+type ModelConfig struct {
+	DisableTrace  bool          `json:",optional"` //不生成每条语句的span
+	SlowThreshold time.Duration `json:",optional"` //span标记慢查询的阈值，默认使用 TraceConfig.SlowQuery
+}
+
+// TraceModelConn 包装 goctl 生成的 model 使用的 sqlx.SqlConn：每条语句一个带脱敏sql和表名的span。
+// 配置在每次调用时从默认 Tracer 读取，model 可以在 Tracer 初始化之前创建，dbSystem 如 mysql、postgresql
+func TraceModelConn(conn sqlx.SqlConn, dbSystem, table string) sqlx.SqlConn {
+	if conn == nil {
+		return conn
+	}
+	if _, ok := conn.(*tracedSqlConn); ok {
+		return conn
+	}
+	return newTracedSqlConn(conn, &modelObserver{
+		system: dbSystem,
+		table:  table,
+	})
+}
+
+type modelObserver struct {
+	system string
+	table  string
+}
+
+func (o *modelObserver) observe(ctx context.Context, operation, statement string, fn func(ctx context.Context) error) error {
+	hc := GetTraceConfig()
+	var mc ModelConfig
+	if hc.Model != nil {
+		mc = *hc.Model
+	}
+	if mc.DisableTrace {
+		return fn(ctx)
+	}
+
+	threshold := mc.SlowThreshold
+	if threshold <= 0 {
+		threshold = hc.slowQueryThreshold()
+	}
+	spanCtx, span := hc.startStoreSpan(ctx, o.system, operation, statement)
+	span.SetAttributes(dbTableAttributeKey.String(o.table))
+	start := time.Now()
+	err := fn(spanCtx)
+	ignore := isNoRows
+	if operation == sqlTransaction {
+		ignore = nil
+	}
+	finishStoreSpan(span, time.Since(start), threshold, err, ignore)
+	return err
+}
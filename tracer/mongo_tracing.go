@@ -0,0 +1,85 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/stores/mon"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const mongoSystem = "mongodb"
+
+var mongoCollectionKey = attribute.Key("db.mongodb.collection")
+
+// MongoOption 返回 go-zero mon 的埋点选项，例如 mon.MustNewModel(uri, db, collection, tc.MongoOption())
+// 通过 mongo 驱动的 CommandMonitor 实现，只记录命令名和集合名，不记录命令内容，配置不完整时返回空选项
+func (hc *TraceConfig) MongoOption() mon.Option {
+	monitor := newMongoMonitor(hc)
+	if monitor == nil {
+		return func(*options.ClientOptions) {}
+	}
+	return func(opts *options.ClientOptions) {
+		opts.SetMonitor(&event.CommandMonitor{
+			Started:   monitor.started,
+			Succeeded: monitor.succeeded,
+			Failed:    monitor.failed,
+		})
+	}
+}
+
+type mongoSpan struct {
+	span  trace.Span
+	start time.Time
+}
+
+type mongoMonitor struct {
+	hc    *TraceConfig
+	spans sync.Map // connectionID/requestID -> *mongoSpan
+}
+
+// newMongoMonitor 创建时检查一次配置，配置不完整时返回nil
+func newMongoMonitor(hc *TraceConfig) *mongoMonitor {
+	if hc.checkConfig() != nil {
+		return nil
+	}
+	return &mongoMonitor{hc: hc}
+}
+
+func mongoSpanKey(connectionID string, requestID int64) string {
+	return fmt.Sprintf("%s/%d", connectionID, requestID)
+}
+
+func (m *mongoMonitor) started(ctx context.Context, evt *event.CommandStartedEvent) {
+	_, span := m.hc.startStoreSpan(ctx, mongoSystem, evt.CommandName, "")
+	span.SetAttributes(attribute.String("db.name", evt.DatabaseName))
+	if collection, ok := evt.Command.Lookup(evt.CommandName).StringValueOK(); ok {
+		span.SetAttributes(mongoCollectionKey.String(collection))
+	}
+	m.spans.Store(mongoSpanKey(evt.ConnectionID, evt.RequestID), &mongoSpan{
+		span:  span,
+		start: time.Now(),
+	})
+}
+
+func (m *mongoMonitor) succeeded(_ context.Context, evt *event.CommandSucceededEvent) {
+	m.finish(evt.ConnectionID, evt.RequestID, nil)
+}
+
+func (m *mongoMonitor) failed(_ context.Context, evt *event.CommandFailedEvent) {
+	m.finish(evt.ConnectionID, evt.RequestID, evt.Failure)
+}
+
+func (m *mongoMonitor) finish(connectionID string, requestID int64, err error) {
+	v, ok := m.spans.LoadAndDelete(mongoSpanKey(connectionID, requestID))
+	if !ok {
+		return
+	}
+	ms := v.(*mongoSpan)
+	m.hc.endStoreSpan(ms.span, ms.start, err, nil)
+}
@@ -0,0 +1,131 @@
+package tracer
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactKeys 默认需要脱敏的属性，支持 path.Match 通配符，大小写不敏感
+var DefaultRedactKeys = []string{
+	"http.request.header.authorization",
+	"http.request.header.cookie",
+	"http.request.header.x-api-key",
+	"http.response.header.set-cookie",
+	"*password*",
+	"*secret*",
+}
+
+// SpanFilter 在span导出前对其进行处理，返回nil表示不导出
+type SpanFilter func(s sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan
+
+// filteredSpan 覆盖原始span的部分只读字段
+type filteredSpan struct {
+	sdktrace.ReadOnlySpan
+	spanContext trace.SpanContext
+	attributes  []attribute.KeyValue
+}
+
+func (s *filteredSpan) SpanContext() trace.SpanContext {
+	return s.spanContext
+}
+
+func (s *filteredSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
+
+// filterProcessor 依次执行 SpanFilter 后再交给下一个处理器导出
+type filterProcessor struct {
+	next    sdktrace.SpanProcessor
+	filters []SpanFilter
+}
+
+// NewFilterProcessor 包装导出用的 SpanProcessor，在导出前执行 filters
+func NewFilterProcessor(next sdktrace.SpanProcessor, filters ...SpanFilter) sdktrace.SpanProcessor {
+	if len(filters) == 0 {
+		return next
+	}
+	return &filterProcessor{
+		next:    next,
+		filters: filters,
+	}
+}
+
+func (p *filterProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *filterProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for _, filter := range p.filters {
+		if s = filter(s); s == nil {
+			return
+		}
+	}
+	p.next.OnEnd(s)
+}
+
+func (p *filterProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *filterProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// RedactFilter 将匹配 keys 的属性值替换为 [REDACTED]，用于去除header等中的敏感信息
+func RedactFilter(keys ...string) SpanFilter {
+	patterns := make([]string, 0, len(keys))
+	for _, key := range keys {
+		patterns = append(patterns, strings.ToLower(key))
+	}
+	matchKey := func(key string) bool {
+		key = strings.ToLower(key)
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, key); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(s sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+		attrs := s.Attributes()
+		var redacted []attribute.KeyValue
+		for i, kv := range attrs {
+			if !matchKey(string(kv.Key)) {
+				continue
+			}
+			if redacted == nil {
+				redacted = make([]attribute.KeyValue, len(attrs))
+				copy(redacted, attrs)
+			}
+			redacted[i] = kv.Key.String(redactedValue)
+		}
+		if redacted == nil {
+			return s
+		}
+		return &filteredSpan{
+			ReadOnlySpan: s,
+			spanContext:  s.SpanContext(),
+			attributes:   redacted,
+		}
+	}
+}
+
+// buildSpanFilters 根据配置生成导出前的 SpanFilter 列表
+func (hc *TraceConfig) buildSpanFilters() []SpanFilter {
+	filters := make([]SpanFilter, 0)
+	if hc.Sampler.tailEnabled() {
+		filters = append(filters, tailSampleFilter(hc.Sampler))
+	}
+	if len(hc.RedactKeys) > 0 {
+		filters = append(filters, RedactFilter(hc.RedactKeys...))
+	}
+	return append(filters, hc.SpanFilters...)
+}
@@ -3,7 +3,6 @@ package tracer
 import (
 	"context"
 	"github.com/streadway/amqp"
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -35,12 +34,12 @@ func (c amqpHeadersCarrier) Keys() []string {
 var _ propagation.TextMapCarrier = amqpHeadersCarrier(nil)
 
 func (hc *TraceConfig) RabbitMQPublishTable(ctx context.Context, headers map[string]any) amqp.Table {
-	otel.GetTextMapPropagator().Inject(ctx, amqpHeadersCarrier(headers))
+	hc.propagator().Inject(ctx, amqpHeadersCarrier(headers))
 	return headers
 }
 
 func (hc *TraceConfig) RabbitMQConsumer(ctx context.Context, headers amqp.Table) context.Context {
-	ctx = otel.GetTextMapPropagator().Extract(ctx, amqpHeadersCarrier(headers))
+	ctx = hc.propagator().Extract(ctx, amqpHeadersCarrier(headers))
 	spanContext := trace.SpanContextFromContext(ctx)
 	newCtx := trace.ContextWithSpanContext(ctx, spanContext)
 	return newCtx
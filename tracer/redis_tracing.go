@@ -0,0 +1,84 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	red "github.com/redis/go-redis/v9"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+const redisSystem = "redis"
+
+// RedisOption 返回 go-zero redis 的埋点选项，例如 redis.MustNewRedis(conf, tc.RedisOption())
+// 配置不完整时返回空选项，不埋点
+func (hc *TraceConfig) RedisOption() redis.Option {
+	hook := newRedisTraceHook(hc)
+	if hook == nil {
+		return func(*redis.Redis) {}
+	}
+	return redis.WithHook(hook)
+}
+
+// newRedisTraceHook 创建时检查一次配置，配置不完整时返回nil
+func newRedisTraceHook(hc *TraceConfig) *redisTraceHook {
+	if hc.checkConfig() != nil {
+		return nil
+	}
+	return &redisTraceHook{hc: hc}
+}
+
+// redisTraceHook 为每个命令生成span，db.statement 只记录命令和key，不记录参数值
+type redisTraceHook struct {
+	hc *TraceConfig
+}
+
+func (h *redisTraceHook) DialHook(next red.DialHook) red.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *redisTraceHook) ProcessHook(next red.ProcessHook) red.ProcessHook {
+	return func(ctx context.Context, cmd red.Cmder) error {
+		ctx, span := h.hc.startStoreSpan(ctx, redisSystem, strings.ToUpper(cmd.Name()), redisStatement(cmd))
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.hc.endStoreSpan(span, start, err, isRedisNil)
+		return err
+	}
+}
+
+func (h *redisTraceHook) ProcessPipelineHook(next red.ProcessPipelineHook) red.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []red.Cmder) error {
+		statements := make([]string, 0, len(cmds))
+		for _, cmd := range cmds {
+			statements = append(statements, redisStatement(cmd))
+		}
+		ctx, span := h.hc.startStoreSpan(ctx, redisSystem, "PIPELINE", truncateStatement(strings.Join(statements, "\n")))
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.hc.endStoreSpan(span, start, err, isRedisNil)
+		return err
+	}
+}
+
+func isRedisNil(err error) bool {
+	return errors.Is(err, red.Nil)
+}
+
+// redisStatement 命令名加第一个参数（一般为key）
+func redisStatement(cmd red.Cmder) string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return strings.ToUpper(cmd.Name())
+	}
+	key, ok := args[1].(string)
+	if !ok {
+		return strings.ToUpper(cmd.Name())
+	}
+	return strings.ToUpper(cmd.Name()) + " " + key
+}
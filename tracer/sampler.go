@@ -0,0 +1,199 @@
+package tracer
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SamplerConfig 采样策略配置，为空时沿用 SamplerPercent 的全局比例采样
+type SamplerConfig struct {
+	Ratio              *float64      `json:",optional"` // 基础采样比例 0~1，设置后覆盖 SamplerPercent，可以设为0
+	ParentBased        bool          `json:",optional"` // 是否遵循上游服务的采样决定
+	Rules              []SamplerRule `json:",optional"` // 按span名称或路由的规则，按顺序匹配，先匹配先生效
+	RateLimit          float64       `json:",optional"` // 每秒最多采样的根span数量，0表示不限制
+	AlwaysSampleErrors bool          `json:",optional"` // 出错的span始终上报
+	SlowThreshold      time.Duration `json:",optional"` // 耗时超过该值的span始终上报，0表示不启用
+}
+
+// SamplerRule 单条采样规则，SpanName 与 Route 至少填写一个
+type SamplerRule struct {
+	SpanName string  `json:",optional"` // span名称，支持 path.Match 通配符，比如 "GET /api/*"
+	Route    string  `json:",optional"` // http路由，比如 /health，与span名称中的路径或 url.path 属性比较
+	Ratio    float64 `json:",optional"` // 命中时的采样比例，0表示不采样，1表示全部采样
+}
+
+func (r *SamplerRule) match(p sdktrace.SamplingParameters) bool {
+	if r.SpanName != "" {
+		if ok, _ := path.Match(r.SpanName, p.Name); ok {
+			return true
+		}
+	}
+	if r.Route == "" {
+		return false
+	}
+	if p.Name == r.Route || strings.HasSuffix(p.Name, " "+r.Route) {
+		return true
+	}
+	for _, kv := range p.Attributes {
+		if kv.Key == semconv.URLPathKey || kv.Key == semconv.HTTPRouteKey {
+			if kv.Value.AsString() == r.Route {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// samplerRatio 实际生效的基础采样比例
+func (hc *TraceConfig) samplerRatio() float64 {
+	if hc.Sampler != nil && hc.Sampler.Ratio != nil {
+		return math.Min(math.Max(*hc.Sampler.Ratio, 0), 1)
+	}
+	return hc.getSampler()
+}
+
+// tailEnabled 是否需要在span结束时根据错误和耗时补充上报
+func (sc *SamplerConfig) tailEnabled() bool {
+	return sc != nil && (sc.AlwaysSampleErrors || sc.SlowThreshold > 0)
+}
+
+// buildSampler 根据配置组合出最终的采样器
+func (hc *TraceConfig) buildSampler() sdktrace.Sampler {
+	sc := hc.Sampler
+	var sampler sdktrace.Sampler = sdktrace.TraceIDRatioBased(hc.samplerRatio())
+	if sc == nil {
+		return sampler
+	}
+
+	sampler = &ruleSampler{
+		rules:    sc.Rules,
+		fallback: sampler,
+	}
+	if sc.RateLimit > 0 {
+		sampler = &rateLimitSampler{
+			next:   sampler,
+			bucket: newTokenBucket(sc.RateLimit),
+		}
+	}
+	if sc.ParentBased {
+		sampler = sdktrace.ParentBased(sampler)
+	}
+	if sc.tailEnabled() {
+		// 未采样的span也需要记录，结束时再由 tailSampleFilter 判断是否上报
+		sampler = sdktrace.AlwaysRecord(sampler)
+	}
+	return sampler
+}
+
+// ruleSampler 按规则采样，未命中时交给 fallback
+type ruleSampler struct {
+	rules    []SamplerRule
+	fallback sdktrace.Sampler
+}
+
+func (s *ruleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for i := range s.rules {
+		if s.rules[i].match(p) {
+			return sdktrace.TraceIDRatioBased(s.rules[i].Ratio).ShouldSample(p)
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *ruleSampler) Description() string {
+	return fmt.Sprintf("RuleSampler{rules:%d,fallback:%s}", len(s.rules), s.fallback.Description())
+}
+
+// rateLimitSampler 限制每秒采样的根span数量，子span跟随父span
+type rateLimitSampler struct {
+	next   sdktrace.Sampler
+	bucket *tokenBucket
+}
+
+func (s *rateLimitSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.next.ShouldSample(p)
+	if result.Decision != sdktrace.RecordAndSample {
+		return result
+	}
+	if trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return result
+	}
+	if !s.bucket.take() {
+		result.Decision = sdktrace.Drop
+	}
+	return result
+}
+
+func (s *rateLimitSampler) Description() string {
+	return fmt.Sprintf("RateLimitSampler{%g/s,%s}", s.bucket.rate, s.next.Description())
+}
+
+// tokenBucket 简单的令牌桶，容量为一秒的令牌数，最少为1
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	capacity := math.Max(rate, 1)
+	return &tokenBucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     time.Now(),
+	}
+}
+
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// tailSampleFilter 对未被采样但出错或慢的span补充上报
+func tailSampleFilter(sc *SamplerConfig) SpanFilter {
+	return func(s sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+		if s.SpanContext().IsSampled() {
+			return s
+		}
+		keep := sc.AlwaysSampleErrors && s.Status().Code == codes.Error
+		if !keep && sc.SlowThreshold > 0 {
+			keep = s.EndTime().Sub(s.StartTime()) >= sc.SlowThreshold
+		}
+		if !keep {
+			return nil
+		}
+		// 复制一份再追加，避免修改span自身的属性切片
+		attrs := make([]attribute.KeyValue, 0, len(s.Attributes())+1)
+		attrs = append(attrs, s.Attributes()...)
+		return &filteredSpan{
+			ReadOnlySpan: s,
+			spanContext:  s.SpanContext().WithTraceFlags(s.SpanContext().TraceFlags().WithSampled(true)),
+			attributes:   append(attrs, attribute.Bool("sampling.tail", true)),
+		}
+	}
+}
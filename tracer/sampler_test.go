@@ -0,0 +1,160 @@
+package tracer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRuleSampler(t *testing.T) {
+	ratio := 1.0
+	hc := &TraceConfig{
+		Sampler: &SamplerConfig{
+			Ratio: &ratio,
+			Rules: []SamplerRule{
+				{Route: "/health", Ratio: 0},
+			},
+		},
+	}
+	sampler := hc.buildSampler()
+
+	health := sampler.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: context.Background(),
+		Name:          "GET /health",
+	})
+	if health.Decision != sdktrace.Drop {
+		t.Errorf("/health decision = %v, want Drop", health.Decision)
+	}
+
+	other := sampler.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: context.Background(),
+		Name:          "GET /users",
+	})
+	if other.Decision != sdktrace.RecordAndSample {
+		t.Errorf("/users decision = %v, want RecordAndSample", other.Decision)
+	}
+}
+
+func TestZeroRatio(t *testing.T) {
+	ratio := 0.0
+	percent := 80
+	hc := &TraceConfig{SamplerPercent: &percent, Sampler: &SamplerConfig{Ratio: &ratio}}
+	if got := hc.samplerRatio(); got != 0 {
+		t.Errorf("samplerRatio() = %v, want 0", got)
+	}
+}
+
+func TestSamplerPercent(t *testing.T) {
+	percent := func(v int) *int {
+		return &v
+	}
+	cases := []struct {
+		percent *int
+		want    float64
+	}{
+		{nil, 0.5},
+		{percent(0), 0},
+		{percent(30), 0.3},
+		{percent(150), 1},
+		{percent(-1), 0},
+	}
+	for _, c := range cases {
+		hc := &TraceConfig{SamplerPercent: c.percent}
+		if got := hc.getSampler(); got != c.want {
+			t.Errorf("getSampler() of %v = %v, want %v", c.percent, got, c.want)
+		}
+	}
+}
+
+func TestTailSampleFilter(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	ratio := 0.0
+	sc := &SamplerConfig{Ratio: &ratio, AlwaysSampleErrors: true, SlowThreshold: 20 * time.Millisecond}
+	hc := &TraceConfig{Sampler: sc}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(hc.buildSampler()),
+		sdktrace.WithSpanProcessor(NewFilterProcessor(sdktrace.NewSimpleSpanProcessor(exporter), hc.buildSpanFilters()...)),
+	)
+	tracer := tp.Tracer("test")
+
+	_, fast := tracer.Start(context.Background(), "fast")
+	fast.End()
+
+	_, failed := tracer.Start(context.Background(), "failed")
+	SetErrorTag(failed, context.DeadlineExceeded)
+	failed.End()
+
+	_, slow := tracer.Start(context.Background(), "slow")
+	time.Sleep(25 * time.Millisecond)
+	slow.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	for _, s := range spans {
+		if s.Name == "fast" {
+			t.Errorf("fast span should not be exported")
+		}
+		if !s.SpanContext.IsSampled() {
+			t.Errorf("span %s should be marked sampled", s.Name)
+		}
+	}
+}
+
+func TestTailSampleFilterCopyAttributes(t *testing.T) {
+	attrs := make([]attribute.KeyValue, 1, 2)
+	attrs[0] = attribute.String("url.path", "/users")
+	stub := tracetest.SpanStub{
+		Name:       "failed",
+		Attributes: attrs,
+		Status:     sdktrace.Status{Code: codes.Error},
+	}
+	filtered := tailSampleFilter(&SamplerConfig{AlwaysSampleErrors: true})(stub.Snapshot())
+	if filtered == nil {
+		t.Fatal("failed span should be kept")
+	}
+	if len(filtered.Attributes()) != 2 {
+		t.Errorf("got %d attributes, want 2", len(filtered.Attributes()))
+	}
+	// span 自身的属性切片不能被修改
+	if extra := attrs[:2][1]; extra.Key != "" {
+		t.Errorf("span attributes modified: %v", extra)
+	}
+}
+
+func TestRedactFilter(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(NewFilterProcessor(sdktrace.NewSimpleSpanProcessor(exporter), RedactFilter(DefaultRedactKeys...))),
+	)
+	_, span := tp.Tracer("test").Start(context.Background(), "redact")
+	span.SetAttributes(
+		attribute.String("http.request.header.Authorization", "Bearer abc"),
+		attribute.String("user.password", "123456"),
+		attribute.String("url.path", "/login"),
+	)
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	for _, kv := range spans[0].Attributes {
+		switch kv.Key {
+		case "http.request.header.Authorization", "user.password":
+			if kv.Value.AsString() != redactedValue {
+				t.Errorf("%s = %q, want redacted", kv.Key, kv.Value.AsString())
+			}
+		case "url.path":
+			if kv.Value.AsString() != "/login" {
+				t.Errorf("url.path = %q, want /login", kv.Value.AsString())
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tracer
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SlogHandler 包装 slog.Handler，自动写入 trace_id/span_id/trace_url，
+// 开启 MirrorErrors 时error级别的日志同时写入当前span的事件
+func (hc *TraceConfig) SlogHandler(next slog.Handler) slog.Handler {
+	return &slogTraceHandler{
+		next: next,
+		hc:   hc,
+	}
+}
+
+type slogTraceHandler struct {
+	next slog.Handler
+	hc   *TraceConfig
+}
+
+func (h *slogTraceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *slogTraceHandler) Handle(ctx context.Context, r slog.Record) error {
+	for key, value := range h.hc.logTraceFields(ctx) {
+		r.AddAttrs(slog.String(key, value))
+	}
+	if r.Level >= slog.LevelError && h.hc.mirrorErrors() {
+		attrs := make([]attribute.KeyValue, 0, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = append(attrs, attribute.String("log."+a.Key, a.Value.String()))
+			return true
+		})
+		h.hc.mirrorLog(ctx, levelError, r.Message, attrs...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *slogTraceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &slogTraceHandler{
+		next: h.next.WithAttrs(attrs),
+		hc:   h.hc,
+	}
+}
+
+func (h *slogTraceHandler) WithGroup(name string) slog.Handler {
+	return &slogTraceHandler{
+		next: h.next.WithGroup(name),
+		hc:   h.hc,
+	}
+}
@@ -0,0 +1,270 @@
+package tracer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/stores/sqlx"
+)
+
+// TraceSqlConn 包装 go-zero 的 sqlx.SqlConn，每条语句生成一个带 db.system/db.operation/db.statement 的span，
+// dbSystem 如 mysql、postgresql
+func (hc *TraceConfig) TraceSqlConn(conn sqlx.SqlConn, dbSystem string) sqlx.SqlConn {
+	if conn == nil || hc.checkConfig() != nil {
+		return conn
+	}
+	return newTracedSqlConn(conn, &storeObserver{
+		hc:     hc,
+		system: dbSystem,
+	})
+}
+
+func newTracedSqlConn(conn sqlx.SqlConn, observer sqlObserver) sqlx.SqlConn {
+	return &tracedSqlConn{
+		SqlConn: conn,
+		session: &tracedSession{
+			session:  conn,
+			observer: observer,
+		},
+	}
+}
+
+const sqlTransaction = "TRANSACTION"
+
+// sqlObserver 观测每次sql调用，operation 为sql的第一个关键字或 TRANSACTION，statement 为脱敏后的sql
+type sqlObserver interface {
+	observe(ctx context.Context, operation, statement string, fn func(ctx context.Context) error) error
+}
+
+// storeObserver 为每次调用生成span
+type storeObserver struct {
+	hc     *TraceConfig
+	system string
+}
+
+func (o *storeObserver) observe(ctx context.Context, operation, statement string, fn func(ctx context.Context) error) error {
+	ctx, span := o.hc.startStoreSpan(ctx, o.system, operation, statement)
+	start := time.Now()
+	err := fn(ctx)
+	ignore := isNoRows
+	if operation == sqlTransaction {
+		ignore = nil
+	}
+	o.hc.endStoreSpan(span, start, err, ignore)
+	return err
+}
+
+func isNoRows(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
+
+type tracedSqlConn struct {
+	sqlx.SqlConn
+	session *tracedSession
+}
+
+func (c *tracedSqlConn) Exec(query string, args ...any) (sql.Result, error) {
+	return c.session.ExecCtx(context.Background(), query, args...)
+}
+
+func (c *tracedSqlConn) ExecCtx(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.session.ExecCtx(ctx, query, args...)
+}
+
+func (c *tracedSqlConn) Prepare(query string) (sqlx.StmtSession, error) {
+	return c.session.PrepareCtx(context.Background(), query)
+}
+
+func (c *tracedSqlConn) PrepareCtx(ctx context.Context, query string) (sqlx.StmtSession, error) {
+	return c.session.PrepareCtx(ctx, query)
+}
+
+func (c *tracedSqlConn) QueryRow(v any, query string, args ...any) error {
+	return c.session.QueryRowCtx(context.Background(), v, query, args...)
+}
+
+func (c *tracedSqlConn) QueryRowCtx(ctx context.Context, v any, query string, args ...any) error {
+	return c.session.QueryRowCtx(ctx, v, query, args...)
+}
+
+func (c *tracedSqlConn) QueryRowPartial(v any, query string, args ...any) error {
+	return c.session.QueryRowPartialCtx(context.Background(), v, query, args...)
+}
+
+func (c *tracedSqlConn) QueryRowPartialCtx(ctx context.Context, v any, query string, args ...any) error {
+	return c.session.QueryRowPartialCtx(ctx, v, query, args...)
+}
+
+func (c *tracedSqlConn) QueryRows(v any, query string, args ...any) error {
+	return c.session.QueryRowsCtx(context.Background(), v, query, args...)
+}
+
+func (c *tracedSqlConn) QueryRowsCtx(ctx context.Context, v any, query string, args ...any) error {
+	return c.session.QueryRowsCtx(ctx, v, query, args...)
+}
+
+func (c *tracedSqlConn) QueryRowsPartial(v any, query string, args ...any) error {
+	return c.session.QueryRowsPartialCtx(context.Background(), v, query, args...)
+}
+
+func (c *tracedSqlConn) QueryRowsPartialCtx(ctx context.Context, v any, query string, args ...any) error {
+	return c.session.QueryRowsPartialCtx(ctx, v, query, args...)
+}
+
+func (c *tracedSqlConn) Transact(fn func(sqlx.Session) error) error {
+	return c.TransactCtx(context.Background(), func(_ context.Context, session sqlx.Session) error {
+		return fn(session)
+	})
+}
+
+func (c *tracedSqlConn) TransactCtx(ctx context.Context, fn func(context.Context, sqlx.Session) error) error {
+	observer := c.session.observer
+	return observer.observe(ctx, sqlTransaction, "", func(ctx context.Context) error {
+		return c.SqlConn.TransactCtx(ctx, func(ctx context.Context, session sqlx.Session) error {
+			return fn(ctx, &tracedSession{
+				session:  session,
+				observer: observer,
+			})
+		})
+	})
+}
+
+// tracedSession 观测 sqlx.Session 的每次调用，事务内的语句作为事务span的子span
+type tracedSession struct {
+	session  sqlx.Session
+	observer sqlObserver
+}
+
+func (s *tracedSession) trace(ctx context.Context, query string, fn func(ctx context.Context) error) error {
+	statement := SanitizeSQL(query)
+	return s.observer.observe(ctx, sqlOperation(statement), statement, fn)
+}
+
+func (s *tracedSession) Exec(query string, args ...any) (sql.Result, error) {
+	return s.ExecCtx(context.Background(), query, args...)
+}
+
+func (s *tracedSession) ExecCtx(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
+	err = s.trace(ctx, query, func(ctx context.Context) error {
+		result, err = s.session.ExecCtx(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func (s *tracedSession) Prepare(query string) (sqlx.StmtSession, error) {
+	return s.PrepareCtx(context.Background(), query)
+}
+
+func (s *tracedSession) PrepareCtx(ctx context.Context, query string) (sqlx.StmtSession, error) {
+	stmt, err := s.session.PrepareCtx(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{
+		StmtSession: stmt,
+		session:     s,
+		query:       query,
+	}, nil
+}
+
+func (s *tracedSession) QueryRow(v any, query string, args ...any) error {
+	return s.QueryRowCtx(context.Background(), v, query, args...)
+}
+
+func (s *tracedSession) QueryRowCtx(ctx context.Context, v any, query string, args ...any) error {
+	return s.trace(ctx, query, func(ctx context.Context) error {
+		return s.session.QueryRowCtx(ctx, v, query, args...)
+	})
+}
+
+func (s *tracedSession) QueryRowPartial(v any, query string, args ...any) error {
+	return s.QueryRowPartialCtx(context.Background(), v, query, args...)
+}
+
+func (s *tracedSession) QueryRowPartialCtx(ctx context.Context, v any, query string, args ...any) error {
+	return s.trace(ctx, query, func(ctx context.Context) error {
+		return s.session.QueryRowPartialCtx(ctx, v, query, args...)
+	})
+}
+
+func (s *tracedSession) QueryRows(v any, query string, args ...any) error {
+	return s.QueryRowsCtx(context.Background(), v, query, args...)
+}
+
+func (s *tracedSession) QueryRowsCtx(ctx context.Context, v any, query string, args ...any) error {
+	return s.trace(ctx, query, func(ctx context.Context) error {
+		return s.session.QueryRowsCtx(ctx, v, query, args...)
+	})
+}
+
+func (s *tracedSession) QueryRowsPartial(v any, query string, args ...any) error {
+	return s.QueryRowsPartialCtx(context.Background(), v, query, args...)
+}
+
+func (s *tracedSession) QueryRowsPartialCtx(ctx context.Context, v any, query string, args ...any) error {
+	return s.trace(ctx, query, func(ctx context.Context) error {
+		return s.session.QueryRowsPartialCtx(ctx, v, query, args...)
+	})
+}
+
+// tracedStmt 预编译语句，执行时使用 Prepare 时的sql生成span
+type tracedStmt struct {
+	sqlx.StmtSession
+	session *tracedSession
+	query   string
+}
+
+func (s *tracedStmt) Exec(args ...any) (sql.Result, error) {
+	return s.ExecCtx(context.Background(), args...)
+}
+
+func (s *tracedStmt) ExecCtx(ctx context.Context, args ...any) (result sql.Result, err error) {
+	err = s.session.trace(ctx, s.query, func(ctx context.Context) error {
+		result, err = s.StmtSession.ExecCtx(ctx, args...)
+		return err
+	})
+	return result, err
+}
+
+func (s *tracedStmt) QueryRow(v any, args ...any) error {
+	return s.QueryRowCtx(context.Background(), v, args...)
+}
+
+func (s *tracedStmt) QueryRowCtx(ctx context.Context, v any, args ...any) error {
+	return s.session.trace(ctx, s.query, func(ctx context.Context) error {
+		return s.StmtSession.QueryRowCtx(ctx, v, args...)
+	})
+}
+
+func (s *tracedStmt) QueryRowPartial(v any, args ...any) error {
+	return s.QueryRowPartialCtx(context.Background(), v, args...)
+}
+
+func (s *tracedStmt) QueryRowPartialCtx(ctx context.Context, v any, args ...any) error {
+	return s.session.trace(ctx, s.query, func(ctx context.Context) error {
+		return s.StmtSession.QueryRowPartialCtx(ctx, v, args...)
+	})
+}
+
+func (s *tracedStmt) QueryRows(v any, args ...any) error {
+	return s.QueryRowsCtx(context.Background(), v, args...)
+}
+
+func (s *tracedStmt) QueryRowsCtx(ctx context.Context, v any, args ...any) error {
+	return s.session.trace(ctx, s.query, func(ctx context.Context) error {
+		return s.StmtSession.QueryRowsCtx(ctx, v, args...)
+	})
+}
+
+func (s *tracedStmt) QueryRowsPartial(v any, args ...any) error {
+	return s.QueryRowsPartialCtx(context.Background(), v, args...)
+}
+
+func (s *tracedStmt) QueryRowsPartialCtx(ctx context.Context, v any, args ...any) error {
+	return s.session.trace(ctx, s.query, func(ctx context.Context) error {
+		return s.StmtSession.QueryRowsPartialCtx(ctx, v, args...)
+	})
+}
@@ -0,0 +1,104 @@
+package tracer
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultSlowQuery      = 500 * time.Millisecond
+	maxStatementLength    = 1024
+	slowQueryAttributeKey = attribute.Key("db.slow_query")
+)
+
+var (
+	sqlStringLiteral = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.)*"`)
+	sqlNumberLiteral = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sqlInList        = regexp.MustCompile(`(?i)\bIN\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	sqlSpaces        = regexp.MustCompile(`\s+`)
+)
+
+// SanitizeSQL 去掉sql中的字符串和数字常量，IN 列表合并为一个占位符，避免敏感数据进入链路
+func SanitizeSQL(query string) string {
+	query = sqlStringLiteral.ReplaceAllString(query, "?")
+	query = sqlNumberLiteral.ReplaceAllString(query, "?")
+	query = sqlInList.ReplaceAllString(query, "IN (?)")
+	query = strings.TrimSpace(sqlSpaces.ReplaceAllString(query, " "))
+	return truncateStatement(query)
+}
+
+func truncateStatement(statement string) string {
+	if len(statement) > maxStatementLength {
+		statement = statement[:maxStatementLength] + "..."
+	}
+	return statement
+}
+
+// sqlOperation 取sql的第一个关键字作为 db.operation
+func sqlOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+func (hc *TraceConfig) slowQueryThreshold() time.Duration {
+	if hc.SlowQuery > 0 {
+		return hc.SlowQuery
+	}
+	return defaultSlowQuery
+}
+
+// startStoreSpan 创建数据存储操作的span，ctx中有请求span时作为其子span
+func (hc *TraceConfig) startStoreSpan(ctx context.Context, system, operation, statement string) (context.Context, trace.Span) {
+	spanName := system
+	if operation != "" {
+		spanName = system + " " + operation
+	}
+	ctx, span := hc.StartSpan(ctx, spanName)
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemKey.String(system),
+	}
+	if operation != "" {
+		attrs = append(attrs, semconv.DBOperationKey.String(operation))
+	}
+	if statement != "" {
+		attrs = append(attrs, semconv.DBStatementKey.String(statement))
+	}
+	span.SetAttributes(attrs...)
+	return ctx, span
+}
+
+// endStoreSpan 结束span，记录错误以及是否慢查询，ignore 为true的错误不算失败，比如未找到记录
+func (hc *TraceConfig) endStoreSpan(span trace.Span, start time.Time, err error, ignore func(error) bool) {
+	finishStoreSpan(span, time.Since(start), hc.slowQueryThreshold(), err, ignore)
+}
+
+// finishStoreSpan 按给定的慢查询阈值结束span
+func finishStoreSpan(span trace.Span, cost, slowThreshold time.Duration, err error, ignore func(error) bool) {
+	defer span.End()
+
+	if cost >= slowThreshold {
+		span.SetAttributes(slowQueryAttributeKey.Bool(true))
+		SetWarnTag(span, errSlowQuery(cost))
+	}
+	if err == nil || (ignore != nil && ignore(err)) {
+		span.SetStatus(codes.Ok, "")
+		return
+	}
+	SetErrorTag(span, err)
+}
+
+type errSlowQuery time.Duration
+
+func (e errSlowQuery) Error() string {
+	return "slow query: " + time.Duration(e).String()
+}
@@ -0,0 +1,208 @@
+package tracer
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	red "github.com/redis/go-redis/v9"
+	"github.com/zeromicro/go-zero/core/stores/sqlx"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	cases := map[string]string{
+		"select * from user where id = 12 and name = 'tom'":   "select * from user where id = ? and name = ?",
+		"SELECT a FROM t1 WHERE id IN (1, 2, 3)":              "SELECT a FROM t1 WHERE id IN (?)",
+		"update  user\n set pwd = \"123\" where id=5":         "update user set pwd = ? where id=?",
+		"insert into log(msg) values ('it''s ok'), ('a\\'b')": "insert into log(msg) values (?), (?)",
+	}
+	for query, want := range cases {
+		if got := SanitizeSQL(query); got != want {
+			t.Errorf("SanitizeSQL(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+type fakeSqlConn struct {
+	sqlx.SqlConn
+}
+
+func (fakeSqlConn) QueryRowCtx(context.Context, any, string, ...any) error {
+	return sql.ErrNoRows
+}
+
+func TestTraceSqlConn(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := newFileConfig(t, "svc-sql")
+	cfg.SpanProcessors = []sdktrace.SpanProcessor{sdktrace.NewSimpleSpanProcessor(exporter)}
+	defer cfg.Stop()
+	if _, err := cfg.Tracer(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := cfg.StartSpan(context.Background(), "GET /users")
+	conn := cfg.TraceSqlConn(fakeSqlConn{}, "mysql")
+	var id int64
+	err := conn.QueryRowCtx(ctx, &id, "select id from user where name = 'tom'")
+	parent.End()
+	if err != sql.ErrNoRows {
+		t.Fatalf("err = %v, want sql.ErrNoRows", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	dbSpan := spans[0]
+	if dbSpan.Name != "mysql SELECT" {
+		t.Errorf("span name = %q, want %q", dbSpan.Name, "mysql SELECT")
+	}
+	if dbSpan.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("db span should be a child of the request span")
+	}
+	attrs := map[string]string{}
+	for _, kv := range dbSpan.Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["db.statement"] != "select id from user where name = ?" {
+		t.Errorf("db.statement = %q", attrs["db.statement"])
+	}
+	if attrs["db.system"] != "mysql" || attrs["db.operation"] != "SELECT" {
+		t.Errorf("unexpected db attributes: %v", attrs)
+	}
+}
+
+func TestTraceModelConn(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := newFileConfig(t, "svc-model")
+	cfg.SpanProcessors = []sdktrace.SpanProcessor{sdktrace.NewSimpleSpanProcessor(exporter)}
+	cfg.Model = &ModelConfig{SlowThreshold: time.Nanosecond}
+	defer cfg.Stop()
+	tr, err := cfg.Tracer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetDefault(tr)
+
+	conn := TraceModelConn(fakeSqlConn{}, "mysql", "`user`")
+	if TraceModelConn(conn, "mysql", "`user`") != conn {
+		t.Errorf("a traced conn should not be wrapped again")
+	}
+	var id int64
+	if err := conn.QueryRowCtx(context.Background(), &id, "select id from `user` where id = 1"); err != sql.ErrNoRows {
+		t.Fatalf("err = %v, want sql.ErrNoRows", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	attrs := map[string]string{}
+	for _, kv := range spans[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["db.sql.table"] != "`user`" || attrs["db.statement"] != "select id from `user` where id = ?" {
+		t.Errorf("unexpected db attributes: %v", attrs)
+	}
+	if attrs["db.slow_query"] != "true" {
+		t.Errorf("the query should be marked as slow: %v", attrs)
+	}
+}
+
+func TestRedisTraceHook(t *testing.T) {
+	if newRedisTraceHook(&TraceConfig{Namespace: "test"}) != nil {
+		t.Errorf("an incomplete config should not create a hook")
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := newFileConfig(t, "svc-redis")
+	cfg.SpanProcessors = []sdktrace.SpanProcessor{sdktrace.NewSimpleSpanProcessor(exporter)}
+	defer cfg.Stop()
+	if _, err := cfg.Tracer(); err != nil {
+		t.Fatal(err)
+	}
+
+	hook := newRedisTraceHook(cfg)
+	process := hook.ProcessHook(func(context.Context, red.Cmder) error {
+		return red.Nil
+	})
+	ctx := context.Background()
+	if err := process(ctx, red.NewStringCmd(ctx, "get", "user:1")); err != red.Nil {
+		t.Fatalf("err = %v, want redis.Nil", err)
+	}
+	pipeline := hook.ProcessPipelineHook(func(context.Context, []red.Cmder) error {
+		return nil
+	})
+	cmds := []red.Cmder{red.NewStatusCmd(ctx, "set", "user:1", "tom"), red.NewIntCmd(ctx, "incr", "count")}
+	if err := pipeline(ctx, cmds); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	statements := map[string]string{}
+	for _, span := range spans {
+		for _, kv := range span.Attributes {
+			if kv.Key == "db.statement" {
+				statements[span.Name] = kv.Value.Emit()
+			}
+		}
+	}
+	if statements["redis GET"] != "GET user:1" || statements["redis PIPELINE"] != "SET user:1\nINCR count" {
+		t.Errorf("unexpected statements: %v", statements)
+	}
+}
+
+func TestMongoMonitor(t *testing.T) {
+	if newMongoMonitor(&TraceConfig{Namespace: "test"}) != nil {
+		t.Errorf("an incomplete config should not create a monitor")
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := newFileConfig(t, "svc-mongo")
+	cfg.SpanProcessors = []sdktrace.SpanProcessor{sdktrace.NewSimpleSpanProcessor(exporter)}
+	defer cfg.Stop()
+	if _, err := cfg.Tracer(); err != nil {
+		t.Fatal(err)
+	}
+
+	command, err := bson.Marshal(bson.D{{Key: "find", Value: "users"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitor := newMongoMonitor(cfg)
+	ctx := context.Background()
+	monitor.started(ctx, &event.CommandStartedEvent{
+		Command:      command,
+		DatabaseName: "app",
+		CommandName:  "find",
+		RequestID:    1,
+		ConnectionID: "conn-1",
+	})
+	monitor.succeeded(ctx, &event.CommandSucceededEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{
+			CommandName:  "find",
+			RequestID:    1,
+			ConnectionID: "conn-1",
+		},
+	})
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	attrs := map[string]string{}
+	for _, kv := range spans[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["db.name"] != "app" || attrs["db.mongodb.collection"] != "users" {
+		t.Errorf("unexpected db attributes: %v", attrs)
+	}
+}
@@ -0,0 +1,215 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// Tracer 持有独立的 TracerProvider、传播器和资源属性，同一进程中可以创建多个互不影响的实例
+type Tracer struct {
+	cfg           *TraceConfig
+	name          string
+	provider      *sdktrace.TracerProvider
+	propagator    propagation.TextMapPropagator
+	meterProvider *sdkmetric.MeterProvider
+	red           *redMetrics
+	useGoZero     atomic.Bool
+}
+
+var (
+	defaultTracer  atomic.Pointer[Tracer]
+	errHandlerOnce sync.Once
+)
+
+// NewTracer 根据配置创建独立的 Tracer，不会修改 otel 的全局设置，需要时调用 SetDefault
+func NewTracer(cfg *TraceConfig) (*Tracer, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("trace config is nil")
+	}
+	if err := cfg.checkConfig(); err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(cfg.resourceAttributes()...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := cfg.createExporter()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(cfg.buildSampler()),
+		sdktrace.WithSpanProcessor(NewFilterProcessor(sdktrace.NewBatchSpanProcessor(exporter), cfg.buildSpanFilters()...)),
+		sdktrace.WithResource(res),
+	}
+	for _, processor := range cfg.SpanProcessors {
+		opts = append(opts, sdktrace.WithSpanProcessor(processor))
+	}
+
+	t := &Tracer{
+		cfg:        cfg,
+		name:       cfg.getTracerName(),
+		provider:   sdktrace.NewTracerProvider(opts...),
+		propagator: cfg.Propagator,
+	}
+	if t.propagator == nil {
+		t.propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+
+	t.meterProvider, err = cfg.newMeterProvider(res)
+	if err != nil {
+		_ = t.provider.Shutdown(context.Background())
+		return nil, err
+	}
+	if t.meterProvider != nil {
+		t.red, err = newRedMetrics(t.meterProvider.Meter(t.name))
+		if err != nil {
+			_ = t.Shutdown(context.Background())
+			return nil, err
+		}
+	}
+
+	errHandlerOnce.Do(func() {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			log.Printf("[otel] error: %v", err)
+		}))
+	})
+	return t, nil
+}
+
+// Default 返回默认的 Tracer，未设置时返回nil
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// SetDefault 设置默认的 Tracer，同时更新 otel 全局的 TracerProvider、传播器和 MeterProvider，
+// 以便 otelgin、otelgrpc、gorm 等第三方埋点使用同一份配置
+func SetDefault(t *Tracer) {
+	if t == nil {
+		return
+	}
+	defaultTracer.Store(t)
+	otel.SetTracerProvider(t.provider)
+	otel.SetTextMapPropagator(t.propagator)
+	if t.meterProvider != nil {
+		otel.SetMeterProvider(t.meterProvider)
+	}
+}
+
+// Config 创建该 Tracer 使用的配置
+func (t *Tracer) Config() *TraceConfig {
+	return t.cfg
+}
+
+// Provider 返回该实例的 TracerProvider
+func (t *Tracer) Provider() *sdktrace.TracerProvider {
+	return t.provider
+}
+
+// Propagator 返回该实例的传播器
+func (t *Tracer) Propagator() propagation.TextMapPropagator {
+	return t.propagator
+}
+
+// MeterProvider 返回该实例的 MeterProvider，未配置 Metric 时返回false
+func (t *Tracer) MeterProvider() (*sdkmetric.MeterProvider, bool) {
+	return t.meterProvider, t.meterProvider != nil
+}
+
+// Start 使用该实例的 TracerProvider 创建span，traceName 为空时使用 namespace.serviceName
+func (t *Tracer) Start(ctx context.Context, spanName string, traceName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if traceName == "" {
+		traceName = t.name
+	}
+	parent := trace.SpanContextFromContext(ctx)
+	ctx, span := t.provider.Tracer(traceName).Start(ctx, spanName, opts...)
+	return t.cfg.withLogTraceURL(ctx, parent, span.SpanContext()), span
+}
+
+// Inject 把ctx中的span信息写入carrier
+func (t *Tracer) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	t.propagator.Inject(ctx, carrier)
+}
+
+// Extract 从carrier中读取上游的span信息
+func (t *Tracer) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return t.propagator.Extract(ctx, carrier)
+}
+
+// Shutdown 刷新并关闭该实例的 TracerProvider 和 MeterProvider
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	err := t.provider.Shutdown(ctx)
+	if t.meterProvider != nil {
+		if mErr := t.meterProvider.Shutdown(ctx); mErr != nil && err == nil {
+			err = mErr
+		}
+	}
+	defaultTracer.CompareAndSwap(t, nil)
+	// otel 全局设置仍指向已关闭的实例时重置为 no-op，第三方埋点不再使用关闭的 provider
+	if otel.GetTracerProvider() == trace.TracerProvider(t.provider) {
+		otel.SetTracerProvider(tracenoop.NewTracerProvider())
+	}
+	if t.meterProvider != nil && otel.GetMeterProvider() == metric.MeterProvider(t.meterProvider) {
+		otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	}
+	return err
+}
+
+// resourceAttributes 服务的资源属性，包括部署环境、版本、主机和k8s pod信息
+func (hc *TraceConfig) resourceAttributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(hc.getTracerName()),
+	}
+	if hc.Namespace != "" {
+		attrs = append(attrs, semconv.ServiceNamespaceKey.String(hc.Namespace))
+	}
+	if hc.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(hc.Environment))
+	}
+	if hc.Version != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(hc.Version))
+	}
+	hostName, _ := os.Hostname()
+	if hostName != "" {
+		attrs = append(attrs, semconv.HostNameKey.String(hostName))
+	}
+	// k8s 中通过 downward API 注入 POD_NAME/POD_NAMESPACE，未注入时 HOSTNAME 即为pod名称
+	podName := os.Getenv("POD_NAME")
+	if podName == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		podName = hostName
+	}
+	if podName != "" {
+		attrs = append(attrs, semconv.K8SPodNameKey.String(podName))
+	}
+	if podNamespace := os.Getenv("POD_NAMESPACE"); podNamespace != "" {
+		attrs = append(attrs, semconv.K8SNamespaceNameKey.String(podNamespace))
+	}
+	for key, value := range hc.ResourceAttributes {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	return attrs
+}
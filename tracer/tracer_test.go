@@ -0,0 +1,160 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/zeromicro/go-zero/core/service"
+	ztrace "github.com/zeromicro/go-zero/core/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newFileConfig(t *testing.T, service string) *TraceConfig {
+	percent := 100
+	return &TraceConfig{
+		Namespace:      "test",
+		ServiceName:    service,
+		Endpoint:       filepath.Join(t.TempDir(), service+".json"),
+		Batcher:        kindFile,
+		SamplerPercent: &percent,
+		Environment:    "unittest",
+		Version:        "v1.0.0",
+	}
+}
+
+func TestTracerInstances(t *testing.T) {
+	cfgA := newFileConfig(t, "svc-a")
+	cfgB := newFileConfig(t, "svc-b")
+	defer cfgA.Stop()
+	defer cfgB.Stop()
+
+	tracerA, err := cfgA.Tracer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracerB, err := cfgB.Tracer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracerA.Provider() == tracerB.Provider() {
+		t.Fatal("each config should own its TracerProvider")
+	}
+	if again, _ := cfgA.Tracer(); again != tracerA {
+		t.Fatal("Tracer() should return the same instance for the same config")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_, span := cfgA.StartSpan(context.Background(), fmt.Sprintf("a-%d", i))
+			span.End()
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, span := cfgB.StartSpan(context.Background(), fmt.Sprintf("b-%d", i))
+			span.End()
+		}(i)
+	}
+	wg.Wait()
+
+	if Default() != tracerA {
+		t.Fatal("the first created tracer should become the default")
+	}
+}
+
+func TestUnbuiltConfig(t *testing.T) {
+	cfgA := newFileConfig(t, "svc-built")
+	defer cfgA.Stop()
+	tracerA, err := cfgA.Tracer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未创建实例的配置不能借用其它配置的 provider
+	cfgB := newFileConfig(t, "svc-unbuilt")
+	if cfgB.tracerProvider() == trace.TracerProvider(tracerA.Provider()) {
+		t.Fatal("unbuilt config should not use the provider of another config")
+	}
+	_, span := cfgB.StartSpan(context.Background(), "unbuilt")
+	defer span.End()
+	if span.IsRecording() || span.SpanContext().IsValid() {
+		t.Error("span of unbuilt config should be a no-op span")
+	}
+}
+
+func TestShutdownResetGlobal(t *testing.T) {
+	cfg := newFileConfig(t, "svc-shutdown")
+	tracer, err := cfg.Tracer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetDefault(tracer)
+	cfg.Stop()
+
+	if otel.GetTracerProvider() == trace.TracerProvider(tracer.Provider()) {
+		t.Fatal("otel global TracerProvider still points to the shutdown provider")
+	}
+	if Default() == tracer {
+		t.Fatal("default tracer still points to the shutdown tracer")
+	}
+}
+
+func TestResourceAttributes(t *testing.T) {
+	cfg := newFileConfig(t, "svc-res")
+	t.Setenv("POD_NAME", "svc-res-7d9f")
+	found := map[string]string{}
+	for _, kv := range cfg.resourceAttributes() {
+		found[string(kv.Key)] = kv.Value.Emit()
+	}
+	want := map[string]string{
+		"service.name":           "test.svc-res",
+		"deployment.environment": "unittest",
+		"service.version":        "v1.0.0",
+		"k8s.pod.name":           "svc-res-7d9f",
+	}
+	for key, value := range want {
+		if found[key] != value {
+			t.Errorf("%s = %q, want %q", key, found[key], value)
+		}
+	}
+}
+
+func TestInitGoZeroTracing(t *testing.T) {
+	cfg := newFileConfig(t, "svc-gozero")
+	ratio := 1.0
+	cfg.Sampler = &SamplerConfig{
+		Ratio: &ratio,
+		Rules: []SamplerRule{{Route: "/health", Ratio: 0}},
+	}
+	defer cfg.Stop()
+
+	var srvConfig service.ServiceConf
+	if err := cfg.InitGoZeroTracing(&srvConfig); err != nil {
+		t.Fatal(err)
+	}
+	if srvConfig.Telemetry.Endpoint != "" {
+		t.Errorf("go-zero agent should not export spans itself, endpoint = %q", srvConfig.Telemetry.Endpoint)
+	}
+	tracer, _ := cfg.Tracer()
+	if otel.GetTracerProvider() != tracer.Provider() {
+		t.Fatal("go-zero should use the TracerProvider of the config")
+	}
+
+	// go-zero 的埋点同样使用规则采样
+	_, health := otel.Tracer(ztrace.TraceName).Start(context.Background(), "GET /health")
+	defer health.End()
+	if health.SpanContext().IsSampled() {
+		t.Error("/health should not be sampled")
+	}
+	_, users := otel.Tracer(ztrace.TraceName).Start(context.Background(), "GET /users")
+	defer users.End()
+	if !users.SpanContext().IsSampled() {
+		t.Error("/users should be sampled")
+	}
+}
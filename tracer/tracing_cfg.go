@@ -3,24 +3,23 @@ package tracer
 import (
 	"context"
 	"fmt"
-	"github.com/magic-lib/go-plat-utils/goroutines"
 	ztrace "github.com/zeromicro/go-zero/core/trace"
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"log"
 	"net/url"
 	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type TraceConfig struct {
@@ -31,22 +30,29 @@ type TraceConfig struct {
 	OtlpHeaders    map[string]string `json:",optional"`
 	OtlpHttpPath   string            `json:",optional"`
 	OtlpHttpSecure bool              `json:",optional"`
-	SamplerPercent int               `json:",samplerPercent"` //采样比例
-	lastTracer     trace.Tracer      //上次使用过的tracer
-	useGoZero      bool
+	SamplerPercent *int              `json:",optional"` //采样比例 0~100，为空时默认50，可以设为0
+	Sampler        *SamplerConfig    `json:",optional"` //采样策略，设置后支持父级采样、规则采样和限速采样
+	RedactKeys     []string          `json:",optional"` //导出前需要脱敏的属性名，可使用 DefaultRedactKeys
+	Metric         *MetricConfig     `json:",optional"` //指标上报配置，为空则不创建 MeterProvider
+	Environment    string            `json:",optional"` //部署环境，写入 deployment.environment
+	Version        string            `json:",optional"` //服务版本，写入 service.version
+	SlowQuery      time.Duration     `json:",optional"` //存储操作超过该耗时标记为慢查询，默认500ms
+	Model          *ModelConfig      `json:",optional"` //goctl 生成的 model 的观测配置
+	Log            *LogConfig        `json:",optional"` //日志与链路关联配置
+
+	ResourceAttributes map[string]string `json:",optional"` //额外的资源属性
+
+	Propagator     propagation.TextMapPropagator `json:"-"` //传播器，默认 TraceContext + Baggage
+	SpanProcessors []sdktrace.SpanProcessor      `json:"-"` //额外的span处理器，比如在 OnStart 中补充属性
+	SpanFilters    []SpanFilter                  `json:"-"` //导出前对span的自定义处理
 }
 
 var (
-	traceProviderCache    = make(map[string]*sdktrace.TracerProvider)
-	singleton             = goroutines.NewSingleFlight()
-	globalTraceConfigLock sync.Mutex
-	globalTraceConfig     *atomic.Value
+	instances      sync.Map // *TraceConfig -> *Tracer
+	instancesLock  sync.Mutex
+	goZeroAgentRun atomic.Bool
 )
 
-type tracerConfigHolder struct {
-	tc *TraceConfig
-}
-
 func (hc *TraceConfig) getTracerName() string {
 	err := hc.checkConfig()
 	if err != nil {
@@ -54,27 +60,42 @@ func (hc *TraceConfig) getTracerName() string {
 	}
 	return fmt.Sprintf("%s.%s", hc.Namespace, hc.ServiceName)
 }
+
+// MeterProvider 返回按 Metric 配置创建的 MeterProvider，未配置时返回false
+func (hc *TraceConfig) MeterProvider() (*sdkmetric.MeterProvider, bool) {
+	t := hc.current()
+	if t == nil {
+		return nil, false
+	}
+	return t.MeterProvider()
+}
+
+// getSampler 计算采样比例，不修改配置本身，可以在并发请求中调用
 func (hc *TraceConfig) getSampler() float64 {
-	if hc.SamplerPercent == 0 { //默认为50%概率
-		hc.SamplerPercent = 50
+	percent := 50 //默认为50%概率
+	if hc.SamplerPercent != nil {
+		percent = *hc.SamplerPercent
 	}
-	if hc.SamplerPercent > 100 {
-		hc.SamplerPercent = 100
+	if percent > 100 {
+		percent = 100
 	}
-	if hc.SamplerPercent < 0 {
-		hc.SamplerPercent = 0
+	if percent < 0 {
+		percent = 0
 	}
-	return float64(hc.SamplerPercent) / 100
+	return float64(percent) / 100
 }
 
-func (hc *TraceConfig) checkConfig() error {
+func (hc *TraceConfig) getBatcher() string {
 	if hc.Batcher == "" {
-		hc.Batcher = kindJaeger
+		return kindJaeger
 	}
+	return hc.Batcher
+}
+
+func (hc *TraceConfig) checkConfig() error {
 	if hc.Namespace == "" {
 		return fmt.Errorf("trace Namespace is required")
 	}
-	_ = hc.getSampler()
 
 	if hc.ServiceName == "" {
 		log.Println("TraceConfig serviceName is required")
@@ -86,77 +107,60 @@ func (hc *TraceConfig) checkConfig() error {
 	}
 	return nil
 }
-func (hc *TraceConfig) startTraceSpan(ctx context.Context, traceName, spanName string) (context.Context, trace.Span) {
-	newTraceCtx, tracer := TracerFromContext(ctx, traceName)
-	span := trace.SpanFromContext(ctx)
-	options := make([]trace.SpanStartOption, 0)
-	if span == nil || !span.SpanContext().IsValid() {
-		options = []trace.SpanStartOption{
-			trace.WithNewRoot(),
-		}
-	}
-	newCtx, span := tracer.Start(newTraceCtx, spanName, options...)
-	newCtx = trace.ContextWithSpan(newCtx, span)
-	hc.lastTracer = tracer
-	return newCtx, span
-}
 
-// 没有tranceName的情况下，使用上次使用过的trance
-func (hc *TraceConfig) startNewSpan(ctx context.Context, spanName string) (context.Context, trace.Span) {
-	if hc.lastTracer == nil {
-		return hc.startTraceSpan(ctx, hc.ServiceName, spanName)
+// Tracer 返回该配置对应的 Tracer 实例，首次调用时创建，第一个创建的实例会成为默认实例
+func (hc *TraceConfig) Tracer() (*Tracer, error) {
+	if t, ok := hc.instance(); ok {
+		return t, nil
 	}
-	parentSpan := trace.SpanFromContext(ctx)
-	if parentSpan != nil && parentSpan.SpanContext().IsValid() {
-		ctx = trace.ContextWithSpan(ctx, parentSpan)
+	instancesLock.Lock()
+	defer instancesLock.Unlock()
+	if t, ok := hc.instance(); ok {
+		return t, nil
 	}
-	return hc.lastTracer.Start(ctx, spanName)
-}
 
-func (hc *TraceConfig) initTraceProvider() (*sdktrace.TracerProvider, error) {
-	if tpTemp, ok := TraceProvider(); ok {
-		return tpTemp, nil
-	}
-
-	err := hc.checkConfig()
+	t, err := NewTracer(hc)
 	if err != nil {
 		return nil, err
 	}
-
-	backGroundCtx := context.Background()
-
-	// 定义服务资源，用于在 Jaeger UI 中识别服务
-	res, err := resource.New(backGroundCtx,
-		resource.WithAttributes(semconv.ServiceNameKey.String(hc.getTracerName())),
-	)
-	if err != nil {
-		return nil, err
+	instances.Store(hc, t)
+	if defaultTracer.CompareAndSwap(nil, t) {
+		SetDefault(t)
 	}
+	return t, nil
+}
 
-	exporter, err := hc.createExporter()
-	if err != nil {
-		return nil, err
+func (hc *TraceConfig) instance() (*Tracer, bool) {
+	if v, ok := instances.Load(hc); ok {
+		return v.(*Tracer), true
 	}
+	return nil, false
+}
 
-	opts := []sdktrace.TracerProviderOption{
-		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(hc.getSampler())),
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-	}
+// current 返回该配置已创建的实例，未创建时返回nil，不会借用其它配置的实例
+func (hc *TraceConfig) current() *Tracer {
+	t, _ := hc.instance()
+	return t
+}
 
-	// 创建 TracerProvider，配置批量处理器和资源
-	newTp := sdktrace.NewTracerProvider(opts...)
-	otel.SetTracerProvider(newTp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
-	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
-		log.Printf("[otel] error: %v", err)
-	}))
+// propagator 当前实例的传播器，未初始化时不做传播
+func (hc *TraceConfig) propagator() propagation.TextMapPropagator {
+	if t := hc.current(); t != nil {
+		return t.propagator
+	}
+	return propagation.NewCompositeTextMapPropagator()
+}
 
-	return newTp, nil
+// tracerProvider 当前实例的 TracerProvider，未初始化时返回 no-op 实现
+func (hc *TraceConfig) tracerProvider() trace.TracerProvider {
+	if t := hc.current(); t != nil {
+		return t.provider
+	}
+	return noop.NewTracerProvider()
 }
 
 func (hc *TraceConfig) createExporter() (sdktrace.SpanExporter, error) {
-	switch hc.Batcher {
+	switch hc.getBatcher() {
 	case kindJaeger:
 		u, err := url.Parse(hc.Endpoint)
 		if err == nil && u.Scheme == protocolUdp {
@@ -201,47 +205,38 @@ func (hc *TraceConfig) createExporter() (sdktrace.SpanExporter, error) {
 	}
 }
 
-// InitTrace 初始化，只用执行一次
+// InitTrace 初始化，只用执行一次，重复调用返回同一个 TracerProvider
 func (hc *TraceConfig) InitTrace() (*sdktrace.TracerProvider, error) {
-	if tp := traceProviderCache[hc.Endpoint]; tp != nil {
-		return tp, nil
-	}
-	if globalTraceConfig == nil {
-		SetTraceConfig(hc)
-	}
-	//一个名称只需要执行一次, 避免重复执行
-	_, err := singleton.Once(hc.Endpoint, func() (any, error) {
-		return hc.initTraceProvider()
-	})
+	t, err := hc.Tracer()
 	if err != nil {
 		return nil, err
 	}
-	if tpTemp, ok := TraceProvider(); ok {
-		traceProviderCache[hc.Endpoint] = tpTemp
-		return tpTemp, nil
-	}
-	return nil, nil
+	return t.Provider(), nil
 }
 
 func (hc *TraceConfig) Stop() {
-	tp, ok := TraceProvider()
-	if ok {
-		err := tp.Shutdown(context.Background())
+	if v, ok := instances.LoadAndDelete(hc); ok {
+		err := v.(*Tracer).Shutdown(context.Background())
 		if err != nil {
 			fmt.Printf("Failed to shutdown tracer provider: %v", err)
-			return
 		}
-		delete(traceProviderCache, hc.Endpoint)
 	}
-	if hc.useGoZero {
+	if goZeroAgentRun.CompareAndSwap(true, false) {
 		ztrace.StopAgent()
-		hc.useGoZero = false
 	}
 }
 
 func (hc *TraceConfig) StartSpan(ctx context.Context, spanName string, traceName ...string) (context.Context, trace.Span) {
-	if len(traceName) == 0 || traceName[0] == "" {
-		return hc.startNewSpan(ctx, spanName)
+	name := ""
+	if len(traceName) > 0 {
+		name = traceName[0]
+	}
+	if t := hc.current(); t != nil {
+		return t.Start(ctx, spanName, name)
+	}
+	// 未调用 Tracer/InitTrace 时不上报，避免span进入其它配置的 TracerProvider
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	return hc.startTraceSpan(ctx, traceName[0], spanName)
+	return noop.NewTracerProvider().Tracer(hc.ServiceName).Start(ctx, spanName)
 }
@@ -14,7 +14,6 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"strings"
-	"sync/atomic"
 )
 
 const (
@@ -28,37 +27,31 @@ const (
 	maxSpanNameLength = 60
 )
 
+// GetTraceConfig 返回默认 Tracer 的配置，未初始化时返回空配置
 func GetTraceConfig() *TraceConfig {
-	if globalTraceConfig == nil {
-		return new(TraceConfig)
-	}
-	globalTraceConfigLock.Lock()
-	defer globalTraceConfigLock.Unlock()
-
-	tc := globalTraceConfig.Load().(tracerConfigHolder).tc
-	if tc != nil {
-		return tc
+	if t := Default(); t != nil {
+		return t.cfg
 	}
 	return new(TraceConfig)
 }
+
+// SetTraceConfig 按配置初始化 Tracer 并设置为默认实例
 func SetTraceConfig(tc *TraceConfig) {
 	if tc == nil {
 		return
 	}
-	err := tc.checkConfig()
+	t, err := tc.Tracer()
 	if err != nil {
 		return
 	}
-	globalTraceConfigLock.Lock()
-	defer globalTraceConfigLock.Unlock()
-	if globalTraceConfig == nil {
-		globalTraceConfig = &atomic.Value{}
-	}
-	globalTraceConfig.Store(tracerConfigHolder{
-		tc: tc,
-	})
+	SetDefault(t)
 }
+
+// TraceProvider 返回默认 Tracer 的 TracerProvider，没有默认实例时检查 otel 全局设置
 func TraceProvider() (*sdktrace.TracerProvider, bool) {
+	if t := Default(); t != nil {
+		return t.provider, true
+	}
 	provider := otel.GetTracerProvider()
 	if !cond.IsNil(provider) {
 		tp, ok := provider.(*sdktrace.TracerProvider)
@@ -69,6 +62,14 @@ func TraceProvider() (*sdktrace.TracerProvider, bool) {
 	return nil, false
 }
 
+// defaultPropagator 默认 Tracer 的传播器，没有默认实例时使用 otel 全局设置
+func defaultPropagator() propagation.TextMapPropagator {
+	if t := Default(); t != nil {
+		return t.propagator
+	}
+	return otel.GetTextMapPropagator()
+}
+
 func SpanToHeader(ctx context.Context, headers http.Header, createNewSpan func(ctx context.Context) (context.Context, trace.Span)) (context.Context, trace.Span) {
 	if ctx == nil {
 		ctx = context.Background()
@@ -102,14 +103,14 @@ func SpanToHeader(ctx context.Context, headers http.Header, createNewSpan func(c
 	// 00-f6db96bfd2e5b58349c18eb6e720da84-d0c2b79e207757c3-01
 	ctx = trace.ContextWithSpan(ctx, span)
 	//req.Header 是引用类型，可以直接修改
-	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(headers))
+	defaultPropagator().Inject(ctx, propagation.HeaderCarrier(headers))
 	return ctx, span
 }
 func SpanFromRequest(ctx context.Context, req *http.Request) context.Context {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	newCtx := otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(req.Header))
+	newCtx := defaultPropagator().Extract(ctx, propagation.HeaderCarrier(req.Header))
 	req = req.WithContext(newCtx)
 	return newCtx
 }
@@ -123,13 +124,11 @@ func TracerFromContext(ctx context.Context, traceName string) (newCtx context.Co
 		traceName = GetTraceConfig().getTracerName()
 	}
 	span := trace.SpanFromContext(ctx)
-	if span.SpanContext().IsValid() {
-		//检测span是否是nonTracer
-		//trace.nonRecordingSpan
-		if span.IsRecording() {
-
-		}
+	if span.SpanContext().IsValid() && span.IsRecording() {
+		//远端传入的 nonRecordingSpan 返回的是空实现的 TracerProvider，不能使用
 		tracer = span.TracerProvider().Tracer(traceName)
+	} else if t := Default(); t != nil {
+		tracer = t.provider.Tracer(traceName)
 	} else {
 		tracer = otel.Tracer(traceName)
 	}
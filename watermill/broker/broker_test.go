@@ -0,0 +1,123 @@
+package broker_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	comm "github.com/magic-lib/go-servicekit/watermill"
+	"github.com/magic-lib/go-servicekit/watermill/broker"
+)
+
+func TestGoChannelPoisonQueue(t *testing.T) {
+	ps, err := broker.New(&broker.Config{
+		Driver:     broker.DriverGoChannel,
+		RetryTimes: 2,
+		Timeout:    50 * time.Millisecond,
+		Failure: comm.FailureConfig{
+			Policy:      comm.FailurePoison,
+			PoisonTopic: "order.poison",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	var attempts int32
+	err = ps.Subscribe("order.created", func(ctx context.Context, msg *message.Message) error {
+		if comm.TopicFromContext(ctx) != "order.created" {
+			t.Errorf("topic from context = %q", comm.TopicFromContext(ctx))
+		}
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			<-ctx.Done() //第一次处理超时
+			return nil
+		}
+		return fmt.Errorf("order %s not found", msg.Metadata.Get("order_id"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	poisoned := make(chan *message.Message, 1)
+	err = ps.Subscribe("order.poison", func(ctx context.Context, msg *message.Message) error {
+		poisoned <- msg
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.NewMessage("m-1", []byte("hello"))
+	msg.Metadata.Set("order_id", "42")
+	if _, err = ps.Publish(context.Background(), "order.created", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-poisoned:
+		if got.UUID != "m-1" || string(got.Payload) != "hello" {
+			t.Errorf("unexpected poisoned message: %s %s", got.UUID, got.Payload)
+		}
+		if got.Metadata.Get(comm.PoisonedTopicKey) != "order.created" {
+			t.Errorf("poisoned topic = %q", got.Metadata.Get(comm.PoisonedTopicKey))
+		}
+		if got.Metadata.Get("order_id") != "42" {
+			t.Errorf("metadata should be kept, got %v", got.Metadata)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("message was not sent to the poison queue")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Errorf("attempts = %d, want 3", n)
+	}
+}
+
+func TestGoChannelNackIsolation(t *testing.T) {
+	ps, err := broker.New(&broker.Config{
+		Driver: broker.DriverGoChannel,
+		Failure: comm.FailureConfig{
+			Policy:  comm.FailureNack,
+			Backoff: comm.Backoff{InitialInterval: 10 * time.Millisecond},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	var okCalls, flakyCalls int32
+	done := make(chan struct{})
+	err = ps.Subscribe("stock.changed", func(ctx context.Context, msg *message.Message) error {
+		atomic.AddInt32(&okCalls, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ps.Subscribe("stock.changed", func(ctx context.Context, msg *message.Message) error {
+		if atomic.AddInt32(&flakyCalls, 1) <= 2 {
+			return fmt.Errorf("stock service unavailable")
+		}
+		close(done)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ps.Publish(context.Background(), "stock.changed", message.NewMessage("s-1", []byte("1"))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("nacked message was not redelivered")
+	}
+	if n := atomic.LoadInt32(&okCalls); n != 1 {
+		t.Errorf("successful handler called %d times, want 1", n)
+	}
+}
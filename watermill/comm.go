@@ -1,3 +1,54 @@
 package watermill
 
+import (
+	"context"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// MessageHandler 旧版的消息处理方法，只能拿到消息ID和内容
+//
+// Deprecated: 使用 HandlerFunc，可以拿到 context、metadata 和原始消息
 type MessageHandler func(messageId, messageData string) error
+
+// HandlerFunc 消息处理方法，msg.Metadata 中包含消息元数据，比如链路信息
+type HandlerFunc func(ctx context.Context, msg *message.Message) error
+
+// Middleware 处理方法的中间件
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Publisher 消息发布
+type Publisher interface {
+	// Publish 发布消息，返回消息ID
+	Publish(ctx context.Context, topic string, msg *message.Message) (string, error)
+}
+
+// Scheduler 延迟发布，到达指定时间后才投递给订阅方
+type Scheduler interface {
+	// PublishAt 在 at 时间发布消息，at 早于当前时间则立即发布，返回消息ID，可用于取消
+	PublishAt(ctx context.Context, topic string, msg *message.Message, at time.Time) (string, error)
+	// PublishAfter 在 delay 之后发布消息
+	PublishAfter(ctx context.Context, topic string, msg *message.Message, delay time.Duration) (string, error)
+	// CancelScheduled 取消还没有投递的消息，消息不存在或者已经投递时返回 ErrScheduledNotFound
+	CancelScheduled(ctx context.Context, topic, id string) error
+}
+
+// PubSub gochannel、grpcchannel、mysqlchannel 统一实现的发布订阅接口
+type PubSub interface {
+	Publisher
+	Scheduler
+	// Subscribe 订阅topic，同一个topic可以注册多个处理方法
+	Subscribe(topic string, handler HandlerFunc) error
+	// Use 添加处理方法的中间件，先添加的在外层
+	Use(middlewares ...Middleware)
+	// Close 关闭连接
+	Close()
+}
+
+// FromMessageHandler 把旧版的处理方法转换为 HandlerFunc
+func FromMessageHandler(handler MessageHandler) HandlerFunc {
+	return func(_ context.Context, msg *message.Message) error {
+		return handler(msg.UUID, string(msg.Payload))
+	}
+}
@@ -0,0 +1,136 @@
+package watermill
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+const (
+	// FailureAck 记录日志后确认消息，消息会丢失
+	FailureAck = "ack"
+	// FailureNack 按退避时间等待后 nack，由后端重新投递
+	FailureNack = "nack"
+	// FailurePoison 转发到死信topic，并写入失败原因
+	FailurePoison = "poison"
+	// FailureRetryTable 写入 mysql 重试表异步重试，仅 mysqlchannel 支持
+	FailureRetryTable = "retry_table"
+
+	// PoisonedAttemptsKey 进入死信队列的消息，记录投递次数
+	PoisonedAttemptsKey = "poisoned_attempts"
+
+	// DefaultMaxRedelivery MaxRedelivery 为0时 nack 的最大次数
+	DefaultMaxRedelivery = 10
+)
+
+// DefaultBackoff 默认的退避策略
+var DefaultBackoff = Backoff{
+	InitialInterval: 100 * time.Millisecond,
+	MaxInterval:     5 * time.Second,
+	Multiplier:      2,
+}
+
+// Backoff 指数退避，第n次等待 InitialInterval*Multiplier^(n-1)，不超过 MaxInterval
+type Backoff struct {
+	InitialInterval time.Duration `json:",optional"`
+	MaxInterval     time.Duration `json:",optional"`
+	Multiplier      float64       `json:",optional"`
+}
+
+// Next 第 attempt 次重试前需要等待的时间，attempt 从1开始
+func (b Backoff) Next(attempt int) time.Duration {
+	if b.InitialInterval <= 0 || attempt <= 0 {
+		return 0
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	interval := float64(b.InitialInterval)
+	for i := 1; i < attempt; i++ {
+		interval *= multiplier
+		if b.MaxInterval > 0 && interval >= float64(b.MaxInterval) {
+			return b.MaxInterval
+		}
+	}
+	return time.Duration(interval)
+}
+
+// FailureConfig 处理方法重试后仍然失败时的处理方式
+type FailureConfig struct {
+	Policy        string  `json:",optional,options=ack|nack|poison|retry_table"` //为空时 mysqlchannel 使用重试表，其他通道使用 ack
+	Backoff       Backoff `json:",optional"`                                     //nack 前的等待时间，为空使用 DefaultBackoff
+	MaxRedelivery int     `json:",default=10"`                                   //nack 的最大次数，超过后转发到 PoisonTopic，未配置则丢弃，0使用 DefaultMaxRedelivery，小于0表示不限制
+	PoisonTopic   string  `json:",optional"`                                     //死信topic
+}
+
+func (fc FailureConfig) maxRedelivery() int {
+	if fc.MaxRedelivery == 0 {
+		return DefaultMaxRedelivery
+	}
+	return fc.MaxRedelivery
+}
+
+func (fc FailureConfig) backoff() Backoff {
+	if fc.Backoff.InitialInterval <= 0 {
+		return DefaultBackoff
+	}
+	return fc.Backoff
+}
+
+// onFailure 按失败策略确认或者 nack 消息
+func (r *Router) onFailure(ctx context.Context, key, topic string, msg *message.Message, state *deliveryState, retError error) {
+	fc := r.failure
+	poison := fc.Policy == FailurePoison
+	if fc.Policy == FailureNack {
+		attempts := state.nextAttempt()
+		if maxRedelivery := fc.maxRedelivery(); maxRedelivery < 0 || attempts <= maxRedelivery {
+			log.Printf("nack message %s of topic %s, attempt %d: %v", msg.UUID, topic, attempts, retError)
+			sleepContext(ctx, fc.backoff().Next(attempts))
+			msg.Nack()
+			return
+		}
+		poison = fc.PoisonTopic != ""
+	}
+	if poison {
+		attempts := state.nextAttempt()
+		if err := r.poison(ctx, topic, msg, attempts, retError); err != nil {
+			log.Printf("move message %s of topic %s to poison queue error: %v", msg.UUID, topic, err)
+			sleepContext(ctx, fc.backoff().Next(attempts))
+			msg.Nack()
+			return
+		}
+	} else {
+		log.Println("Subscribe handler error: ", retError.Error())
+	}
+	r.forget(key)
+	msg.Ack()
+}
+
+func (r *Router) poison(ctx context.Context, topic string, msg *message.Message, attempts int, retError error) error {
+	if r.publisher == nil || r.failure.PoisonTopic == "" {
+		return fmt.Errorf("poison topic or publisher is empty")
+	}
+	poisoned := msg.Copy()
+	poisoned.Metadata.Set(PoisonedTopicKey, topic)
+	poisoned.Metadata.Set(PoisonedReasonKey, retError.Error())
+	poisoned.Metadata.Set(PoisonedAttemptsKey, strconv.Itoa(attempts))
+	_, err := r.publisher.Publish(ctx, r.failure.PoisonTopic, poisoned)
+	return err
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -1,8 +1,11 @@
 package gochannel_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/ThreeDotsLabs/watermill/message"
+	comm "github.com/magic-lib/go-servicekit/watermill"
 	"github.com/magic-lib/go-servicekit/watermill/gochannel"
 	"testing"
 	"time"
@@ -10,23 +13,23 @@ import (
 
 func TestPubSub(t *testing.T) {
 	temp := gochannel.New()
-	err := temp.Subscribe("aaaa", func(messageId, messageData string) error {
-		fmt.Println("aaaa, messageId:", messageId, "messageData:", messageData)
+	err := temp.Subscribe("aaaa", func(ctx context.Context, msg *message.Message) error {
+		fmt.Println("aaaa, messageId:", msg.UUID, "messageData:", string(msg.Payload))
 		return nil
 	})
 	if err != nil {
 		return
 	}
-	err = temp.Subscribe("aaaa", func(messageId, messageData string) error {
-		fmt.Println("aaaa, messageId2:", messageId, "messageData2:", messageData)
+	err = temp.Subscribe("aaaa", func(ctx context.Context, msg *message.Message) error {
+		fmt.Println("aaaa, messageId2:", msg.UUID, "messageData2:", string(msg.Payload))
 		return fmt.Errorf("aaa error")
 	})
 	if err != nil {
 		return
 	}
 
-	err = temp.Subscribe("bbbb", func(messageId, messageData string) error {
-		fmt.Println("bbbb, messageId:", messageId, "messageData:", messageData)
+	err = temp.Subscribe("bbbb", func(ctx context.Context, msg *message.Message) error {
+		fmt.Println("bbbb, messageId:", msg.UUID, "messageData:", string(msg.Payload))
 		return nil
 	})
 	if err != nil {
@@ -52,3 +55,50 @@ func TestPubSub(t *testing.T) {
 	}
 
 }
+
+func TestPublishAfter(t *testing.T) {
+	ps := gochannel.New().WithNamespace("scheduled")
+	defer ps.Close()
+
+	received := make(chan string, 2)
+	err := ps.Subscribe("reminder", func(ctx context.Context, msg *message.Message) error {
+		received <- msg.UUID
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err = ps.PublishAfter(context.Background(), "reminder", message.NewMessage("later", []byte("a")), 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	cancelId, err := ps.PublishAt(context.Background(), "reminder", message.NewMessage("canceled", []byte("b")), time.Now().Add(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ps.CancelScheduled(context.Background(), "reminder", cancelId); err != nil {
+		t.Fatal(err)
+	}
+	if err = ps.CancelScheduled(context.Background(), "reminder", cancelId); !errors.Is(err, comm.ErrScheduledNotFound) {
+		t.Fatalf("cancel twice error = %v", err)
+	}
+
+	select {
+	case id := <-received:
+		if id != "later" {
+			t.Fatalf("received %s, want later", id)
+		}
+		// 时间轮精度为 DefaultScheduleInterval
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond-comm.DefaultScheduleInterval {
+			t.Fatalf("delivered after %s, want about 300ms", elapsed)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("scheduled message not delivered")
+	}
+	select {
+	case id := <-received:
+		t.Fatalf("canceled message %s delivered", id)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
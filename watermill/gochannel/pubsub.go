@@ -5,13 +5,12 @@ import (
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
-	"github.com/hashicorp/go-multierror"
 	"github.com/magic-lib/go-plat-utils/conv"
 	"github.com/magic-lib/go-plat-utils/goroutines"
 	comm "github.com/magic-lib/go-servicekit/watermill"
 	cmap "github.com/orcaman/concurrent-map/v2"
-	"log"
 	"sync"
+	"time"
 )
 
 type Channel struct {
@@ -19,16 +18,18 @@ type Channel struct {
 	namespace           string
 	outputChannelBuffer int64
 	consumerGroup       string
-	handlers            cmap.ConcurrentMap[string, []comm.MessageHandler]
-	subsMu              sync.RWMutex
-	retryTimes          int
-	errorHandler        func(msg *message.Message) error
+	router              *comm.Router
+	clientMu            sync.Mutex
+	scheduler           *comm.MemoryScheduler
+	schedulerMu         sync.Mutex
 }
 
+var _ comm.PubSub = (*Channel)(nil)
+
 func New() *Channel {
 	goChanTemp := new(Channel)
-	goChanTemp.handlers = cmap.New[[]comm.MessageHandler]()
-	goChanTemp.retryTimes = 3
+	goChanTemp.clientMap = cmap.New[*gochannel.GoChannel]()
+	goChanTemp.router = comm.NewRouter().WithPublisher(goChanTemp)
 	return goChanTemp
 }
 
@@ -41,20 +42,30 @@ func (g *Channel) WithChannelBuffer(outputChannelBuffer int) *Channel {
 	return g
 }
 func (g *Channel) WithRetryTimes(retryTimes int) *Channel {
-	g.retryTimes = retryTimes
+	g.router.WithRetryTimes(retryTimes)
+	return g
+}
+
+// WithFailure 重试后仍然失败时的处理方式，默认确认消息
+func (g *Channel) WithFailure(fc comm.FailureConfig) *Channel {
+	g.router.WithFailure(fc)
 	return g
 }
 func (g *Channel) WithErrorHandler(handler func(msg *message.Message) error) *Channel {
-	g.errorHandler = handler
+	g.router.WithErrorHandler(handler)
 	return g
 }
 
+// Use 添加处理方法的中间件
+func (g *Channel) Use(middlewares ...comm.Middleware) {
+	g.router.Use(middlewares...)
+}
+
 func (g *Channel) getClient(namespace string) *gochannel.GoChannel {
-	if g.namespace == "" {
-		g.namespace = "default"
-	}
+	g.clientMu.Lock()
+	defer g.clientMu.Unlock()
 	if namespace == "" {
-		namespace = g.consumerGroup
+		namespace = "default"
 	}
 
 	if sub, ok := g.clientMap.Get(namespace); ok {
@@ -80,6 +91,11 @@ func (g *Channel) getClient(namespace string) *gochannel.GoChannel {
 }
 
 func (g *Channel) Close() {
+	g.schedulerMu.Lock()
+	if g.scheduler != nil {
+		g.scheduler.Stop()
+	}
+	g.schedulerMu.Unlock()
 	for _, client := range g.clientMap.Items() {
 		_ = client.Close()
 	}
@@ -89,109 +105,97 @@ func (g *Channel) Publish(ctx context.Context, topic string, msg *message.Messag
 	if msg == nil {
 		return "", nil
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	if msg.UUID == "" {
 		msg.UUID = watermill.NewUUID()
 	}
-	msg = message.NewMessageWithContext(ctx, msg.UUID, msg.Payload)
-	if err := g.getClient(g.namespace).Publish(topic, msg); err != nil {
+	newMsg := message.NewMessageWithContext(ctx, msg.UUID, msg.Payload)
+	for k, v := range msg.Metadata {
+		newMsg.Metadata.Set(k, v)
+	}
+	comm.InjectTrace(ctx, newMsg)
+	comm.SetDeliveryID(newMsg)
+	if err := g.getClient(g.namespace).Publish(topic, newMsg); err != nil {
 		return "", err
 	}
-	return msg.UUID, nil
+	return newMsg.UUID, nil
 }
 
-func (g *Channel) Subscribe(topic string, handler comm.MessageHandler) error {
-	g.subsMu.Lock()
-	defer g.subsMu.Unlock()
-	if oldHandlers, ok := g.handlers.Get(topic); ok {
-		oldHandlers = append(oldHandlers, handler)
-		g.handlers.Set(topic, oldHandlers)
-		return nil
+// PublishAt 在 at 时间发布消息，延迟的消息保存在内存时间轮中，进程退出后会丢失
+func (g *Channel) PublishAt(ctx context.Context, topic string, msg *message.Message, at time.Time) (string, error) {
+	return g.PublishAfter(ctx, topic, msg, time.Until(at))
+}
+
+// PublishAfter 在 delay 之后发布消息
+func (g *Channel) PublishAfter(ctx context.Context, topic string, msg *message.Message, delay time.Duration) (string, error) {
+	if delay <= 0 {
+		return g.Publish(ctx, topic, msg)
+	}
+	scheduler, err := g.getScheduler()
+	if err != nil {
+		return "", err
 	}
+	return scheduler.PublishAfter(ctx, topic, msg, delay)
+}
 
-	messages, err := g.getClient(g.namespace).Subscribe(context.Background(), topic)
+// CancelScheduled 取消还没有发布的延迟消息
+func (g *Channel) CancelScheduled(ctx context.Context, topic, id string) error {
+	scheduler, err := g.getScheduler()
 	if err != nil {
 		return err
 	}
-	g.handlers.Set(topic, []comm.MessageHandler{handler})
-
-	goroutines.GoAsync(func(params ...interface{}) {
-		topicTemp := conv.String(params[0])
-		for msg := range messages {
-			g.dispatchMessages(topicTemp, msg)
-		}
-	}, topic)
-
-	return nil
+	return scheduler.CancelScheduled(ctx, topic, id)
 }
 
-func (g *Channel) dispatchMessages(topic string, msg *message.Message) {
-	allHandlers, ok := g.handlers.Get(topic)
-	if !ok {
-		log.Println("no handlers for topic: " + topic)
-		return
+func (g *Channel) getScheduler() (*comm.MemoryScheduler, error) {
+	g.schedulerMu.Lock()
+	defer g.schedulerMu.Unlock()
+	if g.scheduler != nil {
+		return g.scheduler, nil
 	}
-	var retError error
-	for _, handler := range allHandlers {
-		if err := handler(msg.UUID, string(msg.Payload)); err != nil {
-			err = g.retryMessage(msg, handler, err)
-			if err != nil {
-				retError = multierror.Append(retError, err)
-			}
-		}
-	}
-	if retError != nil {
-		log.Println("Subscribe handler error: ", retError.Error())
+	scheduler, err := comm.NewMemoryScheduler(g, comm.DefaultScheduleInterval)
+	if err != nil {
+		return nil, err
 	}
-	msg.Ack() //最终都表示处理完毕，避免始终卡住队列了
+	g.scheduler = scheduler
+	return scheduler, nil
 }
 
-func (g *Channel) retryMessage(msg *message.Message, handler comm.MessageHandler, oldError error) error {
-	if g.retryTimes <= 0 {
-		return oldError
+func (g *Channel) Subscribe(topic string, handler comm.HandlerFunc) error {
+	if !g.router.AddHandler(topic, handler) {
+		return nil
 	}
-	retryTimes := g.retryTimes
 
-	var retError error
-	for i := retryTimes; i > 0; i-- {
-		if err := handler(msg.UUID, string(msg.Payload)); err != nil {
-			retError = multierror.Append(retError, err)
-		} else {
-			return nil
-		}
-	}
-	if retError == nil {
-		return nil
+	messages, err := g.getClient(g.namespace).Subscribe(context.Background(), topic)
+	if err != nil {
+		g.router.RemoveHandlers(topic)
+		return err
 	}
 
-	if g.errorHandler != nil {
-		err := g.errorHandler(msg)
-		if err == nil {
-			return nil
+	goroutines.GoAsync(func(params ...interface{}) {
+		topicTemp := conv.String(params[0])
+		for msg := range messages {
+			//发布方的ctx可能已经取消，这里只保留ctx中的值
+			g.router.Deliver(context.WithoutCancel(msg.Context()), topicTemp, msg)
 		}
-	}
+	}, topic)
 
-	return retError
+	return nil
 }
 
-func (g *Channel) SubscribeNew(topic string, handler comm.MessageHandler) error {
+// SubscribeNew 单独订阅，和 Subscribe 注册的处理方法互不影响
+func (g *Channel) SubscribeNew(topic string, handler comm.HandlerFunc) error {
 	messages, err := g.getClient(g.namespace).Subscribe(context.Background(), topic)
 	if err != nil {
 		return err
 	}
 
+	subscription := watermill.NewShortUUID()
 	goroutines.GoAsync(func(params ...interface{}) {
 		for msg := range messages {
-			if err = handler(msg.UUID, string(msg.Payload)); err != nil {
-				err = g.retryMessage(msg, handler, err)
-				if err == nil {
-					msg.Ack()
-					continue
-				}
-				log.Println("SubscribeNew handler error: ", err.Error())
-				msg.Ack() //避免卡住队列
-			} else {
-				msg.Ack()
-			}
+			g.router.DeliverHandler(context.WithoutCancel(msg.Context()), subscription, topic, msg, handler)
 		}
 	})
 
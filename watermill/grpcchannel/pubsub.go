@@ -5,18 +5,24 @@ import (
 	"fmt"
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
-	"github.com/hashicorp/go-multierror"
 	"github.com/magic-lib/go-plat-utils/conv"
 	"github.com/magic-lib/go-plat-utils/goroutines"
 	comm "github.com/magic-lib/go-servicekit/watermill"
 	"github.com/magic-lib/go-servicekit/watermill/grpcchannel/pubsub"
-	cmap "github.com/orcaman/concurrent-map/v2"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"log"
 	"sync"
+	"time"
 )
 
+// reconnectBackoff 订阅流断开后重新订阅的间隔
+var reconnectBackoff = comm.Backoff{
+	InitialInterval: 200 * time.Millisecond,
+	MaxInterval:     10 * time.Second,
+	Multiplier:      2,
+}
+
 type Channel struct {
 	HostAddress string // 服务端地址,自身地址，比如: 0.0.0.0:31116 ，启动服务
 	server      *grpc.Server
@@ -24,13 +30,25 @@ type Channel struct {
 	ServerAddress string //连接服务器地址：比如:192.168.2.84:31116，连接服务器
 	connServer    *grpc.ClientConn
 
-	Namespace    string
-	handlers     cmap.ConcurrentMap[string, []comm.MessageHandler]
-	subsMu       sync.RWMutex
-	retryTimes   int
-	errorHandler func(msg *message.Message) error
+	Namespace     string
+	ConsumerGroup string       //消费组，同一个消费组内负载均衡，为空则每个订阅者都收到全部消息
+	Server        ServerConfig //服务端配置，StartServe 时使用
+
+	// anonymousGroup 没有消费组时使用的匿名消费组，重连时不变，服务端在 AnonymousRetention 内为其保留消息
+	anonymousGroup string
+
+	router    *comm.Router
+	pubServer *pubSubServer
+	connMu    sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	scheduler   *comm.MemoryScheduler
+	schedulerMu sync.Mutex
 }
 
+var _ comm.PubSub = (*Channel)(nil)
+
 func New(cfg *Channel) (*Channel, error) {
 	if cfg.HostAddress == "" || cfg.ServerAddress == "" {
 		return nil, fmt.Errorf("hostAddress or serverAddress error")
@@ -42,8 +60,11 @@ func New(cfg *Channel) (*Channel, error) {
 	if cfg.Namespace != "" {
 		goChanTemp.Namespace = cfg.Namespace
 	}
-	goChanTemp.handlers = cmap.New[[]comm.MessageHandler]()
-	goChanTemp.retryTimes = 3
+	goChanTemp.ConsumerGroup = cfg.ConsumerGroup
+	goChanTemp.anonymousGroup = anonymousPrefix + watermill.NewUUID()
+	goChanTemp.Server = cfg.Server
+	goChanTemp.ctx, goChanTemp.cancel = context.WithCancel(context.Background())
+	goChanTemp.router = comm.NewRouter().WithPublisher(goChanTemp)
 	return goChanTemp, nil
 }
 
@@ -55,25 +76,28 @@ func (g *Channel) StartServe() error {
 	if g.server != nil {
 		return nil
 	}
-	s, err := startService(g.HostAddress)
+	s, ps, err := startService(g.HostAddress, g.Server)
 	if err != nil {
 		return err
 	}
 	g.server = s
+	g.pubServer = ps
 	return nil
 }
 func (g *Channel) getConnServer() (*grpc.ClientConn, error) {
 	if g.ServerAddress == "" {
 		return nil, fmt.Errorf("ServerAddress error")
 	}
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
 	if g.connServer != nil {
 		return g.connServer, nil
 	}
 
+	// 连接断开后 grpc 会自动重连，订阅流由 consume 重新建立
 	conn, err := grpc.NewClient(g.ServerAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
-		log.Fatalf("连接失败: %s: %v", g.ServerAddress, err)
-		return nil, err
+		return nil, fmt.Errorf("连接失败: %s: %w", g.ServerAddress, err)
 	}
 	g.connServer = conn
 
@@ -92,27 +116,47 @@ func (g *Channel) getClient() (pubsub.PubSubServiceClient, error) {
 }
 
 func (g *Channel) Close() {
+	g.cancel()
+	g.schedulerMu.Lock()
+	if g.scheduler != nil {
+		g.scheduler.Stop()
+	}
+	g.schedulerMu.Unlock()
 	if g.server != nil {
 		g.server.Stop()
 	}
+	if g.pubServer != nil {
+		g.pubServer.close()
+	}
 	if g.connServer != nil {
 		_ = g.connServer.Close()
 	}
 }
 
 func (g *Channel) WithRetryTimes(retryTimes int) *Channel {
-	g.retryTimes = retryTimes
+	g.router.WithRetryTimes(retryTimes)
 	return g
 }
 func (g *Channel) WithNamespace(ns string) *Channel {
 	g.Namespace = ns
 	return g
 }
+
+// WithFailure 重试后仍然失败时的处理方式，默认确认消息
+func (g *Channel) WithFailure(fc comm.FailureConfig) *Channel {
+	g.router.WithFailure(fc)
+	return g
+}
 func (g *Channel) WithErrorHandler(handler func(msg *message.Message) error) *Channel {
-	g.errorHandler = handler
+	g.router.WithErrorHandler(handler)
 	return g
 }
 
+// Use 添加处理方法的中间件
+func (g *Channel) Use(middlewares ...comm.Middleware) {
+	g.router.Use(middlewares...)
+}
+
 func (g *Channel) Publish(ctx context.Context, topic string, msg *message.Message) (string, error) {
 	if msg == nil {
 		return "", nil
@@ -121,14 +165,25 @@ func (g *Channel) Publish(ctx context.Context, topic string, msg *message.Messag
 	if err != nil {
 		return "", err
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	if msg.UUID == "" {
 		msg.UUID = watermill.NewUUID()
 	}
+	// 传输用的元数据写入副本，不修改调用方的消息，同一个消息可以重复发布
+	newMsg := message.NewMessageWithContext(ctx, msg.UUID, msg.Payload)
+	for k, v := range msg.Metadata {
+		newMsg.Metadata.Set(k, v)
+	}
+	comm.InjectTrace(ctx, newMsg)
+	comm.SetDeliveryID(newMsg)
 	_, err = client.Publish(ctx, &pubsub.Message{
 		Namespace:      g.Namespace,
 		Topic:          topic,
-		MessageId:      msg.UUID,
-		MessageContent: string(msg.Payload),
+		MessageId:      newMsg.UUID,
+		MessageContent: string(newMsg.Payload),
+		Metadata:       newMsg.Metadata,
 	})
 	if err != nil {
 		return "", err
@@ -136,98 +191,133 @@ func (g *Channel) Publish(ctx context.Context, topic string, msg *message.Messag
 	return msg.UUID, nil
 }
 
-func (g *Channel) Subscribe(topic string, handler comm.MessageHandler) error {
-	g.subsMu.Lock()
-	defer g.subsMu.Unlock()
-	if oldHandlers, ok := g.handlers.Get(topic); ok {
-		oldHandlers = append(oldHandlers, handler)
-		g.handlers.Set(topic, oldHandlers)
-		return nil
+// PublishAt 在 at 时间发布消息，延迟的消息保存在发布方的内存时间轮中，进程退出后会丢失
+func (g *Channel) PublishAt(ctx context.Context, topic string, msg *message.Message, at time.Time) (string, error) {
+	return g.PublishAfter(ctx, topic, msg, time.Until(at))
+}
+
+// PublishAfter 在 delay 之后发布消息
+func (g *Channel) PublishAfter(ctx context.Context, topic string, msg *message.Message, delay time.Duration) (string, error) {
+	if delay <= 0 {
+		return g.Publish(ctx, topic, msg)
+	}
+	scheduler, err := g.getScheduler()
+	if err != nil {
+		return "", err
 	}
+	return scheduler.PublishAfter(ctx, topic, msg, delay)
+}
 
-	client, err := g.getClient()
+// CancelScheduled 取消还没有发布的延迟消息
+func (g *Channel) CancelScheduled(ctx context.Context, topic, id string) error {
+	scheduler, err := g.getScheduler()
 	if err != nil {
 		return err
 	}
+	return scheduler.CancelScheduled(ctx, topic, id)
+}
 
-	stream, err := client.Subscribe(context.Background())
+func (g *Channel) getScheduler() (*comm.MemoryScheduler, error) {
+	g.schedulerMu.Lock()
+	defer g.schedulerMu.Unlock()
+	if g.scheduler != nil {
+		return g.scheduler, nil
+	}
+	scheduler, err := comm.NewMemoryScheduler(g, comm.DefaultScheduleInterval)
 	if err != nil {
-		return fmt.Errorf("订阅失败: %v", err)
+		return nil, err
 	}
+	g.scheduler = scheduler
+	return scheduler, nil
+}
 
+func (g *Channel) subscribeStream(topic string) (pubsub.PubSubService_SubscribeClient, error) {
+	client, err := g.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := client.Subscribe(g.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("订阅失败: %v", err)
+	}
+
+	group := g.ConsumerGroup
+	if group == "" {
+		group = g.anonymousGroup
+	}
 	if err = stream.Send(&pubsub.SubscribeRequest{
-		Namespace: g.Namespace,
-		Topic:     topic,
+		Namespace:     g.Namespace,
+		Topic:         topic,
+		ConsumerGroup: group,
 	}); err != nil {
-		return fmt.Errorf("发送订阅请求失败: %v", err)
+		return nil, fmt.Errorf("发送订阅请求失败: %v", err)
+	}
+	return stream, nil
+}
+
+func (g *Channel) Subscribe(topic string, handler comm.HandlerFunc) error {
+	if !g.router.AddHandler(topic, handler) {
+		return nil
+	}
+
+	stream, err := g.subscribeStream(topic)
+	if err != nil {
+		g.router.RemoveHandlers(topic)
+		return err
 	}
-	g.handlers.Set(topic, []comm.MessageHandler{handler})
 
 	goroutines.GoAsync(func(params ...interface{}) {
-		topicTemp := conv.String(params[0])
-		for {
-			res, err := stream.Recv()
-			if err != nil {
-				log.Printf("接收消息失败: %v", err)
-				continue
-			}
-			g.dispatchMessages(topicTemp, &message.Message{
-				UUID:    res.Message.MessageId,
-				Payload: []byte(res.Message.MessageContent),
-			})
-		}
+		g.consume(conv.String(params[0]), stream)
 	}, topic)
 	return nil
 }
 
-func (g *Channel) dispatchMessages(topic string, msg *message.Message) {
-	allHandlers, ok := g.handlers.Get(topic)
-	if !ok {
-		log.Println("no handlers for topic: " + topic)
-		return
-	}
-	var err error
-	var retError error
-	for _, handler := range allHandlers {
-		if err = handler(msg.UUID, string(msg.Payload)); err != nil {
-			err = g.retryMessage(msg, handler, err)
-			if err != nil {
-				retError = multierror.Append(retError, err)
+// consume 接收并处理消息，处理完成后向服务端确认，流断开后自动重新订阅
+func (g *Channel) consume(topic string, stream pubsub.PubSubService_SubscribeClient) {
+	attempts := 0
+	for {
+		if stream == nil {
+			attempts++
+			select {
+			case <-g.ctx.Done():
+				return
+			case <-time.After(reconnectBackoff.Next(attempts)):
+			}
+			var err error
+			if stream, err = g.subscribeStream(topic); err != nil {
+				log.Printf("重新订阅 Topic %s 失败: %v", topic, err)
+				continue
 			}
+			log.Printf("重新订阅 Topic %s 成功", topic)
 		}
-	}
-	if retError != nil {
-		log.Println("Subscribe handler error: ", retError.Error())
-		msg.Ack()
-		return
-	}
-	msg.Ack()
-}
 
-func (g *Channel) retryMessage(msg *message.Message, handler comm.MessageHandler, oldError error) error {
-	if g.retryTimes <= 0 {
-		return oldError
-	}
-	retryTimes := g.retryTimes
+		res, err := stream.Recv()
+		if err != nil {
+			if g.ctx.Err() != nil {
+				return
+			}
+			log.Printf("接收消息失败，准备重新订阅: %v", err)
+			stream = nil
+			continue
+		}
+		attempts = 0
 
-	var retError error
-	for i := retryTimes; i > 0; i-- {
-		if err := handler(msg.UUID, string(msg.Payload)); err != nil {
-			retError = multierror.Append(retError, err)
-		} else {
-			return nil
+		msg := message.NewMessage(res.Message.MessageId, []byte(res.Message.MessageContent))
+		for k, v := range res.Message.Metadata {
+			msg.Metadata.Set(k, v)
 		}
-	}
-	if retError == nil {
-		return nil
-	}
+		g.router.Deliver(g.ctx, topic, msg)
 
-	//还是有错误，则需要执行错误的处理方式
-	if g.errorHandler != nil {
-		err := g.errorHandler(msg)
-		if err == nil {
-			return nil
+		ack := &pubsub.Ack{DeliveryId: res.DeliveryId}
+		select {
+		case <-msg.Acked():
+		default:
+			ack.Nack = true
+		}
+		if err = stream.Send(&pubsub.SubscribeRequest{Ack: ack}); err != nil {
+			// 服务端会在订阅断开或者确认超时后重新投递
+			log.Printf("确认消息 %s 失败: %v", msg.UUID, err)
 		}
 	}
-	return retError
 }
@@ -28,6 +28,7 @@ type Message struct {
 	Topic          string                 `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
 	MessageId      string                 `protobuf:"bytes,3,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
 	MessageContent string                 `protobuf:"bytes,4,opt,name=message_content,json=messageContent,proto3" json:"message_content,omitempty"`
+	Metadata       map[string]string      `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // 消息元数据，比如链路信息
 	unknownFields  protoimpl.UnknownFields
 	sizeCache      protoimpl.SizeCache
 }
@@ -90,12 +91,20 @@ func (x *Message) GetMessageContent() string {
 	return ""
 }
 
-// SubscribeRequest 订阅请求：客户端指定要订阅的 Topic
+func (x *Message) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// SubscribeRequest 订阅请求：客户端指定要订阅的 Topic，同一个消费组内的订阅者负载均衡
 type SubscribeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
 	Topic         string                 `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
-	ConsumerGroup string                 `protobuf:"bytes,3,opt,name=consumer_group,json=consumerGroup,proto3" json:"consumer_group,omitempty"`
+	ConsumerGroup string                 `protobuf:"bytes,3,opt,name=consumer_group,json=consumerGroup,proto3" json:"consumer_group,omitempty"` // 以 ~ 开头表示没有消费组的订阅者，断开后保留一段时间，重连后继续接收
+	Ack           *Ack                   `protobuf:"bytes,4,opt,name=ack,proto3" json:"ack,omitempty"`                                          // 不为空时表示确认消息，不是订阅请求
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -151,17 +160,78 @@ func (x *SubscribeRequest) GetConsumerGroup() string {
 	return ""
 }
 
+func (x *SubscribeRequest) GetAck() *Ack {
+	if x != nil {
+		return x.Ack
+	}
+	return nil
+}
+
+// Ack 消息确认
+type Ack struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeliveryId    string                 `protobuf:"bytes,1,opt,name=delivery_id,json=deliveryId,proto3" json:"delivery_id,omitempty"`
+	Nack          bool                   `protobuf:"varint,2,opt,name=nack,proto3" json:"nack,omitempty"` // 处理失败，服务端需要重新投递
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ack) Reset() {
+	*x = Ack{}
+	mi := &file_proto_pubsub_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ack) ProtoMessage() {}
+
+func (x *Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_pubsub_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ack.ProtoReflect.Descriptor instead.
+func (*Ack) Descriptor() ([]byte, []int) {
+	return file_proto_pubsub_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Ack) GetDeliveryId() string {
+	if x != nil {
+		return x.DeliveryId
+	}
+	return ""
+}
+
+func (x *Ack) GetNack() bool {
+	if x != nil {
+		return x.Nack
+	}
+	return false
+}
+
 // 订阅响应：服务端向客户端推送的消息
 type SubscribeResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Message       *Message               `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	DeliveryId    string                 `protobuf:"bytes,2,opt,name=delivery_id,json=deliveryId,proto3" json:"delivery_id,omitempty"` // 投递ID，确认消息时使用
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *SubscribeResponse) Reset() {
 	*x = SubscribeResponse{}
-	mi := &file_proto_pubsub_proto_msgTypes[2]
+	mi := &file_proto_pubsub_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -173,7 +243,7 @@ func (x *SubscribeResponse) String() string {
 func (*SubscribeResponse) ProtoMessage() {}
 
 func (x *SubscribeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_pubsub_proto_msgTypes[2]
+	mi := &file_proto_pubsub_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -186,7 +256,7 @@ func (x *SubscribeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SubscribeResponse.ProtoReflect.Descriptor instead.
 func (*SubscribeResponse) Descriptor() ([]byte, []int) {
-	return file_proto_pubsub_proto_rawDescGZIP(), []int{2}
+	return file_proto_pubsub_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *SubscribeResponse) GetMessage() *Message {
@@ -196,6 +266,13 @@ func (x *SubscribeResponse) GetMessage() *Message {
 	return nil
 }
 
+func (x *SubscribeResponse) GetDeliveryId() string {
+	if x != nil {
+		return x.DeliveryId
+	}
+	return ""
+}
+
 // 空响应
 type Empty struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -205,7 +282,7 @@ type Empty struct {
 
 func (x *Empty) Reset() {
 	*x = Empty{}
-	mi := &file_proto_pubsub_proto_msgTypes[3]
+	mi := &file_proto_pubsub_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -217,7 +294,7 @@ func (x *Empty) String() string {
 func (*Empty) ProtoMessage() {}
 
 func (x *Empty) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_pubsub_proto_msgTypes[3]
+	mi := &file_proto_pubsub_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -230,26 +307,37 @@ func (x *Empty) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Empty.ProtoReflect.Descriptor instead.
 func (*Empty) Descriptor() ([]byte, []int) {
-	return file_proto_pubsub_proto_rawDescGZIP(), []int{3}
+	return file_proto_pubsub_proto_rawDescGZIP(), []int{4}
 }
 
 var File_proto_pubsub_proto protoreflect.FileDescriptor
 
 const file_proto_pubsub_proto_rawDesc = "" +
 	"\n" +
-	"\x12proto/pubsub.proto\x12\x06pubsub\"\x85\x01\n" +
+	"\x12proto/pubsub.proto\x12\x06pubsub\"\xfd\x01\n" +
 	"\aMessage\x12\x1c\n" +
 	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x14\n" +
 	"\x05topic\x18\x02 \x01(\tR\x05topic\x12\x1d\n" +
 	"\n" +
 	"message_id\x18\x03 \x01(\tR\tmessageId\x12'\n" +
-	"\x0fmessage_content\x18\x04 \x01(\tR\x0emessageContent\"m\n" +
+	"\x0fmessage_content\x18\x04 \x01(\tR\x0emessageContent\x129\n" +
+	"\bmetadata\x18\x05 \x03(\v2\x1d.pubsub.Message.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x8c\x01\n" +
 	"\x10SubscribeRequest\x12\x1c\n" +
 	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x14\n" +
 	"\x05topic\x18\x02 \x01(\tR\x05topic\x12%\n" +
-	"\x0econsumer_group\x18\x03 \x01(\tR\rconsumerGroup\">\n" +
+	"\x0econsumer_group\x18\x03 \x01(\tR\rconsumerGroup\x12\x1d\n" +
+	"\x03ack\x18\x04 \x01(\v2\v.pubsub.AckR\x03ack\":\n" +
+	"\x03Ack\x12\x1f\n" +
+	"\vdelivery_id\x18\x01 \x01(\tR\n" +
+	"deliveryId\x12\x12\n" +
+	"\x04nack\x18\x02 \x01(\bR\x04nack\"_\n" +
 	"\x11SubscribeResponse\x12)\n" +
-	"\amessage\x18\x01 \x01(\v2\x0f.pubsub.MessageR\amessage\"\a\n" +
+	"\amessage\x18\x01 \x01(\v2\x0f.pubsub.MessageR\amessage\x12\x1f\n" +
+	"\vdelivery_id\x18\x02 \x01(\tR\n" +
+	"deliveryId\"\a\n" +
 	"\x05Empty2\x80\x01\n" +
 	"\rPubSubService\x12D\n" +
 	"\tSubscribe\x12\x18.pubsub.SubscribeRequest\x1a\x19.pubsub.SubscribeResponse(\x010\x01\x12)\n" +
@@ -267,24 +355,28 @@ func file_proto_pubsub_proto_rawDescGZIP() []byte {
 	return file_proto_pubsub_proto_rawDescData
 }
 
-var file_proto_pubsub_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto_pubsub_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_proto_pubsub_proto_goTypes = []any{
 	(*Message)(nil),           // 0: pubsub.Message
 	(*SubscribeRequest)(nil),  // 1: pubsub.SubscribeRequest
-	(*SubscribeResponse)(nil), // 2: pubsub.SubscribeResponse
-	(*Empty)(nil),             // 3: pubsub.Empty
+	(*Ack)(nil),               // 2: pubsub.Ack
+	(*SubscribeResponse)(nil), // 3: pubsub.SubscribeResponse
+	(*Empty)(nil),             // 4: pubsub.Empty
+	nil,                       // 5: pubsub.Message.MetadataEntry
 }
 var file_proto_pubsub_proto_depIdxs = []int32{
-	0, // 0: pubsub.SubscribeResponse.message:type_name -> pubsub.Message
-	1, // 1: pubsub.PubSubService.Subscribe:input_type -> pubsub.SubscribeRequest
-	0, // 2: pubsub.PubSubService.Publish:input_type -> pubsub.Message
-	2, // 3: pubsub.PubSubService.Subscribe:output_type -> pubsub.SubscribeResponse
-	3, // 4: pubsub.PubSubService.Publish:output_type -> pubsub.Empty
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	5, // 0: pubsub.Message.metadata:type_name -> pubsub.Message.MetadataEntry
+	2, // 1: pubsub.SubscribeRequest.ack:type_name -> pubsub.Ack
+	0, // 2: pubsub.SubscribeResponse.message:type_name -> pubsub.Message
+	1, // 3: pubsub.PubSubService.Subscribe:input_type -> pubsub.SubscribeRequest
+	0, // 4: pubsub.PubSubService.Publish:input_type -> pubsub.Message
+	3, // 5: pubsub.PubSubService.Subscribe:output_type -> pubsub.SubscribeResponse
+	4, // 6: pubsub.PubSubService.Publish:output_type -> pubsub.Empty
+	5, // [5:7] is the sub-list for method output_type
+	3, // [3:5] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_proto_pubsub_proto_init() }
@@ -298,7 +390,7 @@ func file_proto_pubsub_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_pubsub_proto_rawDesc), len(file_proto_pubsub_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
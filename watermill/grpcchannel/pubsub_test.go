@@ -4,8 +4,13 @@ import (
 	"context"
 	"fmt"
 	"github.com/ThreeDotsLabs/watermill/message"
+	comm "github.com/magic-lib/go-servicekit/watermill"
 	"github.com/magic-lib/go-servicekit/watermill/grpcchannel"
 	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -25,9 +30,9 @@ func TestPubSub(t *testing.T) {
 		log.Println(err)
 		return
 	}
-	err = ch.Subscribe("test", func(msgId string, msgContent string) error {
-		fmt.Println("receive id: ", msgId)
-		fmt.Println("receive body: ", msgContent)
+	err = ch.Subscribe("test", func(ctx context.Context, msg *message.Message) error {
+		fmt.Println("receive id: ", msg.UUID)
+		fmt.Println("receive body: ", string(msg.Payload))
 		return nil
 	})
 	if err != nil {
@@ -35,10 +40,10 @@ func TestPubSub(t *testing.T) {
 		return
 	}
 
-	err = ch.Subscribe("test", func(msgId string, msgContent string) error {
+	err = ch.Subscribe("test", func(ctx context.Context, msg *message.Message) error {
 		time.Sleep(2 * time.Second)
-		fmt.Println("receive id2: ", msgId)
-		fmt.Println("receive body2: ", msgContent)
+		fmt.Println("receive id2: ", msg.UUID)
+		fmt.Println("receive body2: ", string(msg.Payload))
 		return nil
 	})
 	if err != nil {
@@ -61,3 +66,193 @@ func TestPubSub(t *testing.T) {
 
 	time.Sleep(10 * time.Second)
 }
+
+func newTestChannel(t *testing.T, port int, group string, serve bool, server grpcchannel.ServerConfig) *grpcchannel.Channel {
+	ch, err := grpcchannel.New(&grpcchannel.Channel{
+		Namespace:     "unittest",
+		HostAddress:   fmt.Sprintf("127.0.0.1:%d", port),
+		ServerAddress: fmt.Sprintf("127.0.0.1:%d", port),
+		ConsumerGroup: group,
+		Server:        server,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serve {
+		if err = ch.StartServe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ch
+}
+
+func TestConsumerGroup(t *testing.T) {
+	server := newTestChannel(t, 31107, "workers", true, grpcchannel.ServerConfig{})
+	defer server.Close()
+	worker := newTestChannel(t, 31107, "workers", false, grpcchannel.ServerConfig{})
+	defer worker.Close()
+
+	var mu sync.Mutex
+	received := map[string]int{}
+	wg := sync.WaitGroup{}
+	wg.Add(10)
+	handler := func(name string) comm.HandlerFunc {
+		return func(ctx context.Context, msg *message.Message) error {
+			mu.Lock()
+			received[name]++
+			mu.Unlock()
+			wg.Done()
+			return nil
+		}
+	}
+	if err := server.Subscribe("jobs", handler("server")); err != nil {
+		t.Fatal(err)
+	}
+	if err := worker.Subscribe("jobs", handler("worker")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond) // 等待服务端收到订阅请求
+
+	for i := 0; i < 10; i++ {
+		if _, err := server.Publish(context.Background(), "jobs", message.NewMessage(fmt.Sprintf("job-%d", i), []byte("run"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitTimeout(t, &wg, 5*time.Second)
+	if received["server"] == 0 || received["worker"] == 0 {
+		t.Errorf("messages should be balanced across the group, got %v", received)
+	}
+}
+
+func TestNackRedelivery(t *testing.T) {
+	ch := newTestChannel(t, 31108, "retry", true, grpcchannel.ServerConfig{})
+	defer ch.Close()
+	ch.WithRetryTimes(0).WithFailure(comm.FailureConfig{
+		Policy:  comm.FailureNack,
+		Backoff: comm.Backoff{InitialInterval: 10 * time.Millisecond},
+	})
+
+	var calls int32
+	done := make(chan struct{})
+	err := ch.Subscribe("mail", func(ctx context.Context, msg *message.Message) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return fmt.Errorf("smtp unavailable")
+		}
+		close(done)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if _, err = ch.Publish(context.Background(), "mail", message.NewMessage("mail-1", []byte("hi"))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("nacked message was not redelivered by the server")
+	}
+}
+
+func TestWALPersistence(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "pubsub.wal")
+	server := newTestChannel(t, 31109, "", true, grpcchannel.ServerConfig{WALPath: walPath})
+	consumer := newTestChannel(t, 31109, "orders", false, grpcchannel.ServerConfig{})
+	if err := consumer.Subscribe("created", func(ctx context.Context, msg *message.Message) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	consumer.Close() // 消费者离线
+	time.Sleep(200 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if _, err := server.Publish(context.Background(), "created", message.NewMessage(fmt.Sprintf("order-%d", i), []byte("new"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.Close() // 服务端重启
+
+	server = newTestChannel(t, 31110, "", true, grpcchannel.ServerConfig{WALPath: walPath})
+	defer server.Close()
+	consumer = newTestChannel(t, 31110, "orders", false, grpcchannel.ServerConfig{})
+	defer consumer.Close()
+
+	var mu sync.Mutex
+	var ids []string
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	if err := consumer.Subscribe("created", func(ctx context.Context, msg *message.Message) error {
+		mu.Lock()
+		ids = append(ids, msg.UUID)
+		mu.Unlock()
+		wg.Done()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitTimeout(t, &wg, 5*time.Second)
+	if strings.Join(ids, ",") != "order-0,order-1,order-2" {
+		t.Errorf("replayed messages = %v", ids)
+	}
+}
+
+func waitTimeout(t *testing.T, wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatal("timeout waiting for messages")
+	}
+}
+
+func TestPublishKeepsMetadata(t *testing.T) {
+	ch := newTestChannel(t, 31111, "meta", true, grpcchannel.ServerConfig{})
+	defer ch.Close()
+
+	var mu sync.Mutex
+	deliveryIds := map[string]bool{}
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	if err := ch.Subscribe("metadata", func(ctx context.Context, msg *message.Message) error {
+		mu.Lock()
+		deliveryIds[msg.Metadata.Get(comm.DeliveryIDKey)] = true
+		mu.Unlock()
+		if msg.Metadata.Get("tenant") != "t1" {
+			t.Errorf("tenant metadata = %q", msg.Metadata.Get("tenant"))
+		}
+		wg.Done()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// 同一个消息并发重复发布，调用方的元数据不变
+	msg := message.NewMessage("same", []byte("hi"))
+	msg.Metadata.Set("tenant", "t1")
+	var published sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		published.Add(1)
+		go func() {
+			defer published.Done()
+			if _, err := ch.Publish(context.Background(), "metadata", msg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	published.Wait()
+	waitTimeout(t, &wg, 5*time.Second)
+	if len(msg.Metadata) != 1 {
+		t.Errorf("caller metadata = %v", msg.Metadata)
+	}
+	if len(deliveryIds) != 4 {
+		t.Errorf("delivery ids = %v, want 4 different ids", deliveryIds)
+	}
+}
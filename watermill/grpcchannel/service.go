@@ -3,57 +3,147 @@ package grpcchannel
 import (
 	"context"
 	"fmt"
-	"github.com/magic-lib/go-plat-utils/goroutines"
-	"github.com/magic-lib/go-servicekit/watermill/grpcchannel/pubsub"
-	cmap "github.com/orcaman/concurrent-map/v2"
-	"github.com/samber/lo"
-	"google.golang.org/grpc"
 	"log"
 	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/magic-lib/go-plat-utils/goroutines"
+	"github.com/magic-lib/go-servicekit/watermill/grpcchannel/pubsub"
+	"google.golang.org/grpc"
 )
 
+const (
+	defaultMaxInFlight = 100
+	defaultAckTimeout  = 30 * time.Second
+	defaultMaxPending  = 10000
+	// defaultAnonymousRetention 没有消费组的订阅者断开后保留消息的时间
+	defaultAnonymousRetention = time.Minute
+	// anonymousPrefix 没有消费组的订阅者由客户端生成的消费组名称前缀
+	anonymousPrefix = "~"
+)
+
+// ServerConfig 服务端配置
+type ServerConfig struct {
+	MaxInFlight   int           `json:",optional"` //每个订阅者未确认消息的上限，也是发送队列的长度，默认100
+	AckTimeout    time.Duration `json:",optional"` //超过该时间未确认的消息重新投递，默认30s
+	MaxRedelivery int           `json:",optional"` //最大重新投递次数，超过后丢弃，0表示不限制
+	MaxPending    int           `json:",optional"` //每个消费组积压消息的上限，超过后丢弃最早的消息，默认10000
+	WALPath       string        `json:",optional"` //持久化文件路径，为空则只保存在内存中
+	//没有消费组的订阅者断开后继续为其保留消息的时间，期间重连不会丢失消息，默认1分钟。
+	//消息只保存在内存中，不写入持久化文件，超过时间没有重连或者服务端重启时丢失
+	AnonymousRetention time.Duration `json:",optional"`
+}
+
+func (c ServerConfig) withDefaults() ServerConfig {
+	if c.MaxInFlight <= 0 {
+		c.MaxInFlight = defaultMaxInFlight
+	}
+	if c.AckTimeout <= 0 {
+		c.AckTimeout = defaultAckTimeout
+	}
+	if c.MaxPending <= 0 {
+		c.MaxPending = defaultMaxPending
+	}
+	if c.AnonymousRetention <= 0 {
+		c.AnonymousRetention = defaultAnonymousRetention
+	}
+	return c
+}
+
+type pendingMessage struct {
+	seq      uint64
+	msg      *pubsub.Message
+	attempts int
+}
+
+type inflightMessage struct {
+	pm       *pendingMessage
+	sub      *subscriber
+	deadline time.Time
+}
+
+// subscriber 一个订阅，拥有独立的有界发送队列，慢订阅者不会阻塞发布和其他订阅者
 type subscriber struct {
+	group    *consumerGroup
+	stream   *subscribeStream
+	sendCh   chan *pubsub.SubscribeResponse // 只在持有 group.mu 时写入
+	inflight int                            // 由 group.mu 保护，确认超时后减少，但消息可能还在 sendCh 中
+	closed   chan struct{}
+}
+
+// subscribeStream 一个双向流，可以有多个订阅，发送需要串行
+type subscribeStream struct {
 	stream pubsub.PubSubService_SubscribeServer
+	sendMu sync.Mutex
+}
+
+func (s *subscribeStream) send(resp *pubsub.SubscribeResponse) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.stream.Send(resp)
+}
+
+// consumerGroup 同一个消费组内的订阅者负载均衡，不同消费组各自收到全部消息
+type consumerGroup struct {
+	key     string // namespace/topic
+	name    string
+	durable bool // 命名消费组，没有订阅者时也保留消息
+	// retain 客户端生成的匿名消费组，没有订阅者后保留 AnonymousRetention，idleSince 为最后一个订阅者断开的时间
+	retain    bool
+	idleSince time.Time
+	mu        sync.Mutex
+	members   []*subscriber
+	next      int
+	pending   []*pendingMessage
+	inflight  map[string]*inflightMessage // deliveryId -> 消息
 }
 
 // 服务端实现
 type pubSubServer struct {
 	pubsub.UnimplementedPubSubServiceServer
-	subscribers cmap.ConcurrentMap[string, []subscriber] // key: topic, value: 订阅者列表
-	mu          sync.Mutex
+	cfg         ServerConfig
+	mu          sync.RWMutex
+	groups      map[string]map[string]*consumerGroup // key -> 消费组名称 -> 消费组
+	deliveries  sync.Map                             // deliveryId -> *consumerGroup
+	seq         atomic.Uint64
+	deliverySeq atomic.Uint64
+	wal         *wal
+	compacting  atomic.Bool
+	done        chan struct{}
 }
 
 // 新建服务端实例
-func newPubSubServer() *pubSubServer {
-	return &pubSubServer{
-		subscribers: cmap.New[[]subscriber](),
+func newPubSubServer(cfg ServerConfig) (*pubSubServer, error) {
+	s := &pubSubServer{
+		cfg:    cfg.withDefaults(),
+		groups: make(map[string]map[string]*consumerGroup),
+		done:   make(chan struct{}),
 	}
-}
-
-// Subscribe 实现双向流：客户端发送订阅请求，服务端持续推送消息
-func (s *pubSubServer) Subscribe(stream pubsub.PubSubService_SubscribeServer) error {
-	for {
-		req, err := stream.Recv() // 阻塞等待客户端发送订阅请求
+	if s.cfg.WALPath != "" {
+		w, state, err := openWAL(s.cfg.WALPath)
 		if err != nil {
-			s.removeSubscriber(stream)
-			return err
-		}
-		cacheKey := s.getCacheKey(req.Namespace, req.Topic)
-		s.mu.Lock()
-		// 将客户端流添加到该 Topic 的订阅者列表
-		var list []subscriber
-		var ok bool
-		if list, ok = s.subscribers.Get(cacheKey); ok {
-			list = append(list, subscriber{stream: stream})
+			return nil, err
 		}
-		if list == nil {
-			list = []subscriber{{stream: stream}}
+		s.wal = w
+		s.seq.Store(state.lastSeq)
+		for key, groups := range state.groups {
+			for _, name := range groups {
+				group := s.getGroup(key, name, true)
+				for _, rec := range state.pending[key+"/"+name] {
+					group.pending = append(group.pending, &pendingMessage{seq: rec.Seq, msg: rec.message()})
+				}
+			}
 		}
-		s.subscribers.Set(cacheKey, list)
-		s.mu.Unlock()
-		log.Printf("客户端订阅了 Topic: %s", req.Topic)
 	}
+	goroutines.GoAsync(func(params ...interface{}) {
+		s.checkAckTimeout()
+	})
+	return s, nil
 }
 
 func (s *pubSubServer) getCacheKey(namespace, topic string) string {
@@ -66,60 +156,443 @@ func (s *pubSubServer) getCacheKey(namespace, topic string) string {
 	return fmt.Sprintf("%s/%s", namespace, topic)
 }
 
-// Publish 实现消息发布：接收消息并推送给所有订阅者
-func (s *pubSubServer) Publish(ctx context.Context, msg *pubsub.Message) (*pubsub.Empty, error) {
-	cacheKey := s.getCacheKey(msg.Namespace, msg.Topic)
+// getGroup 获取或者创建消费组，调用方需要持有 s.mu 写锁
+func (s *pubSubServer) getGroup(key, name string, durable bool) *consumerGroup {
+	groups, ok := s.groups[key]
+	if !ok {
+		groups = make(map[string]*consumerGroup)
+		s.groups[key] = groups
+	}
+	if group, ok := groups[name]; ok {
+		return group
+	}
+	group := &consumerGroup{
+		key:      key,
+		name:     name,
+		durable:  durable,
+		inflight: make(map[string]*inflightMessage),
+	}
+	groups[name] = group
+	return group
+}
+
+// Subscribe 实现双向流：客户端发送订阅请求和消息确认，服务端持续推送消息
+func (s *pubSubServer) Subscribe(stream pubsub.PubSubService_SubscribeServer) error {
+	ss := &subscribeStream{stream: stream}
+	var subs []*subscriber
+	defer func() {
+		for _, sub := range subs {
+			s.removeSubscriber(sub)
+		}
+	}()
+	for {
+		req, err := stream.Recv() // 阻塞等待客户端发送订阅请求或者确认
+		if err != nil {
+			return err
+		}
+		if req.Ack != nil {
+			s.ack(req.Ack)
+			continue
+		}
+		sub, err := s.addSubscriber(ss, req)
+		if err != nil {
+			return err
+		}
+		subs = append(subs, sub)
+		log.Printf("客户端订阅了 Topic: %s, 消费组: %s", req.Topic, sub.group.name)
+	}
+}
+
+func (s *pubSubServer) addSubscriber(ss *subscribeStream, req *pubsub.SubscribeRequest) (*subscriber, error) {
+	key := s.getCacheKey(req.Namespace, req.Topic)
+	name := req.ConsumerGroup
+	durable := name != "" && !strings.HasPrefix(name, anonymousPrefix)
+	retain := strings.HasPrefix(name, anonymousPrefix)
+	if name == "" {
+		// 旧版本客户端没有生成匿名消费组，订阅者各自收到全部消息，断开后不再保留
+		name = anonymousPrefix + strconv.FormatUint(s.deliverySeq.Add(1), 10)
+	}
+
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	group, existed := s.groups[key][name]
+	if !existed {
+		if durable && s.wal != nil {
+			if _, err := s.wal.append(&walRecord{Op: walOpGroup, Key: key, Group: name}); err != nil {
+				s.mu.Unlock()
+				return nil, err
+			}
+		}
+		group = s.getGroup(key, name, durable)
+		group.retain = retain
+	}
+	// 持有 s.mu 时加入订阅者，避免匿名消费组在加入前被清理
+	group.mu.Lock()
+	s.mu.Unlock()
 
-	// 遍历该 Topic 的所有订阅者，推送消息
-	if list, ok := s.subscribers.Get(cacheKey); ok {
-		lo.ForEach(list, func(sub subscriber, _ int) {
-			err := sub.stream.Send(&pubsub.SubscribeResponse{Message: msg})
-			if err != nil {
+	sub := &subscriber{
+		group:  group,
+		stream: ss,
+		sendCh: make(chan *pubsub.SubscribeResponse, s.cfg.MaxInFlight),
+		closed: make(chan struct{}),
+	}
+	goroutines.GoAsync(func(params ...interface{}) {
+		s.sendLoop(sub)
+	})
+
+	group.members = append(group.members, sub)
+	group.idleSince = time.Time{}
+	s.dispatchLocked(group)
+	group.mu.Unlock()
+	return sub, nil
+}
+
+func (s *pubSubServer) sendLoop(sub *subscriber) {
+	for {
+		select {
+		case <-sub.closed:
+			return
+		case resp := <-sub.sendCh:
+			if _, ok := s.deliveries.Load(resp.DeliveryId); !ok {
+				continue // 排队期间已经确认超时并重新投递，不再发送
+			}
+			if err := sub.stream.send(resp); err != nil {
 				log.Printf("推送消息给订阅者失败: %v", err)
+				// 未确认的消息会在订阅者断开或者确认超时后重新投递
 			}
+		}
+	}
+}
+
+func (s *pubSubServer) removeSubscriber(sub *subscriber) {
+	group := sub.group
+	group.mu.Lock()
+	close(sub.closed)
+	for i, one := range group.members {
+		if one == sub {
+			group.members = append(group.members[:i], group.members[i+1:]...)
+			break
+		}
+	}
+	// 该订阅者未确认的消息重新投递给其他订阅者
+	var redeliver []*pendingMessage
+	for deliveryId, inflight := range group.inflight {
+		if inflight.sub != sub {
+			continue
+		}
+		delete(group.inflight, deliveryId)
+		s.deliveries.Delete(deliveryId)
+		redeliver = append(redeliver, inflight.pm)
+	}
+	s.requeueLocked(group, redeliver)
+	idle := !group.durable && len(group.members) == 0
+	if idle {
+		group.idleSince = time.Now()
+	} else {
+		s.dispatchLocked(group)
+	}
+	group.mu.Unlock()
+
+	// 客户端生成的匿名消费组保留到 AnonymousRetention 后由 removeIdleGroups 清理
+	if idle && !group.retain {
+		s.removeIdleGroups(func(g *consumerGroup) bool {
+			return g == group
 		})
 	}
-	return &pubsub.Empty{}, nil
 }
 
-func (s *pubSubServer) removeSubscriber(stream pubsub.PubSubService_SubscribeServer) {
+// removeIdleGroups 删除没有订阅者且满足 expired 的匿名消费组
+func (s *pubSubServer) removeIdleGroups(expired func(group *consumerGroup) bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	for key, groups := range s.groups {
+		for name, group := range groups {
+			if group.durable {
+				continue
+			}
+			group.mu.Lock()
+			remove := len(group.members) == 0 && !group.idleSince.IsZero() && expired(group)
+			group.mu.Unlock()
+			if remove {
+				delete(groups, name)
+			}
+		}
+		if len(groups) == 0 {
+			delete(s.groups, key)
+		}
+	}
+}
 
-	for cacheKey, subs := range s.subscribers.Items() {
-		newSubs := make([]subscriber, 0)
-		isRemoved := false
-		for _, sub := range subs {
-			if sub.stream != stream {
-				newSubs = append(newSubs, sub)
-			} else {
-				isRemoved = true
+// requeueLocked 消息放回队列头部等待重新投递，超过最大投递次数的丢弃
+func (s *pubSubServer) requeueLocked(group *consumerGroup, list []*pendingMessage) {
+	if len(list) == 0 {
+		return
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].seq < list[j].seq })
+	requeue := make([]*pendingMessage, 0, len(list))
+	for _, pm := range list {
+		pm.attempts++
+		if s.cfg.MaxRedelivery > 0 && pm.attempts > s.cfg.MaxRedelivery {
+			log.Printf("消息 %s 超过最大投递次数，丢弃, Topic: %s, 消费组: %s", pm.msg.MessageId, group.key, group.name)
+			s.walAck(group, pm)
+			continue
+		}
+		requeue = append(requeue, pm)
+	}
+	group.pending = append(requeue, group.pending...)
+}
+
+// dispatchLocked 把积压的消息按轮询投递给有空闲的订阅者，调用方需要持有 group.mu。
+// 确认超时的消息可能还在慢订阅者的发送队列中没有发出，所以除了未确认数还要看发送队列是否已满，
+// 持有 group.mu 时不能阻塞在发送队列上
+func (s *pubSubServer) dispatchLocked(group *consumerGroup) {
+	for len(group.pending) > 0 {
+		sub := s.pickSubscriberLocked(group)
+		if sub == nil {
+			return
+		}
+		pm := group.pending[0]
+		deliveryId := strconv.FormatUint(s.deliverySeq.Add(1), 10)
+		select {
+		case sub.sendCh <- &pubsub.SubscribeResponse{Message: pm.msg, DeliveryId: deliveryId}:
+		default:
+			// 发送队列只在 group.mu 下写入，这里一般不会满，满了就留在队列中等待下次投递
+			return
+		}
+		group.pending[0] = nil
+		group.pending = group.pending[1:]
+		group.inflight[deliveryId] = &inflightMessage{
+			pm:       pm,
+			sub:      sub,
+			deadline: time.Now().Add(s.cfg.AckTimeout),
+		}
+		s.deliveries.Store(deliveryId, group)
+		sub.inflight++
+	}
+}
+
+// pickSubscriberLocked 轮询选择未确认数没有达到上限、发送队列也没有满的订阅者
+func (s *pubSubServer) pickSubscriberLocked(group *consumerGroup) *subscriber {
+	for i := 0; i < len(group.members); i++ {
+		sub := group.members[(group.next+i)%len(group.members)]
+		if sub.inflight < s.cfg.MaxInFlight && len(sub.sendCh) < cap(sub.sendCh) {
+			group.next = (group.next + i + 1) % len(group.members)
+			return sub
+		}
+	}
+	return nil
+}
+
+func (s *pubSubServer) ack(ack *pubsub.Ack) {
+	v, ok := s.deliveries.LoadAndDelete(ack.DeliveryId)
+	if !ok {
+		return // 已经超时重新投递了
+	}
+	group := v.(*consumerGroup)
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	inflight, ok := group.inflight[ack.DeliveryId]
+	if !ok {
+		return
+	}
+	delete(group.inflight, ack.DeliveryId)
+	inflight.sub.inflight--
+	if ack.Nack {
+		s.requeueLocked(group, []*pendingMessage{inflight.pm})
+	} else {
+		s.walAck(group, inflight.pm)
+	}
+	s.dispatchLocked(group)
+}
+
+// checkAckTimeout 超时未确认的消息重新投递
+func (s *pubSubServer) checkAckTimeout() {
+	interval := s.cfg.AckTimeout / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			for _, group := range s.allGroups() {
+				group.mu.Lock()
+				var redeliver []*pendingMessage
+				for deliveryId, inflight := range group.inflight {
+					if now.Before(inflight.deadline) {
+						continue
+					}
+					delete(group.inflight, deliveryId)
+					s.deliveries.Delete(deliveryId)
+					inflight.sub.inflight--
+					redeliver = append(redeliver, inflight.pm)
+				}
+				s.requeueLocked(group, redeliver)
+				s.dispatchLocked(group)
+				group.mu.Unlock()
 			}
+			s.removeIdleGroups(func(group *consumerGroup) bool {
+				return now.Sub(group.idleSince) >= s.cfg.AnonymousRetention
+			})
 		}
-		if isRemoved {
-			s.subscribers.Set(cacheKey, newSubs)
+	}
+}
+
+func (s *pubSubServer) allGroups() []*consumerGroup {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var list []*consumerGroup
+	for _, groups := range s.groups {
+		for _, group := range groups {
+			list = append(list, group)
 		}
 	}
+	return list
 }
 
-func startService(address string) (*grpc.Server, error) {
+// Publish 实现消息发布：消息写入每个消费组的队列后立即返回，不等待订阅者
+func (s *pubSubServer) Publish(ctx context.Context, msg *pubsub.Message) (*pubsub.Empty, error) {
+	key := s.getCacheKey(msg.Namespace, msg.Topic)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	groups := s.groups[key]
+	if len(groups) == 0 {
+		return &pubsub.Empty{}, nil
+	}
+
+	pm := &pendingMessage{seq: s.seq.Add(1), msg: msg}
+	if s.wal != nil {
+		var durable []string
+		for name, group := range groups {
+			if group.durable {
+				durable = append(durable, name)
+			}
+		}
+		if len(durable) > 0 {
+			_, err := s.wal.append(&walRecord{
+				Op:       walOpPublish,
+				Seq:      pm.seq,
+				Key:      key,
+				Groups:   durable,
+				Id:       msg.MessageId,
+				Content:  msg.MessageContent,
+				Metadata: msg.Metadata,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, group := range groups {
+		group.mu.Lock()
+		// 每个消费组使用独立的副本，重新投递次数互不影响
+		one := &pendingMessage{seq: pm.seq, msg: pm.msg}
+		if len(group.pending) >= s.cfg.MaxPending {
+			dropped := group.pending[0]
+			group.pending = group.pending[1:]
+			log.Printf("消费组积压超过上限，丢弃消息 %s, Topic: %s, 消费组: %s", dropped.msg.MessageId, group.key, group.name)
+			s.walAck(group, dropped)
+		}
+		group.pending = append(group.pending, one)
+		s.dispatchLocked(group)
+		group.mu.Unlock()
+	}
+	return &pubsub.Empty{}, nil
+}
+
+// walAck 记录消费组已经处理完消息
+func (s *pubSubServer) walAck(group *consumerGroup, pm *pendingMessage) {
+	if s.wal == nil || !group.durable {
+		return
+	}
+	needCompact, err := s.wal.append(&walRecord{Op: walOpAck, Seq: pm.seq, Key: group.key, Group: group.name})
+	if err != nil {
+		log.Printf("写入持久化文件失败: %v", err)
+		return
+	}
+	if needCompact && s.compacting.CompareAndSwap(false, true) {
+		goroutines.GoAsync(func(params ...interface{}) {
+			defer s.compacting.Store(false)
+			s.compact()
+		})
+	}
+}
+
+// compact 用内存中未确认的消息重写持久化文件
+func (s *pubSubServer) compact() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := &walState{
+		groups:  make(map[string][]string),
+		pending: make(map[string][]*walRecord),
+	}
+	var locked []*consumerGroup
+	defer func() {
+		for _, group := range locked {
+			group.mu.Unlock()
+		}
+	}()
+	for key, groups := range s.groups {
+		for name, group := range groups {
+			if !group.durable {
+				continue
+			}
+			group.mu.Lock()
+			locked = append(locked, group)
+			state.addGroup(key, name)
+			var list []*pendingMessage
+			for _, inflight := range group.inflight {
+				list = append(list, inflight.pm)
+			}
+			list = append(list, group.pending...)
+			sort.Slice(list, func(i, j int) bool { return list[i].seq < list[j].seq })
+			for _, pm := range list {
+				state.pending[key+"/"+name] = append(state.pending[key+"/"+name], &walRecord{
+					Op:       walOpPublish,
+					Seq:      pm.seq,
+					Key:      key,
+					Id:       pm.msg.MessageId,
+					Content:  pm.msg.MessageContent,
+					Metadata: pm.msg.Metadata,
+				})
+			}
+		}
+	}
+	s.wal.mu.Lock()
+	defer s.wal.mu.Unlock()
+	if err := s.wal.rewrite(state.records()); err != nil {
+		log.Printf("压缩持久化文件失败: %v", err)
+	}
+}
+
+func (s *pubSubServer) close() {
+	close(s.done)
+	if s.wal != nil {
+		_ = s.wal.close()
+	}
+}
+
+func startService(address string, cfg ServerConfig) (*grpc.Server, *pubSubServer, error) {
 	lis, err := net.Listen("tcp", address)
 	if err != nil {
-		log.Fatalf("监听端口失败: %v", err)
-		return nil, err
+		return nil, nil, fmt.Errorf("监听端口失败: %w", err)
 	}
 
+	ps, err := newPubSubServer(cfg)
+	if err != nil {
+		_ = lis.Close()
+		return nil, nil, err
+	}
 	s := grpc.NewServer()
-	pubsub.RegisterPubSubServiceServer(s, newPubSubServer())
+	pubsub.RegisterPubSubServiceServer(s, ps)
 	log.Println("gRPC Pub/Sub start at: " + address)
 	goroutines.GoAsync(func(params ...interface{}) {
-		err = s.Serve(lis)
+		if err := s.Serve(lis); err != nil {
+			log.Printf("服务启动失败: %v", err)
+		}
 	})
-	if err != nil {
-		log.Fatalf("服务启动失败: %v", err)
-		return s, err
-	}
-	return s, nil
+	return s, ps, nil
 }
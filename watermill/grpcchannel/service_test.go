@@ -0,0 +1,169 @@
+package grpcchannel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magic-lib/go-servicekit/watermill/grpcchannel/pubsub"
+	"google.golang.org/grpc"
+)
+
+// fakeStream 测试用的订阅流，block 为true时 Send 一直阻塞，模拟不读取消息的订阅者
+type fakeStream struct {
+	grpc.ServerStream
+	block    bool
+	release  chan struct{}
+	mu       sync.Mutex
+	received []*pubsub.SubscribeResponse
+}
+
+func (f *fakeStream) Send(resp *pubsub.SubscribeResponse) error {
+	if f.block {
+		<-f.release
+		return io.EOF
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.received = append(f.received, resp)
+	return nil
+}
+
+func (f *fakeStream) Recv() (*pubsub.SubscribeRequest, error) {
+	<-f.release
+	return nil, io.EOF
+}
+
+func (f *fakeStream) Context() context.Context {
+	return context.Background()
+}
+
+func (f *fakeStream) take() []*pubsub.SubscribeResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := f.received
+	f.received = nil
+	return list
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	s, err := newPubSubServer(ServerConfig{MaxInFlight: 2, AckTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(s.done)
+	release := make(chan struct{})
+	defer close(release)
+
+	req := &pubsub.SubscribeRequest{Namespace: "ns", Topic: "orders", ConsumerGroup: "group"}
+	slow := &fakeStream{block: true, release: release}
+	if _, err = s.addSubscriber(&subscribeStream{stream: slow}, req); err != nil {
+		t.Fatal(err)
+	}
+	fast := &fakeStream{release: release}
+	if _, err = s.addSubscriber(&subscribeStream{stream: fast}, req); err != nil {
+		t.Fatal(err)
+	}
+
+	const total = 20
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < total; i++ {
+			_, _ = s.Publish(context.Background(), &pubsub.Message{
+				Namespace: "ns", Topic: "orders", MessageId: fmt.Sprint(i), MessageContent: "hello",
+			})
+		}
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked by the slow subscriber")
+	}
+
+	// 慢订阅者的消息确认超时后转给正常的订阅者，发布、确认和超时检查都不能被阻塞
+	got := make(map[string]bool)
+	deadline := time.After(5 * time.Second)
+	for len(got) < total {
+		select {
+		case <-deadline:
+			t.Fatalf("received %d messages, want %d", len(got), total)
+		case <-time.After(10 * time.Millisecond):
+		}
+		for _, resp := range fast.take() {
+			got[resp.Message.MessageId] = true
+			s.ack(&pubsub.Ack{DeliveryId: resp.DeliveryId})
+		}
+	}
+}
+
+func TestAnonymousReconnect(t *testing.T) {
+	s, err := newPubSubServer(ServerConfig{AckTimeout: 100 * time.Millisecond, AnonymousRetention: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(s.done)
+	release := make(chan struct{})
+	defer close(release)
+	groupCount := func() int {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.groups["ns/orders"])
+	}
+
+	// 旧版本客户端没有消费组，断开后立即删除
+	sub, err := s.addSubscriber(&subscribeStream{stream: &fakeStream{release: release}},
+		&pubsub.SubscribeRequest{Namespace: "ns", Topic: "orders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.removeSubscriber(sub)
+	if n := groupCount(); n != 0 {
+		t.Fatalf("groups after the legacy subscriber left = %d, want 0", n)
+	}
+
+	// 客户端生成的匿名消费组断开期间保留消息，重连后收到
+	req := &pubsub.SubscribeRequest{Namespace: "ns", Topic: "orders", ConsumerGroup: anonymousPrefix + "abc"}
+	if sub, err = s.addSubscriber(&subscribeStream{stream: &fakeStream{release: release}}, req); err != nil {
+		t.Fatal(err)
+	}
+	s.removeSubscriber(sub)
+	if _, err = s.Publish(context.Background(), &pubsub.Message{Namespace: "ns", Topic: "orders", MessageId: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	stream := &fakeStream{release: release}
+	if sub, err = s.addSubscriber(&subscribeStream{stream: stream}, req); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(time.Second)
+	var got []*pubsub.SubscribeResponse
+	for len(got) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("message published while disconnected was not delivered after reconnecting")
+		case <-time.After(10 * time.Millisecond):
+		}
+		got = stream.take()
+	}
+	if got[0].Message.MessageId != "1" {
+		t.Errorf("message id = %s, want 1", got[0].Message.MessageId)
+	}
+	s.ack(&pubsub.Ack{DeliveryId: got[0].DeliveryId})
+
+	// 超过 AnonymousRetention 没有重连的匿名消费组被删除
+	s.removeSubscriber(sub)
+	if n := groupCount(); n != 1 {
+		t.Fatalf("groups right after the anonymous subscriber left = %d, want 1", n)
+	}
+	deadline = time.After(2 * time.Second)
+	for groupCount() != 0 {
+		select {
+		case <-deadline:
+			t.Fatal("idle anonymous group was not removed")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package grpcchannel
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/magic-lib/go-servicekit/watermill/grpcchannel/pubsub"
+)
+
+const (
+	walOpGroup   = "group"
+	walOpPublish = "pub"
+	walOpAck     = "ack"
+
+	walCompactAcks = 10000 // 确认的消息数超过该值后压缩文件
+)
+
+// walRecord 持久化文件中的一行记录
+type walRecord struct {
+	Op       string            `json:"op"`
+	Seq      uint64            `json:"seq,omitempty"`
+	Key      string            `json:"key"`
+	Group    string            `json:"group,omitempty"`
+	Groups   []string          `json:"groups,omitempty"`
+	Id       string            `json:"id,omitempty"`
+	Content  string            `json:"content,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (r *walRecord) message() *pubsub.Message {
+	return &pubsub.Message{
+		MessageId:      r.Id,
+		MessageContent: r.Content,
+		Metadata:       r.Metadata,
+	}
+}
+
+// walState 回放持久化文件后的结果
+type walState struct {
+	groups  map[string][]string     // key -> 消费组
+	pending map[string][]*walRecord // key/group -> 未确认的消息，按 seq 排序
+	lastSeq uint64
+}
+
+// wal 追加写的持久化文件，记录命名消费组、发布的消息和确认的消息
+type wal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	w    *bufio.Writer
+	acks int
+}
+
+func openWAL(path string) (*wal, *walState, error) {
+	state, err := replayWAL(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, err
+	}
+	w := &wal{path: path}
+	if err = w.rewrite(state.records()); err != nil {
+		return nil, nil, err
+	}
+	return w, state, nil
+}
+
+func replayWAL(path string) (*walState, error) {
+	state := &walState{
+		groups:  make(map[string][]string),
+		pending: make(map[string][]*walRecord),
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	type published struct {
+		rec    *walRecord
+		groups map[string]bool
+	}
+	messages := make(map[uint64]*published)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var badLine error
+	for line := 1; scanner.Scan(); line++ {
+		if badLine != nil {
+			return nil, badLine
+		}
+		rec := new(walRecord)
+		if err = json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			// 最后一行可能因为进程退出只写了一半，可以忽略，其他行损坏则报错
+			badLine = fmt.Errorf("wal %s line %d: %w", path, line, err)
+			continue
+		}
+		switch rec.Op {
+		case walOpGroup:
+			state.addGroup(rec.Key, rec.Group)
+		case walOpPublish:
+			p := &published{rec: rec, groups: make(map[string]bool)}
+			for _, group := range rec.Groups {
+				p.groups[group] = true
+			}
+			messages[rec.Seq] = p
+			if rec.Seq > state.lastSeq {
+				state.lastSeq = rec.Seq
+			}
+		case walOpAck:
+			if p, ok := messages[rec.Seq]; ok {
+				delete(p.groups, rec.Group)
+				if len(p.groups) == 0 {
+					delete(messages, rec.Seq)
+				}
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	seqList := make([]uint64, 0, len(messages))
+	for seq := range messages {
+		seqList = append(seqList, seq)
+	}
+	sort.Slice(seqList, func(i, j int) bool { return seqList[i] < seqList[j] })
+	for _, seq := range seqList {
+		p := messages[seq]
+		for group := range p.groups {
+			groupKey := p.rec.Key + "/" + group
+			state.pending[groupKey] = append(state.pending[groupKey], p.rec)
+		}
+	}
+	return state, nil
+}
+
+func (s *walState) addGroup(key, group string) {
+	for _, one := range s.groups[key] {
+		if one == group {
+			return
+		}
+	}
+	s.groups[key] = append(s.groups[key], group)
+}
+
+// records 把回放结果转换为压缩后的记录
+func (s *walState) records() []*walRecord {
+	var records []*walRecord
+	seen := make(map[uint64]*walRecord)
+	var pubs []*walRecord
+	for key, groups := range s.groups {
+		for _, group := range groups {
+			records = append(records, &walRecord{Op: walOpGroup, Key: key, Group: group})
+			for _, rec := range s.pending[key+"/"+group] {
+				if old, ok := seen[rec.Seq]; ok {
+					old.Groups = append(old.Groups, group)
+					continue
+				}
+				one := *rec
+				one.Groups = []string{group}
+				seen[rec.Seq] = &one
+				pubs = append(pubs, &one)
+			}
+		}
+	}
+	sort.Slice(pubs, func(i, j int) bool { return pubs[i].Seq < pubs[j].Seq })
+	return append(records, pubs...)
+}
+
+func (w *wal) append(rec *walRecord) (needCompact bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.w == nil {
+		return false, fmt.Errorf("wal %s is closed", w.path)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return false, err
+	}
+	if _, err = w.w.Write(append(data, '\n')); err != nil {
+		return false, err
+	}
+	if err = w.w.Flush(); err != nil {
+		return false, err
+	}
+	if rec.Op == walOpAck {
+		w.acks++
+	}
+	return w.acks >= walCompactAcks, nil
+}
+
+// rewrite 用 records 替换当前文件，先写临时文件再重命名，调用方需要持有锁
+func (w *wal) rewrite(records []*walRecord) error {
+	tmpPath := w.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(bw)
+	for _, rec := range records {
+		if err = enc.Encode(rec); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err = bw.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if w.file != nil {
+		_ = w.file.Close()
+	}
+	if err = os.Rename(tmpPath, w.path); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.w = bufio.NewWriter(file)
+	w.acks = 0
+	return nil
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	_ = w.w.Flush()
+	err := w.file.Close()
+	w.file = nil
+	w.w = nil
+	return err
+}
@@ -0,0 +1,134 @@
+package watermill
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/magic-lib/go-servicekit/watermill"
+
+	// PoisonedTopicKey 进入死信队列的消息，记录原始topic
+	PoisonedTopicKey = "poisoned_topic"
+	// PoisonedReasonKey 进入死信队列的消息，记录失败原因
+	PoisonedReasonKey = "poisoned_reason"
+)
+
+// Retry 处理失败后重试 retryTimes 次，重试间隔按 backoff 计算
+func Retry(retryTimes int, backoff Backoff) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *message.Message) error {
+			err := next(ctx, msg)
+			if err == nil || retryTimes <= 0 {
+				return err
+			}
+			retError := multierror.Append(nil, err)
+			for i := 0; i < retryTimes; i++ {
+				if interval := backoff.Next(i + 1); interval > 0 {
+					select {
+					case <-ctx.Done():
+						return multierror.Append(retError, ctx.Err())
+					case <-time.After(interval):
+					}
+				}
+				if err = next(ctx, msg); err == nil {
+					return nil
+				}
+				retError = multierror.Append(retError, err)
+			}
+			return retError
+		}
+	}
+}
+
+// Timeout 限制单条消息的处理时间，超时后返回 context.DeadlineExceeded，处理方法需要自行响应ctx的取消
+func Timeout(timeout time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		if timeout <= 0 {
+			return next
+		}
+		return func(ctx context.Context, msg *message.Message) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			done := make(chan error, 1)
+			go func() {
+				done <- next(ctx, msg)
+			}()
+			select {
+			case err := <-done:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// PoisonQueue 处理失败的消息发送到 poisonTopic，发送成功则认为消息已处理
+func PoisonQueue(pub Publisher, poisonTopic string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *message.Message) error {
+			err := next(ctx, msg)
+			if err == nil || pub == nil || poisonTopic == "" {
+				return err
+			}
+			poisoned := msg.Copy()
+			poisoned.Metadata.Set(PoisonedTopicKey, TopicFromContext(ctx))
+			poisoned.Metadata.Set(PoisonedReasonKey, err.Error())
+			if _, pubErr := pub.Publish(ctx, poisonTopic, poisoned); pubErr != nil {
+				return multierror.Append(err, fmt.Errorf("publish to poison queue %s: %w", poisonTopic, pubErr))
+			}
+			return nil
+		}
+	}
+}
+
+// Tracing 从消息元数据中提取链路信息，为每条消息创建 consumer span，tp 为空时使用全局 TracerProvider
+func Tracing(tp trace.TracerProvider) Middleware {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	tracer := tp.Tracer(tracerName)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *message.Message) error {
+			topic := TopicFromContext(ctx)
+			if msg.Metadata != nil {
+				ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Metadata))
+			}
+			ctx, span := tracer.Start(ctx, topic+" process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("messaging.system", "watermill"),
+					attribute.String("messaging.destination.name", topic),
+					attribute.String("messaging.message.id", msg.UUID),
+				))
+			defer span.End()
+			err := next(ctx, msg)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
+
+// InjectTrace 发布前把ctx中的链路信息写入消息元数据
+func InjectTrace(ctx context.Context, msg *message.Message) {
+	if ctx == nil || msg == nil {
+		return
+	}
+	if msg.Metadata == nil {
+		msg.Metadata = make(message.Metadata)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(msg.Metadata))
+}
@@ -0,0 +1,187 @@
+package mysqlchannel
+
+import (
+	"context"
+	stdSQL "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// legacyPayload 旧版本的 payload 格式，即 httputil.CommResponse：{"code":0,"message":"topic","data":"base64"}
+type legacyPayload struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+	Data    []byte `json:"data"`
+}
+
+// legacyPayloadKeys 旧版本的 payload 只有这几个字段，其它字段为空时不输出
+var legacyPayloadKeys = []string{"code", "message", "data"}
+
+// WithAutoMigrate 首次发布、订阅 topic 时自动转换旧版本的消息表，默认关闭。
+// 滚动升级时旧版本的发布方还会写入 JSON 格式的 payload，需要所有发布方都升级后再开启或者调用 Migrate
+func (g *Channel) WithAutoMigrate(autoMigrate bool) *Channel {
+	g.autoMigrate = autoMigrate
+	return g
+}
+
+// Migrate 把旧版本的消息表（payload 为 JSON 格式的 base64 数据）转换为二进制 payload，
+// 不存在或者已经转换过的表会跳过。转换前后写入的两种格式订阅方都可以读取
+func (g *Channel) Migrate(ctx context.Context, topics ...string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for _, topic := range topics {
+		if err := g.migrateTopic(ctx, topic); err != nil {
+			return fmt.Errorf("migrate topic %s: %w", topic, err)
+		}
+		g.legacyTopics.Remove(topic)
+	}
+	return nil
+}
+
+// ensureTopic 每个 topic 只检查一次，没有转换的旧版本消息表继续按旧格式写入
+func (g *Channel) ensureTopic(ctx context.Context, topic string) error {
+	if _, ok := g.topics.Get(topic); ok {
+		return nil
+	}
+	if g.autoMigrate {
+		if err := g.Migrate(ctx, topic); err != nil {
+			return err
+		}
+	} else if g.dialect == DialectMysql {
+		dataType, err := g.columnType(ctx, g.sqlDb, g.tableName(topic), "payload")
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(dataType, "json") {
+			g.legacyTopics.Set(topic, true)
+		}
+	}
+	g.topics.Set(topic, true)
+	return nil
+}
+
+// encodePayload 旧版本的消息表 payload 为 JSON 类型，按旧格式写入
+func (g *Channel) encodePayload(topic string, msg *message.Message) error {
+	if _, ok := g.legacyTopics.Get(topic); !ok {
+		return nil
+	}
+	payload, err := json.Marshal(legacyPayload{Message: topic, Data: msg.Payload})
+	if err != nil {
+		return err
+	}
+	msg.Payload = payload
+	return nil
+}
+
+// decodePayload 兼容旧格式的 payload，比如转换前写入或者升级期间旧版本发布方写入的消息。
+// 只还原字段、code 和 message 都与旧版本一致的 payload，避免误改业务自己的 JSON
+func decodePayload(topic string, msg *message.Message) {
+	payload := msg.Payload
+	if len(payload) == 0 || payload[0] != '{' {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil || len(fields) != len(legacyPayloadKeys) {
+		return
+	}
+	for _, key := range legacyPayloadKeys {
+		if _, ok := fields[key]; !ok {
+			return
+		}
+	}
+	var legacy legacyPayload
+	if err := json.Unmarshal(payload, &legacy); err != nil {
+		return
+	}
+	if legacy.Code != 0 || legacy.Message != topic {
+		return
+	}
+	msg.Payload = legacy.Data
+}
+
+func (g *Channel) migrateTopic(ctx context.Context, topic string) error {
+	// postgres 没有旧版本的表结构
+	if g.dialect == DialectPostgres {
+		return nil
+	}
+	table := g.tableName(topic)
+	dataType, err := g.columnType(ctx, g.sqlDb, table, "payload")
+	if err != nil || !strings.EqualFold(dataType, "json") {
+		return err
+	}
+
+	conn, err := g.sqlDb.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	// 多个进程同时启动时只需要一个进程执行转换
+	lockName := "migrate_" + table
+	var locked stdSQL.NullInt64
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", lockName).Scan(&locked); err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("get lock %s timeout", lockName)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	}()
+
+	if dataType, err = g.columnType(ctx, conn, table, "payload"); err != nil || !strings.EqualFold(dataType, "json") {
+		return err
+	}
+	rawType, err := g.columnType(ctx, conn, table, "payload_raw")
+	if err != nil {
+		return err
+	}
+
+	queries := g.migrateQueries(topic, rawType != "")
+	for _, query := range queries {
+		if _, err = conn.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	log.Printf("migrate table %s to binary payload", table)
+	return nil
+}
+
+// migrateQueries 转换旧版本消息表的语句，先写入新字段再替换，payload_raw 已存在说明上次转换中断
+func (g *Channel) migrateQueries(topic string, rawExists bool) []string {
+	quoted := g.getTableFromNamespace(topic)
+	var queries []string
+	if !rawExists {
+		queries = append(queries, "ALTER TABLE "+quoted+" ADD COLUMN `payload_raw` "+mysqlPayloadType+" DEFAULT NULL")
+	}
+	return append(queries,
+		// 旧版本的 payload 为 {"code":0,"message":"topic","data":"base64"}
+		"UPDATE "+quoted+" SET `payload_raw` = FROM_BASE64(JSON_UNQUOTE(JSON_EXTRACT(`payload`, '$.data'))) WHERE `payload_raw` IS NULL",
+		"ALTER TABLE "+quoted+" DROP COLUMN `payload`",
+		"ALTER TABLE "+quoted+" CHANGE COLUMN `payload_raw` `payload` "+mysqlPayloadType+" DEFAULT NULL AFTER `created_at`, ADD INDEX "+
+			g.getIndexFromNamespace(topic)+" (`created_at`)",
+	)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *stdSQL.Row
+}
+
+// columnType 查询字段类型，表或者字段不存在时返回空
+func (g *Channel) columnType(ctx context.Context, db queryRower, table, column string) (string, error) {
+	var dataType string
+	err := db.QueryRowContext(ctx,
+		"SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+		table, column).Scan(&dataType)
+	if errors.Is(err, stdSQL.ErrNoRows) {
+		return "", nil
+	}
+	return dataType, err
+}
@@ -0,0 +1,139 @@
+package mysqlchannel
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/magic-lib/go-plat-utils/conv"
+	"github.com/magic-lib/go-plat-utils/utils/httputil"
+)
+
+func TestMigrateQueries(t *testing.T) {
+	g := newChannel(DialectMysql).WithNamespace("ns")
+	queries := g.migrateQueries("orders", false)
+	want := []string{
+		"ALTER TABLE `ns_orders` ADD COLUMN `payload_raw` LONGBLOB DEFAULT NULL",
+		"UPDATE `ns_orders` SET `payload_raw` = FROM_BASE64(JSON_UNQUOTE(JSON_EXTRACT(`payload`, '$.data'))) WHERE `payload_raw` IS NULL",
+		"ALTER TABLE `ns_orders` DROP COLUMN `payload`",
+		"ALTER TABLE `ns_orders` CHANGE COLUMN `payload_raw` `payload` LONGBLOB DEFAULT NULL AFTER `created_at`, ADD INDEX `ns_orders_created_at` (`created_at`)",
+	}
+	if strings.Join(queries, "\n") != strings.Join(want, "\n") {
+		t.Errorf("migrate queries = %q, want %q", queries, want)
+	}
+
+	// 上次转换中断时 payload_raw 已存在，不再添加
+	resumed := g.migrateQueries("orders", true)
+	if len(resumed) != len(want)-1 || strings.Contains(resumed[0], "ADD COLUMN") {
+		t.Errorf("resumed migrate queries = %q", resumed)
+	}
+}
+
+func TestLegacyPayload(t *testing.T) {
+	g := newChannel(DialectMysql)
+	g.legacyTopics.Set("legacy", true)
+
+	// 未转换的表按旧格式写入，订阅方读取时还原
+	msg := message.NewMessage("1", []byte{0x00, 0xff, 'a'})
+	if err := g.encodePayload("legacy", msg); err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Payload) != `{"code":0,"message":"legacy","data":"AP9h"}` {
+		t.Errorf("legacy payload = %s", msg.Payload)
+	}
+	decodePayload("legacy", msg)
+	if string(msg.Payload) != "\x00\xffa" {
+		t.Errorf("decoded payload = %q", msg.Payload)
+	}
+
+	// 已转换的表写入原始内容
+	msg = message.NewMessage("2", []byte("raw"))
+	if err := g.encodePayload("orders", msg); err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Payload) != "raw" {
+		t.Errorf("payload = %s, want raw", msg.Payload)
+	}
+
+	cases := []struct {
+		payload string
+		want    string
+	}{
+		// 旧版本发布方写入的 httputil.CommResponse
+		{`{"code":0,"message":"orders","data":"aGVsbG8="}`, "hello"},
+		// 旧版本 JSON 类型的 payload 字段，mysql 返回时会调整key的顺序和空格
+		{`{"code": 0, "data": "aGVsbG8=", "message": "orders"}`, "hello"},
+		{`{"code":0,"message":"orders","data":"aGVsbG8=","extra":1}`, `{"code":0,"message":"orders","data":"aGVsbG8=","extra":1}`},
+		{`{"code":0,"message":"orders","data":"not base64!"}`, `{"code":0,"message":"orders","data":"not base64!"}`},
+		// 业务自己的 JSON 不会被还原
+		{`{"message":"orders","data":"aGVsbG8="}`, `{"message":"orders","data":"aGVsbG8="}`},
+		{`{"code":1,"message":"orders","data":"aGVsbG8="}`, `{"code":1,"message":"orders","data":"aGVsbG8="}`},
+		{`{"code":0,"message":"ok","data":"aGVsbG8="}`, `{"code":0,"message":"ok","data":"aGVsbG8="}`},
+		{`{"name":"order"}`, `{"name":"order"}`},
+		{"plain text", "plain text"},
+	}
+	for _, c := range cases {
+		msg := message.NewMessage("3", []byte(c.payload))
+		decodePayload("orders", msg)
+		if string(msg.Payload) != c.want {
+			t.Errorf("decodePayload(%s) = %s, want %s", c.payload, msg.Payload, c.want)
+		}
+	}
+}
+
+// TestDecodeLegacyRow 用旧版本 Publish 的写法生成 payload，确认升级前写入的消息可以还原
+func TestDecodeLegacyRow(t *testing.T) {
+	data := []byte{0x00, 0xff, 'h', 'i'}
+	legacy := []byte(conv.String(httputil.CommResponse{
+		Message: "orders",
+		Data:    base64.StdEncoding.EncodeToString(data),
+	}))
+	if string(legacy) != `{"code":0,"message":"orders","data":"AP9oaQ=="}` {
+		t.Fatalf("legacy row = %s", legacy)
+	}
+
+	msg := message.NewMessage("1", legacy)
+	decodePayload("orders", msg)
+	if string(msg.Payload) != string(data) {
+		t.Errorf("decoded payload = %q, want %q", msg.Payload, data)
+	}
+}
+
+func TestRetentionQueries(t *testing.T) {
+	rc := RetentionConfig{ConsumedAge: time.Hour, MaxAge: 24 * time.Hour, BatchSize: 500}
+	cases := []struct {
+		dialect  string
+		consumed string
+		expired  string
+	}{
+		{
+			DialectMysql,
+			"DELETE FROM `ns_orders` WHERE `offset` <= ? AND `created_at` < CURRENT_TIMESTAMP - INTERVAL ? SECOND LIMIT ?",
+			"DELETE FROM `ns_orders` WHERE `created_at` < CURRENT_TIMESTAMP - INTERVAL ? SECOND LIMIT ?",
+		},
+		{
+			DialectPostgres,
+			`DELETE FROM "ns_orders" WHERE "offset" IN (SELECT "offset" FROM "ns_orders" WHERE transaction_id::text::bigint < $1 AND created_at < CURRENT_TIMESTAMP - make_interval(secs => $2) LIMIT $3)`,
+			`DELETE FROM "ns_orders" WHERE "offset" IN (SELECT "offset" FROM "ns_orders" WHERE created_at < CURRENT_TIMESTAMP - make_interval(secs => $1) LIMIT $2)`,
+		},
+	}
+	for _, c := range cases {
+		g := newChannel(c.dialect).WithNamespace("ns")
+		query, args := g.deleteConsumedQuery("orders", "42", rc)
+		if query != c.consumed {
+			t.Errorf("%s consumed query = %s, want %s", c.dialect, query, c.consumed)
+		}
+		if len(args) != 3 || args[0] != "42" || args[1] != int64(3600) || args[2] != 500 {
+			t.Errorf("%s consumed args = %v", c.dialect, args)
+		}
+		query, args = g.deleteExpiredQuery("orders", rc)
+		if query != c.expired {
+			t.Errorf("%s expired query = %s, want %s", c.dialect, query, c.expired)
+		}
+		if len(args) != 2 || args[0] != int64(86400) || args[1] != 500 {
+			t.Errorf("%s expired args = %v", c.dialect, args)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package mysqlchannel_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/ThreeDotsLabs/watermill/message"
 	driver "github.com/go-sql-driver/mysql"
@@ -22,16 +23,16 @@ func TestPubSub(t *testing.T) {
 
 	temp.WithNamespace("onlyone")
 
-	err = temp.Subscribe("aaaa", func(messageId, messageData string) error {
-		fmt.Println("aaaa, messageId:", messageId, "messageData:", messageData)
+	err = temp.Subscribe("aaaa", func(ctx context.Context, msg *message.Message) error {
+		fmt.Println("aaaa, messageId:", msg.UUID, "messageData:", string(msg.Payload))
 		return nil
 	})
 	if err != nil {
 		return
 	}
 
-	err = temp.Subscribe("aaaa", func(messageId, messageData string) error {
-		fmt.Println("aaaa, messageId3:", messageId, "messageData2:", messageData)
+	err = temp.Subscribe("aaaa", func(ctx context.Context, msg *message.Message) error {
+		fmt.Println("aaaa, messageId3:", msg.UUID, "messageData2:", string(msg.Payload))
 		return nil
 	})
 	if err != nil {
@@ -48,16 +49,16 @@ func TestPubSub(t *testing.T) {
 		return
 	}
 	temp2.WithNamespace("onlyone")
-	err = temp2.Subscribe("aaaa", func(messageId, messageData string) error {
-		fmt.Println("aaaa, messageId2:", messageId, "messageData2:", messageData)
+	err = temp2.Subscribe("aaaa", func(ctx context.Context, msg *message.Message) error {
+		fmt.Println("aaaa, messageId2:", msg.UUID, "messageData2:", string(msg.Payload))
 		return nil
 	})
 	if err != nil {
 		return
 	}
 
-	err = temp.Subscribe("bbbb", func(messageId, messageData string) error {
-		fmt.Println("bbbb, messageId:", messageId, "messageData:", messageData)
+	err = temp.Subscribe("bbbb", func(ctx context.Context, msg *message.Message) error {
+		fmt.Println("bbbb, messageId:", msg.UUID, "messageData:", string(msg.Payload))
 		return nil
 	})
 	if err != nil {
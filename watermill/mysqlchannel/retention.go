@@ -0,0 +1,184 @@
+package mysqlchannel
+
+import (
+	"context"
+	stdSQL "database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	defaultRetentionInterval  = 10 * time.Minute
+	defaultRetentionBatchSize = 1000
+)
+
+// RetentionConfig 消息表的清理策略
+// 已被所有消费组确认的消息超过 ConsumedAge 后删除；不论是否消费，超过 MaxAge 的消息都会删除。
+// 清理后新加入的消费组无法再读取被删除的消息
+type RetentionConfig struct {
+	ConsumedAge time.Duration `json:",optional"`     //已消费消息的保留时间，0表示不清理
+	MaxAge      time.Duration `json:",optional"`     //所有消息的最长保留时间，0表示不限制
+	Interval    time.Duration `json:",default=10m"`  //清理间隔
+	BatchSize   int           `json:",default=1000"` //每次删除的行数，避免长时间锁表
+}
+
+func (rc RetentionConfig) enabled() bool {
+	return rc.ConsumedAge > 0 || rc.MaxAge > 0
+}
+
+// WithRetention 定时清理消息表，只清理当前通道发布或订阅过的 topic
+func (g *Channel) WithRetention(rc RetentionConfig) *Channel {
+	if rc.Interval <= 0 {
+		rc.Interval = defaultRetentionInterval
+	}
+	if rc.BatchSize <= 0 {
+		rc.BatchSize = defaultRetentionBatchSize
+	}
+	g.retention = rc
+	if !rc.enabled() || g.retentionStarted {
+		return g
+	}
+	g.retentionStarted = true
+	go func() {
+		ticker := time.NewTicker(rc.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-g.done:
+				return
+			case <-ticker.C:
+				if _, err := g.Cleanup(context.Background()); err != nil {
+					log.Println("cleanup messages error: ", err.Error())
+				}
+			}
+		}
+	}()
+	return g
+}
+
+// Cleanup 按 RetentionConfig 清理一次消息表，返回删除的行数，topics 为空时清理当前通道使用过的 topic
+func (g *Channel) Cleanup(ctx context.Context, topics ...string) (int64, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(topics) == 0 {
+		topics = g.topics.Keys()
+	}
+	var total int64
+	for _, topic := range topics {
+		deleted, err := g.cleanupTopic(ctx, topic)
+		total += deleted
+		if err != nil {
+			return total, fmt.Errorf("cleanup topic %s: %w", topic, err)
+		}
+	}
+	return total, nil
+}
+
+func (g *Channel) cleanupTopic(ctx context.Context, topic string) (int64, error) {
+	rc := g.retention
+	var total int64
+	if rc.ConsumedAge > 0 {
+		acked, ok, err := g.ackedPosition(ctx, topic)
+		if err != nil {
+			return total, err
+		}
+		if ok {
+			query, args := g.deleteConsumedQuery(topic, acked, rc)
+			deleted, err := g.deleteBatches(ctx, query, args, rc.BatchSize)
+			total += deleted
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	if rc.MaxAge > 0 {
+		query, args := g.deleteExpiredQuery(topic, rc)
+		deleted, err := g.deleteBatches(ctx, query, args, rc.BatchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// ackedPosition 所有消费组都已确认的位置，mysql 为 offset，postgres 为事务id，还没有消费组时返回 false
+func (g *Channel) ackedPosition(ctx context.Context, topic string) (string, bool, error) {
+	var query string
+	if g.dialect == DialectPostgres {
+		// 同一事务内的消息可能没有全部确认，只清理更早的事务
+		query = "SELECT MIN(last_processed_transaction_id::text::bigint) FROM " + g.getOffsetsTableFromNamespace(topic)
+	} else {
+		query = "SELECT MIN(COALESCE(offset_acked, 0)) FROM " + g.getOffsetsTableFromNamespace(topic)
+	}
+	var acked stdSQL.NullString
+	if err := g.sqlDb.QueryRowContext(ctx, query).Scan(&acked); err != nil {
+		if exists, existsErr := g.tableExists(ctx, g.offsetsTableName(topic)); existsErr == nil && !exists {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return acked.String, acked.Valid, nil
+}
+
+func (g *Channel) tableExists(ctx context.Context, table string) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+	if g.dialect == DialectPostgres {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"
+	}
+	var count int
+	err := g.sqlDb.QueryRowContext(ctx, query, table).Scan(&count)
+	return count > 0, err
+}
+
+func (g *Channel) deleteConsumedQuery(topic, acked string, rc RetentionConfig) (string, []any) {
+	table := g.getTableFromNamespace(topic)
+	seconds := int64(rc.ConsumedAge / time.Second)
+	if g.dialect == DialectPostgres {
+		return `DELETE FROM ` + table + ` WHERE "offset" IN (SELECT "offset" FROM ` + table +
+				` WHERE transaction_id::text::bigint < $1 AND created_at < CURRENT_TIMESTAMP - make_interval(secs => $2) LIMIT $3)`,
+			[]any{acked, seconds, rc.BatchSize}
+	}
+	return "DELETE FROM " + table + " WHERE `offset` <= ? AND `created_at` < CURRENT_TIMESTAMP - INTERVAL ? SECOND LIMIT ?",
+		[]any{acked, seconds, rc.BatchSize}
+}
+
+func (g *Channel) deleteExpiredQuery(topic string, rc RetentionConfig) (string, []any) {
+	table := g.getTableFromNamespace(topic)
+	seconds := int64(rc.MaxAge / time.Second)
+	if g.dialect == DialectPostgres {
+		return `DELETE FROM ` + table + ` WHERE "offset" IN (SELECT "offset" FROM ` + table +
+				` WHERE created_at < CURRENT_TIMESTAMP - make_interval(secs => $1) LIMIT $2)`,
+			[]any{seconds, rc.BatchSize}
+	}
+	return "DELETE FROM " + table + " WHERE `created_at` < CURRENT_TIMESTAMP - INTERVAL ? SECOND LIMIT ?",
+		[]any{seconds, rc.BatchSize}
+}
+
+// deleteBatches 分批删除，直到删除的行数小于 batchSize
+func (g *Channel) deleteBatches(ctx context.Context, query string, args []any, batchSize int) (int64, error) {
+	var total int64
+	for {
+		result, err := g.sqlDb.ExecContext(ctx, query, args...)
+		if err != nil {
+			return total, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += affected
+		if affected < int64(batchSize) {
+			return total, nil
+		}
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-g.done:
+			return total, nil
+		default:
+		}
+	}
+}
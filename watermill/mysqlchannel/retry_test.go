@@ -0,0 +1,122 @@
+package mysqlchannel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/magic-lib/go-plat-utils/conv"
+	comm "github.com/magic-lib/go-servicekit/watermill"
+)
+
+func TestRetryParamMessage(t *testing.T) {
+	// 与重试表一致，参数序列化后再读取
+	param := conv.String([]any{"msg-1", "hello", map[string]string{"traceparent": "00-abc-01", "tenant": "t1"}})
+	var paramList []any
+	if err := conv.Unmarshal(param, &paramList); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := retryParamMessage(paramList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.UUID != "msg-1" || string(msg.Payload) != "hello" {
+		t.Errorf("message = %s/%s, want msg-1/hello", msg.UUID, msg.Payload)
+	}
+	if msg.Metadata.Get("traceparent") != "00-abc-01" || msg.Metadata.Get("tenant") != "t1" {
+		t.Errorf("metadata = %v", msg.Metadata)
+	}
+
+	// 旧版本写入的记录没有元数据
+	if msg, err = retryParamMessage([]any{"msg-2", "world"}); err != nil || msg.UUID != "msg-2" {
+		t.Errorf("old record = %v, %v", msg, err)
+	}
+	if _, err = retryParamMessage([]any{"msg-3"}); err == nil {
+		t.Error("invalid param should return error")
+	}
+}
+
+func handleOrder(context.Context, *message.Message) error { return nil }
+
+func handleRefund(context.Context, *message.Message) error { return nil }
+
+func TestRetryTypePerHandler(t *testing.T) {
+	// 注册顺序不同，同一个处理方法的重试类型不变
+	for _, handlers := range [][]comm.HandlerFunc{
+		{handleOrder, handleRefund, handleOrder},
+		{handleRefund, handleOrder, handleOrder},
+	} {
+		g := newChannel(DialectMysql)
+		var got []string
+		for _, handler := range handlers {
+			got = append(got, g.retryType("orders", handler))
+		}
+		sort.Strings(got)
+		want := []string{
+			"orders#github.com/magic-lib/go-servicekit/watermill/mysqlchannel.handleOrder",
+			"orders#github.com/magic-lib/go-servicekit/watermill/mysqlchannel.handleOrder#2",
+			"orders#github.com/magic-lib/go-servicekit/watermill/mysqlchannel.handleRefund",
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("retry types = %v, want %v", got, want)
+		}
+	}
+
+	g := newChannel(DialectMysql)
+	g.WithNamespace(t.Name()).WithConsumerGroup("group")
+	g.registerRetry("orders", "orders", 1, "orders#handleRefund", handleRefund)
+	g.registerRetry("orders", "group/x1", 0, "orders@group", handleOrder)
+	if got, _ := g.retryTypes.Get("orders#1"); got != "orders#handleRefund" {
+		t.Errorf("retry type of orders#1 = %q", got)
+	}
+	if got, _ := g.retryTypes.Get("group/x1#0"); got != "orders@group" {
+		t.Errorf("retry type of group/x1#0 = %q", got)
+	}
+
+	// 没有处理方法信息时不写入重试表
+	retErr := fmt.Errorf("handler failed")
+	if err := g.mysqlRetryMessage(context.Background(), message.NewMessage("1", []byte("a")), nil, retErr); err != retErr {
+		t.Errorf("mysqlRetryMessage() = %v, want %v", err, retErr)
+	}
+}
+
+func TestRetryExecutorMiddleware(t *testing.T) {
+	g := newChannel(DialectMysql)
+	var got []string
+	execute := g.retryExecutor("orders", func(ctx context.Context, msg *message.Message) error {
+		got = append(got, "handler "+comm.TopicFromContext(ctx))
+		return nil
+	})
+	// 注册后添加的中间件同样生效
+	g.Use(func(next comm.HandlerFunc) comm.HandlerFunc {
+		return func(ctx context.Context, msg *message.Message) error {
+			got = append(got, "middleware "+msg.UUID)
+			return next(ctx, msg)
+		}
+	})
+	if _, err := execute([]any{"msg-1", "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[middleware msg-1 handler orders]" {
+		t.Errorf("calls = %v", got)
+	}
+}
+
+func TestSubscribeNewTwice(t *testing.T) {
+	g := newChannel(DialectMysql)
+	g.WithConsumerGroup("group")
+	g.subscriptions.Set("orders@group", true)
+	if err := g.SubscribeNew("orders", "", handleOrder); err == nil {
+		t.Error("subscribe the same topic and consumer group twice should return error")
+	}
+	// 订阅失败后可以重新订阅
+	g.topics.Set("refunds", true)
+	if err := g.SubscribeNew("refunds", "", handleRefund); err == nil {
+		t.Fatal("subscribe without db should return error")
+	}
+	if _, ok := g.subscriptions.Get("refunds@group"); ok {
+		t.Error("failed subscription should be removed")
+	}
+}
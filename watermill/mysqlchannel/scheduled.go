@@ -0,0 +1,297 @@
+package mysqlchannel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill-sql/v4/pkg/sql"
+	"github.com/ThreeDotsLabs/watermill/message"
+	comm "github.com/magic-lib/go-servicekit/watermill"
+)
+
+const (
+	schedulePollInterval = time.Second
+	scheduleBatchSize    = 100
+)
+
+// 延迟消息保存在 {namespace}_scheduled_{topic} 表中，deliver_at 为投递时间的毫秒时间戳。
+// 订阅或者延迟发布过该 topic 的进程会定时把到期的消息移动到消息表，同一事务内完成，
+// 至少需要一个这样的进程在运行，否则延迟消息不会投递。
+// MySQL 8.0+、MariaDB 10.6+ 和 postgres 通过 SKIP LOCKED 让多个进程并行移动，
+// 更早的版本退回使用 FOR UPDATE，多个进程依次移动
+
+func (g *Channel) scheduledTableName(topic string) string {
+	return fmt.Sprintf("%s_scheduled_%s", g.getNamespace(), topic)
+}
+
+func (g *Channel) getScheduledTableFromNamespace(topic string) string {
+	return g.quote(g.scheduledTableName(topic))
+}
+
+func (g *Channel) scheduledSchemaQuery(topic string) []string {
+	table := g.getScheduledTableFromNamespace(topic)
+	if g.dialect == DialectPostgres {
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + table + ` (
+				"id" BIGSERIAL PRIMARY KEY,
+				"uuid" VARCHAR(36) NOT NULL,
+				"deliver_at" BIGINT NOT NULL,
+				"payload" ` + postgresPayloadType + ` DEFAULT NULL,
+				"metadata" JSON DEFAULT NULL,
+				"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS ` + g.quote(g.scheduledTableName(topic)+"_uuid") + ` ON ` + table + ` ("uuid")`,
+			`CREATE INDEX IF NOT EXISTS ` + g.quote(g.scheduledTableName(topic)+"_deliver_at") + ` ON ` + table + ` ("deliver_at")`,
+		}
+	}
+	return []string{strings.Join([]string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (",
+		"`id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,",
+		"`uuid` VARCHAR(36) NOT NULL,",
+		"`deliver_at` BIGINT NOT NULL,",
+		"`payload` " + mysqlPayloadType + " DEFAULT NULL,",
+		"`metadata` JSON DEFAULT NULL,",
+		"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,",
+		"UNIQUE INDEX `idx_uuid` (`uuid`),",
+		"INDEX `idx_deliver_at` (`deliver_at`)",
+		");",
+	}, "\n")}
+}
+
+// supportsSkipLocked 根据 SELECT VERSION() 的结果判断是否支持 SKIP LOCKED
+func supportsSkipLocked(version string) bool {
+	var major, minor int
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		return false
+	}
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		return major > 10 || (major == 10 && minor >= 6)
+	}
+	return major >= 8
+}
+
+// lockClause 选择延迟消息时使用的锁，只检测一次数据库版本
+func (g *Channel) lockClause(ctx context.Context) string {
+	g.lockOnce.Do(func() {
+		g.skipLocked = true
+		if g.dialect == DialectPostgres {
+			return
+		}
+		var version string
+		if err := g.sqlDb.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+			log.Printf("query database version error: %v", err)
+		}
+		g.skipLocked = supportsSkipLocked(version)
+	})
+	if g.skipLocked {
+		return "FOR UPDATE SKIP LOCKED"
+	}
+	return "FOR UPDATE"
+}
+
+// placeholder 第 n 个参数的占位符，n 从1开始
+func (g *Channel) placeholder(n int) string {
+	if g.dialect == DialectPostgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+func (g *Channel) ensureScheduledTable(ctx context.Context, topic string) error {
+	if _, ok := g.scheduledTopics.Get(topic); ok {
+		return nil
+	}
+	for _, query := range g.scheduledSchemaQuery(topic) {
+		if _, err := g.sqlDb.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("create scheduled table of topic %s: %w", topic, err)
+		}
+	}
+	g.scheduledTopics.Set(topic, true)
+	return nil
+}
+
+// PublishAt 在 at 时间投递消息，消息先写入延迟表，到期后由订阅或延迟发布该 topic 的进程移动到消息表
+func (g *Channel) PublishAt(ctx context.Context, topic string, msg *message.Message, at time.Time) (string, error) {
+	if msg == nil {
+		return "", nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !at.After(time.Now()) {
+		return g.Publish(ctx, topic, msg)
+	}
+	// 只发布不订阅的进程也需要移动到期的消息
+	if err := g.ensureTopic(ctx, topic); err != nil {
+		return "", err
+	}
+	if err := g.startScheduledMover(topic); err != nil {
+		return "", err
+	}
+	if msg.UUID == "" {
+		msg.UUID = watermill.NewUUID()
+	}
+	newMsg := message.NewMessageWithContext(ctx, msg.UUID, msg.Payload)
+	for k, v := range msg.Metadata {
+		newMsg.Metadata.Set(k, v)
+	}
+	comm.InjectTrace(ctx, newMsg)
+	comm.SetDeliveryID(newMsg)
+	metadata, err := json.Marshal(newMsg.Metadata)
+	if err != nil {
+		return "", err
+	}
+	query := fmt.Sprintf("INSERT INTO %s (uuid, deliver_at, payload, metadata) VALUES (%s, %s, %s, %s)",
+		g.getScheduledTableFromNamespace(topic), g.placeholder(1), g.placeholder(2), g.placeholder(3), g.placeholder(4))
+	if _, err = g.sqlDb.ExecContext(ctx, query, newMsg.UUID, at.UnixMilli(), newMsg.Payload, metadata); err != nil {
+		return "", err
+	}
+	return newMsg.UUID, nil
+}
+
+// PublishAfter 在 delay 之后投递消息
+func (g *Channel) PublishAfter(ctx context.Context, topic string, msg *message.Message, delay time.Duration) (string, error) {
+	return g.PublishAt(ctx, topic, msg, time.Now().Add(delay))
+}
+
+// CancelScheduled 从延迟表中删除还没有投递的消息
+func (g *Channel) CancelScheduled(ctx context.Context, topic, id string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := g.ensureScheduledTable(ctx, topic); err != nil {
+		return err
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE uuid = %s", g.getScheduledTableFromNamespace(topic), g.placeholder(1))
+	result, err := g.sqlDb.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return comm.ErrScheduledNotFound
+	}
+	return nil
+}
+
+// startScheduledMover 订阅或者延迟发布 topic 后定时移动到期的延迟消息，每个 topic 只启动一次
+func (g *Channel) startScheduledMover(topic string) error {
+	if err := g.ensureScheduledTable(context.Background(), topic); err != nil {
+		return err
+	}
+	if !g.movers.SetIfAbsent(topic, true) {
+		return nil
+	}
+	// 只延迟发布时消息表可能还没有创建
+	queries, err := g.schemaAdapter().SchemaInitializingQueries(sql.SchemaInitializingQueriesParams{Topic: topic})
+	if err != nil {
+		g.movers.Remove(topic)
+		return err
+	}
+	for _, query := range queries {
+		if _, err = g.sqlDb.ExecContext(context.Background(), query.Query, query.Args...); err != nil {
+			g.movers.Remove(topic)
+			return fmt.Errorf("create messages table of topic %s: %w", topic, err)
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(schedulePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-g.done:
+				return
+			case <-ticker.C:
+				for {
+					moved, err := g.moveScheduled(context.Background(), topic)
+					if err != nil {
+						log.Printf("move scheduled messages of topic %s error: %v", topic, err)
+					}
+					if err != nil || moved < scheduleBatchSize {
+						break
+					}
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// moveScheduled 把到期的消息写入消息表，并从延迟表删除，返回移动的条数
+func (g *Channel) moveScheduled(ctx context.Context, topic string) (int, error) {
+	tx, err := g.sqlDb.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := fmt.Sprintf("SELECT id, uuid, payload, metadata FROM %s WHERE deliver_at <= %s ORDER BY deliver_at LIMIT %d %s",
+		g.getScheduledTableFromNamespace(topic), g.placeholder(1), scheduleBatchSize, g.lockClause(ctx))
+	rows, err := tx.QueryContext(ctx, query, time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	var ids []any
+	var msgs message.Messages
+	for rows.Next() {
+		var (
+			id       int64
+			uuid     string
+			payload  []byte
+			metadata []byte
+		)
+		if err = rows.Scan(&id, &uuid, &payload, &metadata); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		msg := message.NewMessage(uuid, payload)
+		if len(metadata) > 0 {
+			if err = json.Unmarshal(metadata, &msg.Metadata); err != nil {
+				log.Printf("unmarshal metadata of scheduled message %s error: %v", uuid, err)
+			}
+		}
+		if err = g.encodePayload(topic, msg); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		msgs = append(msgs, msg)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	insert, err := g.schemaAdapter().InsertQuery(sql.InsertQueryParams{Topic: topic, Msgs: msgs})
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx, insert.Query, insert.Args...); err != nil {
+		return 0, err
+	}
+	markers := make([]string, len(ids))
+	for i := range ids {
+		markers[i] = g.placeholder(i + 1)
+	}
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", g.getScheduledTableFromNamespace(topic), strings.Join(markers, ","))
+	if _, err = tx.ExecContext(ctx, deleteQuery, ids...); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(msgs), nil
+}
@@ -0,0 +1,121 @@
+package mysqlchannel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ThreeDotsLabs/watermill-sql/v4/pkg/sql"
+)
+
+const (
+	DialectMysql    = "mysql"
+	DialectPostgres = "postgres"
+
+	mysqlPayloadType    = "LONGBLOB"
+	postgresPayloadType = "BYTEA"
+)
+
+// mysqlSchema 消息表，payload 以二进制原样保存，metadata 保存为 JSON，created_at 加索引用于清理
+type mysqlSchema struct {
+	sql.DefaultMySQLSchema
+	indexName func(topic string) string
+}
+
+func (s mysqlSchema) SchemaInitializingQueries(params sql.SchemaInitializingQueriesParams) ([]sql.Query, error) {
+	createMessagesTable := strings.Join([]string{
+		"CREATE TABLE IF NOT EXISTS " + s.MessagesTable(params.Topic) + " (",
+		"`offset` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,",
+		"`uuid` VARCHAR(36) NOT NULL,",
+		"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,",
+		"`payload` " + mysqlPayloadType + " DEFAULT NULL,",
+		"`metadata` JSON DEFAULT NULL,",
+		"INDEX " + s.indexName(params.Topic) + " (`created_at`)",
+		");",
+	}, "\n")
+	return []sql.Query{{Query: createMessagesTable}}, nil
+}
+
+// postgresSchema 与 mysqlSchema 相同的表结构，payload 使用 BYTEA
+type postgresSchema struct {
+	sql.DefaultPostgreSQLSchema
+	indexName func(topic string) string
+}
+
+func (s postgresSchema) SchemaInitializingQueries(params sql.SchemaInitializingQueriesParams) ([]sql.Query, error) {
+	queries, err := s.DefaultPostgreSQLSchema.SchemaInitializingQueries(params)
+	if err != nil {
+		return nil, err
+	}
+	createIndex := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s ("created_at")`,
+		s.indexName(params.Topic), s.MessagesTable(params.Topic))
+	return append(queries, sql.Query{Query: createIndex}), nil
+}
+
+// quote 按数据库类型给表名、索引名加引号
+func (g *Channel) quote(name string) string {
+	if g.dialect == DialectPostgres {
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (g *Channel) getNamespace() string {
+	if g.namespace == "" {
+		g.namespace = "default"
+	}
+	return g.namespace
+}
+
+func (g *Channel) tableName(topic string) string {
+	return fmt.Sprintf("%s_%s", g.getNamespace(), topic)
+}
+
+func (g *Channel) offsetsTableName(topic string) string {
+	return fmt.Sprintf("%s_offsets_%s", g.getNamespace(), topic)
+}
+
+func (g *Channel) getTableFromNamespace(topic string) string {
+	return g.quote(g.tableName(topic))
+}
+
+func (g *Channel) getOffsetsTableFromNamespace(topic string) string {
+	return g.quote(g.offsetsTableName(topic))
+}
+
+func (g *Channel) getIndexFromNamespace(topic string) string {
+	return g.quote(g.tableName(topic) + "_created_at")
+}
+
+func (g *Channel) schemaAdapter() sql.SchemaAdapter {
+	if g.dialect == DialectPostgres {
+		return postgresSchema{
+			DefaultPostgreSQLSchema: sql.DefaultPostgreSQLSchema{
+				GenerateMessagesTableName: g.getTableFromNamespace,
+				GeneratePayloadType: func(string) string {
+					return postgresPayloadType
+				},
+			},
+			indexName: g.getIndexFromNamespace,
+		}
+	}
+	return mysqlSchema{
+		DefaultMySQLSchema: sql.DefaultMySQLSchema{
+			GenerateMessagesTableName: g.getTableFromNamespace,
+			GeneratePayloadType: func(string) string {
+				return mysqlPayloadType
+			},
+		},
+		indexName: g.getIndexFromNamespace,
+	}
+}
+
+func (g *Channel) offsetsAdapter() sql.OffsetsAdapter {
+	if g.dialect == DialectPostgres {
+		return sql.DefaultPostgreSQLOffsetsAdapter{
+			GenerateMessagesOffsetsTableName: g.getOffsetsTableFromNamespace,
+		}
+	}
+	return sql.DefaultMySQLOffsetsAdapter{
+		GenerateMessagesOffsetsTableName: g.getOffsetsTableFromNamespace,
+	}
+}
@@ -0,0 +1,80 @@
+package mysqlchannel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ThreeDotsLabs/watermill-sql/v4/pkg/sql"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestSchemaAdapter(t *testing.T) {
+	cases := []struct {
+		dialect string
+		table   string
+		payload string
+		index   string
+	}{
+		{DialectMysql, "`order_created`", "`payload` LONGBLOB", "INDEX `order_created_created_at`"},
+		{DialectPostgres, `"order_created"`, `"payload" BYTEA`, `CREATE INDEX IF NOT EXISTS "order_created_created_at"`},
+	}
+	for _, c := range cases {
+		g := newChannel(c.dialect).WithNamespace("order")
+		adapter := g.schemaAdapter()
+
+		queries, err := adapter.SchemaInitializingQueries(sql.SchemaInitializingQueriesParams{Topic: "created"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var all []string
+		for _, q := range queries {
+			all = append(all, q.Query)
+		}
+		schema := strings.Join(all, "\n")
+		for _, want := range []string{c.table, c.payload, c.index} {
+			if !strings.Contains(schema, want) {
+				t.Errorf("%s schema does not contain %q:\n%s", c.dialect, want, schema)
+			}
+		}
+
+		msg := message.NewMessage("m-1", []byte{0x00, 0xff, 'a'})
+		msg.Metadata.Set("trace", "1")
+		insert, err := adapter.InsertQuery(sql.InsertQueryParams{Topic: "created", Msgs: message.Messages{msg}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload, ok := insert.Args[1].([]byte); !ok || string(payload) != string(msg.Payload) {
+			t.Errorf("%s payload arg = %#v, want raw bytes", c.dialect, insert.Args[1])
+		}
+		if metadata, ok := insert.Args[2].([]byte); !ok || string(metadata) != `{"trace":"1"}` {
+			t.Errorf("%s metadata arg = %#v", c.dialect, insert.Args[2])
+		}
+
+		g.retention = RetentionConfig{ConsumedAge: 1, BatchSize: 10}
+		query, _ := g.deleteConsumedQuery("created", "5", g.retention)
+		if !strings.HasPrefix(query, "DELETE FROM "+c.table) {
+			t.Errorf("%s cleanup query = %s", c.dialect, query)
+		}
+	}
+}
+
+func TestSupportsSkipLocked(t *testing.T) {
+	cases := []struct {
+		version string
+		want    bool
+	}{
+		{"8.0.36", true},
+		{"8.4.0-log", true},
+		{"5.7.44-log", false},
+		{"5.6.51", false},
+		{"10.5.23-MariaDB", false},
+		{"10.6.16-MariaDB-1:10.6.16+maria~ubu2004", true},
+		{"11.2.2-MariaDB", true},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := supportsSkipLocked(c.version); got != c.want {
+			t.Errorf("supportsSkipLocked(%q) = %v, want %v", c.version, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package watermill
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/hashicorp/go-multierror"
+	cmap "github.com/orcaman/concurrent-map/v2"
+)
+
+type topicKey struct{}
+
+type handlerKey struct{}
+
+// handlerRef 当前执行的处理方法
+type handlerRef struct {
+	subscription string
+	index        int
+}
+
+// DeliveryIDKey 每次发布生成的投递ID，重新投递时保持不变，复用UUID的多次发布互不影响
+const DeliveryIDKey = "delivery_id"
+
+// Fallback 中间件和重试都失败后的兜底处理，返回nil表示已处理
+type Fallback func(ctx context.Context, msg *message.Message, handler HandlerFunc, err error) error
+
+// deliveryState 记录消息在各个处理方法上的处理结果，重新投递时只执行失败的处理方法
+type deliveryState struct {
+	mu       sync.Mutex
+	done     map[int]bool
+	attempts int
+}
+
+func (s *deliveryState) isDone(i int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done[i]
+}
+
+func (s *deliveryState) markDone(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done[i] = true
+}
+
+// nextAttempt 增加投递次数并返回
+func (s *deliveryState) nextAttempt() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	return s.attempts
+}
+
+// Router 按topic管理处理方法，负责中间件、重试、分发和失败处理，各个通道共用
+type Router struct {
+	handlers     cmap.ConcurrentMap[string, []HandlerFunc]
+	middlewares  []Middleware
+	retryTimes   int
+	retryBackoff Backoff
+	errorHandler func(msg *message.Message) error
+	fallback     Fallback
+	failure      FailureConfig
+	publisher    Publisher
+	mu           sync.RWMutex
+
+	deliveries   map[string]*deliveryState
+	deliveriesMu sync.Mutex
+}
+
+// NewRouter 默认按 DefaultBackoff 重试3次，仍然失败则确认消息
+func NewRouter() *Router {
+	return &Router{
+		handlers:     cmap.New[[]HandlerFunc](),
+		retryTimes:   3,
+		retryBackoff: DefaultBackoff,
+		failure:      FailureConfig{Policy: FailureAck},
+		deliveries:   make(map[string]*deliveryState),
+	}
+}
+
+// WithRetryTimes 处理失败后的重试次数
+func (r *Router) WithRetryTimes(retryTimes int) *Router {
+	r.retryTimes = retryTimes
+	return r
+}
+
+// WithRetryBackoff 处理失败后重试的间隔
+func (r *Router) WithRetryBackoff(backoff Backoff) *Router {
+	r.retryBackoff = backoff
+	return r
+}
+
+// WithFailure 重试后仍然失败时的处理方式
+func (r *Router) WithFailure(fc FailureConfig) *Router {
+	if fc.Policy == "" {
+		fc.Policy = FailureAck
+	}
+	if fc.Policy == FailurePoison && fc.PoisonTopic == "" {
+		log.Println("poison topic is empty, failed messages will be acked")
+		fc.Policy = FailureAck
+	}
+	r.failure = fc
+	return r
+}
+
+// WithPublisher 死信队列使用的发布者，一般是通道自身
+func (r *Router) WithPublisher(pub Publisher) *Router {
+	r.publisher = pub
+	return r
+}
+
+// WithErrorHandler 重试后仍然失败时执行
+func (r *Router) WithErrorHandler(handler func(msg *message.Message) error) *Router {
+	r.errorHandler = handler
+	return r
+}
+
+// WithFallback errorHandler 也失败时执行，比如写入重试表
+func (r *Router) WithFallback(fallback Fallback) *Router {
+	r.fallback = fallback
+	return r
+}
+
+// Use 添加中间件，先添加的在外层
+func (r *Router) Use(middlewares ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// AddHandler 注册topic的处理方法，第一次注册该topic时返回true，调用方需要开始消费
+func (r *Router) AddHandler(topic string, handler HandlerFunc) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if oldHandlers, ok := r.handlers.Get(topic); ok {
+		r.handlers.Set(topic, append(oldHandlers, handler))
+		return false
+	}
+	r.handlers.Set(topic, []HandlerFunc{handler})
+	return true
+}
+
+func (r *Router) chain(handler HandlerFunc) HandlerFunc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler = Retry(r.retryTimes, r.retryBackoff)(handler)
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	return handler
+}
+
+// Wrap 只使用中间件包装处理方法，不重试也不执行失败处理，供重试表等外部重试使用
+func (r *Router) Wrap(topic string, handler HandlerFunc) HandlerFunc {
+	r.mu.RLock()
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	r.mu.RUnlock()
+	return func(ctx context.Context, msg *message.Message) error {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return handler(context.WithValue(ctx, topicKey{}, topic), msg)
+	}
+}
+
+// Handle 使用中间件和重试执行单个处理方法
+func (r *Router) Handle(ctx context.Context, topic string, msg *message.Message, handler HandlerFunc) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = context.WithValue(ctx, topicKey{}, topic)
+	err := r.chain(handler)(ctx, msg)
+	if err == nil {
+		return nil
+	}
+	if r.errorHandler != nil {
+		if r.errorHandler(msg) == nil {
+			return nil
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback(ctx, msg, handler, err)
+	}
+	return err
+}
+
+// Deliver 把消息分发给topic的所有处理方法，并按失败策略确认或者 nack 消息。
+// 各个处理方法互相隔离，重新投递时已经成功的处理方法不会再执行
+func (r *Router) Deliver(ctx context.Context, topic string, msg *message.Message) {
+	allHandlers, ok := r.handlers.Get(topic)
+	if !ok {
+		log.Println("no handlers for topic: " + topic)
+		msg.Ack()
+		return
+	}
+	r.deliver(ctx, topic, topic, msg, allHandlers)
+}
+
+// DeliverHandler 单独订阅时使用，subscription 用来区分不同订阅的处理结果
+func (r *Router) DeliverHandler(ctx context.Context, subscription, topic string, msg *message.Message, handler HandlerFunc) {
+	r.deliver(ctx, subscription, topic, msg, []HandlerFunc{handler})
+}
+
+func (r *Router) deliver(ctx context.Context, subscription, topic string, msg *message.Message, allHandlers []HandlerFunc) {
+	key := subscription + "/" + deliveryID(msg)
+	state := r.deliveryState(key)
+	var retError error
+	for i, handler := range allHandlers {
+		if state.isDone(i) {
+			continue
+		}
+		handlerCtx := context.WithValue(ctx, handlerKey{}, handlerRef{subscription: subscription, index: i})
+		if err := r.Handle(handlerCtx, topic, msg, handler); err != nil {
+			retError = multierror.Append(retError, err)
+			continue
+		}
+		state.markDone(i)
+	}
+	if retError == nil {
+		r.forget(key)
+		msg.Ack()
+		return
+	}
+	r.onFailure(ctx, key, topic, msg, state, retError)
+}
+
+// SetDeliveryID 发布消息时生成新的投递ID，各个通道的 Publish 调用
+func SetDeliveryID(msg *message.Message) {
+	if msg == nil {
+		return
+	}
+	if msg.Metadata == nil {
+		msg.Metadata = make(message.Metadata)
+	}
+	msg.Metadata.Set(DeliveryIDKey, watermill.NewUUID())
+}
+
+// deliveryID 没有投递ID时（比如旧版本发布的消息）退回使用UUID
+func deliveryID(msg *message.Message) string {
+	if id := msg.Metadata.Get(DeliveryIDKey); id != "" {
+		return id
+	}
+	return msg.UUID
+}
+
+func (r *Router) deliveryState(key string) *deliveryState {
+	r.deliveriesMu.Lock()
+	defer r.deliveriesMu.Unlock()
+	if state, ok := r.deliveries[key]; ok {
+		return state
+	}
+	state := &deliveryState{done: make(map[int]bool)}
+	r.deliveries[key] = state
+	return state
+}
+
+func (r *Router) forget(key string) {
+	r.deliveriesMu.Lock()
+	defer r.deliveriesMu.Unlock()
+	delete(r.deliveries, key)
+}
+
+// TopicFromContext 获取当前处理的topic
+func TopicFromContext(ctx context.Context) string {
+	topic, _ := ctx.Value(topicKey{}).(string)
+	return topic
+}
+
+// HandlerFromContext 获取当前处理方法所属的订阅和在订阅中的序号，Subscribe 注册的订阅名就是topic
+func HandlerFromContext(ctx context.Context) (subscription string, index int, ok bool) {
+	ref, ok := ctx.Value(handlerKey{}).(handlerRef)
+	return ref.subscription, ref.index, ok
+}
+
+// HandlerCount topic已经注册的处理方法数量，新注册的处理方法序号与注册前的数量相同
+func (r *Router) HandlerCount(topic string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handlers, _ := r.handlers.Get(topic)
+	return len(handlers)
+}
+
+// RemoveHandlers 删除topic的所有处理方法，订阅失败时调用，以便下次重新订阅
+func (r *Router) RemoveHandlers(topic string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers.Remove(topic)
+}
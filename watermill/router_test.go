@@ -0,0 +1,153 @@
+package watermill
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// acked 返回消息是否被确认，false 表示 nack
+func acked(t *testing.T, msg *message.Message) bool {
+	t.Helper()
+	select {
+	case <-msg.Acked():
+		return true
+	case <-msg.Nacked():
+		return false
+	case <-time.After(time.Second):
+		t.Fatalf("message %s neither acked nor nacked", msg.UUID)
+		return false
+	}
+}
+
+func newDelivery(uuid string) *message.Message {
+	msg := message.NewMessage(uuid, []byte("payload"))
+	SetDeliveryID(msg)
+	return msg
+}
+
+func TestDeliverReusedUUID(t *testing.T) {
+	r := NewRouter().WithRetryTimes(0).WithFailure(FailureConfig{
+		Policy:  FailureNack,
+		Backoff: Backoff{InitialInterval: time.Microsecond},
+	})
+	var first, second atomic.Int32
+	failOnce := atomic.Bool{}
+	r.AddHandler("orders", func(ctx context.Context, msg *message.Message) error {
+		first.Add(1)
+		return nil
+	})
+	r.AddHandler("orders", func(ctx context.Context, msg *message.Message) error {
+		second.Add(1)
+		if failOnce.CompareAndSwap(false, true) {
+			return fmt.Errorf("fail once")
+		}
+		return nil
+	})
+
+	msgA := newDelivery("axeee")
+	r.Deliver(context.Background(), "orders", msgA)
+	if acked(t, msgA) {
+		t.Fatal("first delivery should be nacked")
+	}
+
+	// 复用UUID的另一次发布，两个处理方法都要执行
+	msgB := newDelivery("axeee")
+	r.Deliver(context.Background(), "orders", msgB)
+	if !acked(t, msgB) {
+		t.Fatal("second publish should be acked")
+	}
+	if first.Load() != 2 || second.Load() != 2 {
+		t.Fatalf("handlers called %d/%d times, want 2/2", first.Load(), second.Load())
+	}
+
+	// 重新投递时只执行失败的处理方法
+	redelivered := msgA.Copy()
+	r.Deliver(context.Background(), "orders", redelivered)
+	if !acked(t, redelivered) {
+		t.Fatal("redelivery should be acked")
+	}
+	if first.Load() != 2 || second.Load() != 3 {
+		t.Fatalf("handlers called %d/%d times, want 2/3", first.Load(), second.Load())
+	}
+	if len(r.deliveries) != 0 {
+		t.Errorf("%d delivery states left", len(r.deliveries))
+	}
+}
+
+func TestDeliverConcurrent(t *testing.T) {
+	r := NewRouter().WithRetryTimes(0)
+	for i := 0; i < 3; i++ {
+		r.AddHandler("orders", func(ctx context.Context, msg *message.Message) error {
+			return nil
+		})
+	}
+
+	// 同一次投递被后端重复投递，并发处理时不能出现数据竞争
+	msg := newDelivery("axeee")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Deliver(context.Background(), "orders", msg.Copy())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDefaultMaxRedelivery(t *testing.T) {
+	r := NewRouter().WithRetryTimes(0).WithFailure(FailureConfig{
+		Policy:  FailureNack,
+		Backoff: Backoff{InitialInterval: time.Microsecond},
+	})
+	var calls int
+	r.AddHandler("orders", func(ctx context.Context, msg *message.Message) error {
+		calls++
+		return fmt.Errorf("poison message")
+	})
+
+	msg := newDelivery("poison")
+	for i := 0; i < DefaultMaxRedelivery; i++ {
+		delivery := msg.Copy()
+		r.Deliver(context.Background(), "orders", delivery)
+		if acked(t, delivery) {
+			t.Fatalf("delivery %d should be nacked", i+1)
+		}
+	}
+	// 超过默认次数后不再 nack
+	delivery := msg.Copy()
+	r.Deliver(context.Background(), "orders", delivery)
+	if !acked(t, delivery) {
+		t.Fatal("message should be acked after the default max redelivery")
+	}
+	if calls != DefaultMaxRedelivery+1 {
+		t.Errorf("handler called %d times, want %d", calls, DefaultMaxRedelivery+1)
+	}
+}
+
+func TestHandlerFromContext(t *testing.T) {
+	r := NewRouter().WithRetryTimes(0)
+	var got []string
+	for i := 0; i < 2; i++ {
+		if n := r.HandlerCount("orders"); n != i {
+			t.Fatalf("HandlerCount() = %d, want %d", n, i)
+		}
+		r.AddHandler("orders", func(ctx context.Context, msg *message.Message) error {
+			subscription, index, ok := HandlerFromContext(ctx)
+			if ok {
+				got = append(got, fmt.Sprintf("%s#%d", subscription, index))
+			}
+			return nil
+		})
+	}
+	r.Deliver(context.Background(), "orders", newDelivery("1"))
+	if fmt.Sprint(got) != "[orders#0 orders#1]" {
+		t.Errorf("handlers = %v, want [orders#0 orders#1]", got)
+	}
+}
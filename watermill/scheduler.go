@@ -0,0 +1,119 @@
+package watermill
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/zeromicro/go-zero/core/collection"
+)
+
+const (
+	// DefaultScheduleInterval 时间轮的精度
+	DefaultScheduleInterval = 100 * time.Millisecond
+	scheduleSlots           = 600
+)
+
+// ErrScheduledNotFound 取消的消息不存在或者已经投递
+var ErrScheduledNotFound = errors.New("scheduled message not found or already delivered")
+
+type scheduledMessage struct {
+	ctx   context.Context
+	topic string
+	msg   *message.Message
+}
+
+// MemoryScheduler 基于时间轮的延迟发布，消息保存在内存中，进程退出后未发布的消息会丢失
+type MemoryScheduler struct {
+	pub     Publisher
+	wheel   *collection.TimingWheel
+	mu      sync.Mutex
+	pending map[string]*scheduledMessage
+}
+
+var _ Scheduler = (*MemoryScheduler)(nil)
+
+// NewMemoryScheduler 到期后通过 pub 发布消息，interval 为时间轮精度，为0时使用 DefaultScheduleInterval
+func NewMemoryScheduler(pub Publisher, interval time.Duration) (*MemoryScheduler, error) {
+	if interval <= 0 {
+		interval = DefaultScheduleInterval
+	}
+	s := &MemoryScheduler{
+		pub:     pub,
+		pending: make(map[string]*scheduledMessage),
+	}
+	wheel, err := collection.NewTimingWheel(interval, scheduleSlots, s.execute)
+	if err != nil {
+		return nil, err
+	}
+	s.wheel = wheel
+	return s, nil
+}
+
+func scheduleKey(topic, id string) string {
+	return topic + "/" + id
+}
+
+func (s *MemoryScheduler) PublishAt(ctx context.Context, topic string, msg *message.Message, at time.Time) (string, error) {
+	return s.PublishAfter(ctx, topic, msg, time.Until(at))
+}
+
+func (s *MemoryScheduler) PublishAfter(ctx context.Context, topic string, msg *message.Message, delay time.Duration) (string, error) {
+	if msg == nil {
+		return "", nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if delay <= 0 {
+		return s.pub.Publish(ctx, topic, msg)
+	}
+	if msg.UUID == "" {
+		msg.UUID = watermill.NewUUID()
+	}
+	key := scheduleKey(topic, msg.UUID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 发布时ctx可能已经结束，这里只保留ctx中的值，比如链路信息
+	scheduled := &scheduledMessage{ctx: context.WithoutCancel(ctx), topic: topic, msg: msg}
+	if err := s.wheel.SetTimer(key, scheduled, delay); err != nil {
+		return "", err
+	}
+	s.pending[key] = scheduled
+	return msg.UUID, nil
+}
+
+func (s *MemoryScheduler) CancelScheduled(_ context.Context, topic, id string) error {
+	key := scheduleKey(topic, id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[key]; !ok {
+		return ErrScheduledNotFound
+	}
+	delete(s.pending, key)
+	return s.wheel.RemoveTimer(key)
+}
+
+func (s *MemoryScheduler) execute(key, value any) {
+	scheduled := value.(*scheduledMessage)
+	s.mu.Lock()
+	// 已经取消，或者被同ID的消息替换
+	if s.pending[key.(string)] != scheduled {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.pending, key.(string))
+	s.mu.Unlock()
+	if _, err := s.pub.Publish(scheduled.ctx, scheduled.topic, scheduled.msg); err != nil {
+		log.Printf("publish scheduled message %s of topic %s error: %v", scheduled.msg.UUID, scheduled.topic, err)
+	}
+}
+
+// Stop 停止时间轮，未发布的消息会丢弃
+func (s *MemoryScheduler) Stop() {
+	s.wheel.Stop()
+}